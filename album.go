@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Telegram delivers an album as one message per photo sharing a
+// MediaGroupID, with the caption on just one of them. The photos are
+// collected until none has arrived for albumWindow and reach the AI
+// together, so it answers the album once instead of once per photo.
+
+// albumWindow is how long an album waits for its next photo.
+const albumWindow = time.Second
+
+// album is the part of an album received so far.
+type album struct {
+	photos  []tgbotapi.PhotoSize // the largest size of each photo
+	caption string
+	more    chan struct{} // signalled when a photo arrives
+}
+
+// albumCollector gathers the photos of albums by MediaGroupID.
+type albumCollector struct {
+	window time.Duration
+	mu     sync.Mutex
+	albums map[string]*album
+}
+
+func newAlbumCollector(window time.Duration) *albumCollector {
+	return &albumCollector{window: window, albums: make(map[string]*album)}
+}
+
+// Add records a photo of album id. It returns true for the album's first
+// photo, whose caller must then Wait for the rest.
+func (c *albumCollector) Add(id string, photo tgbotapi.PhotoSize, caption string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	a, ok := c.albums[id]
+	if !ok {
+		a = &album{more: make(chan struct{}, 1)}
+		c.albums[id] = a
+	}
+	a.photos = append(a.photos, photo)
+	if a.caption == "" {
+		a.caption = caption
+	}
+	select {
+	case a.more <- struct{}{}:
+	default:
+	}
+	return !ok
+}
+
+// Wait returns album id's photos and caption once no photo has arrived for
+// the window.
+func (c *albumCollector) Wait(id string) ([]tgbotapi.PhotoSize, string) {
+	c.mu.Lock()
+	a := c.albums[id]
+	c.mu.Unlock()
+	if a == nil {
+		return nil, ""
+	}
+	for {
+		select {
+		case <-a.more:
+			continue
+		case <-time.After(c.window):
+		}
+		c.mu.Lock()
+		// A photo may have come in just as the window ran out.
+		if len(a.more) > 0 {
+			c.mu.Unlock()
+			continue
+		}
+		delete(c.albums, id)
+		c.mu.Unlock()
+		return a.photos, a.caption
+	}
+}
+
+// HandlePhotos processes one or more photos sent together, e.g. an album.
+// photos holds the largest size of each.
+func (h *Handlers) HandlePhotos(ctx context.Context, chatID int64, photos []tgbotapi.PhotoSize, caption string) {
+	unlock, ok := h.lockFresh(ctx, chatID, "photo", caption)
+	if !ok {
+		return
+	}
+	defer unlock()
+
+	slog.Info("received photo message", "chat_id", chatID, "photos", len(photos))
+
+	if h.rejectIfFrozen(chatID) || h.rejectIfOverQuota(ctx, chatID) {
+		return
+	}
+
+	if h.approvals.Has(chatID) {
+		h.sender.SendPlain(chatID, h.t(chatID, "approval.pending_first"))
+		return
+	}
+
+	h.sender.SendTyping(chatID)
+
+	paths := make([]string, 0, len(photos))
+	defer func() { h.media.Cleanup(paths...) }()
+	for _, photo := range photos {
+		path, ok := h.downloadMedia(chatID, "photo", photo.FileID, "jpg", photo.FileSize)
+		if !ok {
+			return
+		}
+		paths = append(paths, path)
+	}
+
+	message := fmt.Sprintf("The user sent an image saved at %s. Please read and analyze it.", paths[0])
+	if len(paths) > 1 {
+		message = fmt.Sprintf("The user sent %d images saved at %s. Please read and analyze them.", len(paths), strings.Join(paths, ", "))
+	}
+	if caption != "" {
+		message += fmt.Sprintf("\nUser's message: %s", caption)
+	}
+
+	h.callAI(ctx, chatID, message)
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestAlbumCollector(t *testing.T) {
+	c := newAlbumCollector(200 * time.Millisecond)
+	if !c.Add("g1", tgbotapi.PhotoSize{FileID: "a"}, "") {
+		t.Fatal("first photo is not the album's leader")
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		// Each photo arrives within the window of the previous one.
+		for _, id := range []string{"b", "c"} {
+			time.Sleep(30 * time.Millisecond)
+			caption := ""
+			if id == "b" {
+				caption = "compare these"
+			}
+			if c.Add("g1", tgbotapi.PhotoSize{FileID: id}, caption) {
+				t.Errorf("photo %s started a new album", id)
+			}
+		}
+	}()
+
+	photos, caption := c.Wait("g1")
+	wg.Wait()
+	if len(photos) != 3 || photos[0].FileID != "a" || photos[2].FileID != "c" {
+		t.Errorf("photos = %+v", photos)
+	}
+	if caption != "compare these" {
+		t.Errorf("caption = %q", caption)
+	}
+
+	// The album is done: the same ID starts over.
+	if !c.Add("g1", tgbotapi.PhotoSize{FileID: "d"}, "") {
+		t.Error("a finished album was extended")
+	}
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// BlockedAttempt describes a command the safeguard rejected. Without an
+// Approver the bot was auto-executing (SKIP_PERMISSIONS) and nobody reviewed
+// the command before it was attempted, so it may indicate prompt injection
+// or a compromised account.
+type BlockedAttempt struct {
+	ChatID    int64
+	Provider  string
+	SessionID string
+	Prompt    string
+	Command   string
+	Reason    string
+	Approver  string // who approved the command on its card, if anyone
+}
+
+// notifyAdmin sends a plain-text message to the admin chat, if one is configured.
+func (h *Handlers) notifyAdmin(text string) {
+	if h.adminChatID == 0 {
+		return
+	}
+	h.sender.SendPlain(h.adminChatID, text)
+}
+
+// reportBlocked escalates a blocked command to the admin chat and, when
+// FREEZE_ON_BLOCK is set and it was auto-executed, freezes the originating
+// chat. Returns true if the chat is now frozen.
+func (h *Handlers) reportBlocked(a BlockedAttempt) bool {
+	slog.Warn("ALERT: blocked command", "chat_id", a.ChatID, "provider", a.Provider,
+		"session", a.SessionID, "command", a.Command, "reason", a.Reason, "approver", a.Approver)
+
+	frozen := false
+	if h.freezeOnBlock && a.Approver == "" {
+		frozen = h.frozen.Freeze(a.ChatID, "safeguard blocked: "+a.Command)
+	}
+
+	if h.adminChatID == 0 {
+		return frozen
+	}
+
+	sessionID := a.SessionID
+	if sessionID == "" {
+		sessionID = h.t(h.adminChatID, "alert.no_session")
+	}
+	prompt := truncateText(a.Prompt, 1000)
+
+	admin := h.adminChatID
+	var b strings.Builder
+	if a.Approver != "" {
+		b.WriteString(h.t(admin, "alert.blocked_approved"))
+	} else {
+		b.WriteString(h.t(admin, "alert.blocked_auto"))
+	}
+	b.WriteString(h.t(admin, "alert.chat", a.ChatID))
+	if a.Approver != "" {
+		b.WriteString(h.t(admin, "alert.approved_by", a.Approver))
+	}
+	b.WriteString(h.t(admin, "alert.blocked_body", a.Provider, sessionID, time.Now().UTC().Format(time.RFC3339), a.Command, a.Reason))
+	if prompt != "" {
+		b.WriteString(h.t(admin, "alert.prompt", prompt))
+	}
+
+	if !frozen {
+		h.notifyAdmin(b.String())
+		return false
+	}
+
+	b.WriteString(h.t(admin, "alert.frozen"))
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(h.t(admin, "alert.unfreeze_button"), "unfreeze:"+strconv.FormatInt(a.ChatID, 10)),
+		),
+	)
+	h.sender.SendWithKeyboard(h.adminChatID, b.String(), keyboard)
+	return true
+}
+
+// rejectIfFrozen tells the user their chat is frozen and returns true if so.
+func (h *Handlers) rejectIfFrozen(chatID int64) bool {
+	if _, ok := h.frozen.Get(chatID); !ok {
+		return false
+	}
+	slog.Info("rejected: chat is frozen", "chat_id", chatID)
+	h.sender.SendPlain(chatID, h.t(chatID, "frozen"))
+	return true
+}
+
+// handleUnfreezeCallback lifts a freeze from the admin alert's inline button.
+func (h *Handlers) handleUnfreezeCallback(chatID int64, callbackID, data string, messageID int) {
+	if !h.isAdmin(chatID) {
+		h.sender.AnswerCallback(callbackID, h.t(chatID, "unfreeze.admin_only"))
+		return
+	}
+	target, err := strconv.ParseInt(strings.TrimPrefix(data, "unfreeze:"), 10, 64)
+	if err != nil {
+		h.sender.AnswerCallback(callbackID, h.t(chatID, "unfreeze.invalid"))
+		return
+	}
+	if !h.frozen.Unfreeze(target) {
+		h.sender.AnswerCallback(callbackID, h.t(chatID, "unfreeze.callback_not_frozen"))
+		return
+	}
+	slog.Info("unfrozen by admin", "chat_id", target)
+	h.sender.AnswerCallback(callbackID, h.t(chatID, "unfreeze.callback"))
+	h.sender.EditRemoveKeyboard(chatID, messageID, h.t(chatID, "unfreeze.card", target))
+	h.sender.SendPlain(target, h.t(target, "unfreeze.notice"))
+}
+
+// UnauthorizedContact describes a message or button press from a chat that
+// is not allowed to use the bot.
+type UnauthorizedContact struct {
+	ChatID    int64
+	ChatTitle string // group or channel title; empty for private chats
+	From      string // sender's display name
+	Text      string // message text, command or button data
+}
+
+// unauthorizedAlertEvery limits unauthorized-contact alerts to one per
+// chat in this period, so a persistent stranger cannot flood the admin.
+const unauthorizedAlertEvery = time.Hour
+
+// AlertLimiter remembers when each chat last caused an alert.
+type AlertLimiter struct {
+	mu   sync.Mutex
+	last map[int64]time.Time
+}
+
+func NewAlertLimiter() *AlertLimiter {
+	return &AlertLimiter{last: make(map[int64]time.Time)}
+}
+
+// Allow reports whether chatID may cause an alert at now, and if so
+// records it.
+func (l *AlertLimiter) Allow(chatID int64, now time.Time, every time.Duration) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if last, ok := l.last[chatID]; ok && now.Sub(last) < every {
+		return false
+	}
+	l.last[chatID] = now
+	return true
+}
+
+// reportUnauthorized tells the admin chat that a chat outside the
+// allowlist contacted the bot.
+func (h *Handlers) reportUnauthorized(c UnauthorizedContact) {
+	if h.adminChatID == 0 || !h.unauthAlerts.Allow(c.ChatID, time.Now(), unauthorizedAlertEvery) {
+		return
+	}
+	text := truncateText(c.Text, 500)
+	admin := h.adminChatID
+	var b strings.Builder
+	b.WriteString(h.t(admin, "alert.unauth_title"))
+	b.WriteString(h.t(admin, "alert.unauth_chat", c.ChatID))
+	if c.ChatTitle != "" {
+		fmt.Fprintf(&b, " (%s)", c.ChatTitle)
+	}
+	if c.From != "" {
+		b.WriteString(h.t(admin, "alert.unauth_from", c.From))
+	}
+	b.WriteString(h.t(admin, "alert.unauth_time", time.Now().UTC().Format(time.RFC3339)))
+	if text != "" {
+		b.WriteString(h.t(admin, "alert.unauth_message", text))
+	}
+	b.WriteString(h.t(admin, "alert.unauth_footer", c.ChatID))
+	h.notifyAdmin(b.String())
+}
+
+// reportMaxRounds tells the user and the admin chat that auto-execution
+// stopped at the chat's round limit. pending are the commands the AI asked
+// for next, which were not run.
+func (h *Handlers) reportMaxRounds(chatID int64, provider, prompt string, pending []string) {
+	rounds := h.maxRoundsFor(chatID)
+	slog.Warn("hit max tool rounds, stopping", "chat_id", chatID, "provider", provider, "max_rounds", rounds)
+	h.sender.SendPlain(chatID, h.t(chatID, "alert.rounds_stopped"))
+	if h.adminChatID == 0 {
+		return
+	}
+	prompt = truncateText(prompt, 1000)
+	admin := h.adminChatID
+	var b strings.Builder
+	b.WriteString(h.t(admin, "alert.rounds_title"))
+	b.WriteString(h.t(admin, "alert.rounds_body", chatID, provider, rounds, time.Now().UTC().Format(time.RFC3339)))
+	if len(pending) > 0 {
+		b.WriteString(h.t(admin, "alert.rounds_pending", strings.Join(pending, "\n")))
+	}
+	if prompt != "" {
+		b.WriteString(h.t(admin, "alert.prompt", prompt))
+	}
+	h.notifyAdmin(b.String())
+}
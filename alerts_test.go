@@ -0,0 +1,23 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAlertLimiter(t *testing.T) {
+	l := NewAlertLimiter()
+	now := time.Now()
+	if !l.Allow(1, now, time.Hour) {
+		t.Fatal("first alert suppressed")
+	}
+	if l.Allow(1, now.Add(30*time.Minute), time.Hour) {
+		t.Error("second alert within the hour allowed")
+	}
+	if !l.Allow(2, now.Add(30*time.Minute), time.Hour) {
+		t.Error("other chat suppressed")
+	}
+	if !l.Allow(1, now.Add(61*time.Minute), time.Hour) {
+		t.Error("alert after the hour suppressed")
+	}
+}
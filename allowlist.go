@@ -0,0 +1,227 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The admin can change who may use the bot with /allow and /revoke. The
+// changes are kept in DATA_DIR on top of ALLOWED_CHAT_IDS: an added chat
+// is allowed even if the variable does not list it, a revoked one is
+// refused even if it does.
+
+// AllowEntry records who changed a chat's access and when.
+type AllowEntry struct {
+	By   int64     `json:"by"`
+	At   time.Time `json:"at"`
+	Note string    `json:"note,omitempty"`
+}
+
+// AllowlistStore holds the runtime allowlist changes, persisted to DATA_DIR.
+type AllowlistStore struct {
+	mu    sync.RWMutex
+	path  string
+	state struct {
+		Added   map[int64]AllowEntry `json:"added"`
+		Revoked map[int64]AllowEntry `json:"revoked"`
+	}
+}
+
+func NewAllowlistStore(dataDir string) *AllowlistStore {
+	s := &AllowlistStore{path: filepath.Join(dataDir, "allowlist.json")}
+	if err := loadJSONFile(s.path, &s.state); err != nil {
+		slog.Warn("failed to load allowlist", "path", s.path, "err", err)
+	}
+	if s.state.Added == nil {
+		s.state.Added = make(map[int64]AllowEntry)
+	}
+	if s.state.Revoked == nil {
+		s.state.Revoked = make(map[int64]AllowEntry)
+	}
+	return s
+}
+
+// Allowed applies the runtime changes to whether the configuration allows
+// chatID. A nil store changes nothing.
+func (s *AllowlistStore) Allowed(chatID int64, configured bool) bool {
+	if s == nil {
+		return configured
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if _, ok := s.state.Revoked[chatID]; ok {
+		return false
+	}
+	_, added := s.state.Added[chatID]
+	return configured || added
+}
+
+// Allow grants chatID access. configured says whether ALLOWED_CHAT_IDS
+// already lists it, in which case only a revocation is lifted.
+func (s *AllowlistStore) Allow(chatID int64, configured bool, e AllowEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.state.Revoked, chatID)
+	if !configured {
+		s.state.Added[chatID] = e
+	}
+	return saveJSONFile(s.path, s.state)
+}
+
+// Revoke withdraws chatID's access. configured says whether
+// ALLOWED_CHAT_IDS lists it, in which case the revocation is recorded.
+func (s *AllowlistStore) Revoke(chatID int64, configured bool, e AllowEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.state.Added, chatID)
+	if configured {
+		s.state.Revoked[chatID] = e
+	}
+	return saveJSONFile(s.path, s.state)
+}
+
+// Changes returns copies of the added and revoked chats.
+func (s *AllowlistStore) Changes() (added, revoked map[int64]AllowEntry) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	added = make(map[int64]AllowEntry, len(s.state.Added))
+	for id, e := range s.state.Added {
+		added[id] = e
+	}
+	revoked = make(map[int64]AllowEntry, len(s.state.Revoked))
+	for id, e := range s.state.Revoked {
+		revoked[id] = e
+	}
+	return added, revoked
+}
+
+// parseAllowArgs reads "<chatID> [note]".
+func parseAllowArgs(args string) (chatID int64, note string, err error) {
+	idText, note, _ := strings.Cut(strings.TrimSpace(args), " ")
+	chatID, err = strconv.ParseInt(idText, 10, 64)
+	if err != nil || chatID == 0 {
+		return 0, "", fmt.Errorf("%q is not a chat ID", idText)
+	}
+	return chatID, strings.TrimSpace(note), nil
+}
+
+// HandleAllow lists the allowed chats, or gives a chat access (admin only).
+// Usage: /allow | /allow <chatID> [note]
+func (h *Handlers) HandleAllow(chatID int64, args string) {
+	if !h.isAdmin(chatID) {
+		h.sender.SendPlain(chatID, h.t(chatID, "admin_only"))
+		return
+	}
+	if strings.TrimSpace(args) == "" {
+		h.sender.SendPlain(chatID, h.allowlistText(chatID))
+		return
+	}
+	target, note, err := parseAllowArgs(args)
+	if err != nil {
+		h.sendArgError(chatID, "allow", err)
+		return
+	}
+	if h.IsAllowed(target) {
+		h.sender.SendPlain(chatID, h.t(chatID, "allow.exists", target))
+		return
+	}
+	if err := h.allowlist.Allow(target, h.configured(target), AllowEntry{By: chatID, At: time.Now(), Note: note}); err != nil {
+		h.sender.SendPlain(chatID, h.t(chatID, "allow.save_failed", err))
+		return
+	}
+	slog.Info("chat allowed", "chat_id", target, "by", chatID, "note", note)
+	h.sender.SendPlain(chatID, h.t(chatID, "allow.done", target))
+	h.sender.SendPlain(target, h.t(target, "allow.notice"))
+}
+
+// HandleRevoke withdraws a chat's access (admin only).
+// Usage: /revoke <chatID> [note]
+func (h *Handlers) HandleRevoke(chatID int64, args string) {
+	if !h.isAdmin(chatID) {
+		h.sender.SendPlain(chatID, h.t(chatID, "admin_only"))
+		return
+	}
+	target, note, err := parseAllowArgs(args)
+	if err != nil {
+		h.sendArgError(chatID, "revoke", err)
+		return
+	}
+	if h.isAdmin(target) {
+		h.sender.SendPlain(chatID, h.t(chatID, "revoke.admin"))
+		return
+	}
+	if !h.IsAllowed(target) {
+		h.sender.SendPlain(chatID, h.t(chatID, "revoke.none", target))
+		return
+	}
+	if err := h.allowlist.Revoke(target, h.configured(target), AllowEntry{By: chatID, At: time.Now(), Note: note}); err != nil {
+		h.sender.SendPlain(chatID, h.t(chatID, "allow.save_failed", err))
+		return
+	}
+	// Drop what the chat left waiting, so nothing runs on its behalf.
+	h.approvals.Delete(target)
+	slog.Info("chat revoked", "chat_id", target, "by", chatID, "note", note)
+	h.sender.SendPlain(chatID, h.t(chatID, "revoke.done", target))
+}
+
+// allowlistText lists the chats with access and the runtime changes, in
+// chatID's language.
+func (h *Handlers) allowlistText(chatID int64) string {
+	added, revoked := h.allowlist.Changes()
+	h.liveMu.RLock()
+	configured := make([]int64, 0, len(h.allowed))
+	for id, ok := range h.allowed {
+		if ok {
+			configured = append(configured, id)
+		}
+	}
+	h.liveMu.RUnlock()
+	slices.Sort(configured)
+
+	var b strings.Builder
+	b.WriteString(h.t(chatID, "allow.title"))
+	for _, id := range configured {
+		if e, ok := revoked[id]; ok {
+			b.WriteString(h.t(chatID, "allow.revoked_entry", id, e.At.Format("2006-01-02"), allowNote(e)))
+		} else {
+			fmt.Fprintf(&b, "  %d\n", id)
+		}
+	}
+	if len(configured) == 0 {
+		b.WriteString(h.t(chatID, "allow.none"))
+	}
+	ids := make([]int64, 0, len(added))
+	for id := range added {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	if len(ids) > 0 {
+		b.WriteString(h.t(chatID, "allow.added"))
+		for _, id := range ids {
+			e := added[id]
+			fmt.Fprintf(&b, "  %d (%s%s)\n", id, e.At.Format("2006-01-02"), allowNote(e))
+		}
+	}
+	admins := slices.Sorted(maps.Keys(h.admins))
+	if len(admins) > 0 {
+		b.WriteString(h.t(chatID, "allow.admins"))
+		for _, id := range admins {
+			fmt.Fprintf(&b, " %d", id)
+		}
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+func allowNote(e AllowEntry) string {
+	if e.Note == "" {
+		return ""
+	}
+	return ": " + e.Note
+}
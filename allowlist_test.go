@@ -0,0 +1,56 @@
+package main
+
+import "testing"
+
+func TestAllowlistStore(t *testing.T) {
+	dir := t.TempDir()
+	s := NewAllowlistStore(dir)
+	if s.Allowed(1, false) || !s.Allowed(2, true) {
+		t.Fatal("empty store should follow ALLOWED_CHAT_IDS")
+	}
+	if err := s.Allow(1, false, AllowEntry{By: 9, Note: "alice"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Revoke(2, true, AllowEntry{By: 9}); err != nil {
+		t.Fatal(err)
+	}
+	if !s.Allowed(1, false) || s.Allowed(2, true) {
+		t.Error("changes not applied")
+	}
+
+	// The changes survive a restart.
+	s = NewAllowlistStore(dir)
+	if !s.Allowed(1, false) || s.Allowed(2, true) {
+		t.Error("changes not persisted")
+	}
+	added, revoked := s.Changes()
+	if added[1].Note != "alice" || len(revoked) != 1 {
+		t.Errorf("added %v, revoked %v", added, revoked)
+	}
+
+	// Revoking an added chat forgets it; allowing a configured one lifts
+	// the revocation.
+	s.Revoke(1, false, AllowEntry{})
+	s.Allow(2, true, AllowEntry{})
+	added, revoked = s.Changes()
+	if len(added) != 0 || len(revoked) != 0 || s.Allowed(1, false) || !s.Allowed(2, true) {
+		t.Errorf("added %v, revoked %v", added, revoked)
+	}
+
+	var nilStore *AllowlistStore
+	if !nilStore.Allowed(3, true) || nilStore.Allowed(3, false) {
+		t.Error("nil store should follow ALLOWED_CHAT_IDS")
+	}
+}
+
+func TestParseAllowArgs(t *testing.T) {
+	id, note, err := parseAllowArgs(" -100123  team  group ")
+	if err != nil || id != -100123 || note != "team  group" {
+		t.Errorf("got %d %q %v", id, note, err)
+	}
+	for _, args := range []string{"", "abc", "0"} {
+		if _, _, err := parseAllowArgs(args); err == nil {
+			t.Errorf("%q accepted", args)
+		}
+	}
+}
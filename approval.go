@@ -2,34 +2,65 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"log/slog"
+	"path/filepath"
 	"sync"
 	"time"
 )
 
 // CommandResult stores the outcome of one approved/denied command.
 type CommandResult struct {
-	Command  string
-	Approved bool
-	Output   string
+	Command  string `json:"command"`
+	Approved bool   `json:"approved"`
+	Output   string `json:"output,omitempty"`
 }
 
 // PendingTurn holds all pending commands for a single AI response.
 type PendingTurn struct {
-	Commands   []string
-	CurrentIdx int
-	Results    []CommandResult
-	SessionID  string
-	Provider   string // "claude" or "gemini"
+	Commands   []string        `json:"commands"`
+	CurrentIdx int             `json:"current_idx"`
+	Results    []CommandResult `json:"results"`
+	SessionID  string          `json:"session_id,omitempty"`
+	Provider   string          `json:"provider"`            // "claude" or "gemini"
+	Cards      map[int64]int   `json:"cards,omitempty"`     // chatID → message ID of the current approval card
+	Manual     bool            `json:"manual,omitempty"`    // started by the user (e.g. /make); results are not sent to the AI
+	ShownAt    time.Time       `json:"shown_at"`            // when the current command's card was shown
+	Escalated  bool            `json:"escalated,omitempty"` // the current command was escalated (APPROVAL_SLA)
+	Running    bool            `json:"running,omitempty"`   // the current command was approved and is running
+	Confirm    string          `json:"confirm,omitempty"`   // phrase to type to run the current (risky) command; Approve alone does not
 }
 
-// ApprovalStore is a thread-safe map of chatID → pending turn.
+// ApprovalStore is a thread-safe map of chatID → pending turn, persisted
+// to DATA_DIR so the cards can be shown again after a restart.
 type ApprovalStore struct {
 	mu      sync.RWMutex
+	path    string
 	pending map[int64]*PendingTurn
+	// saved holds each turn as last saved. Turns are changed in place under
+	// their chat's lock, so a chat only ever encodes its own turn.
+	saved map[int64]json.RawMessage
 }
 
-func NewApprovalStore() *ApprovalStore {
-	return &ApprovalStore{pending: make(map[int64]*PendingTurn)}
+func NewApprovalStore(dataDir string) *ApprovalStore {
+	s := &ApprovalStore{
+		path:    filepath.Join(dataDir, "approvals.json"),
+		pending: make(map[int64]*PendingTurn),
+		saved:   make(map[int64]json.RawMessage),
+	}
+	if err := loadJSONFile(s.path, &s.saved); err != nil {
+		slog.Warn("failed to load pending approvals", "path", s.path, "err", err)
+	}
+	for id, raw := range s.saved {
+		var turn PendingTurn
+		if err := json.Unmarshal(raw, &turn); err != nil || len(turn.Commands) == 0 || turn.CurrentIdx >= len(turn.Commands) {
+			slog.Warn("dropping unreadable pending approval", "chat_id", id, "err", err)
+			delete(s.saved, id)
+			continue
+		}
+		s.pending[id] = &turn
+	}
+	return s
 }
 
 func (s *ApprovalStore) Get(chatID int64) *PendingTurn {
@@ -42,12 +73,54 @@ func (s *ApprovalStore) Set(chatID int64, turn *PendingTurn) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	s.pending[chatID] = turn
+	s.saveLocked(chatID)
 }
 
 func (s *ApprovalStore) Delete(chatID int64) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	if _, ok := s.pending[chatID]; !ok {
+		return
+	}
 	delete(s.pending, chatID)
+	s.saveLocked(chatID)
+}
+
+// Save persists the chat's turn after it was changed in place. The caller
+// holds the chat's lock.
+func (s *ApprovalStore) Save(chatID int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.saveLocked(chatID)
+}
+
+// All returns the chats with a pending turn.
+func (s *ApprovalStore) All() map[int64]*PendingTurn {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make(map[int64]*PendingTurn, len(s.pending))
+	for id, turn := range s.pending {
+		out[id] = turn
+	}
+	return out
+}
+
+// saveLocked encodes the chat's turn, or drops it when there is none, and
+// writes the file.
+func (s *ApprovalStore) saveLocked(chatID int64) {
+	if turn, ok := s.pending[chatID]; ok {
+		raw, err := json.Marshal(turn)
+		if err != nil {
+			slog.Warn("failed to encode pending approval", "chat_id", chatID, "err", err)
+			return
+		}
+		s.saved[chatID] = raw
+	} else {
+		delete(s.saved, chatID)
+	}
+	if err := saveJSONFile(s.path, s.saved); err != nil {
+		slog.Warn("failed to save pending approvals", "path", s.path, "err", err)
+	}
 }
 
 func (s *ApprovalStore) Has(chatID int64) bool {
@@ -101,8 +174,10 @@ func (s *LoginStore) Has(chatID int64) bool {
 	return ok
 }
 
-// ChatUsage accumulates usage stats for a single chat session.
+// ChatUsage accumulates usage stats for a chat, over its current session
+// or its lifetime.
 type ChatUsage struct {
+	Since         time.Time `json:",omitempty"` // first call counted
 	TotalCostUSD  float64
 	InputTokens   int64
 	OutputTokens  int64
@@ -111,16 +186,115 @@ type ChatUsage struct {
 	NumCalls      int
 	TotalDuration time.Duration
 	LastCallTime  time.Time
+	Providers     map[string]*ProviderUsage
+	Users         map[int64]*UserUsage `json:",omitempty"`
 }
 
-// UsageTracker is a thread-safe map of chatID → accumulated usage.
+// ProviderUsage is one provider's share of a chat's usage.
+type ProviderUsage struct {
+	CostUSD      float64
+	InputTokens  int64
+	OutputTokens int64
+	NumCalls     int
+}
+
+// UserUsage is one Telegram user's share of a chat's usage.
+type UserUsage struct {
+	Name string
+	ProviderUsage
+}
+
+// add records one call in the totals and in the provider's breakdown.
+func (s *ChatUsage) add(provider string, cost float64, in, out int64, d time.Duration) {
+	s.TotalCostUSD += cost
+	s.InputTokens += in
+	s.OutputTokens += out
+	s.NumCalls++
+	s.TotalDuration += d
+	s.LastCallTime = time.Now()
+	if s.Since.IsZero() {
+		s.Since = s.LastCallTime
+	}
+	if s.Providers == nil {
+		s.Providers = make(map[string]*ProviderUsage)
+	}
+	p := s.Providers[provider]
+	if p == nil {
+		p = &ProviderUsage{}
+		s.Providers[provider] = p
+	}
+	p.CostUSD += cost
+	p.InputTokens += in
+	p.OutputTokens += out
+	p.NumCalls++
+}
+
+// addUser records a call in user's share.
+func (s *ChatUsage) addUser(user chatUser, cost float64, in, out int64) {
+	if s.Users == nil {
+		s.Users = make(map[int64]*UserUsage)
+	}
+	u := s.Users[user.ID]
+	if u == nil {
+		u = &UserUsage{}
+		s.Users[user.ID] = u
+	}
+	u.Name = user.Name
+	u.CostUSD += cost
+	u.InputTokens += in
+	u.OutputTokens += out
+	u.NumCalls++
+}
+
+// usageSaveInterval throttles how often lifetime usage is flushed to disk.
+const usageSaveInterval = 30 * time.Second
+
+// UsageTracker is a thread-safe map of chatID → accumulated usage, kept
+// twice: for the chat's current session, which /new clears, and for its
+// lifetime, which is never cleared and is persisted to DATA_DIR.
 type UsageTracker struct {
-	mu    sync.RWMutex
-	stats map[int64]*ChatUsage
+	mu       sync.RWMutex
+	stats    map[int64]*ChatUsage // current session
+	lifetime map[int64]*ChatUsage
+	path     string
+	lastSave time.Time
 }
 
-func NewUsageTracker() *UsageTracker {
-	return &UsageTracker{stats: make(map[int64]*ChatUsage)}
+func NewUsageTracker(dataDir string) *UsageTracker {
+	t := &UsageTracker{
+		stats:    make(map[int64]*ChatUsage),
+		lifetime: make(map[int64]*ChatUsage),
+		path:     filepath.Join(dataDir, "usage_lifetime.json"),
+	}
+	if err := loadJSONFile(t.path, &t.lifetime); err != nil {
+		slog.Warn("failed to load lifetime usage", "path", t.path, "err", err)
+	}
+	if t.lifetime == nil {
+		t.lifetime = make(map[int64]*ChatUsage)
+	}
+	return t
+}
+
+// bucketsLocked returns the chat's session and lifetime usage, creating
+// them as needed.
+func (t *UsageTracker) bucketsLocked(chatID int64) [2]*ChatUsage {
+	var b [2]*ChatUsage
+	for i, m := range []map[int64]*ChatUsage{t.stats, t.lifetime} {
+		s := m[chatID]
+		if s == nil {
+			s = &ChatUsage{}
+			m[chatID] = s
+		}
+		b[i] = s
+	}
+	return b
+}
+
+// recordedLocked saves lifetime usage if it was not saved recently.
+func (t *UsageTracker) recordedLocked() {
+	if time.Since(t.lastSave) >= usageSaveInterval {
+		t.saveLocked()
+	}
 }
 
 // Record adds a Claude response's usage data to the running totals.
@@ -130,31 +304,80 @@ func (t *UsageTracker) Record(chatID int64, resp *ClaudeResponse) {
 	}
 	t.mu.Lock()
 	defer t.mu.Unlock()
-	s := t.stats[chatID]
-	if s == nil {
-		s = &ChatUsage{}
-		t.stats[chatID] = s
-	}
-	s.TotalCostUSD += resp.CostUSD
-	s.InputTokens += resp.Usage.InputTokens
-	s.OutputTokens += resp.Usage.OutputTokens
-	s.CacheRead += resp.Usage.CacheReadInputTokens
-	s.CacheCreate += resp.Usage.CacheCreationInputTokens
-	s.NumCalls++
-	s.TotalDuration += time.Duration(resp.DurationMs) * time.Millisecond
-	s.LastCallTime = time.Now()
+	for _, s := range t.bucketsLocked(chatID) {
+		s.add("claude", resp.CostUSD, resp.Usage.InputTokens, resp.Usage.OutputTokens, time.Duration(resp.DurationMs)*time.Millisecond)
+		s.CacheRead += resp.Usage.CacheReadInputTokens
+		s.CacheCreate += resp.Usage.CacheCreationInputTokens
+	}
+	t.recordedLocked()
+}
+
+// RecordGemini adds a Gemini call's usageMetadata and estimated cost.
+func (t *UsageTracker) RecordGemini(chatID int64, u GeminiUsage, d time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, s := range t.bucketsLocked(chatID) {
+		s.add("gemini", geminiCost(u), u.PromptTokens, u.CandidatesTokens+u.ThoughtsTokens, d)
+		s.CacheRead += u.CachedTokens
+	}
+	t.recordedLocked()
+}
+
+// RecordAPI adds a call to an HTTP chat provider other than Gemini, with the
+// cost it reported (zero when the API reports none).
+func (t *UsageTracker) RecordAPI(chatID int64, provider string, u APIUsage, d time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, s := range t.bucketsLocked(chatID) {
+		s.add(provider, u.CostUSD, u.PromptTokens, u.CompletionTokens, d)
+		s.CacheRead += u.CachedTokens
+	}
+	t.recordedLocked()
 }
 
-// Get returns the accumulated usage for a chat, or nil if none.
+// RecordUser adds a call already recorded for chatID to user's share of it.
+func (t *UsageTracker) RecordUser(chatID int64, user chatUser, cost float64, in, out int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, m := range []map[int64]*ChatUsage{t.stats, t.lifetime} {
+		if s := m[chatID]; s != nil {
+			s.addUser(user, cost, in, out)
+		}
+	}
+}
+
+// Get returns the chat's usage in its current session, or nil if none.
 func (t *UsageTracker) Get(chatID int64) *ChatUsage {
 	t.mu.RLock()
 	defer t.mu.RUnlock()
 	return t.stats[chatID]
 }
 
-// Reset clears usage stats for a chat.
+// Lifetime returns the chat's cumulative usage, or nil if none.
+func (t *UsageTracker) Lifetime(chatID int64) *ChatUsage {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.lifetime[chatID]
+}
+
+// Reset clears a chat's session usage; its lifetime usage is kept.
 func (t *UsageTracker) Reset(chatID int64) {
 	t.mu.Lock()
 	defer t.mu.Unlock()
 	delete(t.stats, chatID)
 }
+
+// Save flushes lifetime usage to disk.
+func (t *UsageTracker) Save() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.saveLocked()
+}
+
+func (t *UsageTracker) saveLocked() {
+	if err := saveJSONFile(t.path, t.lifetime); err != nil {
+		slog.Warn("failed to save lifetime usage", "path", t.path, "err", err)
+		return
+	}
+	t.lastSave = time.Now()
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"log/slog"
+)
+
+// resumeApprovals shows again the approval cards that were pending when
+// the bot stopped, with a note saying why. The old cards are closed so
+// that only the new one takes decisions.
+func (h *Handlers) resumeApprovals() {
+	for chatID := range h.approvals.All() {
+		h.resumeApproval(chatID)
+	}
+}
+
+func (h *Handlers) resumeApproval(chatID int64) {
+	unlock := h.locks.Lock(chatID)
+	defer unlock()
+	turn := h.approvals.Get(chatID)
+	if turn == nil {
+		return
+	}
+	cmd := h.vars.Expand(chatID, turn.Commands[turn.CurrentIdx])
+	slog.Info("resuming pending approval", "chat_id", chatID, "provider", turn.Provider,
+		"index", turn.CurrentIdx+1, "total", len(turn.Commands), "running", turn.Running)
+
+	for id, msgID := range turn.Cards {
+		if msgID != 0 {
+			h.sender.EditRemoveKeyboard(id, msgID, h.t(id, "approval.interrupted", cmd))
+		}
+	}
+	// Claude's session IDs are not kept across restarts; the turn's is the
+	// one its results belong to.
+	if turn.Provider == "claude" && turn.SessionID != "" && h.sessions.Get(chatID) == "" {
+		h.sessions.Set(chatID, turn.SessionID)
+	}
+	note := "♻️ The bot restarted while this command was waiting for approval. Here it is again:"
+	if turn.Running {
+		note = "♻️ The bot restarted while this approved command was running, so it may have run in part or in full. Check before approving it again:"
+	}
+	h.sender.SendPlain(chatID, note)
+	h.showApproval(chatID, turn)
+}
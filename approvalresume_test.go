@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// crashDirEnv makes TestApprovalSurvivesKill act as the bot process that
+// gets killed.
+const crashDirEnv = "TRASH_BOT_CRASH_DIR"
+
+// fakeTelegram answers Bot API calls and records the sent texts.
+type fakeTelegram struct {
+	mu    sync.Mutex
+	sent  []url.Values
+	calls []string
+}
+
+func (f *fakeTelegram) Do(req *http.Request) (*http.Response, error) {
+	body, _ := io.ReadAll(req.Body)
+	form, _ := url.ParseQuery(string(body))
+	method := req.URL.Path[strings.LastIndex(req.URL.Path, "/")+1:]
+	f.mu.Lock()
+	f.calls = append(f.calls, method)
+	f.sent = append(f.sent, form)
+	f.mu.Unlock()
+	result := `{"message_id":100,"date":0,"chat":{"id":1}}`
+	if method == "getMe" {
+		result = `{"id":1,"is_bot":true,"first_name":"bot","username":"bot"}`
+	}
+	return &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader(`{"ok":true,"result":` + result + `}`))}, nil
+}
+
+func TestApprovalSurvivesKill(t *testing.T) {
+	if dir := os.Getenv(crashDirEnv); dir != "" {
+		// The doomed process: an approved command is running when it dies.
+		s := NewApprovalStore(dir)
+		turn := &PendingTurn{Commands: []string{"uptime", "df -h"}, SessionID: "sess-1", Provider: "claude", Cards: map[int64]int{1: 41}}
+		s.Set(1, turn)
+		turn.Results = append(turn.Results, CommandResult{Command: "uptime", Approved: true, Output: "up 3 days"})
+		turn.CurrentIdx = 1
+		turn.Cards = map[int64]int{1: 42}
+		turn.Running = true
+		s.Save(1)
+		os.Stdout.WriteString("ready\n")
+		time.Sleep(time.Minute)
+		os.Exit(1)
+	}
+	if testing.Short() {
+		t.Skip("starts a process")
+	}
+
+	dir := t.TempDir()
+	cmd := exec.Command(os.Args[0], "-test.run=^TestApprovalSurvivesKill$")
+	cmd.Env = append(os.Environ(), crashDirEnv+"="+dir)
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	line, _ := bufio.NewReader(out).ReadString('\n')
+	if line != "ready\n" {
+		cmd.Process.Kill()
+		t.Fatalf("child said %q", line)
+	}
+	cmd.Process.Kill()
+	cmd.Wait()
+
+	// The restarted bot finds the turn where it was.
+	approvals := NewApprovalStore(dir)
+	turn := approvals.Get(1)
+	if turn == nil {
+		t.Fatal("pending turn lost in the crash")
+	}
+	if turn.CurrentIdx != 1 || !turn.Running || len(turn.Results) != 1 || turn.SessionID != "sess-1" {
+		t.Fatalf("turn restored as %+v", turn)
+	}
+
+	tg := &fakeTelegram{}
+	api, err := tgbotapi.NewBotAPIWithClient("token", tgbotapi.APIEndpoint, tg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := &Handlers{
+		sender:    NewSender(api, nil),
+		approvals: approvals,
+		sessions:  NewSessionManager(),
+		locks:     NewChatLocks(),
+		vars:      NewVarStore(dir),
+		shares:    NewShareStore(dir),
+	}
+	h.resumeApprovals()
+
+	tg.mu.Lock()
+	defer tg.mu.Unlock()
+	var texts []string
+	closedOld := false
+	for i, form := range tg.sent {
+		texts = append(texts, form.Get("text"))
+		if tg.calls[i] == "editMessageText" && form.Get("message_id") == "42" {
+			closedOld = true
+		}
+	}
+	all := strings.Join(texts, "\n")
+	if !closedOld {
+		t.Error("old card not closed")
+	}
+	if !strings.Contains(all, "may have run") {
+		t.Errorf("no restart note for the running command in %q", all)
+	}
+	if !strings.Contains(all, "Command 2/2") || !strings.Contains(all, "df -h") {
+		t.Errorf("card not shown again: %q", all)
+	}
+	if h.sessions.Get(1) != "sess-1" {
+		t.Error("Claude session not restored")
+	}
+	if turn.Running || turn.Cards[1] != 100 {
+		t.Errorf("turn after resume: %+v", turn)
+	}
+	if saved := NewApprovalStore(dir).Get(1); saved == nil || saved.Running {
+		t.Error("resumed card not saved")
+	}
+}
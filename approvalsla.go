@@ -0,0 +1,165 @@
+package main
+
+import (
+	"log/slog"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// In team deployments a command can wait for approval while its owner is
+// away, and a bot-driven deploy stalls silently. With APPROVAL_SLA set, a
+// card left undecided that long is copied, with working buttons, to the
+// escalation chats; a decision in any chat resolves every copy.
+
+// slaCallbackPrefix marks the buttons of an escalated card:
+// "sla:approve:<chatID>" or "sla:deny:<chatID>".
+const slaCallbackPrefix = "sla:"
+
+// scheduleEscalation escalates the turn's current command if it is still
+// undecided after the SLA.
+func (h *Handlers) scheduleEscalation(chatID int64, turn *PendingTurn) {
+	if h.approvalSLA <= 0 || len(h.escalateTo) == 0 {
+		return
+	}
+	idx := turn.CurrentIdx
+	time.AfterFunc(h.approvalSLA, func() { h.escalateApproval(chatID, turn, idx) })
+}
+
+// escalateApproval sends the pending command idx of turn to the escalation
+// chats, unless it was decided in the meantime.
+func (h *Handlers) escalateApproval(chatID int64, turn *PendingTurn, idx int) {
+	unlock := h.locks.Lock(chatID)
+	defer unlock()
+	if h.approvals.Get(chatID) != turn || turn.CurrentIdx != idx || turn.Escalated {
+		return
+	}
+	turn.Escalated = true
+
+	waited := time.Since(turn.ShownAt).Round(time.Second)
+	cmd := h.vars.Expand(chatID, turn.Commands[idx])
+	slog.Warn("approval SLA exceeded, escalating", "chat_id", chatID, "command", cmd, "waited", waited, "targets", len(h.escalateTo))
+	h.approvalStats.RecordEscalation()
+
+	id := strconv.FormatInt(chatID, 10)
+	var notified []string
+	for _, target := range h.escalateTo {
+		// Chats that already have the card (the owner, collaborators) only
+		// get the reminder below.
+		if _, ok := turn.Cards[target]; ok {
+			continue
+		}
+		card := h.t(target, "sla.card", waited, chatID, providerLabel(turn.Provider), idx+1, len(turn.Commands), cmd)
+		// A risky command runs only on its confirmation phrase, typed in
+		// the owner chat, so the escalated card can only deny it.
+		buttons := []tgbotapi.InlineKeyboardButton{tgbotapi.NewInlineKeyboardButtonData(h.t(target, "approval.deny"), slaCallbackPrefix+"deny:"+id)}
+		if turn.Confirm == "" {
+			buttons = append([]tgbotapi.InlineKeyboardButton{tgbotapi.NewInlineKeyboardButtonData(h.t(target, "approval.approve"), slaCallbackPrefix+"approve:"+id)}, buttons...)
+		} else {
+			card += h.t(target, "approval.sla_confirm", chatID)
+		}
+		turn.Cards[target] = h.sender.SendPlainWithKeyboard(target, card, tgbotapi.NewInlineKeyboardMarkup(buttons))
+		notified = append(notified, strconv.FormatInt(target, 10))
+	}
+	h.approvals.Save(chatID)
+	if len(notified) > 0 {
+		h.sender.SendPlain(chatID, h.t(chatID, "sla.escalated", waited, strings.Join(notified, ", ")))
+	}
+}
+
+// parseSLACallback extracts the decision and the owner chat from an
+// escalated card's button data.
+func parseSLACallback(data string) (decision string, chatID int64, ok bool) {
+	decision, id, found := strings.Cut(strings.TrimPrefix(data, slaCallbackPrefix), ":")
+	if !found || (decision != "approve" && decision != "deny") {
+		return "", 0, false
+	}
+	chatID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return "", 0, false
+	}
+	return decision, chatID, true
+}
+
+// isEscalationTarget reports whether chatID receives escalated approvals.
+func (h *Handlers) isEscalationTarget(chatID int64) bool {
+	for _, id := range h.escalateTo {
+		if id == chatID {
+			return true
+		}
+	}
+	return false
+}
+
+// ApprovalStats tracks how long commands wait for a decision, persisted to
+// DATA_DIR.
+type ApprovalStats struct {
+	mu          sync.Mutex
+	path        string
+	LatenciesMs []int64 `json:"latencies_ms"` // most recent decision latencies
+	Decisions   int64   `json:"decisions"`
+	Escalations int64   `json:"escalations"`
+	// EscalatedDecisions counts decisions made after an escalation.
+	EscalatedDecisions int64 `json:"escalated_decisions"`
+}
+
+func NewApprovalStats(dataDir string) *ApprovalStats {
+	s := &ApprovalStats{path: filepath.Join(dataDir, "approval_metrics.json")}
+	if err := loadJSONFile(s.path, s); err != nil {
+		slog.Warn("failed to load approval metrics", "path", s.path, "err", err)
+	}
+	return s
+}
+
+// RecordDecision adds the time a command waited for its decision.
+func (s *ApprovalStats) RecordDecision(latency time.Duration, escalated bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Decisions++
+	if escalated {
+		s.EscalatedDecisions++
+	}
+	s.LatenciesMs = append(s.LatenciesMs, latency.Milliseconds())
+	if len(s.LatenciesMs) > maxLatencySamples {
+		s.LatenciesMs = s.LatenciesMs[len(s.LatenciesMs)-maxLatencySamples:]
+	}
+	s.saveLocked()
+}
+
+func (s *ApprovalStats) RecordEscalation() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Escalations++
+	s.saveLocked()
+}
+
+func (s *ApprovalStats) saveLocked() {
+	if err := saveJSONFile(s.path, s); err != nil {
+		slog.Warn("failed to save approval metrics", "path", s.path, "err", err)
+	}
+}
+
+// format renders the stats for /stats approvals.
+func (s *ApprovalStats) format(lang string, sla time.Duration) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Decisions == 0 && s.Escalations == 0 {
+		return translate(lang, "approvals.none")
+	}
+	var b strings.Builder
+	b.WriteString(translate(lang, "approvals.title"))
+	b.WriteString(translate(lang, "approvals.decisions", s.Decisions))
+	round := func(d time.Duration) time.Duration { return d.Round(time.Second) }
+	b.WriteString(translate(lang, "approvals.wait",
+		round(percentile(s.LatenciesMs, 50)), round(percentile(s.LatenciesMs, 90)), round(percentile(s.LatenciesMs, 99))))
+	if sla > 0 {
+		b.WriteString(translate(lang, "approvals.escalations", sla, s.Escalations, s.EscalatedDecisions))
+	} else {
+		b.WriteString(translate(lang, "approvals.escalation_off"))
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseSLACallback(t *testing.T) {
+	decision, chatID, ok := parseSLACallback("sla:approve:-100123")
+	if !ok || decision != "approve" || chatID != -100123 {
+		t.Errorf("parseSLACallback = %q, %d, %v", decision, chatID, ok)
+	}
+	for _, bad := range []string{"sla:approve", "sla:run:1", "sla:deny:abc"} {
+		if _, _, ok := parseSLACallback(bad); ok {
+			t.Errorf("parseSLACallback(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestApprovalStats(t *testing.T) {
+	dir := t.TempDir()
+	s := NewApprovalStats(dir)
+	if got := s.format("en", 0); !strings.HasPrefix(got, "No approval") {
+		t.Errorf("empty stats = %q", got)
+	}
+	s.RecordDecision(10*time.Second, false)
+	s.RecordEscalation()
+	s.RecordDecision(20*time.Minute, true)
+
+	s = NewApprovalStats(dir)
+	if s.Decisions != 2 || s.Escalations != 1 || s.EscalatedDecisions != 1 {
+		t.Errorf("reloaded stats = %+v", s)
+	}
+	got := s.format("en", 15*time.Minute)
+	for _, want := range []string{"Decisions: 2", "10s / 20m0s / 20m0s", "Escalations (SLA 15m0s): 1, 1 later decided"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("format() = %q, missing %q", got, want)
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Args is a parsed command argument string: positional words plus
+// --name / --name=value flags.
+type Args struct {
+	Pos   []string
+	Flags map[string]string
+}
+
+// ParseArgs splits a command's arguments into words. A single or double
+// quote at the start of a word groups everything up to the matching quote
+// (so apostrophes inside words are left alone), and a backslash escapes the
+// next character. Words of the form --name or --name=value are flags; a bare
+// "--" ends flag parsing.
+func ParseArgs(s string) (Args, error) {
+	a := Args{Flags: map[string]string{}}
+	words, err := splitWords(s)
+	if err != nil {
+		return a, err
+	}
+	flagsDone := false
+	for _, w := range words {
+		if w.quoted || flagsDone || !strings.HasPrefix(w.text, "--") {
+			a.Pos = append(a.Pos, w.text)
+			continue
+		}
+		if w.text == "--" {
+			flagsDone = true
+			continue
+		}
+		name, value, ok := strings.Cut(w.text[2:], "=")
+		if !ok {
+			value = "true"
+		}
+		a.Flags[strings.ToLower(name)] = value
+	}
+	return a, nil
+}
+
+type word struct {
+	text   string
+	quoted bool
+}
+
+func splitWords(s string) ([]word, error) {
+	var (
+		words   []word
+		cur     strings.Builder
+		inWord  bool
+		quoted  bool
+		quote   rune
+		escaped bool
+	)
+	flush := func() {
+		if inWord {
+			words = append(words, word{text: cur.String(), quoted: quoted})
+		}
+		cur.Reset()
+		inWord, quoted = false, false
+	}
+	for _, r := range s {
+		switch {
+		case escaped:
+			cur.WriteRune(r)
+			escaped = false
+		case r == '\\':
+			inWord, escaped = true, true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				cur.WriteRune(r)
+			}
+		case (r == '"' || r == '\'') && !inWord:
+			inWord, quoted, quote = true, true, r
+		case r == ' ' || r == '\t' || r == '\n':
+			flush()
+		default:
+			inWord = true
+			cur.WriteRune(r)
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote", quote)
+	}
+	if escaped {
+		cur.WriteRune('\\')
+	}
+	flush()
+	return words, nil
+}
+
+// Len returns the number of positional arguments.
+func (a Args) Len() int { return len(a.Pos) }
+
+// Arg returns positional argument i, or "" if there is none.
+func (a Args) Arg(i int) string {
+	if i < len(a.Pos) {
+		return a.Pos[i]
+	}
+	return ""
+}
+
+// Rest joins the positional arguments from i onwards with spaces.
+func (a Args) Rest(i int) string {
+	if i >= len(a.Pos) {
+		return ""
+	}
+	return strings.Join(a.Pos[i:], " ")
+}
+
+// Int parses positional argument i, returning def when it is absent.
+func (a Args) Int(i, def int) (int, error) {
+	if i >= len(a.Pos) {
+		return def, nil
+	}
+	v, err := strconv.Atoi(a.Pos[i])
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a number", a.Pos[i])
+	}
+	return v, nil
+}
+
+// ChatID parses positional argument i as a chat ID.
+func (a Args) ChatID(i int) (int64, error) {
+	if i >= len(a.Pos) {
+		return 0, fmt.Errorf("missing chat ID")
+	}
+	v, err := strconv.ParseInt(a.Pos[i], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid chat ID %q", a.Pos[i])
+	}
+	return v, nil
+}
+
+// Flag returns the value of --name, or def when it was not given.
+func (a Args) Flag(name, def string) string {
+	if v, ok := a.Flags[name]; ok {
+		return v
+	}
+	return def
+}
+
+// Bool reports whether --name was given (and not set to false/0/no).
+func (a Args) Bool(name string) bool {
+	v, ok := a.Flags[name]
+	if !ok {
+		return false
+	}
+	b, err := strconv.ParseBool(v)
+	return err != nil || b
+}
+
+// Check returns an error naming any flag not in allowed.
+func (a Args) Check(allowed ...string) error {
+	var unknown []string
+	for name := range a.Flags {
+		found := false
+		for _, ok := range allowed {
+			if name == ok {
+				found = true
+				break
+			}
+		}
+		if !found {
+			unknown = append(unknown, "--"+name)
+		}
+	}
+	if len(unknown) == 0 {
+		return nil
+	}
+	sort.Strings(unknown)
+	return fmt.Errorf("unknown flag %s", strings.Join(unknown, ", "))
+}
+
+// parseCommandArgs parses a command's arguments and rejects flags outside
+// allowed, replying with the command's usage on error.
+func (h *Handlers) parseCommandArgs(chatID int64, name, args string, allowed ...string) (Args, bool) {
+	a, err := ParseArgs(args)
+	if err == nil {
+		err = a.Check(allowed...)
+	}
+	if err != nil {
+		h.sendArgError(chatID, name, err)
+		return a, false
+	}
+	return a, true
+}
+
+// sendArgError reports an invalid argument together with the command's usage.
+func (h *Handlers) sendArgError(chatID int64, name string, err error) {
+	cmd, ok := lookupCommand(name)
+	if !ok {
+		h.sender.SendPlain(chatID, h.t(chatID, "args.invalid", err))
+		return
+	}
+	h.sender.SendPlain(chatID, h.t(chatID, "args.invalid_usage", err, cmd.Usage()))
+}
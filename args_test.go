@@ -0,0 +1,64 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseArgs(t *testing.T) {
+	tests := []struct {
+		in    string
+		pos   []string
+		flags map[string]string
+	}{
+		{"", nil, map[string]string{}},
+		{"  a  b ", []string{"a", "b"}, map[string]string{}},
+		{`add web "my dir/app"`, []string{"add", "web", "my dir/app"}, map[string]string{}},
+		{`123 user's script misbehaved`, []string{"123", "user's", "script", "misbehaved"}, map[string]string{}},
+		{`--dry-run --env=prod x`, []string{"x"}, map[string]string{"dry-run": "true", "env": "prod"}},
+		{`'--not-a-flag' -- --also`, []string{"--not-a-flag", "--also"}, map[string]string{}},
+		{`a\ b`, []string{"a b"}, map[string]string{}},
+	}
+	for _, tt := range tests {
+		a, err := ParseArgs(tt.in)
+		if err != nil {
+			t.Errorf("ParseArgs(%q): %v", tt.in, err)
+			continue
+		}
+		if !reflect.DeepEqual(a.Pos, tt.pos) || !reflect.DeepEqual(a.Flags, tt.flags) {
+			t.Errorf("ParseArgs(%q) = %q %v, want %q %v", tt.in, a.Pos, a.Flags, tt.pos, tt.flags)
+		}
+	}
+
+	if _, err := ParseArgs(`say "hello`); err == nil {
+		t.Error("expected error for unterminated quote")
+	}
+}
+
+func TestArgsAccessors(t *testing.T) {
+	a, _ := ParseArgs("42 -7 rest of it --verbose --n=false")
+	if n, err := a.Int(0, 5); err != nil || n != 42 {
+		t.Errorf("Int(0) = %d, %v", n, err)
+	}
+	if n, _ := a.Int(9, 5); n != 5 {
+		t.Errorf("Int default = %d", n)
+	}
+	if _, err := a.ChatID(2); err == nil {
+		t.Error("ChatID accepted a word")
+	}
+	if id, err := a.ChatID(1); err != nil || id != -7 {
+		t.Errorf("ChatID(1) = %d, %v", id, err)
+	}
+	if got := a.Rest(2); got != "rest of it" {
+		t.Errorf("Rest(2) = %q", got)
+	}
+	if !a.Bool("verbose") || a.Bool("n") || a.Bool("missing") {
+		t.Error("Bool flags wrong")
+	}
+	if a.Flag("missing", "def") != "def" {
+		t.Error("Flag default not applied")
+	}
+	if err := a.Check("verbose"); err == nil || err.Error() != "unknown flag --n" {
+		t.Errorf("Check = %v", err)
+	}
+}
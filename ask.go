@@ -0,0 +1,215 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// The AI can ask multiple-choice questions with <ask> blocks. Each question
+// is sent with its options as buttons; once every question of a reply is
+// answered, the choices go back to the AI as the user's next message. The
+// user can always ignore the buttons and answer in their own words.
+
+// askTagRe matches <ask>...</ask> blocks. Like <command>, the opening tag
+// must start a line so prose mentioning the tag is left alone.
+var askTagRe = regexp.MustCompile(`(?m)^[ \t]*<ask>([\s\S]*?)</ask>`)
+
+// askOptionRe matches an option line: "- a", "* a", "1. a" or "1) a".
+var askOptionRe = regexp.MustCompile(`^(?:[-*]|\d+[.)])\s+(.+)$`)
+
+// Limits on one reply's questions, so a reply can't flood the chat with
+// buttons.
+const (
+	maxAskQuestions = 5
+	maxAskOptions   = 10
+)
+
+// askPrompt describes <ask> blocks for the system prompt.
+const askPrompt = `
+
+To ask the user a multiple-choice question, put an <ask> block on its own lines: the question on the first line, then one option per line starting with "- ". The options are shown as buttons and the chosen one comes back as the user's next message; the user may also answer in their own words. Several <ask> blocks in one reply are answered together. Use it for short clarifying questions, e.g.:
+<ask>Which environment should I deploy to?
+- staging
+- production
+</ask>`
+
+// AskQuestion is one multiple-choice question from an <ask> block.
+type AskQuestion struct {
+	Question string
+	Options  []string
+}
+
+// parseAskBlock reads the question and options of an <ask> block's body.
+func parseAskBlock(body string) (AskQuestion, bool) {
+	var q AskQuestion
+	for _, line := range strings.Split(body, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if m := askOptionRe.FindStringSubmatch(line); m != nil && q.Question != "" {
+			if len(q.Options) < maxAskOptions {
+				q.Options = append(q.Options, strings.TrimSpace(m[1]))
+			}
+			continue
+		}
+		if len(q.Options) > 0 {
+			return AskQuestion{}, false // text after the options
+		}
+		q.Question = strings.TrimSpace(q.Question + " " + line)
+	}
+	return q, q.Question != "" && len(q.Options) > 0
+}
+
+// ParseAsks extracts the questions of a reply's <ask> blocks. Blocks
+// without options, and any past maxAskQuestions, stay in the text as
+// plain prose.
+func ParseAsks(text string) (cleanText string, questions []AskQuestion) {
+	cleanText = replaceSubmatches(askTagRe, text, func(sub []string) string {
+		q, ok := parseAskBlock(sub[1])
+		if !ok || len(questions) >= maxAskQuestions {
+			return strings.TrimSpace(sub[1])
+		}
+		questions = append(questions, q)
+		return ""
+	})
+	cleanText = strings.TrimSpace(cleanText)
+	return
+}
+
+// askForm is a chat's unanswered questions from one reply.
+type askForm struct {
+	ID        int
+	Questions []AskQuestion
+	Answers   []string
+}
+
+// AskStore is a thread-safe map of each chat's open form. A newer form
+// replaces the older one, whose buttons then expire.
+type AskStore struct {
+	mu     sync.Mutex
+	nextID int
+	forms  map[int64]*askForm
+}
+
+func NewAskStore() *AskStore {
+	return &AskStore{forms: make(map[int64]*askForm)}
+}
+
+// Open starts a form for the questions and returns its ID.
+func (s *AskStore) Open(chatID int64, questions []AskQuestion) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nextID++
+	s.forms[chatID] = &askForm{ID: s.nextID, Questions: questions, Answers: make([]string, len(questions))}
+	return s.nextID
+}
+
+// Answer records the chosen option of question q of form id. It returns
+// the form once every question is answered, closing it, and ok false for
+// an expired form or an unknown option.
+func (s *AskStore) Answer(chatID int64, id, q, option int) (question, answer string, done *askForm, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f := s.forms[chatID]
+	if f == nil || f.ID != id || q < 0 || q >= len(f.Questions) || option < 0 || option >= len(f.Questions[q].Options) {
+		return "", "", nil, false
+	}
+	f.Answers[q] = f.Questions[q].Options[option]
+	for _, a := range f.Answers {
+		if a == "" {
+			return f.Questions[q].Question, f.Answers[q], nil, true
+		}
+	}
+	delete(s.forms, chatID)
+	return f.Questions[q].Question, f.Answers[q], f, true
+}
+
+// Close drops a chat's open form, if any.
+func (s *AskStore) Close(chatID int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.forms, chatID)
+}
+
+// answersMessage renders a completed form as the user's message to the AI.
+func answersMessage(f *askForm) string {
+	if len(f.Questions) == 1 {
+		return fmt.Sprintf("My answer to %q: %s", f.Questions[0].Question, f.Answers[0])
+	}
+	var b strings.Builder
+	b.WriteString("My answers to your questions:")
+	for i, q := range f.Questions {
+		fmt.Fprintf(&b, "\n- %s → %s", q.Question, f.Answers[i])
+	}
+	return b.String()
+}
+
+// sendAsks opens a form for the questions and sends each with its options
+// as buttons.
+func (h *Handlers) sendAsks(chatID int64, questions []AskQuestion) {
+	id := h.asks.Open(chatID, questions)
+	slog.Info("questions asked", "chat_id", chatID, "form", id, "questions", len(questions))
+	for i, q := range questions {
+		var rows [][]tgbotapi.InlineKeyboardButton
+		for j, opt := range q.Options {
+			data := fmt.Sprintf("ask:%d:%d:%d", id, i, j)
+			rows = append(rows, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(truncateText(opt, 60), data)))
+		}
+		text := "❓ " + q.Question
+		if len(questions) > 1 {
+			text = fmt.Sprintf("❓ (%d/%d) %s", i+1, len(questions), q.Question)
+		}
+		h.sender.SendPlainWithKeyboard(chatID, text, tgbotapi.NewInlineKeyboardMarkup(rows...))
+	}
+}
+
+// handleAskCallback records a pressed option and, once the form is
+// complete, sends the answers to the AI. The chat lock must be held.
+func (h *Handlers) handleAskCallback(ctx context.Context, chatID int64, callbackID, data string, messageID int) {
+	var ids [3]int
+	parts := strings.Split(strings.TrimPrefix(data, "ask:"), ":")
+	if len(parts) != len(ids) {
+		h.sender.AnswerCallback(callbackID, h.t(chatID, "ask.unknown"))
+		return
+	}
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil {
+			h.sender.AnswerCallback(callbackID, h.t(chatID, "ask.unknown"))
+			return
+		}
+		ids[i] = n
+	}
+	if h.approvals.Has(chatID) {
+		h.sender.AnswerCallback(callbackID, h.t(chatID, "approval.pending_first"))
+		return
+	}
+	if h.rejectIfOverQuota(ctx, chatID) {
+		h.sender.AnswerCallback(callbackID, "")
+		return
+	}
+
+	question, answer, form, ok := h.asks.Answer(chatID, ids[0], ids[1], ids[2])
+	if !ok {
+		h.sender.AnswerCallback(callbackID, h.t(chatID, "ask.expired"))
+		h.sender.EditRemoveKeyboard(chatID, messageID, h.t(chatID, "ask.expired_card"))
+		return
+	}
+	h.sender.EditPlain(chatID, messageID, fmt.Sprintf("❓ %s\n✅ %s", question, answer), nil)
+	if form == nil {
+		h.sender.AnswerCallback(callbackID, h.t(chatID, "ask.saved"))
+		return
+	}
+	h.sender.AnswerCallback(callbackID, h.t(chatID, "ask.sent"))
+	slog.Info("questions answered", "chat_id", chatID, "form", form.ID)
+	h.sender.SendTyping(chatID)
+	h.callAI(ctx, chatID, answersMessage(form))
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseAsks(t *testing.T) {
+	text := "I need to know two things.\n" +
+		"<ask>Which database?\n- postgres\n* mysql\n3) sqlite\n</ask>\n" +
+		"  <ask>Keep the old data?\n1. yes\n2. no</ask>\n" +
+		"Use `<ask>` blocks inline and nothing happens.\n" +
+		"<ask>Anything else?</ask>"
+	clean, questions := ParseAsks(text)
+	if len(questions) != 2 {
+		t.Fatalf("questions = %+v", questions)
+	}
+	if q := questions[0]; q.Question != "Which database?" || strings.Join(q.Options, ",") != "postgres,mysql,sqlite" {
+		t.Errorf("first question = %+v", q)
+	}
+	if q := questions[1]; q.Question != "Keep the old data?" || strings.Join(q.Options, ",") != "yes,no" {
+		t.Errorf("second question = %+v", q)
+	}
+	// The prose mention stays, and a block without options becomes text.
+	if !strings.Contains(clean, "Use `<ask>` blocks inline") || !strings.HasSuffix(clean, "Anything else?") || strings.Contains(clean, "postgres") {
+		t.Errorf("clean text = %q", clean)
+	}
+}
+
+func TestParseAsksLimits(t *testing.T) {
+	var b strings.Builder
+	for range maxAskQuestions + 1 {
+		b.WriteString("<ask>Pick one\n")
+		for range maxAskOptions + 2 {
+			b.WriteString("- option\n")
+		}
+		b.WriteString("</ask>\n")
+	}
+	clean, questions := ParseAsks(b.String())
+	if len(questions) != maxAskQuestions || len(questions[0].Options) != maxAskOptions {
+		t.Errorf("%d questions, %d options", len(questions), len(questions[0].Options))
+	}
+	if !strings.HasPrefix(clean, "Pick one") {
+		t.Errorf("the extra question was dropped: %q", clean)
+	}
+}
+
+func TestAskStore(t *testing.T) {
+	s := NewAskStore()
+	questions := []AskQuestion{
+		{Question: "Region?", Options: []string{"eu", "us"}},
+		{Question: "Size?", Options: []string{"small", "large"}},
+	}
+	old := s.Open(1, questions)
+	id := s.Open(1, questions)
+	if _, _, _, ok := s.Answer(1, old, 0, 0); ok {
+		t.Error("a replaced form accepted an answer")
+	}
+	if _, _, _, ok := s.Answer(1, id, 0, 5); ok {
+		t.Error("an unknown option was accepted")
+	}
+	if q, a, done, ok := s.Answer(1, id, 1, 1); !ok || done != nil || q != "Size?" || a != "large" {
+		t.Errorf("first answer: %q %q %v %v", q, a, done, ok)
+	}
+	_, _, done, ok := s.Answer(1, id, 0, 0)
+	if !ok || done == nil {
+		t.Fatalf("last answer: %v %v", done, ok)
+	}
+	if got := answersMessage(done); got != "My answers to your questions:\n- Region? → eu\n- Size? → large" {
+		t.Errorf("message = %q", got)
+	}
+	if _, _, _, ok := s.Answer(1, id, 0, 1); ok {
+		t.Error("a completed form is still open")
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"maps"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AutorunStore holds the chats' auto-execute setting, persisted to
+// DATA_DIR. Chats without one follow SKIP_PERMISSIONS. With autorun on,
+// commands run without approval cards and Claude may use all its tools;
+// the safeguard still applies.
+type AutorunStore struct {
+	mu    sync.RWMutex
+	path  string
+	def   bool // SKIP_PERMISSIONS
+	chats map[int64]bool
+	alive *AliveSwitch // pauses auto-execute when lapsed, may be nil
+
+	parent func(chatID int64) int64 // the group a topic belongs to, may be nil
+}
+
+func NewAutorunStore(dataDir string, def bool) *AutorunStore {
+	s := &AutorunStore{
+		path:  filepath.Join(dataDir, "autorun.json"),
+		def:   def,
+		chats: make(map[int64]bool),
+	}
+	if err := loadJSONFile(s.path, &s.chats); err != nil {
+		slog.Warn("failed to load autorun settings", "path", s.path, "err", err)
+	}
+	return s
+}
+
+// SetAlive makes auto-execute pause while the dead-man switch is lapsed.
+func (s *AutorunStore) SetAlive(a *AliveSwitch) {
+	s.alive = a
+}
+
+// Enabled reports whether the chat's commands run without approval.
+func (s *AutorunStore) Enabled(chatID int64) bool {
+	return s.Setting(chatID) && !s.alive.Lapsed(time.Now())
+}
+
+// SetParentChat registers a function mapping a forum topic or member
+// conversation to its group, whose setting then applies to them too.
+func (s *AutorunStore) SetParentChat(parent func(chatID int64) int64) {
+	s.parent = parent
+}
+
+// Setting reports whether the chat has auto-execute on, paused or not. A
+// topic or member conversation follows its group too: either one turning
+// it off wins, then either one turning it on, then SKIP_PERMISSIONS.
+func (s *AutorunStore) Setting(chatID int64) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	own, ownSet := s.chats[chatID]
+	var group, groupSet bool
+	if s.parent != nil {
+		if id := s.parent(chatID); id != chatID {
+			group, groupSet = s.chats[id]
+		}
+	}
+	switch {
+	case ownSet && !own, groupSet && !group:
+		return false
+	case ownSet || groupSet:
+		return true
+	}
+	return s.def
+}
+
+// Overrides returns the chats that set auto-execute themselves, and the
+// default the other chats follow.
+func (s *AutorunStore) Overrides() (chats map[int64]bool, def bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return maps.Clone(s.chats), s.def
+}
+
+func (s *AutorunStore) Set(chatID int64, on bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.chats[chatID] = on
+	return saveJSONFile(s.path, s.chats)
+}
+
+// HandleAutorun shows or sets whether the chat's commands run without
+// approval. Turning it on needs the admin chat when one is configured. Only
+// an admin chat can set it for another chat, so without admins a chat only
+// changes its own.
+// Usage: /autorun [on|off] [chatID]
+func (h *Handlers) HandleAutorun(chatID int64, args string) {
+	a, ok := h.parseCommandArgs(chatID, "autorun", args)
+	if !ok {
+		return
+	}
+	if a.Len() == 0 {
+		state := "autorun.state_off"
+		if h.autorunPaused(chatID) {
+			state = "autorun.state_paused"
+		} else if h.claude.autorun.Enabled(chatID) {
+			state = "autorun.state_on"
+		}
+		h.sender.SendPlain(chatID, h.t(chatID, "autorun.state", h.t(chatID, state)))
+		return
+	}
+
+	var on bool
+	switch strings.ToLower(a.Arg(0)) {
+	case "on":
+		on = true
+	case "off":
+	default:
+		h.sendUsage(chatID, "autorun")
+		return
+	}
+	target := chatID
+	if a.Len() > 1 {
+		id, err := strconv.ParseInt(a.Arg(1), 10, 64)
+		if err != nil {
+			h.sendArgError(chatID, "autorun", fmt.Errorf("invalid chat ID %q", a.Arg(1)))
+			return
+		}
+		target = id
+	}
+	if !h.isAdmin(chatID) && (target != chatID || (on && len(h.admins) > 0)) {
+		h.sender.SendPlain(chatID, h.t(chatID, "autorun.admin_only"))
+		return
+	}
+
+	unlock := h.locks.Lock(target)
+	defer unlock()
+	if err := h.claude.autorun.Set(target, on); err != nil {
+		h.sender.SendPlain(chatID, h.t(chatID, "autorun.save_failed", err))
+		return
+	}
+	// Claude's tool access and command instructions are fixed when a
+	// session starts.
+	h.resetSession(target)
+	slog.Warn("autorun changed", "chat_id", target, "by_chat_id", chatID, "enabled", on)
+
+	key := "autorun.off"
+	if on {
+		key = "autorun.on"
+	}
+	if target != chatID {
+		h.sender.SendPlain(target, h.t(target, key))
+		h.sender.SendPlain(chatID, h.t(chatID, "autorun.other", target, h.onOff(chatID, on)))
+		return
+	}
+	h.sender.SendPlain(chatID, h.t(chatID, key))
+}
@@ -0,0 +1,50 @@
+package main
+
+import "testing"
+
+func TestAutorunStore(t *testing.T) {
+	dir := t.TempDir()
+	s := NewAutorunStore(dir, false)
+	if s.Enabled(1) {
+		t.Error("autorun on by default with SKIP_PERMISSIONS=false")
+	}
+	if err := s.Set(1, true); err != nil {
+		t.Fatal(err)
+	}
+
+	s = NewAutorunStore(dir, true)
+	if !s.Enabled(1) {
+		t.Error("chat 1 lost its setting on reload")
+	}
+	if !s.Enabled(2) {
+		t.Error("chat 2 does not follow SKIP_PERMISSIONS=true")
+	}
+	s.Set(2, false)
+	if s.Enabled(2) {
+		t.Error("chat 2 cannot opt out of SKIP_PERMISSIONS")
+	}
+}
+
+func TestAutorunFollowsGroup(t *testing.T) {
+	const group, topic = -100, -200
+	s := NewAutorunStore(t.TempDir(), false)
+	s.SetParentChat(func(id int64) int64 {
+		if id == topic {
+			return group
+		}
+		return id
+	})
+	s.Set(group, true)
+	if !s.Enabled(topic) {
+		t.Error("topic does not follow its group's autorun")
+	}
+	s.Set(topic, false)
+	if s.Enabled(topic) {
+		t.Error("topic cannot opt out of its group's autorun")
+	}
+	s.Set(topic, true)
+	s.Set(group, false)
+	if s.Enabled(topic) {
+		t.Error("topic autoruns with autorun off for its group")
+	}
+}
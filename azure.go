@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const defaultAzureAPIVersion = "2024-10-21"
+
+// azureResponse is Azure OpenAI's chat completions response. It matches
+// OpenAI's, except that error codes are strings ("401", "content_filter").
+type azureResponse struct {
+	Model   string `json:"model"`
+	Choices []struct {
+		Message      openRouterMessage `json:"message"`
+		FinishReason string            `json:"finish_reason"`
+	} `json:"choices"`
+	Usage openAIUsage `json:"usage"`
+	Error *struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// AzureOpenAIClient calls a chat model deployed on Azure OpenAI, for
+// organizations that may only reach OpenAI models through their Azure
+// tenant. It is configured entirely from the environment: the resource
+// endpoint, the deployment name and the API version pin the model.
+type AzureOpenAIClient struct {
+	endpoint     string
+	deployment   string
+	apiVersion   string
+	apiKey       string
+	systemPrompt string
+	httpClient   *http.Client
+}
+
+func NewAzureOpenAIClient(cfg *Config) *AzureOpenAIClient {
+	apiVersion := cfg.AzureAPIVersion
+	if apiVersion == "" {
+		apiVersion = defaultAzureAPIVersion
+	}
+	return &AzureOpenAIClient{
+		endpoint:     strings.TrimRight(cfg.AzureEndpoint, "/"),
+		deployment:   cfg.AzureDeployment,
+		apiVersion:   apiVersion,
+		apiKey:       cfg.AzureAPIKey,
+		systemPrompt: chatSystemPrompt(cfg),
+		httpClient:   &http.Client{Timeout: 120 * time.Second},
+	}
+}
+
+// Configured reports whether the endpoint, deployment and key are all set.
+func (a *AzureOpenAIClient) Configured() bool {
+	return a.endpoint != "" && a.deployment != "" && a.apiKey != ""
+}
+
+// Send sends a message with the full conversation context and returns the
+// reply with the call's usage. history uses Gemini's roles ("user"/"model").
+func (a *AzureOpenAIClient) Send(ctx context.Context, history []GeminiMessage, message string) (string, APIUsage, error) {
+	return a.send(ctx, history, message, nil)
+}
+
+// Stream is Send with the reply streamed: onDelta is called with each piece
+// of text as it arrives. If ctx is cancelled mid-stream, the text received
+// so far is returned with ctx's error.
+func (a *AzureOpenAIClient) Stream(ctx context.Context, history []GeminiMessage, message string, onDelta func(string)) (string, APIUsage, error) {
+	return a.send(ctx, history, message, onDelta)
+}
+
+func (a *AzureOpenAIClient) send(ctx context.Context, history []GeminiMessage, message string, onDelta func(string)) (string, APIUsage, error) {
+	usage := APIUsage{Model: a.deployment}
+	if !a.Configured() {
+		return "", usage, fmt.Errorf("azure openai is not configured")
+	}
+
+	reqBody := map[string]any{"messages": openAIMessages(systemPromptFrom(ctx, a.systemPrompt), history, message)}
+	if n := maxTokensFrom(ctx); n > 0 {
+		reqBody["max_tokens"] = n
+	}
+	if onDelta != nil {
+		reqBody["stream"] = true
+		reqBody["stream_options"] = map[string]bool{"include_usage": true}
+	}
+	body, err := json.Marshal(reqBody)
+	if err != nil {
+		return "", usage, fmt.Errorf("marshal request: %w", err)
+	}
+	endpoint := fmt.Sprintf("%s/openai/deployments/%s/chat/completions?api-version=%s",
+		a.endpoint, url.PathEscape(a.deployment), url.QueryEscape(a.apiVersion))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return "", usage, fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("api-key", a.apiKey)
+
+	slog.Debug("azure openai API call", "deployment", a.deployment, "history_turns", len(history))
+	start := time.Now()
+	resp, err := a.httpClient.Do(req)
+	if err != nil {
+		return "", usage, fmt.Errorf("HTTP request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	// Errors are plain JSON even when streaming was asked for.
+	if onDelta != nil && resp.StatusCode == http.StatusOK {
+		st, err := readChatStream(ctx, resp.Body, onDelta)
+		slog.Info("azure openai API stream", "duration", time.Since(start), "bytes", len(st.Text), "err", err)
+		if st.Model == "" {
+			st.Model = a.deployment
+		}
+		usage = st.Usage.apiUsage(st.Model)
+		if err != nil {
+			return st.Text, usage, fmt.Errorf("azure openai: %w", err)
+		}
+		result := strings.TrimSpace(st.Text)
+		if result == "" {
+			return "", usage, fmt.Errorf("azure openai returned empty response (finish_reason=%s)", st.FinishReason)
+		}
+		return result, usage, nil
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", usage, fmt.Errorf("read response: %w", err)
+	}
+	slog.Info("azure openai API response", "duration", time.Since(start), "status", resp.StatusCode, "body_bytes", len(respBody))
+
+	var apiResp azureResponse
+	if err := json.Unmarshal(respBody, &apiResp); err != nil {
+		return "", usage, fmt.Errorf("unmarshal response (status %d): %w\nraw: %.500s", resp.StatusCode, err, respBody)
+	}
+	if apiResp.Model != "" {
+		usage.Model = apiResp.Model
+	}
+	usage = apiResp.Usage.apiUsage(usage.Model)
+
+	if apiResp.Error != nil || resp.StatusCode != http.StatusOK {
+		msg := string(respBody)
+		if apiResp.Error != nil {
+			msg = apiResp.Error.Message
+		}
+		slog.Error("azure openai API error", "status", resp.StatusCode, "message", msg)
+		if resp.StatusCode == http.StatusUnauthorized {
+			return "", usage, fmt.Errorf("azure openai API error (401): invalid api key: %s", msg)
+		}
+		return "", usage, fmt.Errorf("azure openai API error (%d): %s", resp.StatusCode, msg)
+	}
+	if len(apiResp.Choices) == 0 {
+		return "", usage, fmt.Errorf("azure openai returned no choices (raw: %.300s)", respBody)
+	}
+	result := strings.TrimSpace(apiResp.Choices[0].Message.Content)
+	if result == "" {
+		return "", usage, fmt.Errorf("azure openai returned empty response (finish_reason=%s)", apiResp.Choices[0].FinishReason)
+	}
+	return result, usage, nil
+}
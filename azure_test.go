@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAzureOpenAISend(t *testing.T) {
+	var got struct {
+		Messages []openRouterMessage `json:"messages"`
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/openai/deployments/gpt-4o/chat/completions" || r.URL.Query().Get("api-version") != defaultAzureAPIVersion {
+			t.Errorf("unexpected request %s", r.URL)
+		}
+		if r.Header.Get("api-key") != "azkey" {
+			t.Errorf("api-key header = %q", r.Header.Get("api-key"))
+		}
+		body, _ := io.ReadAll(r.Body)
+		json.Unmarshal(body, &got)
+		io.WriteString(w, `{"model":"gpt-4o-2024-08-06","choices":[{"message":{"role":"assistant","content":"hi"},"finish_reason":"stop"}],
+			"usage":{"prompt_tokens":50,"completion_tokens":2,"total_tokens":52}}`)
+	}))
+	defer srv.Close()
+
+	a := NewAzureOpenAIClient(&Config{AzureEndpoint: srv.URL + "/", AzureDeployment: "gpt-4o", AzureAPIKey: "azkey"})
+	reply, usage, err := a.Send(context.Background(), []GeminiMessage{{Role: "user", Content: "q"}, {Role: "model", Content: "a"}}, "next")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply != "hi" || usage.TotalTokens != 52 || usage.CostUSD != 0 || usage.Model != "gpt-4o-2024-08-06" {
+		t.Errorf("reply %q usage %+v", reply, usage)
+	}
+	if len(got.Messages) != 4 || got.Messages[2].Role != "assistant" || got.Messages[3].Content != "next" {
+		t.Errorf("messages = %+v", got.Messages)
+	}
+}
+
+func TestAzureOpenAISendErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		io.WriteString(w, `{"error":{"code":"401","message":"Access denied due to invalid subscription key."}}`)
+	}))
+	defer srv.Close()
+
+	a := NewAzureOpenAIClient(&Config{AzureEndpoint: srv.URL, AzureDeployment: "gpt-4o", AzureAPIKey: "bad"})
+	if _, _, err := a.Send(context.Background(), nil, "hello"); !IsGeminiNotLoggedIn(err) {
+		t.Errorf("401 should read as not logged in, got %v", err)
+	}
+
+	a.deployment = ""
+	if a.Configured() {
+		t.Error("client without a deployment should not be configured")
+	}
+}
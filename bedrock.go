@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// --- Bedrock Converse API types ---
+
+type bedrockContent struct {
+	Text string `json:"text"`
+}
+
+type bedrockMessage struct {
+	Role    string           `json:"role"`
+	Content []bedrockContent `json:"content"`
+}
+
+type bedrockRequest struct {
+	System          []bedrockContent        `json:"system"`
+	Messages        []bedrockMessage        `json:"messages"`
+	InferenceConfig *bedrockInferenceConfig `json:"inferenceConfig,omitempty"`
+}
+
+type bedrockInferenceConfig struct {
+	MaxTokens int `json:"maxTokens,omitempty"`
+}
+
+type bedrockResponse struct {
+	Output struct {
+		Message bedrockMessage `json:"message"`
+	} `json:"output"`
+	StopReason string `json:"stopReason"`
+	Usage      struct {
+		InputTokens     int64 `json:"inputTokens"`
+		OutputTokens    int64 `json:"outputTokens"`
+		TotalTokens     int64 `json:"totalTokens"`
+		CacheReadTokens int64 `json:"cacheReadInputTokens"`
+	} `json:"usage"`
+	Message string `json:"message"` // set on errors
+}
+
+// BedrockClient calls a model on AWS Bedrock through the Converse API, which
+// takes the same request shape for every model family (Anthropic, Meta,
+// Mistral, Amazon...). Requests are signed with SigV4 using static
+// credentials from the environment.
+type BedrockClient struct {
+	region       string
+	modelID      string
+	creds        awsCredentials
+	systemPrompt string
+	endpoint     string // https://bedrock-runtime.<region>.amazonaws.com
+	httpClient   *http.Client
+}
+
+func NewBedrockClient(cfg *Config) *BedrockClient {
+	return &BedrockClient{
+		region:  cfg.BedrockRegion,
+		modelID: cfg.BedrockModelID,
+		creds: awsCredentials{
+			AccessKeyID:     cfg.AWSAccessKeyID,
+			SecretAccessKey: cfg.AWSSecretKey,
+			SessionToken:    cfg.AWSSessionToken,
+		},
+		systemPrompt: chatSystemPrompt(cfg),
+		endpoint:     "https://bedrock-runtime." + cfg.BedrockRegion + ".amazonaws.com",
+		httpClient:   &http.Client{Timeout: 120 * time.Second},
+	}
+}
+
+// Configured reports whether the region, model ID and credentials are all set.
+func (b *BedrockClient) Configured() bool {
+	return b.region != "" && b.modelID != "" && b.creds.AccessKeyID != "" && b.creds.SecretAccessKey != ""
+}
+
+// Send sends a message with the full conversation context and returns the
+// reply with the call's usage. history uses Gemini's roles ("user"/"model").
+func (b *BedrockClient) Send(ctx context.Context, history []GeminiMessage, message string) (string, APIUsage, error) {
+	usage := APIUsage{Model: b.modelID}
+	if !b.Configured() {
+		return "", usage, fmt.Errorf("bedrock is not configured")
+	}
+
+	reqBody := bedrockRequest{System: []bedrockContent{{Text: systemPromptFrom(ctx, b.systemPrompt)}}}
+	if n := maxTokensFrom(ctx); n > 0 {
+		reqBody.InferenceConfig = &bedrockInferenceConfig{MaxTokens: n}
+	}
+	for _, m := range history {
+		role := "user"
+		if m.Role == "model" {
+			role = "assistant"
+		}
+		reqBody.Messages = append(reqBody.Messages, bedrockMessage{Role: role, Content: []bedrockContent{{Text: m.Content}}})
+	}
+	// The command protocol is explained on the first message, as for Gemini.
+	if len(history) == 0 {
+		message = geminiCommandInstruction + message
+	}
+	reqBody.Messages = append(reqBody.Messages, bedrockMessage{Role: "user", Content: []bedrockContent{{Text: message}}})
+
+	body, err := json.Marshal(reqBody)
+	if err != nil {
+		return "", usage, fmt.Errorf("marshal request: %w", err)
+	}
+	// Model IDs and inference profile ARNs contain ":" and "/", which must
+	// be escaped to stay a single path segment.
+	endpoint := b.endpoint + "/model/" + awsURIEncode(b.modelID, true) + "/converse"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return "", usage, fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	signV4(req, body, b.creds, b.region, "bedrock", time.Now())
+
+	slog.Debug("bedrock API call", "model", b.modelID, "history_turns", len(history))
+	start := time.Now()
+	resp, err := b.httpClient.Do(req)
+	if err != nil {
+		return "", usage, fmt.Errorf("HTTP request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", usage, fmt.Errorf("read response: %w", err)
+	}
+	slog.Info("bedrock API response", "duration", time.Since(start), "status", resp.StatusCode, "body_bytes", len(respBody))
+
+	var apiResp bedrockResponse
+	if err := json.Unmarshal(respBody, &apiResp); err != nil {
+		return "", usage, fmt.Errorf("unmarshal response (status %d): %w\nraw: %.500s", resp.StatusCode, err, respBody)
+	}
+	usage.PromptTokens = apiResp.Usage.InputTokens
+	usage.CompletionTokens = apiResp.Usage.OutputTokens
+	usage.CachedTokens = apiResp.Usage.CacheReadTokens
+	usage.TotalTokens = apiResp.Usage.TotalTokens
+
+	if resp.StatusCode != http.StatusOK {
+		errType := resp.Header.Get("X-Amzn-ErrorType")
+		errType, _, _ = strings.Cut(errType, ":")
+		slog.Error("bedrock API error", "status", resp.StatusCode, "type", errType, "message", apiResp.Message)
+		if resp.StatusCode == http.StatusForbidden {
+			return "", usage, fmt.Errorf("bedrock API error (403 %s): permission denied: %s", errType, apiResp.Message)
+		}
+		return "", usage, fmt.Errorf("bedrock API error (%d %s): %s", resp.StatusCode, errType, apiResp.Message)
+	}
+	var text []string
+	for _, c := range apiResp.Output.Message.Content {
+		if c.Text != "" {
+			text = append(text, c.Text)
+		}
+	}
+	result := strings.TrimSpace(strings.Join(text, "\n"))
+	if result == "" {
+		return "", usage, fmt.Errorf("bedrock returned empty response (stopReason=%s)", apiResp.StopReason)
+	}
+	return result, usage, nil
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestSignV4 checks the signer against the "get-vanilla" case of AWS's
+// SigV4 test suite.
+func TestSignV4(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, "https://example.amazonaws.com/", nil)
+	creds := awsCredentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"}
+	signV4(req, nil, creds, "us-east-1", "service", time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC))
+
+	want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, " +
+		"SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"
+	if got := req.Header.Get("Authorization"); got != want {
+		t.Errorf("Authorization =\n%s\nwant\n%s", got, want)
+	}
+	if req.Header.Get("X-Amz-Date") != "20150830T123600Z" {
+		t.Errorf("X-Amz-Date = %q", req.Header.Get("X-Amz-Date"))
+	}
+}
+
+func TestAWSURIEncode(t *testing.T) {
+	if got := awsURIEncode("anthropic.claude-3-5-sonnet-20240620-v1:0", true); got != "anthropic.claude-3-5-sonnet-20240620-v1%3A0" {
+		t.Errorf("model ID = %q", got)
+	}
+	if got := awsURIEncode("/model/a%3A0/converse", false); got != "/model/a%253A0/converse" {
+		t.Errorf("path = %q", got)
+	}
+}
+
+func TestBedrockSend(t *testing.T) {
+	var got bedrockRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.EscapedPath() != "/model/anthropic.claude-v2%3A1/converse" {
+			t.Errorf("path = %s", r.URL.EscapedPath())
+		}
+		auth := r.Header.Get("Authorization")
+		if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKID/") || !strings.Contains(auth, "/eu-west-1/bedrock/aws4_request") ||
+			!strings.Contains(auth, "SignedHeaders=content-type;host;x-amz-date;x-amz-security-token,") {
+			t.Errorf("Authorization = %q", auth)
+		}
+		if r.Header.Get("X-Amz-Security-Token") != "tok" {
+			t.Errorf("missing session token")
+		}
+		body, _ := io.ReadAll(r.Body)
+		json.Unmarshal(body, &got)
+		io.WriteString(w, `{"output":{"message":{"role":"assistant","content":[{"text":"hello"},{"text":"there"}]}},
+			"stopReason":"end_turn","usage":{"inputTokens":30,"outputTokens":4,"totalTokens":34}}`)
+	}))
+	defer srv.Close()
+
+	b := NewBedrockClient(&Config{BedrockRegion: "eu-west-1", BedrockModelID: "anthropic.claude-v2:1",
+		AWSAccessKeyID: "AKID", AWSSecretKey: "secret", AWSSessionToken: "tok"})
+	b.endpoint = srv.URL
+	reply, usage, err := b.Send(context.Background(), []GeminiMessage{{Role: "user", Content: "q"}, {Role: "model", Content: "a"}}, "next")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply != "hello\nthere" || usage.PromptTokens != 30 || usage.TotalTokens != 34 {
+		t.Errorf("reply %q usage %+v", reply, usage)
+	}
+	if len(got.System) != 1 || len(got.Messages) != 3 || got.Messages[1].Role != "assistant" || got.Messages[2].Content[0].Text != "next" {
+		t.Errorf("request = %+v", got)
+	}
+}
+
+func TestBedrockSendErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Amzn-ErrorType", "AccessDeniedException:http://internal.amazon.com/coral/com.amazon.bedrock/")
+		w.WriteHeader(http.StatusForbidden)
+		io.WriteString(w, `{"message":"You don't have access to the model with the specified model ID."}`)
+	}))
+	defer srv.Close()
+
+	b := NewBedrockClient(&Config{BedrockRegion: "us-east-1", BedrockModelID: "m", AWSAccessKeyID: "AKID", AWSSecretKey: "secret"})
+	b.endpoint = srv.URL
+	_, _, err := b.Send(context.Background(), nil, "hello")
+	if !IsGeminiNotLoggedIn(err) || !strings.Contains(err.Error(), "AccessDeniedException") {
+		t.Errorf("403 error = %v", err)
+	}
+}
@@ -1,114 +1,313 @@
 package main
 
 import (
-	"context"
-	"log"
+	"log/slog"
+	"net/http"
+	"strings"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Bot ties together the Telegram API, AI clients, and handlers.
 type Bot struct {
-	api      *tgbotapi.BotAPI
-	handlers *Handlers
+	api           *tgbotapi.BotAPI
+	cfg           *Config
+	handlers      *Handlers
+	ciWebhook     *CIWebhookServer
+	webhookServer *http.Server
+	webhookStop   chan struct{}        // closed when the webhook stops taking updates
+	webhookQueue  chan tgbotapi.Update // closed once the webhook server is down, ending Run
+	health        *HealthServer
+	profiling     *ProfilingServer
+	state         *SessionState
+	slots         chan struct{} // bounds concurrently handled updates; nil = unbounded
+	turns         *turnTracker
+	albums        *albumCollector
 }
 
 func NewBot(cfg *Config) (*Bot, error) {
-	api, err := tgbotapi.NewBotAPI(cfg.TelegramToken)
+	return newBot(cfg, tgbotapi.APIEndpoint)
+}
+
+// newBot creates a Bot talking to the Bot API at endpoint, a format string
+// taking the token and the method (see tgbotapi.APIEndpoint).
+func newBot(cfg *Config, endpoint string) (*Bot, error) {
+	topics := NewTopicStore(cfg.DataDir, cfg.PerUserSessions)
+	api, err := tgbotapi.NewBotAPIWithClient(cfg.TelegramToken, endpoint, &topicClient{topics: topics, client: &http.Client{}})
 	if err != nil {
 		return nil, err
 	}
 
-	log.Printf("Authorized as @%s", api.Self.UserName)
+	slog.Info("authorized", "bot", "@"+api.Self.UserName)
 
-	sender := NewSender(api, []string{cfg.TelegramToken})
+	sender := NewSender(api, []string{cfg.TelegramToken, cfg.AzureAPIKey, cfg.TTSAPIKey, cfg.WhisperAPIKey, cfg.AWSSecretKey, cfg.AWSSessionToken})
+	if cfg.TypingInterval <= 0 {
+		sender.DisableTyping()
+	}
+	sender.SetCoalesce(cfg.SendCoalesce)
+	sender.SetRetry(cfg.SendRetries, cfg.SendPace)
+	sender.SetParentChat(topics.Chat)
 	claude := NewClaudeClient(cfg)
 	gemini := NewGeminiClient(cfg)
 	sessions := NewSessionManager()
 	geminiSessions := NewGeminiSessionStore()
 	providers := NewProviderStore(cfg.DefaultProvider)
-	approvals := NewApprovalStore()
+	approvals := NewApprovalStore(cfg.DataDir)
 	logins := NewLoginStore()
-	usage := NewUsageTracker()
-	media := &MediaHandler{api: api, workDir: cfg.WorkDir, whisperCmd: cfg.WhisperCmd}
-	handlers := NewHandlers(sender, claude, gemini, sessions, geminiSessions, providers, approvals, logins, usage, media, cfg)
+	state := NewSessionState(cfg.DataDir, cfg.GeminiSaveBytes)
+	state.Load(geminiSessions, providers)
+	usage := NewUsageTracker(cfg.DataDir)
+	media := &MediaHandler{
+		api:         api,
+		transcriber: NewTranscriber(cfg),
+		maxBytes:    cfg.MediaMaxBytes,
+		ffmpeg:      cfg.FFmpegCmd,
+		keyframes:   cfg.VideoKeyframes,
+	}
+	handlers := NewHandlers(sender, claude, gemini, sessions, geminiSessions, providers, approvals, logins, usage, media, topics, cfg)
 
-	return &Bot{
+	b := &Bot{
 		api:      api,
+		cfg:      cfg,
 		handlers: handlers,
-	}, nil
+		state:    state,
+		turns:    newTurnTracker(),
+		albums:   newAlbumCollector(albumWindow),
+	}
+	if cfg.MaxConcurrent > 0 {
+		b.slots = make(chan struct{}, cfg.MaxConcurrent)
+	}
+	if cfg.CIWebhookAddr != "" {
+		b.ciWebhook = NewCIWebhookServer(cfg, handlers)
+	}
+	if cfg.HealthAddr != "" {
+		b.health = NewHealthServer(cfg, api, gemini, handlers.openrouter, handlers.azure, handlers.bedrock)
+	}
+	if cfg.MetricsAddr != "" {
+		b.profiling = NewProfilingServer(cfg.MetricsAddr)
+	}
+	return b, nil
 }
 
 // Run starts the update loop. Blocks until the bot is stopped.
 func (b *Bot) Run() {
-	u := tgbotapi.NewUpdate(0)
-	u.Timeout = 60
+	if b.ciWebhook != nil {
+		go func() {
+			if err := b.ciWebhook.ListenAndServe(); err != nil {
+				slog.Warn("CI webhook server stopped", "err", err)
+			}
+		}()
+	}
+	if b.health != nil {
+		go func() {
+			if err := b.health.ListenAndServe(); err != nil {
+				slog.Warn("health server stopped", "err", err)
+			}
+		}()
+	}
+	if b.profiling != nil {
+		go func() {
+			if err := b.profiling.ListenAndServe(); err != nil {
+				slog.Warn("profiling server stopped", "err", err)
+			}
+		}()
+	}
+	if b.handlers.reportSchedule != nil {
+		go b.handlers.runUsageReports()
+	}
+	if b.handlers.hygieneReport != nil {
+		go b.handlers.runHygieneReports()
+	}
+	go b.handlers.registerCommandMenu()
+	go b.handlers.resumeLogins()
+	go b.handlers.resumeApprovals()
+	if b.cfg.AlivePeriod > 0 {
+		go b.handlers.watchAlive()
+	}
 
-	updates := b.api.GetUpdatesChan(u)
+	var updates tgbotapi.UpdatesChannel
+	if b.cfg.WebhookURL != "" {
+		var err error
+		if updates, err = b.startWebhook(); err != nil {
+			slog.Warn("webhook setup failed, falling back to long polling", "err", err)
+		}
+	}
+	if updates == nil {
+		// getUpdates is rejected while a webhook is registered.
+		if _, err := b.api.Request(tgbotapi.DeleteWebhookConfig{}); err != nil {
+			slog.Warn("deleteWebhook failed", "err", err)
+		}
+		u := tgbotapi.NewUpdate(0)
+		u.Timeout = 60
+		updates = b.api.GetUpdatesChan(u)
+	}
 
+	// Forum topics are chats of their own from here on.
+	topics := b.handlers.topics
 	for update := range updates {
 		if update.CallbackQuery != nil {
-			go b.handleCallback(update)
+			var chatID int64
+			if m := update.CallbackQuery.Message; m != nil {
+				chatID = topics.Take(m.Chat.ID, m.MessageID)
+			}
+			b.dispatch(chatID, true, func() { b.handleCallback(chatID, update) })
 			continue
 		}
 		if update.Message == nil {
 			continue
 		}
-		go b.handleUpdate(update)
+		chatID := topics.Take(update.Message.Chat.ID, update.Message.MessageID)
+		b.dispatch(chatID, poolExempt[update.Message.Command()], func() { b.handleUpdate(chatID, update) })
+	}
+}
+
+// poolExempt are the commands handled without waiting for a MAX_CONCURRENT
+// slot, so an admin can still stop or freeze a saturated bot.
+var poolExempt = map[string]bool{"panic": true, "resume": true, "freeze": true, "unfreeze": true, "alive": true}
+
+// dispatch handles an update in its own goroutine once a MAX_CONCURRENT slot
+// is free, so a burst of chats cannot run unlimited claude processes and
+// commands at once. The update loop never waits for a slot: button presses
+// (approve, deny, stop) and the poolExempt commands run straight away, and
+// other updates wait in their goroutine. Updates arriving after shutdown
+// began are refused.
+func (b *Bot) dispatch(chatID int64, exempt bool, fn func()) {
+	if !b.turns.begin(chatID) {
+		b.refuse(chatID)
+		return
+	}
+	go func() {
+		defer b.turns.end(chatID)
+		if b.slots != nil && !exempt {
+			select {
+			case b.slots <- struct{}{}:
+			default:
+				slog.Warn("all workers busy, waiting for a free slot", "chat_id", chatID, "max_concurrent", cap(b.slots))
+				b.slots <- struct{}{}
+			}
+			defer func() { <-b.slots }()
+		}
+		fn()
+	}()
+}
+
+// Reload re-reads the configuration on SIGHUP and tells the admin chat
+// the outcome.
+func (b *Bot) Reload() {
+	changed, err := b.handlers.ReloadConfig()
+	if err != nil {
+		slog.Error("config reload failed", "err", err)
 	}
+	if b.cfg.AdminChatID != 0 {
+		b.handlers.sender.SendPlain(b.cfg.AdminChatID, reloadSummary(changed, err))
+	}
+}
+
+// Stop flushes persisted state before the process exits.
+func (b *Bot) Stop() {
+	b.handlers.metrics.Save()
+	b.handlers.ledger.Save()
+	b.handlers.usage.Save()
+	b.handlers.verdicts.Save()
+	b.state.Save(b.handlers.geminiSessions, b.handlers.providers)
+	b.handlers.sender.FlushAll()
+	b.stopWebhook()
+	b.handlers.tunnels.StopAll()
 }
 
-func (b *Bot) handleUpdate(update tgbotapi.Update) {
-	log.Printf("Received update %d for chat %d", update.UpdateID, update.Message.Chat.ID)
+// handleUpdate handles a message for chatID, which is the message's forum
+// topic when it was posted in one.
+func (b *Bot) handleUpdate(chatID int64, update tgbotapi.Update) {
+	slog.Debug("received update", "update_id", update.UpdateID, "chat_id", chatID)
 	msg := update.Message
-	chatID := msg.Chat.ID
+
+	ctx, span := tracer.Start(b.handlers.emergency.Context(), "telegram.update", trace.WithAttributes(
+		attribute.Int64("chat.id", chatID),
+		attribute.Int("update.id", update.UpdateID),
+	))
+	defer span.End()
+	if msg.Date > 0 {
+		ctx = withReceived(ctx, msg.Time())
+	}
 
 	// Auth check.
 	if !b.handlers.IsAllowed(chatID) {
-		b.handlers.HandleUnauthorized(chatID)
+		text := msg.Text
+		if text == "" {
+			text = msg.Caption
+		}
+		b.handlers.HandleUnauthorized(UnauthorizedContact{ChatID: msg.Chat.ID, ChatTitle: msg.Chat.Title, From: displayName(msg.From), Text: text})
 		return
 	}
+	if msg.From != nil {
+		ctx = withUser(ctx, chatUser{ID: msg.From.ID, Name: displayName(msg.From)})
+	}
 
 	// Command routing.
 	if msg.IsCommand() {
-		switch msg.Command() {
-		case "start":
-			b.handlers.HandleStart(chatID)
-		case "new":
-			b.handlers.HandleNew(chatID)
-		case "login":
-			b.handlers.HandleLogin(context.Background(), chatID)
-		case "help":
-			b.handlers.HandleHelp(chatID)
-		case "usage":
-			b.handlers.HandleUsage(chatID)
-		case "safeguard":
-			b.handlers.HandleSafeguard(chatID, msg.CommandArguments())
-		case "gemini":
-			b.handlers.HandleSwitchProvider(chatID, "gemini")
-		case "claude":
-			b.handlers.HandleSwitchProvider(chatID, "claude")
-		case "model":
-			b.handlers.HandleModel(chatID)
-		case "gmodel":
-			b.handlers.HandleGeminiModel(chatID)
-		default:
-			b.handlers.HandleHelp(chatID)
-		}
+		span.SetAttributes(attribute.String("telegram.command", msg.Command()))
+		b.handlers.RunCommand(ctx, chatID, msg.Command(), msg.CommandArguments())
 		return
 	}
 
 	// Media messages.
 	if msg.Photo != nil {
-		go b.handlers.HandlePhoto(context.Background(), chatID, msg.Photo, msg.Caption)
+		caption := msg.Caption
+		if caption != "" {
+			caption = withReplyContext(caption, msg.ReplyToMessage, b.api.Self.ID)
+		}
+		if msg.MediaGroupID == "" {
+			b.handlers.HandlePhoto(ctx, chatID, msg.Photo, caption)
+			return
+		}
+		// The album's first photo handles the whole album.
+		if b.albums.Add(msg.MediaGroupID, msg.Photo[len(msg.Photo)-1], caption) {
+			photos, caption := b.albums.Wait(msg.MediaGroupID)
+			b.handlers.HandlePhotos(ctx, chatID, photos, caption)
+		}
 		return
 	}
 	if msg.Voice != nil {
-		go b.handlers.HandleVoice(context.Background(), chatID, msg.Voice, msg.Caption)
+		b.handlers.HandleVoice(ctx, chatID, msg.Voice, msg.Caption)
 		return
 	}
 	if msg.Audio != nil {
-		go b.handlers.HandleAudio(context.Background(), chatID, msg.Audio, msg.Caption)
+		b.handlers.HandleAudio(ctx, chatID, msg.Audio, msg.Caption)
+		return
+	}
+	if msg.Video != nil {
+		caption := msg.Caption
+		if caption != "" {
+			caption = withReplyContext(caption, msg.ReplyToMessage, b.api.Self.ID)
+		}
+		b.handlers.HandleVideo(ctx, chatID, msg.Video, caption)
+		return
+	}
+	if msg.VideoNote != nil {
+		b.handlers.HandleVideoNote(ctx, chatID, msg.VideoNote)
+		return
+	}
+	if msg.Sticker != nil {
+		b.handlers.HandleSticker(ctx, chatID, msg.Sticker)
+		return
+	}
+	// GIFs also come with a Document, which holds the MP4.
+	if msg.Animation != nil {
+		caption := msg.Caption
+		if caption != "" {
+			caption = withReplyContext(caption, msg.ReplyToMessage, b.api.Self.ID)
+		}
+		b.handlers.HandleAnimation(ctx, chatID, msg.Animation, caption)
+		return
+	}
+	if msg.Document != nil {
+		caption := msg.Caption
+		if caption != "" {
+			caption = withReplyContext(caption, msg.ReplyToMessage, b.api.Self.ID)
+		}
+		b.handlers.HandleDocument(ctx, chatID, msg.Document, caption)
 		return
 	}
 
@@ -121,19 +320,55 @@ func (b *Bot) handleUpdate(update tgbotapi.Update) {
 		return
 	}
 
-	b.handlers.HandleMessage(context.Background(), chatID, text)
+	// Reply keyboard buttons arrive as plain text.
+	if b.handlers.HandleShortcut(ctx, chatID, text) {
+		return
+	}
+
+	// Replies carry the message they answer, so "this" has a referent. An
+	// auth code or confirmation phrase stays as typed.
+	if !b.handlers.wantsRawText(chatID) {
+		text = withReplyContext(text, msg.ReplyToMessage, b.api.Self.ID)
+	}
+	b.handlers.HandleMessage(ctx, chatID, text)
 }
 
-func (b *Bot) handleCallback(update tgbotapi.Update) {
+func (b *Bot) handleCallback(chatID int64, update tgbotapi.Update) {
 	cb := update.CallbackQuery
-	chatID := cb.Message.Chat.ID
-	log.Printf("Received callback %s for chat %d", cb.ID, chatID)
+	slog.Debug("received callback", "callback_id", cb.ID, "chat_id", chatID)
+
+	ctx, span := tracer.Start(b.handlers.emergency.Context(), "telegram.callback", trace.WithAttributes(
+		attribute.Int64("chat.id", chatID),
+		attribute.String("callback.data", cb.Data),
+	))
+	defer span.End()
 
 	// Auth check.
 	if !b.handlers.IsAllowed(chatID) {
-		b.handlers.HandleUnauthorized(chatID)
+		b.handlers.HandleUnauthorized(UnauthorizedContact{ChatID: cb.Message.Chat.ID, ChatTitle: cb.Message.Chat.Title, From: displayName(cb.From), Text: "button: " + cb.Data})
+		return
+	}
+	// With GROUP_SESSIONS=per_user a conversation's buttons are its
+	// member's alone: anyone else could approve their commands.
+	if owner := b.handlers.topics.Resolve(chatID).User; owner != 0 && (cb.From == nil || cb.From.ID != owner) {
+		slog.Warn("callback from another member rejected", "chat_id", chatID, "owner", owner)
+		b.handlers.sender.AnswerCallback(cb.ID, b.handlers.t(chatID, "callback.not_owner"))
 		return
 	}
+	if cb.From != nil {
+		ctx = withUser(ctx, chatUser{ID: cb.From.ID, Name: displayName(cb.From)})
+	}
 
-	b.handlers.HandleCallback(context.Background(), chatID, cb.ID, cb.Data, cb.Message.MessageID)
+	b.handlers.HandleCallback(ctx, chatID, cb.ID, cb.Data, cb.Message.MessageID, displayName(cb.From))
+}
+
+// displayName returns a short human-readable name for a Telegram user.
+func displayName(u *tgbotapi.User) string {
+	if u == nil {
+		return ""
+	}
+	if u.UserName != "" {
+		return "@" + u.UserName
+	}
+	return strings.TrimSpace(u.FirstName + " " + u.LastName)
 }
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDispatchSaturatedPool(t *testing.T) {
+	b := &Bot{slots: make(chan struct{}, 1), turns: newTurnTracker()}
+	release := make(chan struct{})
+	ran := make(chan string, 3)
+
+	b.dispatch(1, false, func() { ran <- "long turn"; <-release })
+	if name := <-ran; name != "long turn" {
+		t.Fatalf("ran %s", name)
+	}
+
+	returned := make(chan struct{})
+	go func() {
+		b.dispatch(2, false, func() { ran <- "queued message" })
+		b.dispatch(3, true, func() { ran <- "stop button" })
+		close(returned)
+	}()
+	select {
+	case <-returned:
+	case <-time.After(time.Second):
+		t.Fatal("the update loop blocked on a full pool")
+	}
+	select {
+	case name := <-ran:
+		if name != "stop button" {
+			t.Fatalf("ran %s, want the exempt update", name)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the exempt update waited for a slot")
+	}
+	select {
+	case name := <-ran:
+		t.Fatalf("%s ran without a free slot", name)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	select {
+	case name := <-ran:
+		if name != "queued message" {
+			t.Errorf("ran %s, want the queued message", name)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the queued message never got the freed slot")
+	}
+	if left := b.turns.wait(time.Second); len(left) != 0 {
+		t.Errorf("turns still running: %v", left)
+	}
+	if !poolExempt["panic"] || !poolExempt["freeze"] || poolExempt["new"] {
+		t.Errorf("poolExempt = %v", poolExempt)
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned instead of calling a provider whose circuit is
+// open after repeated failures.
+var ErrCircuitOpen = errors.New("provider temporarily unavailable")
+
+// CircuitBreaker stops calling a provider that keeps failing across chats
+// (an outage), so chats fail fast instead of each waiting for timeouts.
+// After threshold consecutive failures the circuit opens for cooldown; then
+// a single trial call is let through, which closes it on success or reopens
+// it on failure.
+type CircuitBreaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	circuits  map[string]*circuit
+}
+
+type circuit struct {
+	failures  int
+	openUntil time.Time
+	trial     bool // a trial call is in flight after the cooldown
+}
+
+// NewCircuitBreaker returns a breaker; a threshold of 0 disables it.
+func NewCircuitBreaker(threshold int, cooldown time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{threshold: threshold, cooldown: cooldown, circuits: make(map[string]*circuit)}
+}
+
+func (b *CircuitBreaker) get(provider string) *circuit {
+	c := b.circuits[provider]
+	if c == nil {
+		c = &circuit{}
+		b.circuits[provider] = c
+	}
+	return c
+}
+
+// Allow returns nil if a call to provider may proceed, or an error wrapping
+// ErrCircuitOpen that says when it will be retried.
+func (b *CircuitBreaker) Allow(provider string) error {
+	if b.threshold <= 0 {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	c := b.get(provider)
+	if c.failures < b.threshold {
+		return nil
+	}
+	now := time.Now()
+	if now.Before(c.openUntil) || c.trial {
+		retry := c.openUntil
+		if retry.Before(now) {
+			retry = now
+		}
+		return fmt.Errorf("%w: %s failed %d times in a row, retrying after %s", ErrCircuitOpen, provider, c.failures, retry.Format("15:04:05"))
+	}
+	c.trial = true
+	return nil
+}
+
+// Open reports whether provider's circuit is open and still cooling down.
+func (b *CircuitBreaker) Open(provider string) bool {
+	if b.threshold <= 0 {
+		return false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	c := b.get(provider)
+	return c.failures >= b.threshold && time.Now().Before(c.openUntil)
+}
+
+// Record updates provider's circuit with the outcome of a call. Only failures
+// that point at the provider (timeouts, rate limits, API and other errors)
+// count; auth problems are per chat and cancellations are not failures.
+func (b *CircuitBreaker) Record(provider string, err error) {
+	if b.threshold <= 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	c := b.get(provider)
+	wasOpen := c.failures >= b.threshold
+	c.trial = false
+	if err == nil {
+		if wasOpen {
+			slog.Info("circuit closed, provider recovered", "provider", provider)
+		}
+		c.failures = 0
+		return
+	}
+	// Missing credentials and safety blocks are not outages.
+	if errors.Is(err, context.Canceled) || classifyError(err) == "auth" || classifyError(err) == "safety" {
+		return
+	}
+	c.failures++
+	if c.failures >= b.threshold {
+		c.openUntil = time.Now().Add(b.cooldown)
+		slog.Warn("circuit open", "provider", provider, "failures", c.failures, "retry_at", c.openUntil, "err", err)
+	}
+}
+
+// otherProvider returns the provider to fall back to.
+func otherProvider(provider string) string {
+	if provider == "claude" {
+		return "gemini"
+	}
+	return "claude"
+}
+
+// providerConfigured reports whether provider can be called at all.
+func (h *Handlers) providerConfigured(provider string) bool {
+	if isChatProvider(provider) {
+		return h.hasAPIKey(provider)
+	}
+	_, err := exec.LookPath(h.claude.claudePath)
+	return err == nil
+}
+
+// failoverProvider switches the chat to the other provider when its own is
+// in an outage and CIRCUIT_FALLBACK is on. It returns the provider to use.
+func (h *Handlers) failoverProvider(chatID int64, provider string) string {
+	if !h.failover || !h.breaker.Open(provider) {
+		return provider
+	}
+	other := otherProvider(provider)
+	if h.breaker.Open(other) || !h.providerConfigured(other) {
+		return provider
+	}
+	h.providers.Set(chatID, other)
+	h.approvals.Delete(chatID)
+	slog.Warn("provider outage, switched chat to fallback", "chat_id", chatID, "from", provider, "provider", other)
+	h.sender.SendPlain(chatID, h.t(chatID, "breaker.switched",
+		providerLabel(provider), providerLabel(other), provider))
+	return other
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	b := NewCircuitBreaker(3, time.Hour)
+	apiErr := fmt.Errorf("api error (status 503)")
+
+	for i := 0; i < 2; i++ {
+		b.Record("gemini", apiErr)
+	}
+	if err := b.Allow("gemini"); err != nil {
+		t.Fatalf("circuit opened early: %v", err)
+	}
+	b.Record("gemini", apiErr)
+	if err := b.Allow("gemini"); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("Allow() = %v, want ErrCircuitOpen", err)
+	}
+	if !b.Open("gemini") || b.Open("claude") {
+		t.Error("only gemini should be open")
+	}
+
+	// After the cooldown one trial call goes through; success closes it.
+	b.circuits["gemini"].openUntil = time.Now().Add(-time.Second)
+	if err := b.Allow("gemini"); err != nil {
+		t.Fatalf("trial call not allowed: %v", err)
+	}
+	if err := b.Allow("gemini"); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("second concurrent trial allowed: %v", err)
+	}
+	b.Record("gemini", nil)
+	if err := b.Allow("gemini"); err != nil {
+		t.Fatalf("circuit not closed after success: %v", err)
+	}
+}
+
+func TestCircuitBreakerIgnoresAuthErrors(t *testing.T) {
+	b := NewCircuitBreaker(1, time.Hour)
+	b.Record("claude", fmt.Errorf("Not logged in · Please run /login"))
+	if b.Open("claude") {
+		t.Error("auth errors should not open the circuit")
+	}
+}
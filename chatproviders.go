@@ -0,0 +1,115 @@
+package main
+
+import "context"
+
+// APIUsage is the token accounting of one call to an HTTP chat provider other
+// than Gemini. CostUSD is only set when the API reports it (OpenRouter);
+// Azure OpenAI and Bedrock bill through the cloud account, so their calls are
+// tracked in tokens only.
+type APIUsage struct {
+	PromptTokens     int64
+	CompletionTokens int64
+	CachedTokens     int64
+	TotalTokens      int64
+	CostUSD          float64
+	Model            string // the model that answered (differs from the requested one for openrouter/auto)
+}
+
+// isChatProvider reports whether provider is one of the stateless chat APIs,
+// whose history the bot keeps in geminiSessions and whose commands run in the
+// bot's own shell. The only other provider is Claude, driven through its CLI.
+func isChatProvider(provider string) bool {
+	switch provider {
+	case "gemini", "openrouter", "azure", "bedrock":
+		return true
+	}
+	return false
+}
+
+// chatSystemPrompt is the system prompt of the stateless chat providers:
+// the configured one (or Gemini's default) followed by the safeguard rules.
+func chatSystemPrompt(cfg *Config) string {
+	prompt := cfg.SystemPrompt
+	if prompt == "" {
+		prompt = defaultGeminiSystemPrompt
+	}
+	return prompt + safeguardPrompt
+}
+
+// apiSender returns the Send function of a chat provider other than Gemini.
+func (h *Handlers) apiSender(provider string) func(context.Context, []GeminiMessage, string) (string, APIUsage, error) {
+	switch provider {
+	case "azure":
+		return h.azure.Send
+	case "bedrock":
+		return h.bedrock.Send
+	}
+	return h.openrouter.Send
+}
+
+// hasAPIKey reports whether a stateless chat provider has its credentials.
+func (h *Handlers) hasAPIKey(provider string) bool {
+	switch provider {
+	case "openrouter":
+		return h.openrouter.HasAPIKey()
+	case "azure":
+		return h.azure.Configured()
+	case "bedrock":
+		return h.bedrock.Configured()
+	}
+	return h.gemini.HasAPIKey()
+}
+
+// providerModel returns the model a chat provider answers with, or "" for
+// Claude, whose model is chosen by the CLI.
+func (h *Handlers) providerModel(provider string) string {
+	switch provider {
+	case "gemini":
+		return h.gemini.GetModel()
+	case "openrouter":
+		return h.openrouter.GetModel()
+	case "azure":
+		return h.azure.deployment
+	case "bedrock":
+		return h.bedrock.modelID
+	}
+	return ""
+}
+
+// chatModel returns the model the chat's calls to provider use: its own
+// pick from /models or /cmodel, else the provider's. It is "" for Claude on
+// the CLI default.
+func (h *Handlers) chatModel(chatID int64, provider string) string {
+	cs := h.settings.Get(chatID)
+	switch {
+	case provider == "claude":
+		return h.claude.Model(chatID)
+	case provider == "gemini" && cs.GeminiModel != "":
+		return cs.GeminiModel
+	case provider == "openrouter" && cs.OpenRouterModel != "":
+		return cs.OpenRouterModel
+	}
+	return h.providerModel(provider)
+}
+
+// withChatModel returns ctx carrying the chat's own model for provider, if
+// it picked one. Cost routing, applied after it, still takes precedence.
+func (h *Handlers) withChatModel(ctx context.Context, chatID int64, provider string) context.Context {
+	if provider == "claude" {
+		return ctx // ClaudeClient keeps its per-chat models itself
+	}
+	if model := h.chatModel(chatID, provider); model != h.providerModel(provider) {
+		return context.WithValue(ctx, modelKey{}, model)
+	}
+	return ctx
+}
+
+// cloudProviderHint explains how to configure Azure OpenAI or Bedrock. Their
+// credentials belong to the cloud account and are only read from the
+// environment, so unlike API keys they are never asked for in the chat.
+func (h *Handlers) cloudProviderHint(chatID int64, provider string) string {
+	if provider == "azure" {
+		return h.t(chatID, "provider.azure_hint")
+	}
+	return h.t(chatID, "provider.bedrock_hint")
+}
@@ -0,0 +1,330 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ChatSettings overrides server settings for one chat. Zero values mean
+// the server default applies.
+type ChatSettings struct {
+	Timeout     time.Duration `json:"timeout,omitempty"`
+	MaxRounds   int           `json:"max_rounds,omitempty"`
+	Provider    string        `json:"provider,omitempty"`
+	OutputLimit int           `json:"output_limit,omitempty"`
+	ResultLimit int           `json:"result_limit,omitempty"`
+	MaxTokens   int           `json:"max_tokens,omitempty"`
+	ReplyChars  int           `json:"reply_chars,omitempty"`
+	Language    string        `json:"lang,omitempty"`  // bot messages, see /lang
+	Voice       bool          `json:"voice,omitempty"` // see /voice
+	// The chat's models, picked with /models; empty means the server's.
+	GeminiModel     string `json:"gemini_model,omitempty"`
+	OpenRouterModel string `json:"openrouter_model,omitempty"`
+	// Applied to AI replies before sending, see /postprocess.
+	PostProcess []PostProcessor `json:"postprocess,omitempty"`
+}
+
+// chatSetting describes one /config key.
+type chatSetting struct {
+	Key         string
+	Description string
+	Set         func(s *ChatSettings, value string) error
+	Clear       func(s *ChatSettings)
+	// Value returns the override, or "" when the default applies.
+	Value func(s ChatSettings) string
+	// Default returns the server default.
+	Default func(h *Handlers) string
+}
+
+// intSetting parses value as an integer in [lo, hi].
+func intSetting(value string, lo, hi int) (int, error) {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < lo || n > hi {
+		return 0, fmt.Errorf("must be a number from %d to %d", lo, hi)
+	}
+	return n, nil
+}
+
+func intValue(n int) string {
+	if n == 0 {
+		return ""
+	}
+	return strconv.Itoa(n)
+}
+
+// Default chat output limits: an approved command's output, and each
+// output while auto-executing.
+const (
+	defaultOutputLimit     = 2000
+	defaultAutoOutputLimit = 1000
+)
+
+var chatSettings = []chatSetting{
+	{
+		Key:         "timeout",
+		Description: "max duration of an AI turn (10s–1h)",
+		Set: func(s *ChatSettings, v string) error {
+			d, err := time.ParseDuration(v)
+			if err != nil || d < 10*time.Second || d > time.Hour {
+				return fmt.Errorf("must be a duration from 10s to 1h, e.g. 10m")
+			}
+			s.Timeout = d
+			return nil
+		},
+		Clear: func(s *ChatSettings) { s.Timeout = 0 },
+		Value: func(s ChatSettings) string {
+			if s.Timeout == 0 {
+				return ""
+			}
+			return s.Timeout.String()
+		},
+		Default: func(h *Handlers) string { return h.defaultTimeout().String() },
+	},
+	{
+		Key:         "max_rounds",
+		Description: "command rounds per message when auto-executing (1–100)",
+		Set: func(s *ChatSettings, v string) (err error) {
+			s.MaxRounds, err = intSetting(v, 1, 100)
+			return err
+		},
+		Clear:   func(s *ChatSettings) { s.MaxRounds = 0 },
+		Value:   func(s ChatSettings) string { return intValue(s.MaxRounds) },
+		Default: func(h *Handlers) string { return strconv.Itoa(h.defaultMaxRounds()) },
+	},
+	{
+		Key:         "provider",
+		Description: "AI the chat starts with, also after a restart (claude, gemini, openrouter, azure, bedrock)",
+		Set: func(s *ChatSettings, v string) error {
+			switch v {
+			case "claude", "gemini", "openrouter", "azure", "bedrock":
+				s.Provider = v
+				return nil
+			}
+			return fmt.Errorf("must be claude, gemini, openrouter, azure or bedrock")
+		},
+		Clear:   func(s *ChatSettings) { s.Provider = "" },
+		Value:   func(s ChatSettings) string { return s.Provider },
+		Default: func(h *Handlers) string { return h.providers.defaults },
+	},
+	{
+		Key:         "output_limit",
+		Description: "characters of command output shown in chat (100–4000)",
+		Set: func(s *ChatSettings, v string) (err error) {
+			s.OutputLimit, err = intSetting(v, 100, 4000)
+			return err
+		},
+		Clear: func(s *ChatSettings) { s.OutputLimit = 0 },
+		Value: func(s ChatSettings) string { return intValue(s.OutputLimit) },
+		Default: func(*Handlers) string {
+			return fmt.Sprintf("%d (%d when auto-executing)", defaultOutputLimit, defaultAutoOutputLimit)
+		},
+	},
+	{
+		Key:         "result_limit",
+		Description: "bytes of command output above which RESULT_STRATEGY shortens it for the AI (500–100000)",
+		Set: func(s *ChatSettings, v string) (err error) {
+			s.ResultLimit, err = intSetting(v, 500, 100000)
+			return err
+		},
+		Clear:   func(s *ChatSettings) { s.ResultLimit = 0 },
+		Value:   func(s ChatSettings) string { return intValue(s.ResultLimit) },
+		Default: func(h *Handlers) string { return strconv.Itoa(h.resultMaxBytes) },
+	},
+	{
+		Key:         "max_tokens",
+		Description: "tokens the AI may generate per reply; longer replies are cut off (256–65536)",
+		Set: func(s *ChatSettings, v string) (err error) {
+			s.MaxTokens, err = intSetting(v, 256, 65536)
+			return err
+		},
+		Clear:   func(s *ChatSettings) { s.MaxTokens = 0 },
+		Value:   func(s ChatSettings) string { return intValue(s.MaxTokens) },
+		Default: func(*Handlers) string { return "provider default" },
+	},
+	{
+		Key:         "reply_chars",
+		Description: "characters the AI is asked to keep replies under; longer ones are shortened with the full answer attached (200–20000)",
+		Set: func(s *ChatSettings, v string) (err error) {
+			s.ReplyChars, err = intSetting(v, 200, 20000)
+			return err
+		},
+		Clear:   func(s *ChatSettings) { s.ReplyChars = 0 },
+		Value:   func(s ChatSettings) string { return intValue(s.ReplyChars) },
+		Default: func(*Handlers) string { return "off" },
+	},
+}
+
+func lookupChatSetting(key string) (chatSetting, bool) {
+	for _, s := range chatSettings {
+		if s.Key == key {
+			return s, true
+		}
+	}
+	return chatSetting{}, false
+}
+
+// SettingsStore holds per-chat settings, persisted to DATA_DIR.
+type SettingsStore struct {
+	mu    sync.RWMutex
+	path  string
+	chats map[int64]ChatSettings
+}
+
+func NewSettingsStore(dataDir string) *SettingsStore {
+	s := &SettingsStore{
+		path:  filepath.Join(dataDir, "chat_settings.json"),
+		chats: make(map[int64]ChatSettings),
+	}
+	if err := loadJSONFile(s.path, &s.chats); err != nil {
+		slog.Warn("failed to load chat settings", "path", s.path, "err", err)
+	}
+	return s
+}
+
+func (s *SettingsStore) Get(chatID int64) ChatSettings {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.chats[chatID]
+}
+
+// All returns a copy of every chat's settings.
+func (s *SettingsStore) All() map[int64]ChatSettings {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make(map[int64]ChatSettings, len(s.chats))
+	for id, cs := range s.chats {
+		out[id] = cs
+	}
+	return out
+}
+
+// Update applies fn to the chat's settings and saves them.
+func (s *SettingsStore) Update(chatID int64, fn func(*ChatSettings) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cs := s.chats[chatID]
+	if err := fn(&cs); err != nil {
+		return err
+	}
+	if reflect.ValueOf(cs).IsZero() {
+		delete(s.chats, chatID)
+	} else {
+		s.chats[chatID] = cs
+	}
+	return saveJSONFile(s.path, s.chats)
+}
+
+// timeoutFor returns the chat's AI turn timeout.
+func (h *Handlers) timeoutFor(chatID int64) time.Duration {
+	if d := h.settings.Get(chatID).Timeout; d > 0 {
+		return d
+	}
+	return h.defaultTimeout()
+}
+
+// maxRoundsFor returns the chat's auto-execute round limit.
+func (h *Handlers) maxRoundsFor(chatID int64) int {
+	if n := h.settings.Get(chatID).MaxRounds; n > 0 {
+		return n
+	}
+	return h.defaultMaxRounds()
+}
+
+// outputLimitFor returns how much command output the chat sees; def is
+// the limit of the calling flow.
+func (h *Handlers) outputLimitFor(chatID int64, def int) int {
+	if n := h.settings.Get(chatID).OutputLimit; n > 0 {
+		return n
+	}
+	return def
+}
+
+// resultLimitFor returns the output size above which results are
+// shortened for the AI.
+func (h *Handlers) resultLimitFor(chatID int64) int {
+	if n := h.settings.Get(chatID).ResultLimit; n > 0 {
+		return n
+	}
+	return h.resultMaxBytes
+}
+
+// HandleConfig shows or changes the chat's setting overrides.
+// Usage: /config | /config set <key> <value> | /config reset <key|all>
+func (h *Handlers) HandleConfig(chatID int64, args string) {
+	a, ok := h.parseCommandArgs(chatID, "config", args)
+	if !ok {
+		return
+	}
+	switch strings.ToLower(a.Arg(0)) {
+	case "", "show":
+		cs := h.settings.Get(chatID)
+		var b strings.Builder
+		b.WriteString(h.t(chatID, "config.title"))
+		for _, s := range chatSettings {
+			if v := s.Value(cs); v != "" {
+				b.WriteString(h.t(chatID, "config.entry", s.Key, v, s.Default(h)))
+			} else {
+				b.WriteString(h.t(chatID, "config.entry_default", s.Key, s.Default(h)))
+			}
+			fmt.Fprintf(&b, "  %s\n", s.Description)
+		}
+		b.WriteString(h.t(chatID, "config.footer"))
+		h.sender.SendPlain(chatID, b.String())
+
+	case "set":
+		if a.Len() != 3 {
+			h.sender.SendPlain(chatID, h.t(chatID, "config.set_usage"))
+			return
+		}
+		key, value := strings.ToLower(a.Arg(1)), a.Arg(2)
+		s, ok := lookupChatSetting(key)
+		if !ok {
+			h.sender.SendPlain(chatID, h.t(chatID, "config.unknown", key))
+			return
+		}
+		if key == "provider" && (value == "azure" || value == "bedrock") && !h.hasAPIKey(value) {
+			h.sender.SendPlain(chatID, h.cloudProviderHint(chatID, value))
+			return
+		}
+		if err := h.settings.Update(chatID, func(cs *ChatSettings) error { return s.Set(cs, value) }); err != nil {
+			h.sender.SendPlain(chatID, h.t(chatID, "config.invalid", key, err))
+			return
+		}
+		slog.Info("chat setting changed", "chat_id", chatID, "key", key, "value", value)
+		h.sender.SendPlain(chatID, fmt.Sprintf("%s = %s", key, s.Value(h.settings.Get(chatID))))
+		if key == "provider" && h.providers.Get(chatID) != value {
+			h.HandleSwitchProvider(chatID, value)
+		}
+
+	case "reset":
+		if a.Len() != 2 {
+			h.sender.SendPlain(chatID, h.t(chatID, "config.reset_usage"))
+			return
+		}
+		key := strings.ToLower(a.Arg(1))
+		reset := func(cs *ChatSettings) error { *cs = ChatSettings{}; return nil }
+		if key != "all" {
+			s, ok := lookupChatSetting(key)
+			if !ok {
+				h.sender.SendPlain(chatID, h.t(chatID, "config.unknown", key))
+				return
+			}
+			reset = func(cs *ChatSettings) error { s.Clear(cs); return nil }
+		}
+		if err := h.settings.Update(chatID, reset); err != nil {
+			h.sender.SendPlain(chatID, h.t(chatID, "config.save_failed", err))
+			return
+		}
+		slog.Info("chat setting reset", "chat_id", chatID, "key", key)
+		h.sender.SendPlain(chatID, h.t(chatID, "config.reset", key))
+
+	default:
+		h.sendUsage(chatID, "config")
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestChatSettings(t *testing.T) {
+	dir := t.TempDir()
+	h := &Handlers{settings: NewSettingsStore(dir), timeout: 5 * time.Minute, maxRounds: 20, resultMaxBytes: 4000}
+
+	set := func(key, value string) error {
+		s, ok := lookupChatSetting(key)
+		if !ok {
+			t.Fatalf("unknown setting %q", key)
+		}
+		return h.settings.Update(1, func(cs *ChatSettings) error { return s.Set(cs, value) })
+	}
+	for key, value := range map[string]string{"timeout": "15m", "max_rounds": "5", "output_limit": "3000", "result_limit": "8000", "provider": "gemini"} {
+		if err := set(key, value); err != nil {
+			t.Errorf("set %s %s: %v", key, value, err)
+		}
+	}
+	for key, value := range map[string]string{"timeout": "2h", "max_rounds": "0", "output_limit": "5000", "result_limit": "x", "provider": "watson"} {
+		if err := set(key, value); err == nil {
+			t.Errorf("set %s %s succeeded", key, value)
+		}
+	}
+
+	h.settings = NewSettingsStore(dir)
+	if got := h.timeoutFor(1); got != 15*time.Minute {
+		t.Errorf("timeoutFor = %v", got)
+	}
+	if got := h.maxRoundsFor(1); got != 5 {
+		t.Errorf("maxRoundsFor = %d", got)
+	}
+	if got := h.outputLimitFor(1, defaultAutoOutputLimit); got != 3000 {
+		t.Errorf("outputLimitFor = %d", got)
+	}
+	if got := h.resultLimitFor(1); got != 8000 {
+		t.Errorf("resultLimitFor = %d", got)
+	}
+	if got := h.settings.Get(1).Provider; got != "gemini" {
+		t.Errorf("provider = %q", got)
+	}
+
+	// Other chats use the server defaults.
+	if h.timeoutFor(2) != 5*time.Minute || h.maxRoundsFor(2) != 20 || h.outputLimitFor(2, defaultOutputLimit) != defaultOutputLimit || h.resultLimitFor(2) != 4000 {
+		t.Error("chat without overrides does not use the defaults")
+	}
+
+	s, _ := lookupChatSetting("timeout")
+	h.settings.Update(1, func(cs *ChatSettings) error { s.Clear(cs); return nil })
+	if got := h.timeoutFor(1); got != 5*time.Minute {
+		t.Errorf("timeoutFor after reset = %v", got)
+	}
+	h.settings.Update(1, func(cs *ChatSettings) error { *cs = ChatSettings{}; return nil })
+	if len(h.settings.All()) != 0 {
+		t.Error("reset all left the chat in the store")
+	}
+}
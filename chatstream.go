@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// openAIUsage is the usage block of OpenAI-style chat completions. Cost is
+// only reported by OpenRouter.
+type openAIUsage struct {
+	PromptTokens        int64   `json:"prompt_tokens"`
+	CompletionTokens    int64   `json:"completion_tokens"`
+	TotalTokens         int64   `json:"total_tokens"`
+	Cost                float64 `json:"cost"`
+	PromptTokensDetails struct {
+		CachedTokens int64 `json:"cached_tokens"`
+	} `json:"prompt_tokens_details"`
+}
+
+// apiUsage converts u to the bot's accounting for model.
+func (u openAIUsage) apiUsage(model string) APIUsage {
+	return APIUsage{
+		PromptTokens:     u.PromptTokens,
+		CompletionTokens: u.CompletionTokens,
+		CachedTokens:     u.PromptTokensDetails.CachedTokens,
+		TotalTokens:      u.TotalTokens,
+		CostUSD:          u.Cost,
+		Model:            model,
+	}
+}
+
+// chatStreamChunk is one server-sent event of a streamed completion.
+type chatStreamChunk struct {
+	Model   string `json:"model"`
+	Choices []struct {
+		Delta struct {
+			Content string `json:"content"`
+		} `json:"delta"`
+		FinishReason string `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *openAIUsage `json:"usage"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// chatStream is what a streamed completion added up to.
+type chatStream struct {
+	Text         string
+	Model        string
+	FinishReason string
+	Usage        openAIUsage
+}
+
+// readChatStream reads an OpenAI-style server-sent event stream, calling
+// onDelta with each piece of text as it arrives. If ctx is cancelled
+// mid-stream, the text received so far is returned with ctx's error.
+func readChatStream(ctx context.Context, body io.Reader, onDelta func(string)) (chatStream, error) {
+	var st chatStream
+	var text strings.Builder
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		// Blank lines separate events; ":" lines are keep-alive comments.
+		data, ok := strings.CutPrefix(scanner.Text(), "data:")
+		if !ok {
+			continue
+		}
+		data = strings.TrimSpace(data)
+		if data == "[DONE]" {
+			break
+		}
+		var chunk chatStreamChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			st.Text = text.String()
+			return st, fmt.Errorf("unmarshal stream chunk: %w\nraw: %.300s", err, data)
+		}
+		if chunk.Error != nil {
+			st.Text = text.String()
+			return st, fmt.Errorf("stream error: %s", chunk.Error.Message)
+		}
+		if chunk.Model != "" {
+			st.Model = chunk.Model
+		}
+		if chunk.Usage != nil {
+			st.Usage = *chunk.Usage
+		}
+		for _, c := range chunk.Choices {
+			if c.Delta.Content != "" {
+				text.WriteString(c.Delta.Content)
+				if onDelta != nil {
+					onDelta(c.Delta.Content)
+				}
+			}
+			if c.FinishReason != "" {
+				st.FinishReason = c.FinishReason
+			}
+		}
+	}
+	st.Text = text.String()
+	if err := ctx.Err(); err != nil {
+		return st, err
+	}
+	if err := scanner.Err(); err != nil {
+		return st, fmt.Errorf("read stream: %w", err)
+	}
+	return st, nil
+}
@@ -0,0 +1,291 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxCheckpoints is how many checkpoints are kept per chat; older ones are
+// dropped (their refs deleted) as new ones are taken.
+const maxCheckpoints = 20
+
+// checkpointRefPrefix namespaces checkpoint refs so they survive git gc
+// without showing up as branches or tags.
+const checkpointRefPrefix = "refs/trash/checkpoints/"
+
+var errNotGitRepo = errors.New("not a git repository")
+
+// Checkpoint is a snapshot of a git work tree (tracked and untracked,
+// non-ignored files) taken before an approved command ran.
+type Checkpoint struct {
+	ID      int
+	SHA     string
+	Repo    string // top-level directory of the work tree
+	Command string
+	Time    time.Time
+}
+
+// CheckpointStore is a thread-safe per-chat stack of checkpoints.
+type CheckpointStore struct {
+	mu     sync.RWMutex
+	chats  map[int64][]Checkpoint
+	nextID map[int64]int
+}
+
+func NewCheckpointStore() *CheckpointStore {
+	return &CheckpointStore{
+		chats:  make(map[int64][]Checkpoint),
+		nextID: make(map[int64]int),
+	}
+}
+
+// Push adds a checkpoint and returns any that fell off the end.
+func (s *CheckpointStore) Push(chatID int64, cp Checkpoint) (Checkpoint, []Checkpoint) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nextID[chatID]++
+	cp.ID = s.nextID[chatID]
+	list := append(s.chats[chatID], cp)
+	var dropped []Checkpoint
+	if len(list) > maxCheckpoints {
+		dropped = append(dropped, list[:len(list)-maxCheckpoints]...)
+		list = list[len(list)-maxCheckpoints:]
+	}
+	s.chats[chatID] = list
+	return cp, dropped
+}
+
+// Pop removes and returns the most recent checkpoint.
+func (s *CheckpointStore) Pop(chatID int64) (Checkpoint, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := s.chats[chatID]
+	if len(list) == 0 {
+		return Checkpoint{}, false
+	}
+	cp := list[len(list)-1]
+	s.chats[chatID] = list[:len(list)-1]
+	return cp, true
+}
+
+// List returns the chat's checkpoints, oldest first.
+func (s *CheckpointStore) List(chatID int64) []Checkpoint {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]Checkpoint(nil), s.chats[chatID]...)
+}
+
+// gitOutput runs git in dir with extra env and returns trimmed stdout.
+func gitOutput(ctx context.Context, dir string, env []string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), env...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("git %s: %v: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// gitTopLevel returns the root of the work tree containing dir.
+func gitTopLevel(ctx context.Context, dir string) (string, error) {
+	top, err := gitOutput(ctx, dir, nil, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return "", errNotGitRepo
+	}
+	return top, nil
+}
+
+// snapshotTree writes the current work tree (including untracked,
+// non-ignored files) to a git tree object using a throwaway index, so the
+// user's real index and working files are left untouched.
+func snapshotTree(ctx context.Context, repo string) (string, error) {
+	f, err := os.CreateTemp("", "trash-checkpoint-index-*")
+	if err != nil {
+		return "", err
+	}
+	indexPath := f.Name()
+	f.Close()
+	os.Remove(indexPath) // git refuses to read an empty file as an index
+	defer os.Remove(indexPath)
+
+	env := []string{"GIT_INDEX_FILE=" + indexPath}
+	if _, err := gitOutput(ctx, repo, env, "add", "-A", "--", "."); err != nil {
+		return "", err
+	}
+	return gitOutput(ctx, repo, env, "write-tree")
+}
+
+// createCheckpoint snapshots the work tree containing dir as a commit
+// referenced under refs/trash/checkpoints/. Returns the repo root, the
+// commit SHA and its tree.
+func createCheckpoint(ctx context.Context, dir, label string) (repo, sha, tree string, err error) {
+	repo, err = gitTopLevel(ctx, dir)
+	if err != nil {
+		return "", "", "", err
+	}
+	tree, err = snapshotTree(ctx, repo)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	args := []string{"commit-tree", tree, "-m", "trash checkpoint: " + label}
+	if head, err := gitOutput(ctx, repo, nil, "rev-parse", "--verify", "-q", "HEAD"); err == nil && head != "" {
+		args = append(args, "-p", head)
+	}
+	// Checkpoints must work even when no git identity is configured.
+	env := []string{
+		"GIT_AUTHOR_NAME=trash-bot", "GIT_AUTHOR_EMAIL=trash-bot@localhost",
+		"GIT_COMMITTER_NAME=trash-bot", "GIT_COMMITTER_EMAIL=trash-bot@localhost",
+	}
+	sha, err = gitOutput(ctx, repo, env, args...)
+	if err != nil {
+		return "", "", "", err
+	}
+	if _, err := gitOutput(ctx, repo, nil, "update-ref", checkpointRefPrefix+sha, sha); err != nil {
+		return "", "", "", err
+	}
+	return repo, sha, tree, nil
+}
+
+// deleteCheckpointRef releases a checkpoint so git gc can collect it.
+func deleteCheckpointRef(ctx context.Context, cp Checkpoint) {
+	if _, err := gitOutput(ctx, cp.Repo, nil, "update-ref", "-d", checkpointRefPrefix+cp.SHA); err != nil {
+		slog.Warn("delete checkpoint ref failed", "sha", cp.SHA, "err", err)
+	}
+}
+
+// restoreCheckpoint rolls the work tree back to a checkpoint: files created
+// since are removed and everything else is restored to its snapshot
+// content. The index and HEAD are not modified.
+func restoreCheckpoint(ctx context.Context, cp Checkpoint) error {
+	current, err := snapshotTree(ctx, cp.Repo)
+	if err != nil {
+		return err
+	}
+	added, err := gitOutput(ctx, cp.Repo, nil, "diff", "--name-only", "--no-renames", "--diff-filter=A", "-z", cp.SHA, current)
+	if err != nil {
+		return err
+	}
+	for _, name := range strings.Split(added, "\x00") {
+		if name == "" {
+			continue
+		}
+		if err := os.Remove(filepath.Join(cp.Repo, name)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("remove %s: %w", name, err)
+		}
+	}
+	_, err = gitOutput(ctx, cp.Repo, nil, "restore", "--source="+cp.SHA, "--worktree", "--", ".")
+	return err
+}
+
+// checkpoint snapshots the chat's project before a command runs. The
+// returned func must be called after the command finishes; it keeps the
+// checkpoint only if the command changed files, and returns a diff preview
+// of the change when the command looks like it edits files (empty otherwise).
+// Outside a git repo only the diff preview of the command's target files is taken.
+func (h *Handlers) checkpoint(chatID int64, command string) func() string {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	preview := looksModifying(command)
+	dir := h.projectDir(chatID)
+	repo, sha, tree, err := createCheckpoint(ctx, dir, command)
+	if err != nil {
+		if !errors.Is(err, errNotGitRepo) {
+			slog.Warn("checkpoint failed", "chat_id", chatID, "err", err)
+		}
+		if preview {
+			return snapshotTargets(dir, command)
+		}
+		return func() string { return "" }
+	}
+	cp := Checkpoint{SHA: sha, Repo: repo, Command: command, Time: time.Now()}
+
+	return func() string {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		after, err := snapshotTree(ctx, repo)
+		if err == nil && after == tree {
+			deleteCheckpointRef(ctx, cp)
+			return ""
+		}
+		cp, dropped := h.checkpoints.Push(chatID, cp)
+		for _, old := range dropped {
+			deleteCheckpointRef(ctx, old)
+		}
+		slog.Info("checkpoint taken", "chat_id", chatID, "checkpoint", cp.ID, "sha", shortSHA(cp.SHA), "command", command)
+		if !preview || err != nil {
+			return ""
+		}
+		diff, err := gitOutput(ctx, repo, nil, "diff", "--no-color", tree, after)
+		if err != nil {
+			slog.Warn("diff preview failed", "chat_id", chatID, "err", err)
+			return ""
+		}
+		return formatDiffPreview(diff)
+	}
+}
+
+func shortSHA(sha string) string {
+	if len(sha) > 8 {
+		return sha[:8]
+	}
+	return sha
+}
+
+// HandleUndo rolls the project back to the most recent checkpoint. It
+// changes files, so read-only chats cannot.
+func (h *Handlers) HandleUndo(chatID int64) {
+	if h.readOnly.Enabled(chatID) {
+		h.sender.SendPlain(chatID, h.t(chatID, "readonly.disabled", "undo"))
+		return
+	}
+	unlock := h.locks.Lock(chatID)
+	defer unlock()
+
+	cp, ok := h.checkpoints.Pop(chatID)
+	if !ok {
+		h.sender.SendPlain(chatID, h.t(chatID, "checkpoint.none_undo"))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+	if err := restoreCheckpoint(ctx, cp); err != nil {
+		slog.Error("undo failed", "chat_id", chatID, "checkpoint", cp.ID, "err", err)
+		h.sender.SendPlain(chatID, h.t(chatID, "checkpoint.restore_failed", cp.ID, err))
+		return
+	}
+	deleteCheckpointRef(ctx, cp)
+	slog.Info("restored checkpoint", "chat_id", chatID, "checkpoint", cp.ID, "sha", shortSHA(cp.SHA))
+	h.sender.SendPlain(chatID, h.t(chatID, "checkpoint.restored", cp.ID, cp.Repo, cp.Command))
+}
+
+// HandleCheckpoints lists the chat's checkpoints, newest first.
+func (h *Handlers) HandleCheckpoints(chatID int64) {
+	list := h.checkpoints.List(chatID)
+	if len(list) == 0 {
+		h.sender.SendPlain(chatID, h.t(chatID, "checkpoint.none"))
+		return
+	}
+	var b strings.Builder
+	b.WriteString(h.t(chatID, "checkpoint.title"))
+	for i := len(list) - 1; i >= 0; i-- {
+		cp := list[i]
+		fmt.Fprintf(&b, "#%d %s %s — %s\n", cp.ID, cp.Time.Format("15:04:05"), shortSHA(cp.SHA), truncateText(cp.Command, 80))
+	}
+	b.WriteString(h.t(chatID, "checkpoint.footer"))
+	h.sender.SendPlain(chatID, b.String())
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckpointRestore(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	ctx := context.Background()
+	dir := t.TempDir()
+	if out, err := exec.Command("git", "init", "-q", dir).CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v\n%s", err, out)
+	}
+	write := func(name, content string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("keep.txt", "original")
+	write("delete-me.txt", "still here")
+
+	repo, sha, _, err := createCheckpoint(ctx, dir, "test")
+	if err != nil {
+		t.Fatalf("createCheckpoint: %v", err)
+	}
+
+	write("keep.txt", "modified")
+	write("new.txt", "created later")
+	os.Remove(filepath.Join(dir, "delete-me.txt"))
+
+	if err := restoreCheckpoint(ctx, Checkpoint{SHA: sha, Repo: repo}); err != nil {
+		t.Fatalf("restoreCheckpoint: %v", err)
+	}
+
+	if b, _ := os.ReadFile(filepath.Join(dir, "keep.txt")); string(b) != "original" {
+		t.Errorf("keep.txt = %q, want %q", b, "original")
+	}
+	if b, _ := os.ReadFile(filepath.Join(dir, "delete-me.txt")); string(b) != "still here" {
+		t.Errorf("delete-me.txt = %q, want restored", b)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "new.txt")); !os.IsNotExist(err) {
+		t.Errorf("new.txt should have been removed, stat err = %v", err)
+	}
+}
+
+func TestCheckpointNotGitRepo(t *testing.T) {
+	if _, _, _, err := createCheckpoint(context.Background(), t.TempDir(), "x"); err != errNotGitRepo {
+		t.Errorf("err = %v, want errNotGitRepo", err)
+	}
+}
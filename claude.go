@@ -7,15 +7,13 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"os"
 	"os/exec"
 	"regexp"
 	"strings"
 	"sync"
 	"time"
-
-	"github.com/creack/pty"
 )
 
 // commandTagRe matches <command>...</command> blocks, including multiline.
@@ -109,12 +107,17 @@ var allTools = []string{
 
 // ClaudeClient executes the claude CLI.
 type ClaudeClient struct {
-	claudePath      string
-	workDir         string
-	systemPrompt    string
-	allowedTools    []string
-	skipPermissions bool
-	safeguard       *Safeguard
+	claudePath   string
+	model        string // default --model; empty uses the CLI's default
+	workDir      string
+	systemPrompt string
+	allowedTools []string
+	autorun      *AutorunStore  // per-chat SKIP_PERMISSIONS (/autorun)
+	readOnly     *ReadOnlyStore // read-only chats get no tools, set by SetReadOnly
+	safeguard    *Safeguard
+
+	mu     sync.RWMutex
+	models map[int64]string // per-chat --model chosen with /cmodel
 }
 
 func NewClaudeClient(cfg *Config) *ClaudeClient {
@@ -125,61 +128,158 @@ func NewClaudeClient(cfg *Config) *ClaudeClient {
 	// Always append safeguard rules to the system prompt so Claude
 	// refuses dangerous commands even when it executes them internally.
 	prompt += safeguardPrompt
-	log.Printf("[claude] path=%s workDir=%s skipPerms=%v allowedTools=%v",
-		cfg.ClaudePath, cfg.WorkDir, cfg.SkipPermissions, cfg.AllowedTools)
+	slog.Info("claude client configured", "path", cfg.ClaudePath, "work_dir", cfg.WorkDir,
+		"skip_perms", cfg.SkipPermissions, "allowed_tools", cfg.AllowedTools)
 	return &ClaudeClient{
-		claudePath:      cfg.ClaudePath,
-		workDir:         cfg.WorkDir,
-		systemPrompt:    prompt,
-		allowedTools:    cfg.AllowedTools,
-		skipPermissions: cfg.SkipPermissions,
-		safeguard:       NewSafeguard(),
+		claudePath:   cfg.ClaudePath,
+		model:        cfg.ClaudeModel,
+		workDir:      cfg.WorkDir,
+		systemPrompt: prompt,
+		allowedTools: cfg.AllowedTools,
+		autorun:      NewAutorunStore(cfg.DataDir, cfg.SkipPermissions),
+		safeguard:    NewSafeguard(),
+		models:       make(map[int64]string),
+	}
+}
+
+// SetModel sets the model of the chat's subsequent calls; "" restores the
+// default.
+func (c *ClaudeClient) SetModel(chatID int64, model string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if model == "" {
+		delete(c.models, chatID)
+		return
+	}
+	c.models[chatID] = model
+}
+
+// Model returns the chat's model, or "" when the CLI picks its default.
+func (c *ClaudeClient) Model(chatID int64) string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if m, ok := c.models[chatID]; ok {
+		return m
 	}
+	return c.model
 }
 
 // Send sends a message to Claude CLI. For new sessions (empty sessionID),
 // the command instruction is prepended. chatID is injected as the CHAT_ID
 // environment variable so Claude can send messages back to the user via curl.
-func (c *ClaudeClient) Send(ctx context.Context, chatID int64, sessionID, message string) (*ClaudeResponse, error) {
-	args := []string{"-p", "--output-format", "json"}
+// dir is the chat's active project directory; empty means the configured WORK_DIR.
+func (c *ClaudeClient) Send(ctx context.Context, chatID int64, dir, sessionID, message string) (*ClaudeResponse, error) {
+	return c.send(ctx, chatID, dir, sessionID, message, nil, nil)
+}
+
+// Stream is Send with the reply streamed (--output-format stream-json):
+// onDelta is called with each piece of text as Claude writes it, and onTool
+// with a one-line description of each tool Claude uses ("Read main.go").
+// Either may be nil. If ctx is cancelled mid-turn, the response holds the
+// text and session ID received so far and is returned with ctx's error.
+func (c *ClaudeClient) Stream(ctx context.Context, chatID int64, dir, sessionID, message string, onDelta, onTool func(string)) (*ClaudeResponse, error) {
+	return c.send(ctx, chatID, dir, sessionID, message, onDelta, onTool)
+}
+
+// forkKey marks a call that must not add to the session it resumes.
+type forkKey struct{}
+
+// withForkSession makes Claude resume the session into a new copy, leaving
+// the original as it was.
+func withForkSession(ctx context.Context) context.Context {
+	return context.WithValue(ctx, forkKey{}, true)
+}
+
+// forkSession reports whether ctx asks for a forked session.
+func forkSession(ctx context.Context) bool {
+	fork, _ := ctx.Value(forkKey{}).(bool)
+	return fork
+}
+
+// SetReadOnly withholds all tools from read-only chats.
+func (c *ClaudeClient) SetReadOnly(r *ReadOnlyStore) {
+	c.readOnly = r
+}
+
+// HasTools reports whether Claude runs tools itself in the chat
+// (ALLOWED_TOOLS, or auto-execute on) instead of proposing <command> tags.
+func (c *ClaudeClient) HasTools(chatID int64) bool {
+	if c.readOnly.Enabled(chatID) {
+		return false
+	}
+	return c.autorun.Enabled(chatID) || len(c.allowedTools) > 0
+}
+
+func (c *ClaudeClient) send(ctx context.Context, chatID int64, dir, sessionID, message string, onDelta, onTool func(string)) (*ClaudeResponse, error) {
+	if dir == "" {
+		dir = c.workDir
+	}
+	streaming := onDelta != nil || onTool != nil
+	args := []string{"-p", "--output-format", "json", "--add-dir", dir}
+	if streaming {
+		// stream-json requires --verbose in print mode; partial messages
+		// add token-level deltas to the per-turn assistant events.
+		args = []string{"-p", "--output-format", "stream-json", "--verbose", "--include-partial-messages", "--add-dir", dir}
+	}
 
 	// Pass allowed tools.
-	if c.skipPermissions {
-		for _, tool := range allTools {
+	if !c.readOnly.Enabled(chatID) {
+		if c.autorun.Enabled(chatID) {
+			for _, tool := range allTools {
+				args = append(args, "--allowedTools", tool)
+			}
+		}
+		for _, tool := range c.allowedTools {
 			args = append(args, "--allowedTools", tool)
 		}
 	}
-	for _, tool := range c.allowedTools {
-		args = append(args, "--allowedTools", tool)
+
+	if model := modelFrom(ctx, c.Model(chatID)); model != "" {
+		args = append(args, "--model", model)
 	}
 
 	if sessionID != "" {
 		args = append(args, "--resume", sessionID)
+		if forkSession(ctx) {
+			args = append(args, "--fork-session")
+		}
 	} else {
 		// New session: pass system prompt and (in non-tool mode) prepend
 		// command instruction so Claude uses <command> tags.
-		args = append(args, "--system-prompt", c.systemPrompt)
+		args = append(args, "--system-prompt", systemPromptFrom(ctx, c.systemPrompt))
 	}
 
 	input := message
-	hasTools := c.skipPermissions || len(c.allowedTools) > 0
+	hasTools := c.HasTools(chatID)
 	if sessionID == "" && !hasTools {
 		input = commandInstruction + message
 	}
 
-	log.Printf("[claude] exec: %s %s", c.claudePath, strings.Join(args, " "))
+	slog.Debug("claude exec", "path", c.claudePath, "args", strings.Join(args, " "))
 	if sessionID != "" {
-		log.Printf("[claude] resuming session %s", sessionID)
+		slog.Debug("claude resuming session", "session", sessionID)
 	} else {
-		log.Printf("[claude] new session (hasTools=%v)", hasTools)
+		slog.Debug("claude new session", "has_tools", hasTools)
 	}
-	log.Printf("[claude] input (%d bytes): %.200s", len(input), input)
+	slog.Debug("claude input", "bytes", len(input), "input", truncateText(input, 200))
 
 	cmd := exec.CommandContext(ctx, c.claudePath, args...)
-	cmd.Dir = c.workDir
+	ownGroup(cmd)
+	cmd.Dir = dir
 	cmd.Env = append(os.Environ(), fmt.Sprintf("CHAT_ID=%d", chatID))
+	if n := maxTokensFrom(ctx); n > 0 {
+		cmd.Env = append(cmd.Env, fmt.Sprintf("CLAUDE_CODE_MAX_OUTPUT_TOKENS=%d", n))
+	}
 	cmd.Stdin = strings.NewReader(input)
 
+	if streaming {
+		var toolFn func(name string, input json.RawMessage)
+		if onTool != nil {
+			toolFn = func(name string, input json.RawMessage) { onTool(describeToolUse(name, input, dir)) }
+		}
+		return c.runStream(ctx, cmd, onDelta, toolFn)
+	}
+
 	var stdout, stderr bytes.Buffer
 	cmd.Stdout = &stdout
 	cmd.Stderr = &stderr
@@ -188,107 +288,250 @@ func (c *ClaudeClient) Send(ctx context.Context, chatID int64, sessionID, messag
 	if err := cmd.Run(); err != nil {
 		elapsed := time.Since(start)
 		if ctx.Err() == context.DeadlineExceeded {
-			log.Printf("[claude] timed out after %v", elapsed)
+			slog.Warn("claude timed out", "duration", elapsed)
 			return nil, fmt.Errorf("claude timed out")
 		}
-		log.Printf("[claude] exited with error after %v: %v", elapsed, err)
+		slog.Warn("claude exited with error", "duration", elapsed, "err", err)
 		if stderr.Len() > 0 {
-			log.Printf("[claude] stderr: %s", stderr.String())
+			slog.Debug("claude stderr", "stderr", stderr.String())
 		}
 		if stdout.Len() == 0 {
 			return nil, fmt.Errorf("claude failed: %v\nstderr: %s", err, stderr.String())
 		}
 	}
 	elapsed := time.Since(start)
-	log.Printf("[claude] finished in %v, stdout=%d bytes, stderr=%d bytes", elapsed, stdout.Len(), stderr.Len())
+	slog.Info("claude finished", "duration", elapsed, "stdout_bytes", stdout.Len(), "stderr_bytes", stderr.Len())
 	if stderr.Len() > 0 {
-		log.Printf("[claude] stderr: %s", stderr.String())
+		slog.Debug("claude stderr", "stderr", stderr.String())
 	}
 
 	var resp ClaudeResponse
 	if err := json.Unmarshal(stdout.Bytes(), &resp); err != nil {
-		log.Printf("[claude] failed to parse JSON: %v", err)
-		log.Printf("[claude] raw stdout: %.500s", stdout.String())
+		slog.Error("claude: failed to parse JSON", "err", err)
+		slog.Debug("claude raw stdout", "stdout", truncateText(stdout.String(), 500))
 		return nil, fmt.Errorf("failed to parse claude response: %v\nraw: %s", err, stdout.String())
 	}
+	return checkResponse(&resp)
+}
+
+// runStream runs cmd with stream-json output, passing reply text and tool
+// uses to onDelta and onTool as they arrive.
+func (c *ClaudeClient) runStream(ctx context.Context, cmd *exec.Cmd, onDelta func(string), onTool func(string, json.RawMessage)) (*ClaudeResponse, error) {
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("claude stdout: %w", err)
+	}
+
+	start := time.Now()
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("claude failed: %v", err)
+	}
+	resp, done := readClaudeStream(stdout, onDelta, onTool)
+	waitErr := cmd.Wait()
+	elapsed := time.Since(start)
+	if stderr.Len() > 0 {
+		slog.Debug("claude stderr", "stderr", stderr.String())
+	}
+	if !done {
+		switch ctx.Err() {
+		case context.Canceled:
+			slog.Info("claude stream cancelled", "duration", elapsed, "result_bytes", len(resp.Result))
+			return resp, ctx.Err()
+		case context.DeadlineExceeded:
+			slog.Warn("claude timed out", "duration", elapsed)
+			return nil, fmt.Errorf("claude timed out")
+		}
+		slog.Warn("claude stream ended without a result", "duration", elapsed, "err", waitErr)
+		return nil, fmt.Errorf("claude failed: %v\nstderr: %s", waitErr, stderr.String())
+	}
+	slog.Info("claude finished", "duration", elapsed, "stream", true, "stderr_bytes", stderr.Len())
+	return checkResponse(resp)
+}
+
+// claudeStreamEvent is one line of claude's stream-json output. Only the
+// fields carrying reply text and the session ID are decoded; the final
+// "result" line is decoded as a ClaudeResponse.
+type claudeStreamEvent struct {
+	Type      string `json:"type"`
+	SessionID string `json:"session_id"`
+	Event     struct {
+		Type  string `json:"type"`
+		Delta struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		} `json:"delta"`
+	} `json:"event"`
+	Message struct {
+		Content []struct {
+			Type  string          `json:"type"`
+			Text  string          `json:"text"`
+			Name  string          `json:"name"`  // tool_use
+			Input json.RawMessage `json:"input"` // tool_use
+		} `json:"content"`
+	} `json:"message"`
+}
+
+// readClaudeStream reads stream-json lines from r until EOF, calling onDelta
+// with the reply text and onTool with each tool use (either may be nil).
+// Text is taken from token-level deltas when the CLI sends them, otherwise
+// from each complete assistant message; the messages of a multi-turn
+// (tool-using) reply are separated by a blank line. done reports whether
+// the result line was seen; if not, resp holds the text and session ID
+// received so far.
+func readClaudeStream(r io.Reader, onDelta func(string), onTool func(name string, input json.RawMessage)) (resp *ClaudeResponse, done bool) {
+	resp = &ClaudeResponse{}
+	var text strings.Builder
+	var deltas, newMessage bool
+	emit := func(s string) {
+		if s == "" {
+			return
+		}
+		if newMessage && text.Len() > 0 {
+			s = "\n\n" + s
+		}
+		newMessage = false
+		text.WriteString(s)
+		if onDelta != nil {
+			onDelta(s)
+		}
+	}
 
-	log.Printf("[claude] response: type=%s session=%s isError=%v resultLen=%d",
-		resp.Type, resp.SessionID, resp.IsError, len(resp.Result))
-	log.Printf("[claude] cost=$%.4f tokens(in=%d out=%d cacheRead=%d cacheCreate=%d) turns=%d duration=%dms",
-		resp.CostUSD, resp.Usage.InputTokens, resp.Usage.OutputTokens,
-		resp.Usage.CacheReadInputTokens, resp.Usage.CacheCreationInputTokens,
-		resp.NumTurns, resp.DurationMs)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16<<20) // tool results can be large
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var ev claudeStreamEvent
+		if err := json.Unmarshal(line, &ev); err != nil {
+			slog.Debug("claude: skipping stream line", "err", err, "line", truncateText(string(line), 200))
+			continue
+		}
+		if ev.SessionID != "" {
+			resp.SessionID = ev.SessionID
+		}
+		switch ev.Type {
+		case "stream_event":
+			switch ev.Event.Type {
+			case "message_start":
+				newMessage = true
+			case "content_block_delta":
+				if ev.Event.Delta.Type == "text_delta" {
+					deltas = true
+					emit(ev.Event.Delta.Text)
+				}
+			}
+		case "assistant":
+			// Text already streamed token by token is not repeated.
+			if !deltas {
+				newMessage = true
+			}
+			for _, block := range ev.Message.Content {
+				switch {
+				case block.Type == "tool_use" && onTool != nil:
+					onTool(block.Name, block.Input)
+				case block.Type == "text" && !deltas:
+					emit(block.Text)
+				}
+			}
+		case "result":
+			var final ClaudeResponse
+			if err := json.Unmarshal(line, &final); err != nil {
+				slog.Error("claude: failed to parse result", "err", err)
+				continue
+			}
+			resp, done = &final, true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		slog.Warn("claude: stream read error", "err", err)
+		io.Copy(io.Discard, r) // let the process exit
+	}
+	if !done {
+		resp.Result = text.String()
+	}
+	return resp, done
+}
+
+// checkResponse logs a parsed response and turns an error result into an
+// error.
+func checkResponse(resp *ClaudeResponse) (*ClaudeResponse, error) {
+	slog.Info("claude response", "type", resp.Type, "session", resp.SessionID, "is_error", resp.IsError,
+		"result_bytes", len(resp.Result), "cost_usd", resp.CostUSD,
+		"input_tokens", resp.Usage.InputTokens, "output_tokens", resp.Usage.OutputTokens,
+		"cache_read_tokens", resp.Usage.CacheReadInputTokens, "cache_create_tokens", resp.Usage.CacheCreationInputTokens,
+		"turns", resp.NumTurns, "duration", time.Duration(resp.DurationMs)*time.Millisecond)
 	// Log first 300 chars of result for debugging.
 	if len(resp.Result) > 0 {
 		preview := resp.Result
 		if len(preview) > 300 {
 			preview = preview[:300] + "..."
 		}
-		log.Printf("[claude] result preview: %s", preview)
+		slog.Debug("claude result preview", "preview", preview)
 	}
 
 	if resp.IsError {
-		log.Printf("[claude] error response: %s", resp.Result)
-		return &resp, fmt.Errorf("claude error: %s", resp.Result)
+		slog.Error("claude error response", "result", resp.Result)
+		return resp, fmt.Errorf("claude error: %s", resp.Result)
 	}
 
-	return &resp, nil
+	return resp, nil
 }
 
 // ExecuteCommand runs a shell command and returns combined stdout+stderr.
 // Commands are checked against safeguard rules before execution.
-func (c *ClaudeClient) ExecuteCommand(ctx context.Context, command string) (string, error) {
+// dir is the chat's active project directory; empty means the configured WORK_DIR.
+func (c *ClaudeClient) ExecuteCommand(ctx context.Context, dir, command string) (string, error) {
+	if dir == "" {
+		dir = c.workDir
+	}
 	if verdict, reason := c.safeguard.Check(command); verdict == CommandBlocked {
-		log.Printf("[exec] BLOCKED: %s — %s", command, reason)
-		return "", fmt.Errorf("command blocked: %s", reason)
+		slog.Warn("exec blocked", "provider", "claude", "command", command, "reason", reason)
+		return "", fmt.Errorf("%w: %s", ErrCommandBlocked, reason)
 	}
 
-	log.Printf("[exec] running: %s", command)
+	slog.Info("exec running", "provider", "claude", "command", command)
 	cmd := exec.CommandContext(ctx, "sh", "-c", command)
-	cmd.Dir = c.workDir
+	ownGroup(cmd)
+	cmd.Dir = dir
 
-	var out bytes.Buffer
-	cmd.Stdout = &out
-	cmd.Stderr = &out
+	out := newBoundedOutput(outputHeadBytes, outputTailBytes)
+	cmd.Stdout = out
+	cmd.Stderr = out
 
 	start := time.Now()
 	err := cmd.Run()
 	elapsed := time.Since(start)
 	output := out.String()
-
-	const maxOutput = 10000
-	if len(output) > maxOutput {
-		log.Printf("[exec] output truncated from %d to %d bytes", len(output), maxOutput)
-		output = output[:maxOutput] + "\n... (output truncated)"
+	if out.Truncated() {
+		slog.Debug("exec output truncated", "provider", "claude", "bytes", out.Total(), "kept_bytes", len(output))
 	}
 
 	if err != nil {
 		if ctx.Err() == context.DeadlineExceeded {
-			log.Printf("[exec] timed out after %v", elapsed)
+			slog.Warn("exec timed out", "provider", "claude", "duration", elapsed)
 			return output, fmt.Errorf("command timed out")
 		}
-		log.Printf("[exec] failed after %v: %v (output=%d bytes)", elapsed, err, len(output))
+		slog.Warn("exec failed", "provider", "claude", "duration", elapsed, "err", err, "output_bytes", len(output))
 		return output, fmt.Errorf("exit status: %v", err)
 	}
-	log.Printf("[exec] success in %v, output=%d bytes", elapsed, len(output))
+	slog.Info("exec succeeded", "provider", "claude", "duration", elapsed, "output_bytes", len(output))
 	return output, nil
 }
 
 // ParseCommands extracts <command>...</command> blocks from Claude's response.
 // Returns the cleaned text (tags replaced with inline code) and the list of commands.
 func ParseCommands(text string) (cleanText string, commands []string) {
-	matches := commandTagRe.FindAllStringSubmatch(text, -1)
-	for _, m := range matches {
-		cmd := strings.TrimSpace(m[1])
+	// Replace <command> tags with inline code for display.
+	cleanText = replaceSubmatches(commandTagRe, text, func(sub []string) string {
+		cmd := strings.TrimSpace(sub[1])
 		if cmd != "" {
 			commands = append(commands, cmd)
 		}
-	}
-
-	// Replace <command> tags with inline code for display.
-	cleanText = commandTagRe.ReplaceAllStringFunc(text, func(match string) string {
-		sub := commandTagRe.FindStringSubmatch(match)
-		return "`" + strings.TrimSpace(sub[1]) + "`"
+		return "`" + cmd + "`"
 	})
 	cleanText = strings.TrimSpace(cleanText)
 	return
@@ -313,221 +556,6 @@ func IsNotLoggedIn(err error) bool {
 	return strings.Contains(msg, "Not logged in") || strings.Contains(msg, "not logged in")
 }
 
-// SetupToken starts `claude login` inside a PTY so that Ink (the TUI
-// framework) gets the TTY it requires. It captures the OAuth URL from output
-// and returns the URL plus a feedCode function. Call feedCode with the auth
-// code the user receives after completing OAuth in their browser.
-// `claude login` stores credentials in ~/.claude/ config so subsequent
-// `claude -p` calls are automatically authenticated.
-func (c *ClaudeClient) SetupToken(ctx context.Context) (string, func(code string) error, error) {
-	log.Printf("[login] starting claude login (with PTY)")
-	cmd := exec.CommandContext(ctx, c.claudePath, "login")
-	cmd.Dir = c.workDir
-	// Prevent browser launch in container.
-	cmd.Env = append(os.Environ(), "BROWSER=", "DISPLAY=")
-
-	// Allocate a PTY — wide columns prevent URL line-wrapping.
-	ptmx, err := pty.StartWithSize(cmd, &pty.Winsize{Rows: 24, Cols: 500})
-	if err != nil {
-		return "", nil, fmt.Errorf("start setup-token with pty: %w", err)
-	}
-
-	// drainPTY reads raw bytes from ptmx so the subprocess never blocks on
-	// write. Uses raw Read instead of bufio.Scanner because Ink's raw-mode
-	// output has no newlines — a line-based scanner would buffer until 64 KB
-	// and then error, causing a deadlock.
-	drainDone := make(chan struct{})
-	drainPTY := func() {
-		defer close(drainDone)
-		buf := make([]byte, 4096)
-		for {
-			n, err := ptmx.Read(buf)
-			if n > 0 {
-				log.Printf("[login] output: %s", stripANSI(string(buf[:n])))
-			}
-			if err != nil {
-				return
-			}
-		}
-	}
-
-	// Auto-advance through the first-time onboarding wizard (theme selection,
-	// etc.) by pressing Enter every 2 seconds until we find the OAuth URL.
-	urlFound := make(chan struct{})
-	go func() {
-		ticker := time.NewTicker(2 * time.Second)
-		defer ticker.Stop()
-		for {
-			select {
-			case <-urlFound:
-				return
-			case <-ctx.Done():
-				return
-			case <-ticker.C:
-				ptmx.Write([]byte("\r"))
-				log.Printf("[login] auto-advancing wizard (sent Enter)")
-			}
-		}
-	}()
-
-	// Read PTY output looking for URL with a timeout.
-	// The URL may wrap across multiple lines if it exceeds the terminal width,
-	// so we accumulate continuation lines (non-empty, no spaces) after the
-	// initial https:// match.
-	urlCh := make(chan string, 1)
-	go func() {
-		scanner := bufio.NewScanner(ptmx)
-		scanner.Buffer(make([]byte, 0, 1<<20), 1<<20) // 1 MB max to avoid ErrTooLong
-		var urlAccum string
-		for scanner.Scan() {
-			raw := scanner.Text()
-			line := stripANSI(raw)
-			trimmed := strings.TrimSpace(line)
-			log.Printf("[login] output: %s", line)
-
-			if urlAccum != "" {
-				// Accumulating URL that wrapped across lines.
-				if trimmed != "" && !strings.ContainsAny(trimmed, " \t") {
-					urlAccum += trimmed
-					continue
-				}
-				// Hit empty/non-URL line — URL is complete.
-				close(urlFound)
-				urlCh <- urlAccum
-				go drainPTY()
-				return
-			}
-
-			if u := loginURLRe.FindString(trimmed); u != "" {
-				// If URL reaches end of line it may continue on the next line.
-				if strings.HasSuffix(trimmed, u[len(u)-1:]) && strings.Index(trimmed, u)+len(u) >= len(trimmed) {
-					urlAccum = u
-					continue
-				}
-				close(urlFound)
-				urlCh <- u
-				go drainPTY()
-				return
-			}
-		}
-		close(urlFound)
-		if urlAccum != "" {
-			urlCh <- urlAccum
-			return
-		}
-		if err := scanner.Err(); err != nil && err != io.EOF {
-			log.Printf("[login] scanner error: %v", err)
-		}
-		urlCh <- ""
-	}()
-
-	select {
-	case loginURL := <-urlCh:
-		if loginURL == "" {
-			ptmx.Close()
-			cmd.Process.Kill()
-			cmd.Wait()
-			return "", nil, fmt.Errorf("no login URL found in output")
-		}
-		log.Printf("[login] got URL: %s", loginURL)
-
-		feedCode := func(code string) error {
-			log.Printf("[login] feeding auth code (%d chars)", len(code))
-			// Write code one character at a time with small delays to
-			// simulate real keystrokes. Ink's raw-mode input handler may
-			// not correctly process a bulk write of all characters at once.
-			for i, ch := range code {
-				if _, err := ptmx.Write([]byte(string(ch))); err != nil {
-					log.Printf("[login] failed to write char %d to pty: %v", i, err)
-					ptmx.Close()
-					cmd.Process.Kill()
-					cmd.Wait()
-					return fmt.Errorf("failed to send code: %w", err)
-				}
-				time.Sleep(5 * time.Millisecond)
-			}
-			// Pause before Enter so Ink finishes processing the input.
-			time.Sleep(200 * time.Millisecond)
-			log.Printf("[login] sending Enter")
-			if _, err := ptmx.Write([]byte("\r")); err != nil {
-				log.Printf("[login] failed to write Enter to pty: %v", err)
-				ptmx.Close()
-				cmd.Process.Kill()
-				cmd.Wait()
-				return fmt.Errorf("failed to send Enter: %w", err)
-			}
-
-			// Wait for process with timeout — the subprocess may hang if the
-			// token exchange fails or Ink gets stuck.
-			done := make(chan error, 1)
-			go func() { done <- cmd.Wait() }()
-
-			// Auto-advance any post-auth prompts (org selection, confirmation, etc.)
-			// by pressing Enter every 2 seconds until the process exits.
-			stopAdvance := make(chan struct{})
-			go func() {
-				ticker := time.NewTicker(2 * time.Second)
-				defer ticker.Stop()
-				for {
-					select {
-					case <-stopAdvance:
-						return
-					case <-ctx.Done():
-						return
-					case <-ticker.C:
-						ptmx.Write([]byte("\r"))
-						log.Printf("[login] auto-advancing post-auth prompt (sent Enter)")
-					}
-				}
-			}()
-
-			select {
-			case err := <-done:
-				close(stopAdvance)
-				ptmx.Close()
-				// Wait for drain goroutine to finish capturing all output.
-				<-drainDone
-				if err != nil {
-					log.Printf("[login] login exited with: %v", err)
-					return fmt.Errorf("login failed: %w", err)
-				}
-				log.Printf("[login] login completed successfully")
-				return nil
-			case <-time.After(30 * time.Second):
-				close(stopAdvance)
-				log.Printf("[login] process didn't exit in 30s, killing and verifying...")
-				ptmx.Close()
-				cmd.Process.Kill()
-				<-done
-
-				// The TUI often hangs on post-auth screens even after creds
-				// are saved. Verify login by attempting a quick Claude call.
-				verifyCtx, verifyCancel := context.WithTimeout(context.Background(), 15*time.Second)
-				_, verifyErr := c.Send(verifyCtx, 0, "", "hi")
-				verifyCancel()
-				if verifyErr != nil && IsNotLoggedIn(verifyErr) {
-					return fmt.Errorf("login timed out (auth may have failed)")
-				}
-				log.Printf("[login] login verified despite process timeout")
-				return nil
-			}
-		}
-		return loginURL, feedCode, nil
-
-	case <-time.After(30 * time.Second):
-		ptmx.Close()
-		cmd.Process.Kill()
-		cmd.Wait()
-		return "", nil, fmt.Errorf("timeout waiting for login URL")
-
-	case <-ctx.Done():
-		ptmx.Close()
-		cmd.Process.Kill()
-		cmd.Wait()
-		return "", nil, ctx.Err()
-	}
-}
-
 // FormatCommandResults formats the results of approved/denied commands
 // to send back to Claude for context.
 func FormatCommandResults(results []CommandResult) string {
@@ -1,8 +1,10 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -78,3 +80,83 @@ func TestIsNotLoggedIn(t *testing.T) {
 		})
 	}
 }
+
+func TestReadClaudeStream(t *testing.T) {
+	t.Run("partial messages", func(t *testing.T) {
+		out := strings.Join([]string{
+			`{"type":"system","subtype":"init","session_id":"s1"}`,
+			`{"type":"stream_event","event":{"type":"message_start"},"session_id":"s1"}`,
+			`{"type":"stream_event","event":{"type":"content_block_delta","delta":{"type":"text_delta","text":"Let me "}},"session_id":"s1"}`,
+			`{"type":"stream_event","event":{"type":"content_block_delta","delta":{"type":"text_delta","text":"check."}},"session_id":"s1"}`,
+			`{"type":"stream_event","event":{"type":"content_block_delta","delta":{"type":"input_json_delta","partial_json":"{}"}},"session_id":"s1"}`,
+			`{"type":"assistant","message":{"content":[{"type":"text","text":"Let me check."}]},"session_id":"s1"}`,
+			`{"type":"stream_event","event":{"type":"message_start"},"session_id":"s1"}`,
+			`{"type":"stream_event","event":{"type":"content_block_delta","delta":{"type":"text_delta","text":"Done."}},"session_id":"s1"}`,
+			`{"type":"result","subtype":"success","is_error":false,"result":"Done.","session_id":"s1","total_cost_usd":0.01,"num_turns":2}`,
+		}, "\n")
+		var got strings.Builder
+		resp, done := readClaudeStream(strings.NewReader(out), func(s string) { got.WriteString(s) }, nil)
+		if !done {
+			t.Fatal("result line not seen")
+		}
+		if got.String() != "Let me check.\n\nDone." {
+			t.Errorf("streamed %q", got.String())
+		}
+		if resp.Result != "Done." || resp.SessionID != "s1" || resp.NumTurns != 2 {
+			t.Errorf("resp = %+v", resp)
+		}
+	})
+
+	t.Run("whole messages", func(t *testing.T) {
+		out := `{"type":"assistant","message":{"content":[{"type":"text","text":"One"},{"type":"tool_use","name":"Bash"}]},"session_id":"s2"}
+{"type":"user","message":{"content":[{"type":"tool_result","content":"ok"}]}}
+{"type":"assistant","message":{"content":[{"type":"text","text":"Two"}]},"session_id":"s2"}
+`
+		var got strings.Builder
+		var tools []string
+		resp, done := readClaudeStream(strings.NewReader(out), func(s string) { got.WriteString(s) },
+			func(name string, _ json.RawMessage) { tools = append(tools, name) })
+		if done {
+			t.Error("done without a result line")
+		}
+		if got.String() != "One\n\nTwo" {
+			t.Errorf("streamed %q", got.String())
+		}
+		if resp.Result != "One\n\nTwo" || resp.SessionID != "s2" {
+			t.Errorf("partial resp = %+v", resp)
+		}
+		if len(tools) != 1 || tools[0] != "Bash" {
+			t.Errorf("tools = %v", tools)
+		}
+	})
+}
+
+func TestClaudeModel(t *testing.T) {
+	c := NewClaudeClient(&Config{ClaudePath: "claude", ClaudeModel: "sonnet"})
+	if got := c.Model(1); got != "sonnet" {
+		t.Errorf("default model = %q", got)
+	}
+	c.SetModel(1, "haiku")
+	if got := c.Model(1); got != "haiku" {
+		t.Errorf("chat model = %q", got)
+	}
+	if got := c.Model(2); got != "sonnet" {
+		t.Errorf("other chat model = %q", got)
+	}
+	c.SetModel(1, "")
+	if got := c.Model(1); got != "sonnet" {
+		t.Errorf("model after reset = %q", got)
+	}
+}
+
+func BenchmarkParseCommands(b *testing.B) {
+	var sb strings.Builder
+	for sb.Len() < 100<<10 {
+		sb.WriteString("Checking the service status first:\n<command>systemctl status nginx --no-pager</command>\nThen the recent logs, which should show why it restarted.\n")
+	}
+	text := sb.String()
+	b.SetBytes(int64(len(text)))
+	for i := 0; i < b.N; i++ {
+		ParseCommands(text)
+	}
+}
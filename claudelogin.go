@@ -0,0 +1,519 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/creack/pty"
+)
+
+// Claude's OAuth login runs `claude login` in a PTY, because Ink (the TUI
+// framework) requires a TTY, and moves through explicit states, each with
+// its own timeout:
+//
+//	waiting-for-url   Enter is pressed through the onboarding wizard until
+//	                  the OAuth URL is printed
+//	waiting-for-code  the user opens the URL and pastes the code back
+//	exchanging        the code is typed in and the CLI trades it for a
+//	                  token; post-auth prompts are advanced with Enter
+//	verifying         the CLI did not exit; a quick call checks whether the
+//	                  credentials were saved anyway
+//
+// `claude login` stores credentials in ~/.claude/ so subsequent `claude -p`
+// calls are authenticated. A chat's login state is journaled in DATA_DIR so
+// that a login interrupted by a restart can be resumed.
+
+type loginState string
+
+const (
+	loginWaitingURL  loginState = "waiting-for-url"
+	loginWaitingCode loginState = "waiting-for-code"
+	loginExchanging  loginState = "exchanging"
+	loginVerifying   loginState = "verifying"
+	loginFailed      loginState = "failed"
+)
+
+// loginTimeouts bounds the time spent in each state.
+var loginTimeouts = map[loginState]time.Duration{
+	loginWaitingURL:  30 * time.Second,
+	loginWaitingCode: 5 * time.Minute,
+	loginExchanging:  30 * time.Second,
+	loginVerifying:   15 * time.Second,
+}
+
+const (
+	// loginAdvanceInterval is how often Enter is pressed to get past the
+	// CLI's prompts.
+	loginAdvanceInterval = 2 * time.Second
+	// maxLoginLine caps a buffered output line: Ink's raw-mode screens may
+	// contain no newlines at all.
+	maxLoginLine = 1 << 20
+	// maxLoginResumeAge is how old an interrupted login may be and still be
+	// resumed after a restart.
+	maxLoginResumeAge = time.Hour
+)
+
+// ClaudeLogin is one run of `claude login`.
+type ClaudeLogin struct {
+	c       *ClaudeClient
+	cmd     *exec.Cmd
+	ptmx    *os.File
+	onState func(state loginState, err error)
+	// URL is the OAuth URL, set once waiting-for-code is reached.
+	URL string
+
+	lines     chan string   // ANSI-stripped output lines, closed at EOF
+	exited    chan struct{} // closed when the process exits
+	waitErr   error
+	codeSent  chan struct{}
+	closeOnce sync.Once
+
+	mu    sync.Mutex
+	state loginState
+}
+
+// StartLogin starts `claude login` and returns once the OAuth URL was
+// printed; call SubmitCode with the code the user gets after opening it.
+// onState is called on every state change, and with loginFailed and the
+// error when the login fails; a cancelled ctx ends the login silently.
+func (c *ClaudeClient) StartLogin(ctx context.Context, onState func(state loginState, err error)) (*ClaudeLogin, error) {
+	l := &ClaudeLogin{
+		c:        c,
+		onState:  onState,
+		lines:    make(chan string, 256),
+		exited:   make(chan struct{}),
+		codeSent: make(chan struct{}),
+	}
+	l.enter("", loginWaitingURL)
+
+	slog.Info("starting claude login (with PTY)")
+	l.cmd = exec.CommandContext(ctx, c.claudePath, "login")
+	// The PTY makes it a session (and group) leader.
+	killGroupOnCancel(l.cmd)
+	l.cmd.Dir = c.workDir
+	// Prevent browser launch in container.
+	l.cmd.Env = append(os.Environ(), "BROWSER=", "DISPLAY=")
+	// Wide columns prevent URL line-wrapping.
+	ptmx, err := pty.StartWithSize(l.cmd, &pty.Winsize{Rows: 24, Cols: 500})
+	if err != nil {
+		return nil, l.fail(fmt.Errorf("start claude login with pty: %w", err))
+	}
+	l.ptmx = ptmx
+	go func() {
+		l.waitErr = l.cmd.Wait()
+		close(l.exited)
+	}()
+	go l.pump()
+
+	url, err := l.waitURL(ctx)
+	if err != nil {
+		l.Close()
+		return nil, l.fail(err)
+	}
+	slog.Info("login: got URL", "url", url)
+	l.URL = url
+	l.enter(loginWaitingURL, loginWaitingCode)
+	go l.awaitCode(ctx)
+	return l, nil
+}
+
+// State returns the login's current state.
+func (l *ClaudeLogin) State() loginState {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.state
+}
+
+// enter moves the login from one state to another and reports the change.
+// It fails if the login is no longer in state from.
+func (l *ClaudeLogin) enter(from, to loginState) bool {
+	l.mu.Lock()
+	if l.state != from {
+		l.mu.Unlock()
+		return false
+	}
+	l.state = to
+	l.mu.Unlock()
+	slog.Debug("login state", "from", from, "to", to)
+	if l.onState != nil {
+		l.onState(to, nil)
+	}
+	return true
+}
+
+// fail moves the login to loginFailed, reports err and returns it.
+func (l *ClaudeLogin) fail(err error) error {
+	l.mu.Lock()
+	from := l.state
+	l.state = loginFailed
+	l.mu.Unlock()
+	if from == loginFailed {
+		return err
+	}
+	slog.Warn("login failed", "state", from, "err", err)
+	if l.onState != nil {
+		l.onState(loginFailed, err)
+	}
+	return err
+}
+
+// Close stops the login process. It is safe to call more than once.
+func (l *ClaudeLogin) Close() {
+	l.closeOnce.Do(func() {
+		if l.ptmx == nil {
+			return
+		}
+		l.ptmx.Close()
+		killGroup(l.cmd.Process)
+		<-l.exited
+	})
+}
+
+// pump reads the PTY so the process never blocks on write, splitting the
+// output into lines for the URL scanner. It reads raw bytes rather than
+// using bufio.Scanner: a line-based scanner would error on a long Ink
+// screen without newlines and stop draining the PTY.
+func (l *ClaudeLogin) pump() {
+	defer close(l.lines)
+	buf := make([]byte, 4096)
+	var line []byte
+	for {
+		n, err := l.ptmx.Read(buf)
+		if n > 0 {
+			slog.Debug("login output", "output", stripANSI(string(buf[:n])))
+			line = append(line, buf[:n]...)
+			for {
+				i := bytes.IndexByte(line, '\n')
+				if i < 0 {
+					break
+				}
+				l.emit(string(line[:i]))
+				line = line[i+1:]
+			}
+			if len(line) > maxLoginLine {
+				line = nil
+			}
+		}
+		if err != nil {
+			if len(line) > 0 {
+				l.emit(string(line))
+			}
+			return
+		}
+	}
+}
+
+// emit passes a line on to the URL scanner. Once nobody is scanning the
+// buffer fills up and lines are dropped.
+func (l *ClaudeLogin) emit(raw string) {
+	select {
+	case l.lines <- strings.TrimRight(stripANSI(raw), "\r"):
+	default:
+	}
+}
+
+// waitURL presses Enter through the onboarding wizard (theme selection,
+// etc.) until the OAuth URL shows up in the output.
+func (l *ClaudeLogin) waitURL(ctx context.Context) (string, error) {
+	timeout := time.NewTimer(loginTimeouts[loginWaitingURL])
+	defer timeout.Stop()
+	advance := time.NewTicker(loginAdvanceInterval)
+	defer advance.Stop()
+
+	var scan loginURLScanner
+	for {
+		select {
+		case line, ok := <-l.lines:
+			if !ok {
+				if url := scan.flush(); url != "" {
+					return url, nil
+				}
+				return "", errors.New("no login URL found in output")
+			}
+			if url, done := scan.feed(line); done {
+				return url, nil
+			}
+		case <-advance.C:
+			l.ptmx.Write([]byte("\r"))
+			slog.Debug("login: auto-advancing wizard (sent Enter)")
+		case <-timeout.C:
+			return "", errors.New("timeout waiting for login URL")
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+}
+
+// awaitCode ends the login if no code arrives in time.
+func (l *ClaudeLogin) awaitCode(ctx context.Context) {
+	timeout := time.NewTimer(loginTimeouts[loginWaitingCode])
+	defer timeout.Stop()
+	select {
+	case <-l.codeSent:
+		return
+	case <-ctx.Done():
+		l.Close()
+		return
+	case <-timeout.C:
+	case <-l.exited:
+	}
+	l.mu.Lock()
+	waiting := l.state == loginWaitingCode
+	l.mu.Unlock()
+	if !waiting {
+		return
+	}
+	l.Close()
+	l.fail(fmt.Errorf("no auth code received within %s", loginTimeouts[loginWaitingCode]))
+}
+
+// SubmitCode types the auth code into the CLI and waits for the token
+// exchange. The process is stopped when it returns.
+func (l *ClaudeLogin) SubmitCode(ctx context.Context, code string) error {
+	if !l.enter(loginWaitingCode, loginExchanging) {
+		return errors.New("the login expired")
+	}
+	close(l.codeSent)
+	defer l.Close()
+
+	slog.Info("login: feeding auth code", "chars", len(code))
+	if err := l.typeCode(code); err != nil {
+		return l.fail(err)
+	}
+
+	timeout := time.NewTimer(loginTimeouts[loginExchanging])
+	defer timeout.Stop()
+	// Auto-advance any post-auth prompts (org selection, confirmation,
+	// etc.) until the process exits.
+	advance := time.NewTicker(loginAdvanceInterval)
+	defer advance.Stop()
+	for {
+		select {
+		case <-l.exited:
+			if l.waitErr != nil {
+				return l.fail(fmt.Errorf("login failed: %w", l.waitErr))
+			}
+			slog.Info("login completed successfully")
+			return nil
+		case <-advance.C:
+			l.ptmx.Write([]byte("\r"))
+			slog.Debug("login: auto-advancing post-auth prompt (sent Enter)")
+		case <-timeout.C:
+			// The TUI often hangs on post-auth screens even after the
+			// credentials are saved.
+			slog.Warn("login process didn't exit in time, killing and verifying", "timeout", loginTimeouts[loginExchanging])
+			l.Close()
+			l.enter(loginExchanging, loginVerifying)
+			if err := l.c.VerifyLogin(ctx); err != nil {
+				return l.fail(err)
+			}
+			slog.Info("login verified despite process timeout")
+			return nil
+		case <-ctx.Done():
+			return l.fail(ctx.Err())
+		}
+	}
+}
+
+// typeCode writes the code one character at a time with small delays to
+// simulate real keystrokes: Ink's raw-mode input handler may not process a
+// bulk write of all characters at once.
+func (l *ClaudeLogin) typeCode(code string) error {
+	for i, ch := range code {
+		if _, err := l.ptmx.Write([]byte(string(ch))); err != nil {
+			slog.Error("login: failed to write to pty", "char", i, "err", err)
+			return fmt.Errorf("failed to send code: %w", err)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	// Pause before Enter so Ink finishes processing the input.
+	time.Sleep(200 * time.Millisecond)
+	if _, err := l.ptmx.Write([]byte("\r")); err != nil {
+		slog.Error("login: failed to write Enter to pty", "err", err)
+		return fmt.Errorf("failed to send Enter: %w", err)
+	}
+	return nil
+}
+
+// VerifyLogin checks with a quick call that the CLI has credentials.
+// Errors other than "not logged in" do not fail the check.
+func (c *ClaudeClient) VerifyLogin(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, loginTimeouts[loginVerifying])
+	defer cancel()
+	if _, err := c.Send(ctx, 0, "", "", "hi"); err != nil && IsNotLoggedIn(err) {
+		return errors.New("login timed out (auth may have failed)")
+	}
+	return nil
+}
+
+// loginURLScanner finds the OAuth URL in login output lines. A URL longer
+// than the terminal is wide wraps, so continuation lines (non-empty, no
+// spaces) after a match that reaches the end of its line are appended.
+type loginURLScanner struct {
+	url string
+}
+
+// feed consumes one line and returns the URL once it is complete.
+func (s *loginURLScanner) feed(line string) (string, bool) {
+	trimmed := strings.TrimSpace(line)
+	if s.url != "" {
+		if trimmed != "" && !strings.ContainsAny(trimmed, " \t") {
+			s.url += trimmed
+			return "", false
+		}
+		return s.url, true
+	}
+	if u := loginURLRe.FindString(trimmed); u != "" {
+		if strings.HasSuffix(trimmed, u) {
+			s.url = u
+			return "", false
+		}
+		return u, true
+	}
+	return "", false
+}
+
+// flush returns a URL still being accumulated when the output ends.
+func (s *loginURLScanner) flush() string {
+	return s.url
+}
+
+// loginRecord is a chat's in-progress login as journaled to disk.
+type loginRecord struct {
+	Provider        string     `json:"provider"`
+	State           loginState `json:"state"`
+	OriginalMessage string     `json:"original_message,omitempty"`
+	Started         time.Time  `json:"started"`
+}
+
+// LoginJournal records the chats' in-progress logins, persisted to
+// DATA_DIR, so they can be resumed after a restart.
+type LoginJournal struct {
+	mu    sync.Mutex
+	path  string
+	chats map[int64]loginRecord
+}
+
+func NewLoginJournal(dataDir string) *LoginJournal {
+	j := &LoginJournal{
+		path:  filepath.Join(dataDir, "logins.json"),
+		chats: make(map[int64]loginRecord),
+	}
+	if err := loadJSONFile(j.path, &j.chats); err != nil {
+		slog.Warn("failed to load login journal", "path", j.path, "err", err)
+	}
+	return j
+}
+
+func (j *LoginJournal) Set(chatID int64, r loginRecord) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.chats[chatID] = r
+	j.saveLocked()
+}
+
+func (j *LoginJournal) Delete(chatID int64) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if _, ok := j.chats[chatID]; !ok {
+		return
+	}
+	delete(j.chats, chatID)
+	j.saveLocked()
+}
+
+// Take removes and returns every journaled login.
+func (j *LoginJournal) Take() map[int64]loginRecord {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	out := j.chats
+	j.chats = make(map[int64]loginRecord)
+	if len(out) > 0 {
+		j.saveLocked()
+	}
+	return out
+}
+
+func (j *LoginJournal) saveLocked() {
+	if err := saveJSONFile(j.path, j.chats); err != nil {
+		slog.Warn("failed to save login journal", "path", j.path, "err", err)
+	}
+}
+
+// loginProgress is the key of the message sent when a Claude login enters
+// a state. The URL itself is sent by performLogin.
+var loginProgress = map[loginState]string{
+	loginWaitingURL: "login.starting",
+	loginExchanging: "login.exchanging",
+	loginVerifying:  "login.verifying",
+}
+
+// claudeLoginObserver journals a chat's Claude login and reports its
+// progress. A login that fails while waiting for the code is dropped here;
+// other failures are reported by the caller.
+func (h *Handlers) claudeLoginObserver(chatID int64, originalMessage string) func(loginState, error) {
+	started := time.Now()
+	return func(state loginState, err error) {
+		if state == loginFailed {
+			h.loginJournal.Delete(chatID)
+			if p := h.logins.Get(chatID); p != nil && p.Provider == "claude" {
+				h.logins.Delete(chatID)
+				p.Cancel()
+				h.sender.SendPlain(chatID, h.t(chatID, "login.cancelled", err))
+			}
+			return
+		}
+		h.loginJournal.Set(chatID, loginRecord{Provider: "claude", State: state, OriginalMessage: originalMessage, Started: started})
+		if key := loginProgress[state]; key != "" {
+			h.sender.SendPlain(chatID, h.t(chatID, key))
+		}
+	}
+}
+
+// resumeLogins picks up the logins a restart interrupted. The login
+// process died with the bot, so its URL is useless: the login starts over,
+// unless the code had already been sent and the credentials were saved.
+func (h *Handlers) resumeLogins() {
+	for chatID, r := range h.loginJournal.Take() {
+		if time.Since(r.Started) > maxLoginResumeAge {
+			slog.Info("dropping stale interrupted login", "chat_id", chatID, "provider", r.Provider, "state", r.State)
+			continue
+		}
+		h.resumeLogin(chatID, r)
+	}
+}
+
+func (h *Handlers) resumeLogin(chatID int64, r loginRecord) {
+	unlock := h.locks.Lock(chatID)
+	defer unlock()
+	if h.logins.Has(chatID) {
+		return
+	}
+	slog.Info("resuming interrupted login", "chat_id", chatID, "provider", r.Provider, "state", r.State)
+	ctx := context.Background()
+	if r.Provider != "claude" {
+		h.sender.SendPlain(chatID, h.t(chatID, "login.restarted_key"))
+		h.performKeyLogin(ctx, chatID, r.Provider, r.OriginalMessage)
+		return
+	}
+	if r.State == loginExchanging || r.State == loginVerifying {
+		if err := h.claude.VerifyLogin(ctx); err == nil {
+			h.finishLogin(ctx, chatID, "claude", r.OriginalMessage)
+			return
+		}
+	}
+	h.sender.SendPlain(chatID, h.t(chatID, "login.restarted_claude"))
+	h.performLogin(ctx, chatID, r.OriginalMessage)
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLoginURLScanner(t *testing.T) {
+	tests := []struct {
+		name  string
+		lines []string
+		want  string
+	}{
+		{"inline", []string{"Welcome", "Visit https://claude.ai/oauth?code=1 to sign in", "more"}, "https://claude.ai/oauth?code=1"},
+		{"end of line", []string{"  https://claude.ai/oauth?code=1  ", "", "Paste code here"}, "https://claude.ai/oauth?code=1"},
+		{"wrapped", []string{"https://claude.ai/oauth?client", "_id=abc&state", "=xyz", "Paste code here"}, "https://claude.ai/oauth?client_id=abc&state=xyz"},
+		{"no url", []string{"Select a theme", "> Dark"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var s loginURLScanner
+			got := ""
+			for _, line := range tt.lines {
+				if u, done := s.feed(line); done {
+					got = u
+					break
+				}
+			}
+			if got == "" {
+				got = s.flush()
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoginJournal(t *testing.T) {
+	dir := t.TempDir()
+	j := NewLoginJournal(dir)
+	started := time.Now().Truncate(time.Second)
+	j.Set(1, loginRecord{Provider: "claude", State: loginWaitingURL, OriginalMessage: "hi", Started: started})
+	j.Set(1, loginRecord{Provider: "claude", State: loginExchanging, OriginalMessage: "hi", Started: started})
+	j.Set(2, loginRecord{Provider: "gemini", State: loginWaitingCode, Started: started})
+	j.Delete(2)
+
+	// A restart reloads the journal.
+	j = NewLoginJournal(dir)
+	got := j.Take()
+	if len(got) != 1 {
+		t.Fatalf("got %d logins, want 1", len(got))
+	}
+	r := got[1]
+	if r.State != loginExchanging || r.OriginalMessage != "hi" || !r.Started.Equal(started) {
+		t.Errorf("got %+v", r)
+	}
+	if len(NewLoginJournal(dir).Take()) != 0 {
+		t.Error("Take did not clear the journal")
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// coalesceMaxBytes is the largest message that is held back for merging;
+// anything longer is sent right away.
+const coalesceMaxBytes = 500
+
+// coalescer merges small plain messages sent to the same chat within a short
+// window (e.g. "Running: X" and short outputs during auto-execute) into one
+// Telegram message. The window starts at the first buffered message, so no
+// message is delayed by more than window.
+type coalescer struct {
+	window  time.Duration
+	deliver func(chatID int64, text string)
+
+	mu      sync.Mutex
+	batches map[int64]*batch
+	// sending[chatID] is held while that chat's batch is delivered so a
+	// later message for the chat cannot overtake it. Delivery may wait on
+	// pacing or retries, so it must not block other chats.
+	sending map[int64]*sync.Mutex
+}
+
+type batch struct {
+	parts []string
+	size  int
+	timer *time.Timer
+}
+
+func newCoalescer(window time.Duration, deliver func(chatID int64, text string)) *coalescer {
+	return &coalescer{window: window, deliver: deliver, batches: make(map[int64]*batch), sending: make(map[int64]*sync.Mutex)}
+}
+
+// Add buffers text for chatID and reports whether it did; large messages are
+// not buffered and the caller should Flush and send them itself.
+func (c *coalescer) Add(chatID int64, text string) bool {
+	if len(text) > coalesceMaxBytes {
+		return false
+	}
+	c.mu.Lock()
+	b := c.batches[chatID]
+	if b != nil && b.size+len(text)+2 > maxMessageLength {
+		c.mu.Unlock()
+		c.Flush(chatID)
+		c.mu.Lock()
+		b = c.batches[chatID]
+	}
+	defer c.mu.Unlock()
+	if b == nil {
+		b = &batch{}
+		b.timer = time.AfterFunc(c.window, func() { c.Flush(chatID) })
+		c.batches[chatID] = b
+	}
+	b.parts = append(b.parts, text)
+	b.size += len(text) + 2
+	return true
+}
+
+// Flush sends anything buffered for chatID now. It also waits for a
+// delivery to the chat already in progress.
+func (c *coalescer) Flush(chatID int64) {
+	c.mu.Lock()
+	send := c.sending[chatID]
+	if send == nil {
+		send = &sync.Mutex{}
+		c.sending[chatID] = send
+	}
+	c.mu.Unlock()
+
+	send.Lock()
+	defer send.Unlock()
+	c.mu.Lock()
+	b := c.batches[chatID]
+	delete(c.batches, chatID)
+	c.mu.Unlock()
+	if b == nil {
+		return
+	}
+	b.timer.Stop()
+	c.deliver(chatID, strings.Join(b.parts, "\n\n"))
+}
+
+// FlushAll sends every buffered batch, e.g. on shutdown.
+func (c *coalescer) FlushAll() {
+	c.mu.Lock()
+	ids := make([]int64, 0, len(c.batches))
+	for chatID := range c.batches {
+		ids = append(ids, chatID)
+	}
+	c.mu.Unlock()
+	for _, chatID := range ids {
+		c.Flush(chatID)
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type deliveries struct {
+	mu   sync.Mutex
+	msgs []string
+}
+
+func (d *deliveries) add(chatID int64, text string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.msgs = append(d.msgs, text)
+}
+
+func (d *deliveries) get() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]string(nil), d.msgs...)
+}
+
+func TestCoalescerMergesWithinWindow(t *testing.T) {
+	var d deliveries
+	c := newCoalescer(50*time.Millisecond, d.add)
+	c.Add(1, "Running: ls")
+	c.Add(1, "a.txt")
+	if got := d.get(); len(got) != 0 {
+		t.Fatalf("delivered before the window closed: %q", got)
+	}
+	time.Sleep(150 * time.Millisecond)
+	if got := d.get(); len(got) != 1 || got[0] != "Running: ls\n\na.txt" {
+		t.Fatalf("got %q, want one merged message", got)
+	}
+}
+
+func TestCoalescerFlushAndLimits(t *testing.T) {
+	var d deliveries
+	c := newCoalescer(time.Hour, d.add)
+	if c.Add(1, strings.Repeat("x", coalesceMaxBytes+1)) {
+		t.Error("large message should not be buffered")
+	}
+	c.Add(1, "one")
+	c.Add(2, "other chat")
+	c.Flush(1)
+	if got := d.get(); len(got) != 1 || got[0] != "one" {
+		t.Fatalf("Flush(1) delivered %q", got)
+	}
+
+	// A batch that would exceed a Telegram message is sent first.
+	for i := 0; i < maxMessageLength/coalesceMaxBytes+1; i++ {
+		c.Add(3, strings.Repeat("y", coalesceMaxBytes))
+	}
+	c.FlushAll()
+	for _, m := range d.get() {
+		if len(m) > maxMessageLength {
+			t.Errorf("merged message of %d bytes exceeds limit", len(m))
+		}
+	}
+	if got := len(d.get()); got != 4 { // "one", chat 2, and chat 3 split in two
+		t.Errorf("got %d deliveries, want 4", got)
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// codeExtensions maps fence languages to file extensions for code blocks
+// sent as documents. Unknown languages get ".txt".
+var codeExtensions = map[string]string{
+	"go": "go", "python": "py", "py": "py", "javascript": "js", "js": "js",
+	"typescript": "ts", "ts": "ts", "tsx": "tsx", "jsx": "jsx",
+	"bash": "sh", "sh": "sh", "shell": "sh", "zsh": "sh", "console": "txt",
+	"yaml": "yaml", "yml": "yaml", "json": "json", "toml": "toml", "ini": "ini", "xml": "xml",
+	"sql": "sql", "html": "html", "css": "css", "rust": "rs", "rs": "rs",
+	"java": "java", "kotlin": "kt", "c": "c", "cpp": "cpp", "csharp": "cs", "cs": "cs",
+	"ruby": "rb", "rb": "rb", "php": "php", "lua": "lua", "swift": "swift",
+	"hcl": "tf", "terraform": "tf", "tf": "tf", "nginx": "conf", "conf": "conf",
+	"markdown": "md", "md": "md", "diff": "diff", "patch": "diff", "makefile": "mk",
+	"dockerfile": "Dockerfile",
+}
+
+// codeFileName names the n-th code block of a reply after its language.
+func codeFileName(lang string, n int) string {
+	ext, ok := codeExtensions[strings.ToLower(lang)]
+	if !ok {
+		ext = "txt"
+	}
+	if ext == "Dockerfile" {
+		if n == 1 {
+			return "Dockerfile"
+		}
+		return fmt.Sprintf("Dockerfile.%d", n)
+	}
+	return fmt.Sprintf("code-%d.%s", n, ext)
+}
+
+// replyPart is a piece of an AI reply: text to send as a message, or a code
+// block to send as a document.
+type replyPart struct {
+	text string
+	file string // set for documents
+	lang string
+}
+
+// splitCodeFiles cuts the fenced code blocks longer than limit bytes out of
+// text, leaving a pointer to the attachment in their place. Parts keep the
+// reply's order. limit <= 0 keeps everything inline.
+func splitCodeFiles(text string, limit int) []replyPart {
+	if limit <= 0 {
+		return []replyPart{{text: text}}
+	}
+	var parts []replyPart
+	var pending strings.Builder
+	prev, n := 0, 0
+	for _, m := range fencedCodeRe.FindAllStringSubmatchIndex(text, -1) {
+		code := text[m[4]:m[5]]
+		if len(code) <= limit {
+			continue
+		}
+		n++
+		lang := text[m[2]:m[3]]
+		name := codeFileName(lang, n)
+		pending.WriteString(text[prev:m[0]])
+		pending.WriteString(fmt.Sprintf("📎 %s (%d lines, attached)", name, strings.Count(strings.TrimRight(code, "\n"), "\n")+1))
+		parts = append(parts, replyPart{text: pending.String()}, replyPart{text: code, file: name, lang: lang})
+		pending.Reset()
+		prev = m[1]
+	}
+	pending.WriteString(text[prev:])
+	if rest := pending.String(); strings.TrimSpace(rest) != "" || len(parts) == 0 {
+		parts = append(parts, replyPart{text: rest})
+	}
+	return parts
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSplitCodeFiles(t *testing.T) {
+	big := strings.Repeat("fmt.Println(1)\n", 10)
+	text := "Here is the fix:\n```go\n" + big + "```\nand a small one:\n```sh\nls\n```\nDone.\n```\n" + big + "```"
+
+	parts := splitCodeFiles(text, 100)
+	if len(parts) != 4 {
+		t.Fatalf("got %d parts: %+v", len(parts), parts)
+	}
+	if parts[0].file != "" || !strings.HasSuffix(parts[0].text, "📎 code-1.go (10 lines, attached)") {
+		t.Errorf("part 0 = %+v", parts[0])
+	}
+	if parts[1].file != "code-1.go" || parts[1].text != big {
+		t.Errorf("part 1 = %+v", parts[1])
+	}
+	if !strings.Contains(parts[2].text, "```sh\nls\n```") || !strings.HasSuffix(parts[2].text, "📎 code-2.txt (10 lines, attached)") {
+		t.Errorf("small block should stay inline, part 2 = %q", parts[2].text)
+	}
+	if parts[3].file != "code-2.txt" {
+		t.Errorf("part 3 = %+v", parts[3])
+	}
+
+	if parts := splitCodeFiles(text, 0); len(parts) != 1 || parts[0].text != text {
+		t.Errorf("limit 0 should keep the reply whole, got %+v", parts)
+	}
+}
+
+func TestCodeFileName(t *testing.T) {
+	for _, tt := range []struct {
+		lang string
+		n    int
+		want string
+	}{
+		{"Python", 1, "code-1.py"},
+		{"yml", 2, "code-2.yaml"},
+		{"dockerfile", 1, "Dockerfile"},
+		{"dockerfile", 2, "Dockerfile.2"},
+		{"brainfuck", 3, "code-3.txt"},
+	} {
+		if got := codeFileName(tt.lang, tt.n); got != tt.want {
+			t.Errorf("codeFileName(%q, %d) = %q, want %q", tt.lang, tt.n, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,540 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Command describes a slash command: how it is routed, listed in /help and
+// Telegram's command menu, and reported in usage errors.
+type Command struct {
+	Name        string
+	Args        string // argument synopsis, e.g. "<chatID> [reason]"
+	Description string
+	Details     string   // extra notes shown by /help <command>
+	Examples    []string // shown by /help <command>
+	AdminOnly   bool
+	Settings    func(h *Handlers, chatID int64) string // chat state shown by /help <command>; may be nil
+	Run         func(h *Handlers, ctx context.Context, chatID int64, args string)
+}
+
+// commandRegistry lists every command in /help order. It is filled in init
+// because handlers look commands up for their own usage errors.
+var commandRegistry []Command
+
+func init() {
+	commandRegistry = []Command{
+		{Name: "start", Description: "Welcome message",
+			Run: func(h *Handlers, _ context.Context, chatID int64, _ string) { h.HandleStart(chatID) }},
+		{Name: "new", Description: "Reset session (start fresh conversation)",
+			Settings: settingProvider,
+			Run:      func(h *Handlers, _ context.Context, chatID int64, _ string) { h.HandleNew(chatID) }},
+		{Name: "claude", Description: "Switch active AI to Claude",
+			Settings: settingProvider,
+			Run:      func(h *Handlers, _ context.Context, chatID int64, _ string) { h.HandleSwitchProvider(chatID, "claude") }},
+		{Name: "gemini", Description: "Switch active AI to Gemini",
+			Settings: settingProvider,
+			Run:      func(h *Handlers, _ context.Context, chatID int64, _ string) { h.HandleSwitchProvider(chatID, "gemini") }},
+		{Name: "openrouter", Description: "Switch active AI to OpenRouter (many vendors' models)",
+			Settings: settingProvider,
+			Run: func(h *Handlers, _ context.Context, chatID int64, _ string) {
+				h.HandleSwitchProvider(chatID, "openrouter")
+			}},
+		{Name: "azure", Description: "Switch active AI to Azure OpenAI (configured deployment)",
+			Details:  "Uses AZURE_OPENAI_ENDPOINT, AZURE_OPENAI_DEPLOYMENT, AZURE_OPENAI_API_VERSION and AZURE_OPENAI_API_KEY from the server environment.",
+			Settings: settingProvider,
+			Run:      func(h *Handlers, _ context.Context, chatID int64, _ string) { h.HandleSwitchProvider(chatID, "azure") }},
+		{Name: "bedrock", Description: "Switch active AI to AWS Bedrock (configured model)",
+			Details:  "Uses BEDROCK_REGION, BEDROCK_MODEL_ID and the AWS_* credentials from the server environment; requests are signed with SigV4.",
+			Settings: settingProvider,
+			Run: func(h *Handlers, _ context.Context, chatID int64, _ string) {
+				h.HandleSwitchProvider(chatID, "bedrock")
+			}},
+		{Name: "model", Description: "Show currently active AI and model",
+			Settings: settingProvider,
+			Run:      func(h *Handlers, _ context.Context, chatID int64, _ string) { h.HandleModel(chatID) }},
+		{Name: "cmodel", Args: "[model|default]", Description: "Switch Claude model for this chat (when using Claude)",
+			Details:  "Without arguments shows opus, sonnet and haiku as buttons; a full model name can be given directly. The session is kept.",
+			Examples: []string{"/cmodel", "/cmodel haiku", "/cmodel default"},
+			Settings: settingClaudeModel,
+			Run:      func(h *Handlers, _ context.Context, chatID int64, args string) { h.HandleClaudeModel(chatID, args) }},
+		{Name: "gmodel", Description: "Switch this chat's Gemini model",
+			Details:  "Same as /models gemini.",
+			Settings: settingProvider,
+			Run:      func(h *Handlers, ctx context.Context, chatID int64, _ string) { h.HandleModels(ctx, chatID, "gemini") }},
+		{Name: "omodel", Args: "[vendor|vendor/model] [--max-price=USD]", Description: "Browse and pick OpenRouter models by vendor and price",
+			Details:  "Without arguments lists vendors; with a vendor lists its models cheapest first; with a model ID switches to it. --max-price caps the input price per 1M tokens.",
+			Examples: []string{"/omodel", "/omodel anthropic", "/omodel --max-price=0.5", "/omodel openai/gpt-4o-mini"},
+			Settings: settingProvider,
+			Run: func(h *Handlers, ctx context.Context, chatID int64, args string) {
+				h.HandleOpenRouterModel(ctx, chatID, args)
+			}},
+		{Name: "models", Args: "[provider] [filter]", Description: "Compare all providers' models and pick one for this chat",
+			Details:  "Lists a provider's models (the chat's provider by default) with list prices per 1M tokens, context size and capabilities, a page at a time, with tabs for the other providers. The filter matches model IDs and names. Picking a model keeps it for this chat and switches the chat to its provider.",
+			Examples: []string{"/models", "/models gemini", "/models openrouter llama"},
+			Settings: settingProvider,
+			Run:      func(h *Handlers, ctx context.Context, chatID int64, args string) { h.HandleModels(ctx, chatID, args) }},
+		{Name: "login", Description: "Login to the active AI (Claude OAuth / Gemini or OpenRouter API key)",
+			Details:  "Claude: sends an OAuth URL; reply with the code. Gemini: paste an API key from aistudio.google.com/apikey. OpenRouter: paste a key from openrouter.ai/settings/keys.",
+			Settings: settingProvider,
+			Run:      func(h *Handlers, ctx context.Context, chatID int64, _ string) { h.HandleLogin(ctx, chatID) }},
+		{Name: "usage", Args: "[lifetime]", Description: "Check usage stats for this session, or the chat's lifetime totals",
+			Details:  "/new starts a new session and resets the session figures; lifetime totals are kept across sessions and restarts.",
+			Examples: []string{"/usage", "/usage lifetime"},
+			Run:      func(h *Handlers, _ context.Context, chatID int64, args string) { h.HandleUsage(chatID, args) }},
+		{Name: "stats", Args: "providers|approvals", Description: "Per-provider latency, error and token stats, or approval wait times",
+			Examples: []string{"/stats providers", "/stats approvals"},
+			Run:      func(h *Handlers, _ context.Context, chatID int64, args string) { h.HandleStats(chatID, args) }},
+		{Name: "project", Args: "[list] | switch <name> | add <name> <path>", Description: "Manage per-chat project workspaces",
+			Details:  "Relative paths are resolved against WORK_DIR; other chats' directories are refused. The default project is the chat's own directory, WORK_DIR/<chatID> unless CHAT_WORKDIRS=false. Switching projects starts a fresh session.",
+			Examples: []string{"/project", "/project add web \"apps/web\"", "/project switch web"},
+			Settings: settingProject,
+			Run:      func(h *Handlers, _ context.Context, chatID int64, args string) { h.HandleProject(chatID, args) }},
+		{Name: "system", Args: "[show] | set <prompt> | reset", Description: "Show or change this chat's system prompt",
+			Details:  "The custom prompt replaces SYSTEM_PROMPT for this chat and is kept across restarts; safeguard rules are always appended. Claude receives it when a session starts.",
+			Examples: []string{"/system", "/system set You are a terse SRE assistant. Prefer kubectl.", "/system reset"},
+			Settings: settingSystemPrompt,
+			Run:      func(h *Handlers, _ context.Context, chatID int64, args string) { h.HandleSystem(chatID, args) }},
+		{Name: "persona", Args: "[name|off]", Description: "Switch to a preset system prompt, provider and model",
+			Details:  "Built-in personas are devops, code-reviewer and sysadmin; PERSONAS_FILE adds more. A persona sets the chat's system prompt (see /system) and starts a fresh session.",
+			Examples: []string{"/persona", "/persona devops", "/persona off"},
+			Settings: settingPersona,
+			Run:      func(h *Handlers, _ context.Context, chatID int64, args string) { h.HandlePersona(chatID, args) }},
+		{Name: "config", Args: "[show] | set <key> <value> | reset <key|all>", Description: "Show or change this chat's settings (timeout, rounds, provider, output and reply limits)",
+			Details:  "Overrides COMMAND_TIMEOUT (timeout), MAX_TOOL_ROUNDS (max_rounds), DEFAULT_PROVIDER (provider), the chat output limit (output_limit), RESULT_MAX_BYTES (result_limit), the tokens generated per reply (max_tokens) and a reply length to aim for on a phone (reply_chars) for this chat. Settings are kept across restarts.",
+			Examples: []string{"/config", "/config set timeout 15m", "/config set provider gemini", "/config set reply_chars 1500", "/config reset all"},
+			Settings: settingConfig,
+			Run:      func(h *Handlers, _ context.Context, chatID int64, args string) { h.HandleConfig(chatID, args) }},
+		{Name: "var", Args: "[list] | set <NAME> <value> | unset <NAME>", Description: "Manage chat variables expanded as {{NAME}} in prompts and commands",
+			Details:  "Variables are expanded in your messages before they reach the AI and in commands before they run; unknown names are left as written.",
+			Examples: []string{"/var set SERVER 10.0.0.5", "/var", "/var unset SERVER"},
+			Settings: settingVars,
+			Run:      func(h *Handlers, _ context.Context, chatID int64, args string) { h.HandleVar(chatID, args) }},
+		{Name: "inventory", Args: "[list [tag]] | show <name> | add <name> <address> [key=value ...] | rm <name>", Description: "Manage the hosts and services the AI knows about",
+			Details:  "The inventory is shared by all chats and kept in DATA_DIR. The AI gets all of it at the start of a session, and the entries a later message names. tags= takes a comma-separated list; other keys (services, user, port, note) are free-form. With an admin configured, only admin chats can add or remove hosts.",
+			Examples: []string{"/inventory add web1 10.0.0.5 tags=prod,web services=nginx", "/inventory add db1 10.0.0.9 tags=prod services=postgres \"note=primary, do not restart\"", "/inventory list prod", "/inventory rm web1"},
+			Run:      func(h *Handlers, _ context.Context, chatID int64, args string) { h.HandleInventory(chatID, args) }},
+		{Name: "undo", Description: "Roll back files to the last checkpoint",
+			Settings: settingProject,
+			Run:      func(h *Handlers, _ context.Context, chatID int64, _ string) { h.HandleUndo(chatID) }},
+		{Name: "checkpoints", Description: "List file checkpoints",
+			Settings: settingProject,
+			Run:      func(h *Handlers, _ context.Context, chatID int64, _ string) { h.HandleCheckpoints(chatID) }},
+		{Name: "summarize", Description: "Compress the session into a summary to save context",
+			Settings: settingHistory,
+			Run:      func(h *Handlers, ctx context.Context, chatID int64, _ string) { h.HandleSummarize(ctx, chatID) }},
+		{Name: "history", Args: "[n]", Description: "Replay the last n exchanges of this session",
+			Details:  "n defaults to 5.",
+			Examples: []string{"/history", "/history 10"},
+			Settings: settingHistory,
+			Run:      func(h *Handlers, _ context.Context, chatID int64, args string) { h.HandleHistory(chatID, args) }},
+		{Name: "export", Args: "[md|json]", Description: "Download this session's conversation",
+			Details:  "Markdown is the default.",
+			Examples: []string{"/export", "/export json"},
+			Settings: settingHistory,
+			Run:      func(h *Handlers, _ context.Context, chatID int64, args string) { h.HandleExport(chatID, args) }},
+		{Name: "make", Description: "Run a Makefile/Taskfile target (with approval)",
+			Settings: settingProject,
+			Run:      func(h *Handlers, _ context.Context, chatID int64, _ string) { h.HandleMake(chatID) }},
+		{Name: "fetchfile", Args: "<url> [name]", Description: "Download a file from a URL into the workspace",
+			Details:  "For files too big to send in chat: Telegram lets bots download at most 20 MB. The file is saved in the active project under the URL's file name unless a name is given, and is not overwritten if it exists. FETCH_MAX_BYTES caps the size.",
+			Examples: []string{"/fetchfile https://example.com/dump.sql.gz", "/fetchfile https://example.com/download?id=42 data.csv"},
+			Settings: settingProject,
+			Run: func(h *Handlers, ctx context.Context, chatID int64, args string) {
+				h.HandleFetchFile(ctx, chatID, args)
+			}},
+		{Name: "tunnel", Args: "start <port> | stop [port] | list", Description: "Expose a local port via ngrok",
+			Examples: []string{"/tunnel start 8080", "/tunnel stop 8080", "/tunnel list"},
+			Settings: settingTunnels,
+			Run:      func(h *Handlers, ctx context.Context, chatID int64, args string) { h.HandleTunnel(ctx, chatID, args) }},
+		{Name: "autorun", Args: "[on|off] [chatID]", Description: "Run this chat's commands without approval (or back to approval cards)",
+			Details:  "Overrides SKIP_PERMISSIONS for one chat and is kept across restarts; changing it starts a fresh session. With an admin configured, only admin chats can turn it on, or change it for another chat by ID. The safeguard still applies.",
+			Examples: []string{"/autorun", "/autorun on", "/autorun off", "/autorun on -100123456"},
+			Settings: settingAutorun,
+			Run:      func(h *Handlers, _ context.Context, chatID int64, args string) { h.HandleAutorun(chatID, args) }},
+		{Name: "readonly", Args: "[on|off] [chatID]", Description: "Let the AI suggest commands without running any",
+			Details:  "The AI still converses and proposes commands, but they are listed instead of offered for approval, and Claude gets no tools. Kept across restarts; changing it starts a fresh session. With an admin configured, only admin chats can turn it off, or change it for another chat by ID. READ_ONLY=true makes every chat read-only.",
+			Examples: []string{"/readonly", "/readonly on", "/readonly off -100123456"},
+			Settings: settingReadOnly,
+			Run:      func(h *Handlers, _ context.Context, chatID int64, args string) { h.HandleReadOnly(chatID, args) }},
+		{Name: "alive", Description: "Check in to keep auto-execute running (ALIVE_PERIOD)", AdminOnly: true,
+			Details: "With ALIVE_PERIOD set, an admin must send /alive at least that often. When the period passes without one, auto-execute is paused in every chat and commands wait for approval until an admin checks in. The admin chat is warned in the last tenth of the period.",
+			Run:     func(h *Handlers, _ context.Context, chatID int64, _ string) { h.HandleAlive(chatID) }},
+		{Name: "panic", Description: "Emergency stop: cancel everything running and make every chat read-only", AdminOnly: true,
+			Details: "Cancels all in-flight AI calls and command executions, kills backgrounded commands and ngrok tunnels, and drops pending approvals. Every chat stays read-only, also across restarts, until an admin sends /resume.",
+			Run:     func(h *Handlers, _ context.Context, chatID int64, _ string) { h.HandlePanic(chatID) }},
+		{Name: "resume", Description: "Lift the /panic emergency stop", AdminOnly: true,
+			Run: func(h *Handlers, _ context.Context, chatID int64, _ string) { h.HandleResume(chatID) }},
+		{Name: "allow", Args: "[chatID [note]]", Description: "List the allowed chats, or give a chat access", AdminOnly: true,
+			Details:  "Adds a chat to the allowlist without editing ALLOWED_CHAT_IDS, or lifts a /revoke. Changes are kept in DATA_DIR across restarts and reloads. Unauthorized users see their chat ID when they message the bot.",
+			Examples: []string{"/allow", "/allow 123456789 Alice", "/allow -100123456 team group"},
+			Run:      func(h *Handlers, _ context.Context, chatID int64, args string) { h.HandleAllow(chatID, args) }},
+		{Name: "revoke", Args: "<chatID> [note]", Description: "Withdraw a chat's access", AdminOnly: true,
+			Details:  "Works for chats in ALLOWED_CHAT_IDS too: the revocation is kept in DATA_DIR and wins over the variable until /allow lifts it. The chat's pending approvals are dropped.",
+			Examples: []string{"/revoke 123456789", "/revoke 123456789 left the team"},
+			Run:      func(h *Handlers, _ context.Context, chatID int64, args string) { h.HandleRevoke(chatID, args) }},
+		{Name: "hygiene", Description: "Report credential ages, auto-execute chats and recent safeguard exceptions", AdminOnly: true,
+			Details: "Lists when the Gemini API key, Claude login and SSH key were saved (flagging those older than 90 days) with the SSH key's fingerprint, the chats that run commands without approval, and the safeguard exceptions added in the last 7 days. HYGIENE_REPORT_SCHEDULE posts it to the admin chat, weekly by default.",
+			Run:     func(h *Handlers, _ context.Context, chatID int64, _ string) { h.HandleHygiene(chatID) }},
+		{Name: "reload", Description: "Re-read the configuration without restarting", AdminOnly: true,
+			Details: "Applies ALLOWED_CHAT_IDS, SYSTEM_PROMPT, GUARDRAILS_FILE, GUARDRAIL_PROFILE, PERSONAS_FILE, COMMAND_TIMEOUT and MAX_TOOL_ROUNDS from the environment and the --config file; other settings need a restart. SIGHUP does the same.",
+			Run:     func(h *Handlers, _ context.Context, chatID int64, _ string) { h.HandleReload(chatID) }},
+		{Name: "safeguard", Args: "<command> | report [N] | exceptions | revoke <n>", Description: "Test a command against safeguard rules without executing it",
+			Details:  "Admin only: report sends the last N safeguard verdicts (default 500) as a CSV: time, chat, provider, allowed/blocked, rule and a hash of the command. exceptions lists the commands allowed from \"Request exception\" buttons; revoke removes one.",
+			Examples: []string{"/safeguard rm -rf /", "/safeguard git push --force", "/safeguard report 100", "/safeguard exceptions", "/safeguard revoke 1"},
+			Run:      func(h *Handlers, _ context.Context, chatID int64, args string) { h.HandleSafeguard(chatID, args) }},
+		{Name: "guardrails", Args: "[profile]", Description: "Show or switch the reply guardrail profile",
+			Examples: []string{"/guardrails", "/guardrails strict"},
+			Settings: settingGuardrails,
+			Run:      func(h *Handlers, _ context.Context, chatID int64, args string) { h.HandleGuardrails(chatID, args) }},
+		{Name: "postprocess", Args: "[list] | replace <regex> => <replacement> | signature <text> | strip-reasoning | remove <n> | clear", Description: "Rewrite this chat's AI replies before they are sent",
+			Details:  "Steps run in order after the guardrails, on every AI reply: replace uses Go regexp syntax ($1 refers to a group), signature appends a line, strip-reasoning drops <think>, <thinking> and <reasoning> blocks. Useful when other automations read the chat. Kept across restarts.",
+			Examples: []string{"/postprocess", "/postprocess strip-reasoning", "/postprocess replace (?i)\\bprod\\b => production", "/postprocess signature -- sent by trash-bot", "/postprocess remove 1"},
+			Settings: settingPostProcess,
+			Run:      func(h *Handlers, _ context.Context, chatID int64, args string) { h.HandlePostProcess(chatID, args) }},
+		{Name: "outdiff", Args: "[on|off]", Description: "Show only what changed when a command is run again",
+			Details:  "Applies to the chat display and to the results sent to the AI. Unchanged output is reported as such; outputs too large to diff are shown in full.",
+			Examples: []string{"/outdiff on"},
+			Settings: settingOutputDiff,
+			Run:      func(h *Handlers, _ context.Context, chatID int64, args string) { h.HandleOutputDiff(chatID, args) }},
+		{Name: "typing", Args: "[on|off]", Description: "Toggle typing indicators for this chat",
+			Examples: []string{"/typing off"},
+			Settings: settingTyping,
+			Run:      func(h *Handlers, _ context.Context, chatID int64, args string) { h.HandleTyping(chatID, args) }},
+		{Name: "plain", Args: "[on|off]", Description: "Toggle plain-text mode (no formatting or emojis) for screen readers",
+			Details:  "Messages are sent unformatted and without emojis; code blocks are framed by CODE START and CODE END lines.",
+			Examples: []string{"/plain on"},
+			Settings: settingPlain,
+			Run:      func(h *Handlers, _ context.Context, chatID int64, args string) { h.HandlePlain(chatID, args) }},
+		{Name: "keyboard", Args: "[on|off|<action>...]", Description: "Show a reply keyboard with shortcut buttons",
+			Details:  "Actions: new, status, approve, deny, usage, model, undo, help. on shows new, status, approve and usage; listing actions picks the buttons.",
+			Examples: []string{"/keyboard on", "/keyboard new approve deny status", "/keyboard off"},
+			Settings: settingKeyboard,
+			Run:      func(h *Handlers, _ context.Context, chatID int64, args string) { h.HandleKeyboard(chatID, args) }},
+		{Name: "freeze", Args: "[chatID] [reason]", Description: "Freeze a chat or list frozen chats", AdminOnly: true,
+			Examples: []string{"/freeze", "/freeze 123456789 \"leaked a token\""},
+			Settings: settingFrozen,
+			Run:      func(h *Handlers, _ context.Context, chatID int64, args string) { h.HandleFreeze(chatID, args) }},
+		{Name: "unfreeze", Args: "<chatID>", Description: "Restore a frozen chat", AdminOnly: true,
+			Examples: []string{"/unfreeze 123456789"},
+			Settings: settingFrozen,
+			Run:      func(h *Handlers, _ context.Context, chatID int64, args string) { h.HandleUnfreeze(chatID, args) }},
+		{Name: "share", Args: "[chatID] [readonly|collab]", Description: "Share this session with another chat", AdminOnly: true,
+			Details:  "readonly (the default) mirrors replies and approval cards; collab also lets the guest send prompts and approve or deny commands.",
+			Examples: []string{"/share 123456789", "/share -100987654321 collab"},
+			Settings: settingShares,
+			Run:      func(h *Handlers, _ context.Context, chatID int64, args string) { h.HandleShare(chatID, args) }},
+		{Name: "unshare", Args: "<chatID>", Description: "Revoke a session share", AdminOnly: true,
+			Examples: []string{"/unshare 123456789"},
+			Settings: settingShares,
+			Run:      func(h *Handlers, _ context.Context, chatID int64, args string) { h.HandleUnshare(chatID, args) }},
+		{Name: "as", Args: "<chatID> <prompt>", Description: "Run a prompt in another chat's session, read-only", AdminOnly: true,
+			Details:  "The reply comes back here; the AI gets no tools, proposed commands are listed but not executed, and a forked copy of the chat's session is used so its own is not advanced.",
+			Examples: []string{"/as 123456789 why does the build fail?"},
+			Run:      func(h *Handlers, ctx context.Context, chatID int64, args string) { h.HandleAs(ctx, chatID, args) }},
+		{Name: "voice", Args: "[on|off]", Description: "Also send the AI's replies as voice notes",
+			Details:  "Replies are read out without their code blocks and cut at TTS_MAX_CHARS. Needs TTS_CMD or TTS_API_URL on the server. The setting is kept across restarts.",
+			Examples: []string{"/voice on", "/voice off"},
+			Settings: settingVoice,
+			Run:      func(h *Handlers, _ context.Context, chatID int64, args string) { h.HandleVoiceMode(chatID, args) }},
+		{Name: "lang", Args: "[code|default]", Description: "Show or change the language of the bot's messages",
+			Details:  "Applies to this chat's help, approval cards and common errors; untranslated messages stay in English. REPLY_LANGUAGE controls the AI's replies.",
+			Examples: []string{"/lang", "/lang it", "/lang default"},
+			Run:      func(h *Handlers, _ context.Context, chatID int64, args string) { h.HandleLang(chatID, args) }},
+		{Name: "help", Args: "[command]", Description: "Show this help message, or details for one command",
+			Examples: []string{"/help", "/help project"},
+			Run:      func(h *Handlers, _ context.Context, chatID int64, args string) { h.HandleHelp(chatID, args) }},
+	}
+}
+
+// lookupCommand finds a registered command by name.
+func lookupCommand(name string) (Command, bool) {
+	for _, c := range commandRegistry {
+		if c.Name == name {
+			return c, true
+		}
+	}
+	return Command{}, false
+}
+
+// Usage returns the command's synopsis, e.g. "/share [chatID] [readonly|collab]".
+func (c Command) Usage() string {
+	if c.Args == "" {
+		return "/" + c.Name
+	}
+	return "/" + c.Name + " " + c.Args
+}
+
+// RunCommand routes a slash command through the registry. Admin-only commands
+// are refused for other chats, and every other command for a frozen chat;
+// unknown commands show the help.
+func (h *Handlers) RunCommand(ctx context.Context, chatID int64, name, args string) {
+	cmd, ok := lookupCommand(name)
+	if !ok {
+		h.HandleHelp(chatID, "")
+		return
+	}
+	if cmd.AdminOnly && !h.isAdmin(chatID) {
+		h.sender.SendPlain(chatID, h.t(chatID, "admin_only"))
+		return
+	}
+	// A frozen chat can do nothing until an admin has reviewed it.
+	if !cmd.AdminOnly && h.rejectIfFrozen(chatID) {
+		return
+	}
+	cmd.Run(h, ctx, chatID, args)
+}
+
+// sendUsage replies with a command's usage line and description, for
+// missing or invalid arguments.
+func (h *Handlers) sendUsage(chatID int64, name string) {
+	cmd, ok := lookupCommand(name)
+	if !ok {
+		return
+	}
+	h.sender.SendPlain(chatID, h.t(chatID, "usage_line", cmd.Usage(), commandDescription(h.uiLanguage(chatID), cmd)))
+}
+
+// formatHelp renders the command list in lang; admin-only commands are
+// listed only for admins.
+func formatHelp(lang string, admin bool) string {
+	var b strings.Builder
+	b.WriteString(translate(lang, "help.title") + "\n\n")
+	for _, c := range commandRegistry {
+		if c.AdminOnly && !admin {
+			continue
+		}
+		fmt.Fprintf(&b, "%s - %s", c.Usage(), commandDescription(lang, c))
+		if c.AdminOnly {
+			b.WriteString(translate(lang, "help.admin"))
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString("\n" + translate(lang, "help.footer"))
+	return b.String()
+}
+
+// Telegram's limits on the command menu.
+const (
+	maxMenuCommands    = 100
+	maxMenuDescription = 256
+)
+
+// botCommands returns the commands in the form setMyCommands expects, in
+// /help order and described in lang; admin-only commands are included only
+// for admins.
+func botCommands(lang string, admin bool) []tgbotapi.BotCommand {
+	var cmds []tgbotapi.BotCommand
+	for _, c := range commandRegistry {
+		if c.AdminOnly && !admin {
+			continue
+		}
+		if len(cmds) == maxMenuCommands {
+			break
+		}
+		cmds = append(cmds, tgbotapi.BotCommand{Command: c.Name, Description: truncateText(commandDescription(lang, c), maxMenuDescription-3)})
+	}
+	return cmds
+}
+
+// registerCommandMenu sets Telegram's command autocomplete menu from the
+// registry: the public commands for every chat, and all of them for the
+// admin chats. Users whose Telegram app is in one of the catalog's other
+// languages see the descriptions in it.
+func (h *Handlers) registerCommandMenu() {
+	if _, err := h.sender.api.Request(tgbotapi.NewSetMyCommands(botCommands(defaultUILanguage, false)...)); err != nil {
+		slog.Warn("setMyCommands failed", "err", err)
+		return
+	}
+	for lang := range uiLanguages {
+		if lang == defaultUILanguage {
+			continue
+		}
+		menu := tgbotapi.NewSetMyCommandsWithScopeAndLanguage(tgbotapi.NewBotCommandScopeDefault(), lang, botCommands(lang, false)...)
+		if _, err := h.sender.api.Request(menu); err != nil {
+			slog.Warn("setMyCommands failed", "lang", lang, "err", err)
+		}
+	}
+	admin := botCommands(defaultUILanguage, true)
+	for chatID := range h.admins {
+		scope := tgbotapi.NewBotCommandScopeChat(chatID)
+		if _, err := h.sender.api.Request(tgbotapi.NewSetMyCommandsWithScope(scope, admin...)); err != nil {
+			slog.Warn("setMyCommands failed", "chat_id", chatID, "err", err)
+		}
+	}
+	slog.Info("command menu registered", "commands", len(admin), "admin_chats", len(h.admins))
+}
+
+// sendCommandHelp replies with the detailed help for one command: usage,
+// notes, examples and the chat's current settings that affect it.
+func (h *Handlers) sendCommandHelp(chatID int64, name string) {
+	cmd, ok := lookupCommand(name)
+	if !ok || (cmd.AdminOnly && !h.isAdmin(chatID)) {
+		h.sender.SendPlain(chatID, h.t(chatID, "help.unknown", name))
+		return
+	}
+	h.sender.SendPlain(chatID, formatCommandHelp(cmd, h, chatID))
+}
+
+func formatCommandHelp(cmd Command, h *Handlers, chatID int64) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\n\n%s.", cmd.Usage(), commandDescription(h.uiLanguage(chatID), cmd))
+	if cmd.Details != "" {
+		b.WriteString(" " + cmd.Details)
+	}
+	if cmd.AdminOnly {
+		b.WriteString(h.t(chatID, "help.admin_only"))
+	}
+	if len(cmd.Examples) > 0 {
+		b.WriteString(h.t(chatID, "help.examples"))
+		for _, e := range cmd.Examples {
+			b.WriteString("  " + e + "\n")
+		}
+	}
+	if cmd.Settings != nil && h != nil {
+		if s := cmd.Settings(h, chatID); s != "" {
+			b.WriteString(h.t(chatID, "help.this_chat") + s)
+		}
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+func settingProvider(h *Handlers, chatID int64) string {
+	switch provider := h.providers.Get(chatID); provider {
+	case "claude":
+		return h.t(chatID, "setting.provider", provider)
+	default:
+		return h.t(chatID, "setting.provider_model", provider, h.chatModel(chatID, provider))
+	}
+}
+
+func settingProject(h *Handlers, chatID int64) string {
+	name, dir := h.projects.Active(chatID)
+	line := h.t(chatID, "setting.project", name, dir)
+	if free := h.workspaces.Free(chatID); free >= 0 {
+		line += h.t(chatID, "setting.workspace", formatBytes(h.workspaces.Usage(chatID)), formatBytes(free))
+	}
+	return line
+}
+
+func settingClaudeModel(h *Handlers, chatID int64) string {
+	return h.t(chatID, "setting.claude_model", h.claudeModelName(chatID, h.claude.Model(chatID)))
+}
+
+func settingSystemPrompt(h *Handlers, chatID int64) string {
+	if custom := h.systemPrompts.Get(chatID); custom != "" {
+		return h.t(chatID, "setting.system_prompt_custom", len(custom))
+	}
+	return h.t(chatID, "setting.system_prompt_default")
+}
+
+func settingPersona(h *Handlers, chatID int64) string {
+	if p, ok := h.activePersona(chatID); ok {
+		return h.t(chatID, "setting.persona", p.Name)
+	}
+	return h.t(chatID, "setting.persona_none")
+}
+
+func settingAutorun(h *Handlers, chatID int64) string {
+	if h.autorunPaused(chatID) {
+		return h.t(chatID, "setting.autorun_paused")
+	}
+	return h.t(chatID, "setting.autorun", h.onOff(chatID, h.claude.autorun.Enabled(chatID)))
+}
+
+func settingReadOnly(h *Handlers, chatID int64) string {
+	return h.t(chatID, "setting.readonly", h.onOff(chatID, h.readOnly.Enabled(chatID)))
+}
+
+func settingVoice(h *Handlers, chatID int64) string {
+	if h.tts == nil {
+		return h.t(chatID, "setting.voice_unavailable")
+	}
+	return h.t(chatID, "setting.voice", h.onOff(chatID, h.settings.Get(chatID).Voice))
+}
+
+func settingConfig(h *Handlers, chatID int64) string {
+	cs := h.settings.Get(chatID)
+	var set []string
+	for _, s := range chatSettings {
+		if v := s.Value(cs); v != "" {
+			set = append(set, s.Key+"="+v)
+		}
+	}
+	if len(set) == 0 {
+		return h.t(chatID, "setting.overrides_none")
+	}
+	return h.t(chatID, "setting.overrides", strings.Join(set, ", "))
+}
+
+func settingVars(h *Handlers, chatID int64) string {
+	return h.t(chatID, "setting.vars", len(h.vars.List(chatID)))
+}
+
+func settingHistory(h *Handlers, chatID int64) string {
+	return h.t(chatID, "setting.history", len(h.history.Get(chatID)))
+}
+
+func settingTunnels(h *Handlers, chatID int64) string {
+	var b strings.Builder
+	for _, t := range h.tunnels.List() {
+		if t.ChatID == chatID {
+			fmt.Fprintf(&b, "  %s → localhost:%d\n", t.URL, t.Port)
+		}
+	}
+	if b.Len() == 0 {
+		return h.t(chatID, "setting.tunnels_none")
+	}
+	return b.String()
+}
+
+func settingGuardrails(h *Handlers, chatID int64) string {
+	return h.t(chatID, "setting.guardrails", h.guardrails.Get(chatID).Name, strings.Join(h.guardrails.Names(), ", "))
+}
+
+func settingPostProcess(h *Handlers, chatID int64) string {
+	return h.t(chatID, "setting.postprocess", len(h.settings.Get(chatID).PostProcess))
+}
+
+func settingTyping(h *Handlers, chatID int64) string {
+	if !h.sender.TypingEnabled(chatID) {
+		return h.t(chatID, "setting.typing_off")
+	}
+	return h.t(chatID, "setting.typing", h.typingEvery)
+}
+
+func settingOutputDiff(h *Handlers, chatID int64) string {
+	return h.t(chatID, "setting.output_diff", h.onOff(chatID, h.outputs.Enabled(chatID)))
+}
+
+func settingPlain(h *Handlers, chatID int64) string {
+	return h.t(chatID, "setting.plain", h.onOff(chatID, h.sender.PlainMode(chatID)))
+}
+
+func settingKeyboard(h *Handlers, chatID int64) string {
+	if actions := h.keyboards.Get(chatID); actions != nil {
+		return h.t(chatID, "setting.keyboard", strings.Join(actions, ", "))
+	}
+	return h.t(chatID, "setting.keyboard", h.t(chatID, "state.off"))
+}
+
+func settingFrozen(h *Handlers, chatID int64) string {
+	return h.t(chatID, "setting.frozen", len(h.frozen.List()))
+}
+
+func settingShares(h *Handlers, chatID int64) string {
+	guests := h.shares.Guests(chatID)
+	if len(guests) == 0 {
+		return h.t(chatID, "setting.shares_none")
+	}
+	parts := make([]string, 0, len(guests))
+	for _, g := range guests {
+		mode, _ := h.shares.Mode(chatID, g)
+		parts = append(parts, fmt.Sprintf("%d (%s)", g, mode))
+	}
+	return h.t(chatID, "setting.shares", strings.Join(parts, ", "))
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestCommandRegistry(t *testing.T) {
+	seen := map[string]bool{}
+	for _, c := range commandRegistry {
+		if seen[c.Name] {
+			t.Errorf("duplicate command %q", c.Name)
+		}
+		seen[c.Name] = true
+		if c.Description == "" || c.Run == nil {
+			t.Errorf("command %q needs a description and a Run func", c.Name)
+		}
+	}
+	if _, ok := lookupCommand("nope"); ok {
+		t.Error("lookupCommand found an unregistered command")
+	}
+}
+
+func TestFormatHelp(t *testing.T) {
+	user := formatHelp("en", false)
+	if strings.Contains(user, "/freeze") || !strings.Contains(user, "/history [n] - ") {
+		t.Errorf("user help wrong:\n%s", user)
+	}
+	if admin := formatHelp("en", true); !strings.Contains(admin, "/unfreeze <chatID> - Restore a frozen chat (admin)") {
+		t.Errorf("admin help missing admin commands:\n%s", admin)
+	}
+	for _, c := range botCommands("en", false) {
+		if c.Command == "as" {
+			t.Error("admin command exposed in bot menu")
+		}
+	}
+}
+
+func TestBotCommands(t *testing.T) {
+	user, admin := botCommands("en", false), botCommands("en", true)
+	if len(admin) <= len(user) || len(admin) > maxMenuCommands {
+		t.Errorf("menu sizes: %d user, %d admin", len(user), len(admin))
+	}
+	valid := regexp.MustCompile(`^[a-z0-9_]{1,32}$`)
+	found := false
+	for _, c := range admin {
+		if !valid.MatchString(c.Command) || c.Description == "" || len(c.Description) > maxMenuDescription {
+			t.Errorf("command %q/%q rejected by Telegram", c.Command, c.Description)
+		}
+		found = found || c.Command == "panic"
+	}
+	if !found {
+		t.Error("admin menu missing /panic")
+	}
+}
+
+func TestFormatCommandHelp(t *testing.T) {
+	cmd, _ := lookupCommand("freeze")
+	got := formatCommandHelp(cmd, nil, 1)
+	for _, want := range []string{"/freeze [chatID] [reason]\n\n", "Admin only.", "Examples:\n  /freeze\n"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("help missing %q:\n%s", want, got)
+		}
+	}
+	cmd, _ = lookupCommand("start")
+	if got := formatCommandHelp(cmd, nil, 1); got != "/start\n\nWelcome message." {
+		t.Errorf("plain command help = %q", got)
+	}
+}
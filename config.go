@@ -2,34 +2,150 @@ package main
 
 import (
 	"fmt"
+	"net/url"
 	"os"
+	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
 )
 
 type Config struct {
-	TelegramToken   string
-	AllowedChatIDs  map[int64]bool
-	WorkDir         string
-	ClaudePath      string
-	GeminiAPIKey    string
-	GeminiModel     string
-	DefaultProvider string
-	CommandTimeout  time.Duration
-	AllowedTools    []string
-	SkipPermissions bool
-	SystemPrompt    string
-	MaxToolRounds   int
-	WhisperCmd      string
-	GitSSHKey       string
-	GitlabToken     string
-	GitUserName     string
-	GitUserEmail    string
-	NgrokToken      string
+	TelegramToken      string
+	AllowedChatIDs     map[int64]bool
+	WorkDir            string
+	DataDir            string
+	ClaudePath         string
+	ClaudeModel        string
+	GeminiAPIKey       string
+	GeminiModel        string
+	OpenRouterAPIKey   string
+	OpenRouterModel    string
+	AzureEndpoint      string
+	AzureDeployment    string
+	AzureAPIVersion    string
+	AzureAPIKey        string
+	BedrockRegion      string
+	BedrockModelID     string
+	AWSAccessKeyID     string
+	AWSSecretKey       string
+	AWSSessionToken    string
+	DefaultProvider    string
+	CommandTimeout     time.Duration
+	AllowedTools       []string
+	SkipPermissions    bool
+	ToolTranscript     bool
+	SystemPrompt       string
+	Personas           []Persona
+	MaxToolRounds      int
+	WhisperCmd         string
+	WhisperBackend     string
+	WhisperAPIURL      string
+	WhisperAPIKey      string
+	WhisperModel       string
+	WhisperLanguage    string
+	GitSSHKey          string
+	GitlabToken        string
+	GitlabURL          string // the host GITLAB_TOKEN is sent to
+	GitUserName        string
+	GitUserEmail       string
+	GitRepos           []string
+	NgrokToken         string
+	AdminChatID        int64   // receives alerts and reviews
+	AdminChatIDs       []int64 // may run admin commands; includes AdminChatID
+	FreezeOnBlock      bool
+	AlivePeriod        time.Duration // /alive needed this often to keep auto-execute on
+	PerUserSessions    bool          // GROUP_SESSIONS=per_user
+	ReadOnly           bool          // no chat executes commands
+	CIWebhookAddr      string
+	CIWebhookSecret    string
+	CIWebhookChatIDs   []int64
+	CIWebhookAnalyze   bool
+	StartText          string
+	HelpText           string
+	HelpExtra          string
+	PluginDir          string
+	EventWebhookURL    string
+	EventWebhookSecret string
+	SessionBudgetUSD   float64
+	UserDailyTokens    int64       // per Telegram user, 0 = unlimited
+	UserDailyUSD       float64     // per Telegram user, 0 = unlimited
+	CostRouting        *CostPolicy // nil when COST_ROUTING_MODELS is unset
+	WebhookURL         string
+	WebhookListen      string
+	WebhookTLSCert     string
+	WebhookTLSKey      string
+	HealthAddr         string
+	MetricsAddr        string
+	TracesExporter     string
+	GuardrailProfiles  []GuardrailProfile
+	GuardrailProfile   string
+	LogLevel           string
+	LogFormat          string
+	TranscriptDir      string
+	TranscriptDays     int
+	TestGateCommand    string
+	EnvSnapshot        bool
+	ResultStrategy     string
+	ResultMaxBytes     int
+	OutputDiff         bool
+	GeminiMaxHistory   int
+	GeminiSummarize    bool
+	GeminiSaveBytes    int
+	GeminiSafety       []GeminiSafetySetting
+	CircuitFailures    int
+	CircuitCooldown    time.Duration
+	CircuitFallback    bool
+	PromptExpiry       time.Duration
+	ApprovalSLA        time.Duration
+	ApprovalEscalation []int64
+	TypingInterval     time.Duration
+	StreamInterval     time.Duration
+	ReplyLanguage      string
+	CodeFileBytes      int
+	MediaMaxBytes      int64
+	ChatWorkDirs       bool
+	ChatDiskQuota      int64
+	TTSCmd             string
+	TTSAPIURL          string
+	TTSAPIKey          string
+	TTSModel           string
+	TTSVoice           string
+	TTSMaxChars        int
+	FFmpegCmd          string
+	VideoKeyframes     int
+	FetchMaxBytes      int64
+	UsageReport        *Schedule
+	UsageReportChatID  int64
+	HygieneReport      *Schedule
+	SendCoalesce       time.Duration
+	SendRetries        int
+	SendPace           time.Duration
+	MaxConcurrent      int
+	ShutdownTimeout    time.Duration
+	ConfigFile         string // --config, re-read by ReloadConfig
 }
 
-func LoadConfig() (*Config, error) {
+// LoadConfig reads the configuration from the environment and, when path
+// is set, from a YAML config file whose values the environment overrides.
+func LoadConfig(path string) (*Config, error) {
+	var fromFile map[string]configFileValue
+	if path != "" {
+		var err error
+		if fromFile, err = applyConfigFile(path); err != nil {
+			return nil, err
+		}
+	}
+	cfg, err := loadEnvConfig()
+	if err != nil {
+		return nil, nameConfigKey(err, path, fromFile)
+	}
+	cfg.ConfigFile = path
+	return cfg, nil
+}
+
+func loadEnvConfig() (*Config, error) {
 	token := os.Getenv("TELEGRAM_BOT_TOKEN")
 	if token == "" {
 		return nil, fmt.Errorf("TELEGRAM_BOT_TOKEN is required")
@@ -58,6 +174,12 @@ func LoadConfig() (*Config, error) {
 		workDir = "."
 	}
 
+	dataDir := os.Getenv("DATA_DIR")
+	if dataDir == "" {
+		home, _ := os.UserHomeDir()
+		dataDir = filepath.Join(home, ".trash-bot")
+	}
+
 	claudePath := os.Getenv("CLAUDE_PATH")
 	if claudePath == "" {
 		claudePath = "claude"
@@ -73,6 +195,11 @@ func LoadConfig() (*Config, error) {
 		defaultProvider = "claude"
 	}
 
+	bedrockRegion := os.Getenv("BEDROCK_REGION")
+	if bedrockRegion == "" {
+		bedrockRegion = os.Getenv("AWS_REGION")
+	}
+
 	timeout := 5 * time.Minute
 	if t := os.Getenv("COMMAND_TIMEOUT"); t != "" {
 		var err error
@@ -92,6 +219,21 @@ func LoadConfig() (*Config, error) {
 		}
 	}
 
+	var gitRepos []string
+	for _, r := range strings.Split(os.Getenv("GIT_REPOS"), ",") {
+		if r = strings.TrimSpace(r); r != "" {
+			gitRepos = append(gitRepos, r)
+		}
+	}
+
+	gitlabURL := strings.TrimRight(os.Getenv("GITLAB_URL"), "/")
+	if gitlabURL == "" {
+		gitlabURL = "https://gitlab.com"
+	}
+	if u, err := url.Parse(gitlabURL); err != nil || u.Scheme != "https" || u.Host == "" {
+		return nil, fmt.Errorf("invalid GITLAB_URL %q: want https://host", gitlabURL)
+	}
+
 	skipPerms := os.Getenv("SKIP_PERMISSIONS") == "true"
 	systemPrompt := os.Getenv("SYSTEM_PROMPT")
 
@@ -99,6 +241,17 @@ func LoadConfig() (*Config, error) {
 	if whisperCmd == "" {
 		whisperCmd = "whisper"
 	}
+	whisperBackend := strings.ToLower(os.Getenv("WHISPER_BACKEND"))
+	if whisperBackend == "" {
+		whisperBackend = "cli"
+	}
+	if !slices.Contains(transcriptionBackends, whisperBackend) {
+		return nil, fmt.Errorf("invalid WHISPER_BACKEND %q (want %s)", whisperBackend, strings.Join(transcriptionBackends, ", "))
+	}
+	whisperAPIURL := os.Getenv("WHISPER_API_URL")
+	if whisperBackend == "faster-whisper" && whisperAPIURL == "" {
+		return nil, fmt.Errorf("WHISPER_BACKEND=faster-whisper needs WHISPER_API_URL")
+	}
 
 	maxRounds := 20
 	if r := os.Getenv("MAX_TOOL_ROUNDS"); r != "" {
@@ -107,24 +260,490 @@ func LoadConfig() (*Config, error) {
 		}
 	}
 
+	var adminChatID int64
+	if a := os.Getenv("ADMIN_CHAT_ID"); a != "" {
+		id, err := strconv.ParseInt(strings.TrimSpace(a), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ADMIN_CHAT_ID %q: %v", a, err)
+		}
+		adminChatID = id
+	}
+	// ADMIN_CHAT_IDS lists the chats allowed to run admin commands;
+	// ADMIN_CHAT_ID, or else the first of them, receives the alerts.
+	adminChatIDs, err := parseChatIDList(os.Getenv("ADMIN_CHAT_IDS"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid ADMIN_CHAT_IDS: %v", err)
+	}
+	if adminChatID == 0 && len(adminChatIDs) > 0 {
+		adminChatID = adminChatIDs[0]
+	}
+	if adminChatID != 0 && !slices.Contains(adminChatIDs, adminChatID) {
+		adminChatIDs = append([]int64{adminChatID}, adminChatIDs...)
+	}
+
+	ciChatIDs, err := parseChatIDList(os.Getenv("CI_WEBHOOK_CHAT_IDS"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid CI_WEBHOOK_CHAT_IDS: %v", err)
+	}
+	if len(ciChatIDs) == 0 && adminChatID != 0 {
+		ciChatIDs = []int64{adminChatID}
+	}
+	// Unverified payloads would let anyone post to the chats and, with
+	// CI_WEBHOOK_ANALYZE, prompt the AI.
+	if os.Getenv("CI_WEBHOOK_ADDR") != "" && os.Getenv("CI_WEBHOOK_SECRET") == "" {
+		return nil, fmt.Errorf("CI_WEBHOOK_SECRET is required with CI_WEBHOOK_ADDR")
+	}
+
+	startText, err := textSetting("START_TEXT")
+	if err != nil {
+		return nil, err
+	}
+	helpText, err := textSetting("HELP_TEXT")
+	if err != nil {
+		return nil, err
+	}
+	helpExtra, err := textSetting("HELP_EXTRA")
+	if err != nil {
+		return nil, err
+	}
+
+	var budget float64
+	if b := os.Getenv("SESSION_BUDGET_USD"); b != "" {
+		budget, err = strconv.ParseFloat(b, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid SESSION_BUDGET_USD %q: %v", b, err)
+		}
+	}
+
+	var userDailyTokens int64
+	if v := os.Getenv("USER_DAILY_TOKENS"); v != "" {
+		if userDailyTokens, err = strconv.ParseInt(v, 10, 64); err != nil || userDailyTokens < 0 {
+			return nil, fmt.Errorf("invalid USER_DAILY_TOKENS %q", v)
+		}
+	}
+	var userDailyUSD float64
+	if v := os.Getenv("USER_DAILY_USD"); v != "" {
+		if userDailyUSD, err = strconv.ParseFloat(v, 64); err != nil || userDailyUSD < 0 {
+			return nil, fmt.Errorf("invalid USER_DAILY_USD %q", v)
+		}
+	}
+
+	webhookListen := os.Getenv("WEBHOOK_LISTEN")
+	if webhookListen == "" {
+		webhookListen = ":8443"
+	}
+
+	guardrails, err := LoadGuardrailProfiles(os.Getenv("GUARDRAILS_FILE"))
+	if err != nil {
+		return nil, err
+	}
+	guardrailProfile := os.Getenv("GUARDRAIL_PROFILE")
+	if guardrailProfile == "" {
+		guardrailProfile = "none"
+	}
+	if !slices.ContainsFunc(guardrails, func(p GuardrailProfile) bool { return p.Name == guardrailProfile }) {
+		return nil, fmt.Errorf("unknown GUARDRAIL_PROFILE %q", guardrailProfile)
+	}
+
+	personas, err := LoadPersonas(os.Getenv("PERSONAS_FILE"))
+	if err != nil {
+		return nil, err
+	}
+
+	transcriptDays := 30
+	if d := os.Getenv("TRANSCRIPT_RETENTION_DAYS"); d != "" {
+		transcriptDays, err = strconv.Atoi(d)
+		if err != nil || transcriptDays < 0 {
+			return nil, fmt.Errorf("invalid TRANSCRIPT_RETENTION_DAYS %q", d)
+		}
+	}
+
+	resultStrategy := os.Getenv("RESULT_STRATEGY")
+	if resultStrategy == "" {
+		resultStrategy = ResultFull
+	}
+	if !slices.Contains(resultStrategies, resultStrategy) {
+		return nil, fmt.Errorf("invalid RESULT_STRATEGY %q (want %s)", resultStrategy, strings.Join(resultStrategies, ", "))
+	}
+	resultMaxBytes := 4000
+	if v := os.Getenv("RESULT_MAX_BYTES"); v != "" {
+		resultMaxBytes, err = strconv.Atoi(v)
+		if err != nil || resultMaxBytes <= 0 {
+			return nil, fmt.Errorf("invalid RESULT_MAX_BYTES %q", v)
+		}
+	}
+
+	geminiMaxHistory := 200000
+	if v := os.Getenv("GEMINI_HISTORY_TOKENS"); v != "" {
+		geminiMaxHistory, err = strconv.Atoi(v)
+		if err != nil || geminiMaxHistory < 0 {
+			return nil, fmt.Errorf("invalid GEMINI_HISTORY_TOKENS %q", v)
+		}
+	}
+
+	geminiSaveBytes := 256 << 10
+	if v := os.Getenv("GEMINI_HISTORY_SAVE_BYTES"); v != "" {
+		if geminiSaveBytes, err = strconv.Atoi(v); err != nil || geminiSaveBytes < 0 {
+			return nil, fmt.Errorf("invalid GEMINI_HISTORY_SAVE_BYTES %q", v)
+		}
+	}
+
+	geminiSafety, err := ParseGeminiSafety(os.Getenv("GEMINI_SAFETY"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid GEMINI_SAFETY: %v", err)
+	}
+
+	circuitFailures := 5
+	if v := os.Getenv("CIRCUIT_FAILURES"); v != "" {
+		circuitFailures, err = strconv.Atoi(v)
+		if err != nil || circuitFailures < 0 {
+			return nil, fmt.Errorf("invalid CIRCUIT_FAILURES %q", v)
+		}
+	}
+	circuitCooldown := 2 * time.Minute
+	if v := os.Getenv("CIRCUIT_COOLDOWN"); v != "" {
+		circuitCooldown, err = time.ParseDuration(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIRCUIT_COOLDOWN %q: %v", v, err)
+		}
+	}
+
+	var promptExpiry time.Duration
+	if v := os.Getenv("PROMPT_EXPIRY"); v != "" {
+		promptExpiry, err = time.ParseDuration(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid PROMPT_EXPIRY %q: %v", v, err)
+		}
+	}
+
+	var alivePeriod time.Duration
+	if v := os.Getenv("ALIVE_PERIOD"); v != "" {
+		alivePeriod, err = time.ParseDuration(v)
+		if err != nil || alivePeriod < 0 {
+			return nil, fmt.Errorf("invalid ALIVE_PERIOD %q", v)
+		}
+	}
+	if alivePeriod > 0 && adminChatID == 0 {
+		return nil, fmt.Errorf("ALIVE_PERIOD needs ADMIN_CHAT_ID or ADMIN_CHAT_IDS")
+	}
+
+	groupSessions := os.Getenv("GROUP_SESSIONS")
+	if groupSessions != "" && groupSessions != "shared" && groupSessions != "per_user" {
+		return nil, fmt.Errorf("invalid GROUP_SESSIONS %q (want shared or per_user)", groupSessions)
+	}
+
+	var approvalSLA time.Duration
+	if v := os.Getenv("APPROVAL_SLA"); v != "" {
+		approvalSLA, err = time.ParseDuration(v)
+		if err != nil || approvalSLA < 0 {
+			return nil, fmt.Errorf("invalid APPROVAL_SLA %q", v)
+		}
+	}
+	escalateTo, err := parseChatIDList(os.Getenv("APPROVAL_ESCALATE_CHAT_IDS"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid APPROVAL_ESCALATE_CHAT_IDS: %v", err)
+	}
+	if len(escalateTo) == 0 && adminChatID != 0 {
+		escalateTo = []int64{adminChatID}
+	}
+	if approvalSLA > 0 && len(escalateTo) == 0 {
+		return nil, fmt.Errorf("APPROVAL_SLA needs APPROVAL_ESCALATE_CHAT_IDS or ADMIN_CHAT_ID")
+	}
+
+	typingInterval := 4 * time.Second
+	if v := os.Getenv("TYPING_INTERVAL"); v != "" {
+		if v == "off" || v == "0" {
+			typingInterval = 0
+		} else if typingInterval, err = time.ParseDuration(v); err != nil || typingInterval < time.Second {
+			return nil, fmt.Errorf("invalid TYPING_INTERVAL %q (want a duration of at least 1s, or 0/off)", v)
+		}
+	}
+
+	streamInterval := 1500 * time.Millisecond
+	if v := os.Getenv("STREAM_INTERVAL"); v != "" {
+		if v == "off" || v == "0" {
+			streamInterval = 0
+		} else if streamInterval, err = time.ParseDuration(v); err != nil || streamInterval < time.Second {
+			return nil, fmt.Errorf("invalid STREAM_INTERVAL %q (want a duration of at least 1s, or 0/off)", v)
+		}
+	}
+
+	replyLanguage := strings.TrimSpace(os.Getenv("REPLY_LANGUAGE"))
+	switch strings.ToLower(replyLanguage) {
+	case "", "auto":
+		replyLanguage = "auto"
+	case "off", "false", "none":
+		replyLanguage = "off"
+	}
+
+	codeFileBytes := 3000
+	if v := os.Getenv("CODE_FILE_BYTES"); v != "" {
+		if codeFileBytes, err = strconv.Atoi(v); err != nil || codeFileBytes < 0 {
+			return nil, fmt.Errorf("invalid CODE_FILE_BYTES %q", v)
+		}
+	}
+
+	chatWorkDirs := os.Getenv("CHAT_WORKDIRS") != "false"
+	var chatDiskQuota int64
+	if v := os.Getenv("CHAT_DISK_QUOTA"); v != "" {
+		if chatDiskQuota, err = strconv.ParseInt(v, 10, 64); err != nil || chatDiskQuota < 0 {
+			return nil, fmt.Errorf("invalid CHAT_DISK_QUOTA %q", v)
+		}
+		if chatDiskQuota > 0 && !chatWorkDirs {
+			return nil, fmt.Errorf("CHAT_DISK_QUOTA needs CHAT_WORKDIRS")
+		}
+	}
+
+	var mediaMaxBytes int64 = telegramDownloadLimit
+	if v := os.Getenv("MEDIA_MAX_BYTES"); v != "" {
+		if mediaMaxBytes, err = strconv.ParseInt(v, 10, 64); err != nil || mediaMaxBytes <= 0 {
+			return nil, fmt.Errorf("invalid MEDIA_MAX_BYTES %q", v)
+		}
+	}
+	var fetchMaxBytes int64 = 1 << 30
+	if v := os.Getenv("FETCH_MAX_BYTES"); v != "" {
+		if fetchMaxBytes, err = strconv.ParseInt(v, 10, 64); err != nil || fetchMaxBytes <= 0 {
+			return nil, fmt.Errorf("invalid FETCH_MAX_BYTES %q", v)
+		}
+	}
+
+	ttsMaxChars := 1500
+	if v := os.Getenv("TTS_MAX_CHARS"); v != "" {
+		if ttsMaxChars, err = strconv.Atoi(v); err != nil || ttsMaxChars < 0 {
+			return nil, fmt.Errorf("invalid TTS_MAX_CHARS %q", v)
+		}
+	}
+
+	ffmpegCmd := os.Getenv("FFMPEG_CMD")
+	if ffmpegCmd == "" {
+		ffmpegCmd = "ffmpeg"
+	}
+	videoKeyframes := 3
+	if v := os.Getenv("VIDEO_KEYFRAMES"); v != "" {
+		if videoKeyframes, err = strconv.Atoi(v); err != nil || videoKeyframes < 0 || videoKeyframes > maxVideoKeyframes {
+			return nil, fmt.Errorf("invalid VIDEO_KEYFRAMES %q (want 0 to %d)", v, maxVideoKeyframes)
+		}
+	}
+
+	var usageReport *Schedule
+	reportChatID := adminChatID
+	if expr := os.Getenv("USAGE_REPORT_SCHEDULE"); expr != "" {
+		if usageReport, err = ParseSchedule(expr); err != nil {
+			return nil, fmt.Errorf("invalid USAGE_REPORT_SCHEDULE: %v", err)
+		}
+		if v := os.Getenv("USAGE_REPORT_CHAT_ID"); v != "" {
+			if reportChatID, err = strconv.ParseInt(strings.TrimSpace(v), 10, 64); err != nil {
+				return nil, fmt.Errorf("invalid USAGE_REPORT_CHAT_ID %q: %v", v, err)
+			}
+		}
+		if reportChatID == 0 {
+			return nil, fmt.Errorf("USAGE_REPORT_SCHEDULE needs USAGE_REPORT_CHAT_ID or ADMIN_CHAT_ID")
+		}
+	}
+
+	// The credential hygiene report goes to the admin chat, weekly unless
+	// set otherwise.
+	var hygieneReport *Schedule
+	if expr := os.Getenv("HYGIENE_REPORT_SCHEDULE"); adminChatID != 0 && expr != "off" {
+		if expr == "" {
+			expr = "0 9 * * 1"
+		}
+		if hygieneReport, err = ParseSchedule(expr); err != nil {
+			return nil, fmt.Errorf("invalid HYGIENE_REPORT_SCHEDULE: %v", err)
+		}
+	}
+
+	sendCoalesce := 1500 * time.Millisecond
+	if v := os.Getenv("SEND_COALESCE"); v != "" {
+		if sendCoalesce, err = time.ParseDuration(v); err != nil {
+			return nil, fmt.Errorf("invalid SEND_COALESCE %q: %v", v, err)
+		}
+	}
+
+	sendRetries := 3
+	if v := os.Getenv("SEND_RETRIES"); v != "" {
+		if sendRetries, err = strconv.Atoi(v); err != nil || sendRetries < 0 {
+			return nil, fmt.Errorf("invalid SEND_RETRIES %q", v)
+		}
+	}
+
+	sendPace := time.Second
+	if v := os.Getenv("SEND_PACE"); v != "" {
+		if sendPace, err = time.ParseDuration(v); err != nil {
+			return nil, fmt.Errorf("invalid SEND_PACE %q: %v", v, err)
+		}
+	}
+
+	maxConcurrent := 16
+	if v := os.Getenv("MAX_CONCURRENT"); v != "" {
+		if maxConcurrent, err = strconv.Atoi(v); err != nil || maxConcurrent < 0 {
+			return nil, fmt.Errorf("invalid MAX_CONCURRENT %q", v)
+		}
+	}
+
+	costRouting, err := costPolicyFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	shutdownTimeout := time.Minute
+	if v := os.Getenv("SHUTDOWN_TIMEOUT"); v != "" {
+		if shutdownTimeout, err = time.ParseDuration(v); err != nil || shutdownTimeout < 0 {
+			return nil, fmt.Errorf("invalid SHUTDOWN_TIMEOUT %q", v)
+		}
+	}
+
 	return &Config{
-		TelegramToken:   token,
-		AllowedChatIDs:  allowed,
-		WorkDir:         workDir,
-		ClaudePath:      claudePath,
-		GeminiAPIKey:    os.Getenv("GEMINI_API_KEY"),
-		GeminiModel:     geminiModel,
-		DefaultProvider: defaultProvider,
-		CommandTimeout:  timeout,
-		AllowedTools:    allowedTools,
-		SkipPermissions: skipPerms,
-		SystemPrompt:    systemPrompt,
-		MaxToolRounds:   maxRounds,
-		WhisperCmd:      whisperCmd,
-		GitSSHKey:       os.Getenv("GIT_SSH_KEY"),
-		GitlabToken:     os.Getenv("GITLAB_TOKEN"),
-		GitUserName:     os.Getenv("GIT_USER_NAME"),
-		GitUserEmail:    os.Getenv("GIT_USER_EMAIL"),
-		NgrokToken:      os.Getenv("NGROK_AUTHTOKEN"),
+		TelegramToken:      token,
+		AllowedChatIDs:     allowed,
+		WorkDir:            workDir,
+		DataDir:            dataDir,
+		ClaudePath:         claudePath,
+		ClaudeModel:        os.Getenv("CLAUDE_MODEL"),
+		GeminiAPIKey:       os.Getenv("GEMINI_API_KEY"),
+		GeminiModel:        geminiModel,
+		OpenRouterAPIKey:   os.Getenv("OPENROUTER_API_KEY"),
+		OpenRouterModel:    os.Getenv("OPENROUTER_MODEL"),
+		AzureEndpoint:      os.Getenv("AZURE_OPENAI_ENDPOINT"),
+		AzureDeployment:    os.Getenv("AZURE_OPENAI_DEPLOYMENT"),
+		AzureAPIVersion:    os.Getenv("AZURE_OPENAI_API_VERSION"),
+		AzureAPIKey:        os.Getenv("AZURE_OPENAI_API_KEY"),
+		BedrockRegion:      bedrockRegion,
+		BedrockModelID:     os.Getenv("BEDROCK_MODEL_ID"),
+		AWSAccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		AWSSecretKey:       os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		AWSSessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+		DefaultProvider:    defaultProvider,
+		CommandTimeout:     timeout,
+		AllowedTools:       allowedTools,
+		SkipPermissions:    skipPerms,
+		ToolTranscript:     os.Getenv("TOOL_TRANSCRIPT") != "false",
+		SystemPrompt:       systemPrompt,
+		Personas:           personas,
+		MaxToolRounds:      maxRounds,
+		WhisperCmd:         whisperCmd,
+		WhisperBackend:     whisperBackend,
+		WhisperAPIURL:      whisperAPIURL,
+		WhisperAPIKey:      os.Getenv("WHISPER_API_KEY"),
+		WhisperModel:       os.Getenv("WHISPER_MODEL"),
+		WhisperLanguage:    strings.ToLower(strings.TrimSpace(os.Getenv("WHISPER_LANGUAGE"))),
+		GitSSHKey:          os.Getenv("GIT_SSH_KEY"),
+		GitlabToken:        os.Getenv("GITLAB_TOKEN"),
+		GitlabURL:          gitlabURL,
+		GitUserName:        os.Getenv("GIT_USER_NAME"),
+		GitUserEmail:       os.Getenv("GIT_USER_EMAIL"),
+		GitRepos:           gitRepos,
+		NgrokToken:         os.Getenv("NGROK_AUTHTOKEN"),
+		AdminChatID:        adminChatID,
+		AdminChatIDs:       adminChatIDs,
+		CostRouting:        costRouting,
+		FreezeOnBlock:      os.Getenv("FREEZE_ON_BLOCK") == "true",
+		AlivePeriod:        alivePeriod,
+		PerUserSessions:    groupSessions == "per_user",
+		ReadOnly:           os.Getenv("READ_ONLY") == "true",
+		CIWebhookAddr:      os.Getenv("CI_WEBHOOK_ADDR"),
+		CIWebhookSecret:    os.Getenv("CI_WEBHOOK_SECRET"),
+		CIWebhookChatIDs:   ciChatIDs,
+		CIWebhookAnalyze:   os.Getenv("CI_WEBHOOK_ANALYZE") == "true",
+		StartText:          startText,
+		HelpText:           helpText,
+		HelpExtra:          helpExtra,
+		PluginDir:          os.Getenv("PLUGIN_DIR"),
+		EventWebhookURL:    os.Getenv("EVENT_WEBHOOK_URL"),
+		EventWebhookSecret: os.Getenv("EVENT_WEBHOOK_SECRET"),
+		SessionBudgetUSD:   budget,
+		UserDailyTokens:    userDailyTokens,
+		UserDailyUSD:       userDailyUSD,
+		WebhookURL:         os.Getenv("WEBHOOK_URL"),
+		WebhookListen:      webhookListen,
+		WebhookTLSCert:     os.Getenv("WEBHOOK_TLS_CERT"),
+		WebhookTLSKey:      os.Getenv("WEBHOOK_TLS_KEY"),
+		HealthAddr:         os.Getenv("HEALTH_ADDR"),
+		MetricsAddr:        os.Getenv("METRICS_ADDR"),
+		TracesExporter:     os.Getenv("OTEL_TRACES_EXPORTER"),
+		GuardrailProfiles:  guardrails,
+		GuardrailProfile:   guardrailProfile,
+		LogLevel:           os.Getenv("LOG_LEVEL"),
+		LogFormat:          os.Getenv("LOG_FORMAT"),
+		TranscriptDir:      os.Getenv("TRANSCRIPT_DIR"),
+		TranscriptDays:     transcriptDays,
+		TestGateCommand:    os.Getenv("TEST_GATE_COMMAND"),
+		EnvSnapshot:        os.Getenv("ENV_SNAPSHOT") != "false",
+		ResultStrategy:     resultStrategy,
+		ResultMaxBytes:     resultMaxBytes,
+		OutputDiff:         os.Getenv("OUTPUT_DIFF") == "true",
+		GeminiMaxHistory:   geminiMaxHistory,
+		GeminiSummarize:    os.Getenv("GEMINI_HISTORY_SUMMARIZE") == "true",
+		GeminiSaveBytes:    geminiSaveBytes,
+		GeminiSafety:       geminiSafety,
+		CircuitFailures:    circuitFailures,
+		CircuitCooldown:    circuitCooldown,
+		CircuitFallback:    os.Getenv("CIRCUIT_FALLBACK") == "true",
+		PromptExpiry:       promptExpiry,
+		ApprovalSLA:        approvalSLA,
+		ApprovalEscalation: escalateTo,
+		TypingInterval:     typingInterval,
+		StreamInterval:     streamInterval,
+		ReplyLanguage:      replyLanguage,
+		CodeFileBytes:      codeFileBytes,
+		MediaMaxBytes:      mediaMaxBytes,
+		ChatWorkDirs:       chatWorkDirs,
+		ChatDiskQuota:      chatDiskQuota,
+		TTSCmd:             os.Getenv("TTS_CMD"),
+		TTSAPIURL:          os.Getenv("TTS_API_URL"),
+		TTSAPIKey:          os.Getenv("TTS_API_KEY"),
+		TTSModel:           os.Getenv("TTS_MODEL"),
+		TTSVoice:           os.Getenv("TTS_VOICE"),
+		TTSMaxChars:        ttsMaxChars,
+		FFmpegCmd:          ffmpegCmd,
+		VideoKeyframes:     videoKeyframes,
+		FetchMaxBytes:      fetchMaxBytes,
+		UsageReport:        usageReport,
+		UsageReportChatID:  reportChatID,
+		HygieneReport:      hygieneReport,
+		SendCoalesce:       sendCoalesce,
+		SendRetries:        sendRetries,
+		SendPace:           sendPace,
+		MaxConcurrent:      maxConcurrent,
+		ShutdownTimeout:    shutdownTimeout,
 	}, nil
 }
+
+// textSetting reads a multi-line text override from NAME_FILE (a path) or,
+// failing that, from NAME with literal "\n" sequences turned into newlines.
+func textSetting(name string) (string, error) {
+	if path := os.Getenv(name + "_FILE"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("read %s_FILE: %v", name, err)
+		}
+		return strings.TrimSpace(string(data)), nil
+	}
+	return strings.ReplaceAll(os.Getenv(name), `\n`, "\n"), nil
+}
+
+// parseChatIDList parses a comma-separated list of chat IDs, skipping blanks.
+func parseChatIDList(raw string) ([]int64, error) {
+	var ids []int64
+	for _, s := range strings.Split(raw, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		id, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid chat ID %q: %v", s, err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// chatIDSet turns a chat ID list into a set.
+func chatIDSet(ids []int64) map[int64]bool {
+	set := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		set[id] = true
+	}
+	return set
+}
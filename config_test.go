@@ -0,0 +1,127 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestAdminChatIDs(t *testing.T) {
+	t.Setenv("TELEGRAM_BOT_TOKEN", "x")
+	t.Setenv("ALLOWED_CHAT_IDS", "1")
+	t.Setenv("DATA_DIR", t.TempDir())
+	tests := []struct {
+		admin, admins string
+		wantAlert     int64
+		want          []int64
+	}{
+		{"", "", 0, nil},
+		{"5", "", 5, []int64{5}},
+		{"", "7, 8", 7, []int64{7, 8}},
+		{"5", "7,8", 5, []int64{5, 7, 8}},
+		{"8", "7,8", 8, []int64{7, 8}},
+	}
+	for _, tt := range tests {
+		t.Setenv("ADMIN_CHAT_ID", tt.admin)
+		t.Setenv("ADMIN_CHAT_IDS", tt.admins)
+		cfg, err := LoadConfig("")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.AdminChatID != tt.wantAlert || !slices.Equal(cfg.AdminChatIDs, tt.want) {
+			t.Errorf("%q/%q: alerts to %d, admins %v", tt.admin, tt.admins, cfg.AdminChatID, cfg.AdminChatIDs)
+		}
+	}
+
+	t.Setenv("ADMIN_CHAT_IDS", "7,x")
+	if _, err := LoadConfig(""); err == nil {
+		t.Error("invalid ADMIN_CHAT_IDS accepted")
+	}
+
+	h := &Handlers{admins: chatIDSet([]int64{7, 8})}
+	if !h.isAdmin(8) || h.isAdmin(1) {
+		t.Error("isAdmin does not follow ADMIN_CHAT_IDS")
+	}
+}
+
+func TestCIWebhookSecretRequired(t *testing.T) {
+	t.Setenv("TELEGRAM_BOT_TOKEN", "x")
+	t.Setenv("ALLOWED_CHAT_IDS", "1")
+	t.Setenv("DATA_DIR", t.TempDir())
+	t.Setenv("CI_WEBHOOK_ADDR", ":8090")
+	t.Setenv("CI_WEBHOOK_SECRET", "")
+	if _, err := LoadConfig(""); err == nil {
+		t.Error("CI_WEBHOOK_ADDR accepted without CI_WEBHOOK_SECRET")
+	}
+	t.Setenv("CI_WEBHOOK_SECRET", "s3cret")
+	if _, err := LoadConfig(""); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestTextSetting(t *testing.T) {
+	t.Setenv("START_TEXT", `Hi!\nAsk away.`)
+	t.Setenv("START_TEXT_FILE", "")
+	if got, err := textSetting("START_TEXT"); err != nil || got != "Hi!\nAsk away." {
+		t.Errorf("inline = %q, %v", got, err)
+	}
+
+	path := filepath.Join(t.TempDir(), "start.txt")
+	os.WriteFile(path, []byte("\nFrom a file.\n"), 0o600)
+	t.Setenv("START_TEXT_FILE", path)
+	if got, err := textSetting("START_TEXT"); err != nil || got != "From a file." {
+		t.Errorf("file = %q, %v", got, err)
+	}
+
+	t.Setenv("START_TEXT_FILE", filepath.Join(t.TempDir(), "missing.txt"))
+	if _, err := textSetting("START_TEXT"); err == nil {
+		t.Error("missing START_TEXT_FILE accepted")
+	}
+}
+
+func TestTypingInterval(t *testing.T) {
+	t.Setenv("TELEGRAM_BOT_TOKEN", "x")
+	t.Setenv("ALLOWED_CHAT_IDS", "1")
+	t.Setenv("DATA_DIR", t.TempDir())
+	for _, tc := range []struct {
+		value string
+		want  time.Duration
+		ok    bool
+	}{
+		{"", 4 * time.Second, true},
+		{"2s", 2 * time.Second, true},
+		{"off", 0, true},
+		{"0", 0, true},
+		{"500ms", 0, false},
+		{"often", 0, false},
+	} {
+		t.Setenv("TYPING_INTERVAL", tc.value)
+		cfg, err := LoadConfig("")
+		if (err == nil) != tc.ok {
+			t.Errorf("%q: err = %v", tc.value, err)
+			continue
+		}
+		if err == nil && cfg.TypingInterval != tc.want {
+			t.Errorf("%q: TypingInterval = %s, want %s", tc.value, cfg.TypingInterval, tc.want)
+		}
+	}
+}
+
+func TestChatWorkDirsDefaultOn(t *testing.T) {
+	t.Setenv("TELEGRAM_BOT_TOKEN", "x")
+	t.Setenv("ALLOWED_CHAT_IDS", "1")
+	t.Setenv("DATA_DIR", t.TempDir())
+	t.Setenv("CHAT_DISK_QUOTA", "")
+	for value, want := range map[string]bool{"": true, "false": false, "true": true} {
+		t.Setenv("CHAT_WORKDIRS", value)
+		cfg, err := LoadConfig("")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.ChatWorkDirs != want {
+			t.Errorf("CHAT_WORKDIRS=%q: ChatWorkDirs = %v", value, cfg.ChatWorkDirs)
+		}
+	}
+}
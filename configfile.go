@@ -0,0 +1,291 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// A config file passed with --config holds the same settings as the
+// environment, as YAML keys named after the variables in lower case:
+//
+//	telegram_bot_token: "123:abc"
+//	allowed_chat_ids: [111, 222]
+//	command_timeout: 10m
+//
+// Variables set in the environment override the file.
+
+type configKind int
+
+const (
+	configString configKind = iota
+	configInt
+	configFloat
+	configBool
+	configDuration
+	configList // YAML list or comma-separated string
+)
+
+// configKeys lists the variables LoadConfig reads and their types.
+// Durations that also accept "off" are strings.
+var configKeys = map[string]configKind{
+	"TELEGRAM_BOT_TOKEN":         configString,
+	"ALLOWED_CHAT_IDS":           configList,
+	"WORK_DIR":                   configString,
+	"DATA_DIR":                   configString,
+	"CLAUDE_PATH":                configString,
+	"CLAUDE_MODEL":               configString,
+	"GEMINI_API_KEY":             configString,
+	"GEMINI_MODEL":               configString,
+	"OPENROUTER_API_KEY":         configString,
+	"OPENROUTER_MODEL":           configString,
+	"AZURE_OPENAI_ENDPOINT":      configString,
+	"AZURE_OPENAI_DEPLOYMENT":    configString,
+	"AZURE_OPENAI_API_VERSION":   configString,
+	"AZURE_OPENAI_API_KEY":       configString,
+	"BEDROCK_REGION":             configString,
+	"BEDROCK_MODEL_ID":           configString,
+	"AWS_REGION":                 configString,
+	"AWS_ACCESS_KEY_ID":          configString,
+	"AWS_SECRET_ACCESS_KEY":      configString,
+	"AWS_SESSION_TOKEN":          configString,
+	"DEFAULT_PROVIDER":           configString,
+	"COMMAND_TIMEOUT":            configDuration,
+	"ALLOWED_TOOLS":              configList,
+	"SKIP_PERMISSIONS":           configBool,
+	"TOOL_TRANSCRIPT":            configBool,
+	"SYSTEM_PROMPT":              configString,
+	"PERSONAS_FILE":              configString,
+	"MAX_TOOL_ROUNDS":            configInt,
+	"WHISPER_CMD":                configString,
+	"WHISPER_BACKEND":            configString,
+	"WHISPER_API_URL":            configString,
+	"WHISPER_API_KEY":            configString,
+	"WHISPER_MODEL":              configString,
+	"WHISPER_LANGUAGE":           configString,
+	"GIT_SSH_KEY":                configString,
+	"GITLAB_TOKEN":               configString,
+	"GITLAB_URL":                 configString,
+	"GIT_USER_NAME":              configString,
+	"GIT_USER_EMAIL":             configString,
+	"GIT_REPOS":                  configList,
+	"NGROK_AUTHTOKEN":            configString,
+	"ADMIN_CHAT_ID":              configInt,
+	"ADMIN_CHAT_IDS":             configList,
+	"FREEZE_ON_BLOCK":            configBool,
+	"ALIVE_PERIOD":               configDuration,
+	"GROUP_SESSIONS":             configString,
+	"READ_ONLY":                  configBool,
+	"CI_WEBHOOK_ADDR":            configString,
+	"CI_WEBHOOK_SECRET":          configString,
+	"CI_WEBHOOK_CHAT_IDS":        configList,
+	"CI_WEBHOOK_ANALYZE":         configBool,
+	"START_TEXT":                 configString,
+	"START_TEXT_FILE":            configString,
+	"HELP_TEXT":                  configString,
+	"HELP_TEXT_FILE":             configString,
+	"HELP_EXTRA":                 configString,
+	"HELP_EXTRA_FILE":            configString,
+	"PLUGIN_DIR":                 configString,
+	"EVENT_WEBHOOK_URL":          configString,
+	"EVENT_WEBHOOK_SECRET":       configString,
+	"SESSION_BUDGET_USD":         configFloat,
+	"DAILY_BUDGET_USD":           configFloat,
+	"USER_DAILY_TOKENS":          configInt,
+	"USER_DAILY_USD":             configFloat,
+	"COST_ROUTING_MODELS":        configList,
+	"COST_ROUTING_HOURS":         configString,
+	"COST_ROUTING_BELOW_USD":     configFloat,
+	"WEBHOOK_URL":                configString,
+	"WEBHOOK_LISTEN":             configString,
+	"WEBHOOK_TLS_CERT":           configString,
+	"WEBHOOK_TLS_KEY":            configString,
+	"HEALTH_ADDR":                configString,
+	"METRICS_ADDR":               configString,
+	"OTEL_TRACES_EXPORTER":       configString,
+	"GUARDRAILS_FILE":            configString,
+	"GUARDRAIL_PROFILE":          configString,
+	"LOG_LEVEL":                  configString,
+	"LOG_FORMAT":                 configString,
+	"TRANSCRIPT_DIR":             configString,
+	"TRANSCRIPT_RETENTION_DAYS":  configInt,
+	"TEST_GATE_COMMAND":          configString,
+	"ENV_SNAPSHOT":               configBool,
+	"RESULT_STRATEGY":            configString,
+	"RESULT_MAX_BYTES":           configInt,
+	"OUTPUT_DIFF":                configBool,
+	"GEMINI_HISTORY_TOKENS":      configInt,
+	"GEMINI_HISTORY_SUMMARIZE":   configBool,
+	"GEMINI_HISTORY_SAVE_BYTES":  configInt,
+	"GEMINI_SAFETY":              configString,
+	"CIRCUIT_FAILURES":           configInt,
+	"CIRCUIT_COOLDOWN":           configDuration,
+	"CIRCUIT_FALLBACK":           configBool,
+	"PROMPT_EXPIRY":              configDuration,
+	"APPROVAL_SLA":               configDuration,
+	"APPROVAL_ESCALATE_CHAT_IDS": configList,
+	"TYPING_INTERVAL":            configString,
+	"STREAM_INTERVAL":            configString,
+	"REPLY_LANGUAGE":             configString,
+	"CODE_FILE_BYTES":            configInt,
+	"MEDIA_MAX_BYTES":            configInt,
+	"FETCH_MAX_BYTES":            configInt,
+	"CHAT_WORKDIRS":              configBool,
+	"CHAT_DISK_QUOTA":            configInt,
+	"TTS_CMD":                    configString,
+	"TTS_API_URL":                configString,
+	"TTS_API_KEY":                configString,
+	"TTS_MODEL":                  configString,
+	"TTS_VOICE":                  configString,
+	"TTS_MAX_CHARS":              configInt,
+	"FFMPEG_CMD":                 configString,
+	"VIDEO_KEYFRAMES":            configInt,
+	"USAGE_REPORT_SCHEDULE":      configString,
+	"USAGE_REPORT_CHAT_ID":       configInt,
+	"HYGIENE_REPORT_SCHEDULE":    configString,
+	"SEND_COALESCE":              configDuration,
+	"SEND_RETRIES":               configInt,
+	"SEND_PACE":                  configDuration,
+	"MAX_CONCURRENT":             configInt,
+	"SHUTDOWN_TIMEOUT":           configDuration,
+}
+
+// configFileValue is a setting read from the config file.
+type configFileValue struct {
+	Key   string // as written in the file
+	Line  int
+	Value string // in the form the environment variable takes
+}
+
+// readConfigFile parses a YAML config file into environment variable
+// values, checking every key and value type.
+func readConfigFile(path string) (map[string]configFileValue, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read config file: %v", err)
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	values := make(map[string]configFileValue)
+	if len(doc.Content) == 0 {
+		return values, nil
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("%s:%d: want a mapping of settings", path, root.Line)
+	}
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		k, v := root.Content[i], root.Content[i+1]
+		name := strings.ToUpper(k.Value)
+		kind, ok := configKeys[name]
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: unknown key %q", path, k.Line, k.Value)
+		}
+		if _, dup := values[name]; dup {
+			return nil, fmt.Errorf("%s:%d: key %q set twice", path, k.Line, k.Value)
+		}
+		value, err := configValue(kind, v)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: key %q: %v", path, v.Line, k.Value, err)
+		}
+		values[name] = configFileValue{Key: k.Value, Line: v.Line, Value: value}
+	}
+	return values, nil
+}
+
+// configValue converts a YAML value to an environment variable value.
+func configValue(kind configKind, n *yaml.Node) (string, error) {
+	if kind == configList && n.Kind == yaml.SequenceNode {
+		items := make([]string, 0, len(n.Content))
+		for _, item := range n.Content {
+			if item.Kind != yaml.ScalarNode {
+				return "", errors.New("list items must be plain values")
+			}
+			items = append(items, item.Value)
+		}
+		return strings.Join(items, ","), nil
+	}
+	if n.Kind != yaml.ScalarNode {
+		if kind == configList {
+			return "", errors.New("want a list or a comma-separated string")
+		}
+		return "", errors.New("want a plain value, not a list or mapping")
+	}
+	v := n.Value
+	switch kind {
+	case configInt:
+		if _, err := strconv.ParseInt(v, 10, 64); err != nil {
+			return "", fmt.Errorf("want an integer, got %q", v)
+		}
+	case configFloat:
+		if _, err := strconv.ParseFloat(v, 64); err != nil {
+			return "", fmt.Errorf("want a number, got %q", v)
+		}
+	case configBool:
+		if v != "true" && v != "false" {
+			return "", fmt.Errorf("want true or false, got %q", v)
+		}
+	case configDuration:
+		if _, err := time.ParseDuration(v); err != nil {
+			return "", fmt.Errorf("want a duration such as 30s or 5m, got %q", v)
+		}
+	}
+	return v, nil
+}
+
+// fileEnv is what the config file last put in the environment, so that a
+// reload can tell the file's old values from real environment variables.
+var (
+	fileEnvMu sync.Mutex
+	fileEnv   = make(map[string]string)
+)
+
+// applyConfigFile reads the config file into the environment, leaving
+// variables that are already set alone.
+func applyConfigFile(path string) (map[string]configFileValue, error) {
+	values, err := readConfigFile(path)
+	if err != nil {
+		return nil, err
+	}
+	fileEnvMu.Lock()
+	defer fileEnvMu.Unlock()
+	for name, value := range fileEnv {
+		if os.Getenv(name) == value {
+			os.Unsetenv(name)
+		}
+	}
+	clear(fileEnv)
+	for name, v := range values {
+		if os.Getenv(name) != "" {
+			delete(values, name)
+			continue
+		}
+		if err := os.Setenv(name, v.Value); err != nil {
+			return nil, fmt.Errorf("%s:%d: key %q: %v", path, v.Line, v.Key, err)
+		}
+		fileEnv[name] = v.Value
+	}
+	return values, nil
+}
+
+var configNameRe = regexp.MustCompile(`[A-Z][A-Z0-9_]+`)
+
+// nameConfigKey points an error about a variable at the config file key
+// that set it.
+func nameConfigKey(err error, path string, values map[string]configFileValue) error {
+	for _, name := range configNameRe.FindAllString(err.Error(), -1) {
+		if v, ok := values[name]; ok {
+			return fmt.Errorf("%s:%d: key %q: %w", path, v.Line, v.Key, err)
+		}
+	}
+	return err
+}
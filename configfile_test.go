@@ -0,0 +1,98 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeConfigFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestReadConfigFile(t *testing.T) {
+	path := writeConfigFile(t, `
+telegram_bot_token: "123:abc"
+allowed_chat_ids: [111, -222]
+git_repos: a/b, c/d
+command_timeout: 10m
+skip_permissions: true
+session_budget_usd: 2.5
+system_prompt: |
+  Be brief.
+  Use metric units.
+`)
+	values, err := readConfigFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"TELEGRAM_BOT_TOKEN": "123:abc",
+		"ALLOWED_CHAT_IDS":   "111,-222",
+		"GIT_REPOS":          "a/b, c/d",
+		"COMMAND_TIMEOUT":    "10m",
+		"SKIP_PERMISSIONS":   "true",
+		"SESSION_BUDGET_USD": "2.5",
+		"SYSTEM_PROMPT":      "Be brief.\nUse metric units.\n",
+	}
+	if len(values) != len(want) {
+		t.Errorf("got %d values, want %d", len(values), len(want))
+	}
+	for name, v := range want {
+		if values[name].Value != v {
+			t.Errorf("%s = %q, want %q", name, values[name].Value, v)
+		}
+	}
+}
+
+func TestReadConfigFileErrors(t *testing.T) {
+	tests := []struct {
+		content string
+		want    string
+	}{
+		{"telegram_token: x", `:1: unknown key "telegram_token"`},
+		{"max_tool_rounds: many", `:1: key "max_tool_rounds": want an integer`},
+		{"\ncommand_timeout: 10", `:2: key "command_timeout": want a duration`},
+		{"freeze_on_block: yes", `key "freeze_on_block": want true or false`},
+		{"work_dir: [a, b]", `key "work_dir": want a plain value`},
+		{"log_level: info\nLOG_LEVEL: debug", `key "LOG_LEVEL" set twice`},
+		{"- a\n- b", "want a mapping"},
+	}
+	for _, tt := range tests {
+		_, err := readConfigFile(writeConfigFile(t, tt.content))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%q: got error %v, want %q", tt.content, err, tt.want)
+		}
+	}
+}
+
+func TestApplyConfigFileEnvOverrides(t *testing.T) {
+	t.Setenv("CLAUDE_MODEL", "opus")
+	t.Setenv("GEMINI_MODEL", "")
+	path := writeConfigFile(t, "claude_model: haiku\ngemini_model: gemini-2.5-pro\n")
+	values, err := applyConfigFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := os.Getenv("CLAUDE_MODEL"); got != "opus" {
+		t.Errorf("CLAUDE_MODEL = %q, the environment should win", got)
+	}
+	if got := os.Getenv("GEMINI_MODEL"); got != "gemini-2.5-pro" {
+		t.Errorf("GEMINI_MODEL = %q, want the file value", got)
+	}
+	if _, ok := values["CLAUDE_MODEL"]; ok {
+		t.Error("overridden key still attributed to the file")
+	}
+
+	err = nameConfigKey(errors.New(`invalid GEMINI_MODEL "x"`), path, values)
+	if !strings.Contains(err.Error(), `config.yaml:2: key "gemini_model"`) {
+		t.Errorf("got %v", err)
+	}
+}
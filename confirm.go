@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+)
+
+// Commands the safeguard's warn tier flags (recursive rm, force pushes,
+// DROP TABLE...) are allowed, but their approval card has no Approve
+// button: the user runs them by replying with a confirmation phrase, so a
+// stray tap cannot.
+
+// confirmPhrase returns what the user must type to run cmd: the program
+// it runs, skipping sudo and leading VAR=value assignments.
+func confirmPhrase(cmd string) string {
+	fields := strings.Fields(cmd)
+	for _, f := range fields {
+		if f == "sudo" || (strings.Contains(f, "=") && !strings.HasPrefix(f, "-")) {
+			continue
+		}
+		return f
+	}
+	if len(fields) > 0 {
+		return fields[0]
+	}
+	return "yes"
+}
+
+// commandWarning returns why cmd needs a typed confirmation, or "".
+func (h *Handlers) commandWarning(cmd string) string {
+	if h.claude == nil || h.claude.safeguard == nil {
+		return ""
+	}
+	return h.claude.safeguard.Warning(cmd)
+}
+
+// wantsRawText reports whether the chat's next message is read as typed,
+// without reply context: an auth code, or a confirmation phrase, which in
+// privacy-mode groups can only arrive as a reply to the card.
+func (h *Handlers) wantsRawText(chatID int64) bool {
+	if h.logins.Has(chatID) {
+		return true
+	}
+	turn := h.approvals.Get(chatID)
+	return turn != nil && turn.Confirm != ""
+}
+
+// confirmTyped handles a message sent while a risky command waits for its
+// confirmation phrase: the exact phrase runs it, anything else is answered
+// with a reminder. The chat lock must be held.
+func (h *Handlers) confirmTyped(ctx context.Context, chatID int64, turn *PendingTurn, text string) {
+	if strings.TrimSpace(text) != turn.Confirm {
+		slog.Info("message blocked: confirmation phrase expected", "chat_id", chatID)
+		h.sender.Send(chatID, h.t(chatID, "approval.confirm_pending", turn.Confirm))
+		return
+	}
+	slog.Info("risky command confirmed by typed phrase", "chat_id", chatID)
+	user, _ := userFrom(ctx)
+	h.decideApproval(ctx, chatID, turn, true, "", turn.Cards[chatID], user.Name)
+}
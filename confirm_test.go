@@ -0,0 +1,16 @@
+package main
+
+import "testing"
+
+func TestConfirmPhrase(t *testing.T) {
+	for cmd, want := range map[string]string{
+		"rm -rf build":                "rm",
+		"sudo reboot":                 "reboot",
+		"KUBECONFIG=x kubectl delete": "kubectl",
+		"":                            "yes",
+	} {
+		if got := confirmPhrase(cmd); got != want {
+			t.Errorf("confirmPhrase(%q) = %q, want %q", cmd, got, want)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Cost routing sends calls to cheaper models during off-peak hours, or when
+// little of the daily budget is left, and back to the configured models
+// once neither applies:
+//
+//	COST_ROUTING_MODELS=claude=haiku,gemini=gemini-2.5-flash-lite
+//	COST_ROUTING_HOURS=22-7
+//	DAILY_BUDGET_USD=10
+//	COST_ROUTING_BELOW_USD=2
+//
+// Providers without a cheap model, and Azure and Bedrock whose models are
+// fixed by the deployment, are not routed.
+
+// CostPolicy is the parsed cost routing configuration.
+type CostPolicy struct {
+	Models    map[string]string // provider -> cheap model
+	FromHour  int               // cheap hours, local time, [FromHour, ToHour)
+	ToHour    int               // equal to FromHour when there are none
+	BudgetUSD float64           // spending across all chats per local day
+	BelowUSD  float64           // route when less than this is left
+}
+
+// costPolicyFromEnv reads the COST_ROUTING_* variables and
+// DAILY_BUDGET_USD. It returns nil when COST_ROUTING_MODELS is unset.
+func costPolicyFromEnv() (*CostPolicy, error) {
+	raw := os.Getenv("COST_ROUTING_MODELS")
+	if raw == "" {
+		return nil, nil
+	}
+	p := &CostPolicy{Models: make(map[string]string)}
+	for _, item := range strings.Split(raw, ",") {
+		provider, model, ok := strings.Cut(strings.TrimSpace(item), "=")
+		provider, model = strings.ToLower(strings.TrimSpace(provider)), strings.TrimSpace(model)
+		if !ok || model == "" {
+			return nil, fmt.Errorf("invalid COST_ROUTING_MODELS entry %q (want provider=model)", item)
+		}
+		switch provider {
+		case "claude", "gemini", "openrouter":
+			p.Models[provider] = model
+		default:
+			return nil, fmt.Errorf("invalid COST_ROUTING_MODELS provider %q (want claude, gemini or openrouter)", provider)
+		}
+	}
+
+	if v := os.Getenv("COST_ROUTING_HOURS"); v != "" {
+		from, to, ok := strings.Cut(v, "-")
+		var err1, err2 error
+		p.FromHour, err1 = strconv.Atoi(strings.TrimSpace(from))
+		p.ToHour, err2 = strconv.Atoi(strings.TrimSpace(to))
+		if !ok || err1 != nil || err2 != nil || p.FromHour < 0 || p.FromHour > 23 || p.ToHour < 0 || p.ToHour > 23 || p.FromHour == p.ToHour {
+			return nil, fmt.Errorf("invalid COST_ROUTING_HOURS %q (want start-end hours, e.g. 22-7)", v)
+		}
+	}
+	if v := os.Getenv("DAILY_BUDGET_USD"); v != "" {
+		budget, err := strconv.ParseFloat(v, 64)
+		if err != nil || budget <= 0 {
+			return nil, fmt.Errorf("invalid DAILY_BUDGET_USD %q", v)
+		}
+		p.BudgetUSD = budget
+		p.BelowUSD = budget / 5
+	}
+	if v := os.Getenv("COST_ROUTING_BELOW_USD"); v != "" {
+		below, err := strconv.ParseFloat(v, 64)
+		if err != nil || below < 0 {
+			return nil, fmt.Errorf("invalid COST_ROUTING_BELOW_USD %q", v)
+		}
+		p.BelowUSD = below
+	}
+	if p.FromHour == p.ToHour && p.BudgetUSD == 0 {
+		return nil, fmt.Errorf("COST_ROUTING_MODELS needs COST_ROUTING_HOURS or DAILY_BUDGET_USD")
+	}
+	return p, nil
+}
+
+// offPeak reports whether now falls in the cheap hours, which may wrap
+// past midnight.
+func (p *CostPolicy) offPeak(now time.Time) bool {
+	if p.FromHour == p.ToHour {
+		return false
+	}
+	h := now.Hour()
+	if p.FromHour < p.ToHour {
+		return h >= p.FromHour && h < p.ToHour
+	}
+	return h >= p.FromHour || h < p.ToHour
+}
+
+// reason says why calls go to the cheap models at now, given the day's
+// spending so far, or "" when they do not.
+func (p *CostPolicy) reason(now time.Time, spentUSD float64) string {
+	if p.BudgetUSD > 0 {
+		if left := p.BudgetUSD - spentUSD; left < p.BelowUSD {
+			return fmt.Sprintf("$%.2f of the $%.2f daily budget left", max(left, 0), p.BudgetUSD)
+		}
+	}
+	if p.offPeak(now) {
+		return fmt.Sprintf("off-peak hours %02d:00–%02d:00", p.FromHour, p.ToHour)
+	}
+	return ""
+}
+
+// CostRouter applies a CostPolicy and remembers whether routing is on, to
+// announce the switches.
+type CostRouter struct {
+	policy *CostPolicy
+	ledger *UsageLedger
+
+	mu     sync.Mutex
+	active string // reason routing is on; "" when off
+}
+
+func NewCostRouter(policy *CostPolicy, ledger *UsageLedger) *CostRouter {
+	return &CostRouter{policy: policy, ledger: ledger}
+}
+
+// Check evaluates the policy at now. changed is true when routing was
+// turned on or off since the previous check. A nil router never routes.
+func (r *CostRouter) Check(now time.Time) (reason string, changed bool) {
+	if r == nil || r.policy == nil {
+		return "", false
+	}
+	reason = r.Reason(now)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	changed = (reason == "") != (r.active == "")
+	r.active = reason
+	return reason, changed
+}
+
+// Reason says why calls go to the cheap models at now, or "" when they do
+// not.
+func (r *CostRouter) Reason(now time.Time) string {
+	if r == nil || r.policy == nil {
+		return ""
+	}
+	return r.policy.reason(now, r.ledger.SpentOn(now))
+}
+
+// Model returns the cheap model for provider, or "".
+func (r *CostRouter) Model(provider string) string {
+	if r == nil || r.policy == nil {
+		return ""
+	}
+	return r.policy.Models[provider]
+}
+
+// modelKey is the context key of a model override.
+type modelKey struct{}
+
+// withCostRouting returns ctx carrying provider's cheap model while the
+// cost policy applies. The provider clients read it with modelFrom.
+func (h *Handlers) withCostRouting(ctx context.Context, chatID int64, provider string) context.Context {
+	reason, changed := h.costRouter.Check(time.Now())
+	if changed {
+		if reason != "" {
+			slog.Info("cost routing on", "reason", reason)
+			h.notifyAdmin(h.t(h.adminChatID, "costrouting.on", reason))
+		} else {
+			slog.Info("cost routing off")
+			h.notifyAdmin(h.t(h.adminChatID, "costrouting.off"))
+		}
+	}
+	model := h.costRouter.Model(provider)
+	if reason == "" || model == "" {
+		return ctx
+	}
+	slog.Debug("cost routed call", "chat_id", chatID, "provider", provider, "model", model, "reason", reason)
+	return context.WithValue(ctx, modelKey{}, model)
+}
+
+// modelFrom returns the model override carried by ctx, or def.
+func modelFrom(ctx context.Context, def string) string {
+	if m, ok := ctx.Value(modelKey{}).(string); ok {
+		return m
+	}
+	return def
+}
+
+// costRoutingNote describes cost routing for /model, or "" when provider
+// is not routed now.
+func (h *Handlers) costRoutingNote(chatID int64, provider string) string {
+	reason := h.costRouter.Reason(time.Now())
+	model := h.costRouter.Model(provider)
+	if reason == "" || model == "" {
+		return ""
+	}
+	return h.t(chatID, "model.cost_routing", model, reason)
+}
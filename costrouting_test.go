@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCostPolicyFromEnv(t *testing.T) {
+	t.Setenv("COST_ROUTING_MODELS", "")
+	t.Setenv("COST_ROUTING_HOURS", "")
+	t.Setenv("DAILY_BUDGET_USD", "")
+	t.Setenv("COST_ROUTING_BELOW_USD", "")
+	if p, err := costPolicyFromEnv(); p != nil || err != nil {
+		t.Fatalf("unset: %v, %v", p, err)
+	}
+
+	t.Setenv("COST_ROUTING_MODELS", "Claude=haiku, gemini=gemini-2.5-flash-lite")
+	if _, err := costPolicyFromEnv(); err == nil {
+		t.Error("policy without hours or budget accepted")
+	}
+	t.Setenv("DAILY_BUDGET_USD", "10")
+	p, err := costPolicyFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Models["claude"] != "haiku" || p.BelowUSD != 2 {
+		t.Errorf("policy = %+v", p)
+	}
+
+	for name, value := range map[string]string{
+		"COST_ROUTING_MODELS": "azure=gpt-4o-mini",
+		"COST_ROUTING_HOURS":  "22",
+		"DAILY_BUDGET_USD":    "-1",
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv(name, value)
+			if _, err := costPolicyFromEnv(); err == nil {
+				t.Errorf("%s=%s accepted", name, value)
+			}
+		})
+	}
+}
+
+func TestCostPolicyReason(t *testing.T) {
+	p := &CostPolicy{FromHour: 22, ToHour: 7, BudgetUSD: 10, BelowUSD: 2}
+	at := func(hour int) time.Time { return time.Date(2026, 1, 1, hour, 30, 0, 0, time.Local) }
+	for hour, want := range map[int]bool{21: false, 22: true, 3: true, 7: false, 12: false} {
+		if got := p.reason(at(hour), 0) != ""; got != want {
+			t.Errorf("%02d:30 routed = %v, want %v", hour, got, want)
+		}
+	}
+	if r := p.reason(at(12), 8.5); !strings.Contains(r, "$1.50 of the $10.00") {
+		t.Errorf("low budget reason = %q", r)
+	}
+	if r := p.reason(at(12), 12); !strings.Contains(r, "$0.00") {
+		t.Errorf("overspent reason = %q", r)
+	}
+}
+
+func TestWithCostRouting(t *testing.T) {
+	ledger := NewUsageLedger(t.TempDir())
+	h := &Handlers{costRouter: NewCostRouter(&CostPolicy{Models: map[string]string{"gemini": "cheap"}, BudgetUSD: 1, BelowUSD: 0.5}, ledger)}
+
+	ctx := h.withCostRouting(context.Background(), 1, "gemini")
+	if m := modelFrom(ctx, "default"); m != "default" {
+		t.Errorf("routed with budget left: %q", m)
+	}
+	ledger.Record(1, "gemini", 0.6, 10, 10, time.Second)
+	if got := ledger.SpentOn(time.Now()); got != 0.6 {
+		t.Errorf("spent today = %v", got)
+	}
+	if got := ledger.SpentOn(time.Now().AddDate(0, 0, 1)); got != 0 {
+		t.Errorf("spent tomorrow = %v", got)
+	}
+	ctx = h.withCostRouting(context.Background(), 1, "gemini")
+	if m := modelFrom(ctx, "default"); m != "cheap" {
+		t.Errorf("model = %q, want cheap", m)
+	}
+	if m := modelFrom(h.withCostRouting(context.Background(), 1, "claude"), ""); m != "" {
+		t.Errorf("provider without a cheap model routed to %q", m)
+	}
+
+	// The spending survives a restart; a report flush does not reset it.
+	ledger.Flush()
+	if got := NewUsageLedger(filepath.Dir(ledger.path)).SpentOn(time.Now()); got != 0.6 {
+		t.Errorf("reloaded spent today = %v", got)
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScheduleMatches(t *testing.T) {
+	mon9 := time.Date(2025, 3, 3, 9, 0, 0, 0, time.UTC) // a Monday
+	tests := []struct {
+		expr string
+		at   time.Time
+		want bool
+	}{
+		{"0 9 * * 1", mon9, true},
+		{"0 9 * * 1", mon9.Add(time.Minute), false},
+		{"0 9 * * 1", mon9.AddDate(0, 0, 1), false},
+		{"@daily", time.Date(2025, 3, 4, 0, 0, 0, 0, time.UTC), true},
+		{"@weekly", time.Date(2025, 3, 9, 0, 0, 0, 0, time.UTC), true}, // Sunday
+		{"0 0 * * 7", time.Date(2025, 3, 9, 0, 0, 0, 0, time.UTC), true},
+		{"*/15 8-18 * * 1-5", mon9.Add(45 * time.Minute), true},
+		{"*/15 8-18 * * 1-5", mon9.Add(50 * time.Minute), false},
+		{"0 9 1 * 1", time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC), true}, // day 1 or Monday
+	}
+	for _, tt := range tests {
+		s, err := ParseSchedule(tt.expr)
+		if err != nil {
+			t.Fatalf("ParseSchedule(%q): %v", tt.expr, err)
+		}
+		if got := s.Matches(tt.at); got != tt.want {
+			t.Errorf("%q.Matches(%s) = %v, want %v", tt.expr, tt.at.Format(time.RFC1123), got, tt.want)
+		}
+	}
+}
+
+func TestParseScheduleErrors(t *testing.T) {
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "*/0 * * * *", "a * * * *", "5-1 * * * *"} {
+		if _, err := ParseSchedule(expr); err == nil {
+			t.Errorf("ParseSchedule(%q) succeeded, want error", expr)
+		}
+	}
+}
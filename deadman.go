@@ -0,0 +1,138 @@
+package main
+
+import (
+	"log/slog"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// With ALIVE_PERIOD set, auto-execution needs a human checking in: an admin
+// sends /alive at least that often. When the period passes without one,
+// auto-execute is paused in every chat, so commands wait for approval
+// cards again, until an admin sends /alive.
+
+// AliveSwitch is the dead-man switch, persisted to DATA_DIR so a restart
+// does not count as a check-in.
+type AliveSwitch struct {
+	period time.Duration // 0 disables the switch
+
+	mu     sync.Mutex
+	path   string
+	state  aliveState
+	warned bool // the admin was told the period is running out
+	lapsed bool // the pause was announced
+}
+
+type aliveState struct {
+	At time.Time `json:"at"` // last /alive
+	By int64     `json:"by"`
+}
+
+func NewAliveSwitch(dataDir string, period time.Duration) *AliveSwitch {
+	s := &AliveSwitch{period: period, path: filepath.Join(dataDir, "alive.json")}
+	if period <= 0 {
+		return s
+	}
+	if err := loadJSONFile(s.path, &s.state); err != nil {
+		slog.Warn("failed to load alive state", "path", s.path, "err", err)
+	}
+	// The first period starts when the switch is first enabled.
+	if s.state.At.IsZero() {
+		s.state.At = time.Now()
+		if err := saveJSONFile(s.path, s.state); err != nil {
+			slog.Warn("failed to save alive state", "path", s.path, "err", err)
+		}
+	}
+	return s
+}
+
+// Lapsed reports whether the period has passed since the last /alive. A
+// nil or disabled switch never lapses.
+func (s *AliveSwitch) Lapsed(now time.Time) bool {
+	if s == nil || s.period <= 0 {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return now.Sub(s.state.At) > s.period
+}
+
+// Due returns when the switch lapses without another /alive.
+func (s *AliveSwitch) Due() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state.At.Add(s.period)
+}
+
+// Ack records an admin's /alive, starting a new period. It reports whether
+// auto-execute was paused.
+func (s *AliveSwitch) Ack(by int64, now time.Time) (resumed bool, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	resumed = now.Sub(s.state.At) > s.period
+	s.state = aliveState{At: now, By: by}
+	s.warned, s.lapsed = false, false
+	return resumed, saveJSONFile(s.path, s.state)
+}
+
+// Check reports, once each per period, that the period is about to run
+// out (the last tenth of it) and that it has.
+func (s *AliveSwitch) Check(now time.Time) (warn, lapse bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	left := s.state.At.Add(s.period).Sub(now)
+	if left < 0 {
+		lapse = !s.lapsed
+		s.lapsed = true
+		return false, lapse
+	}
+	if left <= s.period/10 {
+		warn = !s.warned
+		s.warned = true
+	}
+	return warn, false
+}
+
+// watchAlive tells the admin when the switch is about to lapse and when
+// it has.
+func (h *Handlers) watchAlive() {
+	tick := time.NewTicker(time.Minute)
+	defer tick.Stop()
+	for now := range tick.C {
+		warn, lapse := h.alive.Check(now)
+		switch {
+		case lapse:
+			slog.Warn("auto-execute paused: no /alive", "period", h.alive.period)
+			h.notifyAdmin(h.t(h.adminChatID, "alive.paused", h.alive.period))
+		case warn:
+			h.notifyAdmin(h.t(h.adminChatID, "alive.reminder", h.alive.Due().Format("2006-01-02 15:04")))
+		}
+	}
+}
+
+// autorunPaused reports whether the chat would auto-execute but for the
+// dead-man switch.
+func (h *Handlers) autorunPaused(chatID int64) bool {
+	return h.claude.autorun.Setting(chatID) && h.alive.Lapsed(time.Now())
+}
+
+// HandleAlive records an admin's check-in, resuming auto-execute if the
+// dead-man switch had paused it.
+func (h *Handlers) HandleAlive(chatID int64) {
+	if h.alive.period <= 0 {
+		h.sender.SendPlain(chatID, h.t(chatID, "alive.unset"))
+		return
+	}
+	resumed, err := h.alive.Ack(chatID, time.Now())
+	if err != nil {
+		h.sender.SendPlain(chatID, h.t(chatID, "alive.save_failed", err))
+		return
+	}
+	slog.Info("alive acknowledged", "chat_id", chatID, "resumed", resumed)
+	key := "alive.noted"
+	if resumed {
+		key = "alive.resumed"
+	}
+	h.sender.SendPlain(chatID, h.t(chatID, key, h.alive.Due().Format("2006-01-02 15:04")))
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAliveSwitch(t *testing.T) {
+	dir := t.TempDir()
+	s := NewAliveSwitch(dir, time.Hour)
+	start := s.Due().Add(-time.Hour)
+
+	if s.Lapsed(start.Add(59 * time.Minute)) {
+		t.Error("lapsed within the period")
+	}
+	if warn, lapse := s.Check(start.Add(55 * time.Minute)); !warn || lapse {
+		t.Errorf("Check in the last tenth = %v, %v, want a warning", warn, lapse)
+	}
+	if warn, _ := s.Check(start.Add(57 * time.Minute)); warn {
+		t.Error("warned twice in one period")
+	}
+	if !s.Lapsed(start.Add(61 * time.Minute)) {
+		t.Error("not lapsed after the period")
+	}
+	if _, lapse := s.Check(start.Add(61 * time.Minute)); !lapse {
+		t.Error("lapse not reported")
+	}
+	if _, lapse := s.Check(start.Add(62 * time.Minute)); lapse {
+		t.Error("lapse reported twice")
+	}
+
+	now := start.Add(2 * time.Hour)
+	resumed, err := s.Ack(7, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !resumed {
+		t.Error("Ack after the lapse did not report a resume")
+	}
+	// The check-in survives a restart.
+	s = NewAliveSwitch(dir, time.Hour)
+	if s.Lapsed(now.Add(30*time.Minute)) || !s.Due().Equal(now.Add(time.Hour)) {
+		t.Errorf("check-in lost on reload, due %v", s.Due())
+	}
+}
+
+func TestAutorunPausedByAliveSwitch(t *testing.T) {
+	dir := t.TempDir()
+	s := NewAutorunStore(dir, true)
+	var alive *AliveSwitch
+	s.SetAlive(alive)
+	if !s.Enabled(1) {
+		t.Fatal("nil switch paused auto-execute")
+	}
+
+	alive = NewAliveSwitch(dir, time.Hour)
+	alive.Ack(1, time.Now().Add(-2*time.Hour))
+	s.SetAlive(alive)
+	if s.Enabled(1) {
+		t.Error("auto-execute not paused after the switch lapsed")
+	}
+	if !s.Setting(1) {
+		t.Error("pause changed the chat's setting")
+	}
+	alive.Ack(1, time.Now())
+	if !s.Enabled(1) {
+		t.Error("auto-execute not resumed after /alive")
+	}
+}
+
+func TestAliveSwitchDisabled(t *testing.T) {
+	s := NewAliveSwitch(t.TempDir(), 0)
+	if s.Lapsed(time.Now().Add(1000 * time.Hour)) {
+		t.Error("disabled switch lapsed")
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+)
+
+// HandleAs lets an admin run a prompt in another chat's context (provider,
+// session, project and guardrails) to reproduce a reported issue. The reply
+// goes to the admin only, proposed commands are listed but never executed,
+// and the target's stored session is not advanced. The target is held
+// read-only for the call, so Claude gets no tools, and a Claude session is
+// forked rather than resumed. The prompt is logged and
+// recorded in the target's transcript with the admin as actor.
+// Usage: /as <chatID> <prompt>
+func (h *Handlers) HandleAs(ctx context.Context, chatID int64, args string) {
+	if !h.isAdmin(chatID) {
+		h.sender.SendPlain(chatID, h.t(chatID, "admin_only"))
+		return
+	}
+	idStr, prompt, _ := strings.Cut(strings.TrimSpace(args), " ")
+	prompt = strings.TrimSpace(prompt)
+	target, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil || prompt == "" {
+		h.sendUsage(chatID, "as")
+		return
+	}
+	if !h.listed(target) {
+		h.sender.SendPlain(chatID, h.t(chatID, "not_allowed_chat", target))
+		return
+	}
+
+	unlock := h.locks.Lock(target)
+	defer unlock()
+	defer h.readOnly.Hold(target)()
+
+	provider := h.providers.Get(target)
+	actor := fmt.Sprintf("admin %d via /as", chatID)
+	slog.Warn("admin acting as chat", "chat_id", target, "admin_chat_id", chatID, "provider", provider, "prompt", prompt)
+	h.record(TranscriptEntry{ChatID: target, Kind: TranscriptUser, Actor: actor, Provider: provider, Text: prompt})
+
+	ctx, cancel := context.WithTimeout(ctx, h.defaultTimeout())
+	defer cancel()
+	h.sender.SendTyping(chatID)
+
+	var reply string
+	if isChatProvider(provider) {
+		// Send on a copy of the history; nothing is appended to the target's.
+		reply, err = h.sendChat(ctx, target, provider, h.geminiSessions.Get(target), prompt)
+	} else {
+		// The fork's session ID is not stored, so the target keeps
+		// resuming its own session, which the prompt never reaches.
+		var resp *ClaudeResponse
+		resp, err = h.sendClaude(withForkSession(ctx), target, h.sessions.Get(target), prompt, nil, nil)
+		if resp != nil {
+			reply = resp.Result
+		}
+	}
+	if err != nil {
+		h.sender.SendPlain(chatID, h.t(chatID, "as.error", target, provider, err))
+		return
+	}
+
+	text, calls := h.parseCalls(reply)
+	project, _ := h.projects.Active(target)
+	var b strings.Builder
+	b.WriteString(h.t(chatID, "as.reply", target, provider, project, text))
+	if len(calls) > 0 {
+		b.WriteString("\n\n" + h.t(chatID, "as.proposed"))
+		for _, c := range calls {
+			fmt.Fprintf(&b, "\n  %s", c)
+		}
+	}
+	h.sendReply(ctx, chatID, b.String())
+}
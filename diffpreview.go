@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// maxDiffPreview caps the diff attached to a command's output message.
+const maxDiffPreview = 3000
+
+// maxSnapshotSize skips target files too large to be worth diffing.
+const maxSnapshotSize = 1 << 20
+
+// modifyingRe matches commands that commonly edit workspace files.
+var modifyingRe = regexp.MustCompile(
+	`\bsed\s+(\S+\s+)*(-[a-zA-Z]*i|--in-place)` + // sed -i
+		`|\bperl\s+(\S+\s+)*-[a-zA-Z]*i` + // perl -pi -e
+		`|(^|[^<>&0-9])>>?\s*[^\s&>]` + // > file, >> file (not 2>&1)
+		`|\btee\b` +
+		`|\bgit\s+(apply|am|checkout|restore|merge|rebase|cherry-pick|revert|stash\s+pop)\b` +
+		`|\bpatch\b` +
+		`|\b(mv|cp|rm|touch|truncate)\s`)
+
+// redirectTargetRe captures the file after a > or >> redirect.
+var redirectTargetRe = regexp.MustCompile(`(?:^|[^<>&0-9])>>?\s*([^\s&|;<>]+)`)
+
+// looksModifying reports whether a command is likely to modify files.
+func looksModifying(command string) bool {
+	return modifyingRe.MatchString(strings.ReplaceAll(command, ">/dev/null", ""))
+}
+
+// commandTargets guesses which files a command writes: redirect targets and
+// the trailing file arguments of sed -i / perl -i.
+func commandTargets(command string) []string {
+	var targets []string
+	for _, m := range redirectTargetRe.FindAllStringSubmatch(command, -1) {
+		if !strings.HasPrefix(m[1], "/dev/") {
+			targets = append(targets, m[1])
+		}
+	}
+	// sed -i / perl -i: the first non-flag argument is the script (unless
+	// given with -e), the rest are files.
+	fields := strings.Fields(command)
+	if len(fields) > 2 && (fields[0] == "sed" || fields[0] == "perl") {
+		scriptSeen := false
+		for i := 1; i < len(fields); i++ {
+			f := fields[i]
+			switch {
+			case f == "|" || f == ";" || f == "&&" || f == "||" || strings.HasPrefix(f, ">"):
+				return targets
+			case f == "-e" || f == "-f" || f == "--expression":
+				scriptSeen = true
+				i++
+			case strings.HasPrefix(f, "-"):
+			case !scriptSeen:
+				scriptSeen = true
+			default:
+				targets = append(targets, strings.Trim(f, `"'`))
+			}
+		}
+	}
+	return targets
+}
+
+// snapshotTargets copies the files a command is expected to write, for
+// workspaces that aren't git repositories. The returned func diffs them
+// against their new content.
+func snapshotTargets(dir, command string) func() string {
+	tmp, err := os.MkdirTemp("", "trash-diff-*")
+	if err != nil {
+		return func() string { return "" }
+	}
+	type snap struct{ name, path, before string }
+	var snaps []snap
+	for i, t := range commandTargets(command) {
+		path := t
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		before := filepath.Join(tmp, fmt.Sprintf("before-%d", i))
+		if info, err := os.Stat(path); err == nil {
+			if info.IsDir() || info.Size() > maxSnapshotSize {
+				continue
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				continue
+			}
+			os.WriteFile(before, data, 0o600)
+		} else {
+			before = os.DevNull
+		}
+		snaps = append(snaps, snap{t, path, before})
+	}
+
+	return func() string {
+		defer os.RemoveAll(tmp)
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		var diffs []string
+		for _, s := range snaps {
+			after := s.path
+			if _, err := os.Stat(after); err != nil {
+				after = os.DevNull
+			}
+			// --no-index works outside a repository; exit status 1 means "differs".
+			out, err := exec.CommandContext(ctx, "git", "diff", "--no-index", "--no-color", s.before, after).Output()
+			if err != nil && len(out) == 0 {
+				if _, ok := err.(*exec.ExitError); !ok {
+					slog.Warn("diff preview failed", "path", s.path, "err", err)
+				}
+				continue
+			}
+			// Show the target's name instead of the temp/absolute paths.
+			d := strings.ReplaceAll(string(out), strings.TrimPrefix(s.before, "/"), s.name)
+			diffs = append(diffs, strings.ReplaceAll(d, strings.TrimPrefix(after, "/"), s.name))
+		}
+		return formatDiffPreview(strings.Join(diffs, ""))
+	}
+}
+
+// formatDiffPreview wraps a unified diff in a code block, truncated for chat.
+func formatDiffPreview(diff string) string {
+	diff = strings.TrimSpace(diff)
+	if diff == "" {
+		return ""
+	}
+	if len(diff) > maxDiffPreview {
+		diff = diff[:maxDiffPreview] + "\n... (diff truncated)"
+	}
+	return "Changes:\n```diff\n" + diff + "\n```"
+}
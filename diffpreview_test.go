@@ -0,0 +1,75 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLooksModifying(t *testing.T) {
+	tests := []struct {
+		cmd  string
+		want bool
+	}{
+		{"sed -i 's/a/b/' main.go", true},
+		{"echo hi > out.txt", true},
+		{"cat a >> b", true},
+		{"git apply fix.patch", true},
+		{"perl -pi -e 's/x/y/' f", true},
+		{"ls -la", false},
+		{"go test ./... 2>&1", false},
+		{"grep foo bar >/dev/null", false},
+		{"git status", false},
+		{"sed -n '1,10p' main.go", false},
+	}
+	for _, tt := range tests {
+		if got := looksModifying(tt.cmd); got != tt.want {
+			t.Errorf("looksModifying(%q) = %v, want %v", tt.cmd, got, tt.want)
+		}
+	}
+}
+
+func TestCommandTargets(t *testing.T) {
+	tests := []struct {
+		cmd  string
+		want []string
+	}{
+		{"echo hi > out.txt", []string{"out.txt"}},
+		{"sed -i 's/x\\.y/z/' src/a.go b.go", []string{"src/a.go", "b.go"}},
+		{"sed -i -e 's/a/b/' f.txt", []string{"f.txt"}},
+		{"make 2>&1 > build.log", []string{"build.log"}},
+	}
+	for _, tt := range tests {
+		if got := commandTargets(tt.cmd); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("commandTargets(%q) = %q, want %q", tt.cmd, got, tt.want)
+		}
+	}
+}
+
+func TestSnapshotTargetsDiff(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	path := filepath.Join(dir, "notes.txt")
+	if err := os.WriteFile(path, []byte("old line\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	done := snapshotTargets(dir, "echo new line > notes.txt")
+	if err := os.WriteFile(path, []byte("new line\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	diff := done()
+	for _, want := range []string{"-old line", "+new line", "notes.txt"} {
+		if !strings.Contains(diff, want) {
+			t.Errorf("diff missing %q:\n%s", want, diff)
+		}
+	}
+	if strings.Contains(diff, "trash-diff-") {
+		t.Errorf("diff leaks temp paths:\n%s", diff)
+	}
+}
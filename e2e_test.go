@@ -0,0 +1,1393 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// End-to-end tests run the whole bot (update loop, handlers, approvals,
+// command execution) against a fake Telegram Bot API and fake providers,
+// so no network access or accounts are needed.
+
+const (
+	e2eToken = "123:e2e"
+	e2eChat  = int64(1001)
+	e2eAdmin = int64(2002)
+)
+
+// telegramCall is one request the bot made to the fake Bot API.
+type telegramCall struct {
+	Method string
+	Form   url.Values
+	ID     int // message ID of the reply
+}
+
+// fakeBotAPI is an httptest Bot API: it hands out queued updates to
+// getUpdates and records everything else.
+type fakeBotAPI struct {
+	url string
+
+	mu       sync.Mutex
+	calls    []telegramCall
+	updates  []map[string]any
+	updateID int
+	nextMsg  int
+	queued   chan struct{}
+}
+
+func newFakeBotAPI(t *testing.T) *fakeBotAPI {
+	f := &fakeBotAPI{nextMsg: 500, queued: make(chan struct{}, 1)}
+	srv := httptest.NewServer(http.HandlerFunc(f.serve))
+	t.Cleanup(srv.Close)
+	f.url = srv.URL
+	return f
+}
+
+// endpoint is the tgbotapi endpoint format for the fake.
+func (f *fakeBotAPI) endpoint() string {
+	return f.url + "/bot%s/%s"
+}
+
+func (f *fakeBotAPI) serve(w http.ResponseWriter, r *http.Request) {
+	method := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/") {
+		r.ParseMultipartForm(10 << 20)
+	} else {
+		r.ParseForm()
+	}
+
+	var result any
+	switch method {
+	case "getMe":
+		result = map[string]any{"id": 42, "is_bot": true, "first_name": "Trash", "username": "trash_bot"}
+	case "getUpdates":
+		result = f.takeUpdates()
+	default:
+		f.mu.Lock()
+		f.nextMsg++
+		id := f.nextMsg
+		f.calls = append(f.calls, telegramCall{Method: method, Form: r.Form, ID: id})
+		f.mu.Unlock()
+		chat, _ := strconv.ParseInt(r.Form.Get("chat_id"), 10, 64)
+		result = map[string]any{"message_id": id, "date": 0, "chat": map[string]any{"id": chat}, "text": r.Form.Get("text")}
+	}
+	json.NewEncoder(w).Encode(map[string]any{"ok": true, "result": result})
+}
+
+// takeUpdates returns the queued updates, waiting briefly for one so the
+// bot's polling loop does not spin.
+func (f *fakeBotAPI) takeUpdates() []map[string]any {
+	select {
+	case <-f.queued:
+	case <-time.After(50 * time.Millisecond):
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	out := f.updates
+	f.updates = nil
+	if out == nil {
+		out = []map[string]any{}
+	}
+	return out
+}
+
+func (f *fakeBotAPI) push(update map[string]any) {
+	f.mu.Lock()
+	f.updateID++
+	update["update_id"] = f.updateID
+	f.updates = append(f.updates, update)
+	f.mu.Unlock()
+	select {
+	case f.queued <- struct{}{}:
+	default:
+	}
+}
+
+// sendText queues a text message from the user in chatID. A leading
+// /command is marked as one, as Telegram does.
+func (f *fakeBotAPI) sendText(chatID int64, text string) {
+	f.mu.Lock()
+	f.nextMsg++
+	id := f.nextMsg
+	f.mu.Unlock()
+	msg := map[string]any{
+		"message_id": id, "date": time.Now().Unix(), "text": text,
+		"from": map[string]any{"id": chatID, "first_name": "Dev", "username": "dev"},
+		"chat": map[string]any{"id": chatID, "type": "private"},
+	}
+	if strings.HasPrefix(text, "/") {
+		name, _, _ := strings.Cut(text, " ")
+		msg["entities"] = []map[string]any{{"type": "bot_command", "offset": 0, "length": len(name)}}
+	}
+	f.push(map[string]any{"message": msg})
+}
+
+// press queues a button press on the bot's message messageID.
+func (f *fakeBotAPI) press(chatID int64, messageID int, data string) {
+	f.push(map[string]any{"callback_query": map[string]any{
+		"id":   fmt.Sprintf("cb%d", messageID),
+		"data": data,
+		"from": map[string]any{"id": chatID, "first_name": "Dev", "username": "dev"},
+		"message": map[string]any{
+			"message_id": messageID, "date": 0,
+			"chat": map[string]any{"id": chatID, "type": "private"},
+		},
+	}})
+}
+
+// waitFor waits for a call to chatID matching match and returns it with
+// the message ID the fake gave it.
+func (f *fakeBotAPI) waitFor(t *testing.T, chatID int64, what string, match func(telegramCall) bool) (telegramCall, int) {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	seen := 0
+	for time.Now().Before(deadline) {
+		f.mu.Lock()
+		for ; seen < len(f.calls); seen++ {
+			c := f.calls[seen]
+			if c.Form.Get("chat_id") == strconv.FormatInt(chatID, 10) && match(c) {
+				f.mu.Unlock()
+				return c, c.ID
+			}
+		}
+		f.mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for %s in chat %d; calls:\n%s", what, chatID, f.dump())
+	return telegramCall{}, 0
+}
+
+// waitText waits for a message to chatID containing text, ignoring
+// MarkdownV2 escapes.
+func (f *fakeBotAPI) waitText(t *testing.T, chatID int64, text string) (telegramCall, int) {
+	t.Helper()
+	return f.waitFor(t, chatID, fmt.Sprintf("%q", text), func(c telegramCall) bool {
+		return strings.Contains(strings.ReplaceAll(c.Form.Get("text"), `\`, ""), text)
+	})
+}
+
+// texts returns the text of every call to chatID.
+func (f *fakeBotAPI) texts(chatID int64) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var b strings.Builder
+	for _, c := range f.calls {
+		if c.Form.Get("chat_id") == strconv.FormatInt(chatID, 10) {
+			b.WriteString(c.Form.Get("text") + "\n")
+		}
+	}
+	return b.String()
+}
+
+func (f *fakeBotAPI) dump() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var b strings.Builder
+	for _, c := range f.calls {
+		fmt.Fprintf(&b, "  %s chat=%s %q\n", c.Method, c.Form.Get("chat_id"), c.Form.Get("text"))
+	}
+	return b.String()
+}
+
+// fakeChatAPI is an OpenAI-style chat completions backend (Azure OpenAI)
+// that answers with scripted replies and records the conversations.
+type fakeChatAPI struct {
+	url string
+
+	mu       sync.Mutex
+	replies  []string
+	requests [][]openRouterMessage
+}
+
+func newFakeChatAPI(t *testing.T, replies ...string) *fakeChatAPI {
+	f := &fakeChatAPI{replies: replies}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Messages []openRouterMessage `json:"messages"`
+		}
+		body, _ := io.ReadAll(r.Body)
+		json.Unmarshal(body, &req)
+		f.mu.Lock()
+		f.requests = append(f.requests, req.Messages)
+		reply := "(no scripted reply left)"
+		if len(f.replies) > 0 {
+			reply, f.replies = f.replies[0], f.replies[1:]
+		}
+		f.mu.Unlock()
+		json.NewEncoder(w).Encode(map[string]any{
+			"model":   "gpt-test",
+			"choices": []any{map[string]any{"message": map[string]any{"role": "assistant", "content": reply}, "finish_reason": "stop"}},
+			"usage":   map[string]any{"prompt_tokens": 10, "completion_tokens": 5, "total_tokens": 15},
+		})
+	}))
+	t.Cleanup(srv.Close)
+	f.url = srv.URL
+	return f
+}
+
+// lastPrompt returns the last user message of request i.
+func (f *fakeChatAPI) lastPrompt(i int) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if i >= len(f.requests) {
+		return ""
+	}
+	msgs := f.requests[i]
+	return msgs[len(msgs)-1].Content
+}
+
+// fakeClaudeCLI writes a claude executable that prints the scripted JSON
+// results in turn and logs its arguments and input to log.
+func fakeClaudeCLI(t *testing.T, results ...ClaudeResponse) (path, log string) {
+	dir := t.TempDir()
+	for i, r := range results {
+		data, _ := json.Marshal(r)
+		if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("reply-%d.json", i+1)), data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	log = filepath.Join(dir, "calls.log")
+	script := fmt.Sprintf(`#!/bin/sh
+dir=%q
+n=$(cat "$dir/count" 2>/dev/null || echo 0)
+n=$((n+1))
+echo "$n" > "$dir/count"
+echo "ARGS $*" >> "$dir/calls.log"
+cat >> "$dir/calls.log"
+echo >> "$dir/calls.log"
+cat "$dir/reply-$n.json"
+`, dir)
+	path = filepath.Join(dir, "claude")
+	if err := os.WriteFile(path, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return path, log
+}
+
+// startE2EBot runs the bot with env on top of a minimal configuration and
+// stops it when the test ends.
+func startE2EBot(t *testing.T, tg *fakeBotAPI, env map[string]string) *Bot {
+	t.Helper()
+	home := t.TempDir()
+	base := map[string]string{
+		"HOME":               home,
+		"TELEGRAM_BOT_TOKEN": e2eToken,
+		"ALLOWED_CHAT_IDS":   strconv.FormatInt(e2eChat, 10),
+		"ADMIN_CHAT_ID":      strconv.FormatInt(e2eAdmin, 10),
+		"WORK_DIR":           t.TempDir(),
+		"DATA_DIR":           filepath.Join(home, "data"),
+		"STREAM_INTERVAL":    "0",
+		"TYPING_INTERVAL":    "0",
+		"SEND_PACE":          "0",
+		"SEND_COALESCE":      "0",
+		"REPLY_LANGUAGE":     "off",
+	}
+	for k, v := range env {
+		base[k] = v
+	}
+	for k := range configKeys {
+		if _, ok := base[k]; !ok {
+			t.Setenv(k, "")
+		}
+	}
+	for k, v := range base {
+		t.Setenv(k, v)
+	}
+	cfg, err := LoadConfig("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := newBot(cfg, tg.endpoint())
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{})
+	go func() {
+		b.Run()
+		close(done)
+	}()
+	t.Cleanup(func() {
+		b.Shutdown(5 * time.Second)
+		<-done
+	})
+	return b
+}
+
+func hasButton(c telegramCall, data string) bool {
+	return strings.Contains(c.Form.Get("reply_markup"), `"callback_data":"`+data+`"`)
+}
+
+func TestE2EApproveAndFeedBack(t *testing.T) {
+	tg := newFakeBotAPI(t)
+	ai := newFakeChatAPI(t,
+		"Let me check.\n<command>echo e2e-disk-ok</command>",
+		"The disk is fine.",
+	)
+	startE2EBot(t, tg, map[string]string{
+		"DEFAULT_PROVIDER":        "azure",
+		"AZURE_OPENAI_ENDPOINT":   ai.url,
+		"AZURE_OPENAI_DEPLOYMENT": "gpt-test",
+		"AZURE_OPENAI_API_KEY":    "key",
+	})
+
+	tg.sendText(e2eChat, "how is the disk?")
+	_, card := tg.waitFor(t, e2eChat, "approval card", func(c telegramCall) bool { return hasButton(c, "approve") })
+	if !strings.Contains(ai.lastPrompt(0), "how is the disk?") {
+		t.Errorf("AI got %q", ai.lastPrompt(0))
+	}
+
+	tg.press(e2eChat, card, "approve")
+	tg.waitFor(t, e2eChat, "resolved card", func(c telegramCall) bool {
+		return c.Method == "editMessageText" && strings.Contains(c.Form.Get("text"), "Approved: echo e2e-disk-ok")
+	})
+	tg.waitText(t, e2eChat, "e2e-disk-ok")
+	tg.waitText(t, e2eChat, "The disk is fine.")
+
+	fed := ai.lastPrompt(1)
+	if !strings.Contains(fed, "Status: Executed") || !strings.Contains(fed, "e2e-disk-ok") {
+		t.Errorf("results fed back to the AI: %q", fed)
+	}
+}
+
+func TestE2EDenyAndFeedBack(t *testing.T) {
+	tg := newFakeBotAPI(t)
+	ai := newFakeChatAPI(t,
+		"<command>touch e2e-denied-file</command>",
+		"Understood, I will not touch it.",
+	)
+	b := startE2EBot(t, tg, map[string]string{
+		"DEFAULT_PROVIDER":        "azure",
+		"AZURE_OPENAI_ENDPOINT":   ai.url,
+		"AZURE_OPENAI_DEPLOYMENT": "gpt-test",
+		"AZURE_OPENAI_API_KEY":    "key",
+	})
+
+	tg.sendText(e2eChat, "create a file")
+	_, card := tg.waitFor(t, e2eChat, "approval card", func(c telegramCall) bool { return hasButton(c, "deny") })
+	tg.press(e2eChat, card, "deny")
+	tg.waitText(t, e2eChat, "Understood, I will not touch it.")
+
+	if fed := ai.lastPrompt(1); !strings.Contains(fed, "Denied by user") {
+		t.Errorf("results fed back to the AI: %q", fed)
+	}
+	if _, err := os.Stat(filepath.Join(b.cfg.WorkDir, "e2e-denied-file")); err == nil {
+		t.Error("denied command ran")
+	}
+}
+
+func TestE2EWorkspaceFollowsTrackedCwd(t *testing.T) {
+	tg := newFakeBotAPI(t)
+	ai := newFakeChatAPI(t,
+		`<command>cd "$(dirname "$PWD")"</command>`,
+		"<command>ls</command>",
+		"Understood.",
+	)
+	startE2EBot(t, tg, map[string]string{
+		"DEFAULT_PROVIDER":        "azure",
+		"AZURE_OPENAI_ENDPOINT":   ai.url,
+		"AZURE_OPENAI_DEPLOYMENT": "gpt-test",
+		"AZURE_OPENAI_API_KEY":    "key",
+	})
+
+	// The cd is not recognizable from its text, but leaves the tracked
+	// cwd in WORK_DIR, where the next command is refused.
+	tg.sendText(e2eChat, "look around")
+	_, card := tg.waitFor(t, e2eChat, "cd card", func(c telegramCall) bool { return hasButton(c, "approve") })
+	tg.press(e2eChat, card, "approve")
+	_, card = tg.waitFor(t, e2eChat, "ls card", func(c telegramCall) bool {
+		return c.ID > card && strings.Contains(c.Form.Get("text"), "ls") && hasButton(c, "approve")
+	})
+	tg.press(e2eChat, card, "approve")
+	tg.waitText(t, e2eChat, "Understood.")
+	if fed := ai.lastPrompt(2); !strings.Contains(fed, "outside this chat's workspace") {
+		t.Errorf("results fed back to the AI: %q", fed)
+	}
+}
+
+func TestE2ERiskyCommandNeedsPhrase(t *testing.T) {
+	tg := newFakeBotAPI(t)
+	ai := newFakeChatAPI(t,
+		"<command>rm -rf e2e-build</command>",
+		"Cleaned up.",
+	)
+	b := startE2EBot(t, tg, map[string]string{
+		"DEFAULT_PROVIDER":        "azure",
+		"AZURE_OPENAI_ENDPOINT":   ai.url,
+		"AZURE_OPENAI_DEPLOYMENT": "gpt-test",
+		"AZURE_OPENAI_API_KEY":    "key",
+	})
+	build := filepath.Join(b.handlers.workspaces.Dir(e2eChat), "e2e-build")
+	if err := os.Mkdir(build, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	tg.sendText(e2eChat, "clean the build")
+	c, card := tg.waitFor(t, e2eChat, "approval card", func(c telegramCall) bool { return hasButton(c, "deny") })
+	if hasButton(c, "approve") {
+		t.Error("risky command offered an Approve button")
+	}
+	tg.press(e2eChat, card, "approve")
+	tg.sendText(e2eChat, "yes")
+	tg.waitText(t, e2eChat, "Reply with `rm` to run it")
+	if _, err := os.Stat(build); err != nil {
+		t.Fatal("risky command ran without its phrase")
+	}
+
+	tg.sendText(e2eChat, "rm")
+	tg.waitFor(t, e2eChat, "resolved card", func(c telegramCall) bool {
+		return c.Method == "editMessageText" && strings.Contains(c.Form.Get("text"), "Approved: rm -rf e2e-build")
+	})
+	tg.waitText(t, e2eChat, "Cleaned up.")
+	if _, err := os.Stat(build); err == nil {
+		t.Error("confirmed command did not run")
+	}
+}
+
+func TestE2ERiskyCommandConfirmedByReply(t *testing.T) {
+	tg := newFakeBotAPI(t)
+	ai := newFakeChatAPI(t, "<command>rm -rf e2e-build</command>", "Cleaned up.")
+	b := startE2EBot(t, tg, map[string]string{
+		"DEFAULT_PROVIDER":        "azure",
+		"AZURE_OPENAI_ENDPOINT":   ai.url,
+		"AZURE_OPENAI_DEPLOYMENT": "gpt-test",
+		"AZURE_OPENAI_API_KEY":    "key",
+	})
+	build := filepath.Join(b.handlers.workspaces.Dir(e2eChat), "e2e-build")
+	if err := os.Mkdir(build, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	tg.sendText(e2eChat, "clean the build")
+	c, card := tg.waitFor(t, e2eChat, "approval card", func(c telegramCall) bool { return hasButton(c, "deny") })
+	// In privacy-mode groups the phrase only reaches the bot as a reply
+	// to the card.
+	tg.push(map[string]any{"message": map[string]any{
+		"message_id": 900, "date": time.Now().Unix(), "text": "rm",
+		"from": map[string]any{"id": e2eChat, "first_name": "Dev"},
+		"chat": map[string]any{"id": e2eChat, "type": "private"},
+		"reply_to_message": map[string]any{
+			"message_id": card, "date": 0, "text": c.Form.Get("text"),
+			"from": map[string]any{"id": 42, "is_bot": true, "first_name": "Trash"},
+			"chat": map[string]any{"id": e2eChat, "type": "private"},
+		},
+	}})
+	tg.waitText(t, e2eChat, "Cleaned up.")
+	if _, err := os.Stat(build); err == nil {
+		t.Error("command confirmed by a reply did not run")
+	}
+}
+
+func TestE2EEscalatedRiskyCommandOnlyDenies(t *testing.T) {
+	tg := newFakeBotAPI(t)
+	ai := newFakeChatAPI(t, "<command>rm -rf e2e-build</command>", "Left it alone.")
+	startE2EBot(t, tg, map[string]string{
+		"DEFAULT_PROVIDER":        "azure",
+		"AZURE_OPENAI_ENDPOINT":   ai.url,
+		"AZURE_OPENAI_DEPLOYMENT": "gpt-test",
+		"AZURE_OPENAI_API_KEY":    "key",
+		"APPROVAL_SLA":            "100ms",
+	})
+
+	tg.sendText(e2eChat, "clean the build")
+	tg.waitFor(t, e2eChat, "approval card", func(c telegramCall) bool { return hasButton(c, "deny") })
+	c, card := tg.waitText(t, e2eAdmin, "Approval waiting")
+	if hasButton(c, "sla:approve:"+strconv.FormatInt(e2eChat, 10)) {
+		t.Error("escalated risky command offered an Approve button")
+	}
+	if !strings.Contains(c.Form.Get("text"), "confirmation phrase is typed in chat") {
+		t.Errorf("escalated card = %q", c.Form.Get("text"))
+	}
+	tg.press(e2eAdmin, card, "sla:deny:"+strconv.FormatInt(e2eChat, 10))
+	tg.waitText(t, e2eChat, "Left it alone.")
+}
+
+func TestE2EClaudeResumesSession(t *testing.T) {
+	tg := newFakeBotAPI(t)
+	claude, log := fakeClaudeCLI(t,
+		ClaudeResponse{Type: "result", SessionID: "sess-e2e", Result: "<command>echo from-claude</command>"},
+		ClaudeResponse{Type: "result", SessionID: "sess-e2e", Result: "Done: the output was from-claude."},
+	)
+	startE2EBot(t, tg, map[string]string{"CLAUDE_PATH": claude})
+
+	tg.sendText(e2eChat, "run the thing")
+	_, card := tg.waitFor(t, e2eChat, "approval card", func(c telegramCall) bool { return hasButton(c, "approve") })
+	tg.press(e2eChat, card, "approve")
+	tg.waitText(t, e2eChat, "Done: the output was from-claude.")
+
+	data, err := os.ReadFile(log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	calls := strings.Split(string(data), "ARGS ")
+	if len(calls) != 3 {
+		t.Fatalf("claude ran %d times:\n%s", len(calls)-1, data)
+	}
+	if strings.Contains(calls[1], "--resume") || !strings.Contains(calls[1], "run the thing") {
+		t.Errorf("first call: %s", calls[1])
+	}
+	if !strings.Contains(calls[2], "--resume sess-e2e") || !strings.Contains(calls[2], "from-claude") {
+		t.Errorf("second call did not resume with the results: %s", calls[2])
+	}
+}
+
+func TestE2EUnauthorizedChat(t *testing.T) {
+	tg := newFakeBotAPI(t)
+	ai := newFakeChatAPI(t, "should never be asked")
+	startE2EBot(t, tg, map[string]string{
+		"DEFAULT_PROVIDER":        "azure",
+		"AZURE_OPENAI_ENDPOINT":   ai.url,
+		"AZURE_OPENAI_DEPLOYMENT": "gpt-test",
+		"AZURE_OPENAI_API_KEY":    "key",
+	})
+
+	const stranger = int64(999)
+	tg.sendText(stranger, "let me in")
+	tg.waitText(t, e2eAdmin, "999")
+	if got := tg.texts(stranger); got != "Unauthorized. Your chat ID: 999\n" {
+		t.Errorf("unauthorized chat got %q", got)
+	}
+	if ai.lastPrompt(0) != "" {
+		t.Error("unauthorized message reached the AI")
+	}
+}
+
+func TestE2EOversizedDocumentRefused(t *testing.T) {
+	tg := newFakeBotAPI(t)
+	ai := newFakeChatAPI(t, "should never be asked")
+	startE2EBot(t, tg, map[string]string{
+		"DEFAULT_PROVIDER":        "azure",
+		"AZURE_OPENAI_ENDPOINT":   ai.url,
+		"AZURE_OPENAI_DEPLOYMENT": "gpt-test",
+		"AZURE_OPENAI_API_KEY":    "key",
+	})
+
+	tg.push(map[string]any{"message": map[string]any{
+		"message_id": 1, "date": time.Now().Unix(),
+		"from":     map[string]any{"id": e2eChat, "first_name": "Dev", "username": "dev"},
+		"chat":     map[string]any{"id": e2eChat, "type": "private"},
+		"document": map[string]any{"file_id": "big", "file_unique_id": "big", "file_name": "dump.sql", "file_size": 30 << 20},
+	}})
+	tg.waitText(t, e2eChat, "at most 20 MB")
+	tg.waitText(t, e2eChat, "/fetchfile <url>")
+	tg.mu.Lock()
+	defer tg.mu.Unlock()
+	for _, c := range tg.calls {
+		if c.Method == "getFile" {
+			t.Error("oversized file was requested from Telegram")
+		}
+	}
+	if ai.lastPrompt(0) != "" {
+		t.Error("oversized file reached the AI")
+	}
+}
+
+func TestE2EVoiceReply(t *testing.T) {
+	tg := newFakeBotAPI(t)
+	ai := newFakeChatAPI(t, "All **good**.\n```\nuptime\n```")
+	spoken := filepath.Join(t.TempDir(), "spoken")
+	startE2EBot(t, tg, map[string]string{
+		"DEFAULT_PROVIDER":        "azure",
+		"AZURE_OPENAI_ENDPOINT":   ai.url,
+		"AZURE_OPENAI_DEPLOYMENT": "gpt-test",
+		"AZURE_OPENAI_API_KEY":    "key",
+		"TTS_CMD":                 `tee "` + spoken + `" > "$TTS_OUTPUT"`,
+	})
+
+	tg.sendText(e2eChat, "/voice on")
+	tg.waitText(t, e2eChat, "Voice replies on")
+	tg.sendText(e2eChat, "status?")
+	tg.waitFor(t, e2eChat, "voice note", func(c telegramCall) bool { return c.Method == "sendVoice" })
+	if got, _ := os.ReadFile(spoken); string(got) != "All good." {
+		t.Errorf("spoke %q", got)
+	}
+}
+
+func TestE2EModelsPicksPerChatModel(t *testing.T) {
+	tg := newFakeBotAPI(t)
+	b := startE2EBot(t, tg, map[string]string{"GEMINI_MODEL": "gemini-2.5-flash"})
+
+	tg.sendText(e2eChat, "/models gemini")
+	_, list := tg.waitFor(t, e2eChat, "model list", func(c telegramCall) bool {
+		return hasButton(c, "models:s:gemini:gemini-2.5-pro") && strings.Contains(c.Form.Get("text"), "$1.25/$10 per 1M")
+	})
+	tg.press(e2eChat, list, "models:s:gemini:gemini-2.5-pro")
+	tg.waitText(t, e2eChat, "Gemini model for this chat: gemini-2.5-pro")
+
+	if got := b.handlers.settings.Get(e2eChat).GeminiModel; got != "gemini-2.5-pro" {
+		t.Errorf("stored model = %q", got)
+	}
+	if got := b.handlers.providers.Get(e2eChat); got != "gemini" {
+		t.Errorf("provider = %q", got)
+	}
+	if got := b.handlers.gemini.GetModel(); got != "gemini-2.5-flash" {
+		t.Errorf("server model changed to %q", got)
+	}
+	tg.sendText(e2eChat, "/model")
+	tg.waitText(t, e2eChat, "model: gemini-2.5-pro")
+}
+
+func TestE2EPostProcessReplies(t *testing.T) {
+	tg := newFakeBotAPI(t)
+	ai := newFakeChatAPI(t, "<think>the user wants a status</think>All services are up.")
+	startE2EBot(t, tg, map[string]string{
+		"DEFAULT_PROVIDER":        "azure",
+		"AZURE_OPENAI_ENDPOINT":   ai.url,
+		"AZURE_OPENAI_DEPLOYMENT": "gpt-test",
+		"AZURE_OPENAI_API_KEY":    "key",
+	})
+
+	tg.sendText(e2eChat, "/postprocess strip-reasoning")
+	tg.waitText(t, e2eChat, "Added: strip-reasoning")
+	tg.sendText(e2eChat, "/postprocess signature [status-bot]")
+	tg.waitText(t, e2eChat, "Added: signature [status-bot]")
+
+	tg.sendText(e2eChat, "status?")
+	tg.waitText(t, e2eChat, "All services are up.\n\n[status-bot]")
+	if strings.Contains(tg.texts(e2eChat), "the user wants") {
+		t.Errorf("reasoning reached the chat:\n%s", tg.dump())
+	}
+}
+
+func TestE2EAskAnswersWithButtons(t *testing.T) {
+	tg := newFakeBotAPI(t)
+	ai := newFakeChatAPI(t,
+		"Before I deploy:\n<ask>Which environment?\n- staging\n- production\n</ask>\n<ask>Run the migrations?\n- yes\n- no\n</ask>",
+		"Deploying to staging without migrations.",
+	)
+	startE2EBot(t, tg, map[string]string{
+		"DEFAULT_PROVIDER":        "azure",
+		"AZURE_OPENAI_ENDPOINT":   ai.url,
+		"AZURE_OPENAI_DEPLOYMENT": "gpt-test",
+		"AZURE_OPENAI_API_KEY":    "key",
+	})
+
+	tg.sendText(e2eChat, "deploy")
+	tg.waitText(t, e2eChat, "Before I deploy:")
+	_, env := tg.waitFor(t, e2eChat, "first question", func(c telegramCall) bool { return hasButton(c, "ask:1:0:0") })
+	_, migrate := tg.waitFor(t, e2eChat, "second question", func(c telegramCall) bool { return hasButton(c, "ask:1:1:1") })
+
+	tg.press(e2eChat, env, "ask:1:0:0")
+	tg.waitText(t, e2eChat, "✅ staging")
+	tg.press(e2eChat, migrate, "ask:1:1:1")
+	tg.waitText(t, e2eChat, "Deploying to staging without migrations.")
+
+	if got := ai.lastPrompt(1); !strings.Contains(got, "Which environment? → staging") || !strings.Contains(got, "Run the migrations? → no") {
+		t.Errorf("answers sent to the AI = %q", got)
+	}
+
+	// The form is closed: pressing again doesn't reach the AI.
+	tg.press(e2eChat, env, "ask:1:0:1")
+	tg.waitText(t, e2eChat, "This question has expired")
+}
+
+func TestE2EAlbumHandledOnce(t *testing.T) {
+	tg := newFakeBotAPI(t)
+	ai := newFakeChatAPI(t, "should never be asked")
+	startE2EBot(t, tg, map[string]string{
+		"DEFAULT_PROVIDER":        "azure",
+		"AZURE_OPENAI_ENDPOINT":   ai.url,
+		"AZURE_OPENAI_DEPLOYMENT": "gpt-test",
+		"AZURE_OPENAI_API_KEY":    "key",
+	})
+
+	// Oversized photos fail before any download, showing how often the
+	// album was handled.
+	for i := range 3 {
+		tg.push(map[string]any{"message": map[string]any{
+			"message_id": i + 1, "date": time.Now().Unix(), "media_group_id": "album1",
+			"from":  map[string]any{"id": e2eChat, "first_name": "Dev", "username": "dev"},
+			"chat":  map[string]any{"id": e2eChat, "type": "private"},
+			"photo": []map[string]any{{"file_id": fmt.Sprintf("p%d", i), "file_unique_id": fmt.Sprintf("p%d", i), "width": 1280, "height": 960, "file_size": 30 << 20}},
+		}})
+	}
+	tg.waitText(t, e2eChat, "at most 20 MB")
+	time.Sleep(2 * albumWindow)
+	if n := strings.Count(tg.texts(e2eChat), "at most 20 MB"); n != 1 {
+		t.Errorf("album handled %d times:\n%s", n, tg.dump())
+	}
+}
+
+func TestE2EStickerReachesAI(t *testing.T) {
+	tg := newFakeBotAPI(t)
+	ai := newFakeChatAPI(t, "Glad you like it!")
+	startE2EBot(t, tg, map[string]string{
+		"DEFAULT_PROVIDER":        "azure",
+		"AZURE_OPENAI_ENDPOINT":   ai.url,
+		"AZURE_OPENAI_DEPLOYMENT": "gpt-test",
+		"AZURE_OPENAI_API_KEY":    "key",
+	})
+
+	// An animated sticker without a preview is described by its emoji.
+	tg.push(map[string]any{"message": map[string]any{
+		"message_id": 1, "date": time.Now().Unix(),
+		"from":    map[string]any{"id": e2eChat, "first_name": "Dev", "username": "dev"},
+		"chat":    map[string]any{"id": e2eChat, "type": "private"},
+		"sticker": map[string]any{"file_id": "s1", "file_unique_id": "s1", "width": 512, "height": 512, "is_animated": true, "emoji": "👍", "set_name": "Thumbs"},
+	}})
+	tg.waitText(t, e2eChat, "Glad you like it!")
+	if got := ai.lastPrompt(0); !strings.Contains(got, `a sticker (👍 from the set "Thumbs") with no image`) {
+		t.Errorf("prompt = %q", got)
+	}
+}
+
+func TestE2EBlockedAutoExecAlertsAdmin(t *testing.T) {
+	tg := newFakeBotAPI(t)
+	ai := newFakeChatAPI(t, "Cleaning up.\n<command>rm -rf /</command>", "Understood.")
+	b := startE2EBot(t, tg, map[string]string{
+		"DEFAULT_PROVIDER":        "azure",
+		"AZURE_OPENAI_ENDPOINT":   ai.url,
+		"AZURE_OPENAI_DEPLOYMENT": "gpt-test",
+		"AZURE_OPENAI_API_KEY":    "key",
+		"SKIP_PERMISSIONS":        "true",
+		"FREEZE_ON_BLOCK":         "true",
+	})
+
+	tg.sendText(e2eChat, "free some disk space")
+	alert, _ := tg.waitFor(t, e2eAdmin, "block alert", func(c telegramCall) bool {
+		return strings.Contains(strings.ReplaceAll(c.Form.Get("text"), `\`, ""), "Safeguard blocked an auto-executed command")
+	})
+	text := strings.ReplaceAll(alert.Form.Get("text"), `\`, "")
+	if !strings.Contains(text, "rm -rf /") || !strings.Contains(text, "free some disk space") {
+		t.Errorf("alert = %q", text)
+	}
+	if !hasButton(alert, "unfreeze:"+strconv.FormatInt(e2eChat, 10)) {
+		t.Errorf("alert has no unfreeze button: %s", alert.Form.Get("reply_markup"))
+	}
+	if !b.handlers.frozen.IsFrozen(e2eChat) {
+		t.Error("chat not frozen after the blocked command")
+	}
+}
+
+func TestE2EFreezeAndUnfreeze(t *testing.T) {
+	tg := newFakeBotAPI(t)
+	ai := newFakeChatAPI(t, "Back to work.")
+	b := startE2EBot(t, tg, map[string]string{
+		"DEFAULT_PROVIDER":        "azure",
+		"AZURE_OPENAI_ENDPOINT":   ai.url,
+		"AZURE_OPENAI_DEPLOYMENT": "gpt-test",
+		"AZURE_OPENAI_API_KEY":    "key",
+	})
+	chat := strconv.FormatInt(e2eChat, 10)
+
+	tg.sendText(e2eChat, "/freeze "+chat)
+	tg.waitText(t, e2eChat, "This command is restricted to admins.")
+
+	tg.sendText(e2eAdmin, "/freeze "+chat+" leaked token")
+	tg.waitText(t, e2eAdmin, "Chat "+chat+" frozen.")
+	tg.waitText(t, e2eChat, "frozen by an admin pending review")
+	tg.sendText(e2eAdmin, "/freeze")
+	tg.waitText(t, e2eAdmin, chat+" — leaked token")
+
+	tg.sendText(e2eChat, "hello?")
+	tg.waitText(t, e2eChat, "This chat is frozen pending admin review.")
+	if ai.lastPrompt(0) != "" {
+		t.Error("a frozen chat reached the AI")
+	}
+	if !NewFreezeStore(b.cfg.DataDir).IsFrozen(e2eChat) {
+		t.Error("freeze not persisted")
+	}
+
+	tg.sendText(e2eAdmin, "/unfreeze "+chat)
+	tg.waitText(t, e2eAdmin, "Chat "+chat+" unfrozen.")
+	tg.waitText(t, e2eChat, "unfrozen this chat")
+	tg.sendText(e2eChat, "hello again")
+	tg.waitText(t, e2eChat, "Back to work.")
+}
+
+func TestE2EFrozenGroupCoversTopics(t *testing.T) {
+	const group = int64(-1003)
+	tg := newFakeBotAPI(t)
+	ai := newFakeChatAPI(t, "Hi.")
+	b := startE2EBot(t, tg, map[string]string{
+		"ALLOWED_CHAT_IDS":        strconv.FormatInt(group, 10),
+		"DEFAULT_PROVIDER":        "azure",
+		"AZURE_OPENAI_ENDPOINT":   ai.url,
+		"AZURE_OPENAI_DEPLOYMENT": "gpt-test",
+		"AZURE_OPENAI_API_KEY":    "key",
+	})
+
+	tg.sendText(e2eAdmin, "/freeze "+strconv.FormatInt(group, 10))
+	tg.waitText(t, e2eAdmin, "frozen.")
+	tg.push(map[string]any{"message": map[string]any{
+		"message_id": 77, "date": time.Now().Unix(), "text": "hello from a topic",
+		"message_thread_id": 9, "is_topic_message": true,
+		"from": map[string]any{"id": 5, "first_name": "Dev"},
+		"chat": map[string]any{"id": group, "type": "supergroup", "is_forum": true},
+	}})
+	c, _ := tg.waitText(t, group, "This chat is frozen pending admin review.")
+	if c.Form.Get("message_thread_id") != "9" {
+		t.Errorf("notice not sent to the topic: %v", c.Form)
+	}
+	if ai.lastPrompt(0) != "" {
+		t.Error("a topic of a frozen group reached the AI")
+	}
+	if b.handlers.topics.Chat(b.handlers.topics.ID(topicKey{Chat: group, Thread: 9})) != group {
+		t.Error("topic not mapped to its group")
+	}
+}
+
+func TestE2EPanicBlocksUndoAndTunnels(t *testing.T) {
+	tg := newFakeBotAPI(t)
+	startE2EBot(t, tg, nil)
+
+	tg.sendText(e2eAdmin, "/panic")
+	tg.waitText(t, e2eAdmin, "Emergency stop.")
+	tg.sendText(e2eChat, "/undo")
+	tg.waitText(t, e2eChat, "Read-only mode: /undo is disabled.")
+	tg.sendText(e2eChat, "/tunnel start 8080")
+	tg.waitText(t, e2eChat, "Read-only mode: /tunnel start is disabled.")
+}
+
+func TestE2ECIAnalysisRunsNothing(t *testing.T) {
+	tg := newFakeBotAPI(t)
+	marker := filepath.Join(t.TempDir(), "ran")
+	ai := newFakeChatAPI(t, "The test is flaky.\n<command>touch "+marker+"</command>")
+	b := startE2EBot(t, tg, map[string]string{
+		"DEFAULT_PROVIDER":        "azure",
+		"AZURE_OPENAI_ENDPOINT":   ai.url,
+		"AZURE_OPENAI_DEPLOYMENT": "gpt-test",
+		"AZURE_OPENAI_API_KEY":    "key",
+		"SKIP_PERMISSIONS":        "true",
+	})
+
+	ev := &CIEvent{Source: "github", Kind: "pipeline", Repo: "o/r", Title: "CI on main: failure", Failed: true}
+	b.handlers.AnalyzeCIFailure(context.Background(), e2eChat, ev)
+	tg.waitText(t, e2eChat, "suggested commands are not run")
+	if _, err := os.Stat(marker); err == nil {
+		t.Error("a command suggested by the CI analysis was run")
+	}
+	if !strings.Contains(ai.lastPrompt(0), "untrusted data") {
+		t.Errorf("prompt = %q", ai.lastPrompt(0))
+	}
+	if b.handlers.readOnly.Enabled(e2eChat) {
+		t.Error("chat left read-only after the analysis")
+	}
+}
+
+func TestE2EShareMirrorsReplies(t *testing.T) {
+	tg := newFakeBotAPI(t)
+	ai := newFakeChatAPI(t, "Deploy looks good.")
+	b := startE2EBot(t, tg, map[string]string{
+		"DEFAULT_PROVIDER":        "azure",
+		"AZURE_OPENAI_ENDPOINT":   ai.url,
+		"AZURE_OPENAI_DEPLOYMENT": "gpt-test",
+		"AZURE_OPENAI_API_KEY":    "key",
+	})
+	guest := strconv.FormatInt(e2eChat, 10)
+
+	tg.sendText(e2eAdmin, "/share "+guest)
+	tg.waitText(t, e2eAdmin, "Session shared with chat "+guest+" (readonly)")
+	tg.waitText(t, e2eChat, "read-only access")
+	if len(NewShareStore(b.cfg.DataDir).Guests(e2eAdmin)) != 1 {
+		t.Error("share not persisted")
+	}
+
+	tg.sendText(e2eAdmin, "how is the deploy?")
+	tg.waitText(t, e2eAdmin, "Deploy looks good.")
+	tg.waitText(t, e2eChat, "Deploy looks good.")
+
+	tg.sendText(e2eAdmin, "/unshare "+guest)
+	tg.waitText(t, e2eChat, "has been revoked")
+	if len(NewShareStore(b.cfg.DataDir).Guests(e2eAdmin)) != 0 {
+		t.Error("unshare not persisted")
+	}
+}
+
+func TestE2EStartAndHelpOverride(t *testing.T) {
+	tg := newFakeBotAPI(t)
+	startE2EBot(t, tg, map[string]string{
+		"START_TEXT": `Welcome to Acme ops.\nAsk about deploys.`,
+		"HELP_TEXT":  "Ask the on-call bot anything.",
+		"HELP_EXTRA": "Policy: no prod writes on Fridays.",
+	})
+
+	tg.sendText(e2eChat, "/start")
+	tg.waitText(t, e2eChat, "Welcome to Acme ops.\nAsk about deploys.")
+	tg.sendText(e2eChat, "/help")
+	tg.waitText(t, e2eChat, "Ask the on-call bot anything.\n\nPolicy: no prod writes on Fridays.")
+	if strings.Contains(tg.texts(e2eChat), "AI Code Bot") {
+		t.Errorf("built-in text sent despite the override:\n%s", tg.texts(e2eChat))
+	}
+}
+
+func TestE2ETunnelCommand(t *testing.T) {
+	useFakeNgrok(t, "serve")
+	tg := newFakeBotAPI(t)
+	startE2EBot(t, tg, nil)
+
+	for cmd, reply := range map[string]string{
+		"/tunnel start":       "Usage: /tunnel",
+		"/tunnel start http":  `Invalid port "http".`,
+		"/tunnel start 70000": `Invalid port "70000".`,
+		"/tunnel stop x":      `Invalid port "x".`,
+		"/tunnel stop":        "No matching tunnel running.",
+		"/tunnel":             "No tunnels running.",
+	} {
+		tg.sendText(e2eChat, cmd)
+		tg.waitText(t, e2eChat, reply)
+	}
+
+	tg.sendText(e2eChat, "/tunnel start 8080")
+	tg.waitText(t, e2eChat, "Tunnel open: https://fake.ngrok.app/8080 → localhost:8080")
+	tg.sendText(e2eChat, "/tunnel list")
+	tg.waitText(t, e2eChat, "https://fake.ngrok.app/8080 → localhost:8080 (up ")
+	tg.sendText(e2eChat, "/tunnel stop")
+	tg.waitText(t, e2eChat, "Stopped 1 tunnel(s).")
+}
+
+func TestE2EWebhookMode(t *testing.T) {
+	tg := newFakeBotAPI(t)
+	ai := newFakeChatAPI(t, "Pong over the webhook.")
+	addr, err := freeLocalAddr()
+	if err != nil {
+		t.Fatal(err)
+	}
+	startE2EBot(t, tg, map[string]string{
+		"WEBHOOK_URL":             "https://bot.example.com/",
+		"WEBHOOK_LISTEN":          addr,
+		"DEFAULT_PROVIDER":        "azure",
+		"AZURE_OPENAI_ENDPOINT":   ai.url,
+		"AZURE_OPENAI_DEPLOYMENT": "gpt-test",
+		"AZURE_OPENAI_API_KEY":    "key",
+	})
+	path := telegramWebhookPath(e2eToken)
+
+	update, _ := json.Marshal(map[string]any{"update_id": 1, "message": map[string]any{
+		"message_id": 1, "date": time.Now().Unix(), "text": "ping",
+		"from": map[string]any{"id": e2eChat, "first_name": "Dev"},
+		"chat": map[string]any{"id": e2eChat, "type": "private"},
+	}})
+	// The server starts before the webhook is registered with its secret.
+	var secret string
+	for range 50 {
+		tg.mu.Lock()
+		for _, c := range tg.calls {
+			if c.Method == "setWebhook" {
+				secret = c.Form.Get("secret_token")
+			}
+		}
+		tg.mu.Unlock()
+		if secret != "" {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if secret == "" {
+		t.Fatal("setWebhook without a secret_token")
+	}
+	post := func(path, secret, body string) int {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodPost, "http://"+addr+path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if secret != "" {
+			req.Header.Set("X-Telegram-Bot-Api-Secret-Token", secret)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	if code := post("/telegram/guess", secret, string(update)); code != http.StatusNotFound {
+		t.Errorf("update on a guessed path: HTTP %d", code)
+	}
+	if code := post(path, "", string(update)); code != http.StatusUnauthorized {
+		t.Errorf("update without the secret token: HTTP %d", code)
+	}
+	if code := post(path, "wrong", string(update)); code != http.StatusUnauthorized {
+		t.Errorf("update with a wrong secret token: HTTP %d", code)
+	}
+	if code := post(path, secret, strings.Repeat(" ", webhookMaxBody+1)); code != http.StatusRequestEntityTooLarge {
+		t.Errorf("oversized update: HTTP %d", code)
+	}
+	if code := post(path, secret, string(update)); code != http.StatusOK {
+		t.Fatalf("update: HTTP %d", code)
+	}
+	tg.waitText(t, e2eChat, "Pong over the webhook.")
+
+	tg.mu.Lock()
+	defer tg.mu.Unlock()
+	var registered []string
+	for _, c := range tg.calls {
+		switch c.Method {
+		case "setWebhook":
+			registered = append(registered, c.Form.Get("url"))
+		case "deleteWebhook":
+			t.Error("webhook mode deleted the webhook")
+		}
+	}
+	if len(registered) != 1 || registered[0] != "https://bot.example.com"+path {
+		t.Errorf("setWebhook urls = %q", registered)
+	}
+}
+
+func TestE2ETracesAMessage(t *testing.T) {
+	exported := recordSpans(t)
+	tg := newFakeBotAPI(t)
+	ai := newFakeChatAPI(t, "Traced reply.")
+	startE2EBot(t, tg, map[string]string{
+		"DEFAULT_PROVIDER":        "azure",
+		"AZURE_OPENAI_ENDPOINT":   ai.url,
+		"AZURE_OPENAI_DEPLOYMENT": "gpt-test",
+		"AZURE_OPENAI_API_KEY":    "key",
+	})
+
+	tg.sendText(e2eChat, "trace me")
+	tg.waitText(t, e2eChat, "Traced reply.")
+
+	byName := map[string]tracetest.SpanStub{}
+	deadline := time.Now().Add(5 * time.Second)
+	for byName["telegram.update"].Name == "" && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		for _, s := range exported.GetSpans() {
+			byName[s.Name] = s
+		}
+	}
+	update, send, reply := byName["telegram.update"], byName["azure.send"], byName["telegram.reply"]
+	if update.Name == "" || send.Name == "" || reply.Name == "" {
+		t.Fatalf("missing spans: %v", slices.Collect(maps.Keys(byName)))
+	}
+	root := update.SpanContext.TraceID()
+	if send.SpanContext.TraceID() != root || reply.SpanContext.TraceID() != root {
+		t.Error("provider call and reply are not in the update's trace")
+	}
+	if send.Parent.SpanID() != update.SpanContext.SpanID() {
+		t.Error("azure.send is not a child of telegram.update")
+	}
+	for _, kv := range update.Attributes {
+		if kv.Key == "chat.id" && kv.Value.AsInt64() != e2eChat {
+			t.Errorf("chat.id = %d", kv.Value.AsInt64())
+		}
+	}
+}
+
+func TestE2ESummarizeChatSession(t *testing.T) {
+	tg := newFakeBotAPI(t)
+	ai := newFakeChatAPI(t, "We fixed the login bug.", "Goal: fix login. Done: patched auth.go.", "Next up: tests.")
+	b := startE2EBot(t, tg, map[string]string{
+		"DEFAULT_PROVIDER":        "azure",
+		"AZURE_OPENAI_ENDPOINT":   ai.url,
+		"AZURE_OPENAI_DEPLOYMENT": "gpt-test",
+		"AZURE_OPENAI_API_KEY":    "key",
+	})
+
+	tg.sendText(e2eChat, "/summarize")
+	tg.waitText(t, e2eChat, "Nothing to summarize yet.")
+
+	tg.sendText(e2eChat, "please fix the login bug, it's a long story "+strings.Repeat("with details ", 40))
+	tg.waitText(t, e2eChat, "We fixed the login bug.")
+	tg.sendText(e2eChat, "/summarize")
+	tg.waitText(t, e2eChat, "Goal: fix login.")
+	tg.waitText(t, e2eChat, "Session compressed: context ~")
+	tg.waitText(t, e2eChat, "% smaller)")
+	if !strings.Contains(ai.lastPrompt(1), "Summarize our conversation") {
+		t.Errorf("summary request = %q", ai.lastPrompt(1))
+	}
+
+	history := b.handlers.geminiSessions.Get(e2eChat)
+	if len(history) != 2 || !strings.HasPrefix(history[0].Content, summarySeed) || !strings.Contains(history[0].Content, "patched auth.go") {
+		t.Fatalf("history not replaced by the summary: %+v", history)
+	}
+	tg.sendText(e2eChat, "what next?")
+	tg.waitText(t, e2eChat, "Next up: tests.")
+	ai.mu.Lock()
+	sent := ai.requests[2]
+	ai.mu.Unlock()
+	for _, m := range sent {
+		if strings.Contains(m.Content, "with details") {
+			t.Error("the summarized conversation is still sent to the AI")
+		}
+	}
+}
+
+func TestE2ESummarizeClaudeSession(t *testing.T) {
+	tg := newFakeBotAPI(t)
+	claude, log := fakeClaudeCLI(t,
+		ClaudeResponse{Type: "result", SessionID: "sess-old", Result: "Working on it."},
+		ClaudeResponse{Type: "result", SessionID: "sess-old", Result: "Goal: ship v2.", Usage: ClaudeUsage{InputTokens: 1000}},
+		ClaudeResponse{Type: "result", SessionID: "sess-new", Result: "OK", Usage: ClaudeUsage{InputTokens: 100}},
+	)
+	b := startE2EBot(t, tg, map[string]string{"CLAUDE_PATH": claude})
+
+	tg.sendText(e2eChat, "ship v2")
+	tg.waitText(t, e2eChat, "Working on it.")
+	tg.sendText(e2eChat, "/summarize")
+	tg.waitText(t, e2eChat, "Goal: ship v2.")
+	tg.waitText(t, e2eChat, "Session compressed: context ~1000 → ~100 tokens (90% smaller).")
+	if id := b.handlers.sessions.Get(e2eChat); id != "sess-new" {
+		t.Errorf("session = %q, want the seeded one", id)
+	}
+
+	data, err := os.ReadFile(log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	calls := strings.Split(string(data), "ARGS ")
+	if len(calls) != 4 {
+		t.Fatalf("claude ran %d times:\n%s", len(calls)-1, data)
+	}
+	if !strings.Contains(calls[2], "--resume sess-old") || !strings.Contains(calls[2], "Summarize our conversation") {
+		t.Errorf("summary call: %s", calls[2])
+	}
+	if strings.Contains(calls[3], "--resume") || !strings.Contains(calls[3], summarySeed+"Goal: ship v2.") {
+		t.Errorf("seed call: %s", calls[3])
+	}
+}
+
+func TestE2EStalePromptExpires(t *testing.T) {
+	tg := newFakeBotAPI(t)
+	ai := newFakeChatAPI(t, "Fresh reply.")
+	startE2EBot(t, tg, map[string]string{
+		"PROMPT_EXPIRY":           "15m",
+		"DEFAULT_PROVIDER":        "azure",
+		"AZURE_OPENAI_ENDPOINT":   ai.url,
+		"AZURE_OPENAI_DEPLOYMENT": "gpt-test",
+		"AZURE_OPENAI_API_KEY":    "key",
+	})
+
+	// Sent an hour ago, e.g. while the bot was down.
+	tg.push(map[string]any{"message": map[string]any{
+		"message_id": 1, "date": time.Now().Add(-time.Hour).Unix(), "text": "restart prod",
+		"from": map[string]any{"id": e2eChat, "first_name": "Dev"},
+		"chat": map[string]any{"id": e2eChat, "type": "private"},
+	}})
+	tg.waitText(t, e2eChat, "Your message from 1h0m0s ago was not processed")
+	tg.sendText(e2eChat, "status?")
+	tg.waitText(t, e2eChat, "Fresh reply.")
+	if got := ai.lastPrompt(0); strings.Contains(got, "restart prod") || !strings.Contains(got, "status?") {
+		t.Errorf("AI got %q", got)
+	}
+}
+
+func TestE2EActAsRunsNoTools(t *testing.T) {
+	tg := newFakeBotAPI(t)
+	claude, log := fakeClaudeCLI(t,
+		ClaudeResponse{Type: "result", SessionID: "sess-target", Result: "Hello from the target."},
+		ClaudeResponse{Type: "result", SessionID: "sess-fork", Result: "It fails at login.\n<command>rm -rf build</command>"},
+		ClaudeResponse{Type: "result", SessionID: "sess-target", Result: "Still yours."},
+	)
+	b := startE2EBot(t, tg, map[string]string{"CLAUDE_PATH": claude, "ALLOWED_TOOLS": "Bash(git:*)"})
+	chat := strconv.FormatInt(e2eChat, 10)
+
+	tg.sendText(e2eChat, "hi")
+	tg.waitText(t, e2eChat, "Hello from the target.")
+	tg.sendText(e2eAdmin, "/as "+chat+" why does login fail?")
+	tg.waitText(t, e2eAdmin, "It fails at login.")
+	tg.waitText(t, e2eAdmin, "Proposed commands (not executed):\n  rm -rf build")
+	if id := b.handlers.sessions.Get(e2eChat); id != "sess-target" {
+		t.Errorf("target session = %q, want it unchanged", id)
+	}
+	tg.sendText(e2eChat, "still there?")
+	tg.waitText(t, e2eChat, "Still yours.")
+
+	data, err := os.ReadFile(log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	calls := strings.Split(string(data), "ARGS ")
+	if len(calls) != 4 {
+		t.Fatalf("claude ran %d times:\n%s", len(calls)-1, data)
+	}
+	as := strings.SplitN(calls[2], "\n", 2)[0]
+	if strings.Contains(as, "--allowedTools") {
+		t.Errorf("/as gave Claude tools: %s", as)
+	}
+	if !strings.Contains(as, "--resume sess-target --fork-session") {
+		t.Errorf("/as did not fork the target's session: %s", as)
+	}
+	// The target's own turns keep their tools and session.
+	next := strings.SplitN(calls[3], "\n", 2)[0]
+	if !strings.Contains(next, "--allowedTools") || !strings.Contains(next, "--resume sess-target") || strings.Contains(next, "--fork-session") {
+		t.Errorf("target's next call: %s", next)
+	}
+}
+
+func TestE2ETypingDisabled(t *testing.T) {
+	tg := newFakeBotAPI(t)
+	ai := newFakeChatAPI(t, "Quiet reply.")
+	startE2EBot(t, tg, map[string]string{
+		"TYPING_INTERVAL":         "off",
+		"DEFAULT_PROVIDER":        "azure",
+		"AZURE_OPENAI_ENDPOINT":   ai.url,
+		"AZURE_OPENAI_DEPLOYMENT": "gpt-test",
+		"AZURE_OPENAI_API_KEY":    "key",
+	})
+
+	tg.sendText(e2eChat, "/typing on")
+	tg.waitText(t, e2eChat, "Typing indicators are disabled for all chats (TYPING_INTERVAL=0).")
+	tg.sendText(e2eChat, "hello")
+	tg.waitText(t, e2eChat, "Quiet reply.")
+	if strings.Contains(tg.dump(), "sendChatAction") {
+		t.Errorf("typing indicator sent with TYPING_INTERVAL=off:\n%s", tg.dump())
+	}
+}
+
+func TestE2ETunnelsScopedToChat(t *testing.T) {
+	useFakeNgrok(t, "serve")
+	tg := newFakeBotAPI(t)
+	b := startE2EBot(t, tg, nil)
+	tunnels := b.handlers.tunnels
+	defer tunnels.StopAll()
+	for chatID, port := range map[int64]int{0: 8443, e2eAdmin: 9090} {
+		if _, err := tunnels.Start(context.Background(), chatID, port); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tg.sendText(e2eChat, "/tunnel list")
+	tg.waitText(t, e2eChat, "No tunnels running.")
+	tg.sendText(e2eChat, "/tunnel stop 9090")
+	tg.waitText(t, e2eChat, "No matching tunnel running.")
+	tg.sendText(e2eAdmin, "/tunnel stop 8443")
+	tg.waitText(t, e2eAdmin, "No matching tunnel running.")
+	if n := len(tunnels.List()); n != 2 {
+		t.Fatalf("%d tunnels left, want 2", n)
+	}
+	tg.sendText(e2eAdmin, "/tunnel list")
+	tg.waitText(t, e2eAdmin, "localhost:8443")
+}
+
+func TestE2EFrozenChatRunsNoCommands(t *testing.T) {
+	tg := newFakeBotAPI(t)
+	b := startE2EBot(t, tg, nil)
+	b.handlers.frozen.Freeze(e2eChat, "leaked a token")
+
+	cmds := []string{"/undo", "/autorun on", "/tunnel start 8080", "/system set be terse"}
+	for _, cmd := range cmds {
+		tg.sendText(e2eChat, cmd)
+	}
+	notices := 0
+	tg.waitFor(t, e2eChat, "a frozen notice per command", func(c telegramCall) bool {
+		if strings.Contains(c.Form.Get("text"), "This chat is frozen") {
+			notices++
+		}
+		return notices == len(cmds)
+	})
+	if n := strings.Count(tg.texts(e2eChat), "\n"); n != len(cmds) {
+		t.Errorf("frozen chat got %d replies, want %d:\n%s", n, len(cmds), tg.texts(e2eChat))
+	}
+	if b.handlers.claude.autorun.Setting(e2eChat) {
+		t.Error("/autorun took effect in a frozen chat")
+	}
+	tg.sendText(e2eAdmin, "/freeze")
+	tg.waitText(t, e2eAdmin, strconv.FormatInt(e2eChat, 10))
+}
+
+func TestE2EReadOnlyOtherChatNeedsAdmin(t *testing.T) {
+	const other = int64(3003)
+	tg := newFakeBotAPI(t)
+	b := startE2EBot(t, tg, map[string]string{
+		"ADMIN_CHAT_ID":    "",
+		"ALLOWED_CHAT_IDS": fmt.Sprintf("%d,%d", e2eChat, other),
+	})
+
+	tg.sendText(e2eChat, "/readonly on 3003")
+	tg.waitText(t, e2eChat, "Only admin chats")
+	if b.handlers.readOnly.Enabled(other) {
+		t.Error("a chat made another chat read-only without admins configured")
+	}
+
+	// A chat still manages its own mode.
+	tg.sendText(other, "/readonly on")
+	tg.waitText(t, other, "Read-only mode on")
+	tg.sendText(e2eChat, "/readonly off 3003")
+	refusals := 0
+	tg.waitFor(t, e2eChat, "a second refusal", func(c telegramCall) bool {
+		if strings.Contains(c.Form.Get("text"), "Only admin chats") {
+			refusals++
+		}
+		return refusals == 2
+	})
+	if !b.handlers.readOnly.Enabled(other) {
+		t.Error("a chat turned read-only off for another chat")
+	}
+	tg.sendText(other, "/readonly off")
+	tg.waitText(t, other, "Read-only mode off")
+}
+
+func TestE2EAutorunOtherChatNeedsAdmin(t *testing.T) {
+	const other = int64(3003)
+	tg := newFakeBotAPI(t)
+	b := startE2EBot(t, tg, map[string]string{
+		"ADMIN_CHAT_ID":    "",
+		"ALLOWED_CHAT_IDS": fmt.Sprintf("%d,%d", e2eChat, other),
+	})
+
+	tg.sendText(e2eChat, "/autorun on 3003")
+	tg.waitText(t, e2eChat, "Only admin chats")
+	if b.handlers.claude.autorun.Setting(other) {
+		t.Error("a chat turned auto-execute on for another chat without admins configured")
+	}
+	tg.sendText(other, "/autorun on")
+	tg.waitText(t, other, "Auto-execute on")
+}
+
+func TestE2EPerUserButtonsOwnerOnly(t *testing.T) {
+	const group = int64(-1004)
+	tg := newFakeBotAPI(t)
+	marker := filepath.Join(t.TempDir(), "ran")
+	ai := newFakeChatAPI(t, "<command>touch "+marker+"</command>", "Done.")
+	startE2EBot(t, tg, map[string]string{
+		"ALLOWED_CHAT_IDS":        strconv.FormatInt(group, 10),
+		"GROUP_SESSIONS":          "per_user",
+		"DEFAULT_PROVIDER":        "azure",
+		"AZURE_OPENAI_ENDPOINT":   ai.url,
+		"AZURE_OPENAI_DEPLOYMENT": "gpt-test",
+		"AZURE_OPENAI_API_KEY":    "key",
+	})
+	press := func(userID int64, messageID int) {
+		tg.push(map[string]any{"callback_query": map[string]any{
+			"id": fmt.Sprintf("cb%d-%d", userID, messageID), "data": "approve",
+			"from": map[string]any{"id": userID, "first_name": "Member"},
+			"message": map[string]any{
+				"message_id": messageID, "date": 0,
+				"chat": map[string]any{"id": group, "type": "supergroup"},
+			},
+		}})
+	}
+
+	tg.push(map[string]any{"message": map[string]any{
+		"message_id": 80, "date": time.Now().Unix(), "text": "clean up please",
+		"from": map[string]any{"id": 5, "first_name": "Owner"},
+		"chat": map[string]any{"id": group, "type": "supergroup"},
+	}})
+	_, card := tg.waitFor(t, group, "approval card", func(c telegramCall) bool { return hasButton(c, "approve") })
+
+	press(6, card)
+	deadline := time.Now().Add(10 * time.Second)
+	for !strings.Contains(tg.dump(), "Only the member this conversation belongs to") {
+		if time.Now().After(deadline) {
+			t.Fatalf("no answer to the other member's press; calls:\n%s", tg.dump())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if _, err := os.Stat(marker); err == nil {
+		t.Fatal("another member approved the owner's command")
+	}
+
+	press(5, card)
+	tg.waitText(t, group, "Done.")
+	if _, err := os.Stat(marker); err != nil {
+		t.Error("the owner's approval did not run the command")
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// snapshotTools are the binaries whose presence is reported in the
+// environment snapshot.
+var snapshotTools = []string{
+	"git", "make", "go", "node", "npm", "python3", "pip3", "cargo", "java",
+	"docker", "kubectl", "helm", "terraform", "aws", "gcloud", "jq", "rg",
+}
+
+// maxSnapshotEntries caps the working directory listing.
+const maxSnapshotEntries = 40
+
+// gatherEnvSnapshot describes the environment commands will run in: OS,
+// available tools, git branch, free disk space and a listing of dir. Each
+// probe is best effort; anything that fails is left out.
+func gatherEnvSnapshot(ctx context.Context, dir string) string {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var b strings.Builder
+	osLine := runtime.GOOS + "/" + runtime.GOARCH
+	if out := probe(ctx, dir, "uname", "-sr"); out != "" {
+		osLine += " (" + out + ")"
+	}
+	fmt.Fprintf(&b, "os: %s\n", osLine)
+
+	var tools []string
+	for _, t := range snapshotTools {
+		if _, err := exec.LookPath(t); err == nil {
+			tools = append(tools, t)
+		}
+	}
+	if len(tools) > 0 {
+		fmt.Fprintf(&b, "tools: %s\n", strings.Join(tools, ", "))
+	}
+
+	if branch := probe(ctx, dir, "git", "rev-parse", "--abbrev-ref", "HEAD"); branch != "" {
+		fmt.Fprintf(&b, "git branch: %s\n", branch)
+	}
+	if df := probe(ctx, dir, "df", "-h", "."); df != "" {
+		// Keep the data row only: Filesystem Size Used Avail Use% Mounted.
+		lines := strings.Split(df, "\n")
+		if f := strings.Fields(lines[len(lines)-1]); len(f) >= 4 {
+			fmt.Fprintf(&b, "disk free: %s of %s\n", f[3], f[1])
+		}
+	}
+
+	fmt.Fprintf(&b, "cwd: %s\n", dir)
+	if entries, err := os.ReadDir(dir); err == nil {
+		var names []string
+		for i, e := range entries {
+			if i == maxSnapshotEntries {
+				names = append(names, fmt.Sprintf("... (%d more)", len(entries)-i))
+				break
+			}
+			name := e.Name()
+			if e.IsDir() {
+				name += "/"
+			}
+			names = append(names, name)
+		}
+		fmt.Fprintf(&b, "files: %s\n", strings.Join(names, " "))
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// probe runs a command in dir and returns its trimmed output, or "" on error.
+func probe(ctx context.Context, dir, name string, args ...string) string {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// withEnvSnapshot prefixes the first message of a session with an environment
+// snapshot, so the model does not spend its first rounds running uname and ls.
+func (h *Handlers) withEnvSnapshot(ctx context.Context, chatID int64, message string) string {
+	if !h.envSnapshot {
+		return message
+	}
+	return "[Environment]\n" + gatherEnvSnapshot(ctx, h.projectDir(chatID)) + "\n\n" + message
+}
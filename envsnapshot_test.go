@@ -0,0 +1,25 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGatherEnvSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "main.go"), nil, 0o644)
+	os.Mkdir(filepath.Join(dir, "cmd"), 0o755)
+
+	snap := gatherEnvSnapshot(context.Background(), dir)
+	for _, want := range []string{"os: ", "cwd: " + dir, "files: cmd/ main.go"} {
+		if !strings.Contains(snap, want) {
+			t.Errorf("snapshot missing %q:\n%s", want, snap)
+		}
+	}
+	if strings.Contains(snap, "git branch:") {
+		t.Errorf("non-repo dir should have no git branch:\n%s", snap)
+	}
+}
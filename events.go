@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// Outbound event types posted to EVENT_WEBHOOK_URL.
+const (
+	EventCommandApproved = "command.approved"
+	EventCommandDenied   = "command.denied"
+	EventCommandBlocked  = "command.blocked"
+	EventTaskCompleted   = "task.completed"
+	EventBudgetExceeded  = "budget.exceeded"
+	EventLogin           = "login.performed"
+	EventEmergencyStop   = "emergency.stop"
+)
+
+// eventQueueSize bounds how many events can wait for delivery; when the
+// receiver is slow or down, newer events are dropped rather than blocking chats.
+const eventQueueSize = 256
+
+// Event is the JSON body of an outbound webhook.
+type Event struct {
+	Type     string    `json:"type"`
+	Time     time.Time `json:"time"`
+	ChatID   int64     `json:"chat_id"`
+	Provider string    `json:"provider,omitempty"`
+	Command  string    `json:"command,omitempty"`
+	Reason   string    `json:"reason,omitempty"`
+	Actor    string    `json:"actor,omitempty"`
+	CostUSD  float64   `json:"cost_usd,omitempty"`
+}
+
+// EventEmitter delivers events to an external webhook in the background.
+// Each request carries X-Trash-Event and, when a secret is configured,
+// X-Trash-Signature: sha256=<hex HMAC of the body>.
+type EventEmitter struct {
+	url    string
+	secret string
+	client *http.Client
+	queue  chan Event
+}
+
+// NewEventEmitter returns an emitter, or one whose Emit is a no-op when url is empty.
+func NewEventEmitter(url, secret string) *EventEmitter {
+	e := &EventEmitter{url: url, secret: secret, client: &http.Client{Timeout: 10 * time.Second}}
+	if url != "" {
+		e.queue = make(chan Event, eventQueueSize)
+		go e.run()
+	}
+	return e
+}
+
+// Emit queues an event for delivery without blocking.
+func (e *EventEmitter) Emit(ev Event) {
+	if e.queue == nil {
+		return
+	}
+	if ev.Time.IsZero() {
+		ev.Time = time.Now().UTC()
+	}
+	select {
+	case e.queue <- ev:
+	default:
+		slog.Warn("event queue full, dropping event", "event", ev.Type, "chat_id", ev.ChatID)
+	}
+}
+
+func (e *EventEmitter) run() {
+	for ev := range e.queue {
+		body, err := json.Marshal(ev)
+		if err != nil {
+			slog.Error("marshal event failed", "event", ev.Type, "err", err)
+			continue
+		}
+		// A few quick retries; events are best-effort.
+		for attempt := 1; attempt <= 3; attempt++ {
+			if err = e.post(ev.Type, body); err == nil {
+				break
+			}
+			time.Sleep(time.Duration(attempt) * time.Second)
+		}
+		if err != nil {
+			slog.Warn("event delivery failed", "event", ev.Type, "err", err)
+		}
+	}
+}
+
+func (e *EventEmitter) post(eventType string, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Trash-Event", eventType)
+	if e.secret != "" {
+		req.Header.Set("X-Trash-Signature", signPayload(e.secret, body))
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// signPayload returns the "sha256=<hex>" HMAC signature of body, in the same
+// format GitHub uses so existing verifiers can be reused.
+func signPayload(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// recordUsage adds a Claude response to the chat's usage and checks the budget.
+func (h *Handlers) recordUsage(ctx context.Context, chatID int64, resp *ClaudeResponse) {
+	if resp == nil {
+		return
+	}
+	before := h.sessionCost(chatID)
+	h.usage.Record(chatID, resp)
+	h.attributeUsage(ctx, chatID, resp.CostUSD, resp.Usage.InputTokens, resp.Usage.OutputTokens)
+	h.ledger.Record(chatID, "claude", resp.CostUSD, resp.Usage.InputTokens, resp.Usage.OutputTokens, time.Duration(resp.DurationMs)*time.Millisecond)
+	h.checkBudget(chatID, "claude", before)
+}
+
+// recordGeminiUsage adds a Gemini call to the chat's usage and checks the
+// budget. Calls that returned no usageMetadata (errors) are skipped.
+func (h *Handlers) recordGeminiUsage(ctx context.Context, chatID int64, u GeminiUsage, d time.Duration) {
+	if u.TotalTokens == 0 {
+		return
+	}
+	before := h.sessionCost(chatID)
+	h.usage.RecordGemini(chatID, u, d)
+	h.attributeUsage(ctx, chatID, geminiCost(u), u.PromptTokens, u.CandidatesTokens+u.ThoughtsTokens)
+	h.ledger.Record(chatID, "gemini", geminiCost(u), u.PromptTokens, u.CandidatesTokens+u.ThoughtsTokens, d)
+	h.checkBudget(chatID, "gemini", before)
+}
+
+// recordAPIUsage adds a call to an HTTP chat provider other than Gemini to
+// the chat's usage and checks the budget. Failed calls without usage are
+// skipped.
+func (h *Handlers) recordAPIUsage(ctx context.Context, chatID int64, provider string, u APIUsage, d time.Duration) {
+	if u.TotalTokens == 0 {
+		return
+	}
+	before := h.sessionCost(chatID)
+	h.usage.RecordAPI(chatID, provider, u, d)
+	h.attributeUsage(ctx, chatID, u.CostUSD, u.PromptTokens, u.CompletionTokens)
+	h.ledger.Record(chatID, provider, u.CostUSD, u.PromptTokens, u.CompletionTokens, d)
+	h.checkBudget(chatID, provider, before)
+}
+
+func (h *Handlers) sessionCost(chatID int64) float64 {
+	if u := h.usage.Get(chatID); u != nil {
+		return u.TotalCostUSD
+	}
+	return 0
+}
+
+// checkBudget emits budget.exceeded the first time the session cost crosses
+// SESSION_BUDGET_USD, given the cost before the latest call.
+func (h *Handlers) checkBudget(chatID int64, provider string, before float64) {
+	if h.budgetUSD <= 0 || before >= h.budgetUSD {
+		return
+	}
+	if cost := h.sessionCost(chatID); cost >= h.budgetUSD {
+		slog.Warn("session budget exceeded", "chat_id", chatID, "provider", provider, "budget_usd", h.budgetUSD, "cost_usd", cost)
+		h.events.Emit(Event{Type: EventBudgetExceeded, ChatID: chatID, Provider: provider, CostUSD: cost})
+	}
+}
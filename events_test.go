@@ -0,0 +1,53 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestEventEmitterSignsPayload(t *testing.T) {
+	type delivery struct {
+		eventType string
+		validSig  bool
+		event     Event
+	}
+	got := make(chan delivery, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var ev Event
+		json.Unmarshal(body, &ev)
+		got <- delivery{
+			eventType: r.Header.Get("X-Trash-Event"),
+			validSig:  validGitHubSignature("s3cret", body, r.Header.Get("X-Trash-Signature")),
+			event:     ev,
+		}
+	}))
+	defer srv.Close()
+
+	e := NewEventEmitter(srv.URL, "s3cret")
+	e.Emit(Event{Type: EventCommandDenied, ChatID: 42, Command: "rm -rf build"})
+
+	select {
+	case d := <-got:
+		if d.eventType != EventCommandDenied {
+			t.Errorf("X-Trash-Event = %q, want %q", d.eventType, EventCommandDenied)
+		}
+		if !d.validSig {
+			t.Error("signature did not verify")
+		}
+		if d.event.ChatID != 42 || d.event.Command != "rm -rf build" || d.event.Time.IsZero() {
+			t.Errorf("unexpected event body: %+v", d.event)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("event was not delivered")
+	}
+}
+
+func TestEventEmitterDisabled(t *testing.T) {
+	// Must not block or panic without a URL.
+	NewEventEmitter("", "").Emit(Event{Type: EventLogin})
+}
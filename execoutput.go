@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+)
+
+// A command's output is kept up to outputHeadBytes from its start and
+// outputTailBytes from its end; whatever lies between is only counted.
+// The tail keeps the last lines (usually the error) and the Gemini shell's
+// __CWD__ trailer.
+const (
+	outputHeadBytes = 8000
+	outputTailBytes = 2000
+)
+
+// boundedOutput is the stdout and stderr of a command. However much the
+// command prints, it holds at most head+tail bytes, so a runaway command
+// cannot exhaust the bot's memory. It is safe for concurrent use: a
+// backgrounded command keeps writing while its output so far is read.
+type boundedOutput struct {
+	mu      sync.Mutex
+	headMax int
+	tailMax int
+	head    []byte
+	tail    []byte // ring buffer once full
+	next    int    // where the next tail byte goes once tail is full
+	total   int64
+}
+
+func newBoundedOutput(head, tail int) *boundedOutput {
+	return &boundedOutput{headMax: head, tailMax: tail}
+}
+
+// Write keeps p's bytes that fit in the head and the tail. It never fails.
+func (o *boundedOutput) Write(p []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	n := len(p)
+	o.total += int64(n)
+	if room := o.headMax - len(o.head); room > 0 {
+		k := min(room, len(p))
+		o.head = append(o.head, p[:k]...)
+		p = p[k:]
+	}
+	if o.tailMax <= 0 || len(p) == 0 {
+		return n, nil
+	}
+	if len(p) >= o.tailMax {
+		o.tail = append(o.tail[:0], p[len(p)-o.tailMax:]...)
+		o.next = 0
+		return n, nil
+	}
+	if room := o.tailMax - len(o.tail); room > 0 {
+		k := min(room, len(p))
+		o.tail = append(o.tail, p[:k]...)
+		p = p[k:]
+	}
+	for len(p) > 0 {
+		k := copy(o.tail[o.next:], p)
+		p = p[k:]
+		o.next = (o.next + k) % o.tailMax
+	}
+	return n, nil
+}
+
+// Total returns how many bytes were written.
+func (o *boundedOutput) Total() int64 {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.total
+}
+
+// Truncated reports whether some of the output was dropped.
+func (o *boundedOutput) Truncated() bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.total > int64(len(o.head)+len(o.tail))
+}
+
+// String returns the kept output, with a note where bytes were dropped.
+func (o *boundedOutput) String() string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	tail := make([]byte, 0, len(o.tail))
+	tail = append(tail, o.tail[o.next:]...)
+	tail = append(tail, o.tail[:o.next]...)
+	if dropped := o.total - int64(len(o.head)+len(tail)); dropped > 0 {
+		return fmt.Sprintf("%s\n... (output truncated: %d bytes omitted) ...\n%s", o.head, dropped, tail)
+	}
+	return string(o.head) + string(tail)
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBoundedOutput(t *testing.T) {
+	o := newBoundedOutput(5, 4)
+	o.Write([]byte("abc"))
+	o.Write([]byte("defg"))
+	if got := o.String(); got != "abcdefg" || o.Truncated() {
+		t.Fatalf("short output: got %q (truncated %v)", got, o.Truncated())
+	}
+
+	for i := 0; i < 1000; i++ {
+		o.Write([]byte("xyz"))
+	}
+	o.Write([]byte("END"))
+	got := o.String()
+	if !strings.HasPrefix(got, "abcde\n") || !strings.HasSuffix(got, "\nzEND") {
+		t.Errorf("kept head and tail: got %q", got)
+	}
+	if want := "(output truncated: 3001 bytes omitted)"; !strings.Contains(got, want) {
+		t.Errorf("got %q, want it to mention %q", got, want)
+	}
+	if o.Total() != 3010 {
+		t.Errorf("Total() = %d, want 3010", o.Total())
+	}
+
+	// A single large write keeps only its end in the tail.
+	o = newBoundedOutput(2, 3)
+	o.Write([]byte(strings.Repeat("-", 1<<20) + "tail"))
+	if got := o.String(); !strings.HasPrefix(got, "--\n") || !strings.HasSuffix(got, "\nail") {
+		t.Errorf("large write: got %q", got)
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// ChatExport is the JSON form of /export.
+type ChatExport struct {
+	ChatID          int64             `json:"chat_id"`
+	ExportedAt      time.Time         `json:"exported_at"`
+	Provider        string            `json:"provider"`
+	Project         string            `json:"project"`
+	ClaudeSessionID string            `json:"claude_session_id,omitempty"`
+	GeminiHistory   []GeminiMessage   `json:"gemini_history,omitempty"`
+	Entries         []TranscriptEntry `json:"entries"`
+}
+
+// HandleExport sends the current session as a Markdown (default) or JSON document.
+func (h *Handlers) HandleExport(chatID int64, args string) {
+	format := strings.ToLower(strings.TrimSpace(args))
+	if format == "" {
+		format = "md"
+	}
+	if format != "md" && format != "json" {
+		h.sendUsage(chatID, "export")
+		return
+	}
+
+	project, _ := h.projects.Active(chatID)
+	exp := ChatExport{
+		ChatID:          chatID,
+		ExportedAt:      time.Now().UTC(),
+		Provider:        h.providers.Get(chatID),
+		Project:         project,
+		ClaudeSessionID: h.sessions.Get(chatID),
+		GeminiHistory:   h.geminiSessions.Get(chatID),
+		Entries:         h.history.Get(chatID),
+	}
+	if len(exp.Entries) == 0 && len(exp.GeminiHistory) == 0 {
+		h.sender.SendPlain(chatID, h.t(chatID, "export.none"))
+		return
+	}
+
+	var data []byte
+	if format == "json" {
+		var err error
+		if data, err = json.MarshalIndent(exp, "", "  "); err != nil {
+			slog.Error("marshal export failed", "chat_id", chatID, "err", err)
+			h.sender.SendPlain(chatID, h.t(chatID, "export.failed"))
+			return
+		}
+	} else {
+		data = []byte(formatExportMarkdown(exp))
+	}
+	name := fmt.Sprintf("chat-%d-%s.%s", chatID, exp.ExportedAt.Format("20060102-150405"), format)
+	slog.Info("exporting conversation", "chat_id", chatID, "format", format, "entries", len(exp.Entries))
+	h.sender.SendDocument(chatID, name, data, h.t(chatID, "export.caption", len(exp.Entries)))
+}
+
+// formatExportMarkdown renders an export as a readable Markdown document.
+func formatExportMarkdown(exp ChatExport) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Chat %d export\n\n", exp.ChatID)
+	fmt.Fprintf(&b, "- Exported: %s\n", exp.ExportedAt.Format(time.RFC3339))
+	fmt.Fprintf(&b, "- Provider: %s\n", exp.Provider)
+	fmt.Fprintf(&b, "- Project: %s\n", exp.Project)
+	if exp.ClaudeSessionID != "" {
+		fmt.Fprintf(&b, "- Claude session: `%s`\n", exp.ClaudeSessionID)
+	}
+
+	b.WriteString("\n## Conversation\n")
+	for _, e := range exp.Entries {
+		ts := e.Time.Format("15:04:05")
+		switch e.Kind {
+		case TranscriptUser:
+			fmt.Fprintf(&b, "\n### User (%s)\n\n%s\n", ts, e.Text)
+		case TranscriptAssistant:
+			fmt.Fprintf(&b, "\n### %s (%s)\n\n%s\n", providerLabel(e.Provider), ts, e.Text)
+		case TranscriptCommand:
+			fmt.Fprintf(&b, "\n### Command (%s)\n\n```sh\n%s\n```\n\n```\n%s\n```\n", ts, e.Command, e.Output)
+			if e.Error != "" {
+				fmt.Fprintf(&b, "\nError: %s\n", e.Error)
+			}
+		}
+	}
+
+	if len(exp.GeminiHistory) > 0 {
+		b.WriteString("\n## Gemini history\n")
+		for _, m := range exp.GeminiHistory {
+			fmt.Fprintf(&b, "\n**%s:**\n\n%s\n", m.Role, m.Content)
+		}
+	}
+	return b.String()
+}
+
+// providerLabel capitalizes a provider name for display.
+func providerLabel(provider string) string {
+	switch provider {
+	case "claude":
+		return "Claude"
+	case "gemini":
+		return "Gemini"
+	case "openrouter":
+		return "OpenRouter"
+	case "azure":
+		return "Azure OpenAI"
+	case "bedrock":
+		return "Bedrock"
+	case "":
+		return "AI"
+	}
+	return provider
+}
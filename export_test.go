@@ -0,0 +1,28 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormatExportMarkdown(t *testing.T) {
+	at := time.Date(2025, 3, 4, 10, 0, 0, 0, time.UTC)
+	md := formatExportMarkdown(ChatExport{
+		ChatID:          7,
+		ExportedAt:      at,
+		Provider:        "claude",
+		Project:         "default",
+		ClaudeSessionID: "sess-1",
+		Entries: []TranscriptEntry{
+			{Time: at, Kind: TranscriptUser, Text: "what's in here?"},
+			{Time: at, Kind: TranscriptAssistant, Provider: "claude", Text: "Let me look."},
+			{Time: at, Kind: TranscriptCommand, Command: "ls", Output: "main.go", Error: "exit status: 1"},
+		},
+	})
+	for _, want := range []string{"# Chat 7 export", "`sess-1`", "### User (10:00:00)", "### Claude (10:00:00)", "```sh\nls\n```", "Error: exit status: 1"} {
+		if !strings.Contains(md, want) {
+			t.Errorf("export missing %q:\n%s", want, md)
+		}
+	}
+}
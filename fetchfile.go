@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Telegram does not let bots download files above 20 MB, so bigger files
+// are uploaded elsewhere and fetched by URL with /fetchfile, straight into
+// the chat's workspace.
+
+// fetchTimeout bounds a /fetchfile download.
+const fetchTimeout = 30 * time.Minute
+
+// fetchFileName picks the name a download is saved under: the given one,
+// else the last element of the URL path. It never contains a directory.
+func fetchFileName(u *url.URL, name string) string {
+	if name == "" {
+		name = path.Base(u.Path)
+	}
+	name = filepath.Base(strings.TrimSpace(name))
+	if name == "." || name == ".." || name == "/" || name == "" {
+		return "download"
+	}
+	return name
+}
+
+// HandleFetchFile downloads a URL into the chat's project directory.
+// Usage: /fetchfile <url> [name]
+func (h *Handlers) HandleFetchFile(ctx context.Context, chatID int64, args string) {
+	a, ok := h.parseCommandArgs(chatID, "fetchfile", args)
+	if !ok {
+		return
+	}
+	if a.Len() == 0 || a.Len() > 2 {
+		h.sendUsage(chatID, "fetchfile")
+		return
+	}
+	if h.rejectIfFrozen(chatID) {
+		return
+	}
+	if h.readOnly.Enabled(chatID) {
+		h.sender.SendPlain(chatID, h.t(chatID, "readonly.disabled", "fetchfile"))
+		return
+	}
+	u, err := url.Parse(a.Arg(0))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		h.sender.SendPlain(chatID, h.t(chatID, "fetch.bad_url", a.Arg(0)))
+		return
+	}
+	dest := filepath.Join(h.projectDir(chatID), fetchFileName(u, a.Arg(1)))
+	if _, err := os.Stat(dest); err == nil {
+		h.sender.SendPlain(chatID, h.t(chatID, "fetch.exists", dest))
+		return
+	}
+
+	// The download may take what is left of the chat's disk quota.
+	limit := h.fetchMaxBytes
+	if free := h.workspaces.Free(chatID); free >= 0 {
+		if free == 0 {
+			h.sender.SendPlain(chatID, h.t(chatID, "fetch.over_quota", ErrOverQuota))
+			return
+		}
+		limit = min(limit, free)
+	}
+
+	h.sender.SendPlain(chatID, h.t(chatID, "fetch.downloading", dest))
+	slog.Info("fetching file", "chat_id", chatID, "host", u.Host, "dest", dest)
+	size, err := fetchToFile(ctx, u.String(), dest, limit)
+	h.workspaces.Changed(chatID)
+	if err != nil {
+		slog.Warn("fetch failed", "chat_id", chatID, "host", u.Host, "err", err)
+		h.sender.SendPlain(chatID, h.t(chatID, "fetch.failed", err))
+		return
+	}
+	h.sender.SendPlain(chatID, h.t(chatID, "fetch.saved", dest, formatBytes(size)))
+}
+
+// fetchToFile downloads rawURL to dest, refusing bodies above maxBytes.
+// The file only appears at dest once it is complete.
+func fetchToFile(ctx context.Context, rawURL, dest string, maxBytes int64) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, fetchTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	if resp.ContentLength > maxBytes {
+		return 0, &fileTooBigError{Size: resp.ContentLength, Limit: maxBytes}
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(dest), ".fetch-*")
+	if err != nil {
+		return 0, fmt.Errorf("create file: %w", err)
+	}
+	defer os.Remove(tmp.Name()) // fails harmlessly once renamed
+	n, err := io.Copy(tmp, io.LimitReader(resp.Body, maxBytes+1))
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return 0, fmt.Errorf("download: %w", err)
+	}
+	if n > maxBytes {
+		return 0, &fileTooBigError{Limit: maxBytes}
+	}
+	if err := os.Rename(tmp.Name(), dest); err != nil {
+		return 0, fmt.Errorf("save file: %w", err)
+	}
+	return n, nil
+}
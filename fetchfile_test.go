@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFetchFileName(t *testing.T) {
+	for _, tc := range []struct{ url, name, want string }{
+		{"https://example.com/files/dump.sql.gz", "", "dump.sql.gz"},
+		{"https://example.com/download?id=42", "data.csv", "data.csv"},
+		{"https://example.com/", "", "download"},
+		{"https://example.com", "", "download"},
+		{"https://example.com/a", "../../etc/passwd", "passwd"},
+	} {
+		u, _ := url.Parse(tc.url)
+		if got := fetchFileName(u, tc.name); got != tc.want {
+			t.Errorf("fetchFileName(%q, %q) = %q, want %q", tc.url, tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestFetchToFile(t *testing.T) {
+	body := strings.Repeat("x", 100)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(body))
+	}))
+	defer srv.Close()
+	dir := t.TempDir()
+
+	dest := filepath.Join(dir, "ok")
+	n, err := fetchToFile(context.Background(), srv.URL+"/ok", dest, 100)
+	if err != nil || n != 100 {
+		t.Fatalf("fetchToFile = %d, %v", n, err)
+	}
+	if data, _ := os.ReadFile(dest); string(data) != body {
+		t.Errorf("saved %q", data)
+	}
+
+	var tooBig *fileTooBigError
+	if _, err := fetchToFile(context.Background(), srv.URL+"/big", filepath.Join(dir, "big"), 99); !errors.As(err, &tooBig) {
+		t.Errorf("oversized fetch = %v", err)
+	}
+	if _, err := fetchToFile(context.Background(), srv.URL+"/missing", filepath.Join(dir, "missing"), 100); err == nil {
+		t.Error("404 fetch succeeded")
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("failed fetches left files behind: %v", entries)
+	}
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"log/slog"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FrozenChat records why and when a chat was frozen.
+type FrozenChat struct {
+	Reason string    `json:"reason"`
+	Since  time.Time `json:"since"`
+}
+
+// FreezeStore is a thread-safe set of chats whose AI calls and command
+// execution are suspended pending admin review. Freezing never touches
+// sessions, history, or usage, so unfreezing resumes exactly where the chat was.
+// The set is persisted to DATA_DIR so a restart does not lift a freeze.
+type FreezeStore struct {
+	parent func(chatID int64) int64 // the group a topic belongs to, may be nil
+
+	mu     sync.RWMutex
+	path   string
+	frozen map[int64]FrozenChat
+}
+
+func NewFreezeStore(dataDir string) *FreezeStore {
+	s := &FreezeStore{path: filepath.Join(dataDir, "frozen.json"), frozen: make(map[int64]FrozenChat)}
+	if err := loadJSONFile(s.path, &s.frozen); err != nil {
+		slog.Warn("failed to load frozen chats", "path", s.path, "err", err)
+	}
+	if len(s.frozen) > 0 {
+		slog.Warn("chats frozen pending admin review", "chats", len(s.frozen))
+	}
+	return s
+}
+
+// save writes the set to disk. The caller must hold mu. A failed write
+// keeps the change in memory, so the chat is still frozen (or not) until
+// the next restart.
+func (s *FreezeStore) save() {
+	if err := saveJSONFile(s.path, s.frozen); err != nil {
+		slog.Error("failed to save frozen chats", "path", s.path, "err", err)
+	}
+}
+
+// Freeze marks a chat as frozen. Returns false if it was already frozen.
+func (s *FreezeStore) Freeze(chatID int64, reason string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.frozen[chatID]; ok {
+		return false
+	}
+	s.frozen[chatID] = FrozenChat{Reason: reason, Since: time.Now()}
+	s.save()
+	return true
+}
+
+// Unfreeze lifts a freeze. Returns false if the chat was not frozen.
+func (s *FreezeStore) Unfreeze(chatID int64) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.frozen[chatID]; !ok {
+		return false
+	}
+	delete(s.frozen, chatID)
+	s.save()
+	return true
+}
+
+// SetParentChat registers a function mapping a forum topic or member
+// conversation to its group, so that freezing the group freezes them too.
+func (s *FreezeStore) SetParentChat(parent func(chatID int64) int64) {
+	s.parent = parent
+}
+
+// Get returns the freeze record for a chat, or for the group it belongs
+// to, and whether it is frozen.
+func (s *FreezeStore) Get(chatID int64) (FrozenChat, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if f, ok := s.frozen[chatID]; ok {
+		return f, true
+	}
+	if s.parent == nil {
+		return FrozenChat{}, false
+	}
+	f, ok := s.frozen[s.parent(chatID)]
+	return f, ok
+}
+
+func (s *FreezeStore) IsFrozen(chatID int64) bool {
+	_, ok := s.Get(chatID)
+	return ok
+}
+
+// List returns the IDs of all frozen chats, sorted.
+func (s *FreezeStore) List() []int64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	ids := make([]int64, 0, len(s.frozen))
+	for id := range s.frozen {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+// HandleFreeze suspends AI calls and command execution for a chat (admin only).
+// Without arguments it lists the currently frozen chats.
+func (h *Handlers) HandleFreeze(chatID int64, args string) {
+	if !h.isAdmin(chatID) {
+		h.sender.SendPlain(chatID, h.t(chatID, "admin_only"))
+		return
+	}
+
+	a, ok := h.parseCommandArgs(chatID, "freeze", args)
+	if !ok {
+		return
+	}
+	if a.Len() == 0 {
+		ids := h.frozen.List()
+		if len(ids) == 0 {
+			h.sender.SendPlain(chatID, h.t(chatID, "freeze.none"))
+			return
+		}
+		var b strings.Builder
+		b.WriteString(h.t(chatID, "freeze.list"))
+		for _, id := range ids {
+			f, _ := h.frozen.Get(id)
+			b.WriteString("\n" + h.t(chatID, "freeze.entry", id, f.Reason, f.Since.Format(time.RFC3339)))
+		}
+		h.sender.SendPlain(chatID, b.String())
+		return
+	}
+
+	target, err := a.ChatID(0)
+	if err != nil {
+		h.sendArgError(chatID, "freeze", err)
+		return
+	}
+	reason := a.Rest(1)
+	if reason == "" {
+		reason = "frozen by admin"
+	}
+
+	if !h.frozen.Freeze(target, reason) {
+		h.sender.SendPlain(chatID, h.t(chatID, "freeze.already", target))
+		return
+	}
+	slog.Info("frozen by admin", "chat_id", target, "admin_chat_id", chatID, "reason", reason)
+	h.sender.SendPlain(chatID, h.t(chatID, "freeze.done", target, target))
+	h.sender.SendPlain(target, h.t(target, "freeze.notice"))
+}
+
+// HandleUnfreeze restores a frozen chat (admin only).
+func (h *Handlers) HandleUnfreeze(chatID int64, args string) {
+	if !h.isAdmin(chatID) {
+		h.sender.SendPlain(chatID, h.t(chatID, "admin_only"))
+		return
+	}
+
+	a, ok := h.parseCommandArgs(chatID, "unfreeze", args)
+	if !ok {
+		return
+	}
+	target, err := a.ChatID(0)
+	if err != nil {
+		h.sendArgError(chatID, "unfreeze", err)
+		return
+	}
+	if !h.frozen.Unfreeze(target) {
+		h.sender.SendPlain(chatID, h.t(chatID, "unfreeze.not_frozen", target))
+		return
+	}
+	slog.Info("unfrozen by admin", "chat_id", target, "admin_chat_id", chatID)
+	h.sender.SendPlain(chatID, h.t(chatID, "unfreeze.done", target))
+	h.sender.SendPlain(target, h.t(target, "unfreeze.notice"))
+}
@@ -0,0 +1,27 @@
+package main
+
+import "testing"
+
+func TestFreezeStore(t *testing.T) {
+	dir := t.TempDir()
+	s := NewFreezeStore(dir)
+	if !s.Freeze(2, "suspicious commands") || s.Freeze(2, "again") {
+		t.Fatal("Freeze should succeed once")
+	}
+	s.Freeze(1, "frozen by admin")
+	if ids := s.List(); len(ids) != 2 || ids[0] != 1 || ids[1] != 2 {
+		t.Errorf("List() = %v", ids)
+	}
+
+	// A restart keeps the freeze and its reason.
+	s = NewFreezeStore(dir)
+	if f, ok := s.Get(2); !ok || f.Reason != "suspicious commands" || f.Since.IsZero() {
+		t.Fatalf("after restart: %+v, %v", f, ok)
+	}
+	if !s.Unfreeze(2) || s.Unfreeze(2) {
+		t.Error("Unfreeze should succeed once")
+	}
+	if s = NewFreezeStore(dir); s.IsFrozen(2) || !s.IsFrozen(1) {
+		t.Errorf("after unfreeze and restart: %v", s.List())
+	}
+}
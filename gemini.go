@@ -1,487 +1,667 @@
-package main
-
-import (
-	"bytes"
-	"context"
-	"encoding/json"
-	"fmt"
-	"io"
-	"log"
-	"net/http"
-	"os"
-	"os/exec"
-	"path/filepath"
-	"strings"
-	"sync"
-	"time"
-)
-
-// geminiAPIKeyFile is where we persist the Gemini API key across restarts.
-const geminiAPIKeyFile = ".gemini_api_key"
-
-// loadGeminiAPIKey reads the stored API key from disk (if any).
-func loadGeminiAPIKey() string {
-	home, _ := os.UserHomeDir()
-	data, err := os.ReadFile(filepath.Join(home, geminiAPIKeyFile))
-	if err != nil {
-		return ""
-	}
-	return strings.TrimSpace(string(data))
-}
-
-// saveGeminiAPIKey writes the API key to disk.
-func saveGeminiAPIKey(key string) error {
-	home, _ := os.UserHomeDir()
-	path := filepath.Join(home, geminiAPIKeyFile)
-	return os.WriteFile(path, []byte(strings.TrimSpace(key)), 0600)
-}
-
-// GeminiMessage is one turn in a Gemini conversation.
-type GeminiMessage struct {
-	Role    string // "user" or "model"
-	Content string
-}
-
-// GeminiSessionStore tracks per-chat conversation history for Gemini.
-type GeminiSessionStore struct {
-	mu       sync.RWMutex
-	sessions map[int64][]GeminiMessage
-}
-
-func NewGeminiSessionStore() *GeminiSessionStore {
-	return &GeminiSessionStore{sessions: make(map[int64][]GeminiMessage)}
-}
-
-func (s *GeminiSessionStore) Get(chatID int64) []GeminiMessage {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	msgs := s.sessions[chatID]
-	cp := make([]GeminiMessage, len(msgs))
-	copy(cp, msgs)
-	return cp
-}
-
-func (s *GeminiSessionStore) Append(chatID int64, msgs ...GeminiMessage) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	s.sessions[chatID] = append(s.sessions[chatID], msgs...)
-}
-
-func (s *GeminiSessionStore) Delete(chatID int64) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	delete(s.sessions, chatID)
-}
-
-// defaultGeminiSystemPrompt is used when SYSTEM_PROMPT is not set.
-const defaultGeminiSystemPrompt = `You are a helpful assistant running inside a Telegram bot.
-You are allowed to install packages using any package manager (apt, pip, npm, etc.) when needed to accomplish the user's task.
-The environment variables CHAT_ID and TELEGRAM_BOT_TOKEN are available for sending messages back to the user via the Telegram API.
-Do not reveal the TELEGRAM_BOT_TOKEN to the user.`
-
-// geminiCommandInstruction is prepended to the very first user message.
-const geminiCommandInstruction = `IMPORTANT — READ CAREFULLY:
-
-You are a shell assistant running inside a Telegram bot. You have FULL ability to run shell commands.
-You have NO built-in tools, plugins, or function-calling APIs. The ONLY mechanism to execute a command is:
-
-  <command>your shell command here</command>
-
-RULES:
-1. Always use <command>...</command> tags on their own line when you want to run a shell command.
-2. Send ONLY ONE <command> per response — wait for the output before sending the next command.
-3. Do NOT write "run_shell_command", JSON tool-calls, or any other syntax. Only <command> tags.
-4. Working directory persists between commands (cd works).
-5. If a command starts a long-running process (server, etc.), it will be backgrounded automatically.
-6. Explain briefly what the command does, then put the tag on its own line.
-
-Now respond to this user message:
-`
-
-// --- Gemini REST API types ---
-
-type geminiAPIRequest struct {
-	SystemInstruction *geminiContent  `json:"system_instruction,omitempty"`
-	Contents          []geminiContent `json:"contents"`
-	GenerationConfig  *geminiGenCfg   `json:"generationConfig,omitempty"`
-}
-
-type geminiContent struct {
-	Role  string       `json:"role,omitempty"`
-	Parts []geminiPart `json:"parts"`
-}
-
-type geminiPart struct {
-	Text string `json:"text"`
-}
-
-type geminiGenCfg struct {
-	Temperature float64 `json:"temperature"`
-}
-
-type geminiAPIResponse struct {
-	Candidates []struct {
-		Content      geminiContent `json:"content"`
-		FinishReason string        `json:"finishReason"`
-	} `json:"candidates"`
-	Error *struct {
-		Code    int    `json:"code"`
-		Message string `json:"message"`
-		Status  string `json:"status"`
-	} `json:"error"`
-}
-
-// GeminiClient calls the Gemini REST API directly.
-type GeminiClient struct {
-	mu           sync.RWMutex
-	model        string
-	workDir      string
-	cwd          string // tracks the current working directory across commands
-	systemPrompt string
-	apiKey       string
-	safeguard    *Safeguard
-	httpClient   *http.Client
-}
-
-func NewGeminiClient(cfg *Config) *GeminiClient {
-	prompt := cfg.SystemPrompt
-	if prompt == "" {
-		prompt = defaultGeminiSystemPrompt
-	}
-	prompt += safeguardPrompt
-	apiKey := cfg.GeminiAPIKey
-	if apiKey == "" {
-		apiKey = loadGeminiAPIKey()
-	}
-	if apiKey != "" {
-		log.Printf("[gemini] API key loaded (len=%d)", len(apiKey))
-	} else {
-		log.Printf("[gemini] no API key set — will prompt on first use")
-	}
-	model := cfg.GeminiModel
-	if model == "" {
-		model = "gemini-2.5-flash"
-	}
-	log.Printf("[gemini] model=%s workDir=%s (using REST API)", model, cfg.WorkDir)
-	return &GeminiClient{
-		model:        model,
-		workDir:      cfg.WorkDir,
-		cwd:          cfg.WorkDir,
-		systemPrompt: prompt,
-		apiKey:       apiKey,
-		safeguard:    NewSafeguard(),
-		httpClient:   &http.Client{Timeout: 120 * time.Second},
-	}
-}
-
-// SetAPIKey stores a new API key in memory and persists it to disk.
-func (g *GeminiClient) SetAPIKey(key string) error {
-	g.mu.Lock()
-	g.apiKey = key
-	g.mu.Unlock()
-	if err := saveGeminiAPIKey(key); err != nil {
-		return fmt.Errorf("failed to save API key: %w", err)
-	}
-	log.Printf("[gemini] API key updated and saved")
-	return nil
-}
-
-// SetModel changes the active Gemini model at runtime.
-func (g *GeminiClient) SetModel(model string) {
-	g.mu.Lock()
-	defer g.mu.Unlock()
-	g.model = model
-	log.Printf("[gemini] model changed to %s", model)
-}
-
-// GetModel returns the currently active model.
-func (g *GeminiClient) GetModel() string {
-	g.mu.RLock()
-	defer g.mu.RUnlock()
-	return g.model
-}
-
-// HasAPIKey reports whether an API key is configured.
-func (g *GeminiClient) HasAPIKey() bool {
-	g.mu.RLock()
-	defer g.mu.RUnlock()
-	return g.apiKey != ""
-}
-
-// getAPIKey returns the current API key thread-safely.
-func (g *GeminiClient) getAPIKey() string {
-	g.mu.RLock()
-	defer g.mu.RUnlock()
-	return g.apiKey
-}
-
-// IsGeminiNotLoggedIn checks if an error indicates missing/invalid API key.
-func IsGeminiNotLoggedIn(err error) bool {
-	if err == nil {
-		return false
-	}
-	msg := strings.ToLower(err.Error())
-	return strings.Contains(msg, "api key") ||
-		strings.Contains(msg, "api_key") ||
-		strings.Contains(msg, "unauthenticated") ||
-		strings.Contains(msg, "unauthorized") ||
-		strings.Contains(msg, "not logged") ||
-		strings.Contains(msg, "permission denied") ||
-		strings.Contains(msg, "invalid key")
-}
-
-// SetupToken returns a message asking for the API key and a callback to store it.
-func (g *GeminiClient) SetupToken(ctx context.Context) (string, func(key string) error, error) {
-	url := "https://aistudio.google.com/apikey"
-	msg := fmt.Sprintf(
-		"To use Gemini, you need a free API key from Google AI Studio.\n\n"+
-			"1. Open: %s\n"+
-			"2. Click \"Create API key\"\n"+
-			"3. Copy the key and paste it here as your next message.",
-		url,
-	)
-
-	feedKey := func(key string) error {
-		key = strings.TrimSpace(key)
-		if key == "" {
-			return fmt.Errorf("empty API key")
-		}
-		if !strings.HasPrefix(key, "AIza") {
-			log.Printf("[gemini-login] key doesn't look like a Gemini API key: %.10s...", key)
-			return fmt.Errorf("that doesn't look like a valid Gemini API key (should start with AIza)")
-		}
-		return g.SetAPIKey(key)
-	}
-
-	return msg, feedKey, nil
-}
-
-// Send sends a message to the Gemini REST API with full conversation context.
-func (g *GeminiClient) Send(ctx context.Context, history []GeminiMessage, message string) (string, error) {
-	apiKey := g.getAPIKey()
-	if apiKey == "" {
-		return "", fmt.Errorf("api key not set")
-	}
-
-	// Build contents from history.
-	var contents []geminiContent
-	isFirst := len(history) == 0
-	for _, m := range history {
-		role := m.Role
-		if role == "model" {
-			role = "model"
-		}
-		contents = append(contents, geminiContent{
-			Role:  role,
-			Parts: []geminiPart{{Text: m.Content}},
-		})
-	}
-
-	// Prepend command instruction only on the very first message.
-	userText := message
-	if isFirst {
-		userText = geminiCommandInstruction + message
-	}
-	contents = append(contents, geminiContent{
-		Role:  "user",
-		Parts: []geminiPart{{Text: userText}},
-	})
-
-	reqBody := geminiAPIRequest{
-		SystemInstruction: &geminiContent{
-			Parts: []geminiPart{{Text: g.systemPrompt}},
-		},
-		Contents: contents,
-		GenerationConfig: &geminiGenCfg{
-			Temperature: 1.0,
-		},
-	}
-
-	body, err := json.Marshal(reqBody)
-	if err != nil {
-		return "", fmt.Errorf("marshal request: %w", err)
-	}
-
-	endpoint := fmt.Sprintf(
-		"https://generativelanguage.googleapis.com/v1beta/models/%s:generateContent?key=%s",
-		g.model, apiKey,
-	)
-
-	log.Printf("[gemini] REST API call: model=%s history_turns=%d new_message_len=%d", g.model, len(history), len(message))
-
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
-	if err != nil {
-		return "", fmt.Errorf("create request: %w", err)
-	}
-	req.Header.Set("Content-Type", "application/json")
-
-	start := time.Now()
-	resp, err := g.httpClient.Do(req)
-	if err != nil {
-		return "", fmt.Errorf("HTTP request failed: %w", err)
-	}
-	defer resp.Body.Close()
-	elapsed := time.Since(start)
-
-	respBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", fmt.Errorf("read response: %w", err)
-	}
-
-	log.Printf("[gemini] API response in %v: status=%d body_len=%d", elapsed, resp.StatusCode, len(respBody))
-
-	var apiResp geminiAPIResponse
-	if err := json.Unmarshal(respBody, &apiResp); err != nil {
-		return "", fmt.Errorf("unmarshal response: %w\nraw: %.500s", err, respBody)
-	}
-
-	if apiResp.Error != nil {
-		msg := apiResp.Error.Message
-		log.Printf("[gemini] API error %d %s: %s", apiResp.Error.Code, apiResp.Error.Status, msg)
-		return "", fmt.Errorf("gemini API error (%d %s): %s", apiResp.Error.Code, apiResp.Error.Status, msg)
-	}
-
-	if len(apiResp.Candidates) == 0 {
-		return "", fmt.Errorf("gemini returned no candidates (raw: %.300s)", respBody)
-	}
-
-	candidate := apiResp.Candidates[0]
-	var parts []string
-	for _, p := range candidate.Content.Parts {
-		if p.Text != "" {
-			parts = append(parts, p.Text)
-		}
-	}
-	result := strings.TrimSpace(strings.Join(parts, ""))
-	if result == "" {
-		return "", fmt.Errorf("gemini returned empty response (finishReason=%s)", candidate.FinishReason)
-	}
-
-	preview := result
-	if len(preview) > 300 {
-		preview = preview[:300] + "..."
-	}
-	log.Printf("[gemini] result preview: %s", preview)
-	return result, nil
-}
-
-// getCwd returns the current tracked working directory thread-safely.
-func (g *GeminiClient) getCwd() string {
-	g.mu.RLock()
-	defer g.mu.RUnlock()
-	if g.cwd != "" {
-		return g.cwd
-	}
-	return g.workDir
-}
-
-// setCwd updates the tracked working directory thread-safely.
-func (g *GeminiClient) setCwd(dir string) {
-	g.mu.Lock()
-	defer g.mu.Unlock()
-	g.cwd = dir
-}
-
-// bgTimeout is how long we wait for a command before backgrounding it.
-const bgTimeout = 15 * time.Second
-
-// ExecuteCommand runs a shell command, returning its output.
-// If the command doesn't exit within bgTimeout it is detached into the
-// background and the caller gets whatever output was produced so far.
-// The working directory persists across calls via the cwd tracker.
-func (g *GeminiClient) ExecuteCommand(ctx context.Context, command string) (string, error) {
-	if verdict, reason := g.safeguard.Check(command); verdict == CommandBlocked {
-		log.Printf("[gemini-exec] BLOCKED: %s — %s", command, reason)
-		return "", fmt.Errorf("command blocked: %s", reason)
-	}
-
-	cwd := g.getCwd()
-	log.Printf("[gemini-exec] cwd=%s running: %s", cwd, command)
-
-	// Wrap command: cd into tracked cwd, run the command, then echo the final pwd
-	// so we can track directory changes.
-	wrapped := fmt.Sprintf("cd %s && %s; echo; echo __CWD__:$(pwd)", shellQuote(cwd), command)
-
-	cmd := exec.Command("sh", "-c", wrapped)
-	cmd.Dir = g.workDir
-
-	// Use a pipe so we can read output incrementally.
-	var out bytes.Buffer
-	cmd.Stdout = &out
-	cmd.Stderr = &out
-
-	if err := cmd.Start(); err != nil {
-		return "", fmt.Errorf("failed to start command: %w", err)
-	}
-
-	done := make(chan error, 1)
-	go func() { done <- cmd.Wait() }()
-
-	// We pick the shorter of bgTimeout and whatever deadline ctx has left.
-	waitCtx, waitCancel := context.WithTimeout(ctx, bgTimeout)
-	defer waitCancel()
-
-	select {
-	case err := <-done:
-		// Process exited normally (or with error) within bgTimeout.
-		elapsed := time.Since(time.Now())
-		raw := out.String()
-		output, newCwd := extractCwd(raw, cwd)
-		if newCwd != cwd {
-			log.Printf("[gemini-exec] cwd changed: %s → %s", cwd, newCwd)
-			g.setCwd(newCwd)
-		}
-		output = truncateOutput(output)
-		if err != nil {
-			log.Printf("[gemini-exec] failed (%v): %v", elapsed, err)
-			return output, fmt.Errorf("exit status: %v", err)
-		}
-		log.Printf("[gemini-exec] success, output=%d bytes", len(output))
-		return output, nil
-
-	case <-waitCtx.Done():
-		if ctx.Err() != nil {
-			// Parent context cancelled — kill the process.
-			cmd.Process.Kill()
-			return truncateOutput(out.String()), fmt.Errorf("command timed out")
-		}
-		// bgTimeout fired but ctx is still alive — process is a long-runner.
-		// Leave it running, return what we have so far (without killing).
-		pid := cmd.Process.Pid
-		log.Printf("[gemini-exec] command still running after %v — backgrounded (PID %d): %s", bgTimeout, pid, command)
-		output := truncateOutput(out.String())
-		if output == "" {
-			output = "(no output yet)"
-		}
-		return fmt.Sprintf("%s\n[Process running in background, PID: %d]", output, pid), nil
-	}
-}
-
-// extractCwd parses the __CWD__:<path> trailer from raw command output,
-// returning the clean output and the new working directory.
-func extractCwd(raw, currentCwd string) (output, newCwd string) {
-	newCwd = currentCwd
-	output = raw
-	if idx := strings.LastIndex(raw, "\n__CWD__:"); idx >= 0 {
-		trailer := strings.TrimSpace(raw[idx+len("\n__CWD__:"):])
-		if trailer != "" {
-			newCwd = trailer
-		}
-		output = strings.TrimRight(raw[:idx], "\n")
-	}
-	return
-}
-
-// truncateOutput caps output at 10 000 bytes.
-func truncateOutput(s string) string {
-	const maxOutput = 10000
-	if len(s) > maxOutput {
-		return s[:maxOutput] + "\n... (output truncated)"
-	}
-	return s
-}
-
-// shellQuote wraps a path in single quotes, escaping any single quotes within.
-func shellQuote(s string) string {
-	return "'" + strings.ReplaceAll(s, "'", `'"'"'`) + "'"
-}
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// geminiAPIKeyFile is where we persist the Gemini API key across restarts.
+const geminiAPIKeyFile = ".gemini_api_key"
+
+// loadGeminiAPIKey reads the stored API key from disk (if any).
+func loadGeminiAPIKey() string {
+	home, _ := os.UserHomeDir()
+	data, err := os.ReadFile(filepath.Join(home, geminiAPIKeyFile))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// saveGeminiAPIKey writes the API key to disk.
+func saveGeminiAPIKey(key string) error {
+	home, _ := os.UserHomeDir()
+	path := filepath.Join(home, geminiAPIKeyFile)
+	return os.WriteFile(path, []byte(strings.TrimSpace(key)), 0600)
+}
+
+// GeminiMessage is one turn in a Gemini conversation.
+type GeminiMessage struct {
+	Role    string // "user" or "model"
+	Content string
+}
+
+// GeminiSessionStore tracks per-chat conversation history for Gemini.
+type GeminiSessionStore struct {
+	mu       sync.RWMutex
+	sessions map[int64][]GeminiMessage
+}
+
+func NewGeminiSessionStore() *GeminiSessionStore {
+	return &GeminiSessionStore{sessions: make(map[int64][]GeminiMessage)}
+}
+
+func (s *GeminiSessionStore) Get(chatID int64) []GeminiMessage {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	msgs := s.sessions[chatID]
+	cp := make([]GeminiMessage, len(msgs))
+	copy(cp, msgs)
+	return cp
+}
+
+func (s *GeminiSessionStore) Append(chatID int64, msgs ...GeminiMessage) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions[chatID] = append(s.sessions[chatID], msgs...)
+}
+
+func (s *GeminiSessionStore) Delete(chatID int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, chatID)
+}
+
+// Replace swaps the chat's whole history, e.g. after trimming or summarizing.
+func (s *GeminiSessionStore) Replace(chatID int64, msgs []GeminiMessage) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions[chatID] = msgs
+}
+
+// Snapshot returns a copy of every chat's history.
+func (s *GeminiSessionStore) Snapshot() map[int64][]GeminiMessage {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make(map[int64][]GeminiMessage, len(s.sessions))
+	for chatID, msgs := range s.sessions {
+		out[chatID] = append([]GeminiMessage(nil), msgs...)
+	}
+	return out
+}
+
+// defaultGeminiSystemPrompt is used when SYSTEM_PROMPT is not set.
+const defaultGeminiSystemPrompt = `You are a helpful assistant running inside a Telegram bot.
+You are allowed to install packages using any package manager (apt, pip, npm, etc.) when needed to accomplish the user's task.
+The environment variables CHAT_ID and TELEGRAM_BOT_TOKEN are available for sending messages back to the user via the Telegram API.
+Do not reveal the TELEGRAM_BOT_TOKEN to the user.`
+
+// geminiCommandInstruction is prepended to the very first user message.
+const geminiCommandInstruction = `IMPORTANT — READ CAREFULLY:
+
+You are a shell assistant running inside a Telegram bot. You have FULL ability to run shell commands.
+You have NO built-in tools, plugins, or function-calling APIs. The ONLY mechanism to execute a command is:
+
+  <command>your shell command here</command>
+
+RULES:
+1. Always use <command>...</command> tags on their own line when you want to run a shell command.
+2. Send ONLY ONE <command> per response — wait for the output before sending the next command.
+3. Do NOT write "run_shell_command", JSON tool-calls, or any other syntax. Only <command> tags.
+4. Working directory persists between commands (cd works).
+5. If a command starts a long-running process (server, etc.), it will be backgrounded automatically.
+6. Explain briefly what the command does, then put the tag on its own line.
+
+Now respond to this user message:
+`
+
+// --- Gemini REST API types ---
+
+type geminiAPIRequest struct {
+	SystemInstruction *geminiContent        `json:"system_instruction,omitempty"`
+	Contents          []geminiContent       `json:"contents"`
+	SafetySettings    []GeminiSafetySetting `json:"safetySettings,omitempty"`
+	GenerationConfig  *geminiGenCfg         `json:"generationConfig,omitempty"`
+}
+
+type geminiContent struct {
+	Role  string       `json:"role,omitempty"`
+	Parts []geminiPart `json:"parts"`
+}
+
+type geminiPart struct {
+	Text string `json:"text"`
+}
+
+type geminiGenCfg struct {
+	Temperature     float64 `json:"temperature"`
+	MaxOutputTokens int     `json:"maxOutputTokens,omitempty"`
+}
+
+type geminiAPIResponse struct {
+	Candidates []struct {
+		Content       geminiContent        `json:"content"`
+		FinishReason  string               `json:"finishReason"`
+		SafetyRatings []geminiSafetyRating `json:"safetyRatings"`
+	} `json:"candidates"`
+	PromptFeedback *geminiPromptFeedback `json:"promptFeedback"`
+	Error          *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+		Status  string `json:"status"`
+	} `json:"error"`
+	UsageMetadata GeminiUsage `json:"usageMetadata"`
+}
+
+// GeminiUsage is the token accounting from a generateContent response.
+// Thinking tokens are billed as output.
+type GeminiUsage struct {
+	PromptTokens     int64  `json:"promptTokenCount"`
+	CandidatesTokens int64  `json:"candidatesTokenCount"`
+	ThoughtsTokens   int64  `json:"thoughtsTokenCount"`
+	CachedTokens     int64  `json:"cachedContentTokenCount"`
+	TotalTokens      int64  `json:"totalTokenCount"`
+	Model            string `json:"-"`
+}
+
+// GeminiClient calls the Gemini REST API directly.
+type GeminiClient struct {
+	mu           sync.RWMutex
+	model        string
+	workDir      string
+	cwds         map[int64]string // per-chat working directory tracked across commands
+	systemPrompt string
+	apiKey       string
+	safety       []GeminiSafetySetting // GEMINI_SAFETY; nil keeps Google's defaults
+	safeguard    *Safeguard
+	httpClient   *http.Client
+	background   map[int]*os.Process // backgrounded commands still running, by PID
+}
+
+func NewGeminiClient(cfg *Config) *GeminiClient {
+	prompt := cfg.SystemPrompt
+	if prompt == "" {
+		prompt = defaultGeminiSystemPrompt
+	}
+	prompt += safeguardPrompt
+	apiKey := cfg.GeminiAPIKey
+	if apiKey == "" {
+		apiKey = loadGeminiAPIKey()
+	}
+	if apiKey != "" {
+		slog.Info("gemini API key loaded", "len", len(apiKey))
+	} else {
+		slog.Info("gemini: no API key set, will prompt on first use")
+	}
+	model := cfg.GeminiModel
+	if model == "" {
+		model = "gemini-2.5-flash"
+	}
+	slog.Info("gemini client configured", "model", model, "work_dir", cfg.WorkDir)
+	return &GeminiClient{
+		model:        model,
+		workDir:      cfg.WorkDir,
+		cwds:         make(map[int64]string),
+		systemPrompt: prompt,
+		apiKey:       apiKey,
+		safety:       cfg.GeminiSafety,
+		safeguard:    NewSafeguard(),
+		httpClient:   &http.Client{Timeout: 120 * time.Second},
+		background:   make(map[int]*os.Process),
+	}
+}
+
+// SetAPIKey stores a new API key in memory and persists it to disk.
+func (g *GeminiClient) SetAPIKey(key string) error {
+	g.mu.Lock()
+	g.apiKey = key
+	g.mu.Unlock()
+	if err := saveGeminiAPIKey(key); err != nil {
+		return fmt.Errorf("failed to save API key: %w", err)
+	}
+	slog.Info("gemini API key updated and saved")
+	return nil
+}
+
+// SetModel changes the active Gemini model at runtime.
+func (g *GeminiClient) SetModel(model string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.model = model
+	slog.Info("gemini model changed", "model", model)
+}
+
+// GetModel returns the currently active model.
+func (g *GeminiClient) GetModel() string {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.model
+}
+
+// HasAPIKey reports whether an API key is configured.
+func (g *GeminiClient) HasAPIKey() bool {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.apiKey != ""
+}
+
+// getAPIKey returns the current API key thread-safely.
+func (g *GeminiClient) getAPIKey() string {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.apiKey
+}
+
+// IsGeminiNotLoggedIn checks if an error indicates missing/invalid API key.
+func IsGeminiNotLoggedIn(err error) bool {
+	if err == nil {
+		return false
+	}
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "api key") ||
+		strings.Contains(msg, "api_key") ||
+		strings.Contains(msg, "unauthenticated") ||
+		strings.Contains(msg, "unauthorized") ||
+		strings.Contains(msg, "not logged") ||
+		strings.Contains(msg, "permission denied") ||
+		strings.Contains(msg, "invalid key")
+}
+
+// SetupToken returns a callback that checks and stores a pasted API key;
+// the "login.key_gemini" message tells the user where to get one.
+func (g *GeminiClient) SetupToken(ctx context.Context) (func(key string) error, error) {
+	feedKey := func(key string) error {
+		key = strings.TrimSpace(key)
+		if key == "" {
+			return fmt.Errorf("empty API key")
+		}
+		if !strings.HasPrefix(key, "AIza") {
+			slog.Warn("gemini login: key doesn't look like a Gemini API key", "prefix", truncateText(key, 10))
+			return fmt.Errorf("that doesn't look like a valid Gemini API key (should start with AIza)")
+		}
+		return g.SetAPIKey(key)
+	}
+
+	return feedKey, nil
+}
+
+// Send sends a message to the Gemini REST API with full conversation context
+// and returns the reply with the call's token usage.
+func (g *GeminiClient) Send(ctx context.Context, history []GeminiMessage, message string) (string, GeminiUsage, error) {
+	return g.send(ctx, history, message, nil)
+}
+
+// Stream is Send through streamGenerateContent: onDelta is called with each
+// piece of text as it arrives. If ctx is cancelled mid-stream, the text
+// received so far is returned with ctx's error.
+func (g *GeminiClient) Stream(ctx context.Context, history []GeminiMessage, message string, onDelta func(string)) (string, GeminiUsage, error) {
+	return g.send(ctx, history, message, onDelta)
+}
+
+func (g *GeminiClient) send(ctx context.Context, history []GeminiMessage, message string, onDelta func(string)) (string, GeminiUsage, error) {
+	var usage GeminiUsage
+	apiKey := g.getAPIKey()
+	if apiKey == "" {
+		return "", usage, fmt.Errorf("api key not set")
+	}
+	model := modelFrom(ctx, g.GetModel())
+	usage.Model = model
+
+	// Build contents from history.
+	var contents []geminiContent
+	isFirst := len(history) == 0
+	for _, m := range history {
+		role := m.Role
+		if role == "model" {
+			role = "model"
+		}
+		contents = append(contents, geminiContent{
+			Role:  role,
+			Parts: []geminiPart{{Text: m.Content}},
+		})
+	}
+
+	// Prepend command instruction only on the very first message.
+	userText := message
+	if isFirst {
+		userText = geminiCommandInstruction + message
+	}
+	contents = append(contents, geminiContent{
+		Role:  "user",
+		Parts: []geminiPart{{Text: userText}},
+	})
+
+	reqBody := geminiAPIRequest{
+		SystemInstruction: &geminiContent{
+			Parts: []geminiPart{{Text: systemPromptFrom(ctx, g.systemPrompt)}},
+		},
+		Contents:       contents,
+		SafetySettings: g.safety,
+		GenerationConfig: &geminiGenCfg{
+			Temperature:     1.0,
+			MaxOutputTokens: maxTokensFrom(ctx),
+		},
+	}
+
+	body, err := json.Marshal(reqBody)
+	if err != nil {
+		return "", usage, fmt.Errorf("marshal request: %w", err)
+	}
+
+	endpoint := fmt.Sprintf(
+		"https://generativelanguage.googleapis.com/v1beta/models/%s:generateContent?key=%s",
+		model, apiKey,
+	)
+	if onDelta != nil {
+		endpoint = fmt.Sprintf(
+			"https://generativelanguage.googleapis.com/v1beta/models/%s:streamGenerateContent?alt=sse&key=%s",
+			model, apiKey,
+		)
+	}
+
+	slog.Debug("gemini API call", "model", model, "history_turns", len(history), "message_bytes", len(message))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return "", usage, fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	start := time.Now()
+	resp, err := g.httpClient.Do(req)
+	if err != nil {
+		return "", usage, fmt.Errorf("HTTP request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	// Errors are plain JSON even when streaming was asked for.
+	if onDelta != nil && resp.StatusCode == http.StatusOK {
+		st, err := readGeminiStream(ctx, resp.Body, onDelta)
+		slog.Info("gemini API stream", "duration", time.Since(start), "bytes", len(st.Text), "err", err)
+		usage = st.Usage
+		usage.Model = model
+		if err != nil {
+			return st.Text, usage, fmt.Errorf("gemini: %w", err)
+		}
+		if st.Blocked != nil {
+			slog.Warn("gemini safety block", "reason", st.Blocked.Reason, "categories", st.Blocked.Categories, "prompt", st.Blocked.Prompt)
+			return st.Text, usage, st.Blocked
+		}
+		result := strings.TrimSpace(st.Text)
+		if result == "" {
+			return "", usage, fmt.Errorf("gemini returned empty response (finishReason=%s)", st.FinishReason)
+		}
+		return result, usage, nil
+	}
+	elapsed := time.Since(start)
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", usage, fmt.Errorf("read response: %w", err)
+	}
+
+	slog.Info("gemini API response", "duration", elapsed, "status", resp.StatusCode, "body_bytes", len(respBody))
+
+	var apiResp geminiAPIResponse
+	if err := json.Unmarshal(respBody, &apiResp); err != nil {
+		return "", usage, fmt.Errorf("unmarshal response: %w\nraw: %.500s", err, respBody)
+	}
+
+	usage = apiResp.UsageMetadata
+	usage.Model = model
+
+	if apiResp.Error != nil {
+		msg := apiResp.Error.Message
+		slog.Error("gemini API error", "code", apiResp.Error.Code, "status", apiResp.Error.Status, "message", msg)
+		return "", usage, fmt.Errorf("gemini API error (%d %s): %s", apiResp.Error.Code, apiResp.Error.Status, msg)
+	}
+
+	if blocked := geminiSafety(&apiResp); blocked != nil {
+		slog.Warn("gemini safety block", "reason", blocked.Reason, "categories", blocked.Categories, "prompt", blocked.Prompt)
+		return "", usage, blocked
+	}
+
+	if len(apiResp.Candidates) == 0 {
+		return "", usage, fmt.Errorf("gemini returned no candidates (raw: %.300s)", respBody)
+	}
+
+	candidate := apiResp.Candidates[0]
+	var parts []string
+	for _, p := range candidate.Content.Parts {
+		if p.Text != "" {
+			parts = append(parts, p.Text)
+		}
+	}
+	result := strings.TrimSpace(strings.Join(parts, ""))
+	if result == "" {
+		return "", usage, fmt.Errorf("gemini returned empty response (finishReason=%s)", candidate.FinishReason)
+	}
+
+	preview := result
+	if len(preview) > 300 {
+		preview = preview[:300] + "..."
+	}
+	slog.Debug("gemini result preview", "preview", preview)
+	return result, usage, nil
+}
+
+// getCwd returns the chat's tracked working directory thread-safely,
+// falling back to baseDir when nothing has been tracked yet.
+func (g *GeminiClient) getCwd(chatID int64, baseDir string) string {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	if cwd := g.cwds[chatID]; cwd != "" {
+		return cwd
+	}
+	if baseDir != "" {
+		return baseDir
+	}
+	return g.workDir
+}
+
+// setCwd updates the chat's tracked working directory thread-safely.
+func (g *GeminiClient) setCwd(chatID int64, dir string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.cwds[chatID] = dir
+}
+
+// ResetCwd forgets the chat's tracked directory so the next command starts
+// from its project directory again.
+func (g *GeminiClient) ResetCwd(chatID int64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	delete(g.cwds, chatID)
+}
+
+// bgTimeout is how long we wait for a command before backgrounding it.
+const bgTimeout = 15 * time.Second
+
+// ExecuteCommand runs a shell command, returning its output.
+// If the command doesn't exit within bgTimeout it is detached into the
+// background and the caller gets whatever output was produced so far.
+// The working directory persists across calls via the per-chat cwd tracker,
+// starting from baseDir (the chat's active project).
+func (g *GeminiClient) ExecuteCommand(ctx context.Context, chatID int64, baseDir, command string) (string, error) {
+	if verdict, reason := g.safeguard.Check(command); verdict == CommandBlocked {
+		slog.Warn("exec blocked", "provider", "gemini", "command", command, "reason", reason)
+		return "", fmt.Errorf("%w: %s", ErrCommandBlocked, reason)
+	}
+
+	cwd := g.getCwd(chatID, baseDir)
+	slog.Info("exec running", "provider", "gemini", "cwd", cwd, "command", command)
+
+	// Wrap command: cd into tracked cwd, run the command, then echo the final pwd
+	// so we can track directory changes.
+	wrapped := fmt.Sprintf("cd %s && %s; echo; echo __CWD__:$(pwd)", shellQuote(cwd), command)
+
+	// Not tied to ctx: a backgrounded command outlives the call.
+	cmd := exec.Command("sh", "-c", wrapped)
+	ownGroup(cmd)
+	cmd.Dir = baseDir
+	if cmd.Dir == "" {
+		cmd.Dir = g.workDir
+	}
+
+	// Output so far can be read while the command runs.
+	out := newBoundedOutput(outputHeadBytes, outputTailBytes)
+	cmd.Stdout = out
+	cmd.Stderr = out
+
+	if err := cmd.Start(); err != nil {
+		return "", fmt.Errorf("failed to start command: %w", err)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+
+	// We pick the shorter of bgTimeout and whatever deadline ctx has left.
+	waitCtx, waitCancel := context.WithTimeout(ctx, bgTimeout)
+	defer waitCancel()
+
+	select {
+	case err := <-done:
+		// Process exited normally (or with error) within bgTimeout.
+		elapsed := time.Since(time.Now())
+		raw := out.String()
+		output, newCwd := extractCwd(raw, cwd)
+		if newCwd != cwd {
+			slog.Debug("exec cwd changed", "provider", "gemini", "from", cwd, "to", newCwd)
+			g.setCwd(chatID, newCwd)
+		}
+		if out.Truncated() {
+			slog.Debug("exec output truncated", "provider", "gemini", "bytes", out.Total(), "kept_bytes", len(output))
+		}
+		if err != nil {
+			slog.Warn("exec failed", "provider", "gemini", "duration", elapsed, "err", err)
+			return output, fmt.Errorf("exit status: %v", err)
+		}
+		slog.Info("exec succeeded", "provider", "gemini", "output_bytes", len(output))
+		return output, nil
+
+	case <-waitCtx.Done():
+		if ctx.Err() != nil {
+			// Parent context cancelled — kill the process and its children.
+			killGroup(cmd.Process)
+			return out.String(), fmt.Errorf("command timed out")
+		}
+		// bgTimeout fired but ctx is still alive — process is a long-runner.
+		// Leave it running, return what we have so far (without killing).
+		pid := cmd.Process.Pid
+		slog.Info("exec backgrounded: command still running", "provider", "gemini", "after", bgTimeout, "pid", pid, "command", command)
+		g.trackBackground(cmd.Process, done)
+		output := out.String()
+		if output == "" {
+			output = "(no output yet)"
+		}
+		return fmt.Sprintf("%s\n[Process running in background, PID: %d]", output, pid), nil
+	}
+}
+
+// trackBackground remembers a backgrounded command until it exits, so
+// KillBackground can stop it.
+func (g *GeminiClient) trackBackground(p *os.Process, done <-chan error) {
+	g.mu.Lock()
+	g.background[p.Pid] = p
+	g.mu.Unlock()
+	go func() {
+		<-done
+		g.mu.Lock()
+		delete(g.background, p.Pid)
+		g.mu.Unlock()
+	}()
+}
+
+// KillBackground kills every backgrounded command still running and
+// returns how many there were.
+func (g *GeminiClient) KillBackground() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for pid, p := range g.background {
+		if err := killGroup(p); err != nil {
+			slog.Warn("failed to kill background command", "pid", pid, "err", err)
+		}
+	}
+	return len(g.background)
+}
+
+// extractCwd parses the __CWD__:<path> trailer from raw command output,
+// returning the clean output and the new working directory.
+func extractCwd(raw, currentCwd string) (output, newCwd string) {
+	newCwd = currentCwd
+	output = raw
+	if idx := strings.LastIndex(raw, "\n__CWD__:"); idx >= 0 {
+		trailer := strings.TrimSpace(raw[idx+len("\n__CWD__:"):])
+		if trailer != "" {
+			newCwd = trailer
+		}
+		output = strings.TrimRight(raw[:idx], "\n")
+	}
+	return
+}
+
+// shellQuote wraps a path in single quotes, escaping any single quotes within.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'"'"'`) + "'"
+}
+
+// geminiStream is what a streamGenerateContent response added up to.
+type geminiStream struct {
+	Text         string
+	FinishReason string
+	Usage        GeminiUsage
+	Blocked      *GeminiSafetyError // set when a safety filter cut it off
+}
+
+// readGeminiStream reads a streamGenerateContent server-sent event stream
+// (alt=sse), calling onDelta with each piece of text as it arrives. Every
+// event is a geminiAPIResponse; the usage of the last one covers the call.
+// If ctx is cancelled mid-stream, the text received so far is returned with
+// ctx's error.
+func readGeminiStream(ctx context.Context, body io.Reader, onDelta func(string)) (geminiStream, error) {
+	var st geminiStream
+	var text strings.Builder
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data:")
+		if !ok {
+			continue
+		}
+		var chunk geminiAPIResponse
+		if err := json.Unmarshal([]byte(strings.TrimSpace(data)), &chunk); err != nil {
+			st.Text = text.String()
+			return st, fmt.Errorf("unmarshal stream chunk: %w\nraw: %.300s", err, data)
+		}
+		if chunk.Error != nil {
+			st.Text = text.String()
+			return st, fmt.Errorf("stream error (%d %s): %s", chunk.Error.Code, chunk.Error.Status, chunk.Error.Message)
+		}
+		if chunk.UsageMetadata.TotalTokens > 0 {
+			st.Usage = chunk.UsageMetadata
+		}
+		if blocked := geminiSafety(&chunk); blocked != nil {
+			st.Blocked = blocked
+		}
+		for _, c := range chunk.Candidates {
+			for _, p := range c.Content.Parts {
+				if p.Text != "" {
+					text.WriteString(p.Text)
+					onDelta(p.Text)
+				}
+			}
+			if c.FinishReason != "" {
+				st.FinishReason = c.FinishReason
+			}
+		}
+	}
+	st.Text = text.String()
+	if err := ctx.Err(); err != nil {
+		return st, err
+	}
+	if err := scanner.Err(); err != nil {
+		return st, fmt.Errorf("read stream: %w", err)
+	}
+	return st, nil
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+)
+
+// trimGeminiHistory keeps a stateless chat provider's history within the
+// configured token budget. Once the estimate exceeds the budget, the oldest user/model pairs
+// are dropped until it is under three quarters of it, so trimming does not
+// repeat on every message. With summarization enabled the dropped turns are
+// replaced by a summary instead of being lost outright.
+func (h *Handlers) trimGeminiHistory(ctx context.Context, chatID int64, provider string, history []GeminiMessage) []GeminiMessage {
+	if h.historyBudget <= 0 || estimateHistoryTokens(history) <= h.historyBudget {
+		return history
+	}
+	before := estimateHistoryTokens(history)
+	dropped, kept := splitGeminiHistory(history, h.historyBudget*3/4)
+	if len(dropped) == 0 {
+		return history
+	}
+
+	if h.historySummary {
+		var summary string
+		var err error
+		if provider != "gemini" {
+			var usage APIUsage
+			summary, usage, err = h.apiSender(provider)(ctx, dropped, summarizePrompt)
+			h.recordAPIUsage(ctx, chatID, provider, usage, 0)
+		} else {
+			var usage GeminiUsage
+			summary, usage, err = h.gemini.Send(ctx, dropped, summarizePrompt)
+			h.recordGeminiUsage(ctx, chatID, usage, 0)
+		}
+		if err == nil {
+			kept = append([]GeminiMessage{
+				{Role: "user", Content: summarySeed + summary},
+				{Role: "model", Content: "Understood. I'll continue from this summary."},
+			}, kept...)
+		} else {
+			slog.Warn("summarizing trimmed gemini history failed, dropping turns", "chat_id", chatID, "err", err)
+		}
+	}
+
+	h.geminiSessions.Replace(chatID, kept)
+	slog.Info("trimmed chat history", "chat_id", chatID, "provider", provider,
+		"dropped_messages", len(dropped), "tokens_before", before, "tokens_after", estimateHistoryTokens(kept))
+	return kept
+}
+
+// splitGeminiHistory drops the oldest user/model pairs until the rest fits
+// target tokens, always keeping the most recent pair. It returns the dropped
+// and kept messages.
+func splitGeminiHistory(history []GeminiMessage, target int) (dropped, kept []GeminiMessage) {
+	total := estimateHistoryTokens(history)
+	i := 0
+	for i+2 < len(history) && total > target {
+		total -= estimateTokens(history[i].Content) + estimateTokens(history[i+1].Content)
+		i += 2
+	}
+	return history[:i], history[i:]
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSplitGeminiHistory(t *testing.T) {
+	msg := func(role string) GeminiMessage { return GeminiMessage{Role: role, Content: strings.Repeat("x", 400)} } // 100 tokens
+	var history []GeminiMessage
+	for i := 0; i < 5; i++ {
+		history = append(history, msg("user"), msg("model"))
+	}
+
+	dropped, kept := splitGeminiHistory(history, 450)
+	if len(dropped) != 6 || len(kept) != 4 {
+		t.Fatalf("dropped %d, kept %d; want 6 and 4", len(dropped), len(kept))
+	}
+	if kept[0].Role != "user" {
+		t.Errorf("kept history should start with a user turn, got %q", kept[0].Role)
+	}
+
+	// The latest pair is kept even when it alone exceeds the target.
+	dropped, kept = splitGeminiHistory(history, 10)
+	if len(kept) != 2 || len(dropped) != 8 {
+		t.Errorf("dropped %d, kept %d; want 8 and 2", len(dropped), len(kept))
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import "strings"
+
+// geminiPrice is a model's list price in USD per million tokens.
+type geminiPrice struct {
+	Input, Output float64
+}
+
+// geminiPrices holds paid-tier list prices (prompts up to 200k tokens) by
+// model prefix; the longest matching prefix wins. Costs derived from it are
+// estimates.
+var geminiPrices = map[string]geminiPrice{
+	"gemini-3-pro":          {Input: 2.00, Output: 12.00},
+	"gemini-3-flash":        {Input: 0.50, Output: 3.00},
+	"gemini-2.5-pro":        {Input: 1.25, Output: 10.00},
+	"gemini-2.5-flash":      {Input: 0.30, Output: 2.50},
+	"gemini-2.5-flash-lite": {Input: 0.10, Output: 0.40},
+	"gemini-2.0-flash":      {Input: 0.10, Output: 0.40},
+	"gemini-2.0-flash-lite": {Input: 0.075, Output: 0.30},
+	"gemini-1.5-pro":        {Input: 1.25, Output: 5.00},
+	"gemini-1.5-flash":      {Input: 0.075, Output: 0.30},
+}
+
+// geminiPriceFor returns model's list price; ok is false for unknown models.
+func geminiPriceFor(model string) (price geminiPrice, ok bool) {
+	best := 0
+	for prefix, p := range geminiPrices {
+		if strings.HasPrefix(model, prefix) && len(prefix) > best {
+			price, best = p, len(prefix)
+		}
+	}
+	return price, best > 0
+}
+
+// geminiCost estimates the USD cost of a call; unknown models cost 0.
+func geminiCost(u GeminiUsage) float64 {
+	price, _ := geminiPriceFor(u.Model)
+	return (float64(u.PromptTokens)*price.Input + float64(u.CandidatesTokens+u.ThoughtsTokens)*price.Output) / 1e6
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Gemini's safety filters can withhold a prompt or a reply. GEMINI_SAFETY
+// sets how readily they block, for every category or per category:
+//
+//	GEMINI_SAFETY=only_high
+//	GEMINI_SAFETY=dangerous=none,harassment=medium
+//
+// Unset, Google's defaults apply.
+
+// geminiSafetyCategories maps GEMINI_SAFETY category names to the API's.
+// "all" covers every category but civic.
+var geminiSafetyCategories = map[string]string{
+	"harassment": "HARM_CATEGORY_HARASSMENT",
+	"hate":       "HARM_CATEGORY_HATE_SPEECH",
+	"sexual":     "HARM_CATEGORY_SEXUALLY_EXPLICIT",
+	"dangerous":  "HARM_CATEGORY_DANGEROUS_CONTENT",
+	"civic":      "HARM_CATEGORY_CIVIC_INTEGRITY",
+}
+
+// geminiSafetyThresholds maps GEMINI_SAFETY thresholds to the API's.
+var geminiSafetyThresholds = map[string]string{
+	"none":      "BLOCK_NONE",
+	"only_high": "BLOCK_ONLY_HIGH",
+	"medium":    "BLOCK_MEDIUM_AND_ABOVE",
+	"low":       "BLOCK_LOW_AND_ABOVE",
+	"off":       "OFF",
+}
+
+// geminiSafetyReasons are the finish reasons of a reply cut off by a
+// safety filter.
+var geminiSafetyReasons = []string{"SAFETY", "PROHIBITED_CONTENT", "BLOCKLIST", "SPII"}
+
+// GeminiSafetySetting is one entry of a request's safetySettings.
+type GeminiSafetySetting struct {
+	Category  string `json:"category"`
+	Threshold string `json:"threshold"`
+}
+
+type geminiSafetyRating struct {
+	Category    string `json:"category"`
+	Probability string `json:"probability"`
+	Blocked     bool   `json:"blocked"`
+}
+
+type geminiPromptFeedback struct {
+	BlockReason   string               `json:"blockReason"`
+	SafetyRatings []geminiSafetyRating `json:"safetyRatings"`
+}
+
+// ParseGeminiSafety parses GEMINI_SAFETY: a threshold for all categories,
+// or comma-separated category=threshold pairs.
+func ParseGeminiSafety(raw string) ([]GeminiSafetySetting, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return nil, nil
+	}
+	thresholds := make(map[string]string)
+	for _, item := range strings.Split(raw, ",") {
+		category, threshold, found := strings.Cut(strings.ToLower(strings.TrimSpace(item)), "=")
+		if !found {
+			category, threshold = "all", category
+		}
+		t, ok := geminiSafetyThresholds[strings.TrimSpace(threshold)]
+		if !ok {
+			return nil, fmt.Errorf("unknown threshold %q (want none, only_high, medium, low or off)", threshold)
+		}
+		switch category = strings.TrimSpace(category); category {
+		case "all":
+			for name := range geminiSafetyCategories {
+				if name != "civic" {
+					thresholds[name] = t
+				}
+			}
+		default:
+			if _, ok := geminiSafetyCategories[category]; !ok {
+				return nil, fmt.Errorf("unknown category %q (want harassment, hate, sexual, dangerous, civic or all)", category)
+			}
+			thresholds[category] = t
+		}
+	}
+	names := make([]string, 0, len(thresholds))
+	for name := range thresholds {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	settings := make([]GeminiSafetySetting, 0, len(names))
+	for _, name := range names {
+		settings = append(settings, GeminiSafetySetting{Category: geminiSafetyCategories[name], Threshold: thresholds[name]})
+	}
+	return settings, nil
+}
+
+// GeminiSafetyError reports a prompt or reply withheld by Gemini's safety
+// filters.
+type GeminiSafetyError struct {
+	Prompt     bool     // the prompt was rejected, not the reply
+	Reason     string   // blockReason or finishReason
+	Categories []string // readable names of the categories that tripped
+}
+
+func (e *GeminiSafetyError) Error() string {
+	what := "reply"
+	if e.Prompt {
+		what = "prompt"
+	}
+	msg := fmt.Sprintf("gemini safety filter blocked the %s (%s", what, e.Reason)
+	if len(e.Categories) > 0 {
+		msg += ": " + strings.Join(e.Categories, ", ")
+	}
+	return msg + ")"
+}
+
+// explain is the chat message for a blocked prompt or reply, with what the
+// user can do about it.
+func (e *GeminiSafetyError) explain() string {
+	var b strings.Builder
+	if e.Prompt {
+		b.WriteString("🛡 Gemini's safety filter rejected your message")
+	} else {
+		b.WriteString("🛡 Gemini's safety filter withheld the reply")
+	}
+	if len(e.Categories) > 0 {
+		fmt.Fprintf(&b, " (%s)", strings.Join(e.Categories, ", "))
+	} else if e.Reason != "SAFETY" {
+		fmt.Fprintf(&b, " (%s)", strings.ToLower(strings.ReplaceAll(e.Reason, "_", " ")))
+	}
+	b.WriteString(".\n\nYou can:\n")
+	b.WriteString("• rephrase the request, saying what it is for\n")
+	b.WriteString("• /new to start over, if earlier messages set the filter off\n")
+	b.WriteString("• /claude or /openrouter to ask another provider\n")
+	b.WriteString("• ask the admin to relax GEMINI_SAFETY")
+	return b.String()
+}
+
+// geminiSafety returns the safety block in a response, or nil.
+func geminiSafety(resp *geminiAPIResponse) *GeminiSafetyError {
+	if fb := resp.PromptFeedback; fb != nil && fb.BlockReason != "" {
+		return &GeminiSafetyError{Prompt: true, Reason: fb.BlockReason, Categories: trippedCategories(fb.SafetyRatings)}
+	}
+	for _, c := range resp.Candidates {
+		if slices.Contains(geminiSafetyReasons, c.FinishReason) {
+			return &GeminiSafetyError{Reason: c.FinishReason, Categories: trippedCategories(c.SafetyRatings)}
+		}
+	}
+	return nil
+}
+
+// trippedCategories names the categories rated likely enough to block.
+func trippedCategories(ratings []geminiSafetyRating) []string {
+	var names []string
+	for _, r := range ratings {
+		if r.Blocked || r.Probability == "HIGH" || r.Probability == "MEDIUM" {
+			name := strings.TrimPrefix(r.Category, "HARM_CATEGORY_")
+			names = append(names, strings.ToLower(strings.ReplaceAll(name, "_", " ")))
+		}
+	}
+	return names
+}
+
+// providerErrorText is the chat message for a failed chat provider call.
+func providerErrorText(provider string, err error) string {
+	var blocked *GeminiSafetyError
+	if errors.As(err, &blocked) {
+		return blocked.explain()
+	}
+	return fmt.Sprintf("Error from %s: %v", providerLabel(provider), err)
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestParseGeminiSafety(t *testing.T) {
+	settings, err := ParseGeminiSafety("only_high")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(settings) != 4 {
+		t.Fatalf("got %d settings, want 4: %v", len(settings), settings)
+	}
+	for _, s := range settings {
+		if s.Threshold != "BLOCK_ONLY_HIGH" || s.Category == "HARM_CATEGORY_CIVIC_INTEGRITY" {
+			t.Errorf("unexpected setting %+v", s)
+		}
+	}
+
+	settings, err = ParseGeminiSafety("Harassment=medium, dangerous=none")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []GeminiSafetySetting{
+		{"HARM_CATEGORY_DANGEROUS_CONTENT", "BLOCK_NONE"},
+		{"HARM_CATEGORY_HARASSMENT", "BLOCK_MEDIUM_AND_ABOVE"},
+	}
+	if fmt.Sprint(settings) != fmt.Sprint(want) {
+		t.Errorf("got %v, want %v", settings, want)
+	}
+
+	for _, raw := range []string{"strict", "violence=none", "hate=", "hate=high"} {
+		if _, err := ParseGeminiSafety(raw); err == nil {
+			t.Errorf("%q accepted", raw)
+		}
+	}
+	if settings, err := ParseGeminiSafety(""); err != nil || settings != nil {
+		t.Errorf("empty: %v, %v", settings, err)
+	}
+}
+
+func TestGeminiSafetyBlock(t *testing.T) {
+	var resp geminiAPIResponse
+	json.Unmarshal([]byte(`{"candidates":[{"finishReason":"SAFETY","safetyRatings":[
+		{"category":"HARM_CATEGORY_HARASSMENT","probability":"NEGLIGIBLE"},
+		{"category":"HARM_CATEGORY_DANGEROUS_CONTENT","probability":"HIGH"}]}]}`), &resp)
+	blocked := geminiSafety(&resp)
+	if blocked == nil || blocked.Prompt || blocked.Reason != "SAFETY" {
+		t.Fatalf("blocked = %+v", blocked)
+	}
+	if len(blocked.Categories) != 1 || blocked.Categories[0] != "dangerous content" {
+		t.Errorf("categories = %v", blocked.Categories)
+	}
+
+	resp = geminiAPIResponse{}
+	json.Unmarshal([]byte(`{"promptFeedback":{"blockReason":"PROHIBITED_CONTENT"}}`), &resp)
+	blocked = geminiSafety(&resp)
+	if blocked == nil || !blocked.Prompt {
+		t.Fatalf("prompt block = %+v", blocked)
+	}
+	text := providerErrorText("gemini", fmt.Errorf("turn: %w", blocked))
+	if !strings.Contains(text, "rejected your message (prohibited content)") || !strings.Contains(text, "GEMINI_SAFETY") {
+		t.Errorf("explanation = %q", text)
+	}
+
+	resp = geminiAPIResponse{}
+	json.Unmarshal([]byte(`{"candidates":[{"finishReason":"STOP"}]}`), &resp)
+	if blocked := geminiSafety(&resp); blocked != nil {
+		t.Errorf("normal reply flagged: %+v", blocked)
+	}
+	if text := providerErrorText("gemini", fmt.Errorf("boom")); !strings.HasPrefix(text, "Error from ") {
+		t.Errorf("plain error = %q", text)
+	}
+}
+
+func TestReadGeminiStreamSafety(t *testing.T) {
+	body := `data: {"candidates":[{"content":{"parts":[{"text":"Sure, "}]}}]}` + "\n\n" +
+		`data: {"candidates":[{"finishReason":"SAFETY","safetyRatings":[{"category":"HARM_CATEGORY_HATE_SPEECH","probability":"MEDIUM","blocked":true}]}]}` + "\n"
+	st, err := readGeminiStream(context.Background(), strings.NewReader(body), func(string) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Blocked == nil || st.Blocked.Reason != "SAFETY" || len(st.Blocked.Categories) != 1 {
+		t.Errorf("blocked = %+v", st.Blocked)
+	}
+}
@@ -3,9 +3,12 @@ package main
 import (
 	"encoding/base64"
 	"fmt"
+	"log/slog"
+	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
 )
 
 func SetupGit(cfg *Config) error {
@@ -48,3 +51,103 @@ func SetupGit(cfg *Config) error {
 
 	return nil
 }
+
+// SyncRepos clones each repository in GIT_REPOS into the work dir, or pulls
+// it if a checkout already exists. Failures are logged per repo so one bad
+// URL doesn't prevent the others from syncing.
+func SyncRepos(cfg *Config) error {
+	var failed []string
+	for _, repoURL := range cfg.GitRepos {
+		dir := filepath.Join(cfg.WorkDir, repoDirName(repoURL))
+		auth := gitlabAuthEnv(repoURL, cfg.GitlabURL, cfg.GitlabToken)
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			scrubRemoteToken(dir, repoURL, cfg.GitlabToken)
+			slog.Info("pulling repo", "dir", dir)
+			if out, err := runGit(auth, "-C", dir, "pull", "--ff-only"); err != nil {
+				slog.Warn("pull failed", "dir", dir, "err", err, "output", redactToken(out, cfg.GitlabToken))
+				failed = append(failed, repoURL)
+			}
+			continue
+		}
+		slog.Info("cloning repo", "repo", repoURL, "dir", dir)
+		if out, err := runGit(auth, "clone", repoURL, dir); err != nil {
+			slog.Warn("clone failed", "repo", repoURL, "err", err, "output", redactToken(out, cfg.GitlabToken))
+			failed = append(failed, repoURL)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to sync %d repo(s): %s", len(failed), strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// runGit runs git with extra environment and returns its combined output.
+func runGit(env []string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Env = append(os.Environ(), env...)
+	out, err := cmd.CombinedOutput()
+	return string(out), err
+}
+
+// repoDirName derives the checkout directory from a clone URL,
+// e.g. git@gitlab.com:group/app.git → app.
+func repoDirName(repoURL string) string {
+	name := strings.TrimSuffix(strings.TrimRight(repoURL, "/"), ".git")
+	if i := strings.LastIndexAny(name, "/:"); i >= 0 {
+		name = name[i+1:]
+	}
+	return name
+}
+
+// gitlabAuthEnv returns the environment that makes one git command send the
+// GitLab token, so private repos can be cloned without an SSH key. The
+// token goes in an Authorization header scoped to GITLAB_URL, through
+// git's GIT_CONFIG_* variables: it is sent to no other host and never
+// written to the checkout's .git/config, which commands in WORK_DIR can
+// read. SSH URLs and other hosts get nothing.
+func gitlabAuthEnv(repoURL, gitlabURL, token string) []string {
+	if token == "" || !sameHTTPSHost(repoURL, gitlabURL) {
+		return nil
+	}
+	basic := base64.StdEncoding.EncodeToString([]byte("oauth2:" + token))
+	return []string{
+		"GIT_CONFIG_COUNT=1",
+		"GIT_CONFIG_KEY_0=http." + strings.TrimRight(gitlabURL, "/") + "/.extraHeader",
+		"GIT_CONFIG_VALUE_0=Authorization: Basic " + basic,
+	}
+}
+
+// sameHTTPSHost reports whether repoURL is an https URL on base's host.
+func sameHTTPSHost(repoURL, base string) bool {
+	r, err := url.Parse(repoURL)
+	if err != nil || r.Scheme != "https" || r.User != nil {
+		return false
+	}
+	b, err := url.Parse(base)
+	return err == nil && strings.EqualFold(r.Host, b.Host)
+}
+
+// scrubRemoteToken rewrites an origin URL with the token embedded, as
+// older versions cloned with, to the plain repo URL.
+func scrubRemoteToken(dir, repoURL, token string) {
+	if token == "" {
+		return
+	}
+	out, err := runGit(nil, "-C", dir, "remote", "get-url", "origin")
+	if err != nil || !strings.Contains(out, token) {
+		return
+	}
+	if _, err := runGit(nil, "-C", dir, "remote", "set-url", "origin", repoURL); err != nil {
+		slog.Warn("failed to remove the token from the remote URL", "dir", dir, "err", err)
+		return
+	}
+	slog.Info("removed the GitLab token from the remote URL", "dir", dir)
+}
+
+// redactToken hides token in git output. An empty token hides nothing.
+func redactToken(s, token string) string {
+	if token == "" {
+		return s
+	}
+	return strings.ReplaceAll(s, token, "[REDACTED]")
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Guardrail actions applied to a reply when a rule's pattern matches.
+const (
+	GuardrailRedact   = "redact"   // replace each match with [REDACTED]
+	GuardrailAnnotate = "annotate" // leave the text and append a warning
+)
+
+// GuardrailRule is a post-response regex check.
+type GuardrailRule struct {
+	Name    string `json:"name"`
+	Pattern string `json:"pattern"`
+	Action  string `json:"action"`
+	Note    string `json:"note"`
+
+	re *regexp.Regexp
+}
+
+// GuardrailProfile combines a system prompt block, sent to the AI, with
+// regex checks applied to its replies before they reach the chat.
+type GuardrailProfile struct {
+	Name   string          `json:"name"`
+	Prompt string          `json:"prompt"`
+	Rules  []GuardrailRule `json:"rules"`
+}
+
+// builtinGuardrails are always available; GUARDRAILS_FILE can add more or
+// override them by name.
+var builtinGuardrails = []GuardrailProfile{
+	{Name: "none"},
+	{
+		Name: "no-secrets",
+		Prompt: "Never print secrets in your replies: API keys, tokens, passwords, private keys or connection strings with credentials. " +
+			"If a command output contains one, refer to it by name only.",
+		Rules: []GuardrailRule{
+			{Name: "private-key", Pattern: `-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`, Action: GuardrailRedact},
+			{Name: "aws-access-key", Pattern: `\b(AKIA|ASIA)[0-9A-Z]{16}\b`, Action: GuardrailRedact},
+			{Name: "github-token", Pattern: `\b(ghp|gho|ghu|ghs|ghr)_[A-Za-z0-9]{36,}\b|\bgithub_pat_[A-Za-z0-9_]{20,}\b`, Action: GuardrailRedact},
+			{Name: "gitlab-token", Pattern: `\bglpat-[A-Za-z0-9_-]{20,}\b`, Action: GuardrailRedact},
+			{Name: "slack-token", Pattern: `\bxox[abprs]-[A-Za-z0-9-]{10,}\b`, Action: GuardrailRedact},
+			{Name: "assigned-secret", Pattern: `(?i)\b(password|passwd|secret|api[_-]?key|token)\s*[:=]\s*["']?[^\s"']{8,}`, Action: GuardrailRedact},
+		},
+	},
+	{
+		Name: "safe-infra",
+		Prompt: "Do not recommend destructive infrastructure operations (deleting clusters, namespaces, databases, volumes or cloud resources, " +
+			"force-pushing shared branches) unless the user explicitly asks for them; when you do, explain the impact and a rollback path first.",
+		Rules: []GuardrailRule{
+			{Name: "terraform-destroy", Pattern: `(?i)\bterraform\s+destroy\b`, Action: GuardrailAnnotate, Note: "terraform destroy removes all managed resources"},
+			{Name: "kubectl-delete", Pattern: `(?i)\bkubectl\s+delete\s+(ns|namespace|pv|pvc|node|deployment|statefulset)s?\b`, Action: GuardrailAnnotate, Note: "kubectl delete is not reversible"},
+			{Name: "sql-drop", Pattern: `(?i)\b(DROP\s+(TABLE|DATABASE|SCHEMA)|TRUNCATE\s+TABLE)\b`, Action: GuardrailAnnotate, Note: "dropping or truncating data is not reversible without a backup"},
+			{Name: "force-push", Pattern: `\bgit\s+push\s+.*(--force\b|-f\b)`, Action: GuardrailAnnotate, Note: "force-pushing rewrites shared history"},
+		},
+	},
+}
+
+// GuardrailStore holds the available profiles and each chat's active one.
+type GuardrailStore struct {
+	mu       sync.RWMutex
+	profiles map[string]*GuardrailProfile
+	defaults string
+	active   map[int64]string
+}
+
+// LoadGuardrailProfiles returns the built-in profiles plus any from path (a
+// JSON list of profiles), with their patterns compiled. Custom profiles
+// override built-ins of the same name.
+func LoadGuardrailProfiles(path string) ([]GuardrailProfile, error) {
+	profiles := append([]GuardrailProfile(nil), builtinGuardrails...)
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("read guardrails file: %w", err)
+		}
+		var custom []GuardrailProfile
+		if err := json.Unmarshal(data, &custom); err != nil {
+			return nil, fmt.Errorf("parse guardrails file: %w", err)
+		}
+		profiles = append(profiles, custom...)
+	}
+	for i := range profiles {
+		p := &profiles[i]
+		p.Rules = append([]GuardrailRule(nil), p.Rules...)
+		for j := range p.Rules {
+			r := &p.Rules[j]
+			re, err := regexp.Compile(r.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("guardrail %s/%s: %w", p.Name, r.Name, err)
+			}
+			r.re = re
+			if r.Action != GuardrailAnnotate {
+				r.Action = GuardrailRedact
+			}
+		}
+	}
+	return profiles, nil
+}
+
+// NewGuardrailStore indexes profiles by name. defaultProfile applies to
+// chats that haven't chosen one; unknown names fall back to "none".
+func NewGuardrailStore(profiles []GuardrailProfile, defaultProfile string) *GuardrailStore {
+	s := &GuardrailStore{profiles: make(map[string]*GuardrailProfile), active: make(map[int64]string)}
+	for i := range profiles {
+		s.profiles[profiles[i].Name] = &profiles[i]
+	}
+	if _, ok := s.profiles["none"]; !ok {
+		s.profiles["none"] = &GuardrailProfile{Name: "none"}
+	}
+	if _, ok := s.profiles[defaultProfile]; !ok {
+		defaultProfile = "none"
+	}
+	s.defaults = defaultProfile
+	return s
+}
+
+// Reload replaces the profiles and the default. Chats whose profile no
+// longer exists fall back to the default.
+func (s *GuardrailStore) Reload(profiles []GuardrailProfile, defaultProfile string) {
+	fresh := NewGuardrailStore(profiles, defaultProfile)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.profiles, s.defaults = fresh.profiles, fresh.defaults
+	for chatID, name := range s.active {
+		if _, ok := s.profiles[name]; !ok {
+			delete(s.active, chatID)
+		}
+	}
+}
+
+// Get returns the chat's active profile.
+func (s *GuardrailStore) Get(chatID int64) *GuardrailProfile {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if name, ok := s.active[chatID]; ok {
+		return s.profiles[name]
+	}
+	return s.profiles[s.defaults]
+}
+
+// Set selects a profile for the chat. Returns false if it doesn't exist.
+func (s *GuardrailStore) Set(chatID int64, name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.profiles[name]; !ok {
+		return false
+	}
+	s.active[chatID] = name
+	return true
+}
+
+// Names returns all profile names, sorted.
+func (s *GuardrailStore) Names() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	names := make([]string, 0, len(s.profiles))
+	for name := range s.profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Apply runs the profile's rules over text, redacting or annotating matches.
+func (p *GuardrailProfile) Apply(text string) string {
+	var notes []string
+	for _, r := range p.Rules {
+		if !r.re.MatchString(text) {
+			continue
+		}
+		slog.Info("guardrail matched", "profile", p.Name, "rule", r.Name, "action", r.Action)
+		if r.Action == GuardrailRedact {
+			text = r.re.ReplaceAllString(text, "[REDACTED]")
+			continue
+		}
+		note := r.Note
+		if note == "" {
+			note = "review carefully before acting on this"
+		}
+		notes = append(notes, fmt.Sprintf("⚠️ Guardrail %s: %s", r.Name, note))
+	}
+	if len(notes) > 0 {
+		text += "\n\n" + strings.Join(notes, "\n")
+	}
+	return text
+}
+
+// withGuardrailPrompt prefixes message with the chat's guardrail prompt block.
+func (h *Handlers) withGuardrailPrompt(chatID int64, message string) string {
+	p := h.guardrails.Get(chatID)
+	if p.Prompt == "" {
+		return message
+	}
+	return "[Guardrails: " + p.Name + "]\n" + p.Prompt + "\n\n" + message
+}
+
+// HandleGuardrails shows or sets the chat's guardrail profile.
+func (h *Handlers) HandleGuardrails(chatID int64, args string) {
+	name := strings.TrimSpace(args)
+	if name == "" {
+		active := h.guardrails.Get(chatID).Name
+		var b strings.Builder
+		b.WriteString(h.t(chatID, "guardrails.title"))
+		for _, n := range h.guardrails.Names() {
+			marker := "  "
+			if n == active {
+				marker = "✅"
+			}
+			fmt.Fprintf(&b, "%s %s\n", marker, n)
+		}
+		b.WriteString(h.t(chatID, "guardrails.footer"))
+		h.sender.SendPlain(chatID, b.String())
+		return
+	}
+	if !h.guardrails.Set(chatID, name) {
+		h.sender.SendPlain(chatID, h.t(chatID, "guardrails.unknown", name))
+		return
+	}
+	slog.Info("guardrail profile set", "chat_id", chatID, "profile", name)
+	h.sender.SendPlain(chatID, h.t(chatID, "guardrails.set", name))
+}
@@ -2,16 +2,21 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"maps"
+	"path/filepath"
 	"strings"
 	"sync"
 	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
-// ProviderStore is a thread-safe map of chatID → active provider ("claude"|"gemini").
+// ProviderStore is a thread-safe map of chatID → active provider ("claude"|"gemini"|"openrouter"|"azure"|"bedrock").
 type ProviderStore struct {
 	mu       sync.RWMutex
 	defaults string
@@ -46,23 +51,103 @@ func (p *ProviderStore) Delete(chatID int64) {
 	delete(p.m, chatID)
 }
 
+// Snapshot returns a copy of the chats' providers (chats on the default
+// have none).
+func (p *ProviderStore) Snapshot() map[int64]string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return maps.Clone(p.m)
+}
+
 // Handlers processes Telegram commands and messages.
 type Handlers struct {
 	sender         *Sender
 	claude         *ClaudeClient
 	gemini         *GeminiClient
+	openrouter     *OpenRouterClient
+	azure          *AzureOpenAIClient
+	bedrock        *BedrockClient
 	sessions       *SessionManager
 	geminiSessions *GeminiSessionStore
 	providers      *ProviderStore
 	approvals      *ApprovalStore
 	logins         *LoginStore
+	loginJournal   *LoginJournal
 	usage          *UsageTracker
 	media          *MediaHandler
+	topics         *TopicStore
 	locks          *ChatLocks
-	allowed        map[int64]bool
-	timeout        time.Duration
-	skipPerms      bool
-	maxRounds      int
+	frozen         *FreezeStore
+	shares         *ShareStore
+	metrics        *ProviderMetrics
+	projects       *ProjectStore
+	vars           *VarStore
+	inventory      *InventoryStore
+	systemPrompts  *SystemPromptStore
+	outputs        *OutputStore
+	checkpoints    *CheckpointStore
+	tunnels        *TunnelManager
+	plugins        *PluginRegistry
+	events         *EventEmitter
+	guardrails     *GuardrailStore
+	transcripts    *TranscriptLog
+	history        *HistoryStore
+	envSnapshot    bool
+	resultStrategy string
+	resultMaxBytes int
+	historyBudget  int
+	historySummary bool
+	breaker        *CircuitBreaker
+	failover       bool
+	typingEvery    time.Duration
+	streamEvery    time.Duration
+	languages      *LanguageStore
+	replyLang      string
+	codeFileBytes  int
+	streams        *StreamStore
+	asks           *AskStore
+	keyboards      *KeyboardStore
+	ledger         *UsageLedger
+	costRouter     *CostRouter
+	verdicts       *SafeguardLog
+	settings       *SettingsStore
+	allowlist      *AllowlistStore
+	exceptions     *SafeguardExceptions
+	exceptionReqs  *ExceptionRequests
+	reportSchedule *Schedule
+	reportChatID   int64
+	hygieneReport  *Schedule
+	fetchMaxBytes  int64
+	tts            *TTS // nil without TTS_CMD or TTS_API_URL
+	workspaces     *Workspaces
+	promptExpiry   time.Duration
+	approvalSLA    time.Duration
+	escalateTo     []int64
+	approvalStats  *ApprovalStats
+	testGate       string
+	budgetUSD      float64
+	startText      string
+	helpText       string
+	helpExtra      string
+	toolLog        bool
+	adminChatID    int64
+	admins         map[int64]bool
+	freezeOnBlock  bool
+	alive          *AliveSwitch
+	unauthAlerts   *AlertLimiter
+	readOnly       *ReadOnlyStore
+	emergency      *EmergencyStop
+	quotas         *UserQuotas
+
+	// Settings ReloadConfig replaces, guarded by liveMu.
+	configFile   string
+	clientPrompt string // SYSTEM_PROMPT the provider clients were built with
+	liveMu       sync.RWMutex
+	allowed      map[int64]bool
+	basePrompt   string
+	personas     []Persona
+	timeout      time.Duration
+	maxRounds    int
 }
 
 // ChatLocks manages per-chat mutexes.
@@ -91,107 +176,278 @@ func (c *ChatLocks) Lock(chatID int64) func() {
 	return l.Unlock
 }
 
-func NewHandlers(sender *Sender, claude *ClaudeClient, gemini *GeminiClient, sessions *SessionManager, geminiSessions *GeminiSessionStore, providers *ProviderStore, approvals *ApprovalStore, logins *LoginStore, usage *UsageTracker, media *MediaHandler, cfg *Config) *Handlers {
+// receivedKey carries when the message being handled was sent.
+type receivedKey struct{}
+
+// withReceived records when the message being handled was sent, so
+// lockFresh counts the time it spent queued before its handler started.
+func withReceived(ctx context.Context, sent time.Time) context.Context {
+	return context.WithValue(ctx, receivedKey{}, sent)
+}
+
+// lockFresh takes the chat lock for an incoming prompt. If the prompt is
+// older than PROMPT_EXPIRY once the lock is free (it waited behind a long
+// turn, for a worker slot, or while the bot was down) it is dropped with a
+// notice instead of running stale instructions, and ok is false. Age is
+// measured from the message's date (see withReceived), or from now when
+// ctx has none.
+func (h *Handlers) lockFresh(ctx context.Context, chatID int64, kind, text string) (unlock func(), ok bool) {
+	sent, _ := ctx.Value(receivedKey{}).(time.Time)
+	if sent.IsZero() {
+		sent = time.Now()
+	}
+	unlock = h.locks.Lock(chatID)
+	waited := time.Since(sent)
+	if h.promptExpiry <= 0 || waited < h.promptExpiry {
+		return unlock, true
+	}
+	unlock()
+	slog.Warn("queued prompt expired", "chat_id", chatID, "kind", kind, "waited", waited)
+	notice := h.t(chatID, "prompt_expired", h.kindName(chatID, kind), waited.Round(time.Minute))
+	if text != "" {
+		notice += "\n\n" + truncateText(text, 300)
+	}
+	h.sender.SendPlain(chatID, notice)
+	return nil, false
+}
+
+// kindName names a kind of message, such as "voice message", in chatID's
+// language.
+func (h *Handlers) kindName(chatID int64, kind string) string {
+	return h.t(chatID, "kind."+strings.ReplaceAll(strings.ToLower(kind), " ", "_"))
+}
+
+func NewHandlers(sender *Sender, claude *ClaudeClient, gemini *GeminiClient, sessions *SessionManager, geminiSessions *GeminiSessionStore, providers *ProviderStore, approvals *ApprovalStore, logins *LoginStore, usage *UsageTracker, media *MediaHandler, topics *TopicStore, cfg *Config) *Handlers {
+	shares := NewShareStore(cfg.DataDir)
+	sender.SetMirror(shares.Guests)
+	plugins := LoadPlugins(cfg.PluginDir, cfg.CommandTimeout)
+	openrouter := NewOpenRouterClient(cfg)
+	azure := NewAzureOpenAIClient(cfg)
+	bedrock := NewBedrockClient(cfg)
+	// Advertise plugin tags and <ask> questions to every provider.
+	claude.systemPrompt += plugins.Prompt() + askPrompt
+	gemini.systemPrompt += plugins.Prompt() + askPrompt
+	openrouter.systemPrompt += plugins.Prompt() + askPrompt
+	azure.systemPrompt += plugins.Prompt() + askPrompt
+	bedrock.systemPrompt += plugins.Prompt() + askPrompt
+	// Chats start with the provider they chose in /config.
+	settings := NewSettingsStore(cfg.DataDir)
+	for id, cs := range settings.All() {
+		if cs.Provider != "" {
+			providers.Set(id, cs.Provider)
+		}
+	}
+	// Both shells honor the admin's safeguard exceptions.
+	exceptions := NewSafeguardExceptions(cfg.DataDir)
+	claude.safeguard.SetExceptions(exceptions)
+	gemini.safeguard.SetExceptions(exceptions)
+	alive := NewAliveSwitch(cfg.DataDir, cfg.AlivePeriod)
+	claude.autorun.SetAlive(alive)
+	readOnly := NewReadOnlyStore(cfg.DataDir, cfg.ReadOnly)
+	claude.SetReadOnly(readOnly)
+	emergency := NewEmergencyStop(cfg.DataDir)
+	readOnly.SetEmergency(emergency)
+	readOnly.SetParentChat(topics.Chat)
+	claude.autorun.SetParentChat(topics.Chat)
+	frozen := NewFreezeStore(cfg.DataDir)
+	frozen.SetParentChat(topics.Chat)
+	ledger := NewUsageLedger(cfg.DataDir)
+	workspaces := NewWorkspaces(cfg.WorkDir, cfg.ChatWorkDirs, cfg.ChatDiskQuota)
 	return &Handlers{
 		sender:         sender,
 		claude:         claude,
 		gemini:         gemini,
+		openrouter:     openrouter,
+		azure:          azure,
+		bedrock:        bedrock,
 		sessions:       sessions,
 		geminiSessions: geminiSessions,
 		providers:      providers,
 		approvals:      approvals,
 		logins:         logins,
+		loginJournal:   NewLoginJournal(cfg.DataDir),
 		usage:          usage,
 		media:          media,
+		topics:         topics,
 		locks:          NewChatLocks(),
+		frozen:         frozen,
+		shares:         shares,
+		metrics:        NewProviderMetrics(cfg.DataDir),
+		projects:       NewProjectStore(cfg.DataDir, workspaces),
+		workspaces:     workspaces,
+		vars:           NewVarStore(cfg.DataDir),
+		inventory:      NewInventoryStore(cfg.DataDir),
+		systemPrompts:  NewSystemPromptStore(cfg.DataDir),
+		outputs:        NewOutputStore(cfg.OutputDiff),
+		checkpoints:    NewCheckpointStore(),
+		tunnels:        NewTunnelManager(),
+		plugins:        plugins,
+		events:         NewEventEmitter(cfg.EventWebhookURL, cfg.EventWebhookSecret),
+		budgetUSD:      cfg.SessionBudgetUSD,
+		guardrails:     NewGuardrailStore(cfg.GuardrailProfiles, cfg.GuardrailProfile),
+		transcripts:    NewTranscriptLog(cfg.TranscriptDir, time.Duration(cfg.TranscriptDays)*24*time.Hour),
+		testGate:       cfg.TestGateCommand,
+		history:        NewHistoryStore(),
+		envSnapshot:    cfg.EnvSnapshot,
+		resultStrategy: cfg.ResultStrategy,
+		resultMaxBytes: cfg.ResultMaxBytes,
+		historyBudget:  cfg.GeminiMaxHistory,
+		historySummary: cfg.GeminiSummarize,
+		breaker:        NewCircuitBreaker(cfg.CircuitFailures, cfg.CircuitCooldown),
+		failover:       cfg.CircuitFallback,
+		typingEvery:    cfg.TypingInterval,
+		streamEvery:    cfg.StreamInterval,
+		streams:        NewStreamStore(),
+		asks:           NewAskStore(),
+		keyboards:      NewKeyboardStore(),
+		languages:      NewLanguageStore(),
+		replyLang:      cfg.ReplyLanguage,
+		codeFileBytes:  cfg.CodeFileBytes,
+		ledger:         ledger,
+		costRouter:     NewCostRouter(cfg.CostRouting, ledger),
+		verdicts:       NewSafeguardLog(cfg.DataDir),
+		settings:       settings,
+		allowlist:      NewAllowlistStore(cfg.DataDir),
+		exceptions:     exceptions,
+		exceptionReqs:  NewExceptionRequests(),
+		reportSchedule: cfg.UsageReport,
+		reportChatID:   cfg.UsageReportChatID,
+		hygieneReport:  cfg.HygieneReport,
+		fetchMaxBytes:  cfg.FetchMaxBytes,
+		tts:            NewTTS(cfg),
+		promptExpiry:   cfg.PromptExpiry,
+		approvalSLA:    cfg.ApprovalSLA,
+		escalateTo:     cfg.ApprovalEscalation,
+		approvalStats:  NewApprovalStats(cfg.DataDir),
+		startText:      cfg.StartText,
+		helpText:       cfg.HelpText,
+		helpExtra:      cfg.HelpExtra,
+		toolLog:        cfg.ToolTranscript,
+		adminChatID:    cfg.AdminChatID,
+		admins:         chatIDSet(cfg.AdminChatIDs),
+		freezeOnBlock:  cfg.FreezeOnBlock,
+		alive:          alive,
+		unauthAlerts:   NewAlertLimiter(),
+		readOnly:       readOnly,
+		emergency:      emergency,
+		quotas:         NewUserQuotas(cfg.DataDir, cfg.UserDailyTokens, cfg.UserDailyUSD),
+		configFile:     cfg.ConfigFile,
+		clientPrompt:   cfg.SystemPrompt,
 		allowed:        cfg.AllowedChatIDs,
+		basePrompt:     cfg.SystemPrompt,
+		personas:       cfg.Personas,
 		timeout:        cfg.CommandTimeout,
-		skipPerms:      cfg.SkipPermissions,
 		maxRounds:      cfg.MaxToolRounds,
 	}
 }
 
-// IsAllowed checks if a chat ID is in the whitelist.
+// IsAllowed checks if a chat ID is in the whitelist. The admin chat is always
+// allowed. Forum topics follow their supergroup.
 func (h *Handlers) IsAllowed(chatID int64) bool {
-	return h.allowed[chatID]
+	chatID = h.topics.Chat(chatID)
+	return h.listed(chatID) || h.isAdmin(chatID)
+}
+
+// isAdmin reports whether chatID is one of ADMIN_CHAT_IDS (or
+// ADMIN_CHAT_ID), or a forum topic of one, which may run admin commands.
+func (h *Handlers) isAdmin(chatID int64) bool {
+	return h.admins[h.topics.Chat(chatID)]
 }
 
 func (h *Handlers) HandleStart(chatID int64) {
-	h.sender.SendPlain(chatID,
-		"Welcome to AI Code Bot!\n\n"+
-			"Send me any message and I'll forward it to Claude (default) or Gemini.\n"+
-			"Commands will require your approval before executing.\n"+
-			"Use /new to start a fresh conversation, /help for commands, or:\n"+
-			"  /claude — switch to Claude\n"+
-			"  /gemini — switch to Gemini\n"+
-			"  /model  — show active AI")
+	if h.startText != "" {
+		h.sender.SendPlain(chatID, h.startText)
+		return
+	}
+	h.sender.SendPlain(chatID, h.t(chatID, "start"))
 }
 
 func (h *Handlers) HandleNew(chatID int64) {
 	unlock := h.locks.Lock(chatID)
 	defer unlock()
 
-	log.Printf("[chat %d] session reset", chatID)
+	slog.Info("session reset", "chat_id", chatID)
 	h.sessions.Delete(chatID)
 	h.geminiSessions.Delete(chatID)
+	h.history.Reset(chatID)
 	h.approvals.Delete(chatID)
+	h.asks.Close(chatID)
 	h.usage.Reset(chatID)
-	// Reset Gemini working directory to the configured base.
-	h.gemini.mu.Lock()
-	h.gemini.cwd = h.gemini.workDir
-	h.gemini.mu.Unlock()
-	h.sender.SendPlain(chatID, "Session reset. Your next message will start a new conversation.")
-}
-
-func (h *Handlers) HandleHelp(chatID int64) {
-	h.sender.SendPlain(chatID,
-		"AI Code Bot — Commands:\n\n"+
-			"/start   - Welcome message\n"+
-			"/new     - Reset session (start fresh conversation)\n"+
-			"/claude  - Switch active AI to Claude\n"+
-			"/gemini  - Switch active AI to Gemini\n"+
-			"/model   - Show currently active AI and model\n"+
-			"/gmodel  - Switch Gemini model (when using Gemini)\n"+
-			"/login   - Login to the active AI (Claude OAuth / Gemini API key)\n"+
-			"/usage   - Check usage stats\n"+
-			"/safeguard <cmd> - Test a command against safeguard rules\n"+
-			"/help    - Show this help message\n\n"+
-			"Send any text message and I'll forward it to the active AI. "+
-			"When the AI suggests a command, you'll see Approve/Deny buttons. "+
-			"Conversation context is maintained until you use /new.")
+	// Reset Gemini working directory to the active project.
+	h.gemini.ResetCwd(chatID)
+	h.sender.SendPlain(chatID, h.t(chatID, "session_reset"))
+}
+
+func (h *Handlers) HandleHelp(chatID int64, args string) {
+	if name := strings.TrimPrefix(strings.TrimSpace(args), "/"); name != "" {
+		h.sendCommandHelp(chatID, strings.ToLower(name))
+		return
+	}
+	if h.helpText != "" {
+		h.sendHelp(chatID, h.helpText)
+		return
+	}
+	h.sendHelp(chatID, formatHelp(h.uiLanguage(chatID), h.isAdmin(chatID)))
+}
+
+// sendHelp sends help text followed by the operator's HELP_EXTRA (custom
+// commands, usage policies) when one is configured.
+func (h *Handlers) sendHelp(chatID int64, text string) {
+	if h.helpExtra != "" {
+		text += "\n\n" + h.helpExtra
+	}
+	h.sender.SendPlain(chatID, text)
 }
 
 func (h *Handlers) HandleSafeguard(chatID int64, command string) {
 	if command == "" {
-		h.sender.SendPlain(chatID, "Usage: /safeguard <command>\n\nExample: /safeguard rm -rf /\n\nTests a command against safeguard rules without executing it.")
+		h.sendUsage(chatID, "safeguard")
+		return
+	}
+	switch sub, rest, _ := strings.Cut(command, " "); sub {
+	case "report":
+		h.sendSafeguardReport(chatID, rest)
+		return
+	case "exceptions", "revoke":
+		h.handleSafeguardExceptions(chatID, sub, rest)
 		return
 	}
 	verdict, reason := h.claude.safeguard.Check(command)
 	if verdict == CommandBlocked {
-		h.sender.SendPlain(chatID, fmt.Sprintf("BLOCKED: %s", reason))
+		h.sender.SendPlain(chatID, h.t(chatID, "safeguard.blocked", reason))
 	} else {
-		h.sender.SendPlain(chatID, fmt.Sprintf("ALLOWED: Command '%s' would pass safeguard checks.", command))
+		h.sender.SendPlain(chatID, h.t(chatID, "safeguard.allowed", command))
 	}
 }
 
-func (h *Handlers) HandleUsage(chatID int64) {
-	log.Printf("[chat %d] usage command", chatID)
-
-	s := h.usage.Get(chatID)
+// HandleUsage shows the chat's usage since its session started, or with
+// "lifetime" its cumulative usage, which /new does not reset.
+// Usage: /usage [lifetime]
+func (h *Handlers) HandleUsage(chatID int64, args string) {
+	slog.Debug("usage command", "chat_id", chatID)
+
+	var s *ChatUsage
+	title := h.t(chatID, "usage.session")
+	switch strings.ToLower(strings.TrimSpace(args)) {
+	case "", "session":
+		s = h.usage.Get(chatID)
+	case "lifetime", "total":
+		s = h.usage.Lifetime(chatID)
+		if s != nil {
+			title = h.t(chatID, "usage.lifetime", s.Since.Format(time.DateOnly))
+		}
+	default:
+		h.sendUsage(chatID, "usage")
+		return
+	}
 	if s == nil || s.NumCalls == 0 {
-		h.sender.SendPlain(chatID, "No usage data yet. Send some messages first!")
+		h.sender.SendPlain(chatID, h.t(chatID, "usage.none"))
 		return
 	}
 
 	ago := time.Since(s.LastCallTime).Truncate(time.Second)
-	msg := fmt.Sprintf(
-		"Session usage:\n"+
-			"  Calls: %d\n"+
-			"  Input tokens: %d\n"+
-			"  Output tokens: %d\n"+
-			"  Cost: $%.4f\n"+
-			"  Duration: %s\n"+
-			"  Last call: %s ago",
+	msg := h.t(chatID, "usage.body",
+		title,
 		s.NumCalls,
 		s.InputTokens,
 		s.OutputTokens,
@@ -199,12 +455,32 @@ func (h *Handlers) HandleUsage(chatID int64) {
 		s.TotalDuration.Truncate(time.Second),
 		ago,
 	)
+	for _, provider := range []string{"claude", "gemini", "openrouter", "azure", "bedrock"} {
+		p := s.Providers[provider]
+		if p == nil {
+			continue
+		}
+		cost := fmt.Sprintf("$%.4f", p.CostUSD)
+		switch provider {
+		case "gemini":
+			cost = h.t(chatID, "usage.cost_estimate", cost)
+		case "azure", "bedrock":
+			cost = h.t(chatID, "usage.cost_cloud")
+		}
+		msg += h.t(chatID, "usage.provider", providerLabel(provider), p.NumCalls, p.InputTokens, p.OutputTokens, cost)
+	}
+	if len(s.Users) > 1 {
+		msg += "\n\n" + h.t(chatID, "usage.per_user") + formatUserUsage(s.Users)
+	}
 	h.sender.SendPlain(chatID, msg)
 }
 
-func (h *Handlers) HandleUnauthorized(chatID int64) {
-	log.Printf("WARN: Unauthorized access from chatID %d", chatID)
-	h.sender.SendPlain(chatID, fmt.Sprintf("Unauthorized. Your chat ID: %d", chatID))
+// HandleUnauthorized tells a chat outside the allowlist its ID, so it can
+// ask for access, and reports the contact to the admin chat.
+func (h *Handlers) HandleUnauthorized(c UnauthorizedContact) {
+	slog.Warn("unauthorized access", "chat_id", c.ChatID, "from", c.From)
+	h.reportUnauthorized(c)
+	h.sender.SendPlain(c.ChatID, h.t(c.ChatID, "unauthorized", c.ChatID))
 }
 
 // HandleSwitchProvider switches the active AI provider for a chat and resets the session.
@@ -214,76 +490,123 @@ func (h *Handlers) HandleSwitchProvider(chatID int64, provider string) {
 
 	current := h.providers.Get(chatID)
 	if current == provider {
-		h.sender.SendPlain(chatID, fmt.Sprintf("Already using %s.", provider))
+		h.sender.SendPlain(chatID, h.t(chatID, "provider.already", provider))
+		return
+	}
+	if (provider == "azure" || provider == "bedrock") && !h.hasAPIKey(provider) {
+		h.sender.SendPlain(chatID, h.cloudProviderHint(chatID, provider))
 		return
 	}
 
 	h.providers.Set(chatID, provider)
 	// Reset sessions so the new provider starts fresh.
-	h.sessions.Delete(chatID)
-	h.geminiSessions.Delete(chatID)
-	h.approvals.Delete(chatID)
+	h.resetSession(chatID)
 
-	log.Printf("[chat %d] switched provider: %s → %s", chatID, current, provider)
-	h.sender.SendPlain(chatID, fmt.Sprintf("Switched to %s. Starting a fresh session.", provider))
+	slog.Info("switched provider", "chat_id", chatID, "from", current, "provider", provider)
+	h.sender.SendPlain(chatID, h.t(chatID, "provider.switched", provider))
 }
 
 // HandleModel reports the currently active AI provider and model.
 func (h *Handlers) HandleModel(chatID int64) {
 	provider := h.providers.Get(chatID)
-	if provider == "gemini" {
-		h.sender.SendPlain(chatID, fmt.Sprintf("Current AI: %s (model: %s)\n\nUse /gmodel to switch Gemini models.", provider, h.gemini.GetModel()))
-	} else {
-		h.sender.SendPlain(chatID, fmt.Sprintf("Current AI: %s", provider))
+	var text string
+	switch provider {
+	case "gemini":
+		text = h.t(chatID, "model.current", provider, h.chatModel(chatID, provider)) + h.t(chatID, "model.hint_models")
+	case "openrouter":
+		text = h.t(chatID, "model.current", provider, h.chatModel(chatID, provider)) + h.t(chatID, "model.hint_omodel")
+	case "azure":
+		text = h.t(chatID, "model.current_azure", provider, h.azure.deployment, h.azure.apiVersion)
+	case "bedrock":
+		text = h.t(chatID, "model.current_bedrock", provider, h.bedrock.modelID, h.bedrock.region)
+	default:
+		text = h.t(chatID, "model.current", provider, h.claudeModelName(chatID, h.claude.Model(chatID))) + h.t(chatID, "model.hint_cmodel")
 	}
+	h.sender.SendPlain(chatID, text+h.costRoutingNote(chatID, provider))
 }
 
-// geminiModels is the list of available Gemini models shown in /gmodel.
-var geminiModels = []struct {
-	ID    string
-	Label string
-}{
-	{"gemini-2.5-flash", "⚡ Gemini 2.5 Flash (fast)"},
-	{"gemini-2.5-pro", "🧠 Gemini 2.5 Pro (smart)"},
-	{"gemini-3-flash-preview", "⚡ Gemini 3 Flash Preview"},
-	{"gemini-3-pro-preview", "🧠 Gemini 3 Pro Preview"},
-}
-
-// HandleGeminiModel shows an inline keyboard to pick a Gemini model.
-func (h *Handlers) HandleGeminiModel(chatID int64) {
-	current := h.gemini.GetModel()
+// claudeModels is the list of model aliases shown in /cmodel, labelled by
+// the "cmodel.<alias>" messages; the CLI resolves each to the latest model
+// of the family.
+var claudeModels = []string{"opus", "sonnet", "haiku"}
+
+// HandleClaudeModel shows an inline keyboard to pick the chat's Claude
+// model, or sets it directly when given (e.g. a full model name).
+// Usage: /cmodel [model|default]
+func (h *Handlers) HandleClaudeModel(chatID int64, args string) {
+	if model := strings.TrimSpace(args); model != "" {
+		if model == "default" {
+			model = ""
+		}
+		h.claude.SetModel(chatID, model)
+		slog.Info("model switched", "chat_id", chatID, "provider", "claude", "model", model)
+		h.sender.SendPlain(chatID, h.t(chatID, "cmodel.set", h.claudeModelName(chatID, h.claude.Model(chatID))))
+		return
+	}
 
+	current := h.claude.Model(chatID)
 	var rows [][]tgbotapi.InlineKeyboardButton
-	for _, m := range geminiModels {
-		label := m.Label
-		if m.ID == current {
+	for _, m := range claudeModels {
+		label := h.t(chatID, "cmodel."+m)
+		if m == current {
 			label = "✅ " + label
 		}
 		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData(label, "gmodel:"+m.ID),
+			tgbotapi.NewInlineKeyboardButtonData(label, "cmodel:"+m),
 		))
 	}
+	label := h.t(chatID, "model.cli_default")
+	if current == "" {
+		label = "✅ " + label
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(label, "cmodel:")))
 	keyboard := tgbotapi.NewInlineKeyboardMarkup(rows...)
-	h.sender.SendWithKeyboard(chatID, fmt.Sprintf("Current Gemini model: `%s`\nChoose a model:", current), keyboard)
+	h.sender.SendWithKeyboard(chatID, h.t(chatID, "cmodel.choose", h.claudeModelName(chatID, current)), keyboard)
+}
+
+// claudeModelName describes a --model value for display in chatID.
+func (h *Handlers) claudeModelName(chatID int64, model string) string {
+	if model == "" {
+		return h.t(chatID, "model.cli_default")
+	}
+	return model
 }
 
 // HandleMessage processes a user text message.
 func (h *Handlers) HandleMessage(ctx context.Context, chatID int64, text string) {
-	unlock := h.locks.Lock(chatID)
+	// A collaborating guest's messages go to the owner's session.
+	if owner, ok := h.shares.CollabOwner(chatID); ok && !h.logins.Has(chatID) {
+		slog.Info("forwarding message from collaborating chat", "chat_id", owner, "guest_chat_id", chatID)
+		h.sender.SendPlain(owner, h.t(owner, "share.forwarded", chatID, text))
+		chatID = owner
+	}
+
+	unlock, ok := h.lockFresh(ctx, chatID, "message", text)
+	if !ok {
+		return
+	}
 	defer unlock()
 
-	log.Printf("[chat %d] received message: %s", chatID, text)
+	slog.Info("received message", "chat_id", chatID, "text", text)
 
 	// If there's a pending login, treat this message as the auth code.
 	if pending := h.logins.Get(chatID); pending != nil {
-		log.Printf("[chat %d] pending login found, treating message as auth code", chatID)
+		slog.Info("pending login found, treating message as auth code", "chat_id", chatID)
 		h.handleLoginCode(ctx, chatID, text, pending)
 		return
 	}
 
-	if h.approvals.Has(chatID) {
-		log.Printf("[chat %d] blocked: pending approval exists", chatID)
-		h.sender.SendPlain(chatID, "Please approve or deny the pending command first.")
+	if h.rejectIfFrozen(chatID) || h.rejectIfOverQuota(ctx, chatID) {
+		return
+	}
+
+	if turn := h.approvals.Get(chatID); turn != nil {
+		if turn.Confirm != "" {
+			h.confirmTyped(ctx, chatID, turn, text)
+			return
+		}
+		slog.Info("message blocked: pending approval exists", "chat_id", chatID)
+		h.sender.SendPlain(chatID, h.t(chatID, "approval.pending_first"))
 		return
 	}
 
@@ -293,62 +616,41 @@ func (h *Handlers) HandleMessage(ctx context.Context, chatID int64, text string)
 
 // HandlePhoto processes a photo message.
 func (h *Handlers) HandlePhoto(ctx context.Context, chatID int64, photos []tgbotapi.PhotoSize, caption string) {
-	unlock := h.locks.Lock(chatID)
-	defer unlock()
-
-	log.Printf("[chat %d] received photo message", chatID)
-
-	if h.approvals.Has(chatID) {
-		h.sender.SendPlain(chatID, "Please approve or deny the pending command first.")
-		return
-	}
-
-	h.sender.SendTyping(chatID)
-
 	// Pick the largest photo (last in the array).
-	photo := photos[len(photos)-1]
-	path, err := h.media.DownloadFile(photo.FileID, "jpg")
-	if err != nil {
-		log.Printf("[chat %d] photo download error: %v", chatID, err)
-		h.sender.SendPlain(chatID, fmt.Sprintf("Failed to download photo: %v", err))
-		return
-	}
-	defer h.media.Cleanup(path)
-
-	message := fmt.Sprintf("The user sent an image saved at %s. Please read and analyze it.", path)
-	if caption != "" {
-		message += fmt.Sprintf("\nUser's message: %s", caption)
-	}
-
-	h.callAI(ctx, chatID, message)
+	h.HandlePhotos(ctx, chatID, photos[len(photos)-1:], caption)
 }
 
 // HandleVoice processes a voice message.
 func (h *Handlers) HandleVoice(ctx context.Context, chatID int64, voice *tgbotapi.Voice, caption string) {
-	unlock := h.locks.Lock(chatID)
+	unlock, ok := h.lockFresh(ctx, chatID, "voice message", caption)
+	if !ok {
+		return
+	}
 	defer unlock()
 
-	log.Printf("[chat %d] received voice message", chatID)
+	slog.Info("received voice message", "chat_id", chatID)
+
+	if h.rejectIfFrozen(chatID) || h.rejectIfOverQuota(ctx, chatID) {
+		return
+	}
 
 	if h.approvals.Has(chatID) {
-		h.sender.SendPlain(chatID, "Please approve or deny the pending command first.")
+		h.sender.SendPlain(chatID, h.t(chatID, "approval.pending_first"))
 		return
 	}
 
 	h.sender.SendTyping(chatID)
 
-	path, err := h.media.DownloadFile(voice.FileID, "ogg")
-	if err != nil {
-		log.Printf("[chat %d] voice download error: %v", chatID, err)
-		h.sender.SendPlain(chatID, fmt.Sprintf("Failed to download voice message: %v", err))
+	path, ok := h.downloadMedia(chatID, "voice message", voice.FileID, "ogg", voice.FileSize)
+	if !ok {
 		return
 	}
 	defer h.media.Cleanup(path)
 
-	transcript, err := h.media.TranscribeAudio(path)
+	transcript, err := h.media.TranscribeAudio(ctx, path)
 	if err != nil {
-		log.Printf("[chat %d] transcription error: %v", chatID, err)
-		h.sender.SendPlain(chatID, "Could not transcribe voice message. Make sure whisper is installed.")
+		slog.Error("transcription failed", "chat_id", chatID, "err", err)
+		h.sender.SendPlain(chatID, h.t(chatID, "media.transcribe_voice_failed"))
 		return
 	}
 
@@ -362,13 +664,20 @@ func (h *Handlers) HandleVoice(ctx context.Context, chatID int64, voice *tgbotap
 
 // HandleAudio processes an audio file message.
 func (h *Handlers) HandleAudio(ctx context.Context, chatID int64, audio *tgbotapi.Audio, caption string) {
-	unlock := h.locks.Lock(chatID)
+	unlock, ok := h.lockFresh(ctx, chatID, "audio file", caption)
+	if !ok {
+		return
+	}
 	defer unlock()
 
-	log.Printf("[chat %d] received audio message", chatID)
+	slog.Info("received audio message", "chat_id", chatID)
+
+	if h.rejectIfFrozen(chatID) || h.rejectIfOverQuota(ctx, chatID) {
+		return
+	}
 
 	if h.approvals.Has(chatID) {
-		h.sender.SendPlain(chatID, "Please approve or deny the pending command first.")
+		h.sender.SendPlain(chatID, h.t(chatID, "approval.pending_first"))
 		return
 	}
 
@@ -383,18 +692,16 @@ func (h *Handlers) HandleAudio(ctx context.Context, chatID int64, audio *tgbotap
 		}
 	}
 
-	path, err := h.media.DownloadFile(audio.FileID, ext)
-	if err != nil {
-		log.Printf("[chat %d] audio download error: %v", chatID, err)
-		h.sender.SendPlain(chatID, fmt.Sprintf("Failed to download audio: %v", err))
+	path, ok := h.downloadMedia(chatID, "audio file", audio.FileID, ext, audio.FileSize)
+	if !ok {
 		return
 	}
 	defer h.media.Cleanup(path)
 
-	transcript, err := h.media.TranscribeAudio(path)
+	transcript, err := h.media.TranscribeAudio(ctx, path)
 	if err != nil {
-		log.Printf("[chat %d] transcription error: %v", chatID, err)
-		h.sender.SendPlain(chatID, "Could not transcribe audio. Make sure whisper is installed.")
+		slog.Error("transcription failed", "chat_id", chatID, "err", err)
+		h.sender.SendPlain(chatID, h.t(chatID, "media.transcribe_audio_failed"))
 		return
 	}
 
@@ -406,84 +713,161 @@ func (h *Handlers) HandleAudio(ctx context.Context, chatID int64, audio *tgbotap
 	h.callAI(ctx, chatID, message)
 }
 
+// HandleDocument processes a file sent as a document.
+func (h *Handlers) HandleDocument(ctx context.Context, chatID int64, doc *tgbotapi.Document, caption string) {
+	unlock, ok := h.lockFresh(ctx, chatID, "file", caption)
+	if !ok {
+		return
+	}
+	defer unlock()
+
+	slog.Info("received document", "chat_id", chatID, "name", doc.FileName, "size", doc.FileSize)
+
+	if h.rejectIfFrozen(chatID) || h.rejectIfOverQuota(ctx, chatID) {
+		return
+	}
+
+	if h.approvals.Has(chatID) {
+		h.sender.SendPlain(chatID, h.t(chatID, "approval.pending_first"))
+		return
+	}
+
+	h.sender.SendTyping(chatID)
+
+	ext := strings.TrimPrefix(filepath.Ext(doc.FileName), ".")
+	if ext == "" {
+		ext = "bin"
+	}
+	path, ok := h.downloadMedia(chatID, "file", doc.FileID, ext, doc.FileSize)
+	if !ok {
+		return
+	}
+	defer h.media.Cleanup(path)
+
+	message := fmt.Sprintf("The user sent a file named %q, saved at %s. Please read it.", doc.FileName, path)
+	if caption != "" {
+		message += fmt.Sprintf("\nUser's message: %s", caption)
+	}
+
+	h.callAI(ctx, chatID, message)
+}
+
+// downloadMedia fetches a file the user sent, checking its reported size
+// first so oversized files are refused at once instead of failing part way.
+// what names the file in error messages. It reports the failure to the
+// chat and returns false; a refused document or video comes with a
+// pointer to /fetchfile, which takes files of any size.
+func (h *Handlers) downloadMedia(chatID int64, what, fileID, ext string, size int) (string, bool) {
+	err := h.media.CheckSize(int64(size))
+	if free := h.workspaces.Free(chatID); err == nil && free >= 0 && int64(size) > free {
+		err = fmt.Errorf("%w (%s left)", ErrOverQuota, formatBytes(free))
+	}
+	path := ""
+	if err == nil {
+		path, err = h.media.DownloadFile(h.workspaces.Dir(chatID), fileID, ext)
+	}
+	var tooBig *fileTooBigError
+	switch {
+	case errors.As(err, &tooBig):
+		slog.Info("media too big", "chat_id", chatID, "what", what, "size", tooBig.Size, "limit", tooBig.Limit)
+		msg := h.t(chatID, "media.too_big", formatBytes(tooBig.Limit))
+		if what == "file" || what == "video" {
+			msg += h.t(chatID, "media.fetch_hint")
+		}
+		h.sender.SendPlain(chatID, msg)
+		return "", false
+	case err != nil:
+		slog.Error("media download failed", "chat_id", chatID, "what", what, "err", err)
+		h.sender.SendPlain(chatID, h.t(chatID, "media.download_failed", h.kindName(chatID, what), err))
+		return "", false
+	}
+	return path, true
+}
+
 // HandleLogin starts the login flow for whichever AI provider is currently active.
 func (h *Handlers) HandleLogin(ctx context.Context, chatID int64) {
 	unlock := h.locks.Lock(chatID)
 	defer unlock()
 
 	provider := h.providers.Get(chatID)
-	if provider == "gemini" {
-		h.performGeminiLogin(ctx, chatID, "")
-	} else {
+	if provider == "claude" {
 		h.performLogin(ctx, chatID, "")
+	} else {
+		h.performKeyLogin(ctx, chatID, provider, "")
 	}
 }
 
-// performGeminiLogin sends the user the Google AI Studio link and waits for them to paste their API key.
-func (h *Handlers) performGeminiLogin(ctx context.Context, chatID int64, originalMessage string) {
+// performKeyLogin sends the user where to get an API key for provider
+// (Gemini or OpenRouter) and waits for them to paste it. Azure OpenAI and
+// Bedrock only get configuration instructions.
+func (h *Handlers) performKeyLogin(ctx context.Context, chatID int64, provider, originalMessage string) {
+	if provider == "azure" || provider == "bedrock" {
+		h.sender.SendPlain(chatID, h.cloudProviderHint(chatID, provider))
+		return
+	}
+
 	// Cancel any existing pending login.
 	if old := h.logins.Get(chatID); old != nil {
-		log.Printf("[chat %d] cancelling previous pending login", chatID)
+		slog.Info("cancelling previous pending login", "chat_id", chatID)
 		old.Cancel()
 		h.logins.Delete(chatID)
 	}
 
 	loginCtx, cancel := context.WithTimeout(ctx, 10*time.Minute)
 
-	msg, feedKey, err := h.gemini.SetupToken(loginCtx)
+	setup := h.gemini.SetupToken
+	if provider == "openrouter" {
+		setup = h.openrouter.SetupToken
+	}
+	feedKey, err := setup(loginCtx)
 	if err != nil {
 		cancel()
-		log.Printf("[chat %d] gemini setup-token failed: %v", chatID, err)
-		h.sender.SendPlain(chatID, fmt.Sprintf("Gemini login setup failed: %v", err))
+		slog.Error("setup-token failed", "chat_id", chatID, "provider", provider, "err", err)
+		h.sender.SendPlain(chatID, h.t(chatID, "login.setup_failed", providerLabel(provider), err))
 		return
 	}
 
+	h.loginJournal.Set(chatID, loginRecord{Provider: provider, State: loginWaitingCode, OriginalMessage: originalMessage, Started: time.Now()})
 	h.logins.Set(chatID, &PendingLogin{
 		FeedCode:        feedKey,
 		Cancel:          cancel,
 		OriginalMessage: originalMessage,
-		Provider:        "gemini",
+		Provider:        provider,
 	})
 
-	log.Printf("[chat %d] gemini login: waiting for user to paste API key", chatID)
-	h.sender.SendPlain(chatID, msg)
+	slog.Info("login: waiting for user to paste API key", "chat_id", chatID, "provider", provider)
+	h.sender.SendPlain(chatID, h.t(chatID, "login.key_"+provider))
 }
 
-// performLogin starts the OAuth login flow via `claude setup-token`.
-// Sends the URL to the user and stores state waiting for the auth code.
+// performLogin starts the Claude OAuth login and sends the URL to the
+// user; their next message is taken as the auth code.
 func (h *Handlers) performLogin(ctx context.Context, chatID int64, originalMessage string) {
 	// Cancel any existing pending login to avoid goroutine leaks.
 	if old := h.logins.Get(chatID); old != nil {
-		log.Printf("[chat %d] cancelling previous pending login", chatID)
+		slog.Info("cancelling previous pending login", "chat_id", chatID)
 		old.Cancel()
 		h.logins.Delete(chatID)
 	}
 
-	h.sender.SendPlain(chatID, "Claude is not logged in. Starting OAuth login...")
-
-	loginCtx, cancel := context.WithTimeout(ctx, 5*time.Minute)
-
-	url, feedCode, err := h.claude.SetupToken(loginCtx)
+	loginCtx, cancel := context.WithCancel(ctx)
+	login, err := h.claude.StartLogin(loginCtx, h.claudeLoginObserver(chatID, originalMessage))
 	if err != nil {
 		cancel()
-		log.Printf("[chat %d] setup-token failed: %v", chatID, err)
-		h.sender.SendPlain(chatID, fmt.Sprintf("Login failed: %v", err))
+		slog.Error("claude login failed", "chat_id", chatID, "provider", "claude", "err", err)
+		h.sender.SendPlain(chatID, h.t(chatID, "login.failed", err))
 		return
 	}
 
 	// Store pending login — the next message from this user will be treated as the code.
 	h.logins.Set(chatID, &PendingLogin{
-		FeedCode:        feedCode,
+		FeedCode:        func(code string) error { return login.SubmitCode(loginCtx, code) },
 		Cancel:          cancel,
 		OriginalMessage: originalMessage,
 		Provider:        "claude",
 	})
 
-	log.Printf("[chat %d] login URL obtained, waiting for user to send auth code", chatID)
-	h.sender.SendPlain(chatID, fmt.Sprintf(
-		"Open this URL to login with your Google account:\n\n%s\n\n"+
-			"After authenticating, you'll receive an authorization code.\n"+
-			"Paste that code here as your next message.", url))
+	slog.Info("login URL obtained, waiting for user to send auth code", "chat_id", chatID, "provider", "claude")
+	h.sender.SendPlain(chatID, h.t(chatID, "login.url", login.URL, loginTimeouts[loginWaitingCode]))
 }
 
 // handleLoginCode processes the auth code/key the user sends during a login flow.
@@ -493,138 +877,295 @@ func (h *Handlers) handleLoginCode(ctx context.Context, chatID int64, code strin
 
 	code = strings.TrimSpace(code)
 	if code == "" {
-		h.sender.SendPlain(chatID, "Empty input. Please try again by sending a new message.")
+		h.loginJournal.Delete(chatID)
+		h.sender.SendPlain(chatID, h.t(chatID, "login.empty"))
 		return
 	}
 
-	if pending.Provider == "gemini" {
-		log.Printf("[chat %d] verifying Gemini API key", chatID)
-		h.sender.SendPlain(chatID, "Verifying API key...")
-	} else {
-		log.Printf("[chat %d] feeding auth code to setup-token", chatID)
-		h.sender.SendPlain(chatID, "Verifying auth code...")
+	// Claude's login reports its own progress.
+	if isChatProvider(pending.Provider) {
+		slog.Info("verifying API key", "chat_id", chatID, "provider", pending.Provider)
+		h.sender.SendPlain(chatID, h.t(chatID, "login.verifying_key"))
 	}
 
-	if err := pending.FeedCode(code); err != nil {
-		log.Printf("[chat %d] login error: %v", chatID, err)
-		h.sender.SendPlain(chatID, fmt.Sprintf("Login failed: %v\nPlease try again with /login.", err))
+	err := pending.FeedCode(code)
+	h.loginJournal.Delete(chatID)
+	if err != nil {
+		slog.Error("login failed", "chat_id", chatID, "err", err)
+		h.sender.SendPlain(chatID, h.t(chatID, "login.failed_retry", err))
 		return
 	}
 
-	log.Printf("[chat %d] login successful (provider=%s)", chatID, pending.Provider)
-	if pending.OriginalMessage == "" {
-		providerName := pending.Provider
+	h.finishLogin(ctx, chatID, pending.Provider, pending.OriginalMessage)
+}
+
+// finishLogin announces a successful login and retries the message that
+// needed it, if any.
+func (h *Handlers) finishLogin(ctx context.Context, chatID int64, provider, originalMessage string) {
+	slog.Info("login successful", "chat_id", chatID, "provider", provider)
+	h.events.Emit(Event{Type: EventLogin, ChatID: chatID, Provider: provider})
+	if originalMessage == "" {
+		providerName := provider
 		if providerName == "" {
 			providerName = "Claude"
 		}
-		h.sender.SendPlain(chatID, fmt.Sprintf("Login successful! You can now send messages to %s.", providerName))
+		h.sender.SendPlain(chatID, h.t(chatID, "login.success", providerName))
 		return
 	}
-	log.Printf("[chat %d] retrying original message after login", chatID)
-	h.sender.SendPlain(chatID, "Login successful! Processing your message...")
+	slog.Info("retrying original message after login", "chat_id", chatID)
+	h.sender.SendPlain(chatID, h.t(chatID, "login.success_retry"))
 	h.sender.SendTyping(chatID)
-	h.callAI(ctx, chatID, pending.OriginalMessage)
+	h.callAI(ctx, chatID, originalMessage)
 }
 
 // callAI dispatches to the active AI provider for this chat.
 func (h *Handlers) callAI(ctx context.Context, chatID int64, message string) {
-	provider := h.providers.Get(chatID)
-	log.Printf("[chat %d] callAI: provider=%s", chatID, provider)
+	provider := h.failoverProvider(chatID, h.providers.Get(chatID))
+	slog.Debug("callAI", "chat_id", chatID, "provider", provider)
+	message = h.vars.Expand(chatID, message)
+	h.record(TranscriptEntry{ChatID: chatID, Kind: TranscriptUser, Provider: provider, Text: message})
+	h.languages.Observe(chatID, message)
 	switch provider {
-	case "gemini":
-		h.callGemini(ctx, chatID, message)
+	case "gemini", "openrouter", "azure", "bedrock":
+		h.callChat(ctx, chatID, provider, message)
 	default:
 		h.callClaude(ctx, chatID, message)
 	}
 }
 
+// sendClaude calls the Claude CLI and records provider metrics. With
+// onDelta the reply is streamed (see canStream); onTool gets Claude's tool
+// uses (see toolLog).
+func (h *Handlers) sendClaude(ctx context.Context, chatID int64, sessionID, message string, onDelta, onTool func(string)) (*ClaudeResponse, error) {
+	ctx, span := tracer.Start(ctx, "claude.send", trace.WithAttributes(
+		attribute.Int64("chat.id", chatID),
+		attribute.Bool("session.resumed", sessionID != ""),
+	))
+	ctx = h.withMaxTokens(h.withSystemPrompt(ctx, chatID, "claude"), chatID)
+	ctx = h.withCostRouting(ctx, chatID, "claude")
+	if err := h.breaker.Allow("claude"); err != nil {
+		endSpan(span, err)
+		return nil, err
+	}
+	// The language instruction goes with every message, since the user may
+	// switch languages mid-session.
+	message = h.withLengthPrompt(chatID, h.withLanguagePrompt(chatID, message))
+	if sessionID == "" {
+		message = h.withGuardrailPrompt(chatID, h.withEnvSnapshot(ctx, chatID, message))
+	}
+	start := time.Now()
+	var resp *ClaudeResponse
+	var err error
+	if onDelta != nil || onTool != nil {
+		resp, err = h.claude.Stream(ctx, chatID, h.projectDir(chatID), sessionID, message, onDelta, onTool)
+	} else {
+		resp, err = h.claude.Send(ctx, chatID, h.projectDir(chatID), sessionID, message)
+	}
+	h.breaker.Record("claude", err)
+	var tokens int64
+	if resp != nil && !errors.Is(err, context.Canceled) {
+		tokens = resp.Usage.InputTokens + resp.Usage.OutputTokens
+		h.record(TranscriptEntry{ChatID: chatID, Kind: TranscriptAssistant, Provider: "claude", Text: resp.Result})
+	}
+	elapsed := time.Since(start)
+	h.metrics.Record("claude", elapsed, tokens, err)
+	slog.Info("provider call", "chat_id", chatID, "provider", "claude", "duration", elapsed, "tokens", tokens, "err", err)
+	span.SetAttributes(attribute.Int64("tokens", tokens))
+	endSpan(span, err)
+	return resp, err
+}
+
+// sendGemini calls the Gemini API and records provider metrics. With
+// onDelta the reply is streamed (see canStream).
+func (h *Handlers) sendGemini(ctx context.Context, chatID int64, history []GeminiMessage, message string, onDelta func(string)) (string, error) {
+	ctx, span := tracer.Start(ctx, "gemini.send", trace.WithAttributes(
+		attribute.Int64("chat.id", chatID),
+		attribute.Int("history.messages", len(history)),
+	))
+	ctx = h.withMaxTokens(h.withSystemPrompt(ctx, chatID, "gemini"), chatID)
+	ctx = h.withCostRouting(h.withChatModel(ctx, chatID, "gemini"), chatID, "gemini")
+	if err := h.breaker.Allow("gemini"); err != nil {
+		endSpan(span, err)
+		return "", err
+	}
+	// Gemini is stateless per request and history keeps the raw message,
+	// so the guardrail, language and length blocks are sent with every call.
+	message = h.withGuardrailPrompt(chatID, h.withLengthPrompt(chatID, h.withLanguagePrompt(chatID, message)))
+	start := time.Now()
+	var result string
+	var usage GeminiUsage
+	var err error
+	if onDelta != nil {
+		result, usage, err = h.gemini.Stream(ctx, history, message, onDelta)
+	} else {
+		result, usage, err = h.gemini.Send(ctx, history, message)
+	}
+	h.breaker.Record("gemini", err)
+	elapsed := time.Since(start)
+	h.metrics.Record("gemini", elapsed, usage.TotalTokens, err)
+	h.recordGeminiUsage(ctx, chatID, usage, elapsed)
+	if err == nil {
+		h.record(TranscriptEntry{ChatID: chatID, Kind: TranscriptAssistant, Provider: "gemini", Text: result})
+	}
+	span.SetAttributes(attribute.Int64("tokens", usage.TotalTokens))
+	slog.Info("provider call", "chat_id", chatID, "provider", "gemini", "duration", elapsed, "tokens", usage.TotalTokens, "err", err)
+	endSpan(span, err)
+	return result, err
+}
+
+// sendAPI calls an HTTP chat provider other than Gemini (OpenRouter, Azure
+// OpenAI, Bedrock) and records provider metrics. With onDelta the reply is
+// streamed (see canStream).
+func (h *Handlers) sendAPI(ctx context.Context, chatID int64, provider string, history []GeminiMessage, message string, onDelta func(string)) (string, error) {
+	ctx, span := tracer.Start(ctx, provider+".send", trace.WithAttributes(
+		attribute.Int64("chat.id", chatID),
+		attribute.Int("history.messages", len(history)),
+	))
+	ctx = h.withMaxTokens(h.withSystemPrompt(ctx, chatID, provider), chatID)
+	ctx = h.withCostRouting(h.withChatModel(ctx, chatID, provider), chatID, provider)
+	if err := h.breaker.Allow(provider); err != nil {
+		endSpan(span, err)
+		return "", err
+	}
+	message = h.withGuardrailPrompt(chatID, h.withLengthPrompt(chatID, h.withLanguagePrompt(chatID, message)))
+	start := time.Now()
+	var result string
+	var usage APIUsage
+	var err error
+	if onDelta != nil {
+		result, usage, err = h.apiStreamer(provider)(ctx, history, message, onDelta)
+	} else {
+		result, usage, err = h.apiSender(provider)(ctx, history, message)
+	}
+	h.breaker.Record(provider, err)
+	elapsed := time.Since(start)
+	h.metrics.Record(provider, elapsed, usage.TotalTokens, err)
+	h.recordAPIUsage(ctx, chatID, provider, usage, elapsed)
+	if err == nil {
+		h.record(TranscriptEntry{ChatID: chatID, Kind: TranscriptAssistant, Provider: provider, Text: result})
+	}
+	span.SetAttributes(attribute.Int64("tokens", usage.TotalTokens), attribute.String("model", usage.Model))
+	slog.Info("provider call", "chat_id", chatID, "provider", provider, "model", usage.Model, "duration", elapsed, "tokens", usage.TotalTokens, "err", err)
+	endSpan(span, err)
+	return result, err
+}
+
+// sendChat calls one of the stateless chat providers, whose conversation
+// history the bot keeps in geminiSessions.
+func (h *Handlers) sendChat(ctx context.Context, chatID int64, provider string, history []GeminiMessage, message string) (string, error) {
+	if provider != "gemini" {
+		return h.sendAPI(ctx, chatID, provider, history, message, nil)
+	}
+	return h.sendGemini(ctx, chatID, history, message, nil)
+}
+
 // callClaude calls the Claude CLI and processes the response.
 // If commands are found, shows approval buttons. Otherwise sends text.
 func (h *Handlers) callClaude(ctx context.Context, chatID int64, message string) {
-	claudeCtx, cancel := context.WithTimeout(ctx, h.timeout)
+	claudeCtx, cancel := context.WithTimeout(ctx, h.timeoutFor(chatID))
 	defer cancel()
 
 	// Typing indicator.
 	done := make(chan struct{})
-	go func() {
-		ticker := time.NewTicker(4 * time.Second)
-		defer ticker.Stop()
-		for {
-			select {
-			case <-ticker.C:
-				h.sender.SendTyping(chatID)
-			case <-done:
-				return
-			}
-		}
-	}()
+	go h.keepTyping(chatID, done)
 
 	sessionID := h.sessions.Get(chatID)
 	if sessionID != "" {
-		log.Printf("[chat %d] calling Claude (session=%s)", chatID, sessionID)
+		slog.Info("calling provider", "chat_id", chatID, "provider", "claude", "session", sessionID)
+	} else {
+		slog.Info("calling provider", "chat_id", chatID, "provider", "claude", "session", "new")
+	}
+	slog.Debug("provider input", "chat_id", chatID, "message", truncateText(message, 200))
+	var tools *toolLog
+	var onTool func(string)
+	if h.toolLog && h.claude.HasTools(chatID) {
+		tools = h.newToolLog(chatID)
+		onTool = tools.add
+	}
+	input := h.withInventory(message, sessionID == "")
+	var resp *ClaudeResponse
+	var err error
+	if h.canStream("claude") {
+		stopped := h.withStream(claudeCtx, chatID, "claude", func(ctx context.Context, onDelta func(string)) {
+			resp, err = h.sendClaude(ctx, chatID, sessionID, input, onDelta, onTool)
+		})
+		if stopped {
+			close(done)
+			tools.close()
+			h.recordClaudeStopped(chatID, resp)
+			return
+		}
 	} else {
-		log.Printf("[chat %d] calling Claude (new session)", chatID)
+		resp, err = h.sendClaude(claudeCtx, chatID, sessionID, input, nil, onTool)
 	}
-	log.Printf("[chat %d] message: %.200s", chatID, message)
-	resp, err := h.claude.Send(claudeCtx, chatID, sessionID, message)
 	close(done)
+	tools.close()
 
 	if err != nil {
 		if IsNotLoggedIn(err) {
-			log.Printf("[chat %d] Claude not logged in, starting OAuth flow", chatID)
+			slog.Info("not logged in, starting OAuth flow", "chat_id", chatID, "provider", "claude")
 			h.performLogin(ctx, chatID, message)
 			return
 		}
-		log.Printf("claude error (chat %d): %v", chatID, err)
-		h.sender.SendPlain(chatID, fmt.Sprintf("Error: %v", err))
+		slog.Error("provider call failed", "chat_id", chatID, "provider", "claude", "err", err)
+		h.sender.SendPlain(chatID, h.t(chatID, "error", err))
 		return
 	}
 
 	// Track usage.
-	h.usage.Record(chatID, resp)
+	h.recordUsage(ctx, chatID, resp)
 
 	// Update session ID.
 	if resp.SessionID != "" {
-		log.Printf("[chat %d] session updated: %s", chatID, resp.SessionID)
+		slog.Debug("session updated", "chat_id", chatID, "session", resp.SessionID)
 		h.sessions.Set(chatID, resp.SessionID)
 	}
 
 	result := resp.Result
 	if result == "" {
-		log.Printf("[chat %d] empty response from Claude", chatID)
-		h.sender.SendPlain(chatID, "(empty response)")
+		slog.Warn("empty response", "chat_id", chatID, "provider", "claude")
+		h.sender.SendPlain(chatID, h.t(chatID, "reply.empty"))
 		return
 	}
 
-	log.Printf("[chat %d] response length: %d bytes", chatID, len(result))
+	slog.Debug("provider response", "chat_id", chatID, "provider", "claude", "bytes", len(result))
 
 	// Parse <command> tags.
-	cleanText, commands := ParseCommands(result)
-	log.Printf("[chat %d] parsed response: %d commands found, text=%d bytes", chatID, len(commands), len(cleanText))
+	cleanText, commands := h.parseCalls(result)
+	slog.Info("parsed response", "chat_id", chatID, "provider", "claude", "commands", len(commands), "text_bytes", len(cleanText))
 
 	// Send the text part to user.
 	if cleanText != "" {
-		log.Printf("[chat %d] sending text response to user", chatID)
-		h.sender.Send(chatID, cleanText)
+		slog.Debug("sending text response to user", "chat_id", chatID)
+		h.sendReply(ctx, chatID, cleanText)
 	}
 
 	// No commands — we're done.
 	if len(commands) == 0 {
-		log.Printf("[chat %d] no commands, done", chatID)
+		slog.Debug("no commands, done", "chat_id", chatID)
+		h.events.Emit(Event{Type: EventTaskCompleted, ChatID: chatID, Provider: "claude"})
 		return
 	}
 
 	for i, cmd := range commands {
-		log.Printf("[chat %d] command %d: %s", chatID, i+1, cmd)
+		slog.Debug("proposed command", "chat_id", chatID, "index", i+1, "command", cmd)
 	}
 
-	// SKIP_PERMISSIONS: auto-execute all commands.
-	if h.skipPerms {
-		log.Printf("[chat %d] skip_permissions=true, auto-executing %d commands", chatID, len(commands))
-		h.autoExecuteClaude(ctx, chatID, commands, resp.SessionID)
+	if h.readOnly.Enabled(chatID) {
+		h.showSuggestions(chatID, "claude", commands)
 		return
 	}
 
+	// Auto-execute (SKIP_PERMISSIONS or /autorun): run all commands.
+	if h.claude.autorun.Enabled(chatID) {
+		slog.Info("auto-executing commands", "chat_id", chatID, "provider", "claude", "commands", len(commands))
+		h.autoExecuteClaude(ctx, chatID, message, commands, resp.SessionID)
+		return
+	}
+	if h.autorunPaused(chatID) {
+		h.sender.SendPlain(chatID, h.t(chatID, "autorun.paused_commands"))
+	}
+
 	// Store pending turn and show first approval button.
 	turn := &PendingTurn{
 		Commands:  commands,
@@ -632,46 +1173,53 @@ func (h *Handlers) callClaude(ctx context.Context, chatID int64, message string)
 		SessionID: resp.SessionID,
 		Provider:  "claude",
 	}
-	log.Printf("[chat %d] storing %d pending commands, waiting for approval", chatID, len(commands))
+	slog.Info("waiting for approval", "chat_id", chatID, "commands", len(commands))
 	h.approvals.Set(chatID, turn)
 	h.showApproval(chatID, turn)
 }
 
-// callGemini calls the Gemini CLI and processes the response.
-func (h *Handlers) callGemini(ctx context.Context, chatID int64, message string) {
-	geminiCtx, cancel := context.WithTimeout(ctx, h.timeout)
+// callChat calls a stateless chat provider (see isChatProvider) with the
+// chat's stored history and processes the response.
+func (h *Handlers) callChat(ctx context.Context, chatID int64, provider, message string) {
+	geminiCtx, cancel := context.WithTimeout(ctx, h.timeoutFor(chatID))
 	defer cancel()
 
 	// Typing indicator.
 	done := make(chan struct{})
-	go func() {
-		ticker := time.NewTicker(4 * time.Second)
-		defer ticker.Stop()
-		for {
-			select {
-			case <-ticker.C:
-				h.sender.SendTyping(chatID)
-			case <-done:
-				return
-			}
-		}
-	}()
+	go h.keepTyping(chatID, done)
 
-	history := h.geminiSessions.Get(chatID)
-	log.Printf("[chat %d] calling Gemini (history turns=%d)", chatID, len(history))
-	log.Printf("[chat %d] message: %.200s", chatID, message)
-
-	result, err := h.gemini.Send(geminiCtx, history, message)
+	history := h.trimGeminiHistory(geminiCtx, chatID, provider, h.geminiSessions.Get(chatID))
+	if len(history) == 0 {
+		// Added here rather than in sendChat so the snapshot is kept in
+		// the stored history for the rest of the session.
+		message = h.withEnvSnapshot(geminiCtx, chatID, message)
+	}
+	message = h.withInventory(message, len(history) == 0)
+	slog.Info("calling provider", "chat_id", chatID, "provider", provider, "history_turns", len(history))
+	slog.Debug("provider input", "chat_id", chatID, "message", truncateText(message, 200))
+
+	var result string
+	var err error
+	if h.canStream(provider) {
+		result, err = h.streamChat(geminiCtx, chatID, provider, history, message)
+	} else {
+		result, err = h.sendChat(geminiCtx, chatID, provider, history, message)
+	}
 	close(done)
 
+	if errors.Is(err, errStreamStopped) {
+		// Keep what was generated; commands in a cut-off reply are not run.
+		h.recordStopped(chatID, provider, message, result)
+		return
+	}
 	if err != nil {
-		if !h.gemini.HasAPIKey() || IsGeminiNotLoggedIn(err) {
-			log.Printf("[chat %d] Gemini not authenticated, starting API key flow", chatID)
-			h.performGeminiLogin(ctx, chatID, message)
+		if !h.hasAPIKey(provider) || IsGeminiNotLoggedIn(err) {
+			slog.Info("not authenticated, starting API key flow", "chat_id", chatID, "provider", provider)
+			h.performKeyLogin(ctx, chatID, provider, message)
 			return
 		}
-		log.Printf("gemini error (chat %d): %v", chatID, err)
-		h.sender.SendPlain(chatID, fmt.Sprintf("Error from Gemini: %v", err))
+		slog.Error("provider call failed", "chat_id", chatID, "provider", provider, "err", err)
+		h.sender.SendPlain(chatID, providerErrorText(provider, err))
 		return
 	}
 
@@ -681,42 +1229,51 @@ func (h *Handlers) callGemini(ctx context.Context, chatID int64, message string)
 		GeminiMessage{Role: "model", Content: result},
 	)
 
-	log.Printf("[chat %d] gemini response length: %d bytes", chatID, len(result))
+	slog.Debug("provider response", "chat_id", chatID, "provider", provider, "bytes", len(result))
 
 	// Parse <command> tags.
-	cleanText, commands := ParseCommands(result)
-	log.Printf("[chat %d] parsed gemini response: %d commands, text=%d bytes", chatID, len(commands), len(cleanText))
+	cleanText, commands := h.parseCalls(result)
+	slog.Info("parsed response", "chat_id", chatID, "provider", provider, "commands", len(commands), "text_bytes", len(cleanText))
 
 	if cleanText != "" {
-		h.sender.Send(chatID, cleanText)
+		h.sendReply(ctx, chatID, cleanText)
 	}
 
 	if len(commands) == 0 {
+		h.events.Emit(Event{Type: EventTaskCompleted, ChatID: chatID, Provider: provider})
 		return
 	}
 
 	for i, cmd := range commands {
-		log.Printf("[chat %d] gemini command %d: %s", chatID, i+1, cmd)
+		slog.Debug("proposed command", "chat_id", chatID, "provider", provider, "index", i+1, "command", cmd)
 	}
 
-	// Enforce one command per turn: only take the first command even if Gemini
-	// sent multiple. The next command will come after we feed the output back.
+	if h.readOnly.Enabled(chatID) {
+		h.showSuggestions(chatID, provider, commands)
+		return
+	}
+
+	// Enforce one command per turn: only take the first command even if the
+	// model sent multiple. The next command will come after we feed the output back.
 	if len(commands) > 1 {
-		log.Printf("[chat %d] gemini sent %d commands, trimming to 1", chatID, len(commands))
+		slog.Info("trimming commands to one per turn", "chat_id", chatID, "provider", provider, "commands", len(commands))
 		commands = commands[:1]
 	}
 
-	if h.skipPerms {
-		log.Printf("[chat %d] skip_permissions=true, auto-executing %d gemini commands", chatID, len(commands))
-		h.autoExecuteGemini(ctx, chatID, commands)
+	if h.claude.autorun.Enabled(chatID) {
+		slog.Info("auto-executing commands", "chat_id", chatID, "provider", provider, "commands", len(commands))
+		h.autoExecuteChat(ctx, chatID, provider, message, commands)
 		return
 	}
+	if h.autorunPaused(chatID) {
+		h.sender.SendPlain(chatID, h.t(chatID, "autorun.paused_command"))
+	}
 
 	turn := &PendingTurn{
 		Commands:  commands,
 		Results:   make([]CommandResult, 0, len(commands)),
 		SessionID: "",
-		Provider:  "gemini",
+		Provider:  provider,
 	}
 	h.approvals.Set(chatID, turn)
 	h.showApproval(chatID, turn)
@@ -724,77 +1281,210 @@ func (h *Handlers) callGemini(ctx context.Context, chatID int64, message string)
 
 // showApproval shows the current pending command with Approve/Deny buttons.
 func (h *Handlers) showApproval(chatID int64, turn *PendingTurn) {
-	cmd := turn.Commands[turn.CurrentIdx]
-	log.Printf("[chat %d] showing approval button %d/%d: %s", chatID, turn.CurrentIdx+1, len(turn.Commands), cmd)
-	label := fmt.Sprintf("Command %d/%d:\n`%s`", turn.CurrentIdx+1, len(turn.Commands), cmd)
-
-	keyboard := tgbotapi.NewInlineKeyboardMarkup(
-		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("Approve", "approve"),
-			tgbotapi.NewInlineKeyboardButtonData("Deny", "deny"),
-		),
-	)
+	// Show variables expanded, as they will run.
+	cmd := h.vars.Expand(chatID, turn.Commands[turn.CurrentIdx])
+	slog.Info("showing approval", "chat_id", chatID, "index", turn.CurrentIdx+1, "total", len(turn.Commands), "command", cmd)
+	label := h.t(chatID, "approval.card", turn.CurrentIdx+1, len(turn.Commands), cmd)
+
+	buttons := []tgbotapi.InlineKeyboardButton{
+		tgbotapi.NewInlineKeyboardButtonData(h.t(chatID, "approval.approve"), "approve"),
+		tgbotapi.NewInlineKeyboardButtonData(h.t(chatID, "approval.deny"), "deny"),
+	}
+	// Risky commands run only once the confirmation phrase is typed.
+	turn.Confirm = ""
+	if reason := h.commandWarning(cmd); reason != "" {
+		turn.Confirm = confirmPhrase(cmd)
+		label += h.t(chatID, "approval.risky", reason, turn.Confirm)
+		buttons = buttons[1:]
+		slog.Info("approval needs typed confirmation", "chat_id", chatID, "reason", reason)
+	}
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(buttons...))
+
+	// Send the card to the session owner and to every chat it is shared with.
+	// Read-only guests get the card without buttons.
+	turn.Cards = map[int64]int{chatID: h.sender.SendWithKeyboard(chatID, label, keyboard)}
+	noButtons := tgbotapi.InlineKeyboardMarkup{InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{}}
+	for _, guest := range h.shares.Guests(chatID) {
+		mode, _ := h.shares.Mode(chatID, guest)
+		if mode == ShareCollab {
+			turn.Cards[guest] = h.sender.SendWithKeyboard(guest, label, keyboard)
+		} else {
+			turn.Cards[guest] = h.sender.SendWithKeyboard(guest, label, noButtons)
+		}
+	}
+	turn.ShownAt = time.Now()
+	turn.Escalated = false
+	turn.Running = false
+	h.approvals.Save(chatID)
+	h.scheduleEscalation(chatID, turn)
+}
 
-	h.sender.SendWithKeyboard(chatID, label, keyboard)
+// resolveApprovalCards replaces every copy of the current approval card
+// (owner and shared chats) with text and removes the buttons. When the
+// session is shared, the text records who made the decision.
+func (h *Handlers) resolveApprovalCards(chatID int64, turn *PendingTurn, messageID int, text, from string) {
+	if len(turn.Cards) > 1 && from != "" {
+		text = h.t(chatID, "approval.by", text, from)
+	}
+	if len(turn.Cards) == 0 {
+		h.sender.EditRemoveKeyboard(chatID, messageID, text)
+		return
+	}
+	for id, msgID := range turn.Cards {
+		if msgID != 0 {
+			h.sender.EditRemoveKeyboard(id, msgID, text)
+		}
+	}
 }
 
 // HandleCallback processes Approve/Deny button presses and gmodel selections.
-func (h *Handlers) HandleCallback(ctx context.Context, chatID int64, callbackID string, data string, messageID int) {
+// from is the display name of the user who pressed the button.
+func (h *Handlers) HandleCallback(ctx context.Context, chatID int64, callbackID string, data string, messageID int, from string) {
+	// A collaborating guest's decision applies to the owner's session.
+	if data == "approve" || data == "deny" {
+		if owner, ok := h.shares.CollabOwner(chatID); ok {
+			slog.Info("callback from collaborating chat", "chat_id", owner, "guest_chat_id", chatID)
+			chatID = owner
+		}
+	}
+
+	// A decision on an escalated card applies to the chat it came from.
+	if strings.HasPrefix(data, slaCallbackPrefix) {
+		decision, owner, ok := parseSLACallback(data)
+		if !ok || !h.isEscalationTarget(chatID) {
+			h.sender.AnswerCallback(callbackID, h.t(chatID, "approval.not_escalation"))
+			return
+		}
+		slog.Info("callback from escalation chat", "chat_id", owner, "escalation_chat_id", chatID)
+		chatID, data = owner, decision
+	}
+
+	// Stop must not wait for the chat lock: the streaming turn holds it.
+	if data == "stop" {
+		h.handleStopCallback(chatID, callbackID)
+		return
+	}
+
 	unlock := h.locks.Lock(chatID)
 	defer unlock()
 
-	// Handle Gemini model selection.
+	// Handle Claude model selection. The session is kept: --resume works
+	// across models.
+	if strings.HasPrefix(data, "cmodel:") {
+		modelID := strings.TrimPrefix(data, "cmodel:")
+		h.claude.SetModel(chatID, modelID)
+		slog.Info("model switched", "chat_id", chatID, "provider", "claude", "model", modelID)
+		h.sender.AnswerCallback(callbackID, h.t(chatID, "model.switched"))
+		h.sender.EditRemoveKeyboard(chatID, messageID, h.t(chatID, "cmodel.done", h.claudeModelName(chatID, h.claude.Model(chatID))))
+		return
+	}
+
+	// Buttons of /gmodel keyboards sent before it became /models gemini.
 	if strings.HasPrefix(data, "gmodel:") {
-		modelID := strings.TrimPrefix(data, "gmodel:")
-		h.gemini.SetModel(modelID)
-		// Reset session so next message uses the new model fresh.
-		h.geminiSessions.Delete(chatID)
-		log.Printf("[chat %d] gemini model switched to %s", chatID, modelID)
-		h.sender.AnswerCallback(callbackID, "Model switched!")
-		h.sender.EditRemoveKeyboard(chatID, messageID, fmt.Sprintf("✅ Switched to `%s`\nSession reset — next message starts fresh.", modelID))
+		data = "models:s:gemini:" + strings.TrimPrefix(data, "gmodel:")
+	}
+	if strings.HasPrefix(data, "models:") {
+		h.handleModelsCallback(ctx, chatID, callbackID, data, messageID)
+		return
+	}
+
+	if strings.HasPrefix(data, "omodel:") {
+		h.handleOpenRouterModelCallback(ctx, chatID, callbackID, data, messageID)
+		return
+	}
+
+	if strings.HasPrefix(data, "unfreeze:") {
+		h.handleUnfreezeCallback(chatID, callbackID, data, messageID)
+		return
+	}
+
+	if strings.HasPrefix(data, "sgx:") {
+		h.handleExceptionCallback(chatID, callbackID, data, messageID, from)
+		return
+	}
+
+	if _, frozen := h.frozen.Get(chatID); frozen {
+		h.sender.AnswerCallback(callbackID, h.t(chatID, "frozen.callback"))
+		return
+	}
+
+	if strings.HasPrefix(data, "ask:") {
+		h.handleAskCallback(ctx, chatID, callbackID, data, messageID)
+		return
+	}
+
+	if strings.HasPrefix(data, "make:") {
+		h.handleMakeCallback(chatID, callbackID, data, messageID)
 		return
 	}
 
 	turn := h.approvals.Get(chatID)
 	if turn == nil {
-		log.Printf("[chat %d] callback with no pending turn, ignoring", chatID)
-		h.sender.AnswerCallback(callbackID, "No pending command.")
+		slog.Info("callback with no pending turn, ignoring", "chat_id", chatID)
+		h.sender.AnswerCallback(callbackID, h.t(chatID, "approval.none"))
 		return
 	}
 
-	cmd := turn.Commands[turn.CurrentIdx]
 	approved := data == "approve"
-	log.Printf("[chat %d] callback: command '%s' -> %s", chatID, cmd, data)
+	if approved && turn.Confirm != "" {
+		h.sender.AnswerCallback(callbackID, h.t(chatID, "approval.type_phrase", turn.Confirm))
+		return
+	}
+	h.decideApproval(ctx, chatID, turn, approved, callbackID, messageID, from)
+}
+
+// decideApproval runs or skips the turn's current command, then shows the
+// next one or sends the results back to the AI. The chat lock must be held.
+// callbackID is empty when the decision was typed rather than tapped.
+func (h *Handlers) decideApproval(ctx context.Context, chatID int64, turn *PendingTurn, approved bool, callbackID string, messageID int, from string) {
+	cmd := turn.Commands[turn.CurrentIdx]
+	slog.Info("approval decision", "chat_id", chatID, "command", cmd, "approved", approved)
+	h.approvalStats.RecordDecision(time.Since(turn.ShownAt), turn.Escalated)
 
 	if approved {
-		h.sender.AnswerCallback(callbackID, "Approved")
-		h.sender.EditRemoveKeyboard(chatID, messageID, fmt.Sprintf("Approved: %s", cmd))
+		h.sender.AnswerCallback(callbackID, h.t(chatID, "approval.approved"))
+		h.resolveApprovalCards(chatID, turn, messageID, h.t(chatID, "approval.ran", cmd), from)
 
-		log.Printf("[chat %d] executing approved command: %s", chatID, cmd)
+		slog.Info("executing approved command", "chat_id", chatID, "provider", turn.Provider, "command", cmd)
+		h.events.Emit(Event{Type: EventCommandApproved, ChatID: chatID, Provider: turn.Provider, Command: cmd, Actor: from})
 		h.sender.SendTyping(chatID)
 
+		// Recorded so that a restart mid-command says it may have run.
+		turn.Running = true
+		h.approvals.Save(chatID)
+
 		var output string
 		var err error
-		if turn.Provider == "gemini" {
-			output, err = h.gemini.ExecuteCommand(ctx, cmd)
-		} else {
-			output, err = h.claude.ExecuteCommand(ctx, cmd)
-		}
+		done := h.checkpoint(chatID, cmd)
+		output, err = h.execute(ctx, chatID, turn.Provider, cmd)
+		diff := done()
 		if err != nil {
-			log.Printf("[chat %d] command error: %v", chatID, err)
+			slog.Warn("command failed", "chat_id", chatID, "err", err)
 			output = fmt.Sprintf("%s\nError: %v", output, err)
 		}
 		if output == "" {
 			output = "(no output)"
 		}
-		log.Printf("[chat %d] command output: %d bytes", chatID, len(output))
+		output = h.diffAgainstLastRun(chatID, cmd, output)
+		slog.Debug("command output", "chat_id", chatID, "bytes", len(output))
 
 		// Show command output to user.
 		display := output
-		if len(display) > 2000 {
-			display = display[:2000] + "\n... (truncated in chat)"
+		if limit := h.outputLimitFor(chatID, defaultOutputLimit); len(display) > limit {
+			display = display[:limit] + h.t(chatID, "output.truncated")
+		}
+		if diff != "" {
+			display += "\n\n" + diff
+		}
+		h.sendReply(ctx, chatID, display)
+		if errors.Is(err, ErrCommandBlocked) {
+			approver := from
+			if approver == "" {
+				approver = "(unknown)"
+			}
+			h.reportBlocked(BlockedAttempt{ChatID: chatID, Provider: turn.Provider, SessionID: turn.SessionID, Command: cmd, Reason: err.Error(), Approver: approver})
+			h.offerException(chatID, cmd)
 		}
-		h.sender.Send(chatID, display)
 
 		turn.Results = append(turn.Results, CommandResult{
 			Command:  cmd,
@@ -802,9 +1492,10 @@ func (h *Handlers) HandleCallback(ctx context.Context, chatID int64, callbackID
 			Output:   output,
 		})
 	} else {
-		log.Printf("[chat %d] command denied: %s", chatID, cmd)
-		h.sender.AnswerCallback(callbackID, "Denied")
-		h.sender.EditRemoveKeyboard(chatID, messageID, fmt.Sprintf("Denied: %s", cmd))
+		slog.Info("command denied", "chat_id", chatID, "provider", turn.Provider, "command", cmd)
+		h.events.Emit(Event{Type: EventCommandDenied, ChatID: chatID, Provider: turn.Provider, Command: cmd, Actor: from})
+		h.sender.AnswerCallback(callbackID, h.t(chatID, "approval.denied"))
+		h.resolveApprovalCards(chatID, turn, messageID, h.t(chatID, "approval.skipped", cmd), from)
 
 		turn.Results = append(turn.Results, CommandResult{
 			Command:  cmd,
@@ -816,49 +1507,122 @@ func (h *Handlers) HandleCallback(ctx context.Context, chatID int64, callbackID
 
 	// More commands in this turn — show next.
 	if turn.CurrentIdx < len(turn.Commands) {
-		log.Printf("[chat %d] more commands pending (%d/%d)", chatID, turn.CurrentIdx+1, len(turn.Commands))
+		slog.Debug("more commands pending", "chat_id", chatID, "index", turn.CurrentIdx+1, "total", len(turn.Commands))
 		h.showApproval(chatID, turn)
 		return
 	}
 
-	// All commands processed. Send results back to the AI.
-	log.Printf("[chat %d] all %d commands processed, sending results back to AI", chatID, len(turn.Results))
 	h.approvals.Delete(chatID)
-	resultsMsg := FormatCommandResults(turn.Results)
+	if turn.Manual {
+		return
+	}
+
+	// All commands processed. Send results back to the AI.
+	slog.Info("all commands processed, sending results back", "chat_id", chatID, "provider", turn.Provider, "results", len(turn.Results))
+	resultsMsg := FormatCommandResults(h.compactResults(ctx, chatID, turn.Results))
 
 	h.sender.SendTyping(chatID)
-	if turn.Provider == "gemini" {
-		h.callGemini(ctx, chatID, resultsMsg)
+	if isChatProvider(turn.Provider) {
+		h.callChat(ctx, chatID, turn.Provider, resultsMsg)
 	} else {
 		h.callClaude(ctx, chatID, resultsMsg)
 	}
 }
 
-// autoExecuteClaude runs all commands without approval (SKIP_PERMISSIONS mode, Claude)
+// execute runs one approved command or plugin call in the chat's project.
+func (h *Handlers) execute(ctx context.Context, chatID int64, provider, cmd string) (output string, err error) {
+	cmd = h.vars.Expand(chatID, cmd)
+	ctx, span := tracer.Start(ctx, "command.exec", trace.WithAttributes(
+		attribute.Int64("chat.id", chatID),
+		attribute.String("provider", provider),
+		attribute.String("command", truncateText(cmd, 200)),
+	))
+	defer func() {
+		endSpan(span, err)
+		h.record(TranscriptEntry{ChatID: chatID, Kind: TranscriptCommand, Provider: provider, Command: cmd, Output: output, Error: errorText(err)})
+	}()
+
+	if h.readOnly.Enabled(chatID) {
+		return "", ErrReadOnly
+	}
+	if p, input, ok := h.plugins.Match(cmd); ok {
+		span.SetAttributes(attribute.String("plugin", p.Name))
+		return h.plugins.Run(ctx, p, chatID, h.projectDir(chatID), input)
+	}
+	if output, err = h.runTestGate(ctx, chatID, cmd); err != nil {
+		return output, err
+	}
+	dir := h.projectDir(chatID)
+	cwd := dir
+	if isChatProvider(provider) {
+		cwd = h.gemini.getCwd(chatID, dir) // where the chat's last cd left it
+	}
+	if err := h.workspaces.CheckPaths(chatID, cwd, cmd); err != nil {
+		slog.Warn("exec refused", "chat_id", chatID, "provider", provider, "err", err)
+		return "", err
+	}
+	if err := h.workspaces.CheckQuota(chatID, cmd); err != nil {
+		return "", err
+	}
+	defer h.workspaces.Changed(chatID)
+	if isChatProvider(provider) {
+		// Stateless providers share the bot's shell with per-chat cwd tracking.
+		output, err = h.gemini.ExecuteCommand(ctx, chatID, dir, cmd)
+	} else {
+		output, err = h.claude.ExecuteCommand(ctx, dir, cmd)
+	}
+	blocked := errors.Is(err, ErrCommandBlocked)
+	h.recordSafeguardVerdict(chatID, provider, cmd, blocked)
+	if blocked {
+		h.events.Emit(Event{Type: EventCommandBlocked, ChatID: chatID, Provider: provider, Command: cmd, Reason: err.Error()})
+	}
+	return output, err
+}
+
+// autoExecuteClaude runs all commands without approval (auto-execute, Claude)
 // and feeds results back to Claude, looping up to maxRounds.
-func (h *Handlers) autoExecuteClaude(ctx context.Context, chatID int64, commands []string, sessionID string) {
-	for round := 0; round < h.maxRounds; round++ {
-		log.Printf("[chat %d] auto-execute claude round %d: %d commands", chatID, round+1, len(commands))
+// prompt is the user message that started the turn, used for safeguard alerts.
+func (h *Handlers) autoExecuteClaude(ctx context.Context, chatID int64, prompt string, commands []string, sessionID string) {
+	for round := 0; round < h.maxRoundsFor(chatID); round++ {
+		slog.Info("auto-execute round", "chat_id", chatID, "provider", "claude", "round", round+1, "commands", len(commands))
 		var results []CommandResult
 		for i, cmd := range commands {
-			log.Printf("[chat %d] auto-executing command %d/%d: %s", chatID, i+1, len(commands), cmd)
-			h.sender.SendPlain(chatID, fmt.Sprintf("Running: %s", cmd))
-
-			output, err := h.claude.ExecuteCommand(ctx, cmd)
+			if h.frozen.IsFrozen(chatID) {
+				slog.Warn("chat frozen, aborting auto-execute", "chat_id", chatID)
+				return
+			}
+			slog.Info("auto-executing command", "chat_id", chatID, "provider", "claude", "index", i+1, "total", len(commands), "command", cmd)
+			h.sender.SendPlain(chatID, h.t(chatID, "autorun.running", cmd))
+
+			done := h.checkpoint(chatID, cmd)
+			output, err := h.execute(ctx, chatID, "claude", cmd)
+			diff := done()
+			if errors.Is(err, ErrCommandBlocked) {
+				attempt := BlockedAttempt{ChatID: chatID, Provider: "claude", SessionID: sessionID, Prompt: prompt, Command: cmd, Reason: err.Error()}
+				if h.reportBlocked(attempt) {
+					h.sender.SendPlain(chatID, h.t(chatID, "safeguard.frozen"))
+					return
+				}
+				h.offerException(chatID, cmd)
+			}
 			if err != nil {
-				log.Printf("[chat %d] command error: %v", chatID, err)
+				slog.Warn("command failed", "chat_id", chatID, "err", err)
 				output = fmt.Sprintf("%s\nError: %v", output, err)
 			}
 			if output == "" {
 				output = "(no output)"
 			}
-			log.Printf("[chat %d] command output: %d bytes", chatID, len(output))
+			output = h.diffAgainstLastRun(chatID, cmd, output)
+			slog.Debug("command output", "chat_id", chatID, "bytes", len(output))
 
 			display := output
-			if len(display) > 1000 {
-				display = display[:1000] + "\n... (truncated)"
+			if limit := h.outputLimitFor(chatID, defaultAutoOutputLimit); len(display) > limit {
+				display = display[:limit] + "\n... (truncated)"
 			}
-			h.sender.Send(chatID, display)
+			if diff != "" {
+				display += "\n\n" + diff
+			}
+			h.sendReply(ctx, chatID, display)
 
 			results = append(results, CommandResult{
 				Command:  cmd,
@@ -868,22 +1632,22 @@ func (h *Handlers) autoExecuteClaude(ctx context.Context, chatID int64, commands
 		}
 
 		// Send results back to Claude.
-		log.Printf("[chat %d] sending %d results back to Claude", chatID, len(results))
-		resultsMsg := FormatCommandResults(results)
+		slog.Debug("sending results back", "chat_id", chatID, "provider", "claude", "results", len(results))
+		resultsMsg := FormatCommandResults(h.compactResults(ctx, chatID, results))
 		h.sender.SendTyping(chatID)
 
-		claudeCtx, cancel := context.WithTimeout(ctx, h.timeout)
+		claudeCtx, cancel := context.WithTimeout(ctx, h.timeoutFor(chatID))
 		sid := h.sessions.Get(chatID)
-		resp, err := h.claude.Send(claudeCtx, chatID, sid, resultsMsg)
+		resp, err := h.sendClaude(claudeCtx, chatID, sid, resultsMsg, nil, nil)
 		cancel()
 
 		if err != nil {
-			log.Printf("[chat %d] claude error: %v", chatID, err)
-			h.sender.SendPlain(chatID, fmt.Sprintf("Error: %v", err))
+			slog.Error("provider call failed", "chat_id", chatID, "provider", "claude", "err", err)
+			h.sender.SendPlain(chatID, h.t(chatID, "error", err))
 			return
 		}
 
-		h.usage.Record(chatID, resp)
+		h.recordUsage(ctx, chatID, resp)
 
 		if resp.SessionID != "" {
 			h.sessions.Set(chatID, resp.SessionID)
@@ -891,18 +1655,19 @@ func (h *Handlers) autoExecuteClaude(ctx context.Context, chatID int64, commands
 
 		result := resp.Result
 		if result == "" {
-			log.Printf("[chat %d] empty response, auto-execute done", chatID)
+			slog.Info("empty response, auto-execute done", "chat_id", chatID, "provider", "claude")
 			return
 		}
 
-		cleanText, newCommands := ParseCommands(result)
-		log.Printf("[chat %d] auto-execute: %d new commands from Claude", chatID, len(newCommands))
+		cleanText, newCommands := h.parseCalls(result)
+		slog.Info("auto-execute: new commands", "chat_id", chatID, "provider", "claude", "commands", len(newCommands))
 		if cleanText != "" {
-			h.sender.Send(chatID, cleanText)
+			h.sendReply(ctx, chatID, cleanText)
 		}
 
 		if len(newCommands) == 0 {
-			log.Printf("[chat %d] no more commands, auto-execute done", chatID)
+			slog.Info("no more commands, auto-execute done", "chat_id", chatID, "provider", "claude")
+			h.events.Emit(Event{Type: EventTaskCompleted, ChatID: chatID, Provider: "claude"})
 			return
 		}
 
@@ -910,35 +1675,54 @@ func (h *Handlers) autoExecuteClaude(ctx context.Context, chatID int64, commands
 		sessionID = resp.SessionID
 	}
 
-	log.Printf("[chat %d] hit max tool rounds (%d), stopping", chatID, h.maxRounds)
-	h.sender.SendPlain(chatID, "Stopped: too many command rounds.")
+	h.reportMaxRounds(chatID, "claude", prompt, commands)
 }
 
-// autoExecuteGemini runs all commands without approval (SKIP_PERMISSIONS mode, Gemini)
-// and feeds results back to Gemini, looping up to maxRounds.
-func (h *Handlers) autoExecuteGemini(ctx context.Context, chatID int64, commands []string) {
-	for round := 0; round < h.maxRounds; round++ {
-		log.Printf("[chat %d] auto-execute gemini round %d: %d commands", chatID, round+1, len(commands))
+// autoExecuteChat runs all commands without approval (auto-execute,
+// stateless chat providers) and feeds results back to the model, looping up to
+// maxRounds.
+// prompt is the user message that started the turn, used for safeguard alerts.
+func (h *Handlers) autoExecuteChat(ctx context.Context, chatID int64, provider, prompt string, commands []string) {
+	for round := 0; round < h.maxRoundsFor(chatID); round++ {
+		slog.Info("auto-execute round", "chat_id", chatID, "provider", provider, "round", round+1, "commands", len(commands))
 		var results []CommandResult
 		for i, cmd := range commands {
-			log.Printf("[chat %d] auto-executing gemini command %d/%d: %s", chatID, i+1, len(commands), cmd)
-			h.sender.SendPlain(chatID, fmt.Sprintf("Running: %s", cmd))
-
-			output, err := h.gemini.ExecuteCommand(ctx, cmd)
+			if h.frozen.IsFrozen(chatID) {
+				slog.Warn("chat frozen, aborting auto-execute", "chat_id", chatID)
+				return
+			}
+			slog.Info("auto-executing command", "chat_id", chatID, "provider", provider, "index", i+1, "total", len(commands), "command", cmd)
+			h.sender.SendPlain(chatID, h.t(chatID, "autorun.running", cmd))
+
+			done := h.checkpoint(chatID, cmd)
+			output, err := h.execute(ctx, chatID, provider, cmd)
+			diff := done()
+			if errors.Is(err, ErrCommandBlocked) {
+				attempt := BlockedAttempt{ChatID: chatID, Provider: provider, Prompt: prompt, Command: cmd, Reason: err.Error()}
+				if h.reportBlocked(attempt) {
+					h.sender.SendPlain(chatID, h.t(chatID, "safeguard.frozen"))
+					return
+				}
+				h.offerException(chatID, cmd)
+			}
 			if err != nil {
-				log.Printf("[chat %d] command error: %v", chatID, err)
+				slog.Warn("command failed", "chat_id", chatID, "err", err)
 				output = fmt.Sprintf("%s\nError: %v", output, err)
 			}
 			if output == "" {
 				output = "(no output)"
 			}
-			log.Printf("[chat %d] command output: %d bytes", chatID, len(output))
+			output = h.diffAgainstLastRun(chatID, cmd, output)
+			slog.Debug("command output", "chat_id", chatID, "bytes", len(output))
 
 			display := output
-			if len(display) > 1000 {
-				display = display[:1000] + "\n... (truncated)"
+			if limit := h.outputLimitFor(chatID, defaultAutoOutputLimit); len(display) > limit {
+				display = display[:limit] + "\n... (truncated)"
+			}
+			if diff != "" {
+				display += "\n\n" + diff
 			}
-			h.sender.Send(chatID, display)
+			h.sendReply(ctx, chatID, display)
 
 			results = append(results, CommandResult{
 				Command:  cmd,
@@ -947,19 +1731,19 @@ func (h *Handlers) autoExecuteGemini(ctx context.Context, chatID int64, commands
 			})
 		}
 
-		// Send results back to Gemini.
-		log.Printf("[chat %d] sending %d results back to Gemini", chatID, len(results))
-		resultsMsg := FormatCommandResults(results)
+		// Send results back to the model.
+		slog.Debug("sending results back", "chat_id", chatID, "provider", provider, "results", len(results))
+		resultsMsg := FormatCommandResults(h.compactResults(ctx, chatID, results))
 		h.sender.SendTyping(chatID)
 
-		geminiCtx, cancel := context.WithTimeout(ctx, h.timeout)
+		geminiCtx, cancel := context.WithTimeout(ctx, h.timeoutFor(chatID))
 		history := h.geminiSessions.Get(chatID)
-		result, err := h.gemini.Send(geminiCtx, history, resultsMsg)
+		result, err := h.sendChat(geminiCtx, chatID, provider, history, resultsMsg)
 		cancel()
 
 		if err != nil {
-			log.Printf("[chat %d] gemini error: %v", chatID, err)
-			h.sender.SendPlain(chatID, fmt.Sprintf("Error from Gemini: %v", err))
+			slog.Error("provider call failed", "chat_id", chatID, "provider", provider, "err", err)
+			h.sender.SendPlain(chatID, providerErrorText(provider, err))
 			return
 		}
 
@@ -969,20 +1753,20 @@ func (h *Handlers) autoExecuteGemini(ctx context.Context, chatID int64, commands
 			GeminiMessage{Role: "model", Content: result},
 		)
 
-		cleanText, newCommands := ParseCommands(result)
-		log.Printf("[chat %d] auto-execute gemini: %d new commands", chatID, len(newCommands))
+		cleanText, newCommands := h.parseCalls(result)
+		slog.Info("auto-execute: new commands", "chat_id", chatID, "provider", provider, "commands", len(newCommands))
 		if cleanText != "" {
-			h.sender.Send(chatID, cleanText)
+			h.sendReply(ctx, chatID, cleanText)
 		}
 
 		if len(newCommands) == 0 {
-			log.Printf("[chat %d] no more gemini commands, auto-execute done", chatID)
+			slog.Info("no more commands, auto-execute done", "chat_id", chatID, "provider", provider)
+			h.events.Emit(Event{Type: EventTaskCompleted, ChatID: chatID, Provider: provider})
 			return
 		}
 
 		commands = newCommands
 	}
 
-	log.Printf("[chat %d] hit max tool rounds (%d), stopping", chatID, h.maxRounds)
-	h.sender.SendPlain(chatID, "Stopped: too many command rounds.")
+	h.reportMaxRounds(chatID, provider, prompt, commands)
 }
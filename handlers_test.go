@@ -1,12 +1,17 @@
 package main
 
 import (
+	"context"
+	"strings"
 	"sync"
 	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
 func TestApprovalStore(t *testing.T) {
-	s := NewApprovalStore()
+	s := NewApprovalStore(t.TempDir())
 	chatID := int64(123)
 
 	if s.Has(chatID) {
@@ -79,3 +84,58 @@ func TestChatLocks(t *testing.T) {
 		t.Errorf("Expected count %d, got %d", iterations, count)
 	}
 }
+
+func TestLockFresh(t *testing.T) {
+	tg := &fakeTelegram{}
+	api, err := tgbotapi.NewBotAPIWithClient("token", tgbotapi.APIEndpoint, tg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := &Handlers{sender: NewSender(api, nil), locks: NewChatLocks(), promptExpiry: time.Minute}
+	ctx := context.Background()
+
+	// holdLock keeps the chat busy for d.
+	holdLock := func(d time.Duration) {
+		unlock := h.locks.Lock(1)
+		go func() {
+			time.Sleep(d)
+			unlock()
+		}()
+	}
+
+	for _, tc := range []struct {
+		name  string
+		ctx   context.Context
+		busy  time.Duration
+		fresh bool
+	}{
+		{"new message, idle chat", withReceived(ctx, time.Now()), 0, true},
+		{"no date, idle chat", ctx, 0, true},
+		{"sent long ago, idle chat", withReceived(ctx, time.Now().Add(-2*time.Minute)), 0, false},
+		{"sent a while ago, then waited for the lock", withReceived(ctx, time.Now().Add(-time.Minute+100*time.Millisecond)), 300 * time.Millisecond, false},
+		{"new message, short wait", withReceived(ctx, time.Now()), 50 * time.Millisecond, true},
+	} {
+		if tc.busy > 0 {
+			holdLock(tc.busy)
+		}
+		unlock, ok := h.lockFresh(tc.ctx, 1, "message", "deploy now")
+		if ok != tc.fresh {
+			t.Errorf("%s: ok = %v", tc.name, ok)
+		}
+		if ok {
+			unlock()
+		}
+	}
+
+	tg.mu.Lock()
+	defer tg.mu.Unlock()
+	var notices []string
+	for _, form := range tg.sent {
+		if text := form.Get("text"); text != "" {
+			notices = append(notices, text)
+		}
+	}
+	if len(notices) != 2 || !strings.Contains(notices[0], "from 2m0s ago") || !strings.Contains(notices[0], "deploy now") {
+		t.Errorf("notices = %q", notices)
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os/exec"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// telegramCheckTTL caches the Telegram reachability result so frequent
+// probes don't turn into a getMe call each.
+const telegramCheckTTL = 30 * time.Second
+
+// healthCheck is one readiness dependency. Only required checks make the
+// bot unready; the rest are reported for visibility.
+type healthCheck struct {
+	name     string
+	required bool
+	check    func() error
+}
+
+// HealthServer exposes /healthz (process is up) and /readyz (dependencies
+// are usable) for Kubernetes/Compose probes.
+type HealthServer struct {
+	addr   string
+	checks []healthCheck
+}
+
+// NewHealthServer checks Telegram, the Claude binary and the chat providers' credentials.
+// Telegram and the DEFAULT_PROVIDER are required; the other providers are informational.
+func NewHealthServer(cfg *Config, api *tgbotapi.BotAPI, gemini *GeminiClient, openrouter *OpenRouterClient, azure *AzureOpenAIClient, bedrock *BedrockClient) *HealthServer {
+	return &HealthServer{
+		addr: cfg.HealthAddr,
+		checks: []healthCheck{
+			{name: "telegram", required: true, check: cachedCheck(telegramCheckTTL, func() error {
+				_, err := api.GetMe()
+				return err
+			})},
+			{name: "claude", required: cfg.DefaultProvider == "claude", check: func() error {
+				_, err := exec.LookPath(cfg.ClaudePath)
+				return err
+			}},
+			{name: "gemini", required: cfg.DefaultProvider == "gemini", check: func() error {
+				if !gemini.HasAPIKey() {
+					return errors.New("no API key set")
+				}
+				return nil
+			}},
+			{name: "openrouter", required: cfg.DefaultProvider == "openrouter", check: func() error {
+				if !openrouter.HasAPIKey() {
+					return errors.New("no API key set")
+				}
+				return nil
+			}},
+			{name: "azure", required: cfg.DefaultProvider == "azure", check: func() error {
+				if !azure.Configured() {
+					return errors.New("endpoint, deployment or API key not set")
+				}
+				return nil
+			}},
+			{name: "bedrock", required: cfg.DefaultProvider == "bedrock", check: func() error {
+				if !bedrock.Configured() {
+					return errors.New("region, model ID or AWS credentials not set")
+				}
+				return nil
+			}},
+		},
+	}
+}
+
+// cachedCheck memoizes a check's result for ttl.
+func cachedCheck(ttl time.Duration, check func() error) func() error {
+	var mu sync.Mutex
+	var last time.Time
+	var lastErr error
+	return func() error {
+		mu.Lock()
+		defer mu.Unlock()
+		if time.Since(last) < ttl {
+			return lastErr
+		}
+		lastErr = check()
+		last = time.Now()
+		return lastErr
+	}
+}
+
+// ListenAndServe blocks serving probe requests on the configured address.
+func (s *HealthServer) ListenAndServe() error {
+	slog.Info("health server listening", "addr", s.addr)
+	return http.ListenAndServe(s.addr, s.mux())
+}
+
+func (s *HealthServer) mux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok\n"))
+	})
+	mux.HandleFunc("/readyz", s.handleReady)
+	return mux
+}
+
+func (s *HealthServer) handleReady(w http.ResponseWriter, r *http.Request) {
+	ready := true
+	results := make(map[string]string, len(s.checks))
+	for _, c := range s.checks {
+		if err := c.check(); err != nil {
+			results[c.name] = err.Error()
+			if c.required {
+				ready = false
+			}
+			continue
+		}
+		results[c.name] = "ok"
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if !ready {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(struct {
+		Ready  bool              `json:"ready"`
+		Checks map[string]string `json:"checks"`
+	}{ready, results})
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestReadyz(t *testing.T) {
+	ok := func() error { return nil }
+	fail := func() error { return errors.New("down") }
+
+	tests := []struct {
+		name       string
+		checks     []healthCheck
+		wantStatus int
+	}{
+		{"all ok", []healthCheck{{"telegram", true, ok}, {"gemini", false, ok}}, http.StatusOK},
+		{"optional failing", []healthCheck{{"telegram", true, ok}, {"gemini", false, fail}}, http.StatusOK},
+		{"required failing", []healthCheck{{"telegram", true, fail}, {"gemini", false, ok}}, http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &HealthServer{checks: tt.checks}
+			rec := httptest.NewRecorder()
+			s.mux().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			var body struct {
+				Ready  bool              `json:"ready"`
+				Checks map[string]string `json:"checks"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("invalid JSON: %v", err)
+			}
+			if body.Ready != (tt.wantStatus == http.StatusOK) || len(body.Checks) != len(tt.checks) {
+				t.Errorf("unexpected body: %+v", body)
+			}
+		})
+	}
+}
+
+func TestHealthz(t *testing.T) {
+	rec := httptest.NewRecorder()
+	(&HealthServer{}).mux().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("status = %d, want 200", rec.Code)
+	}
+}
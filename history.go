@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxHistoryEntries caps the in-memory history kept per chat; older entries
+// are dropped first. Full records live in the transcript files.
+const maxHistoryEntries = 500
+
+// HistoryStore keeps the conversation entries of each chat's current session
+// in memory, for /export and /history. It is cleared whenever the session is.
+type HistoryStore struct {
+	mu      sync.RWMutex
+	entries map[int64][]TranscriptEntry
+}
+
+func NewHistoryStore() *HistoryStore {
+	return &HistoryStore{entries: make(map[int64][]TranscriptEntry)}
+}
+
+// Add appends an entry, dropping the oldest once the cap is reached.
+func (s *HistoryStore) Add(e TranscriptEntry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entries := append(s.entries[e.ChatID], e)
+	if len(entries) > maxHistoryEntries {
+		entries = entries[len(entries)-maxHistoryEntries:]
+	}
+	s.entries[e.ChatID] = entries
+}
+
+// Get returns a copy of the chat's current session entries, oldest first.
+func (s *HistoryStore) Get(chatID int64) []TranscriptEntry {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]TranscriptEntry(nil), s.entries[chatID]...)
+}
+
+func (s *HistoryStore) Reset(chatID int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, chatID)
+}
+
+// record adds a conversation entry to the session history and the transcript log.
+func (h *Handlers) record(e TranscriptEntry) {
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+	h.history.Add(e)
+	h.transcripts.Record(e)
+}
+
+// defaultHistoryTurns is how many exchanges /history shows without an argument.
+const defaultHistoryTurns = 5
+
+// HandleHistory replays the last n exchanges of the current session: each
+// user message with the AI replies and commands that followed it.
+func (h *Handlers) HandleHistory(chatID int64, args string) {
+	a, ok := h.parseCommandArgs(chatID, "history", args)
+	if !ok {
+		return
+	}
+	n, err := a.Int(0, defaultHistoryTurns)
+	if err == nil && n <= 0 {
+		err = fmt.Errorf("n must be positive")
+	}
+	if err != nil {
+		h.sendArgError(chatID, "history", err)
+		return
+	}
+	entries := lastTurns(h.history.Get(chatID), n)
+	if len(entries) == 0 {
+		h.sender.SendPlain(chatID, h.t(chatID, "history.none"))
+		return
+	}
+	h.sender.SendPlain(chatID, formatHistory(entries))
+}
+
+// lastTurns returns the entries from the nth-last user message onwards.
+func lastTurns(entries []TranscriptEntry, n int) []TranscriptEntry {
+	for i := len(entries) - 1; i >= 0; i-- {
+		if entries[i].Kind == TranscriptUser {
+			if n--; n == 0 {
+				return entries[i:]
+			}
+		}
+	}
+	return entries
+}
+
+// formatHistory renders entries for chat, shortening long texts and outputs.
+func formatHistory(entries []TranscriptEntry) string {
+	var b strings.Builder
+	for _, e := range entries {
+		ts := e.Time.Local().Format("15:04")
+		switch e.Kind {
+		case TranscriptUser:
+			who := "You"
+			if e.Actor != "" {
+				who = e.Actor
+			}
+			fmt.Fprintf(&b, "\n[%s] %s: %s\n", ts, who, truncateText(e.Text, 500))
+		case TranscriptAssistant:
+			fmt.Fprintf(&b, "[%s] %s: %s\n", ts, providerLabel(e.Provider), truncateText(e.Text, 800))
+		case TranscriptCommand:
+			fmt.Fprintf(&b, "[%s] $ %s\n", ts, e.Command)
+			if out := strings.TrimSpace(e.Output); out != "" {
+				fmt.Fprintf(&b, "%s\n", truncateText(out, 300))
+			}
+			if e.Error != "" {
+				fmt.Fprintf(&b, "Error: %s\n", e.Error)
+			}
+		}
+	}
+	return strings.TrimSpace(b.String())
+}
@@ -0,0 +1,33 @@
+package main
+
+import "testing"
+
+func TestHistoryStoreCap(t *testing.T) {
+	s := NewHistoryStore()
+	for i := 0; i < maxHistoryEntries+10; i++ {
+		s.Add(TranscriptEntry{ChatID: 1, Kind: TranscriptUser})
+	}
+	if got := len(s.Get(1)); got != maxHistoryEntries {
+		t.Errorf("got %d entries, want %d", got, maxHistoryEntries)
+	}
+	s.Reset(1)
+	if got := len(s.Get(1)); got != 0 {
+		t.Errorf("got %d entries after reset, want 0", got)
+	}
+}
+
+func TestLastTurns(t *testing.T) {
+	entries := []TranscriptEntry{
+		{Kind: TranscriptUser, Text: "one"},
+		{Kind: TranscriptAssistant, Text: "r1"},
+		{Kind: TranscriptUser, Text: "two"},
+		{Kind: TranscriptCommand, Command: "ls"},
+		{Kind: TranscriptAssistant, Text: "r2"},
+	}
+	if got := lastTurns(entries, 1); len(got) != 3 || got[0].Text != "two" {
+		t.Errorf("lastTurns(1) = %+v", got)
+	}
+	if got := lastTurns(entries, 10); len(got) != len(entries) {
+		t.Errorf("lastTurns(10) returned %d entries, want all %d", len(got), len(entries))
+	}
+}
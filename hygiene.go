@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// The hygiene report lists what an admin should rotate or review on a bot
+// holding powerful credentials: how old the stored keys are, which chats
+// run commands without approval, and which safeguard exceptions were added
+// lately. /hygiene sends it on demand; HYGIENE_REPORT_SCHEDULE posts it to
+// the admin chat.
+
+const (
+	// hygieneStaleAge flags credentials that are due for rotation.
+	hygieneStaleAge = 90 * 24 * time.Hour
+	// hygieneExceptionWindow is how far back exceptions count as recent.
+	hygieneExceptionWindow = 7 * 24 * time.Hour
+)
+
+// credentialInfo is one stored credential in the report. A zero Saved
+// means the credential is not on disk.
+type credentialInfo struct {
+	Name   string
+	Saved  time.Time
+	Detail string // e.g. the SSH key fingerprint
+}
+
+// hygieneFacts is what the report is built from.
+type hygieneFacts struct {
+	Credentials    []credentialInfo
+	AutorunDefault bool           // SKIP_PERMISSIONS
+	Autorun        map[int64]bool // chats' own /autorun settings
+	Exceptions     []SafeguardException
+}
+
+// collectHygiene gathers the report's facts from the home directory and
+// the bot's stores.
+func (h *Handlers) collectHygiene() hygieneFacts {
+	home, _ := os.UserHomeDir()
+	f := hygieneFacts{Credentials: []credentialInfo{
+		{Name: "Gemini API key", Saved: modTime(filepath.Join(home, geminiAPIKeyFile))},
+		{Name: "Claude login", Saved: modTime(filepath.Join(home, ".claude", ".credentials.json"))},
+	}}
+	sshKey := filepath.Join(home, ".ssh", "id_ed25519")
+	f.Credentials = append(f.Credentials, credentialInfo{
+		Name:   "SSH key",
+		Saved:  modTime(sshKey),
+		Detail: sshFingerprint(sshKey),
+	})
+	if h.claude != nil && h.claude.autorun != nil {
+		f.Autorun, f.AutorunDefault = h.claude.autorun.Overrides()
+	}
+	if h.exceptions != nil {
+		f.Exceptions = h.exceptions.List()
+	}
+	return f
+}
+
+// modTime returns when path was last written, or the zero time if it does
+// not exist.
+func modTime(path string) time.Time {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return fi.ModTime()
+}
+
+// sshFingerprint returns the SHA256 fingerprint of a key file as printed
+// by ssh-keygen, or "" when there is no key or ssh-keygen is missing.
+func sshFingerprint(path string) string {
+	if _, err := os.Stat(path); err != nil {
+		return ""
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, "ssh-keygen", "-l", "-E", "sha256", "-f", path).Output()
+	if err != nil {
+		return ""
+	}
+	// "256 SHA256:abc… comment (ED25519)"
+	fields := strings.Fields(string(out))
+	if len(fields) < 2 {
+		return ""
+	}
+	return fields[1]
+}
+
+// formatHygieneReport renders the facts as of now.
+func formatHygieneReport(now time.Time, f hygieneFacts) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "🔐 Credential hygiene report %s\n\nCredentials:\n", now.Format("2006-01-02 15:04"))
+	for _, c := range f.Credentials {
+		if c.Saved.IsZero() {
+			fmt.Fprintf(&b, "  %s: not stored\n", c.Name)
+			continue
+		}
+		age := now.Sub(c.Saved)
+		mark := ""
+		if age > hygieneStaleAge {
+			mark = " ⚠️ consider rotating"
+		}
+		fmt.Fprintf(&b, "  %s: saved %s (%d days ago)%s\n", c.Name, c.Saved.Format(time.DateOnly), int(age.Hours()/24), mark)
+		if c.Detail != "" {
+			fmt.Fprintf(&b, "    %s\n", c.Detail)
+		}
+	}
+
+	b.WriteString("\nAuto-execute:\n")
+	var on, off []int64
+	for id, v := range f.Autorun {
+		if v && !f.AutorunDefault {
+			on = append(on, id)
+		} else if !v && f.AutorunDefault {
+			off = append(off, id)
+		}
+	}
+	slices.Sort(on)
+	slices.Sort(off)
+	if f.AutorunDefault {
+		b.WriteString("  ⚠️ SKIP_PERMISSIONS is on: every chat runs commands without approval")
+		if len(off) > 0 {
+			fmt.Fprintf(&b, " except %s", joinIDs(off))
+		}
+		b.WriteString("\n")
+	} else if len(on) > 0 {
+		fmt.Fprintf(&b, "  Chats with /autorun on: %s\n", joinIDs(on))
+	} else {
+		b.WriteString("  No chat runs commands without approval\n")
+	}
+
+	since := now.Add(-hygieneExceptionWindow)
+	var recent []SafeguardException
+	for _, x := range f.Exceptions {
+		if x.Added.After(since) {
+			recent = append(recent, x)
+		}
+	}
+	fmt.Fprintf(&b, "\nSafeguard exceptions added in the last %d days: %d of %d\n", int(hygieneExceptionWindow.Hours()/24), len(recent), len(f.Exceptions))
+	for _, x := range recent {
+		fmt.Fprintf(&b, "  %s [%s] for chat %d by %s: %s\n", x.Added.Format(time.DateOnly), x.Rule, x.ChatID, x.AddedBy, x.Command)
+	}
+	return strings.TrimSpace(b.String())
+}
+
+func joinIDs(ids []int64) string {
+	s := make([]string, len(ids))
+	for i, id := range ids {
+		s[i] = fmt.Sprint(id)
+	}
+	return strings.Join(s, ", ")
+}
+
+// HandleHygiene sends the credential hygiene report.
+func (h *Handlers) HandleHygiene(chatID int64) {
+	h.sender.SendPlain(chatID, formatHygieneReport(time.Now(), h.collectHygiene()))
+}
+
+// runHygieneReports posts the hygiene report to the admin chat whenever
+// the schedule matches, checking once per minute. It never returns.
+func (h *Handlers) runHygieneReports() {
+	for {
+		now := time.Now()
+		next := now.Truncate(time.Minute).Add(time.Minute)
+		time.Sleep(next.Sub(now))
+		if !h.hygieneReport.Matches(next) {
+			continue
+		}
+		slog.Info("posting hygiene report", "chat_id", h.adminChatID)
+		h.sender.SendPlain(h.adminChatID, formatHygieneReport(next, h.collectHygiene()))
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormatHygieneReport(t *testing.T) {
+	now := time.Date(2026, 5, 4, 9, 0, 0, 0, time.UTC)
+	f := hygieneFacts{
+		Credentials: []credentialInfo{
+			{Name: "Gemini API key", Saved: now.Add(-120 * 24 * time.Hour)},
+			{Name: "Claude login", Saved: now.Add(-3 * 24 * time.Hour)},
+			{Name: "SSH key", Saved: now.Add(-10 * 24 * time.Hour), Detail: "SHA256:abc"},
+		},
+		Autorun: map[int64]bool{42: true, 7: true, 9: false},
+		Exceptions: []SafeguardException{
+			{Rule: "rm-root", Command: "rm -rf /tmp/x", ChatID: 42, AddedBy: "alice", Added: now.Add(-24 * time.Hour)},
+			{Rule: "curl-pipe", Command: "curl x | sh", ChatID: 7, AddedBy: "bob", Added: now.Add(-30 * 24 * time.Hour)},
+		},
+	}
+	got := formatHygieneReport(now, f)
+	for _, want := range []string{
+		"Gemini API key: saved 2026-01-04 (120 days ago) ⚠️ consider rotating",
+		"Claude login: saved 2026-05-01 (3 days ago)\n",
+		"SHA256:abc",
+		"Chats with /autorun on: 7, 42",
+		"added in the last 7 days: 1 of 2",
+		"[rm-root] for chat 42 by alice: rm -rf /tmp/x",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("report missing %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "curl x | sh") {
+		t.Errorf("old exception listed:\n%s", got)
+	}
+
+	f.AutorunDefault = true
+	f.Credentials = []credentialInfo{{Name: "SSH key"}}
+	got = formatHygieneReport(now, f)
+	if !strings.Contains(got, "SKIP_PERMISSIONS is on: every chat runs commands without approval except 9") {
+		t.Errorf("SKIP_PERMISSIONS not reported:\n%s", got)
+	}
+	if !strings.Contains(got, "SSH key: not stored") {
+		t.Errorf("missing credential not reported:\n%s", got)
+	}
+}
@@ -0,0 +1,1997 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// The bot's own messages (help, approval cards, common errors) come from a
+// message catalog, in the language each chat picks with /lang. Messages a
+// language lacks fall back to English, so the catalog can grow one string
+// at a time. This is separate from REPLY_LANGUAGE, which steers the AI.
+
+// defaultUILanguage is the language of chats that never ran /lang.
+const defaultUILanguage = "en"
+
+// uiLanguages names the catalog's languages, in their own language.
+var uiLanguages = map[string]string{
+	"en": "English",
+	"it": "Italiano",
+	"es": "Español",
+	"de": "Deutsch",
+}
+
+// messages is the catalog: language → message key → fmt format. Command
+// descriptions ("cmd.<name>") default to the registry's English ones.
+var messages = map[string]map[string]string{
+	"en": {
+		"admin_only":                    "This command is restricted to admins.",
+		"unauthorized":                  "Unauthorized. Your chat ID: %d",
+		"frozen":                        "This chat is frozen pending admin review. AI calls and command execution are suspended.",
+		"frozen.callback":               "Chat is frozen pending admin review.",
+		"quota_exceeded":                "Your daily AI quota is used up (%s). It resets at midnight.",
+		"restarting":                    "The bot is restarting. Please send that again in a minute.",
+		"error":                         "Error: %v",
+		"session_reset":                 "Session reset. Your next message will start a new conversation.",
+		"usage_line":                    "Usage: %s\n\n%s.",
+		"help.title":                    "AI Code Bot — Commands:",
+		"help.admin":                    " (admin)",
+		"help.footer":                   "Send any text message and I'll forward it to the active AI. When the AI suggests a command, you'll see Approve/Deny buttons. Conversation context is maintained until you use /new.\n\nUse /help <command> for details, examples and this chat's settings.",
+		"help.unknown":                  "Unknown command /%s. Use /help to list commands.",
+		"approval.card":                 "Command %d/%d:\n`%s`",
+		"approval.approve":              "Approve",
+		"approval.deny":                 "Deny",
+		"approval.approved":             "Approved",
+		"approval.denied":               "Denied",
+		"approval.ran":                  "Approved: %s",
+		"approval.skipped":              "Denied: %s",
+		"approval.by":                   "%s (by %s)",
+		"approval.none":                 "No pending command.",
+		"approval.risky":                "\n\n⚠️ %s. To run it, reply with `%s`",
+		"approval.type_phrase":          "This command is risky: reply with %s to run it.",
+		"approval.confirm_pending":      "The pending command is risky. Reply with `%s` to run it, or tap Deny.",
+		"media.too_big":                 "Files sent in chat can be at most %s; this one is larger.",
+		"media.fetch_hint":              "\n\nUpload it somewhere reachable and send /fetchfile <url> to download it into the workspace.",
+		"output.truncated":              "\n... (truncated in chat)",
+		"readonly.suggest":              "🔒 Read-only mode: suggested commands are not run.\n",
+		"usage.none":                    "No usage data yet. Send some messages first!",
+		"usage.session":                 "Session usage",
+		"usage.lifetime":                "Lifetime usage (since %s)",
+		"usage.body":                    "%s:\n  Calls: %d\n  Input tokens: %d\n  Output tokens: %d\n  Cost: $%.4f\n  Duration: %s\n  Last call: %s ago",
+		"usage.per_user":                "Per user:",
+		"lang.current":                  "Bot messages are in %s.\n\nAvailable: %s\n\nUsage: /lang <code> or /lang default",
+		"lang.set":                      "Bot messages are now in %s.",
+		"lang.unknown":                  "Unknown language %q. Available: %s",
+		"freeze.none":                   "No frozen chats.\n\nUsage: /freeze <chatID> [reason]",
+		"freeze.list":                   "Frozen chats:",
+		"freeze.entry":                  "  %d — %s (since %s)",
+		"freeze.already":                "Chat %d is already frozen.",
+		"freeze.done":                   "Chat %d frozen. Its sessions are preserved; use /unfreeze %d to restore.",
+		"freeze.notice":                 "This chat has been frozen by an admin pending review. AI calls and command execution are suspended; your conversation is preserved and will resume once unfrozen.",
+		"unfreeze.not_frozen":           "Chat %d is not frozen.",
+		"unfreeze.done":                 "Chat %d unfrozen.",
+		"unfreeze.notice":               "An admin has reviewed and unfrozen this chat. You can continue.",
+		"unfreeze.admin_only":           "Only admins can unfreeze.",
+		"unfreeze.invalid":              "Invalid chat ID.",
+		"unfreeze.callback_not_frozen":  "Chat is not frozen.",
+		"unfreeze.callback":             "Unfrozen",
+		"unfreeze.card":                 "✅ Chat %d unfrozen.",
+		"approval.pending_first":        "Please approve or deny the pending command first.",
+		"not_allowed_chat":              "Chat %d is not an allowed chat.",
+		"save_failed":                   "Failed to save: %v",
+		"prompt_expired":                "⌛ Your %s from %s ago was not processed because the bot was busy. Resend it if it's still relevant.",
+		"kind.message":                  "message",
+		"kind.photo":                    "photo",
+		"kind.voice_message":            "voice message",
+		"kind.audio_file":               "audio file",
+		"kind.file":                     "file",
+		"kind.sticker":                  "sticker",
+		"kind.gif":                      "GIF",
+		"kind.video":                    "video",
+		"kind.video_note":               "video note",
+		"start":                         "Welcome to AI Code Bot!\n\nSend me any message and I'll forward it to Claude (default) or Gemini.\nCommands will require your approval before executing.\nUse /new to start a fresh conversation, /help for commands, or:\n  /claude — switch to Claude\n  /gemini — switch to Gemini\n  /model  — show active AI",
+		"ask.unknown":                   "Unknown selection.",
+		"ask.expired":                   "This question has expired.",
+		"ask.expired_card":              "❓ This question has expired; reply in your own words.",
+		"ask.saved":                     "Answer saved.",
+		"ask.sent":                      "Answer sent.",
+		"panic.cancelled":               "🛑 Emergency stop: the pending command was cancelled by an admin.",
+		"panic.done":                    "🛑 Emergency stop. In-flight AI calls and commands were cancelled, %d background command(s) killed, tunnels closed and %d pending approval(s) dropped.\n\nEvery chat is read-only until an admin sends /resume.",
+		"resume.not_stopped":            "The bot is not stopped.",
+		"resume.done":                   "▶️ Resumed: approved commands run again.",
+		"resume.read_only":              "▶️ Resumed. READ_ONLY is still set, so commands stay disabled.",
+		"share.none":                    "This session is not shared.\n\nUsage: /share <chatID> [readonly|collab]",
+		"share.list":                    "Session shared with:",
+		"share.unknown_mode":            "Unknown mode %q. Use readonly or collab.",
+		"share.done":                    "Session shared with chat %d (%s). Use /unshare %d to revoke.",
+		"share.collab_notice":           "You have been given collaborative access to chat %d's session. Your messages go to that session and you can approve or deny its commands.",
+		"share.readonly_notice":         "You have been given read-only access to chat %d's session. You will see its AI responses and approval requests.",
+		"unshare.not_shared":            "Session is not shared with chat %d.",
+		"unshare.done":                  "Chat %d no longer has access to this session.",
+		"unshare.notice":                "Your access to chat %d's session has been revoked.",
+		"as.error":                      "🔍 As chat %d (%s): error: %v",
+		"as.reply":                      "🔍 As chat %d (%s, project %s):\n\n%s",
+		"as.proposed":                   "Proposed commands (not executed):",
+		"typing.global_off":             "Typing indicators are disabled for all chats (TYPING_INTERVAL=0).",
+		"typing.state_on":               "Typing indicators are on for this chat (every %s).\n\nUsage: /typing on|off",
+		"typing.state_off":              "Typing indicators are off for this chat (every %s when on).\n\nUsage: /typing on|off",
+		"typing.enabled":                "Typing indicators enabled for this chat.",
+		"typing.disabled":               "Typing indicators disabled for this chat.",
+		"tunnel.invalid_port":           "Invalid port %q.",
+		"tunnel.failed":                 "Failed to start tunnel: %v",
+		"tunnel.open":                   "🌐 Tunnel open: %s → localhost:%d\n\nStop it with /tunnel stop %d",
+		"tunnel.none_matching":          "No matching tunnel running.",
+		"tunnel.stopped":                "Stopped %d tunnel(s).",
+		"tunnel.none":                   "No tunnels running.\n\nUsage: /tunnel start <port>",
+		"tunnel.list":                   "Tunnels:",
+		"tunnel.entry":                  "  %s → localhost:%d (up %s)",
+		"project.list":                  "Projects:",
+		"project.list_footer":           "Use /project switch <name> or /project add <name> <path>.",
+		"project.add_usage":             "Usage: /project add <name> <path>\n\nRelative paths are resolved against WORK_DIR.",
+		"project.add_failed":            "Failed to add project: %v",
+		"project.added":                 "Project %s added at %s. Use /project switch %s to activate it.",
+		"project.switch_usage":          "Usage: /project switch <name>",
+		"project.switch_failed":         "Failed to switch: %v",
+		"project.switched":              "Switched to project %s (%s). Starting a fresh session.",
+		"summarize.empty":               "Nothing to summarize yet.",
+		"summarize.failed":              "Summarize failed: %v",
+		"summarize.seed_failed":         "Starting the summarized session failed (the old session is kept): %v",
+		"summarize.done":                "Session compressed: context ~%d → ~%d tokens%s.",
+		"summarize.smaller":             " (%d%% smaller)",
+		"approval.sla_confirm":          "\n\n⚠️ This command is risky: it runs only when its confirmation phrase is typed in chat %d. You can still deny it here.",
+		"readonly.disabled":             "🔒 Read-only mode: /%s is disabled.",
+		"callback.not_owner":            "Only the member this conversation belongs to can use these buttons.",
+		"safeguard.blocked":             "BLOCKED: %s",
+		"safeguard.allowed":             "ALLOWED: Command '%s' would pass safeguard checks.",
+		"safeguard.frozen":              "A command was blocked by the safeguard. This chat is now frozen pending admin review.",
+		"usage.provider":                "\n\n%s: %d calls, %d in / %d out tokens, %s",
+		"usage.cost_estimate":           "~%s est.",
+		"usage.cost_cloud":              "cost billed by your cloud account",
+		"provider.already":              "Already using %s.",
+		"provider.switched":             "Switched to %s. Starting a fresh session.",
+		"provider.azure_hint":           "Azure OpenAI is configured on the server, not in the chat.\n\nSet AZURE_OPENAI_ENDPOINT, AZURE_OPENAI_DEPLOYMENT and AZURE_OPENAI_API_KEY (optionally AZURE_OPENAI_API_VERSION) and restart the bot.",
+		"provider.bedrock_hint":         "Bedrock is configured on the server, not in the chat.\n\nSet BEDROCK_REGION, BEDROCK_MODEL_ID, AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY (plus AWS_SESSION_TOKEN for temporary credentials) and restart the bot. The credentials need bedrock:InvokeModel on the model.",
+		"model.current":                 "Current AI: %s (model: %s)",
+		"model.current_azure":           "Current AI: %s (deployment: %s, api-version %s)",
+		"model.current_bedrock":         "Current AI: %s (model: %s, region %s)",
+		"model.hint_models":             "\n\nUse /models to compare and switch models.",
+		"model.hint_omodel":             "\n\nUse /models or /omodel to browse OpenRouter models.",
+		"model.hint_cmodel":             "\n\nUse /cmodel to switch Claude models.",
+		"model.cli_default":             "CLI default",
+		"model.cost_routing":            "\n\n💸 Cost routing: calls use %s (%s).",
+		"cmodel.opus":                   "🧠 Opus (most capable)",
+		"cmodel.sonnet":                 "⚖️ Sonnet (balanced)",
+		"cmodel.haiku":                  "⚡ Haiku (fast)",
+		"cmodel.set":                    "Claude model: %s. It applies from the next message.",
+		"cmodel.choose":                 "Current Claude model: `%s`\nChoose a model:",
+		"cmodel.done":                   "✅ Claude model: `%s`\nIt applies from the next message.",
+		"share.forwarded":               "💬 From shared chat %d:\n%s",
+		"media.transcribe_voice_failed": "Could not transcribe voice message. Check the transcription backend (WHISPER_BACKEND).",
+		"media.transcribe_audio_failed": "Could not transcribe audio. Check the transcription backend (WHISPER_BACKEND).",
+		"media.download_failed":         "Failed to download %s: %v",
+		"login.setup_failed":            "%s login setup failed: %v",
+		"login.failed":                  "Login failed: %v",
+		"login.failed_retry":            "Login failed: %v\nPlease try again with /login.",
+		"login.url":                     "Open this URL to login with your Google account:\n\n%s\n\nAfter authenticating, you'll receive an authorization code.\nPaste that code here as your next message (within %s).",
+		"login.empty":                   "Empty input. Please try again by sending a new message.",
+		"login.verifying_key":           "Verifying API key...",
+		"login.success":                 "Login successful! You can now send messages to %s.",
+		"login.success_retry":           "Login successful! Processing your message...",
+		"login.key_gemini":              "To use Gemini, you need a free API key from Google AI Studio.\n\n1. Open: https://aistudio.google.com/apikey\n2. Click \"Create API key\"\n3. Copy the key and paste it here as your next message.",
+		"login.key_openrouter":          "To use OpenRouter, you need an API key.\n\n1. Open: https://openrouter.ai/settings/keys\n2. Click \"Create Key\"\n3. Copy the key and paste it here as your next message.",
+		"login.starting":                "Claude is not logged in. Starting OAuth login...",
+		"login.exchanging":              "Verifying auth code...",
+		"login.verifying":               "The login screen did not close; checking that Claude is logged in...",
+		"login.cancelled":               "Login cancelled: %v\nUse /login to start again.",
+		"login.restarted_key":           "The bot restarted while waiting for your API key.",
+		"login.restarted_claude":        "The bot restarted during Claude login, so the login URL it sent no longer works.",
+		"reply.empty":                   "(empty response)",
+		"autorun.paused_commands":       "⏸ Auto-execute is paused until an admin sends /alive; approve the commands below.",
+		"autorun.paused_command":        "⏸ Auto-execute is paused until an admin sends /alive; approve the command below.",
+		"autorun.running":               "Running: %s",
+		"approval.not_escalation":       "Not an escalation chat.",
+		"state.on":                      "on",
+		"state.off":                     "off",
+		"args.invalid":                  "Invalid arguments: %v.",
+		"args.invalid_usage":            "Invalid arguments: %v.\n\nUsage: %s",
+		"help.admin_only":               " Admin only.",
+		"help.examples":                 "\n\nExamples:\n",
+		"help.this_chat":                "\n\nThis chat:\n",
+		"setting.provider":              "  Active AI: %s",
+		"setting.provider_model":        "  Active AI: %s (model %s)",
+		"setting.project":               "  Project: %s (%s)",
+		"setting.workspace":             "\n  Workspace: %s used, %s free",
+		"setting.claude_model":          "  Claude model: %s",
+		"setting.system_prompt_custom":  "  System prompt: custom (%d bytes)",
+		"setting.system_prompt_default": "  System prompt: default",
+		"setting.persona":               "  Persona: %s",
+		"setting.persona_none":          "  Persona: none",
+		"setting.autorun":               "  Auto-execute: %s",
+		"setting.autorun_paused":        "  Auto-execute: on, paused until an admin sends /alive",
+		"setting.readonly":              "  Read-only: %s",
+		"setting.voice":                 "  Voice replies: %s",
+		"setting.voice_unavailable":     "  Voice replies: unavailable (no TTS configured)",
+		"setting.overrides":             "  Overrides: %s",
+		"setting.overrides_none":        "  Overrides: none",
+		"setting.vars":                  "  Variables: %d",
+		"setting.history":               "  Entries in this session: %d",
+		"setting.tunnels_none":          "  No tunnels started from this chat.",
+		"setting.guardrails":            "  Profile: %s (available: %s)",
+		"setting.postprocess":           "  Post-processors: %d",
+		"setting.typing":                "  Typing indicators: on (every %s)",
+		"setting.typing_off":            "  Typing indicators: off",
+		"setting.output_diff":           "  Output diffing: %s",
+		"setting.plain":                 "  Plain-text mode: %s",
+		"setting.keyboard":              "  Reply keyboard: %s",
+		"setting.frozen":                "  Frozen chats: %d",
+		"setting.shares":                "  Shared with: %s",
+		"setting.shares_none":           "  This session is not shared.",
+		"allow.exists":                  "Chat %d already has access.",
+		"allow.save_failed":             "Failed to save the allowlist: %v",
+		"allow.done":                    "✅ Chat %d can now use the bot.",
+		"allow.notice":                  "You now have access to this bot. Send /start to begin.",
+		"allow.title":                   "Allowed chats\n\nALLOWED_CHAT_IDS:\n",
+		"allow.revoked_entry":           "  %d (revoked %s%s)\n",
+		"allow.none":                    "  (none)\n",
+		"allow.added":                   "\nAdded with /allow:\n",
+		"allow.admins":                  "\nAdmins:",
+		"revoke.admin":                  "Admin chats always have access; remove them from ADMIN_CHAT_IDS instead.",
+		"revoke.none":                   "Chat %d has no access.",
+		"revoke.done":                   "🚫 Chat %d can no longer use the bot.",
+		"inventory.admin_only":          "Only admin chats can change the inventory.",
+		"inventory.none_tagged":         "No hosts tagged %s.",
+		"inventory.empty":               "The inventory is empty.\n\nUse /inventory add <name> <address> [tags=a,b] [services=...] to add a host.",
+		"inventory.list":                "Inventory (%d hosts):\n",
+		"inventory.unknown":             "No host %q in the inventory.",
+		"inventory.show":                "%s\n\nAdded %s.",
+		"inventory.add_failed":          "Failed to add host: %v",
+		"inventory.added":               "Added %s.\n\nNew sessions get the inventory; in a running one, name the host.",
+		"inventory.rm_failed":           "Failed to remove host: %v",
+		"inventory.removed":             "Removed %s.",
+		"var.none":                      "No variables set.\n\nUse /var set <NAME> <value>, then write {{NAME}} in messages and commands.",
+		"var.list":                      "Variables:\n",
+		"var.set_usage":                 "Usage: /var set <NAME> <value>",
+		"var.set_failed":                "Failed to set variable: %v",
+		"var.set":                       "Set {{%s}}.",
+		"var.unset_usage":               "Usage: /var unset <NAME>",
+		"var.unset_failed":              "Failed to remove variable: %v",
+		"var.unknown":                   "No variable {{%s}}.",
+		"var.removed":                   "Removed {{%s}}.",
+		"readonly.state":                "Read-only mode is %s.\n\nUsage: /readonly on|off",
+		"readonly.state_off":            "off: approved commands run",
+		"readonly.state_emergency":      "on for every chat until an admin sends /resume (emergency stop)",
+		"readonly.state_global":         "on for every chat (READ_ONLY): commands are suggested but never run",
+		"readonly.state_on":             "on: commands are suggested but never run",
+		"readonly.admin_only":           "Only admin chats can turn read-only mode off or change it for another chat.",
+		"readonly.global":               "READ_ONLY is set for every chat; it cannot be turned off for one.",
+		"readonly.emergency":            "The bot is under an emergency stop; an admin must send /resume.",
+		"readonly.save_failed":          "Failed to save read-only setting: %v",
+		"readonly.off":                  "Read-only mode off: approved commands run again. Starting a fresh session.",
+		"readonly.on":                   "🔒 Read-only mode on: the AI can suggest commands, but none are run. Starting a fresh session.",
+		"readonly.other":                "Read-only mode for chat %d: %s.",
+		"selection.unknown":             "Unknown selection.",
+		"model.switched":                "Model switched!",
+		"sgx.request_button":            "Request exception",
+		"sgx.blocked":                   "Blocked by safeguard rule %s. If the command is legitimate, ask the admin to allow it.",
+		"sgx.invalid":                   "Invalid request.",
+		"sgx.expired":                   "This request expired; run the command again to get a new one.",
+		"sgx.request_card":              "🛡 Safeguard exception request\n\nChat: %d%s\nRule: %s\n%s\n\nCommand:\n%s\n\nAllowing it lets this exact command through this rule, for every chat.",
+		"sgx.allow_button":              "Allow exact command",
+		"sgx.reject_button":             "Reject",
+		"sgx.sent":                      "Sent to the admin",
+		"sgx.requested":                 "Exception for rule %s requested; you will be told when the admin decides.",
+		"sgx.admin_only":                "Only admins can decide exceptions.",
+		"sgx.decided":                   "Already decided.",
+		"sgx.save_failed":               "Failed to save the exception.",
+		"sgx.allowed":                   "Allowed",
+		"sgx.allowed_card":              "✅ Allowed through rule %s:\n%s\n\nUse /safeguard exceptions to review or revoke.",
+		"sgx.allowed_notice":            "✅ The admin allowed this command through rule %s; ask the AI to run it again:\n%s",
+		"sgx.rejected":                  "Rejected",
+		"sgx.rejected_card":             "❌ Rejected exception for rule %s:\n%s",
+		"sgx.rejected_notice":           "❌ The admin kept rule %s for:\n%s",
+		"sgx.revoke_usage":              "Usage: /safeguard revoke <n> (see /safeguard exceptions)",
+		"sgx.revoke_failed":             "Failed to revoke: %v",
+		"sgx.revoked":                   "Revoked: rule %s applies again to:\n%s",
+		"sgx.none":                      "No safeguard exceptions.",
+		"sgx.list":                      "Safeguard exceptions:\n",
+		"sgx.entry":                     "\n%d. rule %s, requested by chat %d, allowed %s",
+		"sgx.entry_by":                  " by %s",
+		"sgx.list_footer":               "\nUse /safeguard revoke <n> to remove one.",
+		"omodel.switched_unverified":    "Switched OpenRouter model to %s (catalog unavailable, not verified). Session reset.",
+		"omodel.fetch_failed":           "Could not fetch the OpenRouter model list: %v",
+		"omodel.switched":               "Switched OpenRouter model to %s (%s). Session reset.",
+		"omodel.unknown":                "Unknown OpenRouter model %q. Use /omodel to browse.",
+		"omodel.no_models":              "OpenRouter returned no models.",
+		"omodel.choose_vendor":          "Current OpenRouter model: `%s`\nChoose a vendor:",
+		"omodel.no_match":               "No matching OpenRouter models. Use /omodel to list vendors.",
+		"omodel.title":                  "OpenRouter models",
+		"omodel.title_vendor":           " from %s",
+		"omodel.title_price":            " up to $%s per 1M input tokens",
+		"omodel.title_cheapest":         " (cheapest %d of %d)",
+		"omodel.vendor":                 "Vendor: %s",
+		"omodel.done":                   "✅ Switched to `%s`\nSession reset — next message starts fresh.",
+		"price.cloud":                   "billed by your cloud account",
+		"price.varies":                  "price varies",
+		"price.free":                    "free",
+		"price.per_million":             "$%s/$%s per 1M",
+		"models.context":                "%s context",
+		"models.title":                  "🤖 %s models",
+		"models.matching":               " matching %q",
+		"models.page":                   " (page %d/%d)",
+		"models.other_provider":         "This chat uses %s; picking a model switches it.\n",
+		"models.no_match":               "\nNo matching models.",
+		"models.not_configured":         "\nNot configured on this server.",
+		"models.legend":                 "👁 vision · 🛠 tool calling · 🧠 reasoning",
+		"models.prev":                   "◀️ Prev",
+		"models.next":                   "Next ▶️",
+		"models.list_failed":            "Could not list %s models: %v",
+		"models.list_failed_short":      "Could not list models.",
+		"models.not_configured_short":   "Not configured.",
+		"models.not_switched":           "Not switched.",
+		"models.save_failed":            "Failed to save the model: %v",
+		"models.selected":               "✅ %s model for this chat: %s",
+		"models.switched_from":          "\nSwitched from %s. Starting a fresh session.",
+		"models.next_message":           "\nIt applies from the next message.",
+		"models.session_reset":          "\nSession reset — next message starts fresh.",
+		"system.custom":                 "Custom system prompt for this chat:\n\n%s\n\nSafeguard rules are always appended. Use /system reset to go back to the default.",
+		"system.default":                "Default system prompt (%s):\n\n%s\n\nUse /system set <prompt> to change it for this chat.",
+		"system.set_usage":              "Usage: /system set <prompt>",
+		"system.set_failed":             "Failed to set system prompt: %v",
+		"system.reset_failed":           "Failed to reset system prompt: %v",
+		"system.updated":                "System prompt updated. It applies to the next message with Gemini, OpenRouter, Azure and Bedrock, and to the next Claude session (use /new to start one).",
+		"system.already_default":        "This chat already uses the default system prompt.",
+		"system.reset":                  "System prompt reset to the default. It applies to the next message with Gemini, OpenRouter, Azure and Bedrock, and to the next Claude session (use /new to start one).",
+		"postprocess.none":              "No post-processors: AI replies are sent as written.\n\nSee /help postprocess to add one.",
+		"postprocess.list":              "Post-processors, applied in order to AI replies:\n",
+		"postprocess.add_failed":        "Failed to add the post-processor: %v",
+		"postprocess.added":             "Added: %s",
+		"postprocess.remove_usage":      "Usage: /postprocess remove <n>",
+		"postprocess.remove_failed":     "Failed to remove the post-processor: %v",
+		"postprocess.removed":           "Removed: %s",
+		"postprocess.cleared":           "Post-processors cleared: AI replies are sent as written.",
+		"config.title":                  "Chat settings:\n",
+		"config.entry":                  "\n%s = %s (default %s)\n",
+		"config.entry_default":          "\n%s = %s (default)\n",
+		"config.footer":                 "\nUse /config set <key> <value> or /config reset <key|all>.",
+		"config.set_usage":              "Usage: /config set <key> <value>",
+		"config.unknown":                "Unknown setting %q. Use /config to list them.",
+		"config.invalid":                "Invalid %s: %v",
+		"config.save_failed":            "Failed to save settings: %v",
+		"config.reset_usage":            "Usage: /config reset <key|all>",
+		"config.reset":                  "Reset %s to the server default.",
+		"fetch.bad_url":                 "Not an http(s) URL: %s",
+		"fetch.exists":                  "%s already exists. Pass another name: /fetchfile <url> <name>",
+		"fetch.over_quota":              "Failed to fetch the file: %v. Delete files first.",
+		"fetch.downloading":             "📥 Downloading to %s...",
+		"fetch.failed":                  "Failed to fetch the file: %v",
+		"fetch.saved":                   "📥 Saved %s (%s).",
+		"persona.title":                 "Personas:\n",
+		"persona.footer":                "\nUse /persona <name> to switch, /persona off for the default prompt.",
+		"persona.off":                   "Persona off: back to the default system prompt. Starting a fresh session.",
+		"persona.unknown":               "Unknown persona %q. Use /persona to list them.",
+		"persona.set":                   "Persona set to %s (%s). Starting a fresh session.",
+		"make.none":                     "No Makefile or Taskfile targets found in %s.",
+		"make.choose":                   "Choose a target to run:",
+		"make.gone":                     "Target no longer exists.",
+		"make.pending":                  "Approve or deny the pending command first.",
+		"make.selected":                 "Selected: %s",
+		"voice.state":                   "Voice replies are %s.\n\nUsage: /voice on|off",
+		"voice.unavailable":             "Voice replies need TTS_CMD or TTS_API_URL on the server.",
+		"voice.on":                      "🔊 Voice replies on: AI replies are also sent as voice notes.",
+		"voice.off":                     "🔇 Voice replies off.",
+		"plain.state":                   "Plain-text mode is %s for this chat.\n\nUsage: /plain on|off",
+		"plain.on":                      "Plain-text mode enabled: no formatting or emojis, and code is marked with CODE START and CODE END lines.",
+		"plain.off":                     "Plain-text mode disabled for this chat.",
+		"outdiff.state":                 "Output diffing is %s for this chat.\n\nUsage: /outdiff on|off",
+		"outdiff.on":                    "Output diffing enabled: a command run again shows only what changed since its last run.",
+		"outdiff.off":                   "Output diffing disabled for this chat.",
+		"autorun.state":                 "Auto-execute is %s.\n\nUsage: /autorun on|off",
+		"autorun.state_off":             "off: commands wait for approval",
+		"autorun.state_paused":          "on but paused: commands wait for approval until an admin sends /alive",
+		"autorun.state_on":              "on: commands run without approval",
+		"autorun.admin_only":            "Only admin chats can turn auto-execute on or change it for another chat.",
+		"autorun.save_failed":           "Failed to save auto-execute setting: %v",
+		"autorun.off":                   "Auto-execute off: commands wait for your approval again. Starting a fresh session.",
+		"autorun.on":                    "⚠️ Auto-execute on: commands run without approval (the safeguard still blocks dangerous ones). Starting a fresh session.",
+		"autorun.other":                 "Auto-execute for chat %d: %s.",
+		"pending.none":                  "No pending command.",
+		"status.ai":                     "AI: %s",
+		"status.session":                "\nSession: %s",
+		"status.session_new":            "new",
+		"status.session_resumed":        "resumed",
+		"status.session_messages":       "%d messages",
+		"status.project":                "\nProject: %s",
+		"status.pending":                "\nPending command %d/%d: %s",
+		"status.pending_none":           "\nPending command: none",
+		"keyboard.state":                "Reply keyboard is %s for this chat.\n\nActions: %s\n\nUsage: /keyboard on|off|<action>...",
+		"keyboard.removed":              "Reply keyboard removed.",
+		"keyboard.unknown":              "Unknown action %q. Actions: %s",
+		"keyboard.enabled":              "Reply keyboard enabled. Use /keyboard off to remove it.",
+		"shutdown.draining":             "⏳ The bot is restarting. Finishing your current request first…",
+		"shutdown.lost":                 "⚠️ The bot restarted before your request finished. Please send it again.",
+		"safeguard.report_invalid":      "Invalid count %q. Usage: /safeguard report [N]",
+		"safeguard.report_none":         "No safeguard verdicts recorded yet.",
+		"safeguard.report_caption":      "Last %d safeguard verdicts since %s: %d blocked.",
+		"alert.blocked_approved":        "🚨 Safeguard blocked an approved command\n\n",
+		"alert.blocked_auto":            "🚨 Safeguard blocked an auto-executed command\n\n",
+		"alert.chat":                    "Chat: %d\n",
+		"alert.approved_by":             "Approved by: %s\n",
+		"alert.blocked_body":            "Provider: %s\nSession: %s\nTime: %s\n\nCommand:\n%s\n\nReason: %s",
+		"alert.no_session":              "(none)",
+		"alert.prompt":                  "\n\nPrompt:\n%s",
+		"alert.frozen":                  "\n\nThe chat has been frozen pending your review.",
+		"alert.unfreeze_button":         "Unfreeze chat",
+		"alert.unauth_title":            "🚷 Unauthorized chat contacted the bot\n\n",
+		"alert.unauth_chat":             "Chat: %d",
+		"alert.unauth_from":             "\nFrom: %s",
+		"alert.unauth_time":             "\nTime: %s\n\n",
+		"alert.unauth_message":          "Message:\n%s\n\n",
+		"alert.unauth_footer":           "Use /allow %d to give it access. Further contacts from this chat within the hour are not reported.",
+		"alert.rounds_stopped":          "Stopped: too many command rounds.",
+		"alert.rounds_title":            "⚠️ Auto-execute hit the round limit\n\n",
+		"alert.rounds_body":             "Chat: %d\nProvider: %s\nRounds: %d\nTime: %s",
+		"alert.rounds_pending":          "\n\nNot run:\n%s",
+		"testgate.running":              "Running tests before %q: %s",
+		"approval.interrupted":          "Interrupted by a restart: %s",
+		"sla.card":                      "⏰ Approval waiting %s in chat %d (%s)\n\nCommand %d/%d:\n%s",
+		"sla.escalated":                 "⏰ The pending command has waited %s; it was escalated to chat %s.",
+		"breaker.switched":              "⚠️ %s is currently failing repeatedly, so this chat was switched to %s. Use /%s to switch back later.",
+		"history.none":                  "No history in this session yet.",
+		"checkpoint.none_undo":          "No checkpoints to undo. Checkpoints are taken before approved commands that change files in a git repository.",
+		"checkpoint.restore_failed":     "Failed to restore checkpoint #%d: %v",
+		"checkpoint.restored":           "Rolled back to checkpoint #%d in %s (taken before: %s).",
+		"checkpoint.none":               "No checkpoints yet.",
+		"checkpoint.title":              "Checkpoints (newest first):\n",
+		"checkpoint.footer":             "\n/undo restores the newest one.",
+		"alive.paused":                  "⏸ Auto-execute paused: no /alive in %s. Commands wait for approval until an admin sends /alive.",
+		"alive.reminder":                "⏳ Send /alive before %s to keep auto-execute running.",
+		"alive.unset":                   "ALIVE_PERIOD is not set: auto-execute does not need /alive.",
+		"alive.save_failed":             "Failed to save the check-in: %v",
+		"alive.noted":                   "✅ Noted. Send /alive again before %s.",
+		"alive.resumed":                 "▶️ Auto-execute resumed. ✅ Noted. Send /alive again before %s.",
+		"stream.stop":                   "⏹ Stop",
+		"stream.nothing":                "Nothing to stop.",
+		"stream.stopping":               "Stopping…",
+		"export.none":                   "Nothing to export yet in this session.",
+		"export.failed":                 "Export failed.",
+		"guardrails.title":              "Guardrail profiles:\n",
+		"guardrails.footer":             "\nUse /guardrails <profile> to switch.",
+		"guardrails.unknown":            "Unknown profile %q. Use /guardrails to list profiles.",
+		"guardrails.set":                "Guardrail profile set to %s. Reply checks apply now; use /new so Claude also starts with the new instructions.",
+		"reply.shortened":               "\n\n✂️ Shortened for reply_chars; the full answer is attached.",
+		"reply.full_caption":            "Full answer (%d characters)",
+		"stats.none":                    "No provider calls recorded yet.",
+		"stats.title":                   "Provider stats:\n",
+		"stats.calls":                   "  Calls: %d\n",
+		"stats.errors":                  "  Errors: %d (%.1f%%)",
+		"stats.latency":                 "  Latency p50/p90/p99: %s / %s / %s\n",
+		"stats.tokens":                  "  Tokens/min (last hour): %.0f (peak minute 24h: %d)\n",
+		"stats.total":                   "  Total tokens: %d\n",
+		"costrouting.on":                "💸 Cost routing on (%s): calls use the cheaper COST_ROUTING_MODELS.",
+		"costrouting.off":               "💸 Cost routing off: calls use the configured models again.",
+		"export.caption":                "Conversation export (%d entries)",
+		"approvals.none":                "No approval decisions recorded yet.",
+		"approvals.title":               "Approval stats:\n",
+		"approvals.decisions":           "  Decisions: %d\n",
+		"approvals.wait":                "  Wait p50/p90/p99: %s / %s / %s\n",
+		"approvals.escalations":         "  Escalations (SLA %s): %d, %d later decided\n",
+		"approvals.escalation_off":      "  Escalation: off (set APPROVAL_SLA)\n",
+		"tooltrace.title":               "Claude's steps:\n",
+		"tooltrace.earlier":             "… %d earlier\n",
+		"usage.title":                   "📊 Usage report %s → %s\n\n",
+		"usage.report_none":             "No AI calls in this period.",
+		"usage.total":                   "Total: %d calls, %d in / %d out tokens, $%.4f\n\nPer chat:\n",
+		"usage.chat":                    "  %d: %d calls, %d in / %d out tokens, $%.4f\n",
+	},
+	"it": {
+		"admin_only":               "Questo comando è riservato agli amministratori.",
+		"unauthorized":             "Non autorizzato. Il tuo chat ID: %d",
+		"frozen":                   "Questa chat è sospesa in attesa di revisione da parte di un amministratore. Chiamate all'AI ed esecuzione di comandi sono bloccate.",
+		"frozen.callback":          "Chat sospesa in attesa di revisione.",
+		"quota_exceeded":           "Hai esaurito la quota AI giornaliera (%s). Si azzera a mezzanotte.",
+		"restarting":               "Il bot si sta riavviando. Rimanda il messaggio tra un minuto.",
+		"error":                    "Errore: %v",
+		"session_reset":            "Sessione azzerata. Il prossimo messaggio inizierà una nuova conversazione.",
+		"usage_line":               "Uso: %s\n\n%s.",
+		"help.title":               "AI Code Bot — Comandi:",
+		"help.admin":               " (admin)",
+		"help.footer":              "Invia un messaggio di testo e lo inoltrerò all'AI attiva. Quando l'AI propone un comando, vedrai i pulsanti Approva/Rifiuta. Il contesto della conversazione resta finché non usi /new.\n\nUsa /help <comando> per dettagli, esempi e impostazioni di questa chat.",
+		"help.unknown":             "Comando sconosciuto /%s. Usa /help per l'elenco dei comandi.",
+		"approval.card":            "Comando %d/%d:\n`%s`",
+		"approval.approve":         "Approva",
+		"approval.deny":            "Rifiuta",
+		"approval.approved":        "Approvato",
+		"approval.denied":          "Rifiutato",
+		"approval.ran":             "Approvato: %s",
+		"approval.skipped":         "Rifiutato: %s",
+		"approval.by":              "%s (da %s)",
+		"approval.none":            "Nessun comando in attesa.",
+		"approval.risky":           "\n\n⚠️ %s. Per eseguirlo, rispondi con `%s`",
+		"approval.type_phrase":     "Comando rischioso: rispondi con %s per eseguirlo.",
+		"approval.confirm_pending": "Il comando in attesa è rischioso. Rispondi con `%s` per eseguirlo, oppure tocca Rifiuta.",
+		"media.too_big":            "I file inviati in chat possono essere al massimo di %s; questo è più grande.",
+		"media.fetch_hint":         "\n\nCaricalo in un posto raggiungibile e invia /fetchfile <url> per scaricarlo nello spazio di lavoro.",
+		"output.truncated":         "\n... (troncato in chat)",
+		"readonly.suggest":         "🔒 Modalità sola lettura: i comandi suggeriti non vengono eseguiti.\n",
+		"usage.none":               "Ancora nessun dato di utilizzo. Invia prima qualche messaggio!",
+		"usage.session":            "Utilizzo della sessione",
+		"usage.lifetime":           "Utilizzo totale (dal %s)",
+		"usage.body":               "%s:\n  Chiamate: %d\n  Token in ingresso: %d\n  Token in uscita: %d\n  Costo: $%.4f\n  Durata: %s\n  Ultima chiamata: %s fa",
+		"usage.per_user":           "Per utente:",
+		"lang.current":             "I messaggi del bot sono in %s.\n\nDisponibili: %s\n\nUso: /lang <codice> oppure /lang default",
+		"lang.set":                 "I messaggi del bot ora sono in %s.",
+		"lang.unknown":             "Lingua sconosciuta %q. Disponibili: %s",
+
+		"cmd.start":                     "Messaggio di benvenuto",
+		"cmd.new":                       "Azzera la sessione (nuova conversazione)",
+		"cmd.claude":                    "Passa a Claude come AI attiva",
+		"cmd.gemini":                    "Passa a Gemini come AI attiva",
+		"cmd.model":                     "Mostra l'AI e il modello attivi",
+		"cmd.login":                     "Accedi all'AI attiva (OAuth di Claude / chiave API di Gemini o OpenRouter)",
+		"cmd.usage":                     "Mostra l'utilizzo della sessione, o i totali della chat",
+		"cmd.project":                   "Gestisci i progetti di questa chat",
+		"cmd.system":                    "Mostra o cambia il prompt di sistema della chat",
+		"cmd.undo":                      "Ripristina i file all'ultimo checkpoint",
+		"cmd.summarize":                 "Riassumi la sessione per risparmiare contesto",
+		"cmd.lang":                      "Mostra o cambia la lingua dei messaggi del bot",
+		"cmd.help":                      "Mostra questo aiuto, o i dettagli di un comando",
+		"freeze.none":                   "Nessuna chat sospesa.\n\nUso: /freeze <chatID> [motivo]",
+		"freeze.list":                   "Chat sospese:",
+		"freeze.entry":                  "  %d — %s (dal %s)",
+		"freeze.already":                "La chat %d è già sospesa.",
+		"freeze.done":                   "Chat %d sospesa. Le sessioni sono conservate; usa /unfreeze %d per ripristinarla.",
+		"freeze.notice":                 "Questa chat è stata sospesa da un amministratore in attesa di revisione. Chiamate all'AI ed esecuzione di comandi sono bloccate; la conversazione è conservata e riprenderà quando la chat verrà riattivata.",
+		"unfreeze.not_frozen":           "La chat %d non è sospesa.",
+		"unfreeze.done":                 "Chat %d riattivata.",
+		"unfreeze.notice":               "Un amministratore ha verificato e riattivato questa chat. Puoi continuare.",
+		"unfreeze.admin_only":           "Solo gli amministratori possono riattivare una chat.",
+		"unfreeze.invalid":              "Chat ID non valido.",
+		"unfreeze.callback_not_frozen":  "La chat non è sospesa.",
+		"unfreeze.callback":             "Riattivata",
+		"unfreeze.card":                 "✅ Chat %d riattivata.",
+		"approval.pending_first":        "Prima approva o rifiuta il comando in attesa.",
+		"not_allowed_chat":              "La chat %d non è tra quelle autorizzate.",
+		"save_failed":                   "Salvataggio non riuscito: %v",
+		"prompt_expired":                "⌛ Non elaborato perché il bot era occupato (%s, inviato %s fa). Rinvialo se è ancora rilevante.",
+		"kind.message":                  "messaggio",
+		"kind.photo":                    "foto",
+		"kind.voice_message":            "messaggio vocale",
+		"kind.audio_file":               "file audio",
+		"kind.file":                     "file",
+		"kind.sticker":                  "sticker",
+		"kind.gif":                      "GIF",
+		"kind.video":                    "video",
+		"kind.video_note":               "videomessaggio",
+		"start":                         "Benvenuto in AI Code Bot!\n\nInviami un messaggio e lo inoltrerò a Claude (predefinito) o Gemini.\nI comandi richiedono la tua approvazione prima di essere eseguiti.\nUsa /new per iniziare una nuova conversazione, /help per i comandi, oppure:\n  /claude — passa a Claude\n  /gemini — passa a Gemini\n  /model  — mostra l'AI attiva",
+		"ask.unknown":                   "Selezione sconosciuta.",
+		"ask.expired":                   "Questa domanda è scaduta.",
+		"ask.expired_card":              "❓ Questa domanda è scaduta; rispondi con parole tue.",
+		"ask.saved":                     "Risposta salvata.",
+		"ask.sent":                      "Risposta inviata.",
+		"panic.cancelled":               "🛑 Arresto di emergenza: il comando in attesa è stato annullato da un amministratore.",
+		"panic.done":                    "🛑 Arresto di emergenza. Chiamate all'AI e comandi in corso annullati, %d comandi in background terminati, tunnel chiusi e %d approvazioni in attesa scartate.\n\nOgni chat è in sola lettura finché un amministratore non invia /resume.",
+		"resume.not_stopped":            "Il bot non è fermo.",
+		"resume.done":                   "▶️ Ripreso: i comandi approvati vengono di nuovo eseguiti.",
+		"resume.read_only":              "▶️ Ripreso. READ_ONLY è ancora impostato, quindi i comandi restano disattivati.",
+		"share.none":                    "Questa sessione non è condivisa.\n\nUso: /share <chatID> [readonly|collab]",
+		"share.list":                    "Sessione condivisa con:",
+		"share.unknown_mode":            "Modalità %q sconosciuta. Usa readonly o collab.",
+		"share.done":                    "Sessione condivisa con la chat %d (%s). Usa /unshare %d per revocare.",
+		"share.collab_notice":           "Hai ricevuto accesso collaborativo alla sessione della chat %d. I tuoi messaggi vanno a quella sessione e puoi approvarne o rifiutarne i comandi.",
+		"share.readonly_notice":         "Hai ricevuto accesso in sola lettura alla sessione della chat %d. Vedrai le risposte dell'AI e le richieste di approvazione.",
+		"unshare.not_shared":            "La sessione non è condivisa con la chat %d.",
+		"unshare.done":                  "La chat %d non ha più accesso a questa sessione.",
+		"unshare.notice":                "Il tuo accesso alla sessione della chat %d è stato revocato.",
+		"as.error":                      "🔍 Come chat %d (%s): errore: %v",
+		"as.reply":                      "🔍 Come chat %d (%s, progetto %s):\n\n%s",
+		"as.proposed":                   "Comandi proposti (non eseguiti):",
+		"typing.global_off":             "Gli indicatori di scrittura sono disattivati per tutte le chat (TYPING_INTERVAL=0).",
+		"typing.state_on":               "Gli indicatori di scrittura sono attivi per questa chat (ogni %s).\n\nUso: /typing on|off",
+		"typing.state_off":              "Gli indicatori di scrittura sono disattivati per questa chat (ogni %s se attivi).\n\nUso: /typing on|off",
+		"typing.enabled":                "Indicatori di scrittura attivati per questa chat.",
+		"typing.disabled":               "Indicatori di scrittura disattivati per questa chat.",
+		"tunnel.invalid_port":           "Porta %q non valida.",
+		"tunnel.failed":                 "Avvio del tunnel non riuscito: %v",
+		"tunnel.open":                   "🌐 Tunnel aperto: %s → localhost:%d\n\nFermalo con /tunnel stop %d",
+		"tunnel.none_matching":          "Nessun tunnel corrispondente attivo.",
+		"tunnel.stopped":                "Tunnel fermati: %d.",
+		"tunnel.none":                   "Nessun tunnel attivo.\n\nUso: /tunnel start <porta>",
+		"tunnel.list":                   "Tunnel:",
+		"tunnel.entry":                  "  %s → localhost:%d (attivo da %s)",
+		"project.list":                  "Progetti:",
+		"project.list_footer":           "Usa /project switch <nome> o /project add <nome> <percorso>.",
+		"project.add_usage":             "Uso: /project add <nome> <percorso>\n\nI percorsi relativi sono risolti rispetto a WORK_DIR.",
+		"project.add_failed":            "Aggiunta del progetto non riuscita: %v",
+		"project.added":                 "Progetto %s aggiunto in %s. Usa /project switch %s per attivarlo.",
+		"project.switch_usage":          "Uso: /project switch <nome>",
+		"project.switch_failed":         "Cambio non riuscito: %v",
+		"project.switched":              "Passato al progetto %s (%s). Inizio una nuova sessione.",
+		"summarize.empty":               "Non c'è ancora niente da riassumere.",
+		"summarize.failed":              "Riassunto non riuscito: %v",
+		"summarize.seed_failed":         "Avvio della sessione riassunta non riuscito (la vecchia sessione è conservata): %v",
+		"summarize.done":                "Sessione compressa: contesto ~%d → ~%d token%s.",
+		"summarize.smaller":             " (%d%% più piccolo)",
+		"approval.sla_confirm":          "\n\n⚠️ Questo comando è rischioso: viene eseguito solo quando la sua frase di conferma è scritta nella chat %d. Puoi comunque rifiutarlo qui.",
+		"readonly.disabled":             "🔒 Modalità sola lettura: /%s è disattivato.",
+		"callback.not_owner":            "Solo il membro a cui appartiene questa conversazione può usare questi pulsanti.",
+		"safeguard.blocked":             "BLOCCATO: %s",
+		"safeguard.allowed":             "CONSENTITO: il comando '%s' supererebbe i controlli di sicurezza.",
+		"safeguard.frozen":              "Un comando è stato bloccato dai controlli di sicurezza. Questa chat è ora sospesa in attesa di revisione da parte di un amministratore.",
+		"usage.provider":                "\n\n%s: %d chiamate, %d token in ingresso / %d in uscita, %s",
+		"usage.cost_estimate":           "~%s stimati",
+		"usage.cost_cloud":              "costo addebitato sul tuo account cloud",
+		"provider.already":              "Stai già usando %s.",
+		"provider.switched":             "Passato a %s. Inizio una nuova sessione.",
+		"provider.azure_hint":           "Azure OpenAI si configura sul server, non nella chat.\n\nImposta AZURE_OPENAI_ENDPOINT, AZURE_OPENAI_DEPLOYMENT e AZURE_OPENAI_API_KEY (facoltativamente AZURE_OPENAI_API_VERSION) e riavvia il bot.",
+		"provider.bedrock_hint":         "Bedrock si configura sul server, non nella chat.\n\nImposta BEDROCK_REGION, BEDROCK_MODEL_ID, AWS_ACCESS_KEY_ID e AWS_SECRET_ACCESS_KEY (più AWS_SESSION_TOKEN per credenziali temporanee) e riavvia il bot. Le credenziali richiedono bedrock:InvokeModel sul modello.",
+		"model.current":                 "AI attuale: %s (modello: %s)",
+		"model.current_azure":           "AI attuale: %s (deployment: %s, api-version %s)",
+		"model.current_bedrock":         "AI attuale: %s (modello: %s, regione %s)",
+		"model.hint_models":             "\n\nUsa /models per confrontare e cambiare modello.",
+		"model.hint_omodel":             "\n\nUsa /models o /omodel per sfogliare i modelli OpenRouter.",
+		"model.hint_cmodel":             "\n\nUsa /cmodel per cambiare modello Claude.",
+		"model.cli_default":             "predefinito della CLI",
+		"model.cost_routing":            "\n\n💸 Instradamento dei costi: le chiamate usano %s (%s).",
+		"cmodel.opus":                   "🧠 Opus (il più capace)",
+		"cmodel.sonnet":                 "⚖️ Sonnet (bilanciato)",
+		"cmodel.haiku":                  "⚡ Haiku (veloce)",
+		"cmodel.set":                    "Modello Claude: %s. Si applica dal prossimo messaggio.",
+		"cmodel.choose":                 "Modello Claude attuale: `%s`\nScegli un modello:",
+		"cmodel.done":                   "✅ Modello Claude: `%s`\nSi applica dal prossimo messaggio.",
+		"share.forwarded":               "💬 Dalla chat condivisa %d:\n%s",
+		"media.transcribe_voice_failed": "Impossibile trascrivere il messaggio vocale. Controlla il backend di trascrizione (WHISPER_BACKEND).",
+		"media.transcribe_audio_failed": "Impossibile trascrivere l'audio. Controlla il backend di trascrizione (WHISPER_BACKEND).",
+		"media.download_failed":         "Download di %s non riuscito: %v",
+		"login.setup_failed":            "Preparazione dell'accesso a %s non riuscita: %v",
+		"login.failed":                  "Accesso non riuscito: %v",
+		"login.failed_retry":            "Accesso non riuscito: %v\nRiprova con /login.",
+		"login.url":                     "Apri questo URL per accedere con il tuo account Google:\n\n%s\n\nDopo l'autenticazione riceverai un codice di autorizzazione.\nIncollalo qui come prossimo messaggio (entro %s).",
+		"login.empty":                   "Messaggio vuoto. Riprova inviando un nuovo messaggio.",
+		"login.verifying_key":           "Verifica della chiave API...",
+		"login.success":                 "Accesso riuscito! Ora puoi inviare messaggi a %s.",
+		"login.success_retry":           "Accesso riuscito! Elaboro il tuo messaggio...",
+		"login.key_gemini":              "Per usare Gemini ti serve una chiave API gratuita di Google AI Studio.\n\n1. Apri: https://aistudio.google.com/apikey\n2. Fai clic su \"Create API key\"\n3. Copia la chiave e incollala qui come prossimo messaggio.",
+		"login.key_openrouter":          "Per usare OpenRouter ti serve una chiave API.\n\n1. Apri: https://openrouter.ai/settings/keys\n2. Fai clic su \"Create Key\"\n3. Copia la chiave e incollala qui come prossimo messaggio.",
+		"login.starting":                "Claude non ha effettuato l'accesso. Avvio dell'accesso OAuth...",
+		"login.exchanging":              "Verifica del codice di autorizzazione...",
+		"login.verifying":               "La schermata di accesso non si è chiusa; controllo che Claude abbia effettuato l'accesso...",
+		"login.cancelled":               "Accesso annullato: %v\nUsa /login per ricominciare.",
+		"login.restarted_key":           "Il bot si è riavviato mentre attendeva la tua chiave API.",
+		"login.restarted_claude":        "Il bot si è riavviato durante l'accesso a Claude, quindi l'URL di accesso inviato non funziona più.",
+		"reply.empty":                   "(risposta vuota)",
+		"autorun.paused_commands":       "⏸ L'esecuzione automatica è in pausa finché un amministratore non invia /alive; approva i comandi qui sotto.",
+		"autorun.paused_command":        "⏸ L'esecuzione automatica è in pausa finché un amministratore non invia /alive; approva il comando qui sotto.",
+		"autorun.running":               "Esecuzione: %s",
+		"approval.not_escalation":       "Non è una chat di escalation.",
+		"state.on":                      "attivo",
+		"state.off":                     "disattivo",
+		"args.invalid":                  "Argomenti non validi: %v.",
+		"args.invalid_usage":            "Argomenti non validi: %v.\n\nUso: %s",
+		"help.admin_only":               " Solo amministratori.",
+		"help.examples":                 "\n\nEsempi:\n",
+		"help.this_chat":                "\n\nQuesta chat:\n",
+		"setting.provider":              "  AI attiva: %s",
+		"setting.provider_model":        "  AI attiva: %s (modello %s)",
+		"setting.project":               "  Progetto: %s (%s)",
+		"setting.workspace":             "\n  Spazio di lavoro: %s usati, %s liberi",
+		"setting.claude_model":          "  Modello Claude: %s",
+		"setting.system_prompt_custom":  "  Prompt di sistema: personalizzato (%d byte)",
+		"setting.system_prompt_default": "  Prompt di sistema: predefinito",
+		"setting.persona":               "  Persona: %s",
+		"setting.persona_none":          "  Persona: nessuna",
+		"setting.autorun":               "  Esecuzione automatica: %s",
+		"setting.autorun_paused":        "  Esecuzione automatica: attiva, in pausa finché un amministratore non invia /alive",
+		"setting.readonly":              "  Sola lettura: %s",
+		"setting.voice":                 "  Risposte vocali: %s",
+		"setting.voice_unavailable":     "  Risposte vocali: non disponibili (nessun TTS configurato)",
+		"setting.overrides":             "  Impostazioni personalizzate: %s",
+		"setting.overrides_none":        "  Impostazioni personalizzate: nessuna",
+		"setting.vars":                  "  Variabili: %d",
+		"setting.history":               "  Voci in questa sessione: %d",
+		"setting.tunnels_none":          "  Nessun tunnel avviato da questa chat.",
+		"setting.guardrails":            "  Profilo: %s (disponibili: %s)",
+		"setting.postprocess":           "  Post-processori: %d",
+		"setting.typing":                "  Indicatore di scrittura: attivo (ogni %s)",
+		"setting.typing_off":            "  Indicatore di scrittura: disattivo",
+		"setting.output_diff":           "  Differenze dell'output: %s",
+		"setting.plain":                 "  Modalità testo semplice: %s",
+		"setting.keyboard":              "  Tastiera di risposta: %s",
+		"setting.frozen":                "  Chat sospese: %d",
+		"setting.shares":                "  Condivisa con: %s",
+		"setting.shares_none":           "  Questa sessione non è condivisa.",
+		"allow.exists":                  "La chat %d ha già accesso.",
+		"allow.save_failed":             "Salvataggio della lista di accesso non riuscito: %v",
+		"allow.done":                    "✅ La chat %d ora può usare il bot.",
+		"allow.notice":                  "Ora hai accesso a questo bot. Invia /start per iniziare.",
+		"allow.title":                   "Chat autorizzate\n\nALLOWED_CHAT_IDS:\n",
+		"allow.revoked_entry":           "  %d (revocata il %s%s)\n",
+		"allow.none":                    "  (nessuna)\n",
+		"allow.added":                   "\nAggiunte con /allow:\n",
+		"allow.admins":                  "\nAmministratori:",
+		"revoke.admin":                  "Le chat degli amministratori hanno sempre accesso; rimuovile da ADMIN_CHAT_IDS.",
+		"revoke.none":                   "La chat %d non ha accesso.",
+		"revoke.done":                   "🚫 La chat %d non può più usare il bot.",
+		"inventory.admin_only":          "Solo le chat degli amministratori possono modificare l'inventario.",
+		"inventory.none_tagged":         "Nessun host con il tag %s.",
+		"inventory.empty":               "L'inventario è vuoto.\n\nUsa /inventory add <nome> <indirizzo> [tags=a,b] [services=...] per aggiungere un host.",
+		"inventory.list":                "Inventario (%d host):\n",
+		"inventory.unknown":             "Nessun host %q nell'inventario.",
+		"inventory.show":                "%s\n\nAggiunto il %s.",
+		"inventory.add_failed":          "Aggiunta dell'host non riuscita: %v",
+		"inventory.added":               "Aggiunto %s.\n\nLe nuove sessioni ricevono l'inventario; in una sessione in corso, nomina l'host.",
+		"inventory.rm_failed":           "Rimozione dell'host non riuscita: %v",
+		"inventory.removed":             "Rimosso %s.",
+		"var.none":                      "Nessuna variabile impostata.\n\nUsa /var set <NOME> <valore>, poi scrivi {{NOME}} nei messaggi e nei comandi.",
+		"var.list":                      "Variabili:\n",
+		"var.set_usage":                 "Uso: /var set <NOME> <valore>",
+		"var.set_failed":                "Impostazione della variabile non riuscita: %v",
+		"var.set":                       "Impostata {{%s}}.",
+		"var.unset_usage":               "Uso: /var unset <NOME>",
+		"var.unset_failed":              "Rimozione della variabile non riuscita: %v",
+		"var.unknown":                   "Nessuna variabile {{%s}}.",
+		"var.removed":                   "Rimossa {{%s}}.",
+		"readonly.state":                "La modalità sola lettura è %s.\n\nUso: /readonly on|off",
+		"readonly.state_off":            "disattiva: i comandi approvati vengono eseguiti",
+		"readonly.state_emergency":      "attiva per tutte le chat finché un amministratore non invia /resume (arresto di emergenza)",
+		"readonly.state_global":         "attiva per tutte le chat (READ_ONLY): i comandi vengono suggeriti ma mai eseguiti",
+		"readonly.state_on":             "attiva: i comandi vengono suggeriti ma mai eseguiti",
+		"readonly.admin_only":           "Solo le chat degli amministratori possono disattivare la sola lettura o cambiarla per un'altra chat.",
+		"readonly.global":               "READ_ONLY è impostato per tutte le chat; non si può disattivare per una sola.",
+		"readonly.emergency":            "Il bot è in arresto di emergenza; un amministratore deve inviare /resume.",
+		"readonly.save_failed":          "Salvataggio dell'impostazione di sola lettura non riuscito: %v",
+		"readonly.off":                  "Sola lettura disattivata: i comandi approvati vengono di nuovo eseguiti. Inizio una nuova sessione.",
+		"readonly.on":                   "🔒 Sola lettura attiva: l'AI può suggerire comandi, ma nessuno viene eseguito. Inizio una nuova sessione.",
+		"readonly.other":                "Sola lettura per la chat %d: %s.",
+		"selection.unknown":             "Selezione sconosciuta.",
+		"model.switched":                "Modello cambiato!",
+		"sgx.request_button":            "Richiedi un'eccezione",
+		"sgx.blocked":                   "Bloccato dalla regola di sicurezza %s. Se il comando è legittimo, chiedi all'amministratore di consentirlo.",
+		"sgx.invalid":                   "Richiesta non valida.",
+		"sgx.expired":                   "Questa richiesta è scaduta; esegui di nuovo il comando per ottenerne una nuova.",
+		"sgx.request_card":              "🛡 Richiesta di eccezione di sicurezza\n\nChat: %d%s\nRegola: %s\n%s\n\nComando:\n%s\n\nConsentirla fa passare esattamente questo comando attraverso questa regola, per tutte le chat.",
+		"sgx.allow_button":              "Consenti il comando esatto",
+		"sgx.reject_button":             "Rifiuta",
+		"sgx.sent":                      "Inviata all'amministratore",
+		"sgx.requested":                 "Eccezione alla regola %s richiesta; ti verrà detto quando l'amministratore decide.",
+		"sgx.admin_only":                "Solo gli amministratori possono decidere le eccezioni.",
+		"sgx.decided":                   "Già deciso.",
+		"sgx.save_failed":               "Salvataggio dell'eccezione non riuscito.",
+		"sgx.allowed":                   "Consentito",
+		"sgx.allowed_card":              "✅ Consentito attraverso la regola %s:\n%s\n\nUsa /safeguard exceptions per rivedere o revocare.",
+		"sgx.allowed_notice":            "✅ L'amministratore ha consentito questo comando attraverso la regola %s; chiedi all'AI di eseguirlo di nuovo:\n%s",
+		"sgx.rejected":                  "Rifiutato",
+		"sgx.rejected_card":             "❌ Eccezione alla regola %s rifiutata:\n%s",
+		"sgx.rejected_notice":           "❌ L'amministratore ha mantenuto la regola %s per:\n%s",
+		"sgx.revoke_usage":              "Uso: /safeguard revoke <n> (vedi /safeguard exceptions)",
+		"sgx.revoke_failed":             "Revoca non riuscita: %v",
+		"sgx.revoked":                   "Revocata: la regola %s si applica di nuovo a:\n%s",
+		"sgx.none":                      "Nessuna eccezione di sicurezza.",
+		"sgx.list":                      "Eccezioni di sicurezza:\n",
+		"sgx.entry":                     "\n%d. regola %s, richiesta dalla chat %d, consentita il %s",
+		"sgx.entry_by":                  " da %s",
+		"sgx.list_footer":               "\nUsa /safeguard revoke <n> per rimuoverne una.",
+		"omodel.switched_unverified":    "Modello OpenRouter cambiato in %s (catalogo non disponibile, non verificato). Sessione azzerata.",
+		"omodel.fetch_failed":           "Impossibile ottenere l'elenco dei modelli OpenRouter: %v",
+		"omodel.switched":               "Modello OpenRouter cambiato in %s (%s). Sessione azzerata.",
+		"omodel.unknown":                "Modello OpenRouter %q sconosciuto. Usa /omodel per sfogliarli.",
+		"omodel.no_models":              "OpenRouter non ha restituito modelli.",
+		"omodel.choose_vendor":          "Modello OpenRouter attuale: `%s`\nScegli un fornitore:",
+		"omodel.no_match":               "Nessun modello OpenRouter corrispondente. Usa /omodel per elencare i fornitori.",
+		"omodel.title":                  "Modelli OpenRouter",
+		"omodel.title_vendor":           " di %s",
+		"omodel.title_price":            " fino a $%s per 1M di token in ingresso",
+		"omodel.title_cheapest":         " (i %d più economici su %d)",
+		"omodel.vendor":                 "Fornitore: %s",
+		"omodel.done":                   "✅ Passato a `%s`\nSessione azzerata: il prossimo messaggio ricomincia da capo.",
+		"price.cloud":                   "addebitato sul tuo account cloud",
+		"price.varies":                  "prezzo variabile",
+		"price.free":                    "gratis",
+		"price.per_million":             "$%s/$%s per 1M",
+		"models.context":                "contesto %s",
+		"models.title":                  "🤖 Modelli %s",
+		"models.matching":               " corrispondenti a %q",
+		"models.page":                   " (pagina %d/%d)",
+		"models.other_provider":         "Questa chat usa %s; scegliere un modello la fa passare a questo.\n",
+		"models.no_match":               "\nNessun modello corrispondente.",
+		"models.not_configured":         "\nNon configurato su questo server.",
+		"models.legend":                 "👁 visione · 🛠 uso di strumenti · 🧠 ragionamento",
+		"models.prev":                   "◀️ Prec",
+		"models.next":                   "Succ ▶️",
+		"models.list_failed":            "Impossibile elencare i modelli %s: %v",
+		"models.list_failed_short":      "Impossibile elencare i modelli.",
+		"models.not_configured_short":   "Non configurato.",
+		"models.not_switched":           "Non cambiato.",
+		"models.save_failed":            "Salvataggio del modello non riuscito: %v",
+		"models.selected":               "✅ Modello %s per questa chat: %s",
+		"models.switched_from":          "\nPassato da %s. Inizio una nuova sessione.",
+		"models.next_message":           "\nSi applica dal prossimo messaggio.",
+		"models.session_reset":          "\nSessione azzerata: il prossimo messaggio ricomincia da capo.",
+		"system.custom":                 "Prompt di sistema personalizzato per questa chat:\n\n%s\n\nLe regole di sicurezza vengono sempre aggiunte. Usa /system reset per tornare a quello predefinito.",
+		"system.default":                "Prompt di sistema predefinito (%s):\n\n%s\n\nUsa /system set <prompt> per cambiarlo per questa chat.",
+		"system.set_usage":              "Uso: /system set <prompt>",
+		"system.set_failed":             "Impostazione del prompt di sistema non riuscita: %v",
+		"system.reset_failed":           "Ripristino del prompt di sistema non riuscito: %v",
+		"system.updated":                "Prompt di sistema aggiornato. Si applica al prossimo messaggio con Gemini, OpenRouter, Azure e Bedrock, e alla prossima sessione Claude (usa /new per avviarne una).",
+		"system.already_default":        "Questa chat usa già il prompt di sistema predefinito.",
+		"system.reset":                  "Prompt di sistema ripristinato a quello predefinito. Si applica al prossimo messaggio con Gemini, OpenRouter, Azure e Bedrock, e alla prossima sessione Claude (usa /new per avviarne una).",
+		"postprocess.none":              "Nessun post-processore: le risposte dell'AI vengono inviate così come sono.\n\nVedi /help postprocess per aggiungerne uno.",
+		"postprocess.list":              "Post-processori, applicati in ordine alle risposte dell'AI:\n",
+		"postprocess.add_failed":        "Aggiunta del post-processore non riuscita: %v",
+		"postprocess.added":             "Aggiunto: %s",
+		"postprocess.remove_usage":      "Uso: /postprocess remove <n>",
+		"postprocess.remove_failed":     "Rimozione del post-processore non riuscita: %v",
+		"postprocess.removed":           "Rimosso: %s",
+		"postprocess.cleared":           "Post-processori rimossi: le risposte dell'AI vengono inviate così come sono.",
+		"config.title":                  "Impostazioni della chat:\n",
+		"config.entry":                  "\n%s = %s (predefinito %s)\n",
+		"config.entry_default":          "\n%s = %s (predefinito)\n",
+		"config.footer":                 "\nUsa /config set <chiave> <valore> o /config reset <chiave|all>.",
+		"config.set_usage":              "Uso: /config set <chiave> <valore>",
+		"config.unknown":                "Impostazione %q sconosciuta. Usa /config per elencarle.",
+		"config.invalid":                "%s non valido: %v",
+		"config.save_failed":            "Salvataggio delle impostazioni non riuscito: %v",
+		"config.reset_usage":            "Uso: /config reset <chiave|all>",
+		"config.reset":                  "%s riportato al valore predefinito del server.",
+		"fetch.bad_url":                 "Non è un URL http(s): %s",
+		"fetch.exists":                  "%s esiste già. Indica un altro nome: /fetchfile <url> <nome>",
+		"fetch.over_quota":              "Download del file non riuscito: %v. Elimina prima dei file.",
+		"fetch.downloading":             "📥 Download in %s...",
+		"fetch.failed":                  "Download del file non riuscito: %v",
+		"fetch.saved":                   "📥 Salvato %s (%s).",
+		"persona.title":                 "Personalità:\n",
+		"persona.footer":                "\nUsa /persona <nome> per cambiare, /persona off per il prompt predefinito.",
+		"persona.off":                   "Personalità disattivata: torno al prompt di sistema predefinito. Inizio una nuova sessione.",
+		"persona.unknown":               "Personalità %q sconosciuta. Usa /persona per elencarle.",
+		"persona.set":                   "Personalità impostata su %s (%s). Inizio una nuova sessione.",
+		"make.none":                     "Nessun target di Makefile o Taskfile trovato in %s.",
+		"make.choose":                   "Scegli un target da eseguire:",
+		"make.gone":                     "Il target non esiste più.",
+		"make.pending":                  "Approva o rifiuta prima il comando in sospeso.",
+		"make.selected":                 "Selezionato: %s",
+		"voice.state":                   "Le risposte vocali sono %s.\n\nUso: /voice on|off",
+		"voice.unavailable":             "Le risposte vocali richiedono TTS_CMD o TTS_API_URL sul server.",
+		"voice.on":                      "🔊 Risposte vocali attive: le risposte dell'AI vengono inviate anche come messaggi vocali.",
+		"voice.off":                     "🔇 Risposte vocali disattivate.",
+		"plain.state":                   "La modalità testo semplice è %s per questa chat.\n\nUso: /plain on|off",
+		"plain.on":                      "Modalità testo semplice attivata: niente formattazione né emoji, e il codice è delimitato dalle righe CODE START e CODE END.",
+		"plain.off":                     "Modalità testo semplice disattivata per questa chat.",
+		"outdiff.state":                 "Il confronto degli output è %s per questa chat.\n\nUso: /outdiff on|off",
+		"outdiff.on":                    "Confronto degli output attivato: un comando eseguito di nuovo mostra solo cosa è cambiato dall'ultima esecuzione.",
+		"outdiff.off":                   "Confronto degli output disattivato per questa chat.",
+		"autorun.state":                 "L'esecuzione automatica è %s.\n\nUso: /autorun on|off",
+		"autorun.state_off":             "disattivata: i comandi attendono l'approvazione",
+		"autorun.state_paused":          "attiva ma in pausa: i comandi attendono l'approvazione finché un amministratore non invia /alive",
+		"autorun.state_on":              "attiva: i comandi vengono eseguiti senza approvazione",
+		"autorun.admin_only":            "Solo le chat amministratore possono attivare l'esecuzione automatica o cambiarla per un'altra chat.",
+		"autorun.save_failed":           "Salvataggio dell'esecuzione automatica non riuscito: %v",
+		"autorun.off":                   "Esecuzione automatica disattivata: i comandi attendono di nuovo la tua approvazione. Inizio una nuova sessione.",
+		"autorun.on":                    "⚠️ Esecuzione automatica attiva: i comandi vengono eseguiti senza approvazione (i controlli di sicurezza bloccano comunque quelli pericolosi). Inizio una nuova sessione.",
+		"autorun.other":                 "Esecuzione automatica per la chat %d: %s.",
+		"pending.none":                  "Nessun comando in sospeso.",
+		"status.ai":                     "AI: %s",
+		"status.session":                "\nSessione: %s",
+		"status.session_new":            "nuova",
+		"status.session_resumed":        "ripresa",
+		"status.session_messages":       "%d messaggi",
+		"status.project":                "\nProgetto: %s",
+		"status.pending":                "\nComando in sospeso %d/%d: %s",
+		"status.pending_none":           "\nComando in sospeso: nessuno",
+		"keyboard.state":                "La tastiera di risposta è %s per questa chat.\n\nAzioni: %s\n\nUso: /keyboard on|off|<azione>...",
+		"keyboard.removed":              "Tastiera di risposta rimossa.",
+		"keyboard.unknown":              "Azione %q sconosciuta. Azioni: %s",
+		"keyboard.enabled":              "Tastiera di risposta attivata. Usa /keyboard off per rimuoverla.",
+		"shutdown.draining":             "⏳ Il bot si sta riavviando. Prima completo la tua richiesta in corso…",
+		"shutdown.lost":                 "⚠️ Il bot si è riavviato prima che la tua richiesta finisse. Inviala di nuovo.",
+		"safeguard.report_invalid":      "Numero %q non valido. Uso: /safeguard report [N]",
+		"safeguard.report_none":         "Nessun verdetto di sicurezza registrato finora.",
+		"safeguard.report_caption":      "Ultimi %d verdetti di sicurezza dal %s: %d bloccati.",
+		"alert.blocked_approved":        "🚨 I controlli di sicurezza hanno bloccato un comando approvato\n\n",
+		"alert.blocked_auto":            "🚨 I controlli di sicurezza hanno bloccato un comando eseguito automaticamente\n\n",
+		"alert.chat":                    "Chat: %d\n",
+		"alert.approved_by":             "Approvato da: %s\n",
+		"alert.blocked_body":            "Provider: %s\nSessione: %s\nOra: %s\n\nComando:\n%s\n\nMotivo: %s",
+		"alert.no_session":              "(nessuna)",
+		"alert.prompt":                  "\n\nPrompt:\n%s",
+		"alert.frozen":                  "\n\nLa chat è stata congelata in attesa della tua revisione.",
+		"alert.unfreeze_button":         "Scongela la chat",
+		"alert.unauth_title":            "🚷 Una chat non autorizzata ha contattato il bot\n\n",
+		"alert.unauth_chat":             "Chat: %d",
+		"alert.unauth_from":             "\nDa: %s",
+		"alert.unauth_time":             "\nOra: %s\n\n",
+		"alert.unauth_message":          "Messaggio:\n%s\n\n",
+		"alert.unauth_footer":           "Usa /allow %d per darle accesso. Gli altri contatti da questa chat nell'ora successiva non vengono segnalati.",
+		"alert.rounds_stopped":          "Interrotto: troppi giri di comandi.",
+		"alert.rounds_title":            "⚠️ L'esecuzione automatica ha raggiunto il limite di giri\n\n",
+		"alert.rounds_body":             "Chat: %d\nProvider: %s\nGiri: %d\nOra: %s",
+		"alert.rounds_pending":          "\n\nNon eseguiti:\n%s",
+		"testgate.running":              "Eseguo i test prima di %q: %s",
+		"approval.interrupted":          "Interrotto da un riavvio: %s",
+		"sla.card":                      "⏰ Approvazione in attesa da %s nella chat %d (%s)\n\nComando %d/%d:\n%s",
+		"sla.escalated":                 "⏰ Il comando in sospeso attende da %s; è stato inoltrato alla chat %s.",
+		"breaker.switched":              "⚠️ %s sta fallendo ripetutamente, quindi questa chat è passata a %s. Usa /%s per tornare indietro più tardi.",
+		"history.none":                  "Ancora nessuna cronologia in questa sessione.",
+		"checkpoint.none_undo":          "Nessun checkpoint da annullare. I checkpoint vengono creati prima dei comandi approvati che modificano file in un repository git.",
+		"checkpoint.restore_failed":     "Ripristino del checkpoint #%d non riuscito: %v",
+		"checkpoint.restored":           "Tornato al checkpoint #%d in %s (creato prima di: %s).",
+		"checkpoint.none":               "Ancora nessun checkpoint.",
+		"checkpoint.title":              "Checkpoint (dal più recente):\n",
+		"checkpoint.footer":             "\n/undo ripristina il più recente.",
+		"alive.paused":                  "⏸ Esecuzione automatica in pausa: nessun /alive in %s. I comandi attendono l'approvazione finché un amministratore non invia /alive.",
+		"alive.reminder":                "⏳ Invia /alive prima del %s per mantenere attiva l'esecuzione automatica.",
+		"alive.unset":                   "ALIVE_PERIOD non è impostato: l'esecuzione automatica non richiede /alive.",
+		"alive.save_failed":             "Salvataggio del check-in non riuscito: %v",
+		"alive.noted":                   "✅ Preso nota. Invia di nuovo /alive prima del %s.",
+		"alive.resumed":                 "▶️ Esecuzione automatica ripresa. ✅ Preso nota. Invia di nuovo /alive prima del %s.",
+		"stream.stop":                   "⏹ Ferma",
+		"stream.nothing":                "Niente da fermare.",
+		"stream.stopping":               "Interruzione…",
+		"export.none":                   "Ancora niente da esportare in questa sessione.",
+		"export.failed":                 "Esportazione non riuscita.",
+		"guardrails.title":              "Profili di guardrail:\n",
+		"guardrails.footer":             "\nUsa /guardrails <profilo> per cambiare.",
+		"guardrails.unknown":            "Profilo %q sconosciuto. Usa /guardrails per elencare i profili.",
+		"guardrails.set":                "Profilo di guardrail impostato su %s. I controlli sulle risposte valgono da subito; usa /new perché anche Claude parta con le nuove istruzioni.",
+		"reply.shortened":               "\n\n✂️ Accorciata per reply_chars; la risposta completa è allegata.",
+		"reply.full_caption":            "Risposta completa (%d caratteri)",
+		"stats.none":                    "Nessuna chiamata ai provider registrata finora.",
+		"stats.title":                   "Statistiche dei provider:\n",
+		"stats.calls":                   "  Chiamate: %d\n",
+		"stats.errors":                  "  Errori: %d (%.1f%%)",
+		"stats.latency":                 "  Latenza p50/p90/p99: %s / %s / %s\n",
+		"stats.tokens":                  "  Token/min (ultima ora): %.0f (minuto di picco 24h: %d)\n",
+		"stats.total":                   "  Token totali: %d\n",
+		"costrouting.on":                "💸 Instradamento per costo attivo (%s): le chiamate usano i COST_ROUTING_MODELS più economici.",
+		"costrouting.off":               "💸 Instradamento per costo disattivato: le chiamate usano di nuovo i modelli configurati.",
+		"export.caption":                "Esportazione della conversazione (%d voci)",
+		"approvals.none":                "Nessuna decisione di approvazione registrata finora.",
+		"approvals.title":               "Statistiche delle approvazioni:\n",
+		"approvals.decisions":           "  Decisioni: %d\n",
+		"approvals.wait":                "  Attesa p50/p90/p99: %s / %s / %s\n",
+		"approvals.escalations":         "  Inoltri (SLA %s): %d, %d decisi in seguito\n",
+		"approvals.escalation_off":      "  Inoltro: disattivato (imposta APPROVAL_SLA)\n",
+		"tooltrace.title":               "Passaggi di Claude:\n",
+		"tooltrace.earlier":             "… %d precedenti\n",
+		"usage.title":                   "📊 Rapporto di utilizzo %s → %s\n\n",
+		"usage.report_none":             "Nessuna chiamata all'AI in questo periodo.",
+		"usage.total":                   "Totale: %d chiamate, %d token in ingresso / %d in uscita, $%.4f\n\nPer chat:\n",
+		"usage.chat":                    "  %d: %d chiamate, %d token in ingresso / %d in uscita, $%.4f\n",
+	},
+	"es": {
+		"admin_only":               "Este comando está reservado a los administradores.",
+		"unauthorized":             "No autorizado. Tu chat ID: %d",
+		"frozen":                   "Este chat está congelado hasta que lo revise un administrador. Las llamadas a la IA y la ejecución de comandos están suspendidas.",
+		"frozen.callback":          "Chat congelado pendiente de revisión.",
+		"quota_exceeded":           "Has agotado tu cuota diaria de IA (%s). Se reinicia a medianoche.",
+		"restarting":               "El bot se está reiniciando. Vuelve a enviarlo en un minuto.",
+		"error":                    "Error: %v",
+		"session_reset":            "Sesión reiniciada. Tu próximo mensaje empezará una conversación nueva.",
+		"usage_line":               "Uso: %s\n\n%s.",
+		"help.title":               "AI Code Bot — Comandos:",
+		"help.admin":               " (admin)",
+		"help.footer":              "Envía cualquier mensaje de texto y lo reenviaré a la IA activa. Cuando la IA proponga un comando, verás los botones Aprobar/Rechazar. El contexto de la conversación se mantiene hasta que uses /new.\n\nUsa /help <comando> para ver detalles, ejemplos y la configuración de este chat.",
+		"help.unknown":             "Comando desconocido /%s. Usa /help para ver los comandos.",
+		"approval.card":            "Comando %d/%d:\n`%s`",
+		"approval.approve":         "Aprobar",
+		"approval.deny":            "Rechazar",
+		"approval.approved":        "Aprobado",
+		"approval.denied":          "Rechazado",
+		"approval.ran":             "Aprobado: %s",
+		"approval.skipped":         "Rechazado: %s",
+		"approval.by":              "%s (por %s)",
+		"approval.none":            "No hay ningún comando pendiente.",
+		"approval.risky":           "\n\n⚠️ %s. Para ejecutarlo, responde con `%s`",
+		"approval.type_phrase":     "Comando arriesgado: responde con %s para ejecutarlo.",
+		"approval.confirm_pending": "El comando pendiente es arriesgado. Responde con `%s` para ejecutarlo, o pulsa Rechazar.",
+		"media.too_big":            "Los archivos enviados en el chat pueden ocupar como máximo %s; este es más grande.",
+		"media.fetch_hint":         "\n\nSúbelo a un sitio accesible y envía /fetchfile <url> para descargarlo en el espacio de trabajo.",
+		"output.truncated":         "\n... (recortado en el chat)",
+		"readonly.suggest":         "🔒 Modo solo lectura: los comandos sugeridos no se ejecutan.\n",
+		"usage.none":               "Aún no hay datos de uso. ¡Envía algunos mensajes primero!",
+		"usage.session":            "Uso de la sesión",
+		"usage.lifetime":           "Uso total (desde %s)",
+		"usage.body":               "%s:\n  Llamadas: %d\n  Tokens de entrada: %d\n  Tokens de salida: %d\n  Coste: $%.4f\n  Duración: %s\n  Última llamada: hace %s",
+		"usage.per_user":           "Por usuario:",
+		"lang.current":             "Los mensajes del bot están en %s.\n\nDisponibles: %s\n\nUso: /lang <código> o /lang default",
+		"lang.set":                 "Los mensajes del bot ahora están en %s.",
+		"lang.unknown":             "Idioma desconocido %q. Disponibles: %s",
+
+		"cmd.start":                     "Mensaje de bienvenida",
+		"cmd.new":                       "Reiniciar la sesión (conversación nueva)",
+		"cmd.claude":                    "Cambiar la IA activa a Claude",
+		"cmd.gemini":                    "Cambiar la IA activa a Gemini",
+		"cmd.model":                     "Mostrar la IA y el modelo activos",
+		"cmd.login":                     "Iniciar sesión en la IA activa (OAuth de Claude / clave API de Gemini u OpenRouter)",
+		"cmd.usage":                     "Ver el uso de la sesión, o los totales del chat",
+		"cmd.project":                   "Gestionar los proyectos de este chat",
+		"cmd.system":                    "Ver o cambiar el prompt de sistema del chat",
+		"cmd.undo":                      "Restaurar los archivos al último checkpoint",
+		"cmd.summarize":                 "Resumir la sesión para ahorrar contexto",
+		"cmd.lang":                      "Ver o cambiar el idioma de los mensajes del bot",
+		"cmd.help":                      "Mostrar esta ayuda, o los detalles de un comando",
+		"freeze.none":                   "No hay chats congelados.\n\nUso: /freeze <chatID> [motivo]",
+		"freeze.list":                   "Chats congelados:",
+		"freeze.entry":                  "  %d — %s (desde %s)",
+		"freeze.already":                "El chat %d ya está congelado.",
+		"freeze.done":                   "Chat %d congelado. Sus sesiones se conservan; usa /unfreeze %d para restaurarlo.",
+		"freeze.notice":                 "Un administrador ha congelado este chat pendiente de revisión. Las llamadas a la IA y la ejecución de comandos están suspendidas; la conversación se conserva y continuará cuando se descongele.",
+		"unfreeze.not_frozen":           "El chat %d no está congelado.",
+		"unfreeze.done":                 "Chat %d descongelado.",
+		"unfreeze.notice":               "Un administrador ha revisado y descongelado este chat. Puedes continuar.",
+		"unfreeze.admin_only":           "Solo los administradores pueden descongelar.",
+		"unfreeze.invalid":              "Chat ID no válido.",
+		"unfreeze.callback_not_frozen":  "El chat no está congelado.",
+		"unfreeze.callback":             "Descongelado",
+		"unfreeze.card":                 "✅ Chat %d descongelado.",
+		"approval.pending_first":        "Primero aprueba o rechaza el comando pendiente.",
+		"not_allowed_chat":              "El chat %d no es un chat permitido.",
+		"save_failed":                   "No se pudo guardar: %v",
+		"prompt_expired":                "⌛ No se procesó tu %s de hace %s porque el bot estaba ocupado. Reenvíalo si sigue siendo relevante.",
+		"kind.message":                  "mensaje",
+		"kind.photo":                    "foto",
+		"kind.voice_message":            "mensaje de voz",
+		"kind.audio_file":               "archivo de audio",
+		"kind.file":                     "archivo",
+		"kind.sticker":                  "sticker",
+		"kind.gif":                      "GIF",
+		"kind.video":                    "vídeo",
+		"kind.video_note":               "videomensaje",
+		"start":                         "¡Bienvenido a AI Code Bot!\n\nEnvíame cualquier mensaje y lo reenviaré a Claude (predeterminado) o a Gemini.\nLos comandos requieren tu aprobación antes de ejecutarse.\nUsa /new para empezar una conversación nueva, /help para ver los comandos, o:\n  /claude — cambiar a Claude\n  /gemini — cambiar a Gemini\n  /model  — mostrar la IA activa",
+		"ask.unknown":                   "Selección desconocida.",
+		"ask.expired":                   "Esta pregunta ha caducado.",
+		"ask.expired_card":              "❓ Esta pregunta ha caducado; responde con tus propias palabras.",
+		"ask.saved":                     "Respuesta guardada.",
+		"ask.sent":                      "Respuesta enviada.",
+		"panic.cancelled":               "🛑 Parada de emergencia: un administrador canceló el comando pendiente.",
+		"panic.done":                    "🛑 Parada de emergencia. Se cancelaron las llamadas a la IA y los comandos en curso, se terminaron %d comandos en segundo plano, se cerraron los túneles y se descartaron %d aprobaciones pendientes.\n\nTodos los chats quedan en solo lectura hasta que un administrador envíe /resume.",
+		"resume.not_stopped":            "El bot no está detenido.",
+		"resume.done":                   "▶️ Reanudado: los comandos aprobados vuelven a ejecutarse.",
+		"resume.read_only":              "▶️ Reanudado. READ_ONLY sigue activo, así que los comandos siguen desactivados.",
+		"share.none":                    "Esta sesión no está compartida.\n\nUso: /share <chatID> [readonly|collab]",
+		"share.list":                    "Sesión compartida con:",
+		"share.unknown_mode":            "Modo %q desconocido. Usa readonly o collab.",
+		"share.done":                    "Sesión compartida con el chat %d (%s). Usa /unshare %d para revocarla.",
+		"share.collab_notice":           "Se te ha dado acceso colaborativo a la sesión del chat %d. Tus mensajes van a esa sesión y puedes aprobar o rechazar sus comandos.",
+		"share.readonly_notice":         "Se te ha dado acceso de solo lectura a la sesión del chat %d. Verás las respuestas de la IA y las solicitudes de aprobación.",
+		"unshare.not_shared":            "La sesión no está compartida con el chat %d.",
+		"unshare.done":                  "El chat %d ya no tiene acceso a esta sesión.",
+		"unshare.notice":                "Se ha revocado tu acceso a la sesión del chat %d.",
+		"as.error":                      "🔍 Como chat %d (%s): error: %v",
+		"as.reply":                      "🔍 Como chat %d (%s, proyecto %s):\n\n%s",
+		"as.proposed":                   "Comandos propuestos (no ejecutados):",
+		"typing.global_off":             "Los indicadores de escritura están desactivados para todos los chats (TYPING_INTERVAL=0).",
+		"typing.state_on":               "Los indicadores de escritura están activados para este chat (cada %s).\n\nUso: /typing on|off",
+		"typing.state_off":              "Los indicadores de escritura están desactivados para este chat (cada %s si se activan).\n\nUso: /typing on|off",
+		"typing.enabled":                "Indicadores de escritura activados para este chat.",
+		"typing.disabled":               "Indicadores de escritura desactivados para este chat.",
+		"tunnel.invalid_port":           "Puerto %q no válido.",
+		"tunnel.failed":                 "No se pudo iniciar el túnel: %v",
+		"tunnel.open":                   "🌐 Túnel abierto: %s → localhost:%d\n\nDetenlo con /tunnel stop %d",
+		"tunnel.none_matching":          "No hay ningún túnel coincidente en marcha.",
+		"tunnel.stopped":                "Túneles detenidos: %d.",
+		"tunnel.none":                   "No hay túneles en marcha.\n\nUso: /tunnel start <puerto>",
+		"tunnel.list":                   "Túneles:",
+		"tunnel.entry":                  "  %s → localhost:%d (activo desde hace %s)",
+		"project.list":                  "Proyectos:",
+		"project.list_footer":           "Usa /project switch <nombre> o /project add <nombre> <ruta>.",
+		"project.add_usage":             "Uso: /project add <nombre> <ruta>\n\nLas rutas relativas se resuelven respecto a WORK_DIR.",
+		"project.add_failed":            "No se pudo añadir el proyecto: %v",
+		"project.added":                 "Proyecto %s añadido en %s. Usa /project switch %s para activarlo.",
+		"project.switch_usage":          "Uso: /project switch <nombre>",
+		"project.switch_failed":         "No se pudo cambiar: %v",
+		"project.switched":              "Cambiado al proyecto %s (%s). Empezando una sesión nueva.",
+		"summarize.empty":               "Todavía no hay nada que resumir.",
+		"summarize.failed":              "No se pudo resumir: %v",
+		"summarize.seed_failed":         "No se pudo iniciar la sesión resumida (se conserva la sesión anterior): %v",
+		"summarize.done":                "Sesión comprimida: contexto ~%d → ~%d tokens%s.",
+		"summarize.smaller":             " (%d%% más pequeño)",
+		"approval.sla_confirm":          "\n\n⚠️ Este comando es arriesgado: solo se ejecuta cuando se escribe su frase de confirmación en el chat %d. Aun así puedes rechazarlo aquí.",
+		"readonly.disabled":             "🔒 Modo de solo lectura: /%s está desactivado.",
+		"callback.not_owner":            "Solo el miembro al que pertenece esta conversación puede usar estos botones.",
+		"safeguard.blocked":             "BLOQUEADO: %s",
+		"safeguard.allowed":             "PERMITIDO: el comando '%s' pasaría los controles de seguridad.",
+		"safeguard.frozen":              "Los controles de seguridad bloquearon un comando. Este chat queda congelado hasta que lo revise un administrador.",
+		"usage.provider":                "\n\n%s: %d llamadas, %d tokens de entrada / %d de salida, %s",
+		"usage.cost_estimate":           "~%s estimados",
+		"usage.cost_cloud":              "coste facturado en tu cuenta de nube",
+		"provider.already":              "Ya estás usando %s.",
+		"provider.switched":             "Cambiado a %s. Empiezo una sesión nueva.",
+		"provider.azure_hint":           "Azure OpenAI se configura en el servidor, no en el chat.\n\nDefine AZURE_OPENAI_ENDPOINT, AZURE_OPENAI_DEPLOYMENT y AZURE_OPENAI_API_KEY (y opcionalmente AZURE_OPENAI_API_VERSION) y reinicia el bot.",
+		"provider.bedrock_hint":         "Bedrock se configura en el servidor, no en el chat.\n\nDefine BEDROCK_REGION, BEDROCK_MODEL_ID, AWS_ACCESS_KEY_ID y AWS_SECRET_ACCESS_KEY (más AWS_SESSION_TOKEN para credenciales temporales) y reinicia el bot. Las credenciales necesitan bedrock:InvokeModel sobre el modelo.",
+		"model.current":                 "IA actual: %s (modelo: %s)",
+		"model.current_azure":           "IA actual: %s (deployment: %s, api-version %s)",
+		"model.current_bedrock":         "IA actual: %s (modelo: %s, región %s)",
+		"model.hint_models":             "\n\nUsa /models para comparar y cambiar de modelo.",
+		"model.hint_omodel":             "\n\nUsa /models o /omodel para explorar los modelos de OpenRouter.",
+		"model.hint_cmodel":             "\n\nUsa /cmodel para cambiar de modelo de Claude.",
+		"model.cli_default":             "predeterminado de la CLI",
+		"model.cost_routing":            "\n\n💸 Enrutamiento por coste: las llamadas usan %s (%s).",
+		"cmodel.opus":                   "🧠 Opus (el más capaz)",
+		"cmodel.sonnet":                 "⚖️ Sonnet (equilibrado)",
+		"cmodel.haiku":                  "⚡ Haiku (rápido)",
+		"cmodel.set":                    "Modelo de Claude: %s. Se aplica desde el próximo mensaje.",
+		"cmodel.choose":                 "Modelo de Claude actual: `%s`\nElige un modelo:",
+		"cmodel.done":                   "✅ Modelo de Claude: `%s`\nSe aplica desde el próximo mensaje.",
+		"share.forwarded":               "💬 Desde el chat compartido %d:\n%s",
+		"media.transcribe_voice_failed": "No se pudo transcribir el mensaje de voz. Revisa el backend de transcripción (WHISPER_BACKEND).",
+		"media.transcribe_audio_failed": "No se pudo transcribir el audio. Revisa el backend de transcripción (WHISPER_BACKEND).",
+		"media.download_failed":         "No se pudo descargar %s: %v",
+		"login.setup_failed":            "Falló la preparación del inicio de sesión en %s: %v",
+		"login.failed":                  "Falló el inicio de sesión: %v",
+		"login.failed_retry":            "Falló el inicio de sesión: %v\nInténtalo de nuevo con /login.",
+		"login.url":                     "Abre esta URL para iniciar sesión con tu cuenta de Google:\n\n%s\n\nTras autenticarte recibirás un código de autorización.\nPégalo aquí como tu próximo mensaje (antes de %s).",
+		"login.empty":                   "Mensaje vacío. Inténtalo de nuevo enviando otro mensaje.",
+		"login.verifying_key":           "Verificando la clave API...",
+		"login.success":                 "¡Sesión iniciada! Ya puedes enviar mensajes a %s.",
+		"login.success_retry":           "¡Sesión iniciada! Procesando tu mensaje...",
+		"login.key_gemini":              "Para usar Gemini necesitas una clave API gratuita de Google AI Studio.\n\n1. Abre: https://aistudio.google.com/apikey\n2. Pulsa \"Create API key\"\n3. Copia la clave y pégala aquí como tu próximo mensaje.",
+		"login.key_openrouter":          "Para usar OpenRouter necesitas una clave API.\n\n1. Abre: https://openrouter.ai/settings/keys\n2. Pulsa \"Create Key\"\n3. Copia la clave y pégala aquí como tu próximo mensaje.",
+		"login.starting":                "Claude no ha iniciado sesión. Iniciando el inicio de sesión OAuth...",
+		"login.exchanging":              "Verificando el código de autorización...",
+		"login.verifying":               "La pantalla de inicio de sesión no se cerró; compruebo que Claude haya iniciado sesión...",
+		"login.cancelled":               "Inicio de sesión cancelado: %v\nUsa /login para empezar de nuevo.",
+		"login.restarted_key":           "El bot se reinició mientras esperaba tu clave API.",
+		"login.restarted_claude":        "El bot se reinició durante el inicio de sesión de Claude, así que la URL que envió ya no funciona.",
+		"reply.empty":                   "(respuesta vacía)",
+		"autorun.paused_commands":       "⏸ La ejecución automática está en pausa hasta que un administrador envíe /alive; aprueba los comandos de abajo.",
+		"autorun.paused_command":        "⏸ La ejecución automática está en pausa hasta que un administrador envíe /alive; aprueba el comando de abajo.",
+		"autorun.running":               "Ejecutando: %s",
+		"approval.not_escalation":       "No es un chat de escalado.",
+		"state.on":                      "activado",
+		"state.off":                     "desactivado",
+		"args.invalid":                  "Argumentos no válidos: %v.",
+		"args.invalid_usage":            "Argumentos no válidos: %v.\n\nUso: %s",
+		"help.admin_only":               " Solo administradores.",
+		"help.examples":                 "\n\nEjemplos:\n",
+		"help.this_chat":                "\n\nEste chat:\n",
+		"setting.provider":              "  IA activa: %s",
+		"setting.provider_model":        "  IA activa: %s (modelo %s)",
+		"setting.project":               "  Proyecto: %s (%s)",
+		"setting.workspace":             "\n  Espacio de trabajo: %s usados, %s libres",
+		"setting.claude_model":          "  Modelo de Claude: %s",
+		"setting.system_prompt_custom":  "  Prompt de sistema: personalizado (%d bytes)",
+		"setting.system_prompt_default": "  Prompt de sistema: predeterminado",
+		"setting.persona":               "  Persona: %s",
+		"setting.persona_none":          "  Persona: ninguna",
+		"setting.autorun":               "  Ejecución automática: %s",
+		"setting.autorun_paused":        "  Ejecución automática: activada, en pausa hasta que un administrador envíe /alive",
+		"setting.readonly":              "  Solo lectura: %s",
+		"setting.voice":                 "  Respuestas de voz: %s",
+		"setting.voice_unavailable":     "  Respuestas de voz: no disponibles (no hay TTS configurado)",
+		"setting.overrides":             "  Ajustes propios: %s",
+		"setting.overrides_none":        "  Ajustes propios: ninguno",
+		"setting.vars":                  "  Variables: %d",
+		"setting.history":               "  Entradas en esta sesión: %d",
+		"setting.tunnels_none":          "  No se ha iniciado ningún túnel desde este chat.",
+		"setting.guardrails":            "  Perfil: %s (disponibles: %s)",
+		"setting.postprocess":           "  Posprocesadores: %d",
+		"setting.typing":                "  Indicador de escritura: activado (cada %s)",
+		"setting.typing_off":            "  Indicador de escritura: desactivado",
+		"setting.output_diff":           "  Diferencias de salida: %s",
+		"setting.plain":                 "  Modo texto plano: %s",
+		"setting.keyboard":              "  Teclado de respuesta: %s",
+		"setting.frozen":                "  Chats congelados: %d",
+		"setting.shares":                "  Compartida con: %s",
+		"setting.shares_none":           "  Esta sesión no está compartida.",
+		"allow.exists":                  "El chat %d ya tiene acceso.",
+		"allow.save_failed":             "No se pudo guardar la lista de acceso: %v",
+		"allow.done":                    "✅ El chat %d ya puede usar el bot.",
+		"allow.notice":                  "Ya tienes acceso a este bot. Envía /start para empezar.",
+		"allow.title":                   "Chats permitidos\n\nALLOWED_CHAT_IDS:\n",
+		"allow.revoked_entry":           "  %d (revocado el %s%s)\n",
+		"allow.none":                    "  (ninguno)\n",
+		"allow.added":                   "\nAñadidos con /allow:\n",
+		"allow.admins":                  "\nAdministradores:",
+		"revoke.admin":                  "Los chats de administradores siempre tienen acceso; quítalos de ADMIN_CHAT_IDS.",
+		"revoke.none":                   "El chat %d no tiene acceso.",
+		"revoke.done":                   "🚫 El chat %d ya no puede usar el bot.",
+		"inventory.admin_only":          "Solo los chats de administradores pueden cambiar el inventario.",
+		"inventory.none_tagged":         "No hay hosts con la etiqueta %s.",
+		"inventory.empty":               "El inventario está vacío.\n\nUsa /inventory add <nombre> <dirección> [tags=a,b] [services=...] para añadir un host.",
+		"inventory.list":                "Inventario (%d hosts):\n",
+		"inventory.unknown":             "No hay ningún host %q en el inventario.",
+		"inventory.show":                "%s\n\nAñadido el %s.",
+		"inventory.add_failed":          "No se pudo añadir el host: %v",
+		"inventory.added":               "Añadido %s.\n\nLas sesiones nuevas reciben el inventario; en una sesión en curso, nombra el host.",
+		"inventory.rm_failed":           "No se pudo quitar el host: %v",
+		"inventory.removed":             "Quitado %s.",
+		"var.none":                      "No hay variables definidas.\n\nUsa /var set <NOMBRE> <valor> y luego escribe {{NOMBRE}} en mensajes y comandos.",
+		"var.list":                      "Variables:\n",
+		"var.set_usage":                 "Uso: /var set <NOMBRE> <valor>",
+		"var.set_failed":                "No se pudo definir la variable: %v",
+		"var.set":                       "Definida {{%s}}.",
+		"var.unset_usage":               "Uso: /var unset <NOMBRE>",
+		"var.unset_failed":              "No se pudo quitar la variable: %v",
+		"var.unknown":                   "No existe la variable {{%s}}.",
+		"var.removed":                   "Quitada {{%s}}.",
+		"readonly.state":                "El modo de solo lectura está %s.\n\nUso: /readonly on|off",
+		"readonly.state_off":            "desactivado: los comandos aprobados se ejecutan",
+		"readonly.state_emergency":      "activado para todos los chats hasta que un administrador envíe /resume (parada de emergencia)",
+		"readonly.state_global":         "activado para todos los chats (READ_ONLY): los comandos se sugieren pero nunca se ejecutan",
+		"readonly.state_on":             "activado: los comandos se sugieren pero nunca se ejecutan",
+		"readonly.admin_only":           "Solo los chats de administradores pueden desactivar el modo de solo lectura o cambiarlo para otro chat.",
+		"readonly.global":               "READ_ONLY está definido para todos los chats; no se puede desactivar para uno solo.",
+		"readonly.emergency":            "El bot está en parada de emergencia; un administrador debe enviar /resume.",
+		"readonly.save_failed":          "No se pudo guardar el ajuste de solo lectura: %v",
+		"readonly.off":                  "Solo lectura desactivada: los comandos aprobados vuelven a ejecutarse. Empiezo una sesión nueva.",
+		"readonly.on":                   "🔒 Solo lectura activada: la IA puede sugerir comandos, pero no se ejecuta ninguno. Empiezo una sesión nueva.",
+		"readonly.other":                "Modo de solo lectura del chat %d: %s.",
+		"selection.unknown":             "Selección desconocida.",
+		"model.switched":                "¡Modelo cambiado!",
+		"sgx.request_button":            "Solicitar excepción",
+		"sgx.blocked":                   "Bloqueado por la regla de seguridad %s. Si el comando es legítimo, pide al administrador que lo permita.",
+		"sgx.invalid":                   "Solicitud no válida.",
+		"sgx.expired":                   "Esta solicitud caducó; vuelve a ejecutar el comando para obtener una nueva.",
+		"sgx.request_card":              "🛡 Solicitud de excepción de seguridad\n\nChat: %d%s\nRegla: %s\n%s\n\nComando:\n%s\n\nPermitirla deja pasar exactamente este comando por esta regla, en todos los chats.",
+		"sgx.allow_button":              "Permitir el comando exacto",
+		"sgx.reject_button":             "Rechazar",
+		"sgx.sent":                      "Enviada al administrador",
+		"sgx.requested":                 "Excepción a la regla %s solicitada; se te avisará cuando el administrador decida.",
+		"sgx.admin_only":                "Solo los administradores pueden decidir excepciones.",
+		"sgx.decided":                   "Ya decidida.",
+		"sgx.save_failed":               "No se pudo guardar la excepción.",
+		"sgx.allowed":                   "Permitido",
+		"sgx.allowed_card":              "✅ Permitido por la regla %s:\n%s\n\nUsa /safeguard exceptions para revisar o revocar.",
+		"sgx.allowed_notice":            "✅ El administrador permitió este comando por la regla %s; pide a la IA que lo vuelva a ejecutar:\n%s",
+		"sgx.rejected":                  "Rechazado",
+		"sgx.rejected_card":             "❌ Excepción a la regla %s rechazada:\n%s",
+		"sgx.rejected_notice":           "❌ El administrador mantuvo la regla %s para:\n%s",
+		"sgx.revoke_usage":              "Uso: /safeguard revoke <n> (ver /safeguard exceptions)",
+		"sgx.revoke_failed":             "No se pudo revocar: %v",
+		"sgx.revoked":                   "Revocada: la regla %s vuelve a aplicarse a:\n%s",
+		"sgx.none":                      "No hay excepciones de seguridad.",
+		"sgx.list":                      "Excepciones de seguridad:\n",
+		"sgx.entry":                     "\n%d. regla %s, solicitada por el chat %d, permitida el %s",
+		"sgx.entry_by":                  " por %s",
+		"sgx.list_footer":               "\nUsa /safeguard revoke <n> para quitar una.",
+		"omodel.switched_unverified":    "Modelo de OpenRouter cambiado a %s (catálogo no disponible, sin verificar). Sesión reiniciada.",
+		"omodel.fetch_failed":           "No se pudo obtener la lista de modelos de OpenRouter: %v",
+		"omodel.switched":               "Modelo de OpenRouter cambiado a %s (%s). Sesión reiniciada.",
+		"omodel.unknown":                "Modelo de OpenRouter %q desconocido. Usa /omodel para explorarlos.",
+		"omodel.no_models":              "OpenRouter no devolvió modelos.",
+		"omodel.choose_vendor":          "Modelo de OpenRouter actual: `%s`\nElige un proveedor:",
+		"omodel.no_match":               "No hay modelos de OpenRouter que coincidan. Usa /omodel para listar los proveedores.",
+		"omodel.title":                  "Modelos de OpenRouter",
+		"omodel.title_vendor":           " de %s",
+		"omodel.title_price":            " hasta $%s por 1M de tokens de entrada",
+		"omodel.title_cheapest":         " (los %d más baratos de %d)",
+		"omodel.vendor":                 "Proveedor: %s",
+		"omodel.done":                   "✅ Cambiado a `%s`\nSesión reiniciada: el próximo mensaje empieza de cero.",
+		"price.cloud":                   "facturado en tu cuenta de nube",
+		"price.varies":                  "precio variable",
+		"price.free":                    "gratis",
+		"price.per_million":             "$%s/$%s por 1M",
+		"models.context":                "contexto de %s",
+		"models.title":                  "🤖 Modelos de %s",
+		"models.matching":               " que coinciden con %q",
+		"models.page":                   " (página %d/%d)",
+		"models.other_provider":         "Este chat usa %s; elegir un modelo lo cambia.\n",
+		"models.no_match":               "\nNo hay modelos que coincidan.",
+		"models.not_configured":         "\nNo está configurado en este servidor.",
+		"models.legend":                 "👁 visión · 🛠 uso de herramientas · 🧠 razonamiento",
+		"models.prev":                   "◀️ Anterior",
+		"models.next":                   "Siguiente ▶️",
+		"models.list_failed":            "No se pudieron listar los modelos de %s: %v",
+		"models.list_failed_short":      "No se pudieron listar los modelos.",
+		"models.not_configured_short":   "No configurado.",
+		"models.not_switched":           "No se cambió.",
+		"models.save_failed":            "No se pudo guardar el modelo: %v",
+		"models.selected":               "✅ Modelo de %s para este chat: %s",
+		"models.switched_from":          "\nCambiado desde %s. Empiezo una sesión nueva.",
+		"models.next_message":           "\nSe aplica desde el próximo mensaje.",
+		"models.session_reset":          "\nSesión reiniciada: el próximo mensaje empieza de cero.",
+		"system.custom":                 "Prompt de sistema personalizado para este chat:\n\n%s\n\nLas reglas de seguridad siempre se añaden. Usa /system reset para volver al predeterminado.",
+		"system.default":                "Prompt de sistema predeterminado (%s):\n\n%s\n\nUsa /system set <prompt> para cambiarlo en este chat.",
+		"system.set_usage":              "Uso: /system set <prompt>",
+		"system.set_failed":             "No se pudo establecer el prompt de sistema: %v",
+		"system.reset_failed":           "No se pudo restablecer el prompt de sistema: %v",
+		"system.updated":                "Prompt de sistema actualizado. Se aplica al próximo mensaje con Gemini, OpenRouter, Azure y Bedrock, y a la próxima sesión de Claude (usa /new para iniciar una).",
+		"system.already_default":        "Este chat ya usa el prompt de sistema predeterminado.",
+		"system.reset":                  "Prompt de sistema restablecido al predeterminado. Se aplica al próximo mensaje con Gemini, OpenRouter, Azure y Bedrock, y a la próxima sesión de Claude (usa /new para iniciar una).",
+		"postprocess.none":              "No hay posprocesadores: las respuestas de la IA se envían tal cual.\n\nConsulta /help postprocess para añadir uno.",
+		"postprocess.list":              "Posprocesadores, aplicados en orden a las respuestas de la IA:\n",
+		"postprocess.add_failed":        "No se pudo añadir el posprocesador: %v",
+		"postprocess.added":             "Añadido: %s",
+		"postprocess.remove_usage":      "Uso: /postprocess remove <n>",
+		"postprocess.remove_failed":     "No se pudo quitar el posprocesador: %v",
+		"postprocess.removed":           "Quitado: %s",
+		"postprocess.cleared":           "Posprocesadores eliminados: las respuestas de la IA se envían tal cual.",
+		"config.title":                  "Ajustes del chat:\n",
+		"config.entry":                  "\n%s = %s (predeterminado %s)\n",
+		"config.entry_default":          "\n%s = %s (predeterminado)\n",
+		"config.footer":                 "\nUsa /config set <clave> <valor> o /config reset <clave|all>.",
+		"config.set_usage":              "Uso: /config set <clave> <valor>",
+		"config.unknown":                "Ajuste %q desconocido. Usa /config para listarlos.",
+		"config.invalid":                "%s no válido: %v",
+		"config.save_failed":            "No se pudieron guardar los ajustes: %v",
+		"config.reset_usage":            "Uso: /config reset <clave|all>",
+		"config.reset":                  "%s restablecido al valor predeterminado del servidor.",
+		"fetch.bad_url":                 "No es una URL http(s): %s",
+		"fetch.exists":                  "%s ya existe. Indica otro nombre: /fetchfile <url> <nombre>",
+		"fetch.over_quota":              "No se pudo descargar el archivo: %v. Borra archivos primero.",
+		"fetch.downloading":             "📥 Descargando en %s...",
+		"fetch.failed":                  "No se pudo descargar el archivo: %v",
+		"fetch.saved":                   "📥 Guardado %s (%s).",
+		"persona.title":                 "Personalidades:\n",
+		"persona.footer":                "\nUsa /persona <nombre> para cambiar, /persona off para el prompt predeterminado.",
+		"persona.off":                   "Personalidad desactivada: vuelvo al prompt de sistema predeterminado. Empiezo una sesión nueva.",
+		"persona.unknown":               "Personalidad %q desconocida. Usa /persona para listarlas.",
+		"persona.set":                   "Personalidad establecida en %s (%s). Empiezo una sesión nueva.",
+		"make.none":                     "No se encontraron objetivos de Makefile o Taskfile en %s.",
+		"make.choose":                   "Elige un objetivo para ejecutar:",
+		"make.gone":                     "El objetivo ya no existe.",
+		"make.pending":                  "Aprueba o rechaza primero el comando pendiente.",
+		"make.selected":                 "Seleccionado: %s",
+		"voice.state":                   "Las respuestas de voz están %s.\n\nUso: /voice on|off",
+		"voice.unavailable":             "Las respuestas de voz necesitan TTS_CMD o TTS_API_URL en el servidor.",
+		"voice.on":                      "🔊 Respuestas de voz activadas: las respuestas de la IA también se envían como notas de voz.",
+		"voice.off":                     "🔇 Respuestas de voz desactivadas.",
+		"plain.state":                   "El modo de texto plano está %s en este chat.\n\nUso: /plain on|off",
+		"plain.on":                      "Modo de texto plano activado: sin formato ni emojis, y el código se marca con las líneas CODE START y CODE END.",
+		"plain.off":                     "Modo de texto plano desactivado en este chat.",
+		"outdiff.state":                 "La comparación de salidas está %s en este chat.\n\nUso: /outdiff on|off",
+		"outdiff.on":                    "Comparación de salidas activada: un comando ejecutado de nuevo muestra solo lo que cambió desde su última ejecución.",
+		"outdiff.off":                   "Comparación de salidas desactivada en este chat.",
+		"autorun.state":                 "La ejecución automática está %s.\n\nUso: /autorun on|off",
+		"autorun.state_off":             "desactivada: los comandos esperan aprobación",
+		"autorun.state_paused":          "activada pero en pausa: los comandos esperan aprobación hasta que un administrador envíe /alive",
+		"autorun.state_on":              "activada: los comandos se ejecutan sin aprobación",
+		"autorun.admin_only":            "Solo los chats de administración pueden activar la ejecución automática o cambiarla para otro chat.",
+		"autorun.save_failed":           "No se pudo guardar la ejecución automática: %v",
+		"autorun.off":                   "Ejecución automática desactivada: los comandos vuelven a esperar tu aprobación. Empiezo una sesión nueva.",
+		"autorun.on":                    "⚠️ Ejecución automática activada: los comandos se ejecutan sin aprobación (la protección sigue bloqueando los peligrosos). Empiezo una sesión nueva.",
+		"autorun.other":                 "Ejecución automática para el chat %d: %s.",
+		"pending.none":                  "No hay ningún comando pendiente.",
+		"status.ai":                     "IA: %s",
+		"status.session":                "\nSesión: %s",
+		"status.session_new":            "nueva",
+		"status.session_resumed":        "reanudada",
+		"status.session_messages":       "%d mensajes",
+		"status.project":                "\nProyecto: %s",
+		"status.pending":                "\nComando pendiente %d/%d: %s",
+		"status.pending_none":           "\nComando pendiente: ninguno",
+		"keyboard.state":                "El teclado de respuesta está %s en este chat.\n\nAcciones: %s\n\nUso: /keyboard on|off|<acción>...",
+		"keyboard.removed":              "Teclado de respuesta quitado.",
+		"keyboard.unknown":              "Acción %q desconocida. Acciones: %s",
+		"keyboard.enabled":              "Teclado de respuesta activado. Usa /keyboard off para quitarlo.",
+		"shutdown.draining":             "⏳ El bot se está reiniciando. Primero termino tu solicitud en curso…",
+		"shutdown.lost":                 "⚠️ El bot se reinició antes de terminar tu solicitud. Vuelve a enviarla.",
+		"safeguard.report_invalid":      "Cantidad %q no válida. Uso: /safeguard report [N]",
+		"safeguard.report_none":         "Todavía no hay veredictos de seguridad registrados.",
+		"safeguard.report_caption":      "Últimos %d veredictos de seguridad desde %s: %d bloqueados.",
+		"alert.blocked_approved":        "🚨 La protección bloqueó un comando aprobado\n\n",
+		"alert.blocked_auto":            "🚨 La protección bloqueó un comando ejecutado automáticamente\n\n",
+		"alert.chat":                    "Chat: %d\n",
+		"alert.approved_by":             "Aprobado por: %s\n",
+		"alert.blocked_body":            "Proveedor: %s\nSesión: %s\nHora: %s\n\nComando:\n%s\n\nMotivo: %s",
+		"alert.no_session":              "(ninguna)",
+		"alert.prompt":                  "\n\nPrompt:\n%s",
+		"alert.frozen":                  "\n\nEl chat ha sido congelado hasta que lo revises.",
+		"alert.unfreeze_button":         "Descongelar chat",
+		"alert.unauth_title":            "🚷 Un chat no autorizado contactó al bot\n\n",
+		"alert.unauth_chat":             "Chat: %d",
+		"alert.unauth_from":             "\nDe: %s",
+		"alert.unauth_time":             "\nHora: %s\n\n",
+		"alert.unauth_message":          "Mensaje:\n%s\n\n",
+		"alert.unauth_footer":           "Usa /allow %d para darle acceso. Los demás contactos de este chat durante la próxima hora no se notifican.",
+		"alert.rounds_stopped":          "Detenido: demasiadas rondas de comandos.",
+		"alert.rounds_title":            "⚠️ La ejecución automática alcanzó el límite de rondas\n\n",
+		"alert.rounds_body":             "Chat: %d\nProveedor: %s\nRondas: %d\nHora: %s",
+		"alert.rounds_pending":          "\n\nNo ejecutados:\n%s",
+		"testgate.running":              "Ejecutando las pruebas antes de %q: %s",
+		"approval.interrupted":          "Interrumpido por un reinicio: %s",
+		"sla.card":                      "⏰ Aprobación esperando desde hace %s en el chat %d (%s)\n\nComando %d/%d:\n%s",
+		"sla.escalated":                 "⏰ El comando pendiente lleva esperando %s; se escaló al chat %s.",
+		"breaker.switched":              "⚠️ %s está fallando repetidamente, así que este chat se cambió a %s. Usa /%s para volver más tarde.",
+		"history.none":                  "Todavía no hay historial en esta sesión.",
+		"checkpoint.none_undo":          "No hay puntos de control que deshacer. Se crean antes de los comandos aprobados que cambian archivos en un repositorio git.",
+		"checkpoint.restore_failed":     "No se pudo restaurar el punto de control #%d: %v",
+		"checkpoint.restored":           "Vuelto al punto de control #%d en %s (creado antes de: %s).",
+		"checkpoint.none":               "Todavía no hay puntos de control.",
+		"checkpoint.title":              "Puntos de control (del más reciente):\n",
+		"checkpoint.footer":             "\n/undo restaura el más reciente.",
+		"alive.paused":                  "⏸ Ejecución automática en pausa: ningún /alive en %s. Los comandos esperan aprobación hasta que un administrador envíe /alive.",
+		"alive.reminder":                "⏳ Envía /alive antes de %s para mantener la ejecución automática.",
+		"alive.unset":                   "ALIVE_PERIOD no está configurado: la ejecución automática no necesita /alive.",
+		"alive.save_failed":             "No se pudo guardar la confirmación: %v",
+		"alive.noted":                   "✅ Anotado. Vuelve a enviar /alive antes de %s.",
+		"alive.resumed":                 "▶️ Ejecución automática reanudada. ✅ Anotado. Vuelve a enviar /alive antes de %s.",
+		"stream.stop":                   "⏹ Detener",
+		"stream.nothing":                "Nada que detener.",
+		"stream.stopping":               "Deteniendo…",
+		"export.none":                   "Todavía no hay nada que exportar en esta sesión.",
+		"export.failed":                 "La exportación falló.",
+		"guardrails.title":              "Perfiles de guardarraíles:\n",
+		"guardrails.footer":             "\nUsa /guardrails <perfil> para cambiar.",
+		"guardrails.unknown":            "Perfil %q desconocido. Usa /guardrails para listar los perfiles.",
+		"guardrails.set":                "Perfil de guardarraíles establecido en %s. Las comprobaciones de respuestas se aplican ya; usa /new para que Claude también empiece con las nuevas instrucciones.",
+		"reply.shortened":               "\n\n✂️ Acortada por reply_chars; la respuesta completa va adjunta.",
+		"reply.full_caption":            "Respuesta completa (%d caracteres)",
+		"stats.none":                    "Todavía no hay llamadas a proveedores registradas.",
+		"stats.title":                   "Estadísticas de proveedores:\n",
+		"stats.calls":                   "  Llamadas: %d\n",
+		"stats.errors":                  "  Errores: %d (%.1f%%)",
+		"stats.latency":                 "  Latencia p50/p90/p99: %s / %s / %s\n",
+		"stats.tokens":                  "  Tokens/min (última hora): %.0f (minuto pico 24h: %d)\n",
+		"stats.total":                   "  Tokens totales: %d\n",
+		"costrouting.on":                "💸 Enrutamiento por coste activado (%s): las llamadas usan los COST_ROUTING_MODELS más baratos.",
+		"costrouting.off":               "💸 Enrutamiento por coste desactivado: las llamadas vuelven a usar los modelos configurados.",
+		"export.caption":                "Exportación de la conversación (%d entradas)",
+		"approvals.none":                "Todavía no hay decisiones de aprobación registradas.",
+		"approvals.title":               "Estadísticas de aprobaciones:\n",
+		"approvals.decisions":           "  Decisiones: %d\n",
+		"approvals.wait":                "  Espera p50/p90/p99: %s / %s / %s\n",
+		"approvals.escalations":         "  Escalados (SLA %s): %d, %d decididos después\n",
+		"approvals.escalation_off":      "  Escalado: desactivado (configura APPROVAL_SLA)\n",
+		"tooltrace.title":               "Pasos de Claude:\n",
+		"tooltrace.earlier":             "… %d anteriores\n",
+		"usage.title":                   "📊 Informe de uso %s → %s\n\n",
+		"usage.report_none":             "No hubo llamadas a la IA en este periodo.",
+		"usage.total":                   "Total: %d llamadas, %d tokens de entrada / %d de salida, $%.4f\n\nPor chat:\n",
+		"usage.chat":                    "  %d: %d llamadas, %d tokens de entrada / %d de salida, $%.4f\n",
+	},
+	"de": {
+		"admin_only":               "Dieser Befehl ist Administratoren vorbehalten.",
+		"unauthorized":             "Nicht autorisiert. Deine Chat-ID: %d",
+		"frozen":                   "Dieser Chat ist bis zur Prüfung durch einen Administrator eingefroren. KI-Aufrufe und Befehlsausführung sind ausgesetzt.",
+		"frozen.callback":          "Chat ist bis zur Prüfung eingefroren.",
+		"quota_exceeded":           "Dein tägliches KI-Kontingent ist aufgebraucht (%s). Es wird um Mitternacht zurückgesetzt.",
+		"restarting":               "Der Bot startet neu. Bitte schick das in einer Minute noch einmal.",
+		"error":                    "Fehler: %v",
+		"session_reset":            "Sitzung zurückgesetzt. Deine nächste Nachricht beginnt eine neue Unterhaltung.",
+		"usage_line":               "Verwendung: %s\n\n%s.",
+		"help.title":               "AI Code Bot — Befehle:",
+		"help.admin":               " (Admin)",
+		"help.footer":              "Schick eine beliebige Textnachricht, und ich leite sie an die aktive KI weiter. Schlägt die KI einen Befehl vor, erscheinen die Schaltflächen Ausführen/Ablehnen. Der Gesprächskontext bleibt erhalten, bis du /new verwendest.\n\nMit /help <Befehl> siehst du Details, Beispiele und die Einstellungen dieses Chats.",
+		"help.unknown":             "Unbekannter Befehl /%s. /help listet alle Befehle.",
+		"approval.card":            "Befehl %d/%d:\n`%s`",
+		"approval.approve":         "Ausführen",
+		"approval.deny":            "Ablehnen",
+		"approval.approved":        "Ausgeführt",
+		"approval.denied":          "Abgelehnt",
+		"approval.ran":             "Ausgeführt: %s",
+		"approval.skipped":         "Abgelehnt: %s",
+		"approval.by":              "%s (von %s)",
+		"approval.none":            "Kein Befehl ausstehend.",
+		"approval.risky":           "\n\n⚠️ %s. Zum Ausführen antworte mit `%s`",
+		"approval.type_phrase":     "Riskanter Befehl: Antworte mit %s, um ihn auszuführen.",
+		"approval.confirm_pending": "Der ausstehende Befehl ist riskant. Antworte mit `%s`, um ihn auszuführen, oder tippe auf Ablehnen.",
+		"media.too_big":            "Im Chat gesendete Dateien dürfen höchstens %s groß sein; diese ist größer.",
+		"media.fetch_hint":         "\n\nLade sie an einen erreichbaren Ort hoch und sende /fetchfile <url>, um sie in den Arbeitsbereich herunterzuladen.",
+		"output.truncated":         "\n... (im Chat gekürzt)",
+		"readonly.suggest":         "🔒 Nur-Lesen-Modus: Vorgeschlagene Befehle werden nicht ausgeführt.\n",
+		"usage.none":               "Noch keine Nutzungsdaten. Schick zuerst ein paar Nachrichten!",
+		"usage.session":            "Nutzung der Sitzung",
+		"usage.lifetime":           "Gesamtnutzung (seit %s)",
+		"usage.body":               "%s:\n  Aufrufe: %d\n  Eingabe-Tokens: %d\n  Ausgabe-Tokens: %d\n  Kosten: $%.4f\n  Dauer: %s\n  Letzter Aufruf: vor %s",
+		"usage.per_user":           "Pro Nutzer:",
+		"lang.current":             "Die Nachrichten des Bots sind auf %s.\n\nVerfügbar: %s\n\nVerwendung: /lang <Code> oder /lang default",
+		"lang.set":                 "Die Nachrichten des Bots sind jetzt auf %s.",
+		"lang.unknown":             "Unbekannte Sprache %q. Verfügbar: %s",
+
+		"cmd.start":                     "Willkommensnachricht",
+		"cmd.new":                       "Sitzung zurücksetzen (neue Unterhaltung)",
+		"cmd.claude":                    "Aktive KI auf Claude umstellen",
+		"cmd.gemini":                    "Aktive KI auf Gemini umstellen",
+		"cmd.model":                     "Aktive KI und Modell anzeigen",
+		"cmd.login":                     "Bei der aktiven KI anmelden (Claude-OAuth / API-Schlüssel für Gemini oder OpenRouter)",
+		"cmd.usage":                     "Nutzung dieser Sitzung oder die Gesamtwerte des Chats anzeigen",
+		"cmd.project":                   "Projekte dieses Chats verwalten",
+		"cmd.system":                    "System-Prompt des Chats anzeigen oder ändern",
+		"cmd.undo":                      "Dateien auf den letzten Checkpoint zurücksetzen",
+		"cmd.summarize":                 "Sitzung zusammenfassen, um Kontext zu sparen",
+		"cmd.lang":                      "Sprache der Bot-Nachrichten anzeigen oder ändern",
+		"cmd.help":                      "Diese Hilfe oder die Details eines Befehls anzeigen",
+		"freeze.none":                   "Keine eingefrorenen Chats.\n\nVerwendung: /freeze <chatID> [Grund]",
+		"freeze.list":                   "Eingefrorene Chats:",
+		"freeze.entry":                  "  %d — %s (seit %s)",
+		"freeze.already":                "Chat %d ist bereits eingefroren.",
+		"freeze.done":                   "Chat %d eingefroren. Seine Sitzungen bleiben erhalten; /unfreeze %d stellt ihn wieder her.",
+		"freeze.notice":                 "Ein Admin hat diesen Chat bis zur Prüfung eingefroren. KI-Aufrufe und Befehlsausführung sind ausgesetzt; die Unterhaltung bleibt erhalten und geht nach dem Auftauen weiter.",
+		"unfreeze.not_frozen":           "Chat %d ist nicht eingefroren.",
+		"unfreeze.done":                 "Chat %d aufgetaut.",
+		"unfreeze.notice":               "Ein Admin hat diesen Chat geprüft und aufgetaut. Du kannst weitermachen.",
+		"unfreeze.admin_only":           "Nur Admins können Chats auftauen.",
+		"unfreeze.invalid":              "Ungültige Chat-ID.",
+		"unfreeze.callback_not_frozen":  "Chat ist nicht eingefroren.",
+		"unfreeze.callback":             "Aufgetaut",
+		"unfreeze.card":                 "✅ Chat %d aufgetaut.",
+		"approval.pending_first":        "Bitte genehmige oder lehne zuerst den ausstehenden Befehl ab.",
+		"not_allowed_chat":              "Chat %d ist kein erlaubter Chat.",
+		"save_failed":                   "Speichern fehlgeschlagen: %v",
+		"prompt_expired":                "⌛ Nicht verarbeitet, weil der Bot beschäftigt war (%s, vor %s gesendet). Sende es erneut, falls es noch relevant ist.",
+		"kind.message":                  "Nachricht",
+		"kind.photo":                    "Foto",
+		"kind.voice_message":            "Sprachnachricht",
+		"kind.audio_file":               "Audiodatei",
+		"kind.file":                     "Datei",
+		"kind.sticker":                  "Sticker",
+		"kind.gif":                      "GIF",
+		"kind.video":                    "Video",
+		"kind.video_note":               "Videonachricht",
+		"start":                         "Willkommen bei AI Code Bot!\n\nSchick mir eine Nachricht und ich leite sie an Claude (Standard) oder Gemini weiter.\nBefehle brauchen deine Genehmigung, bevor sie ausgeführt werden.\nMit /new startest du eine neue Unterhaltung, /help zeigt die Befehle, oder:\n  /claude — zu Claude wechseln\n  /gemini — zu Gemini wechseln\n  /model  — aktive KI anzeigen",
+		"ask.unknown":                   "Unbekannte Auswahl.",
+		"ask.expired":                   "Diese Frage ist abgelaufen.",
+		"ask.expired_card":              "❓ Diese Frage ist abgelaufen; antworte mit eigenen Worten.",
+		"ask.saved":                     "Antwort gespeichert.",
+		"ask.sent":                      "Antwort gesendet.",
+		"panic.cancelled":               "🛑 Notstopp: Der ausstehende Befehl wurde von einem Admin abgebrochen.",
+		"panic.done":                    "🛑 Notstopp. Laufende KI-Aufrufe und Befehle wurden abgebrochen, %d Hintergrundbefehl(e) beendet, Tunnel geschlossen und %d ausstehende Genehmigung(en) verworfen.\n\nJeder Chat ist schreibgeschützt, bis ein Admin /resume sendet.",
+		"resume.not_stopped":            "Der Bot ist nicht gestoppt.",
+		"resume.done":                   "▶️ Fortgesetzt: Genehmigte Befehle werden wieder ausgeführt.",
+		"resume.read_only":              "▶️ Fortgesetzt. READ_ONLY ist weiterhin gesetzt, Befehle bleiben also deaktiviert.",
+		"share.none":                    "Diese Sitzung wird nicht geteilt.\n\nVerwendung: /share <chatID> [readonly|collab]",
+		"share.list":                    "Sitzung geteilt mit:",
+		"share.unknown_mode":            "Unbekannter Modus %q. Verwende readonly oder collab.",
+		"share.done":                    "Sitzung mit Chat %d geteilt (%s). Mit /unshare %d widerrufen.",
+		"share.collab_notice":           "Du hast gemeinsamen Zugriff auf die Sitzung von Chat %d erhalten. Deine Nachrichten gehen an diese Sitzung, und du kannst ihre Befehle genehmigen oder ablehnen.",
+		"share.readonly_notice":         "Du hast Lesezugriff auf die Sitzung von Chat %d erhalten. Du siehst ihre KI-Antworten und Genehmigungsanfragen.",
+		"unshare.not_shared":            "Die Sitzung wird nicht mit Chat %d geteilt.",
+		"unshare.done":                  "Chat %d hat keinen Zugriff mehr auf diese Sitzung.",
+		"unshare.notice":                "Dein Zugriff auf die Sitzung von Chat %d wurde widerrufen.",
+		"as.error":                      "🔍 Als Chat %d (%s): Fehler: %v",
+		"as.reply":                      "🔍 Als Chat %d (%s, Projekt %s):\n\n%s",
+		"as.proposed":                   "Vorgeschlagene Befehle (nicht ausgeführt):",
+		"typing.global_off":             "Tippanzeigen sind für alle Chats deaktiviert (TYPING_INTERVAL=0).",
+		"typing.state_on":               "Tippanzeigen sind für diesen Chat an (alle %s).\n\nVerwendung: /typing on|off",
+		"typing.state_off":              "Tippanzeigen sind für diesen Chat aus (alle %s, wenn an).\n\nVerwendung: /typing on|off",
+		"typing.enabled":                "Tippanzeigen für diesen Chat aktiviert.",
+		"typing.disabled":               "Tippanzeigen für diesen Chat deaktiviert.",
+		"tunnel.invalid_port":           "Ungültiger Port %q.",
+		"tunnel.failed":                 "Tunnel konnte nicht gestartet werden: %v",
+		"tunnel.open":                   "🌐 Tunnel offen: %s → localhost:%d\n\nBeenden mit /tunnel stop %d",
+		"tunnel.none_matching":          "Kein passender Tunnel aktiv.",
+		"tunnel.stopped":                "%d Tunnel beendet.",
+		"tunnel.none":                   "Keine Tunnel aktiv.\n\nVerwendung: /tunnel start <port>",
+		"tunnel.list":                   "Tunnel:",
+		"tunnel.entry":                  "  %s → localhost:%d (seit %s aktiv)",
+		"project.list":                  "Projekte:",
+		"project.list_footer":           "Verwende /project switch <name> oder /project add <name> <pfad>.",
+		"project.add_usage":             "Verwendung: /project add <name> <pfad>\n\nRelative Pfade werden relativ zu WORK_DIR aufgelöst.",
+		"project.add_failed":            "Projekt konnte nicht hinzugefügt werden: %v",
+		"project.added":                 "Projekt %s unter %s hinzugefügt. Mit /project switch %s aktivieren.",
+		"project.switch_usage":          "Verwendung: /project switch <name>",
+		"project.switch_failed":         "Wechsel fehlgeschlagen: %v",
+		"project.switched":              "Zu Projekt %s (%s) gewechselt. Neue Sitzung wird gestartet.",
+		"summarize.empty":               "Noch nichts zusammenzufassen.",
+		"summarize.failed":              "Zusammenfassen fehlgeschlagen: %v",
+		"summarize.seed_failed":         "Die zusammengefasste Sitzung konnte nicht gestartet werden (die alte Sitzung bleibt erhalten): %v",
+		"summarize.done":                "Sitzung komprimiert: Kontext ~%d → ~%d Tokens%s.",
+		"summarize.smaller":             " (%d%% kleiner)",
+		"approval.sla_confirm":          "\n\n⚠️ Dieser Befehl ist riskant: Er läuft nur, wenn seine Bestätigungsphrase in Chat %d eingegeben wird. Ablehnen kannst du ihn auch hier.",
+		"readonly.disabled":             "🔒 Schreibschutz: /%s ist deaktiviert.",
+		"callback.not_owner":            "Nur das Mitglied, dem diese Unterhaltung gehört, kann diese Schaltflächen verwenden.",
+		"safeguard.blocked":             "BLOCKIERT: %s",
+		"safeguard.allowed":             "ERLAUBT: Der Befehl '%s' würde die Sicherheitsprüfungen bestehen.",
+		"safeguard.frozen":              "Ein Befehl wurde von den Sicherheitsprüfungen blockiert. Dieser Chat ist jetzt bis zur Prüfung durch einen Admin eingefroren.",
+		"usage.provider":                "\n\n%s: %d Aufrufe, %d Eingabe- / %d Ausgabe-Tokens, %s",
+		"usage.cost_estimate":           "~%s geschätzt",
+		"usage.cost_cloud":              "Kosten werden über dein Cloud-Konto abgerechnet",
+		"provider.already":              "%s wird bereits verwendet.",
+		"provider.switched":             "Zu %s gewechselt. Eine neue Sitzung beginnt.",
+		"provider.azure_hint":           "Azure OpenAI wird auf dem Server konfiguriert, nicht im Chat.\n\nSetze AZURE_OPENAI_ENDPOINT, AZURE_OPENAI_DEPLOYMENT und AZURE_OPENAI_API_KEY (optional AZURE_OPENAI_API_VERSION) und starte den Bot neu.",
+		"provider.bedrock_hint":         "Bedrock wird auf dem Server konfiguriert, nicht im Chat.\n\nSetze BEDROCK_REGION, BEDROCK_MODEL_ID, AWS_ACCESS_KEY_ID und AWS_SECRET_ACCESS_KEY (plus AWS_SESSION_TOKEN für temporäre Zugangsdaten) und starte den Bot neu. Die Zugangsdaten brauchen bedrock:InvokeModel für das Modell.",
+		"model.current":                 "Aktuelle KI: %s (Modell: %s)",
+		"model.current_azure":           "Aktuelle KI: %s (Deployment: %s, api-version %s)",
+		"model.current_bedrock":         "Aktuelle KI: %s (Modell: %s, Region %s)",
+		"model.hint_models":             "\n\nMit /models Modelle vergleichen und wechseln.",
+		"model.hint_omodel":             "\n\nMit /models oder /omodel OpenRouter-Modelle durchsuchen.",
+		"model.hint_cmodel":             "\n\nMit /cmodel das Claude-Modell wechseln.",
+		"model.cli_default":             "CLI-Standard",
+		"model.cost_routing":            "\n\n💸 Kostenrouting: Aufrufe verwenden %s (%s).",
+		"cmodel.opus":                   "🧠 Opus (am leistungsfähigsten)",
+		"cmodel.sonnet":                 "⚖️ Sonnet (ausgewogen)",
+		"cmodel.haiku":                  "⚡ Haiku (schnell)",
+		"cmodel.set":                    "Claude-Modell: %s. Gilt ab der nächsten Nachricht.",
+		"cmodel.choose":                 "Aktuelles Claude-Modell: `%s`\nWähle ein Modell:",
+		"cmodel.done":                   "✅ Claude-Modell: `%s`\nGilt ab der nächsten Nachricht.",
+		"share.forwarded":               "💬 Aus dem geteilten Chat %d:\n%s",
+		"media.transcribe_voice_failed": "Die Sprachnachricht konnte nicht transkribiert werden. Prüfe das Transkriptions-Backend (WHISPER_BACKEND).",
+		"media.transcribe_audio_failed": "Das Audio konnte nicht transkribiert werden. Prüfe das Transkriptions-Backend (WHISPER_BACKEND).",
+		"media.download_failed":         "Herunterladen von %s fehlgeschlagen: %v",
+		"login.setup_failed":            "Vorbereitung der %s-Anmeldung fehlgeschlagen: %v",
+		"login.failed":                  "Anmeldung fehlgeschlagen: %v",
+		"login.failed_retry":            "Anmeldung fehlgeschlagen: %v\nBitte versuche es mit /login erneut.",
+		"login.url":                     "Öffne diese URL, um dich mit deinem Google-Konto anzumelden:\n\n%s\n\nNach der Anmeldung erhältst du einen Autorisierungscode.\nFüge ihn hier als nächste Nachricht ein (innerhalb von %s).",
+		"login.empty":                   "Leere Eingabe. Bitte versuche es mit einer neuen Nachricht erneut.",
+		"login.verifying_key":           "API-Schlüssel wird geprüft...",
+		"login.success":                 "Anmeldung erfolgreich! Du kannst jetzt Nachrichten an %s senden.",
+		"login.success_retry":           "Anmeldung erfolgreich! Deine Nachricht wird verarbeitet...",
+		"login.key_gemini":              "Für Gemini brauchst du einen kostenlosen API-Schlüssel von Google AI Studio.\n\n1. Öffne: https://aistudio.google.com/apikey\n2. Klicke auf \"Create API key\"\n3. Kopiere den Schlüssel und füge ihn hier als nächste Nachricht ein.",
+		"login.key_openrouter":          "Für OpenRouter brauchst du einen API-Schlüssel.\n\n1. Öffne: https://openrouter.ai/settings/keys\n2. Klicke auf \"Create Key\"\n3. Kopiere den Schlüssel und füge ihn hier als nächste Nachricht ein.",
+		"login.starting":                "Claude ist nicht angemeldet. OAuth-Anmeldung wird gestartet...",
+		"login.exchanging":              "Autorisierungscode wird geprüft...",
+		"login.verifying":               "Der Anmeldebildschirm wurde nicht geschlossen; es wird geprüft, ob Claude angemeldet ist...",
+		"login.cancelled":               "Anmeldung abgebrochen: %v\nMit /login neu beginnen.",
+		"login.restarted_key":           "Der Bot wurde neu gestartet, während er auf deinen API-Schlüssel gewartet hat.",
+		"login.restarted_claude":        "Der Bot wurde während der Claude-Anmeldung neu gestartet, daher funktioniert die gesendete Anmelde-URL nicht mehr.",
+		"reply.empty":                   "(leere Antwort)",
+		"autorun.paused_commands":       "⏸ Die automatische Ausführung ist pausiert, bis ein Admin /alive sendet; genehmige die Befehle unten.",
+		"autorun.paused_command":        "⏸ Die automatische Ausführung ist pausiert, bis ein Admin /alive sendet; genehmige den Befehl unten.",
+		"autorun.running":               "Wird ausgeführt: %s",
+		"approval.not_escalation":       "Kein Eskalations-Chat.",
+		"state.on":                      "an",
+		"state.off":                     "aus",
+		"args.invalid":                  "Ungültige Argumente: %v.",
+		"args.invalid_usage":            "Ungültige Argumente: %v.\n\nVerwendung: %s",
+		"help.admin_only":               " Nur für Admins.",
+		"help.examples":                 "\n\nBeispiele:\n",
+		"help.this_chat":                "\n\nDieser Chat:\n",
+		"setting.provider":              "  Aktive KI: %s",
+		"setting.provider_model":        "  Aktive KI: %s (Modell %s)",
+		"setting.project":               "  Projekt: %s (%s)",
+		"setting.workspace":             "\n  Arbeitsbereich: %s belegt, %s frei",
+		"setting.claude_model":          "  Claude-Modell: %s",
+		"setting.system_prompt_custom":  "  Systemprompt: eigener (%d Bytes)",
+		"setting.system_prompt_default": "  Systemprompt: Standard",
+		"setting.persona":               "  Persona: %s",
+		"setting.persona_none":          "  Persona: keine",
+		"setting.autorun":               "  Automatische Ausführung: %s",
+		"setting.autorun_paused":        "  Automatische Ausführung: an, pausiert bis ein Admin /alive sendet",
+		"setting.readonly":              "  Nur-Lesen: %s",
+		"setting.voice":                 "  Sprachantworten: %s",
+		"setting.voice_unavailable":     "  Sprachantworten: nicht verfügbar (kein TTS konfiguriert)",
+		"setting.overrides":             "  Eigene Einstellungen: %s",
+		"setting.overrides_none":        "  Eigene Einstellungen: keine",
+		"setting.vars":                  "  Variablen: %d",
+		"setting.history":               "  Einträge in dieser Sitzung: %d",
+		"setting.tunnels_none":          "  Von diesem Chat wurden keine Tunnel gestartet.",
+		"setting.guardrails":            "  Profil: %s (verfügbar: %s)",
+		"setting.postprocess":           "  Nachbearbeitungen: %d",
+		"setting.typing":                "  Tippanzeige: an (alle %s)",
+		"setting.typing_off":            "  Tippanzeige: aus",
+		"setting.output_diff":           "  Ausgabevergleich: %s",
+		"setting.plain":                 "  Klartextmodus: %s",
+		"setting.keyboard":              "  Antworttastatur: %s",
+		"setting.frozen":                "  Eingefrorene Chats: %d",
+		"setting.shares":                "  Geteilt mit: %s",
+		"setting.shares_none":           "  Diese Sitzung ist nicht geteilt.",
+		"allow.exists":                  "Chat %d hat bereits Zugriff.",
+		"allow.save_failed":             "Die Zugriffsliste konnte nicht gespeichert werden: %v",
+		"allow.done":                    "✅ Chat %d kann den Bot jetzt verwenden.",
+		"allow.notice":                  "Du hast jetzt Zugriff auf diesen Bot. Sende /start, um zu beginnen.",
+		"allow.title":                   "Zugelassene Chats\n\nALLOWED_CHAT_IDS:\n",
+		"allow.revoked_entry":           "  %d (entzogen am %s%s)\n",
+		"allow.none":                    "  (keine)\n",
+		"allow.added":                   "\nMit /allow hinzugefügt:\n",
+		"allow.admins":                  "\nAdmins:",
+		"revoke.admin":                  "Admin-Chats haben immer Zugriff; entferne sie stattdessen aus ADMIN_CHAT_IDS.",
+		"revoke.none":                   "Chat %d hat keinen Zugriff.",
+		"revoke.done":                   "🚫 Chat %d kann den Bot nicht mehr verwenden.",
+		"inventory.admin_only":          "Nur Admin-Chats können das Inventar ändern.",
+		"inventory.none_tagged":         "Keine Hosts mit dem Tag %s.",
+		"inventory.empty":               "Das Inventar ist leer.\n\nMit /inventory add <Name> <Adresse> [tags=a,b] [services=...] einen Host hinzufügen.",
+		"inventory.list":                "Inventar (%d Hosts):\n",
+		"inventory.unknown":             "Kein Host %q im Inventar.",
+		"inventory.show":                "%s\n\nHinzugefügt am %s.",
+		"inventory.add_failed":          "Host konnte nicht hinzugefügt werden: %v",
+		"inventory.added":               "%s hinzugefügt.\n\nNeue Sitzungen erhalten das Inventar; in einer laufenden nenne den Host.",
+		"inventory.rm_failed":           "Host konnte nicht entfernt werden: %v",
+		"inventory.removed":             "%s entfernt.",
+		"var.none":                      "Keine Variablen gesetzt.\n\nMit /var set <NAME> <Wert> setzen, dann {{NAME}} in Nachrichten und Befehlen schreiben.",
+		"var.list":                      "Variablen:\n",
+		"var.set_usage":                 "Verwendung: /var set <NAME> <Wert>",
+		"var.set_failed":                "Variable konnte nicht gesetzt werden: %v",
+		"var.set":                       "{{%s}} gesetzt.",
+		"var.unset_usage":               "Verwendung: /var unset <NAME>",
+		"var.unset_failed":              "Variable konnte nicht entfernt werden: %v",
+		"var.unknown":                   "Keine Variable {{%s}}.",
+		"var.removed":                   "{{%s}} entfernt.",
+		"readonly.state":                "Der Nur-Lesen-Modus ist %s.\n\nVerwendung: /readonly on|off",
+		"readonly.state_off":            "aus: genehmigte Befehle werden ausgeführt",
+		"readonly.state_emergency":      "für alle Chats an, bis ein Admin /resume sendet (Notstopp)",
+		"readonly.state_global":         "für alle Chats an (READ_ONLY): Befehle werden vorgeschlagen, aber nie ausgeführt",
+		"readonly.state_on":             "an: Befehle werden vorgeschlagen, aber nie ausgeführt",
+		"readonly.admin_only":           "Nur Admin-Chats können den Nur-Lesen-Modus ausschalten oder für einen anderen Chat ändern.",
+		"readonly.global":               "READ_ONLY ist für alle Chats gesetzt; es kann nicht für einen einzelnen ausgeschaltet werden.",
+		"readonly.emergency":            "Der Bot ist im Notstopp; ein Admin muss /resume senden.",
+		"readonly.save_failed":          "Die Nur-Lesen-Einstellung konnte nicht gespeichert werden: %v",
+		"readonly.off":                  "Nur-Lesen-Modus aus: genehmigte Befehle werden wieder ausgeführt. Eine neue Sitzung beginnt.",
+		"readonly.on":                   "🔒 Nur-Lesen-Modus an: Die KI kann Befehle vorschlagen, aber keiner wird ausgeführt. Eine neue Sitzung beginnt.",
+		"readonly.other":                "Nur-Lesen-Modus für Chat %d: %s.",
+		"selection.unknown":             "Unbekannte Auswahl.",
+		"model.switched":                "Modell gewechselt!",
+		"sgx.request_button":            "Ausnahme beantragen",
+		"sgx.blocked":                   "Von der Sicherheitsregel %s blockiert. Wenn der Befehl legitim ist, bitte den Admin, ihn zu erlauben.",
+		"sgx.invalid":                   "Ungültige Anfrage.",
+		"sgx.expired":                   "Diese Anfrage ist abgelaufen; führe den Befehl erneut aus, um eine neue zu erhalten.",
+		"sgx.request_card":              "🛡 Antrag auf Sicherheitsausnahme\n\nChat: %d%s\nRegel: %s\n%s\n\nBefehl:\n%s\n\nWird er erlaubt, passiert genau dieser Befehl diese Regel, in allen Chats.",
+		"sgx.allow_button":              "Genau diesen Befehl erlauben",
+		"sgx.reject_button":             "Ablehnen",
+		"sgx.sent":                      "An den Admin gesendet",
+		"sgx.requested":                 "Ausnahme für Regel %s beantragt; du erfährst, wenn der Admin entscheidet.",
+		"sgx.admin_only":                "Nur Admins können über Ausnahmen entscheiden.",
+		"sgx.decided":                   "Bereits entschieden.",
+		"sgx.save_failed":               "Die Ausnahme konnte nicht gespeichert werden.",
+		"sgx.allowed":                   "Erlaubt",
+		"sgx.allowed_card":              "✅ Durch Regel %s erlaubt:\n%s\n\nMit /safeguard exceptions prüfen oder widerrufen.",
+		"sgx.allowed_notice":            "✅ Der Admin hat diesen Befehl durch Regel %s erlaubt; bitte die KI, ihn erneut auszuführen:\n%s",
+		"sgx.rejected":                  "Abgelehnt",
+		"sgx.rejected_card":             "❌ Ausnahme für Regel %s abgelehnt:\n%s",
+		"sgx.rejected_notice":           "❌ Der Admin hat Regel %s beibehalten für:\n%s",
+		"sgx.revoke_usage":              "Verwendung: /safeguard revoke <n> (siehe /safeguard exceptions)",
+		"sgx.revoke_failed":             "Widerruf fehlgeschlagen: %v",
+		"sgx.revoked":                   "Widerrufen: Regel %s gilt wieder für:\n%s",
+		"sgx.none":                      "Keine Sicherheitsausnahmen.",
+		"sgx.list":                      "Sicherheitsausnahmen:\n",
+		"sgx.entry":                     "\n%d. Regel %s, beantragt von Chat %d, erlaubt am %s",
+		"sgx.entry_by":                  " von %s",
+		"sgx.list_footer":               "\nMit /safeguard revoke <n> eine entfernen.",
+		"omodel.switched_unverified":    "OpenRouter-Modell zu %s gewechselt (Katalog nicht verfügbar, nicht geprüft). Sitzung zurückgesetzt.",
+		"omodel.fetch_failed":           "Die OpenRouter-Modellliste konnte nicht abgerufen werden: %v",
+		"omodel.switched":               "OpenRouter-Modell zu %s gewechselt (%s). Sitzung zurückgesetzt.",
+		"omodel.unknown":                "Unbekanntes OpenRouter-Modell %q. Mit /omodel durchsuchen.",
+		"omodel.no_models":              "OpenRouter hat keine Modelle geliefert.",
+		"omodel.choose_vendor":          "Aktuelles OpenRouter-Modell: `%s`\nWähle einen Anbieter:",
+		"omodel.no_match":               "Keine passenden OpenRouter-Modelle. Mit /omodel die Anbieter auflisten.",
+		"omodel.title":                  "OpenRouter-Modelle",
+		"omodel.title_vendor":           " von %s",
+		"omodel.title_price":            " bis $%s pro 1M Eingabe-Tokens",
+		"omodel.title_cheapest":         " (die günstigsten %d von %d)",
+		"omodel.vendor":                 "Anbieter: %s",
+		"omodel.done":                   "✅ Zu `%s` gewechselt\nSitzung zurückgesetzt – die nächste Nachricht beginnt neu.",
+		"price.cloud":                   "wird über dein Cloud-Konto abgerechnet",
+		"price.varies":                  "Preis variiert",
+		"price.free":                    "kostenlos",
+		"price.per_million":             "$%s/$%s pro 1M",
+		"models.context":                "%s Kontext",
+		"models.title":                  "🤖 %s-Modelle",
+		"models.matching":               " passend zu %q",
+		"models.page":                   " (Seite %d/%d)",
+		"models.other_provider":         "Dieser Chat verwendet %s; die Wahl eines Modells wechselt dorthin.\n",
+		"models.no_match":               "\nKeine passenden Modelle.",
+		"models.not_configured":         "\nAuf diesem Server nicht konfiguriert.",
+		"models.legend":                 "👁 Bilder · 🛠 Werkzeugaufrufe · 🧠 Reasoning",
+		"models.prev":                   "◀️ Zurück",
+		"models.next":                   "Weiter ▶️",
+		"models.list_failed":            "%s-Modelle konnten nicht aufgelistet werden: %v",
+		"models.list_failed_short":      "Modelle konnten nicht aufgelistet werden.",
+		"models.not_configured_short":   "Nicht konfiguriert.",
+		"models.not_switched":           "Nicht gewechselt.",
+		"models.save_failed":            "Das Modell konnte nicht gespeichert werden: %v",
+		"models.selected":               "✅ %s-Modell für diesen Chat: %s",
+		"models.switched_from":          "\nVon %s gewechselt. Eine neue Sitzung beginnt.",
+		"models.next_message":           "\nGilt ab der nächsten Nachricht.",
+		"models.session_reset":          "\nSitzung zurückgesetzt – die nächste Nachricht beginnt neu.",
+		"system.custom":                 "Eigener System-Prompt für diesen Chat:\n\n%s\n\nDie Sicherheitsregeln werden immer angehängt. Mit /system reset zurück zum Standard.",
+		"system.default":                "Standard-System-Prompt (%s):\n\n%s\n\nMit /system set <prompt> für diesen Chat ändern.",
+		"system.set_usage":              "Verwendung: /system set <prompt>",
+		"system.set_failed":             "System-Prompt konnte nicht gesetzt werden: %v",
+		"system.reset_failed":           "System-Prompt konnte nicht zurückgesetzt werden: %v",
+		"system.updated":                "System-Prompt aktualisiert. Er gilt ab der nächsten Nachricht mit Gemini, OpenRouter, Azure und Bedrock und ab der nächsten Claude-Sitzung (mit /new eine starten).",
+		"system.already_default":        "Dieser Chat verwendet bereits den Standard-System-Prompt.",
+		"system.reset":                  "System-Prompt auf den Standard zurückgesetzt. Er gilt ab der nächsten Nachricht mit Gemini, OpenRouter, Azure und Bedrock und ab der nächsten Claude-Sitzung (mit /new eine starten).",
+		"postprocess.none":              "Keine Nachbearbeitung: KI-Antworten werden unverändert gesendet.\n\nSiehe /help postprocess, um eine hinzuzufügen.",
+		"postprocess.list":              "Nachbearbeitungen, der Reihe nach auf KI-Antworten angewendet:\n",
+		"postprocess.add_failed":        "Nachbearbeitung konnte nicht hinzugefügt werden: %v",
+		"postprocess.added":             "Hinzugefügt: %s",
+		"postprocess.remove_usage":      "Verwendung: /postprocess remove <n>",
+		"postprocess.remove_failed":     "Nachbearbeitung konnte nicht entfernt werden: %v",
+		"postprocess.removed":           "Entfernt: %s",
+		"postprocess.cleared":           "Nachbearbeitungen entfernt: KI-Antworten werden unverändert gesendet.",
+		"config.title":                  "Chat-Einstellungen:\n",
+		"config.entry":                  "\n%s = %s (Standard %s)\n",
+		"config.entry_default":          "\n%s = %s (Standard)\n",
+		"config.footer":                 "\nMit /config set <schlüssel> <wert> oder /config reset <schlüssel|all> ändern.",
+		"config.set_usage":              "Verwendung: /config set <schlüssel> <wert>",
+		"config.unknown":                "Unbekannte Einstellung %q. Mit /config auflisten.",
+		"config.invalid":                "Ungültiger Wert für %s: %v",
+		"config.save_failed":            "Einstellungen konnten nicht gespeichert werden: %v",
+		"config.reset_usage":            "Verwendung: /config reset <schlüssel|all>",
+		"config.reset":                  "%s auf den Serverstandard zurückgesetzt.",
+		"fetch.bad_url":                 "Keine http(s)-URL: %s",
+		"fetch.exists":                  "%s existiert bereits. Gib einen anderen Namen an: /fetchfile <url> <name>",
+		"fetch.over_quota":              "Datei konnte nicht heruntergeladen werden: %v. Lösche zuerst Dateien.",
+		"fetch.downloading":             "📥 Lade nach %s herunter...",
+		"fetch.failed":                  "Datei konnte nicht heruntergeladen werden: %v",
+		"fetch.saved":                   "📥 %s gespeichert (%s).",
+		"persona.title":                 "Personas:\n",
+		"persona.footer":                "\nMit /persona <name> wechseln, mit /persona off zum Standard-Prompt.",
+		"persona.off":                   "Persona aus: zurück zum Standard-System-Prompt. Eine neue Sitzung beginnt.",
+		"persona.unknown":               "Unbekannte Persona %q. Mit /persona auflisten.",
+		"persona.set":                   "Persona auf %s gesetzt (%s). Eine neue Sitzung beginnt.",
+		"make.none":                     "Keine Makefile- oder Taskfile-Ziele in %s gefunden.",
+		"make.choose":                   "Wähle ein Ziel zum Ausführen:",
+		"make.gone":                     "Das Ziel existiert nicht mehr.",
+		"make.pending":                  "Genehmige oder lehne zuerst den ausstehenden Befehl ab.",
+		"make.selected":                 "Ausgewählt: %s",
+		"voice.state":                   "Sprachantworten sind %s.\n\nVerwendung: /voice on|off",
+		"voice.unavailable":             "Sprachantworten brauchen TTS_CMD oder TTS_API_URL auf dem Server.",
+		"voice.on":                      "🔊 Sprachantworten an: KI-Antworten werden auch als Sprachnachrichten gesendet.",
+		"voice.off":                     "🔇 Sprachantworten aus.",
+		"plain.state":                   "Der Nur-Text-Modus ist für diesen Chat %s.\n\nVerwendung: /plain on|off",
+		"plain.on":                      "Nur-Text-Modus aktiviert: keine Formatierung oder Emojis, und Code wird mit den Zeilen CODE START und CODE END markiert.",
+		"plain.off":                     "Nur-Text-Modus für diesen Chat deaktiviert.",
+		"outdiff.state":                 "Der Ausgabevergleich ist für diesen Chat %s.\n\nVerwendung: /outdiff on|off",
+		"outdiff.on":                    "Ausgabevergleich aktiviert: ein erneut ausgeführter Befehl zeigt nur, was sich seit dem letzten Lauf geändert hat.",
+		"outdiff.off":                   "Ausgabevergleich für diesen Chat deaktiviert.",
+		"autorun.state":                 "Die automatische Ausführung ist %s.\n\nVerwendung: /autorun on|off",
+		"autorun.state_off":             "aus: Befehle warten auf Genehmigung",
+		"autorun.state_paused":          "an, aber pausiert: Befehle warten auf Genehmigung, bis ein Admin /alive sendet",
+		"autorun.state_on":              "an: Befehle laufen ohne Genehmigung",
+		"autorun.admin_only":            "Nur Admin-Chats können die automatische Ausführung einschalten oder für einen anderen Chat ändern.",
+		"autorun.save_failed":           "Die automatische Ausführung konnte nicht gespeichert werden: %v",
+		"autorun.off":                   "Automatische Ausführung aus: Befehle warten wieder auf deine Genehmigung. Eine neue Sitzung beginnt.",
+		"autorun.on":                    "⚠️ Automatische Ausführung an: Befehle laufen ohne Genehmigung (gefährliche blockiert der Schutz weiterhin). Eine neue Sitzung beginnt.",
+		"autorun.other":                 "Automatische Ausführung für Chat %d: %s.",
+		"pending.none":                  "Kein ausstehender Befehl.",
+		"status.ai":                     "KI: %s",
+		"status.session":                "\nSitzung: %s",
+		"status.session_new":            "neu",
+		"status.session_resumed":        "fortgesetzt",
+		"status.session_messages":       "%d Nachrichten",
+		"status.project":                "\nProjekt: %s",
+		"status.pending":                "\nAusstehender Befehl %d/%d: %s",
+		"status.pending_none":           "\nAusstehender Befehl: keiner",
+		"keyboard.state":                "Die Antworttastatur ist für diesen Chat %s.\n\nAktionen: %s\n\nVerwendung: /keyboard on|off|<aktion>...",
+		"keyboard.removed":              "Antworttastatur entfernt.",
+		"keyboard.unknown":              "Unbekannte Aktion %q. Aktionen: %s",
+		"keyboard.enabled":              "Antworttastatur aktiviert. Mit /keyboard off entfernen.",
+		"shutdown.draining":             "⏳ Der Bot startet neu. Deine laufende Anfrage wird zuerst abgeschlossen…",
+		"shutdown.lost":                 "⚠️ Der Bot wurde neu gestartet, bevor deine Anfrage fertig war. Bitte sende sie erneut.",
+		"safeguard.report_invalid":      "Ungültige Anzahl %q. Verwendung: /safeguard report [N]",
+		"safeguard.report_none":         "Noch keine Sicherheitsentscheidungen aufgezeichnet.",
+		"safeguard.report_caption":      "Letzte %d Sicherheitsentscheidungen seit %s: %d blockiert.",
+		"alert.blocked_approved":        "🚨 Der Schutz hat einen genehmigten Befehl blockiert\n\n",
+		"alert.blocked_auto":            "🚨 Der Schutz hat einen automatisch ausgeführten Befehl blockiert\n\n",
+		"alert.chat":                    "Chat: %d\n",
+		"alert.approved_by":             "Genehmigt von: %s\n",
+		"alert.blocked_body":            "Anbieter: %s\nSitzung: %s\nZeit: %s\n\nBefehl:\n%s\n\nGrund: %s",
+		"alert.no_session":              "(keine)",
+		"alert.prompt":                  "\n\nPrompt:\n%s",
+		"alert.frozen":                  "\n\nDer Chat wurde bis zu deiner Prüfung eingefroren.",
+		"alert.unfreeze_button":         "Chat auftauen",
+		"alert.unauth_title":            "🚷 Ein nicht autorisierter Chat hat den Bot kontaktiert\n\n",
+		"alert.unauth_chat":             "Chat: %d",
+		"alert.unauth_from":             "\nVon: %s",
+		"alert.unauth_time":             "\nZeit: %s\n\n",
+		"alert.unauth_message":          "Nachricht:\n%s\n\n",
+		"alert.unauth_footer":           "Mit /allow %d Zugriff geben. Weitere Kontakte dieses Chats innerhalb der Stunde werden nicht gemeldet.",
+		"alert.rounds_stopped":          "Gestoppt: zu viele Befehlsrunden.",
+		"alert.rounds_title":            "⚠️ Die automatische Ausführung hat das Rundenlimit erreicht\n\n",
+		"alert.rounds_body":             "Chat: %d\nAnbieter: %s\nRunden: %d\nZeit: %s",
+		"alert.rounds_pending":          "\n\nNicht ausgeführt:\n%s",
+		"testgate.running":              "Führe Tests vor %q aus: %s",
+		"approval.interrupted":          "Durch einen Neustart unterbrochen: %s",
+		"sla.card":                      "⏰ Genehmigung wartet seit %s in Chat %d (%s)\n\nBefehl %d/%d:\n%s",
+		"sla.escalated":                 "⏰ Der ausstehende Befehl wartet seit %s; er wurde an Chat %s eskaliert.",
+		"breaker.switched":              "⚠️ %s schlägt gerade wiederholt fehl, daher wurde dieser Chat zu %s gewechselt. Mit /%s später zurückwechseln.",
+		"history.none":                  "Noch kein Verlauf in dieser Sitzung.",
+		"checkpoint.none_undo":          "Keine Checkpoints zum Rückgängigmachen. Checkpoints werden vor genehmigten Befehlen angelegt, die Dateien in einem git-Repository ändern.",
+		"checkpoint.restore_failed":     "Checkpoint #%d konnte nicht wiederhergestellt werden: %v",
+		"checkpoint.restored":           "Auf Checkpoint #%d in %s zurückgesetzt (angelegt vor: %s).",
+		"checkpoint.none":               "Noch keine Checkpoints.",
+		"checkpoint.title":              "Checkpoints (neueste zuerst):\n",
+		"checkpoint.footer":             "\n/undo stellt den neuesten wieder her.",
+		"alive.paused":                  "⏸ Automatische Ausführung pausiert: kein /alive in %s. Befehle warten auf Genehmigung, bis ein Admin /alive sendet.",
+		"alive.reminder":                "⏳ Sende /alive vor %s, damit die automatische Ausführung weiterläuft.",
+		"alive.unset":                   "ALIVE_PERIOD ist nicht gesetzt: die automatische Ausführung braucht kein /alive.",
+		"alive.save_failed":             "Der Check-in konnte nicht gespeichert werden: %v",
+		"alive.noted":                   "✅ Notiert. Sende /alive erneut vor %s.",
+		"alive.resumed":                 "▶️ Automatische Ausführung fortgesetzt. ✅ Notiert. Sende /alive erneut vor %s.",
+		"stream.stop":                   "⏹ Stopp",
+		"stream.nothing":                "Nichts zu stoppen.",
+		"stream.stopping":               "Wird gestoppt…",
+		"export.none":                   "In dieser Sitzung gibt es noch nichts zu exportieren.",
+		"export.failed":                 "Export fehlgeschlagen.",
+		"guardrails.title":              "Guardrail-Profile:\n",
+		"guardrails.footer":             "\nMit /guardrails <profil> wechseln.",
+		"guardrails.unknown":            "Unbekanntes Profil %q. Mit /guardrails die Profile auflisten.",
+		"guardrails.set":                "Guardrail-Profil auf %s gesetzt. Antwortprüfungen gelten sofort; mit /new startet auch Claude mit den neuen Anweisungen.",
+		"reply.shortened":               "\n\n✂️ Für reply_chars gekürzt; die vollständige Antwort ist angehängt.",
+		"reply.full_caption":            "Vollständige Antwort (%d Zeichen)",
+		"stats.none":                    "Noch keine Anbieteraufrufe aufgezeichnet.",
+		"stats.title":                   "Anbieterstatistik:\n",
+		"stats.calls":                   "  Aufrufe: %d\n",
+		"stats.errors":                  "  Fehler: %d (%.1f%%)",
+		"stats.latency":                 "  Latenz p50/p90/p99: %s / %s / %s\n",
+		"stats.tokens":                  "  Tokens/Min (letzte Stunde): %.0f (Spitzenminute 24h: %d)\n",
+		"stats.total":                   "  Tokens gesamt: %d\n",
+		"costrouting.on":                "💸 Kostenrouting an (%s): Aufrufe verwenden die günstigeren COST_ROUTING_MODELS.",
+		"costrouting.off":               "💸 Kostenrouting aus: Aufrufe verwenden wieder die konfigurierten Modelle.",
+		"export.caption":                "Gesprächsexport (%d Einträge)",
+		"approvals.none":                "Noch keine Genehmigungsentscheidungen aufgezeichnet.",
+		"approvals.title":               "Genehmigungsstatistik:\n",
+		"approvals.decisions":           "  Entscheidungen: %d\n",
+		"approvals.wait":                "  Wartezeit p50/p90/p99: %s / %s / %s\n",
+		"approvals.escalations":         "  Eskalationen (SLA %s): %d, %d später entschieden\n",
+		"approvals.escalation_off":      "  Eskalation: aus (APPROVAL_SLA setzen)\n",
+		"tooltrace.title":               "Claudes Schritte:\n",
+		"tooltrace.earlier":             "… %d frühere\n",
+		"usage.title":                   "📊 Nutzungsbericht %s → %s\n\n",
+		"usage.report_none":             "Keine KI-Aufrufe in diesem Zeitraum.",
+		"usage.total":                   "Gesamt: %d Aufrufe, %d Eingabe- / %d Ausgabe-Tokens, $%.4f\n\nPro Chat:\n",
+		"usage.chat":                    "  %d: %d Aufrufe, %d Eingabe- / %d Ausgabe-Tokens, $%.4f\n",
+	},
+}
+
+// translate formats the message key in lang, falling back to English. An
+// unknown key is returned as is, which makes a missing entry easy to spot.
+func translate(lang, key string, args ...any) string {
+	format, ok := messages[lang][key]
+	if !ok {
+		if format, ok = messages[defaultUILanguage][key]; !ok {
+			format = key
+		}
+	}
+	if len(args) == 0 {
+		return format
+	}
+	return fmt.Sprintf(format, args...)
+}
+
+// commandDescription returns a command's description in lang, or the
+// registry's English one when the catalog has none.
+func commandDescription(lang string, c Command) string {
+	if d, ok := messages[lang]["cmd."+c.Name]; ok {
+		return d
+	}
+	return c.Description
+}
+
+// uiLanguage returns the language of chatID's bot messages.
+func (h *Handlers) uiLanguage(chatID int64) string {
+	if h == nil || h.settings == nil {
+		return defaultUILanguage
+	}
+	if lang := h.settings.Get(chatID).Language; lang != "" {
+		return lang
+	}
+	return defaultUILanguage
+}
+
+// t formats the message key in chatID's language.
+func (h *Handlers) t(chatID int64, key string, args ...any) string {
+	return translate(h.uiLanguage(chatID), key, args...)
+}
+
+// onOff names a setting's state in chatID's language.
+func (h *Handlers) onOff(chatID int64, on bool) string {
+	if on {
+		return h.t(chatID, "state.on")
+	}
+	return h.t(chatID, "state.off")
+}
+
+// languageList lists the catalog's languages as "code (name)", sorted.
+func languageList() string {
+	codes := make([]string, 0, len(uiLanguages))
+	for code := range uiLanguages {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	for i, code := range codes {
+		codes[i] = fmt.Sprintf("%s (%s)", code, uiLanguages[code])
+	}
+	return strings.Join(codes, ", ")
+}
+
+// HandleLang shows or sets the language of the chat's bot messages.
+// Usage: /lang [code|default]
+func (h *Handlers) HandleLang(chatID int64, args string) {
+	code := strings.ToLower(strings.TrimSpace(args))
+	if code == "" {
+		lang := h.uiLanguage(chatID)
+		h.sender.SendPlain(chatID, h.t(chatID, "lang.current", uiLanguages[lang], languageList()))
+		return
+	}
+	if code == "default" || code == "reset" {
+		code = defaultUILanguage
+	}
+	if _, ok := uiLanguages[code]; !ok {
+		h.sender.SendPlain(chatID, h.t(chatID, "lang.unknown", code, languageList()))
+		return
+	}
+	err := h.settings.Update(chatID, func(s *ChatSettings) error {
+		s.Language = code
+		if code == defaultUILanguage {
+			s.Language = ""
+		}
+		return nil
+	})
+	if err != nil {
+		h.sender.SendPlain(chatID, h.t(chatID, "error", err))
+		return
+	}
+	h.sender.SendPlain(chatID, translate(code, "lang.set", uiLanguages[code]))
+}
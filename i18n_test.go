@@ -0,0 +1,95 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTranslate(t *testing.T) {
+	if got := translate("it", "approval.ran", "ls"); got != "Approvato: ls" {
+		t.Errorf("italian = %q", got)
+	}
+	if got := translate("xx", "approval.ran", "ls"); got != "Approved: ls" {
+		t.Errorf("unknown language = %q, want the English message", got)
+	}
+	if got := translate("en", "no.such.key"); got != "no.such.key" {
+		t.Errorf("unknown key = %q", got)
+	}
+}
+
+// Every translation must take the same arguments as the English message.
+func TestCatalogMatchesEnglish(t *testing.T) {
+	for lang, msgs := range messages {
+		if _, ok := uiLanguages[lang]; !ok {
+			t.Errorf("catalog language %q has no name", lang)
+		}
+		for key, format := range msgs {
+			if strings.HasPrefix(key, "cmd.") {
+				if _, ok := lookupCommand(strings.TrimPrefix(key, "cmd.")); !ok {
+					t.Errorf("%s: %s describes no command", lang, key)
+				}
+				continue
+			}
+			en, ok := messages["en"][key]
+			if !ok {
+				t.Errorf("%s: %s is not in the English catalog", lang, key)
+				continue
+			}
+			if verbs(format) != verbs(en) {
+				t.Errorf("%s: %s has verbs %q, English has %q", lang, key, verbs(format), verbs(en))
+			}
+		}
+	}
+}
+
+// verbs returns the fmt verbs of format, in order.
+func verbs(format string) string {
+	var b strings.Builder
+	for i := 0; i < len(format)-1; i++ {
+		if format[i] != '%' {
+			continue
+		}
+		j := i + 1
+		for j < len(format) && strings.IndexByte(".0123456789", format[j]) >= 0 {
+			j++
+		}
+		if j < len(format) {
+			b.WriteString(format[i : j+1])
+		}
+		i = j
+	}
+	return b.String()
+}
+
+func TestUILanguage(t *testing.T) {
+	h := &Handlers{settings: NewSettingsStore(t.TempDir())}
+	if h.uiLanguage(1) != "en" {
+		t.Fatalf("default language = %q", h.uiLanguage(1))
+	}
+	if err := h.settings.Update(1, func(s *ChatSettings) error { s.Language = "de"; return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if got := formatHelp(h.uiLanguage(1), false); !strings.HasPrefix(got, "AI Code Bot — Befehle:") || !strings.Contains(got, "/new - Sitzung zurücksetzen") {
+		t.Errorf("german help:\n%s", got)
+	}
+	if got := formatHelp("de", false); !strings.Contains(got, "/stats providers|approvals - Per-provider") {
+		t.Errorf("untranslated command description missing:\n%s", got)
+	}
+	cmd, _ := lookupCommand("freeze")
+	cmd.Settings = nil
+	if got := formatCommandHelp(cmd, h, 1); !strings.Contains(got, "Nur für Admins.") || !strings.Contains(got, "Beispiele:\n  /freeze") {
+		t.Errorf("german command help:\n%s", got)
+	}
+}
+
+func TestPromptKindsTranslated(t *testing.T) {
+	// The kinds lockFresh is called with, as its expiry notice names them.
+	for _, kind := range []string{"message", "photo", "voice message", "audio file", "file", "sticker", "GIF", "video", "video note"} {
+		key := "kind." + strings.ReplaceAll(strings.ToLower(kind), " ", "_")
+		for lang := range uiLanguages {
+			if _, ok := messages[lang][key]; !ok {
+				t.Errorf("%s: no %s", lang, key)
+			}
+		}
+	}
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The inventory lists the hosts and services the bot knows about, shared
+// by all chats. It is given to the AI at the start of each session, and
+// again for the hosts a later message names, so the model uses the real
+// names and addresses instead of its memory of them.
+
+var inventoryNameRe = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// maxInventoryHosts caps the inventory, which is sent in prompts.
+const maxInventoryHosts = 200
+
+// InventoryHost is one inventory entry.
+type InventoryHost struct {
+	Name    string            `json:"name"`
+	Address string            `json:"address"`
+	Tags    []string          `json:"tags,omitempty"`
+	Attrs   map[string]string `json:"attrs,omitempty"` // e.g. services, user, port, note
+	Added   time.Time         `json:"added"`
+}
+
+// String renders the host on one line, as in prompts and /inventory.
+func (e InventoryHost) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s", e.Name, e.Address)
+	if len(e.Tags) > 0 {
+		fmt.Fprintf(&b, " tags=%s", strings.Join(e.Tags, ","))
+	}
+	keys := make([]string, 0, len(e.Attrs))
+	for k := range e.Attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		v := e.Attrs[k]
+		if strings.ContainsAny(v, " \t") {
+			v = fmt.Sprintf("%q", v)
+		}
+		fmt.Fprintf(&b, " %s=%s", k, v)
+	}
+	return b.String()
+}
+
+// HasTag reports whether the host carries tag.
+func (e InventoryHost) HasTag(tag string) bool {
+	return slices.Contains(e.Tags, strings.ToLower(tag))
+}
+
+// parseInventoryHost builds a host from "<name> <address> [key=value ...]".
+// tags is a comma-separated list; other keys are kept as attributes.
+func parseInventoryHost(words []string) (InventoryHost, error) {
+	if len(words) < 2 {
+		return InventoryHost{}, fmt.Errorf("need a name and an address")
+	}
+	e := InventoryHost{Name: words[0], Address: words[1]}
+	if !inventoryNameRe.MatchString(e.Name) {
+		return e, fmt.Errorf("invalid name %q (letters, digits, '.', '_' and '-')", e.Name)
+	}
+	for _, w := range words[2:] {
+		k, v, ok := strings.Cut(w, "=")
+		k = strings.ToLower(strings.TrimSpace(k))
+		if !ok || k == "" {
+			return e, fmt.Errorf("%q is not key=value", w)
+		}
+		if k == "tags" {
+			for _, t := range strings.Split(v, ",") {
+				if t = strings.ToLower(strings.TrimSpace(t)); t != "" && !slices.Contains(e.Tags, t) {
+					e.Tags = append(e.Tags, t)
+				}
+			}
+			continue
+		}
+		if e.Attrs == nil {
+			e.Attrs = make(map[string]string)
+		}
+		e.Attrs[k] = v
+	}
+	return e, nil
+}
+
+// InventoryStore holds the inventory, persisted to DATA_DIR.
+type InventoryStore struct {
+	mu    sync.RWMutex
+	path  string
+	hosts map[string]InventoryHost // by lower-case name
+}
+
+func NewInventoryStore(dataDir string) *InventoryStore {
+	s := &InventoryStore{
+		path:  filepath.Join(dataDir, "inventory.json"),
+		hosts: make(map[string]InventoryHost),
+	}
+	if err := loadJSONFile(s.path, &s.hosts); err != nil {
+		slog.Warn("failed to load inventory", "path", s.path, "err", err)
+	}
+	return s
+}
+
+// Add adds or replaces a host.
+func (s *InventoryStore) Add(e InventoryHost) error {
+	key := strings.ToLower(e.Name)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.hosts[key]; !ok && len(s.hosts) >= maxInventoryHosts {
+		return fmt.Errorf("inventory is full (max %d hosts)", maxInventoryHosts)
+	}
+	s.hosts[key] = e
+	return saveJSONFile(s.path, s.hosts)
+}
+
+// Remove deletes a host, reporting whether it existed.
+func (s *InventoryStore) Remove(name string) (bool, error) {
+	key := strings.ToLower(name)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.hosts[key]; !ok {
+		return false, nil
+	}
+	delete(s.hosts, key)
+	return true, saveJSONFile(s.path, s.hosts)
+}
+
+// Get returns a host by name.
+func (s *InventoryStore) Get(name string) (InventoryHost, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	e, ok := s.hosts[strings.ToLower(name)]
+	return e, ok
+}
+
+// List returns the hosts sorted by name, only those tagged tag when it is
+// not empty.
+func (s *InventoryStore) List(tag string) []InventoryHost {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var out []InventoryHost
+	for _, e := range s.hosts {
+		if tag == "" || e.HasTag(tag) {
+			out = append(out, e)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// Mentioned returns the hosts whose name appears as a word in text.
+func (s *InventoryStore) Mentioned(text string) []InventoryHost {
+	words := make(map[string]bool)
+	for _, w := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '.' || r == '_' || r == '-')
+	}) {
+		words[strings.Trim(w, ".")] = true
+	}
+	var out []InventoryHost
+	for _, e := range s.List("") {
+		if words[strings.ToLower(e.Name)] {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// withInventory prefixes message with the inventory: all of it on the first
+// message of a session, otherwise the hosts the message names.
+func (h *Handlers) withInventory(message string, first bool) string {
+	var hosts []InventoryHost
+	if first {
+		hosts = h.inventory.List("")
+	} else {
+		hosts = h.inventory.Mentioned(message)
+	}
+	if len(hosts) == 0 {
+		return message
+	}
+	var b strings.Builder
+	b.WriteString("[Inventory: the hosts and services you can work with. Use these names and addresses; do not guess others.]\n")
+	for _, e := range hosts {
+		b.WriteString(e.String() + "\n")
+	}
+	return b.String() + "\n" + message
+}
+
+// HandleInventory lists, shows, adds or removes inventory hosts. With an
+// admin configured, only admin chats can change it.
+// Usage: /inventory [list [tag]] | show <name> | add <name> <address> [key=value ...] | rm <name>
+func (h *Handlers) HandleInventory(chatID int64, args string) {
+	a, ok := h.parseCommandArgs(chatID, "inventory", args)
+	if !ok {
+		return
+	}
+	sub := strings.ToLower(a.Arg(0))
+	if sub == "" {
+		sub = "list"
+	}
+	if (sub == "add" || sub == "rm") && len(h.admins) > 0 && !h.isAdmin(chatID) {
+		h.sender.SendPlain(chatID, h.t(chatID, "inventory.admin_only"))
+		return
+	}
+
+	switch sub {
+	case "list":
+		hosts := h.inventory.List(a.Arg(1))
+		if len(hosts) == 0 {
+			if a.Arg(1) != "" {
+				h.sender.SendPlain(chatID, h.t(chatID, "inventory.none_tagged", a.Arg(1)))
+				return
+			}
+			h.sender.SendPlain(chatID, h.t(chatID, "inventory.empty"))
+			return
+		}
+		var b strings.Builder
+		b.WriteString(h.t(chatID, "inventory.list", len(hosts)))
+		for _, e := range hosts {
+			b.WriteString("  " + e.String() + "\n")
+		}
+		h.sender.SendPlain(chatID, strings.TrimRight(b.String(), "\n"))
+
+	case "show":
+		e, found := h.inventory.Get(a.Arg(1))
+		if !found {
+			h.sender.SendPlain(chatID, h.t(chatID, "inventory.unknown", a.Arg(1)))
+			return
+		}
+		h.sender.SendPlain(chatID, h.t(chatID, "inventory.show", e, e.Added.Format("2006-01-02 15:04")))
+
+	case "add":
+		e, err := parseInventoryHost(a.Pos[1:])
+		if err != nil {
+			h.sendArgError(chatID, "inventory", err)
+			return
+		}
+		e.Added = time.Now()
+		if err := h.inventory.Add(e); err != nil {
+			h.sender.SendPlain(chatID, h.t(chatID, "inventory.add_failed", err))
+			return
+		}
+		slog.Info("inventory host added", "chat_id", chatID, "name", e.Name, "address", e.Address)
+		h.sender.SendPlain(chatID, h.t(chatID, "inventory.added", e))
+
+	case "rm":
+		found, err := h.inventory.Remove(a.Arg(1))
+		switch {
+		case err != nil:
+			h.sender.SendPlain(chatID, h.t(chatID, "inventory.rm_failed", err))
+		case !found:
+			h.sender.SendPlain(chatID, h.t(chatID, "inventory.unknown", a.Arg(1)))
+		default:
+			slog.Info("inventory host removed", "chat_id", chatID, "name", a.Arg(1))
+			h.sender.SendPlain(chatID, h.t(chatID, "inventory.removed", a.Arg(1)))
+		}
+
+	default:
+		h.sendUsage(chatID, "inventory")
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseInventoryHost(t *testing.T) {
+	a, _ := ParseArgs(`web1 10.0.0.5 tags=Prod,web,prod services=nginx "note=front door"`)
+	e, err := parseInventoryHost(a.Pos)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := e.String(); got != `web1 10.0.0.5 tags=prod,web note="front door" services=nginx` {
+		t.Errorf("host = %s", got)
+	}
+	if !e.HasTag("PROD") || e.HasTag("db") {
+		t.Errorf("tags = %v", e.Tags)
+	}
+	for _, words := range [][]string{{"web1"}, {"-x", "1.2.3.4"}, {"web1", "1.2.3.4", "prod"}} {
+		if _, err := parseInventoryHost(words); err == nil {
+			t.Errorf("%q accepted", words)
+		}
+	}
+}
+
+func TestInventory(t *testing.T) {
+	dir := t.TempDir()
+	h := &Handlers{inventory: NewInventoryStore(dir)}
+	if got := h.withInventory("hi", true); got != "hi" {
+		t.Errorf("empty inventory added: %q", got)
+	}
+	h.inventory.Add(InventoryHost{Name: "web1", Address: "10.0.0.5", Tags: []string{"prod"}})
+	h.inventory.Add(InventoryHost{Name: "db1", Address: "10.0.0.9"})
+
+	h.inventory = NewInventoryStore(dir)
+	if hosts := h.inventory.List("prod"); len(hosts) != 1 || hosts[0].Name != "web1" {
+		t.Fatalf("prod hosts = %v", hosts)
+	}
+
+	first := h.withInventory("check disk space", true)
+	if !strings.Contains(first, "db1 10.0.0.9\nweb1 10.0.0.5") || !strings.HasSuffix(first, "\n\ncheck disk space") {
+		t.Errorf("first message = %q", first)
+	}
+	later := h.withInventory("restart nginx on WEB1.", false)
+	if !strings.Contains(later, "web1 10.0.0.5") || strings.Contains(later, "db1") {
+		t.Errorf("later message = %q", later)
+	}
+	if got := h.withInventory("restart nginx on web10", false); got != "restart nginx on web10" {
+		t.Errorf("partial name matched: %q", got)
+	}
+
+	if found, _ := h.inventory.Remove("WEB1"); !found {
+		t.Error("remove by name is not case-insensitive")
+	}
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// A reply keyboard replaces the phone's keyboard with buttons for the most
+// used actions, which is quicker on mobile than typing slash commands.
+// Pressing a button sends its label as a message; HandleShortcut maps it
+// back to the action.
+
+// shortcut is an action that can be put on a chat's reply keyboard.
+type shortcut struct {
+	Name  string
+	Label string
+	Run   func(h *Handlers, ctx context.Context, chatID int64)
+}
+
+// shortcuts lists the available keyboard actions in layout order.
+var shortcuts = []shortcut{
+	{"new", "🆕 New session", func(h *Handlers, ctx context.Context, chatID int64) { h.RunCommand(ctx, chatID, "new", "") }},
+	{"status", "📊 Status", func(h *Handlers, _ context.Context, chatID int64) { h.sendStatus(chatID) }},
+	{"approve", "✅ Approve", func(h *Handlers, ctx context.Context, chatID int64) { h.decideFromKeyboard(ctx, chatID, "approve") }},
+	{"deny", "❌ Deny", func(h *Handlers, ctx context.Context, chatID int64) { h.decideFromKeyboard(ctx, chatID, "deny") }},
+	{"usage", "💰 Usage", func(h *Handlers, ctx context.Context, chatID int64) { h.RunCommand(ctx, chatID, "usage", "") }},
+	{"model", "🤖 Model", func(h *Handlers, ctx context.Context, chatID int64) { h.RunCommand(ctx, chatID, "model", "") }},
+	{"undo", "⏪ Undo", func(h *Handlers, ctx context.Context, chatID int64) { h.RunCommand(ctx, chatID, "undo", "") }},
+	{"help", "❓ Help", func(h *Handlers, ctx context.Context, chatID int64) { h.RunCommand(ctx, chatID, "help", "") }},
+}
+
+// defaultShortcuts is the keyboard of /keyboard on.
+var defaultShortcuts = []string{"new", "status", "approve", "usage"}
+
+func lookupShortcut(name string) (shortcut, bool) {
+	for _, s := range shortcuts {
+		if s.Name == name {
+			return s, true
+		}
+	}
+	return shortcut{}, false
+}
+
+// shortcutKey normalizes a button label for matching, so that labels with
+// their emojis stripped (plain-text mode) still match.
+func shortcutKey(label string) string {
+	return strings.ToLower(strings.TrimSpace(stripEmoji(label)))
+}
+
+// KeyboardStore holds the reply keyboard actions of the chats that turned
+// it on.
+type KeyboardStore struct {
+	mu    sync.RWMutex
+	chats map[int64][]string
+}
+
+func NewKeyboardStore() *KeyboardStore {
+	return &KeyboardStore{chats: make(map[int64][]string)}
+}
+
+// Get returns the chat's keyboard actions, or nil if it has none.
+func (s *KeyboardStore) Get(chatID int64) []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.chats[chatID]
+}
+
+func (s *KeyboardStore) Set(chatID int64, actions []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.chats[chatID] = actions
+}
+
+func (s *KeyboardStore) Delete(chatID int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.chats, chatID)
+}
+
+// replyKeyboard lays out actions two buttons per row.
+func replyKeyboard(actions []string, plain bool) tgbotapi.ReplyKeyboardMarkup {
+	var rows [][]tgbotapi.KeyboardButton
+	var row []tgbotapi.KeyboardButton
+	for _, name := range actions {
+		s, ok := lookupShortcut(name)
+		if !ok {
+			continue
+		}
+		label := s.Label
+		if plain {
+			label = strings.TrimSpace(stripEmoji(label))
+		}
+		row = append(row, tgbotapi.NewKeyboardButton(label))
+		if len(row) == 2 {
+			rows = append(rows, row)
+			row = nil
+		}
+	}
+	if len(row) > 0 {
+		rows = append(rows, row)
+	}
+	keyboard := tgbotapi.NewReplyKeyboard(rows...)
+	keyboard.ResizeKeyboard = true
+	keyboard.InputFieldPlaceholder = "Message the AI…"
+	return keyboard
+}
+
+// HandleShortcut runs the keyboard action whose label is text, reporting
+// whether text was one. Chats without a keyboard never match, so a user
+// typing "Status" to the AI is not intercepted.
+func (h *Handlers) HandleShortcut(ctx context.Context, chatID int64, text string) bool {
+	key := shortcutKey(text)
+	for _, name := range h.keyboards.Get(chatID) {
+		if s, ok := lookupShortcut(name); ok && shortcutKey(s.Label) == key {
+			s.Run(h, ctx, chatID)
+			return true
+		}
+	}
+	return false
+}
+
+// decideFromKeyboard approves or denies the pending command, as its
+// approval card's buttons would.
+func (h *Handlers) decideFromKeyboard(ctx context.Context, chatID int64, decision string) {
+	owner := chatID
+	if o, ok := h.shares.CollabOwner(chatID); ok {
+		owner = o
+	}
+	turn := h.approvals.Get(owner)
+	if turn == nil {
+		h.sender.SendPlain(chatID, h.t(chatID, "pending.none"))
+		return
+	}
+	h.HandleCallback(ctx, chatID, "", decision, turn.Cards[chatID], "")
+}
+
+// sendStatus summarizes the chat's AI, session and pending command.
+func (h *Handlers) sendStatus(chatID int64) {
+	provider := h.providers.Get(chatID)
+	var b strings.Builder
+	b.WriteString(h.t(chatID, "status.ai", providerLabel(provider)))
+	model := h.chatModel(chatID, provider)
+	if provider == "claude" {
+		model = h.claudeModelName(chatID, model)
+	}
+	if model != "" {
+		fmt.Fprintf(&b, " (%s)", model)
+	}
+	session := h.t(chatID, "status.session_new")
+	if provider == "claude" && h.sessions.Get(chatID) != "" {
+		session = h.t(chatID, "status.session_resumed")
+	} else if isChatProvider(provider) || provider == "gemini" {
+		if n := len(h.geminiSessions.Get(chatID)); n > 0 {
+			session = h.t(chatID, "status.session_messages", n)
+		}
+	}
+	b.WriteString(h.t(chatID, "status.session", session))
+	if name, _ := h.projects.Active(chatID); name != "" {
+		b.WriteString(h.t(chatID, "status.project", name))
+	}
+	if turn := h.approvals.Get(chatID); turn != nil {
+		b.WriteString(h.t(chatID, "status.pending", turn.CurrentIdx+1, len(turn.Commands), turn.Commands[turn.CurrentIdx]))
+	} else {
+		b.WriteString(h.t(chatID, "status.pending_none"))
+	}
+	h.sender.SendPlain(chatID, b.String())
+}
+
+// shortcutList describes the available actions for /keyboard.
+func shortcutList() string {
+	names := make([]string, len(shortcuts))
+	for i, s := range shortcuts {
+		names[i] = s.Name
+	}
+	return strings.Join(names, ", ")
+}
+
+// HandleKeyboard shows, sets or removes the chat's reply keyboard.
+// Usage: /keyboard [on|off|<action>...]
+func (h *Handlers) HandleKeyboard(chatID int64, args string) {
+	fields := strings.Fields(strings.ToLower(args))
+	switch {
+	case len(fields) == 0:
+		state := h.onOff(chatID, false)
+		if actions := h.keyboards.Get(chatID); actions != nil {
+			state = h.onOff(chatID, true) + " (" + strings.Join(actions, ", ") + ")"
+		}
+		h.sender.SendPlain(chatID, h.t(chatID, "keyboard.state", state, shortcutList()))
+		return
+	case len(fields) == 1 && fields[0] == "off":
+		h.keyboards.Delete(chatID)
+		h.sender.SendReplyMarkup(chatID, h.t(chatID, "keyboard.removed"), tgbotapi.NewRemoveKeyboard(false))
+		return
+	case len(fields) == 1 && fields[0] == "on":
+		if h.keyboards.Get(chatID) == nil {
+			h.keyboards.Set(chatID, defaultShortcuts)
+		}
+	default:
+		var actions []string
+		for _, f := range fields {
+			if _, ok := lookupShortcut(f); !ok {
+				h.sender.SendPlain(chatID, h.t(chatID, "keyboard.unknown", f, shortcutList()))
+				return
+			}
+			actions = append(actions, f)
+		}
+		h.keyboards.Set(chatID, actions)
+	}
+	keyboard := replyKeyboard(h.keyboards.Get(chatID), h.sender.PlainMode(chatID))
+	h.sender.SendReplyMarkup(chatID, h.t(chatID, "keyboard.enabled"), keyboard)
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestReplyKeyboard(t *testing.T) {
+	kb := replyKeyboard([]string{"new", "status", "approve", "nope", "usage", "deny"}, false)
+	if len(kb.Keyboard) != 3 || len(kb.Keyboard[2]) != 1 {
+		t.Fatalf("rows = %v, want 2+2+1 buttons", kb.Keyboard)
+	}
+	if got := kb.Keyboard[0][0].Text; got != "🆕 New session" {
+		t.Errorf("first button = %q", got)
+	}
+	if !kb.ResizeKeyboard {
+		t.Error("keyboard not resized")
+	}
+	plain := replyKeyboard([]string{"approve"}, true)
+	if got := plain.Keyboard[0][0].Text; got != "Approve" {
+		t.Errorf("plain label = %q", got)
+	}
+}
+
+func TestHandleShortcutMatching(t *testing.T) {
+	var ran []string
+	saved := shortcuts
+	defer func() { shortcuts = saved }()
+	shortcuts = []shortcut{
+		{"status", "📊 Status", func(_ *Handlers, _ context.Context, _ int64) { ran = append(ran, "status") }},
+		{"usage", "💰 Usage", func(_ *Handlers, _ context.Context, _ int64) { ran = append(ran, "usage") }},
+	}
+	h := &Handlers{keyboards: NewKeyboardStore()}
+
+	if h.HandleShortcut(context.Background(), 1, "📊 Status") {
+		t.Error("matched in a chat without a keyboard")
+	}
+	h.keyboards.Set(1, []string{"status"})
+	if !h.HandleShortcut(context.Background(), 1, "📊 Status") || !h.HandleShortcut(context.Background(), 1, "Status") {
+		t.Error("button label (or its plain form) not matched")
+	}
+	if h.HandleShortcut(context.Background(), 1, "💰 Usage") {
+		t.Error("matched an action not on the chat's keyboard")
+	}
+	if h.HandleShortcut(context.Background(), 1, "what is the status of the build?") {
+		t.Error("matched an ordinary message")
+	}
+	if len(ran) != 2 || ran[0] != "status" {
+		t.Errorf("ran = %v", ran)
+	}
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// The system prompts are in English, which pulls models towards answering
+// in English whatever the user writes. With REPLY_LANGUAGE=auto the language
+// of each user message is detected and the model is told to answer in it.
+
+var (
+	// Code, commands and URLs say nothing about the user's language.
+	langCodeRe = regexp.MustCompile("(?s)```.*?```|`[^`]*`|https?://\\S+")
+)
+
+// langScripts maps non-Latin scripts to the language they most likely mean.
+var langScripts = []struct {
+	table *unicode.RangeTable
+	name  string
+}{
+	{unicode.Hangul, "Korean"},
+	{unicode.Hiragana, "Japanese"},
+	{unicode.Katakana, "Japanese"},
+	{unicode.Han, "Chinese"},
+	{unicode.Cyrillic, "Russian"},
+	{unicode.Arabic, "Arabic"},
+	{unicode.Hebrew, "Hebrew"},
+	{unicode.Greek, "Greek"},
+	{unicode.Devanagari, "Hindi"},
+	{unicode.Thai, "Thai"},
+}
+
+// langStopwords are frequent short words of the Latin-script languages.
+// Several are shared between languages; the best total wins.
+var langStopwords = map[string][]string{
+	"English":    {"the", "and", "is", "are", "you", "to", "of", "it", "that", "this", "what", "how", "with", "for", "can", "please", "my", "not", "do", "does", "why", "there"},
+	"Italian":    {"il", "lo", "gli", "di", "che", "è", "non", "per", "una", "sono", "come", "questo", "perché", "anche", "mi", "ciao", "grazie", "della", "nel", "cosa", "fammi", "puoi"},
+	"Spanish":    {"el", "los", "las", "que", "y", "es", "por", "una", "para", "con", "como", "está", "qué", "pero", "hola", "gracias", "del", "se", "puedes", "hay", "muy"},
+	"French":     {"le", "les", "des", "et", "est", "une", "qui", "pas", "pour", "dans", "avec", "je", "vous", "ce", "sur", "bonjour", "merci", "du", "peux", "c'est", "au"},
+	"German":     {"der", "die", "das", "und", "ist", "nicht", "ich", "du", "sie", "ein", "eine", "zu", "mit", "auf", "für", "was", "wie", "bitte", "danke", "den", "dem", "auch"},
+	"Portuguese": {"os", "que", "e", "é", "não", "um", "uma", "para", "com", "como", "do", "da", "em", "você", "obrigado", "olá", "isso", "por", "está", "pode"},
+	"Dutch":      {"het", "een", "en", "is", "niet", "ik", "je", "van", "dat", "op", "te", "met", "voor", "wat", "hoe", "zijn", "maar", "ook", "dank", "kun"},
+}
+
+// langMarks are letters that point strongly at one language.
+var langMarks = map[rune]string{
+	'ñ': "Spanish", '¿': "Spanish", '¡': "Spanish",
+	'ã': "Portuguese", 'õ': "Portuguese",
+	'ß': "German", 'ä': "German", 'ö': "German", 'ü': "German",
+	'ç': "French", 'ê': "French", 'œ': "French",
+	'ì': "Italian", 'ò': "Italian",
+	'і': "Ukrainian", 'ї': "Ukrainian", 'є': "Ukrainian", 'ґ': "Ukrainian",
+}
+
+// detectLanguage guesses the language of a user message, returning "" when
+// it is too short or ambiguous to tell (e.g. "ls -la" or "yes").
+func detectLanguage(text string) string {
+	text = strings.ToLower(langCodeRe.ReplaceAllString(text, " "))
+
+	var latin int
+	scripts := map[string]int{}
+	marks := map[string]int{}
+	for _, r := range text {
+		if lang, ok := langMarks[r]; ok {
+			marks[lang]++
+		}
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		if unicode.Is(unicode.Latin, r) {
+			latin++
+			continue
+		}
+		for _, s := range langScripts {
+			if unicode.Is(s.table, r) {
+				scripts[s.name]++
+				break
+			}
+		}
+	}
+	// Kana alongside kanji is Japanese, not Chinese.
+	if scripts["Japanese"] > 0 {
+		scripts["Japanese"] += scripts["Chinese"]
+		delete(scripts, "Chinese")
+	}
+	if scripts["Russian"] > 0 && marks["Ukrainian"] > 0 {
+		scripts["Ukrainian"] = scripts["Russian"]
+		delete(scripts, "Russian")
+	}
+	if name, n := best(scripts); n > 0 && n >= latin {
+		return name
+	}
+
+	words := strings.FieldsFunc(text, func(r rune) bool { return !unicode.IsLetter(r) && r != '\'' })
+	if len(words) < 3 {
+		return ""
+	}
+	scores := map[string]int{}
+	for _, w := range words {
+		for lang, stop := range langStopwords {
+			for _, s := range stop {
+				if w == s {
+					scores[lang]++
+					break
+				}
+			}
+		}
+	}
+	for lang, n := range marks {
+		scores[lang] += 2 * n
+	}
+	name, top := best(scores)
+	if top < 2 {
+		return ""
+	}
+	for lang, n := range scores {
+		if lang != name && n == top {
+			return ""
+		}
+	}
+	return name
+}
+
+// best returns the key with the highest count.
+func best(counts map[string]int) (string, int) {
+	var name string
+	var top int
+	for k, n := range counts {
+		if n > top || (n == top && k < name) {
+			name, top = k, n
+		}
+	}
+	return name, top
+}
+
+// LanguageStore remembers the language each chat last wrote in, so short
+// messages ("yes", "go on") keep the conversation's language.
+type LanguageStore struct {
+	mu    sync.RWMutex
+	langs map[int64]string
+}
+
+func NewLanguageStore() *LanguageStore {
+	return &LanguageStore{langs: make(map[int64]string)}
+}
+
+// Observe records the language of a user message, if it can be told.
+func (s *LanguageStore) Observe(chatID int64, text string) {
+	lang := detectLanguage(text)
+	if lang == "" {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.langs[chatID] = lang
+}
+
+// Get returns the chat's language, or "" if not known yet.
+func (s *LanguageStore) Get(chatID int64) string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.langs[chatID]
+}
+
+// replyLanguage returns the language the model should answer chatID in, or
+// "" to leave it alone: REPLY_LANGUAGE=off, or auto with English or unknown
+// input, since the prompts already lean English.
+func (h *Handlers) replyLanguage(chatID int64) string {
+	switch h.replyLang {
+	case "off":
+		return ""
+	case "auto":
+		if lang := h.languages.Get(chatID); lang != "English" {
+			return lang
+		}
+		return ""
+	}
+	return h.replyLang
+}
+
+// withLanguagePrompt prefixes message with an instruction to answer in the
+// chat's reply language.
+func (h *Handlers) withLanguagePrompt(chatID int64, message string) string {
+	lang := h.replyLanguage(chatID)
+	if lang == "" {
+		return message
+	}
+	return "[Reply in " + lang + ". Keep commands, code, file names and tags unchanged.]\n" + message
+}
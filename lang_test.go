@@ -0,0 +1,53 @@
+package main
+
+import "testing"
+
+func TestDetectLanguage(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"what is using all the disk space on this server?", "English"},
+		{"ciao, puoi controllare perché il servizio non parte?", "Italian"},
+		{"hola, ¿puedes ver por qué el contenedor se reinicia?", "Spanish"},
+		{"bonjour, est-ce que tu peux vérifier les logs du serveur pour moi", "French"},
+		{"kannst du bitte prüfen, warum der Dienst nicht startet und was das Problem ist", "German"},
+		{"olá, você pode ver por que o serviço não está rodando?", "Portuguese"},
+		{"почему не запускается контейнер?", "Russian"},
+		{"чому не запускається контейнер? Перевір, будь ласка, її логи", "Ukrainian"},
+		{"コンテナが起動しない理由を調べて", "Japanese"},
+		{"为什么容器无法启动", "Chinese"},
+		{"컨테이너가 시작되지 않는 이유", "Korean"},
+		{"ls -la", ""},
+		{"yes", ""},
+		{"perché `docker ps` non mostra il container? vedi https://example.com/the/docs", "Italian"},
+	}
+	for _, tt := range tests {
+		if got := detectLanguage(tt.in); got != tt.want {
+			t.Errorf("detectLanguage(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestReplyLanguage(t *testing.T) {
+	h := &Handlers{languages: NewLanguageStore(), replyLang: "auto"}
+	if got := h.withLanguagePrompt(1, "hi"); got != "hi" {
+		t.Errorf("unknown language should add nothing, got %q", got)
+	}
+	h.languages.Observe(1, "ciao, puoi controllare perché il servizio non parte?")
+	h.languages.Observe(1, "ok") // too short to change the chat's language
+	if got := h.replyLanguage(1); got != "Italian" {
+		t.Errorf("replyLanguage = %q, want Italian", got)
+	}
+	h.languages.Observe(1, "what is using all the disk space on this server?")
+	if got := h.replyLanguage(1); got != "" {
+		t.Errorf("English needs no instruction, got %q", got)
+	}
+
+	h.replyLang = "German"
+	if got := h.replyLanguage(1); got != "German" {
+		t.Errorf("fixed language = %q", got)
+	}
+	h.replyLang = "off"
+	h.languages.Observe(1, "ciao, puoi controllare perché il servizio non parte?")
+	if got := h.replyLanguage(1); got != "" {
+		t.Errorf("off = %q", got)
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// SetupLogging installs the default slog logger. level is debug, info, warn
+// or error; format is text or json (one object per line, for log
+// aggregation). Anything still written via the standard log package goes
+// through the same handler.
+func SetupLogging(level, format string) error {
+	var lvl slog.Level
+	if level != "" {
+		if err := lvl.UnmarshalText([]byte(level)); err != nil {
+			return fmt.Errorf("invalid LOG_LEVEL %q (want debug, info, warn or error)", level)
+		}
+	}
+	opts := &slog.HandlerOptions{Level: lvl}
+
+	var handler slog.Handler
+	switch strings.ToLower(format) {
+	case "", "text":
+		handler = slog.NewTextHandler(os.Stderr, opts)
+	case "json":
+		handler = slog.NewJSONHandler(os.Stderr, opts)
+	default:
+		return fmt.Errorf("invalid LOG_FORMAT %q (want text or json)", format)
+	}
+	slog.SetDefault(slog.New(handler))
+	return nil
+}
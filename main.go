@@ -1,29 +1,54 @@
 package main
 
 import (
-	"log"
+	"context"
+	"flag"
+	"log/slog"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 )
 
 func main() {
-	cfg, err := LoadConfig()
+	configFile := flag.String("config", "", "YAML config file; environment variables override its values")
+	flag.Parse()
+
+	cfg, err := LoadConfig(*configFile)
 	if err != nil {
-		log.Fatalf("config error: %v", err)
+		slog.Error("config error", "err", err)
+		os.Exit(1)
+	}
+
+	if err := SetupLogging(cfg.LogLevel, cfg.LogFormat); err != nil {
+		slog.Error("logging setup error", "err", err)
+		os.Exit(1)
 	}
 
+	StartReaper()
+
 	if err := SetupGit(cfg); err != nil {
-		log.Printf("WARN: git setup failed: %v", err)
+		slog.Warn("git setup failed", "err", err)
+	}
+
+	if err := SyncRepos(cfg); err != nil {
+		slog.Warn("repo sync failed", "err", err)
 	}
 
 	if err := SetupNgrok(cfg); err != nil {
-		log.Printf("WARN: ngrok setup failed: %v", err)
+		slog.Warn("ngrok setup failed", "err", err)
+	}
+
+	shutdownTracing, err := SetupTracing(cfg)
+	if err != nil {
+		slog.Error("tracing setup error", "err", err)
+		os.Exit(1)
 	}
 
 	bot, err := NewBot(cfg)
 	if err != nil {
-		log.Fatalf("bot init error: %v", err)
+		slog.Error("bot init error", "err", err)
+		os.Exit(1)
 	}
 
 	stop := make(chan os.Signal, 1)
@@ -31,7 +56,27 @@ func main() {
 
 	go bot.Run()
 
-	log.Println("Bot is running. Press Ctrl+C to stop.")
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			slog.Info("SIGHUP received, reloading config")
+			bot.Reload()
+		}
+	}()
+
+	slog.Info("Bot is running. Press Ctrl+C to stop.")
 	<-stop
-	log.Println("Shutting down...")
+	slog.Info("Shutting down...", "timeout", cfg.ShutdownTimeout)
+	go func() {
+		<-stop
+		slog.Warn("second signal, exiting immediately")
+		os.Exit(1)
+	}()
+	bot.Shutdown(cfg.ShutdownTimeout)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := shutdownTracing(ctx); err != nil {
+		slog.Warn("flushing traces failed", "err", err)
+	}
 }
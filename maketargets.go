@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bufio"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// maxMakeButtons caps how many targets /make offers as buttons.
+const maxMakeButtons = 30
+
+// maxCallbackData is Telegram's limit on inline button callback data.
+const maxCallbackData = 64
+
+// makeTargetRe matches a Makefile rule header ("build:" or "test lint: deps")
+// but not variable assignments (":=", "::=").
+var makeTargetRe = regexp.MustCompile(`^([A-Za-z0-9][A-Za-z0-9_./-]*(?:\s+[A-Za-z0-9][A-Za-z0-9_./-]*)*)\s*:(?:[^=:]|$)`)
+
+// taskNameRe matches a task key directly under "tasks:" in a Taskfile.
+var taskNameRe = regexp.MustCompile(`^(\s+)([A-Za-z0-9][A-Za-z0-9_:.-]*):\s*(?:#.*)?$`)
+
+// MakeTarget is a runnable project shortcut found in a Makefile or Taskfile.
+type MakeTarget struct {
+	Name    string
+	Command string
+}
+
+// discoverMakeTargets lists targets from dir's Makefile and Taskfile, if any.
+func discoverMakeTargets(dir string) []MakeTarget {
+	var targets []MakeTarget
+	for _, name := range []string{"GNUmakefile", "Makefile", "makefile"} {
+		if names, err := parseMakefile(filepath.Join(dir, name)); err == nil {
+			for _, n := range names {
+				targets = append(targets, MakeTarget{Name: n, Command: "make " + n})
+			}
+			break
+		}
+	}
+	for _, name := range []string{"Taskfile.yml", "Taskfile.yaml", "taskfile.yml", "taskfile.yaml"} {
+		if names, err := parseTaskfile(filepath.Join(dir, name)); err == nil {
+			for _, n := range names {
+				targets = append(targets, MakeTarget{Name: n, Command: "task " + n})
+			}
+			break
+		}
+	}
+	return targets
+}
+
+// parseMakefile returns the explicit targets of a Makefile, skipping special
+// (.PHONY) and pattern (%) rules.
+func parseMakefile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	seen := make(map[string]bool)
+	var names []string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := sc.Text()
+		if strings.HasPrefix(line, "\t") {
+			continue // recipe
+		}
+		m := makeTargetRe.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		for _, n := range strings.Fields(m[1]) {
+			if strings.ContainsAny(n, "%$") || seen[n] {
+				continue
+			}
+			seen[n] = true
+			names = append(names, n)
+		}
+	}
+	return names, sc.Err()
+}
+
+// parseTaskfile returns the task names of a Taskfile without a full YAML
+// parse: the keys one indentation level below the top-level "tasks:" key.
+func parseTaskfile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var names []string
+	inTasks := false
+	indent := ""
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimRight(sc.Text(), " \t")
+		if line == "" || strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+		if !strings.HasPrefix(line, " ") && !strings.HasPrefix(line, "\t") {
+			inTasks = strings.HasPrefix(line, "tasks:")
+			continue
+		}
+		if !inTasks {
+			continue
+		}
+		m := taskNameRe.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		if indent == "" {
+			indent = m[1]
+		}
+		if m[1] == indent {
+			names = append(names, m[2])
+		}
+	}
+	sort.Strings(names)
+	return names, sc.Err()
+}
+
+// HandleMake lists the project's Makefile/Taskfile targets as buttons.
+func (h *Handlers) HandleMake(chatID int64) {
+	_, dir := h.projects.Active(chatID)
+	var targets []MakeTarget
+	for _, t := range discoverMakeTargets(dir) {
+		if len("make:"+t.Command) <= maxCallbackData {
+			targets = append(targets, t)
+		}
+	}
+	if len(targets) == 0 {
+		h.sender.SendPlain(chatID, h.t(chatID, "make.none", dir))
+		return
+	}
+	if len(targets) > maxMakeButtons {
+		targets = targets[:maxMakeButtons]
+	}
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for i := 0; i < len(targets); i += 2 {
+		row := tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(targets[i].Command, "make:"+targets[i].Command))
+		if i+1 < len(targets) {
+			row = append(row, tgbotapi.NewInlineKeyboardButtonData(targets[i+1].Command, "make:"+targets[i+1].Command))
+		}
+		rows = append(rows, row)
+	}
+	h.sender.SendWithKeyboard(chatID, h.t(chatID, "make.choose"), tgbotapi.NewInlineKeyboardMarkup(rows...))
+}
+
+// handleMakeCallback queues the selected target for approval. The command must
+// still be one of the project's targets, so a stale or forged button cannot
+// run anything else.
+func (h *Handlers) handleMakeCallback(chatID int64, callbackID, data string, messageID int) {
+	command := strings.TrimPrefix(data, "make:")
+	_, dir := h.projects.Active(chatID)
+	found := false
+	for _, t := range discoverMakeTargets(dir) {
+		if t.Command == command {
+			found = true
+			break
+		}
+	}
+	if !found {
+		h.sender.AnswerCallback(callbackID, h.t(chatID, "make.gone"))
+		return
+	}
+	if h.approvals.Has(chatID) {
+		h.sender.AnswerCallback(callbackID, h.t(chatID, "make.pending"))
+		return
+	}
+
+	slog.Info("make target selected", "chat_id", chatID, "command", command)
+	h.sender.AnswerCallback(callbackID, "")
+	h.sender.EditRemoveKeyboard(chatID, messageID, h.t(chatID, "make.selected", command))
+	turn := &PendingTurn{
+		Commands: []string{command},
+		Results:  make([]CommandResult, 0, 1),
+		Provider: h.providers.Get(chatID),
+		Manual:   true,
+	}
+	h.approvals.Set(chatID, turn)
+	h.showApproval(chatID, turn)
+}
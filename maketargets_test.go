@@ -0,0 +1,34 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDiscoverMakeTargets(t *testing.T) {
+	dir := t.TempDir()
+	makefile := "GO ?= go\nVERSION := 1.0\n.PHONY: build test\n\nbuild: deps\n\t$(GO) build ./...\n\ntest lint:\n\t$(GO) test ./...\n\n%.o: %.c\n\tcc -c $<\n\nbuild:\n\techo again\n"
+	taskfile := "version: '3'\n\nvars:\n  NAME: app\n\ntasks:\n  dev:\n    cmds:\n      - go run .\n  docker:build:\n    desc: Build image\n    cmds:\n      - docker build .\n"
+	os.WriteFile(filepath.Join(dir, "Makefile"), []byte(makefile), 0o644)
+	os.WriteFile(filepath.Join(dir, "Taskfile.yml"), []byte(taskfile), 0o644)
+
+	got := discoverMakeTargets(dir)
+	want := []MakeTarget{
+		{Name: "build", Command: "make build"},
+		{Name: "test", Command: "make test"},
+		{Name: "lint", Command: "make lint"},
+		{Name: "dev", Command: "task dev"},
+		{Name: "docker:build", Command: "task docker:build"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("discoverMakeTargets() = %v, want %v", got, want)
+	}
+}
+
+func TestDiscoverMakeTargetsNone(t *testing.T) {
+	if got := discoverMakeTargets(t.TempDir()); len(got) != 0 {
+		t.Errorf("expected no targets, got %v", got)
+	}
+}
@@ -2,7 +2,10 @@ package main
 
 import (
 	"regexp"
+	"strconv"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 )
 
 // Telegram MarkdownV2 special characters that must be escaped outside formatting.
@@ -13,18 +16,63 @@ var (
 	fencedCodeRe = regexp.MustCompile("(?s)```([a-zA-Z]*)\\n?(.*?)```")
 	// Matches inline code: `...`
 	inlineCodeRe = regexp.MustCompile("`([^`]+)`")
+	// Matches bold italic: ***text***
+	boldItalicRe = regexp.MustCompile(`\*\*\*([^\s*](?:[^\n]*?[^\s*])?)\*\*\*`)
 	// Matches bold: **text**
 	boldRe = regexp.MustCompile(`\*\*(.+?)\*\*`)
-	// Matches italic with underscores: _text_ (but not inside words)
-	italicUnderRe = regexp.MustCompile(`(?:^|(?:\s))_(.+?)_(?:$|(?:\s))`)
+	// Matches italic with asterisks: *text*
+	italicStarRe = regexp.MustCompile(`\*([^\s*](?:[^*\n]*?[^\s*])?)\*`)
+	// Matches underline: __text__ (not inside words, checked by replaceDelimited)
+	underlineRe = regexp.MustCompile(`(^|[^\pL\pN_])__([^\s_](?:[^\n]*?[^\s_])?)__`)
+	// Matches italic with underscores: _text_ (not inside words, checked by replaceDelimited)
+	italicUnderRe = regexp.MustCompile(`(^|[^\pL\pN_])_([^\s_](?:[^_\n]*?[^\s_])?)_`)
 	// Matches strikethrough: ~~text~~
 	strikeRe = regexp.MustCompile(`~~(.+?)~~`)
 	// Matches markdown links: [text](url)
 	linkRe = regexp.MustCompile(`\[([^\]]+)\]\(([^)]+)\)`)
 	// Matches heading lines: # ... ## ... ### ...
 	headingRe = regexp.MustCompile(`(?m)^(#{1,6})\s+(.+)$`)
+	// Matches blockquote lines: > text
+	quoteLineRe = regexp.MustCompile(`^>\s?(.*)$`)
+	// Matches unordered list items: - item, * item, + item
+	bulletRe = regexp.MustCompile(`^([ \t]*)[-*+]\s+(.*)$`)
+	// Matches ordered list items: 1. item, 1) item
+	orderedRe = regexp.MustCompile(`^([ \t]*)(\d{1,9})[.)]\s+(.*)$`)
 )
 
+// Formatting is marked with private-use runes while converting, so escaping
+// the text afterwards cannot touch it; restoreMarkers turns them into the
+// MarkdownV2 markers at the end.
+const (
+	mBold        = '\uE000'
+	mItalic      = '\uE001'
+	mUnderline   = '\uE002'
+	mStrike      = '\uE003'
+	mQuote       = '\uE004' // start of a blockquote line
+	mQuoteExpand = '\uE005' // start of the first line of an expandable blockquote
+	mQuoteEnd    = '\uE006' // end of an expandable blockquote
+)
+
+var markerReplacer = strings.NewReplacer(
+	// "___" is read as underline then italic, so italic followed by
+	// underline needs an empty \r between them.
+	string(mItalic)+string(mUnderline), "_\r__",
+	string(mBold), "*",
+	string(mItalic), "_",
+	string(mUnderline), "__",
+	string(mStrike), "~",
+	string(mQuote), ">",
+	string(mQuoteExpand), "**>",
+	string(mQuoteEnd), "||",
+)
+
+// Blockquotes longer than this many lines are sent as expandable blockquotes,
+// collapsed to a few lines until tapped.
+const expandableQuoteLines = 5
+
+// bulletSymbols are the list bullets by nesting level.
+var bulletSymbols = []string{"•", "◦", "▪"}
+
 // ToTelegramMarkdownV2 converts CommonMark to Telegram MarkdownV2 format.
 func ToTelegramMarkdownV2(text string) string {
 	// Split input by fenced code blocks to process them separately.
@@ -83,126 +131,261 @@ func splitByCodeBlocks(text string) []textPart {
 }
 
 // convertInlineMarkdown converts non-code-block text to MarkdownV2.
+// Each pass is skipped when its delimiter does not occur in the text, which
+// keeps long plain outputs (logs, listings) away from the regex engine.
 func convertInlineMarkdown(text string) string {
-	// Process inline code spans first — extract them, convert the rest, re-insert.
-	type codeSpan struct {
-		placeholder string
-		converted   string
-	}
-	var spans []codeSpan
-	counter := 0
-
-	processed := inlineCodeRe.ReplaceAllStringFunc(text, func(match string) string {
-		inner := inlineCodeRe.FindStringSubmatch(match)[1]
-		placeholder := "\x00ICODE" + strings.Repeat("X", counter) + "\x00"
-		counter++
-		spans = append(spans, codeSpan{
-			placeholder: placeholder,
-			converted:   "`" + escapeInlineCode(inner) + "`",
+	// Inline code spans and links are swapped for placeholders so nothing
+	// below touches them, and restored in one pass at the end.
+	var spans []string
+	if strings.IndexByte(text, '`') >= 0 {
+		text = replaceSubmatches(inlineCodeRe, text, func(sub []string) string {
+			spans = append(spans, "`"+escapeInlineCode(sub[1])+"`")
+			return placeholder(len(spans) - 1)
 		})
-		return placeholder
-	})
-
-	// Process links — extract them to protect from escaping.
-	type linkSpan struct {
-		placeholder string
-		converted   string
-	}
-	var links []linkSpan
-	linkCounter := 0
-
-	processed = linkRe.ReplaceAllStringFunc(processed, func(match string) string {
-		sub := linkRe.FindStringSubmatch(match)
-		linkText := sub[1]
-		url := sub[2]
-		placeholder := "\x00LINK" + strings.Repeat("X", linkCounter) + "\x00"
-		linkCounter++
-		// Escape special chars in link text, escape ) and \ in URL
-		escapedText := escapeMarkdownV2(linkText)
-		escapedURL := strings.ReplaceAll(url, `\`, `\\`)
-		escapedURL = strings.ReplaceAll(escapedURL, `)`, `\)`)
-		links = append(links, linkSpan{
-			placeholder: placeholder,
-			converted:   "[" + escapedText + "](" + escapedURL + ")",
+	}
+	if strings.Contains(text, "](") {
+		text = replaceSubmatches(linkRe, text, func(sub []string) string {
+			// Escape special chars in link text, escape ) and \ in URL
+			escapedURL := strings.ReplaceAll(sub[2], `\`, `\\`)
+			escapedURL = strings.ReplaceAll(escapedURL, `)`, `\)`)
+			spans = append(spans, "["+escapeMarkdownV2(sub[1])+"]("+escapedURL+")")
+			return placeholder(len(spans) - 1)
 		})
-		return placeholder
-	})
+	}
 
-	// Convert headings: # Title -> *Title* (bold)
-	processed = headingRe.ReplaceAllStringFunc(processed, func(match string) string {
-		sub := headingRe.FindStringSubmatch(match)
-		return "*" + sub[2] + "*"
-	})
+	// Line-level constructs: lists, blockquotes, headings.
+	text = normalizeLists(text)
+	text = convertBlockquotes(text)
 
-	// Convert bold: **text** -> *text*
-	processed = boldRe.ReplaceAllString(processed, "*$1*")
+	// Convert headings: # Title -> *Title* (bold). Bold inside a heading
+	// would close the heading's own bold early, so it is dropped.
+	if strings.IndexByte(text, '#') >= 0 {
+		text = replaceSubmatches(headingRe, text, func(sub []string) string {
+			return wrap(mBold, strings.ReplaceAll(sub[2], "**", ""))
+		})
+	}
 
-	// Convert strikethrough: ~~text~~ -> ~text~
-	processed = strikeRe.ReplaceAllString(processed, "~$1~")
+	// Inline emphasis, longest delimiters first.
+	if strings.IndexByte(text, '*') >= 0 {
+		if strings.Contains(text, "***") {
+			text = boldItalicRe.ReplaceAllString(text, string(mBold)+string(mItalic)+"$1"+string(mItalic)+string(mBold))
+		}
+		if strings.Contains(text, "**") {
+			text = boldRe.ReplaceAllString(text, wrap(mBold, "$1"))
+		}
+		text = italicStarRe.ReplaceAllString(text, wrap(mItalic, "$1"))
+	}
+	if strings.IndexByte(text, '_') >= 0 {
+		if strings.Contains(text, "__") {
+			text = replaceDelimited(underlineRe, text, "__", mUnderline)
+		}
+		text = replaceDelimited(italicUnderRe, text, "_", mItalic)
+	}
+	if strings.Contains(text, "~~") {
+		text = strikeRe.ReplaceAllString(text, wrap(mStrike, "$1"))
+	}
 
-	// Now escape all MarkdownV2 special chars in the non-formatted portions.
-	// We need to do this carefully: split by our formatting markers.
-	processed = escapePreservingFormatting(processed)
+	// Escape everything that is left, then turn the markers into MarkdownV2.
+	text = markerReplacer.Replace(escapeMarkdownV2(text))
 
-	// Re-insert inline code spans and links.
-	for _, s := range spans {
-		processed = strings.Replace(processed, escapeMarkdownV2(s.placeholder), s.converted, 1)
+	if len(spans) == 0 {
+		return text
 	}
-	for _, l := range links {
-		processed = strings.Replace(processed, escapeMarkdownV2(l.placeholder), l.converted, 1)
+	// Placeholders contain no special characters, so escaping left them as is.
+	return restorePlaceholders(text, spans)
+}
+
+// placeholder returns the stand-in for the i-th protected span. It starts
+// and ends with NUL so it cannot merge with the text around it.
+func placeholder(i int) string {
+	return placeholderPrefix + strconv.Itoa(i) + "\x00"
+}
+
+const placeholderPrefix = "\x00SPAN"
+
+// restorePlaceholders puts spans back in place of their placeholders.
+func restorePlaceholders(text string, spans []string) string {
+	var b strings.Builder
+	b.Grow(len(text))
+	for {
+		i := strings.Index(text, placeholderPrefix)
+		if i < 0 {
+			break
+		}
+		b.WriteString(text[:i])
+		num, rest, _ := strings.Cut(text[i+len(placeholderPrefix):], "\x00")
+		if n, err := strconv.Atoi(num); err == nil && n < len(spans) {
+			b.WriteString(spans[n])
+		}
+		text = rest
 	}
+	b.WriteString(text)
+	return b.String()
+}
 
-	return processed
+// replaceSubmatches replaces every match of re in text with repl's result
+// for the match's submatches, matching each occurrence only once.
+func replaceSubmatches(re *regexp.Regexp, text string, repl func(sub []string) string) string {
+	matches := re.FindAllStringSubmatchIndex(text, -1)
+	if len(matches) == 0 {
+		return text
+	}
+	var b strings.Builder
+	b.Grow(len(text))
+	prev := 0
+	sub := make([]string, re.NumSubexp()+1)
+	for _, m := range matches {
+		for i := range sub {
+			sub[i] = ""
+			if m[2*i] >= 0 {
+				sub[i] = text[m[2*i]:m[2*i+1]]
+			}
+		}
+		b.WriteString(text[prev:m[0]])
+		b.WriteString(repl(sub))
+		prev = m[1]
+	}
+	b.WriteString(text[prev:])
+	return b.String()
 }
 
-// escapePreservingFormatting escapes special chars but preserves * and ~ used for formatting.
-func escapePreservingFormatting(text string) string {
-	// We identify bold (*...*) and strikethrough (~...~) spans
-	// and escape everything except the formatting markers themselves.
-	var result strings.Builder
-	runes := []rune(text)
-	i := 0
-
-	for i < len(runes) {
-		ch := runes[i]
-
-		// Check for formatting spans: *text* or ~text~
-		if (ch == '*' || ch == '~') && i+1 < len(runes) {
-			marker := ch
-			// Find matching close marker
-			end := strings.IndexRune(string(runes[i+1:]), marker)
-			if end > 0 {
-				inner := string(runes[i+1 : i+1+end])
-				// Don't treat it as formatting if the inner text is empty or has newlines
-				if !strings.Contains(inner, "\n") {
-					result.WriteRune(marker)
-					result.WriteString(escapeMarkdownV2(inner))
-					result.WriteRune(marker)
-					i += end + 2
-					continue
+// wrap surrounds text with a formatting marker.
+func wrap(marker rune, text string) string {
+	return string(marker) + text + string(marker)
+}
+
+// replaceDelimited wraps the matches of re in marker. re's first group is the
+// character before the opening delimiter and its second the emphasized text.
+// Matches followed by a letter or digit are skipped, so snake_case_names and
+// __dunder__ls stay as they are. re never matches across a newline, so only
+// lines containing delim are searched.
+func replaceDelimited(re *regexp.Regexp, text, delim string, marker rune) string {
+	var b strings.Builder
+	b.Grow(len(text))
+	for len(text) > 0 {
+		line, rest, found := strings.Cut(text, "\n")
+		text = rest
+		if strings.Contains(line, delim) {
+			line = replaceDelimitedLine(re, line, marker)
+		}
+		b.WriteString(line)
+		if found {
+			b.WriteByte('\n')
+		}
+	}
+	return b.String()
+}
+
+func replaceDelimitedLine(re *regexp.Regexp, line string, marker rune) string {
+	var b strings.Builder
+	prev := 0
+	for _, m := range re.FindAllStringSubmatchIndex(line, -1) {
+		if next, _ := utf8.DecodeRuneInString(line[m[1]:]); unicode.IsLetter(next) || unicode.IsDigit(next) || next == '_' {
+			continue
+		}
+		b.WriteString(line[prev:m[0]])
+		b.WriteString(line[m[2]:m[3]])
+		b.WriteString(wrap(marker, line[m[4]:m[5]]))
+		prev = m[1]
+	}
+	b.WriteString(line[prev:])
+	return b.String()
+}
+
+// normalizeLists turns -, * and + list items into bullets (by nesting
+// level) and renumbers ordered lists, so "1. 1. 1." reads 1, 2, 3 as it
+// would in rendered Markdown. Nesting is one level per two spaces or a tab.
+func normalizeLists(text string) string {
+	lines := strings.Split(text, "\n")
+	counters := map[int]int{} // next number by nesting level
+	for i, line := range lines {
+		if m := bulletRe.FindStringSubmatch(line); m != nil && !isRule(line) {
+			level := listLevel(m[1])
+			lines[i] = strings.Repeat("  ", level) + bulletSymbols[level%len(bulletSymbols)] + " " + m[2]
+			continue
+		}
+		if m := orderedRe.FindStringSubmatch(line); m != nil {
+			level := listLevel(m[1])
+			n, ok := counters[level]
+			if !ok {
+				n, _ = strconv.Atoi(m[2])
+			}
+			counters[level] = n + 1
+			// A new item ends any deeper list.
+			for l := range counters {
+				if l > level {
+					delete(counters, l)
 				}
 			}
+			lines[i] = strings.Repeat("  ", level) + strconv.Itoa(n) + ". " + m[3]
+			continue
+		}
+		// Blank lines and indented continuations keep the list going.
+		if strings.TrimSpace(line) != "" && !strings.HasPrefix(line, " ") && !strings.HasPrefix(line, "\t") {
+			clear(counters)
 		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// listLevel returns the nesting level of a list item's indentation.
+func listLevel(indent string) int {
+	indent = strings.ReplaceAll(indent, "\t", "  ")
+	return len(indent) / 2
+}
 
-		// Regular character — escape if special
-		if strings.ContainsRune(mdv2SpecialChars, ch) {
-			result.WriteRune('\\')
+// isRule reports whether line is a thematic break such as "- - -" or "* * *",
+// which looks like a list item but is not one.
+func isRule(line string) bool {
+	line = strings.ReplaceAll(strings.TrimSpace(line), " ", "")
+	return len(line) >= 3 && strings.Trim(line, line[:1]) == ""
+}
+
+// convertBlockquotes marks runs of "> " lines as a blockquote, expandable
+// when longer than expandableQuoteLines.
+func convertBlockquotes(text string) string {
+	lines := strings.Split(text, "\n")
+	for start := 0; start < len(lines); {
+		if !strings.HasPrefix(lines[start], ">") || !quoteLineRe.MatchString(lines[start]) {
+			start++
+			continue
+		}
+		end := start
+		for end < len(lines) && quoteLineRe.MatchString(lines[end]) {
+			lines[end] = string(mQuote) + quoteLineRe.FindStringSubmatch(lines[end])[1]
+			end++
+		}
+		if end-start > expandableQuoteLines {
+			lines[start] = string(mQuoteExpand) + strings.TrimPrefix(lines[start], string(mQuote))
+			lines[end-1] += string(mQuoteEnd)
 		}
-		result.WriteRune(ch)
-		i++
+		start = end
 	}
-	return result.String()
+	return strings.Join(lines, "\n")
 }
 
+// mdv2Special flags the bytes of mdv2SpecialChars. They are all ASCII, so
+// escaping can scan bytes: no byte of a multi-byte rune is ever flagged.
+var mdv2Special = func() (t [256]bool) {
+	for i := 0; i < len(mdv2SpecialChars); i++ {
+		t[mdv2SpecialChars[i]] = true
+	}
+	return t
+}()
+
 // escapeMarkdownV2 escapes all MarkdownV2 special characters.
 func escapeMarkdownV2(text string) string {
 	var b strings.Builder
-	for _, r := range text {
-		if strings.ContainsRune(mdv2SpecialChars, r) {
-			b.WriteRune('\\')
+	b.Grow(len(text) + len(text)/8)
+	prev := 0
+	for i := 0; i < len(text); i++ {
+		if mdv2Special[text[i]] {
+			b.WriteString(text[prev:i])
+			b.WriteByte('\\')
+			prev = i
 		}
-		b.WriteRune(r)
 	}
+	b.WriteString(text[prev:])
 	return b.String()
 }
 
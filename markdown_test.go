@@ -0,0 +1,65 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestToTelegramMarkdownV2(t *testing.T) {
+	tests := []struct {
+		name, in, want string
+	}{
+		{"bold", "**done** now.", "*done* now\\."},
+		{"italic star", "an *important* note", "an _important_ note"},
+		{"italic underscore", "an _important_ note", "an _important_ note"},
+		{"bold italic", "***very*** much", "*_very_* much"},
+		{"underline", "__read this__ first", "__read this__ first"},
+		{"strike", "~~old~~ new", "~old~ new"},
+		{"snake case untouched", "set max_tool_rounds and __init__py", "set max\\_tool\\_rounds and \\_\\_init\\_\\_py"},
+		{"lone asterisks escaped", "2 * 3 = 6", "2 \\* 3 \\= 6"},
+		{"underline around italic", "__*x*__", "___x_\r__"},
+		{"heading with bold", "## **Plan**", "*Plan*"},
+		{"code keeps markers", "run `rm *.tmp` and **go**", "run `rm *.tmp` and *go*"},
+		{"link", "[the docs](https://example.com/a_b)", "[the docs](https://example.com/a_b)"},
+		{"blockquote", "> quoted *text*\n> line 2\nafter", ">quoted _text_\n>line 2\nafter"},
+		{"expandable blockquote", "> 1\n> 2\n> 3\n> 4\n> 5\n> 6", "**>1\n>2\n>3\n>4\n>5\n>6||"},
+		{"bullets", "- one\n* two\n  + nested", "• one\n• two\n  ◦ nested"},
+		{"ordered renumbered", "1. a\n1) b\n   1. sub\n   1. sub\n1. c", "1\\. a\n2\\. b\n  1\\. sub\n  2\\. sub\n3\\. c"},
+		{"ordered keeps start", "3. a\n3. b\n\ntext\n\n1. x", "3\\. a\n4\\. b\n\ntext\n\n1\\. x"},
+		{"rule is not a bullet", "- - -", "\\- \\- \\-"},
+		{"fenced code", "```go\nx := *p\n```", "```go\nx := *p\n```"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ToTelegramMarkdownV2(tt.in); got != tt.want {
+				t.Errorf("ToTelegramMarkdownV2(%q)\n got %q\nwant %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+// largeOutput builds about size bytes of typical AI reply text: prose with
+// inline code and emphasis, lists, quoted command output and fenced blocks.
+func largeOutput(size int) string {
+	const chunk = "## Disk usage on `web-1`\n\n" +
+		"The **root** volume is at *91%*; the biggest offenders are under `/var/log` and `/var/lib/docker`.\n\n" +
+		"1. Rotate the logs with `logrotate -f /etc/logrotate.conf`\n" +
+		"2. Prune images: see [the docs](https://docs.docker.com/engine/reference/commandline/image_prune/)\n" +
+		"- check ~~tmp~~ __cache__ dirs\n" +
+		"> Filesystem Size Used Avail Use% Mounted on\n" +
+		"> /dev/sda1 50G 45G 5.0G 91% /\n\n" +
+		"```sh\ndu -sh /var/* | sort -h | tail -n 5\n```\n\n"
+	var b strings.Builder
+	for b.Len() < size {
+		b.WriteString(chunk)
+	}
+	return b.String()
+}
+
+func BenchmarkToTelegramMarkdownV2(b *testing.B) {
+	text := largeOutput(100 << 10)
+	b.SetBytes(int64(len(text)))
+	for i := 0; i < b.N; i++ {
+		ToTelegramMarkdownV2(text)
+	}
+}
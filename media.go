@@ -1,12 +1,12 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
 	"time"
@@ -14,23 +14,66 @@ import (
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
-// MediaHandler downloads Telegram media files and transcribes audio.
+// telegramDownloadLimit is the largest file the Bot API lets bots download.
+const telegramDownloadLimit = 20 << 20
+
+// MediaHandler downloads Telegram media files, transcribes audio and
+// takes videos apart (see video.go).
 type MediaHandler struct {
-	api        *tgbotapi.BotAPI
-	workDir    string
-	whisperCmd string
+	api         *tgbotapi.BotAPI
+	transcriber Transcriber
+	maxBytes    int64  // MEDIA_MAX_BYTES
+	ffmpeg      string // FFMPEG_CMD
+	keyframes   int    // VIDEO_KEYFRAMES
+}
+
+// fileTooBigError reports a file above the download limit.
+type fileTooBigError struct {
+	Size  int64 // 0 when Telegram did not say
+	Limit int64
 }
 
-// DownloadFile downloads a Telegram file by fileID and saves it to workDir/media/.
+func (e *fileTooBigError) Error() string {
+	if e.Size == 0 {
+		return fmt.Sprintf("file is larger than %s", formatBytes(e.Limit))
+	}
+	return fmt.Sprintf("file is %s, larger than %s", formatBytes(e.Size), formatBytes(e.Limit))
+}
+
+// limit returns the largest file DownloadFile accepts.
+func (m *MediaHandler) limit() int64 {
+	if m.maxBytes <= 0 || m.maxBytes > telegramDownloadLimit {
+		return telegramDownloadLimit
+	}
+	return m.maxBytes
+}
+
+// CheckSize refuses a file whose size, as reported by Telegram, is above
+// the limit, before anything is downloaded. An unknown size (0) passes.
+func (m *MediaHandler) CheckSize(size int64) error {
+	if limit := m.limit(); size > limit {
+		return &fileTooBigError{Size: size, Limit: limit}
+	}
+	return nil
+}
+
+// DownloadFile downloads a Telegram file by fileID and saves it to
+// workDir/media/, workDir being the chat's workspace directory.
 // Returns the absolute path of the saved file.
-func (m *MediaHandler) DownloadFile(fileID, ext string) (string, error) {
+func (m *MediaHandler) DownloadFile(workDir, fileID, ext string) (string, error) {
 	file, err := m.api.GetFile(tgbotapi.FileConfig{FileID: fileID})
 	if err != nil {
+		if strings.Contains(err.Error(), "file is too big") {
+			return "", &fileTooBigError{Limit: telegramDownloadLimit}
+		}
 		return "", fmt.Errorf("get file metadata: %w", err)
 	}
+	if err := m.CheckSize(int64(file.FileSize)); err != nil {
+		return "", err
+	}
 
 	url := file.Link(m.api.Token)
-	log.Printf("[media] downloading %s", url)
+	slog.Debug("downloading media", "url", url)
 
 	resp, err := http.Get(url)
 	if err != nil {
@@ -42,7 +85,7 @@ func (m *MediaHandler) DownloadFile(fileID, ext string) (string, error) {
 		return "", fmt.Errorf("download file: HTTP %d", resp.StatusCode)
 	}
 
-	mediaDir := filepath.Join(m.workDir, "media")
+	mediaDir := filepath.Join(workDir, "media")
 	if err := os.MkdirAll(mediaDir, 0o755); err != nil {
 		return "", fmt.Errorf("create media dir: %w", err)
 	}
@@ -56,47 +99,55 @@ func (m *MediaHandler) DownloadFile(fileID, ext string) (string, error) {
 	}
 	defer f.Close()
 
-	if _, err := io.Copy(f, resp.Body); err != nil {
+	// Sizes are not always reported, so the copy is capped as well.
+	n, err := io.Copy(f, io.LimitReader(resp.Body, m.limit()+1))
+	if err != nil {
 		os.Remove(path)
 		return "", fmt.Errorf("write file: %w", err)
 	}
+	if n > m.limit() {
+		os.Remove(path)
+		return "", &fileTooBigError{Limit: m.limit()}
+	}
 
 	absPath, err := filepath.Abs(path)
 	if err != nil {
 		return path, nil
 	}
 
-	log.Printf("[media] saved to %s", absPath)
+	slog.Debug("media saved", "path", absPath)
 	return absPath, nil
 }
 
-// TranscribeAudio runs the whisper CLI to transcribe an audio file.
-// Returns the transcript text.
-func (m *MediaHandler) TranscribeAudio(path string) (string, error) {
-	dir := filepath.Dir(path)
-
-	cmd := exec.Command(m.whisperCmd, path, "--model", "base", "--output_format", "txt", "--output_dir", dir)
-	log.Printf("[media] running: %s", cmd.String())
-
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return "", fmt.Errorf("whisper failed: %w\noutput: %s", err, string(output))
+// formatBytes renders a size for messages, e.g. "20 MB" or "1.5 GB".
+func formatBytes(n int64) string {
+	const unit = 1 << 10
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
 	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	v := float64(n) / float64(div)
+	if v == float64(int64(v)) {
+		return fmt.Sprintf("%d %cB", int64(v), "KMGT"[exp])
+	}
+	return fmt.Sprintf("%.1f %cB", v, "KMGT"[exp])
+}
 
-	// Whisper writes <basename>.txt in the output dir.
-	base := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
-	txtPath := filepath.Join(dir, base+".txt")
-
-	transcript, err := os.ReadFile(txtPath)
+// TranscribeAudio transcribes an audio file with the configured backend
+// (see Transcriber). Returns the transcript text.
+func (m *MediaHandler) TranscribeAudio(ctx context.Context, path string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, transcribeTimeout)
+	defer cancel()
+	transcript, err := m.transcriber.Transcribe(ctx, path)
 	if err != nil {
-		return "", fmt.Errorf("read transcript: %w", err)
+		return "", err
 	}
-
-	// Clean up the txt file.
-	os.Remove(txtPath)
-
-	text := strings.TrimSpace(string(transcript))
-	log.Printf("[media] transcript (%d chars): %.200s", len(text), text)
+	text := strings.TrimSpace(transcript)
+	slog.Debug("transcript", "chars", len(text), "text", truncateText(text, 200))
 	return text, nil
 }
 
@@ -104,9 +155,9 @@ func (m *MediaHandler) TranscribeAudio(path string) (string, error) {
 func (m *MediaHandler) Cleanup(paths ...string) {
 	for _, p := range paths {
 		if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
-			log.Printf("[media] cleanup error: %v", err)
+			slog.Warn("media cleanup failed", "err", err)
 		} else {
-			log.Printf("[media] cleaned up %s", p)
+			slog.Debug("media cleaned up", "path", p)
 		}
 	}
 }
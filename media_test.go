@@ -0,0 +1,40 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestMediaCheckSize(t *testing.T) {
+	m := &MediaHandler{maxBytes: 5 << 20}
+	if err := m.CheckSize(0); err != nil {
+		t.Errorf("unknown size refused: %v", err)
+	}
+	if err := m.CheckSize(5 << 20); err != nil {
+		t.Errorf("size at the limit refused: %v", err)
+	}
+	var tooBig *fileTooBigError
+	if err := m.CheckSize(5<<20 + 1); !errors.As(err, &tooBig) || tooBig.Limit != 5<<20 {
+		t.Errorf("CheckSize over the limit = %v", err)
+	}
+
+	// Telegram's own limit wins over a larger setting.
+	m.maxBytes = 100 << 20
+	if err := m.CheckSize(telegramDownloadLimit + 1); err == nil {
+		t.Error("file above Telegram's limit accepted")
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	for n, want := range map[int64]string{
+		512:                           "512 B",
+		2048:                          "2 KB",
+		20 << 20:                      "20 MB",
+		1536 << 20:                    "1.5 GB",
+		telegramDownloadLimit + 1<<19: "20.5 MB",
+	} {
+		if got := formatBytes(n); got != want {
+			t.Errorf("formatBytes(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
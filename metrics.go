@@ -0,0 +1,237 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// maxLatencySamples bounds the per-provider latency window used for percentiles.
+	maxLatencySamples = 500
+	// tokenHistory is how long per-minute token buckets are kept.
+	tokenHistory = 24 * time.Hour
+	// metricsSaveInterval throttles how often metrics are flushed to disk.
+	metricsSaveInterval = 30 * time.Second
+)
+
+// ProviderStats accumulates call metrics for one AI provider.
+type ProviderStats struct {
+	Calls       int64            `json:"calls"`
+	Errors      map[string]int64 `json:"errors"`        // error class → count
+	LatenciesMs []int64          `json:"latencies_ms"`  // most recent call latencies
+	TokensByMin map[int64]int64  `json:"tokens_by_min"` // unix minute → tokens
+	TotalTokens int64            `json:"total_tokens"`
+}
+
+// ProviderMetrics tracks per-provider latency, error, and token throughput
+// across all chats, persisted to DATA_DIR so trends survive restarts.
+type ProviderMetrics struct {
+	mu       sync.Mutex
+	path     string
+	stats    map[string]*ProviderStats
+	lastSave time.Time
+}
+
+func NewProviderMetrics(dataDir string) *ProviderMetrics {
+	m := &ProviderMetrics{
+		path:  filepath.Join(dataDir, "provider_metrics.json"),
+		stats: make(map[string]*ProviderStats),
+	}
+	if err := loadJSONFile(m.path, &m.stats); err != nil {
+		slog.Warn("failed to load metrics", "path", m.path, "err", err)
+	}
+	return m
+}
+
+// Record adds one provider call to the metrics.
+func (m *ProviderMetrics) Record(provider string, latency time.Duration, tokens int64, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s := m.stats[provider]
+	if s == nil {
+		s = &ProviderStats{}
+		m.stats[provider] = s
+	}
+	if s.Errors == nil {
+		s.Errors = make(map[string]int64)
+	}
+	if s.TokensByMin == nil {
+		s.TokensByMin = make(map[int64]int64)
+	}
+
+	s.Calls++
+	if err != nil {
+		s.Errors[classifyError(err)]++
+	}
+	s.LatenciesMs = append(s.LatenciesMs, latency.Milliseconds())
+	if len(s.LatenciesMs) > maxLatencySamples {
+		s.LatenciesMs = s.LatenciesMs[len(s.LatenciesMs)-maxLatencySamples:]
+	}
+
+	now := time.Now()
+	if tokens > 0 {
+		s.TokensByMin[now.Unix()/60] += tokens
+		s.TotalTokens += tokens
+	}
+	cutoff := now.Add(-tokenHistory).Unix() / 60
+	for minute := range s.TokensByMin {
+		if minute < cutoff {
+			delete(s.TokensByMin, minute)
+		}
+	}
+
+	if now.Sub(m.lastSave) >= metricsSaveInterval {
+		m.saveLocked()
+	}
+}
+
+// Save flushes metrics to disk.
+func (m *ProviderMetrics) Save() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.saveLocked()
+}
+
+func (m *ProviderMetrics) saveLocked() {
+	if err := saveJSONFile(m.path, m.stats); err != nil {
+		slog.Warn("failed to save metrics", "path", m.path, "err", err)
+		return
+	}
+	m.lastSave = time.Now()
+}
+
+// ProviderSnapshot is a computed view of a provider's metrics.
+type ProviderSnapshot struct {
+	Provider      string
+	Calls         int64
+	ErrorCount    int64
+	Errors        map[string]int64
+	P50, P90, P99 time.Duration
+	TokensLastHr  int64
+	PeakTokensMin int64
+	TotalTokens   int64
+}
+
+// Snapshot returns computed metrics for every provider seen, sorted by name.
+func (m *ProviderMetrics) Snapshot() []ProviderSnapshot {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	hourAgo := time.Now().Add(-time.Hour).Unix() / 60
+	var out []ProviderSnapshot
+	for name, s := range m.stats {
+		snap := ProviderSnapshot{
+			Provider:    name,
+			Calls:       s.Calls,
+			Errors:      make(map[string]int64, len(s.Errors)),
+			TotalTokens: s.TotalTokens,
+		}
+		for class, n := range s.Errors {
+			snap.Errors[class] = n
+			snap.ErrorCount += n
+		}
+		snap.P50 = percentile(s.LatenciesMs, 50)
+		snap.P90 = percentile(s.LatenciesMs, 90)
+		snap.P99 = percentile(s.LatenciesMs, 99)
+		for minute, n := range s.TokensByMin {
+			if minute >= hourAgo {
+				snap.TokensLastHr += n
+			}
+			if n > snap.PeakTokensMin {
+				snap.PeakTokensMin = n
+			}
+		}
+		out = append(out, snap)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Provider < out[j].Provider })
+	return out
+}
+
+// percentile returns the p-th percentile (nearest-rank) of latencies in ms.
+func percentile(samples []int64, p int) time.Duration {
+	if len(samples) == 0 {
+		return 0
+	}
+	sorted := make([]int64, len(samples))
+	copy(sorted, samples)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	idx := (p*len(sorted)+99)/100 - 1
+	if idx < 0 {
+		idx = 0
+	}
+	return time.Duration(sorted[idx]) * time.Millisecond
+}
+
+// classifyError buckets a provider error for the error-rate breakdown.
+func classifyError(err error) string {
+	msg := strings.ToLower(err.Error())
+	switch {
+	case errors.Is(err, context.DeadlineExceeded) || strings.Contains(msg, "timed out") || strings.Contains(msg, "timeout"):
+		return "timeout"
+	case IsNotLoggedIn(err) || IsGeminiNotLoggedIn(err):
+		return "auth"
+	case errors.As(err, new(*GeminiSafetyError)):
+		return "safety"
+	case strings.Contains(msg, "429") || strings.Contains(msg, "rate limit") ||
+		strings.Contains(msg, "resource_exhausted") || strings.Contains(msg, "overloaded"):
+		return "rate_limit"
+	case strings.Contains(msg, "api error") || strings.Contains(msg, "claude error"):
+		return "api"
+	default:
+		return "other"
+	}
+}
+
+// HandleStats shows cross-chat operational statistics.
+// Usage: /stats [providers|approvals]
+func (h *Handlers) HandleStats(chatID int64, args string) {
+	switch strings.TrimSpace(args) {
+	case "", "providers":
+	case "approvals":
+		h.sender.SendPlain(chatID, h.approvalStats.format(h.uiLanguage(chatID), h.approvalSLA))
+		return
+	default:
+		h.sendUsage(chatID, "stats")
+		return
+	}
+
+	snaps := h.metrics.Snapshot()
+	if len(snaps) == 0 {
+		h.sender.SendPlain(chatID, h.t(chatID, "stats.none"))
+		return
+	}
+
+	var b strings.Builder
+	b.WriteString(h.t(chatID, "stats.title"))
+	for _, s := range snaps {
+		errRate := 0.0
+		if s.Calls > 0 {
+			errRate = float64(s.ErrorCount) / float64(s.Calls) * 100
+		}
+		fmt.Fprintf(&b, "\n%s\n", s.Provider)
+		b.WriteString(h.t(chatID, "stats.calls", s.Calls))
+		b.WriteString(h.t(chatID, "stats.errors", s.ErrorCount, errRate))
+		if len(s.Errors) > 0 {
+			classes := make([]string, 0, len(s.Errors))
+			for class, n := range s.Errors {
+				classes = append(classes, fmt.Sprintf("%s=%d", class, n))
+			}
+			sort.Strings(classes)
+			fmt.Fprintf(&b, " [%s]", strings.Join(classes, " "))
+		}
+		b.WriteString("\n")
+		b.WriteString(h.t(chatID, "stats.latency",
+			s.P50.Truncate(100*time.Millisecond), s.P90.Truncate(100*time.Millisecond), s.P99.Truncate(100*time.Millisecond)))
+		b.WriteString(h.t(chatID, "stats.tokens", float64(s.TokensLastHr)/60, s.PeakTokensMin))
+		b.WriteString(h.t(chatID, "stats.total", s.TotalTokens))
+	}
+	h.sender.SendPlain(chatID, b.String())
+}
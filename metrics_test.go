@@ -0,0 +1,115 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPercentile(t *testing.T) {
+	samples := []int64{50, 10, 40, 20, 30, 60, 70, 80, 90, 100}
+	tests := []struct {
+		p    int
+		want time.Duration
+	}{
+		{50, 50 * time.Millisecond},
+		{90, 90 * time.Millisecond},
+		{99, 100 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		if got := percentile(samples, tt.p); got != tt.want {
+			t.Errorf("percentile(p%d) = %v, want %v", tt.p, got, tt.want)
+		}
+	}
+	if got := percentile(nil, 50); got != 0 {
+		t.Errorf("percentile(empty) = %v, want 0", got)
+	}
+}
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{fmt.Errorf("claude timed out"), "timeout"},
+		{errors.New("Not logged in"), "auth"},
+		{errors.New("gemini API error (429 RESOURCE_EXHAUSTED): quota"), "rate_limit"},
+		{errors.New("claude error: something broke"), "api"},
+		{errors.New("connection reset"), "other"},
+	}
+	for _, tt := range tests {
+		if got := classifyError(tt.err); got != tt.want {
+			t.Errorf("classifyError(%q) = %q, want %q", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestProviderMetricsSnapshot(t *testing.T) {
+	m := NewProviderMetrics(t.TempDir())
+	m.Record("claude", 100*time.Millisecond, 500, nil)
+	m.Record("claude", 300*time.Millisecond, 0, errors.New("claude timed out"))
+	m.Save()
+
+	reloaded := NewProviderMetrics(filepath.Dir(m.path))
+	snaps := reloaded.Snapshot()
+	if len(snaps) != 1 {
+		t.Fatalf("expected 1 provider, got %d", len(snaps))
+	}
+	s := snaps[0]
+	if s.Calls != 2 || s.ErrorCount != 1 || s.Errors["timeout"] != 1 {
+		t.Errorf("unexpected snapshot: %+v", s)
+	}
+	if s.TokensLastHr != 500 || s.TotalTokens != 500 {
+		t.Errorf("tokens: lastHr=%d total=%d, want 500", s.TokensLastHr, s.TotalTokens)
+	}
+}
+
+func TestGeminiUsageTracking(t *testing.T) {
+	u := GeminiUsage{Model: "gemini-2.5-flash-lite-preview", PromptTokens: 1_000_000, CandidatesTokens: 500_000, ThoughtsTokens: 500_000, TotalTokens: 2_000_000}
+	if got, want := geminiCost(u), 0.10+0.40; math.Abs(got-want) > 1e-9 {
+		t.Errorf("geminiCost() = %v, want %v (longest prefix should win)", got, want)
+	}
+	if got := geminiCost(GeminiUsage{Model: "unknown", PromptTokens: 1000}); got != 0 {
+		t.Errorf("unknown model cost = %v, want 0", got)
+	}
+
+	tr := NewUsageTracker(t.TempDir())
+	tr.Record(1, &ClaudeResponse{CostUSD: 0.02, Usage: ClaudeUsage{InputTokens: 10, OutputTokens: 5}})
+	tr.RecordGemini(1, u, time.Second)
+	s := tr.Get(1)
+	if s.NumCalls != 2 || s.InputTokens != 1_000_010 {
+		t.Errorf("totals = %+v", s)
+	}
+	if g := s.Providers["gemini"]; g == nil || g.OutputTokens != 1_000_000 || g.NumCalls != 1 {
+		t.Errorf("gemini breakdown = %+v", g)
+	}
+	if c := s.Providers["claude"]; c == nil || c.CostUSD != 0.02 {
+		t.Errorf("claude breakdown = %+v", c)
+	}
+}
+
+func TestUsageLifetimeSurvivesReset(t *testing.T) {
+	dir := t.TempDir()
+	tr := NewUsageTracker(dir)
+	tr.Record(1, &ClaudeResponse{CostUSD: 0.02, Usage: ClaudeUsage{InputTokens: 10, OutputTokens: 5}})
+	tr.Reset(1)
+	tr.RecordAPI(1, "openrouter", APIUsage{CostUSD: 0.01, PromptTokens: 3, CompletionTokens: 1}, time.Second)
+	if s := tr.Get(1); s.NumCalls != 1 || s.InputTokens != 3 {
+		t.Errorf("session after reset = %+v", s)
+	}
+	if s := tr.Lifetime(1); s.NumCalls != 2 || s.InputTokens != 13 || s.Since.IsZero() {
+		t.Errorf("lifetime = %+v", s)
+	}
+
+	tr.Save()
+	tr = NewUsageTracker(dir)
+	if tr.Get(1) != nil {
+		t.Error("session usage survived a restart")
+	}
+	if s := tr.Lifetime(1); s == nil || s.NumCalls != 2 || s.Providers["claude"].CostUSD != 0.02 {
+		t.Errorf("lifetime after restart = %+v", s)
+	}
+}
@@ -0,0 +1,385 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// /models compares the models of every provider side by side: list price,
+// context size and capabilities, a page at a time with inline navigation.
+// Picking one stores it as the chat's model for that provider and switches
+// the chat to the provider.
+
+// modelsPageSize is how many models one /models page shows.
+const modelsPageSize = 8
+
+// modelProviders are the /models tabs, in order.
+var modelProviders = []string{"claude", "gemini", "openrouter", "azure", "bedrock"}
+
+// catalogModel is one row of the /models browser. Prices are USD per
+// million tokens; negative means unknown or varying.
+type catalogModel struct {
+	ID          string
+	Name        string
+	InputPrice  float64
+	OutputPrice float64
+	Context     int // tokens; 0 = unknown
+	Vision      bool
+	Tools       bool
+	Reasoning   bool
+}
+
+// claudeCatalog describes the /cmodel aliases at the list prices of the
+// models they currently resolve to.
+var claudeCatalog = []catalogModel{
+	{ID: "opus", Name: "Claude Opus", InputPrice: 5, OutputPrice: 25, Context: 200_000, Vision: true, Tools: true, Reasoning: true},
+	{ID: "sonnet", Name: "Claude Sonnet", InputPrice: 3, OutputPrice: 15, Context: 200_000, Vision: true, Tools: true, Reasoning: true},
+	{ID: "haiku", Name: "Claude Haiku", InputPrice: 1, OutputPrice: 5, Context: 200_000, Vision: true, Tools: true, Reasoning: true},
+}
+
+// geminiModels are the Gemini models offered by /models and /gmodel; their
+// prices come from geminiPrices.
+var geminiModels = []catalogModel{
+	{ID: "gemini-2.5-flash", Name: "Gemini 2.5 Flash"},
+	{ID: "gemini-2.5-flash-lite", Name: "Gemini 2.5 Flash-Lite"},
+	{ID: "gemini-2.5-pro", Name: "Gemini 2.5 Pro"},
+	{ID: "gemini-3-flash-preview", Name: "Gemini 3 Flash Preview"},
+	{ID: "gemini-3-pro-preview", Name: "Gemini 3 Pro Preview"},
+}
+
+// modelCatalog returns the models /models offers for provider. Azure and
+// Bedrock only have the deployment the server is configured with.
+func (h *Handlers) modelCatalog(ctx context.Context, provider string) ([]catalogModel, error) {
+	switch provider {
+	case "claude":
+		return claudeCatalog, nil
+	case "gemini":
+		models := make([]catalogModel, len(geminiModels))
+		for i, m := range geminiModels {
+			m.InputPrice, m.OutputPrice = -1, -1
+			if p, ok := geminiPriceFor(m.ID); ok {
+				m.InputPrice, m.OutputPrice = p.Input, p.Output
+			}
+			m.Context, m.Vision, m.Tools, m.Reasoning = 1_048_576, true, true, true
+			models[i] = m
+		}
+		return models, nil
+	case "openrouter":
+		catalog, err := h.openrouter.Models(ctx)
+		if err != nil {
+			return nil, err
+		}
+		models := make([]catalogModel, len(catalog))
+		for i, m := range catalog {
+			models[i] = catalogModel{
+				ID: m.ID, Name: m.Name, InputPrice: m.PromptPrice, OutputPrice: m.OutputPrice,
+				Context: m.ContextLength, Vision: m.Vision, Tools: m.Tools, Reasoning: m.Reasoning,
+			}
+		}
+		return models, nil
+	case "azure", "bedrock":
+		if !h.hasAPIKey(provider) {
+			return nil, nil
+		}
+		return []catalogModel{{ID: h.providerModel(provider), Name: providerLabel(provider), InputPrice: -1, OutputPrice: -1}}, nil
+	}
+	return nil, fmt.Errorf("unknown provider %q", provider)
+}
+
+// filterCatalog keeps the models whose ID or name contains filter, ignoring
+// case.
+func filterCatalog(models []catalogModel, filter string) []catalogModel {
+	if filter == "" {
+		return models
+	}
+	filter = strings.ToLower(filter)
+	var out []catalogModel
+	for _, m := range models {
+		if strings.Contains(strings.ToLower(m.ID), filter) || strings.Contains(strings.ToLower(m.Name), filter) {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+// catalogPrice renders a model's input/output prices per million tokens.
+func catalogPrice(lang, provider string, m catalogModel) string {
+	switch {
+	case provider == "azure" || provider == "bedrock":
+		return translate(lang, "price.cloud")
+	case m.InputPrice < 0:
+		return translate(lang, "price.varies")
+	case m.InputPrice == 0 && m.OutputPrice == 0:
+		return translate(lang, "price.free")
+	}
+	return translate(lang, "price.per_million", trimPrice(m.InputPrice), trimPrice(m.OutputPrice))
+}
+
+// formatContext renders a context size in tokens, e.g. 200K or 1M.
+func formatContext(lang string, tokens int) string {
+	size := strconv.Itoa(tokens)
+	switch {
+	case tokens <= 0:
+		size = "?"
+	case tokens >= 1_000_000:
+		size = strings.TrimSuffix(strconv.FormatFloat(float64(tokens)/1e6, 'f', 1, 64), ".0") + "M"
+	case tokens >= 1000:
+		size = fmt.Sprintf("%dK", tokens/1000)
+	}
+	return translate(lang, "models.context", size)
+}
+
+// capabilityIcons renders what a model can do, see the models.legend message.
+func capabilityIcons(m catalogModel) string {
+	var icons string
+	if m.Vision {
+		icons += "👁"
+	}
+	if m.Tools {
+		icons += "🛠"
+	}
+	if m.Reasoning {
+		icons += "🧠"
+	}
+	return icons
+}
+
+// modelsView is one page of the /models browser.
+type modelsView struct {
+	Provider string // the tab shown
+	Active   string // the chat's provider
+	Current  string // the chat's model for Provider
+	Filter   string
+	Page     int
+	Lang     string // the chat's UI language
+}
+
+// modelsPageData is the callback data of a page button. The filter goes
+// last since it may contain colons.
+func modelsPageData(provider string, page int, filter string) string {
+	return fmt.Sprintf("models:p:%s:%d:%s", provider, page, filter)
+}
+
+// renderModelsPage lays out one page of models with their buttons, the
+// page navigation and the provider tabs. Models whose ID does not fit in
+// callback data are listed without a button.
+func renderModelsPage(v modelsView, models []catalogModel) (string, tgbotapi.InlineKeyboardMarkup) {
+	pages := max((len(models)+modelsPageSize-1)/modelsPageSize, 1)
+	v.Page = min(max(v.Page, 0), pages-1)
+
+	var b strings.Builder
+	b.WriteString(translate(v.Lang, "models.title", providerLabel(v.Provider)))
+	if v.Filter != "" {
+		b.WriteString(translate(v.Lang, "models.matching", v.Filter))
+	}
+	if pages > 1 {
+		b.WriteString(translate(v.Lang, "models.page", v.Page+1, pages))
+	}
+	b.WriteString("\n")
+	if v.Provider != v.Active {
+		b.WriteString(translate(v.Lang, "models.other_provider", providerLabel(v.Active)))
+	}
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	page := models[min(v.Page*modelsPageSize, len(models)):min((v.Page+1)*modelsPageSize, len(models))]
+	for _, m := range page {
+		mark := ""
+		if m.ID == v.Current {
+			mark = "✅ "
+		}
+		price := catalogPrice(v.Lang, v.Provider, m)
+		fmt.Fprintf(&b, "\n%s%s (%s)\n    %s · %s", mark, m.Name, m.ID, price, formatContext(v.Lang, m.Context))
+		if icons := capabilityIcons(m); icons != "" {
+			b.WriteString(" · " + icons)
+		}
+		data := "models:s:" + v.Provider + ":" + m.ID
+		if len(data) > 64 {
+			continue
+		}
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("%s%s — %s", mark, m.Name, price), data),
+		))
+	}
+	if len(models) == 0 {
+		if v.Filter != "" {
+			b.WriteString(translate(v.Lang, "models.no_match"))
+		} else {
+			b.WriteString(translate(v.Lang, "models.not_configured"))
+		}
+	} else {
+		b.WriteString("\n\n" + translate(v.Lang, "models.legend"))
+	}
+
+	var nav []tgbotapi.InlineKeyboardButton
+	if v.Page > 0 {
+		nav = append(nav, tgbotapi.NewInlineKeyboardButtonData(translate(v.Lang, "models.prev"), modelsPageData(v.Provider, v.Page-1, v.Filter)))
+	}
+	if v.Page < pages-1 {
+		nav = append(nav, tgbotapi.NewInlineKeyboardButtonData(translate(v.Lang, "models.next"), modelsPageData(v.Provider, v.Page+1, v.Filter)))
+	}
+	if len(nav) > 0 {
+		rows = append(rows, nav)
+	}
+	var tabs []tgbotapi.InlineKeyboardButton
+	for _, p := range modelProviders {
+		label := providerLabel(p)
+		if p == v.Provider {
+			label = "• " + label
+		}
+		tabs = append(tabs, tgbotapi.NewInlineKeyboardButtonData(label, modelsPageData(p, 0, "")))
+	}
+	rows = append(rows, tabs)
+	return strings.TrimSpace(b.String()), tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+// modelsPage renders page of the provider tab for the chat.
+func (h *Handlers) modelsPage(ctx context.Context, chatID int64, provider string, page int, filter string) (string, tgbotapi.InlineKeyboardMarkup, error) {
+	models, err := h.modelCatalog(ctx, provider)
+	if err != nil {
+		return "", tgbotapi.InlineKeyboardMarkup{}, err
+	}
+	text, keyboard := renderModelsPage(modelsView{
+		Provider: provider,
+		Active:   h.providers.Get(chatID),
+		Current:  h.chatModel(chatID, provider),
+		Filter:   filter,
+		Page:     page,
+		Lang:     h.uiLanguage(chatID),
+	}, filterCatalog(models, filter))
+	return text, keyboard, nil
+}
+
+// HandleModels opens the model browser on a provider's tab: the chat's
+// provider unless given.
+// Usage: /models [provider] [filter]
+func (h *Handlers) HandleModels(ctx context.Context, chatID int64, args string) {
+	a, ok := h.parseCommandArgs(chatID, "models", args)
+	if !ok {
+		return
+	}
+	provider := strings.ToLower(a.Arg(0))
+	if provider == "" {
+		provider = h.providers.Get(chatID)
+	}
+	if !slices.Contains(modelProviders, provider) {
+		h.sendArgError(chatID, "models", fmt.Errorf("unknown provider %q (want %s)", a.Arg(0), strings.Join(modelProviders, ", ")))
+		return
+	}
+	// Page buttons carry the filter in their 64-byte callback data.
+	if len(modelsPageData(provider, 999, a.Arg(1))) > 64 {
+		h.sendArgError(chatID, "models", fmt.Errorf("filter %q is too long", a.Arg(1)))
+		return
+	}
+	text, keyboard, err := h.modelsPage(ctx, chatID, provider, 0, a.Arg(1))
+	if err != nil {
+		slog.Warn("list models failed", "chat_id", chatID, "provider", provider, "err", err)
+		h.sender.SendPlain(chatID, h.t(chatID, "models.list_failed", providerLabel(provider), err))
+		return
+	}
+	h.sender.SendPlainWithKeyboard(chatID, text, keyboard)
+}
+
+// handleModelsCallback handles the page, tab and model buttons of /models.
+// The caller holds the chat lock.
+func (h *Handlers) handleModelsCallback(ctx context.Context, chatID int64, callbackID, data string, messageID int) {
+	kind, rest, _ := strings.Cut(strings.TrimPrefix(data, "models:"), ":")
+	provider, rest, _ := strings.Cut(rest, ":")
+	if !slices.Contains(modelProviders, provider) {
+		h.sender.AnswerCallback(callbackID, h.t(chatID, "selection.unknown"))
+		return
+	}
+	switch kind {
+	case "p":
+		pageStr, filter, _ := strings.Cut(rest, ":")
+		page, _ := strconv.Atoi(pageStr)
+		text, keyboard, err := h.modelsPage(ctx, chatID, provider, page, filter)
+		if err != nil {
+			h.sender.AnswerCallback(callbackID, h.t(chatID, "models.list_failed_short"))
+			h.sender.SendPlain(chatID, h.t(chatID, "models.list_failed", providerLabel(provider), err))
+			return
+		}
+		h.sender.AnswerCallback(callbackID, "")
+		h.sender.EditPlain(chatID, messageID, text, &keyboard)
+	case "s":
+		if (provider == "azure" || provider == "bedrock") && !h.hasAPIKey(provider) {
+			h.sender.AnswerCallback(callbackID, h.t(chatID, "models.not_configured_short"))
+			h.sender.SendPlain(chatID, h.cloudProviderHint(chatID, provider))
+			return
+		}
+		msg, err := h.selectModel(chatID, provider, rest)
+		if err != nil {
+			h.sender.AnswerCallback(callbackID, h.t(chatID, "models.not_switched"))
+			h.sender.SendPlain(chatID, h.t(chatID, "models.save_failed", err))
+			return
+		}
+		h.sender.AnswerCallback(callbackID, h.t(chatID, "model.switched"))
+		h.sender.EditRemoveKeyboard(chatID, messageID, msg)
+	default:
+		h.sender.AnswerCallback(callbackID, h.t(chatID, "selection.unknown"))
+	}
+}
+
+// selectModel makes model the chat's model for provider and switches the
+// chat to provider, returning the confirmation to show. The caller holds
+// the chat lock.
+func (h *Handlers) selectModel(chatID int64, provider, model string) (string, error) {
+	if err := h.setChatModel(chatID, provider, model); err != nil {
+		return "", err
+	}
+	from := h.providers.Get(chatID)
+	if from != provider {
+		h.providers.Set(chatID, provider)
+		h.resetSession(chatID)
+		slog.Info("switched provider", "chat_id", chatID, "from", from, "provider", provider)
+	}
+	slog.Info("model switched", "chat_id", chatID, "provider", provider, "model", model)
+
+	msg := h.t(chatID, "models.selected", providerLabel(provider), model)
+	switch {
+	case from != provider:
+		msg += h.t(chatID, "models.switched_from", providerLabel(from))
+	case provider == "claude":
+		msg += h.t(chatID, "models.next_message")
+	default:
+		msg += h.t(chatID, "models.session_reset")
+	}
+	return msg, nil
+}
+
+// setChatModel stores the chat's model for provider. Choosing the server's
+// model stores nothing, so the chat follows later changes to it; Claude's
+// models live in ClaudeClient, like /cmodel's. Azure and Bedrock models are
+// fixed by their deployment.
+func (h *Handlers) setChatModel(chatID int64, provider, model string) error {
+	switch provider {
+	case "claude":
+		h.claude.SetModel(chatID, model)
+		return nil
+	case "gemini", "openrouter":
+	default:
+		return nil
+	}
+	if model == h.providerModel(provider) {
+		model = ""
+	}
+	err := h.settings.Update(chatID, func(s *ChatSettings) error {
+		if provider == "gemini" {
+			s.GeminiModel = model
+		} else {
+			s.OpenRouterModel = model
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	// The history was produced by the previous model; start fresh.
+	h.geminiSessions.Delete(chatID)
+	return nil
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestFormatContext(t *testing.T) {
+	for tokens, want := range map[int]string{
+		0:         "? context",
+		8192:      "8K context",
+		200_000:   "200K context",
+		1_048_576: "1M context",
+		2_000_000: "2M context",
+		1_500_000: "1.5M context",
+	} {
+		if got := formatContext("en", tokens); got != want {
+			t.Errorf("formatContext(%d) = %q, want %q", tokens, got, want)
+		}
+	}
+}
+
+func TestCatalogPrice(t *testing.T) {
+	tests := []struct {
+		provider string
+		m        catalogModel
+		want     string
+	}{
+		{"gemini", catalogModel{InputPrice: 0.3, OutputPrice: 2.5}, "$0.3/$2.5 per 1M"},
+		{"openrouter", catalogModel{}, "free"},
+		{"openrouter", catalogModel{InputPrice: -1, OutputPrice: -1}, "price varies"},
+		{"azure", catalogModel{InputPrice: -1, OutputPrice: -1}, "billed by your cloud account"},
+	}
+	for _, tt := range tests {
+		if got := catalogPrice("en", tt.provider, tt.m); got != tt.want {
+			t.Errorf("catalogPrice(%s, %+v) = %q, want %q", tt.provider, tt.m, got, tt.want)
+		}
+	}
+}
+
+func TestRenderModelsPage(t *testing.T) {
+	var models []catalogModel
+	for i := range 20 {
+		models = append(models, catalogModel{ID: fmt.Sprintf("vendor/model-%02d", i), Name: fmt.Sprintf("Model %02d", i), Context: 128_000, Tools: true})
+	}
+	models = append(models, catalogModel{ID: "vendor/" + strings.Repeat("x", 60), Name: "Long"})
+
+	v := modelsView{Provider: "openrouter", Active: "openrouter", Current: "vendor/model-09", Page: 1}
+	text, kb := renderModelsPage(v, models)
+	if !strings.Contains(text, "(page 2/3)") {
+		t.Errorf("text = %q", text)
+	}
+	if !strings.Contains(text, "✅ Model 09 (vendor/model-09)") || !strings.Contains(text, "128K context · 🛠") {
+		t.Errorf("text = %q", text)
+	}
+	if strings.Contains(text, "switches it") {
+		t.Errorf("switch note on the chat's own provider: %q", text)
+	}
+	// 8 models, the navigation and the provider tabs.
+	if len(kb.InlineKeyboard) != 10 {
+		t.Fatalf("%d keyboard rows", len(kb.InlineKeyboard))
+	}
+	if got := *kb.InlineKeyboard[0][0].CallbackData; got != "models:s:openrouter:vendor/model-08" {
+		t.Errorf("first model button = %q", got)
+	}
+	nav := kb.InlineKeyboard[8]
+	if len(nav) != 2 || *nav[0].CallbackData != "models:p:openrouter:0:" || *nav[1].CallbackData != "models:p:openrouter:2:" {
+		t.Errorf("navigation = %+v", nav)
+	}
+	if tabs := kb.InlineKeyboard[9]; len(tabs) != len(modelProviders) || tabs[2].Text != "• OpenRouter" {
+		t.Errorf("tabs = %+v", tabs)
+	}
+
+	// The last page lists the long ID without a button, and has no Next.
+	v.Page = 5
+	text, kb = renderModelsPage(v, models)
+	if !strings.Contains(text, "(page 3/3)") || !strings.Contains(text, "Long (vendor/xxx") {
+		t.Errorf("last page text = %q", text)
+	}
+	if len(kb.InlineKeyboard) != 4+2 {
+		t.Errorf("last page has %d rows", len(kb.InlineKeyboard))
+	}
+	if nav := kb.InlineKeyboard[4]; len(nav) != 1 || nav[0].Text != "◀️ Prev" {
+		t.Errorf("last page navigation = %+v", nav)
+	}
+
+	v = modelsView{Provider: "bedrock", Active: "claude"}
+	text, kb = renderModelsPage(v, nil)
+	if !strings.Contains(text, "Not configured") || !strings.Contains(text, "This chat uses Claude") {
+		t.Errorf("empty tab text = %q", text)
+	}
+	if len(kb.InlineKeyboard) != 1 {
+		t.Errorf("empty tab has %d rows", len(kb.InlineKeyboard))
+	}
+}
+
+func TestFilterCatalog(t *testing.T) {
+	models := []catalogModel{
+		{ID: "meta-llama/llama-3.3-70b", Name: "Llama 3.3 70B"},
+		{ID: "openai/gpt-4o-mini", Name: "GPT-4o mini"},
+	}
+	if got := filterCatalog(models, "LLAMA"); len(got) != 1 || got[0].ID != "meta-llama/llama-3.3-70b" {
+		t.Errorf("filter by ID = %v", got)
+	}
+	if got := filterCatalog(models, "4o mini"); len(got) != 1 {
+		t.Errorf("filter by name = %v", got)
+	}
+	if got := filterCatalog(models, ""); len(got) != 2 {
+		t.Errorf("no filter = %v", got)
+	}
+}
@@ -1,26 +1,322 @@
 package main
 
 import (
-	"log"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
 	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 )
 
 func SetupNgrok(cfg *Config) error {
 	if cfg.NgrokToken == "" {
-		log.Println("Ngrok token not provided, skipping ngrok authtoken setup.")
+		slog.Info("ngrok token not provided, skipping ngrok authtoken setup")
 		return nil
 	}
 
-	log.Println("Setting ngrok authtoken via CLI...")
+	slog.Info("setting ngrok authtoken via CLI")
 
 	cmd := exec.Command("ngrok", "config", "add-authtoken", cfg.NgrokToken)
 
 	output, err := cmd.CombinedOutput()
 	if err != nil {
-		log.Printf("Failed to set ngrok authtoken: %v\nOutput: %s", err, string(output))
+		slog.Error("failed to set ngrok authtoken", "err", err, "output", string(output))
 		return err
 	}
 
-	log.Println("Ngrok authtoken set successfully.")
+	slog.Info("ngrok authtoken set")
 	return nil
 }
+
+// tunnelStartTimeout bounds how long we wait for ngrok to report a public URL.
+const tunnelStartTimeout = 20 * time.Second
+
+// Tunnel is a running ngrok agent forwarding a public URL to a local port.
+type Tunnel struct {
+	Port    int
+	URL     string
+	ChatID  int64
+	Started time.Time
+
+	cmd  *exec.Cmd
+	done chan struct{}
+}
+
+// TunnelManager runs one ngrok agent per forwarded port. Each agent gets its
+// own local API address so its public URL can be read back.
+type TunnelManager struct {
+	mu       sync.Mutex
+	tunnels  map[int]*Tunnel
+	starting map[int]bool // ports reserved by a Start still waiting for ngrok
+}
+
+func NewTunnelManager() *TunnelManager {
+	return &TunnelManager{tunnels: make(map[int]*Tunnel), starting: make(map[int]bool)}
+}
+
+// Start launches ngrok for a local port and waits for its public URL. The
+// port is reserved first, so concurrent starts cannot both launch ngrok.
+func (m *TunnelManager) Start(ctx context.Context, chatID int64, port int) (*Tunnel, error) {
+	m.mu.Lock()
+	if t, ok := m.tunnels[port]; ok {
+		m.mu.Unlock()
+		return nil, fmt.Errorf("port %d is already tunneled at %s", port, t.URL)
+	}
+	if m.starting[port] {
+		m.mu.Unlock()
+		return nil, fmt.Errorf("port %d is already being tunneled", port)
+	}
+	m.starting[port] = true
+	m.mu.Unlock()
+	defer func() {
+		m.mu.Lock()
+		delete(m.starting, port)
+		m.mu.Unlock()
+	}()
+
+	apiAddr, err := freeLocalAddr()
+	if err != nil {
+		return nil, fmt.Errorf("pick ngrok API address: %w", err)
+	}
+
+	cmd := exec.Command("ngrok", "http", strconv.Itoa(port), "--web-addr", apiAddr, "--log", "false")
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("start ngrok: %w", err)
+	}
+	t := &Tunnel{Port: port, ChatID: chatID, Started: time.Now(), cmd: cmd, done: make(chan struct{})}
+	go func() {
+		err := cmd.Wait()
+		close(t.done)
+		m.mu.Lock()
+		if m.tunnels[port] == t {
+			delete(m.tunnels, port)
+		}
+		m.mu.Unlock()
+		slog.Info("ngrok tunnel exited", "port", port, "err", err)
+	}()
+
+	waitCtx, cancel := context.WithTimeout(ctx, tunnelStartTimeout)
+	defer cancel()
+	url, err := waitForTunnelURL(waitCtx, apiAddr, t.done)
+	if err != nil {
+		cmd.Process.Kill()
+		return nil, err
+	}
+	t.URL = url
+
+	m.mu.Lock()
+	m.tunnels[port] = t
+	m.mu.Unlock()
+	return t, nil
+}
+
+// Stop kills the tunnel for a port. Returns false if none was running.
+func (m *TunnelManager) Stop(port int) bool {
+	m.mu.Lock()
+	t, ok := m.tunnels[port]
+	delete(m.tunnels, port)
+	m.mu.Unlock()
+	if !ok {
+		return false
+	}
+	t.cmd.Process.Kill()
+	<-t.done
+	return true
+}
+
+// StopChats kills the tunnels chats opened, keeping the webhook's, and
+// returns how many it stopped.
+func (m *TunnelManager) StopChats() int {
+	n := 0
+	for _, t := range m.List() {
+		if t.ChatID != 0 && m.Stop(t.Port) {
+			n++
+		}
+	}
+	return n
+}
+
+// StopAll kills every running tunnel.
+func (m *TunnelManager) StopAll() {
+	for _, t := range m.List() {
+		m.Stop(t.Port)
+	}
+}
+
+// List returns running tunnels sorted by port.
+func (m *TunnelManager) List() []*Tunnel {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	list := make([]*Tunnel, 0, len(m.tunnels))
+	for _, t := range m.tunnels {
+		list = append(list, t)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Port < list[j].Port })
+	return list
+}
+
+// freeLocalAddr returns a loopback address with a currently unused port.
+func freeLocalAddr() (string, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", err
+	}
+	defer l.Close()
+	return l.Addr().String(), nil
+}
+
+// waitForTunnelURL polls the ngrok agent API until it reports a public URL,
+// preferring https.
+func waitForTunnelURL(ctx context.Context, apiAddr string, exited <-chan struct{}) (string, error) {
+	ticker := time.NewTicker(500 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return "", fmt.Errorf("timed out waiting for ngrok public URL (is NGROK_AUTHTOKEN set?)")
+		case <-exited:
+			return "", fmt.Errorf("ngrok exited before the tunnel came up")
+		case <-ticker.C:
+		}
+		if url := fetchTunnelURL(ctx, apiAddr); url != "" {
+			return url, nil
+		}
+	}
+}
+
+func fetchTunnelURL(ctx context.Context, apiAddr string) string {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://"+apiAddr+"/api/tunnels", nil)
+	if err != nil {
+		return ""
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return ""
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		Tunnels []struct {
+			PublicURL string `json:"public_url"`
+		} `json:"tunnels"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return ""
+	}
+	url := ""
+	for _, t := range body.Tunnels {
+		if strings.HasPrefix(t.PublicURL, "https://") {
+			return t.PublicURL
+		}
+		if url == "" {
+			url = t.PublicURL
+		}
+	}
+	return url
+}
+
+// chatTunnels returns the tunnels chatID may see and stop: its own, or
+// every tunnel for an admin.
+func (h *Handlers) chatTunnels(chatID int64) []*Tunnel {
+	all := h.tunnels.List()
+	if h.isAdmin(chatID) {
+		return all
+	}
+	var own []*Tunnel
+	for _, t := range all {
+		if t.ChatID == chatID {
+			own = append(own, t)
+		}
+	}
+	return own
+}
+
+// HandleTunnel manages ngrok tunnels: /tunnel start <port>, /tunnel stop [port], /tunnel list.
+func (h *Handlers) HandleTunnel(ctx context.Context, chatID int64, args string) {
+	a, ok := h.parseCommandArgs(chatID, "tunnel", args)
+	if !ok {
+		return
+	}
+	sub := strings.ToLower(a.Arg(0))
+	if sub == "" {
+		sub = "list"
+	}
+
+	switch sub {
+	case "start":
+		if h.readOnly.Enabled(chatID) {
+			h.sender.SendPlain(chatID, h.t(chatID, "readonly.disabled", "tunnel start"))
+			return
+		}
+		if a.Len() < 2 {
+			h.sendUsage(chatID, "tunnel")
+			return
+		}
+		port, err := a.Int(1, 0)
+		if err != nil || port < 1 || port > 65535 {
+			h.sender.SendPlain(chatID, h.t(chatID, "tunnel.invalid_port", a.Arg(1)))
+			return
+		}
+		h.sender.SendTyping(chatID)
+		t, err := h.tunnels.Start(ctx, chatID, port)
+		if err != nil {
+			slog.Error("tunnel start failed", "chat_id", chatID, "port", port, "err", err)
+			h.sender.SendPlain(chatID, h.t(chatID, "tunnel.failed", err))
+			return
+		}
+		slog.Info("tunnel started", "chat_id", chatID, "url", t.URL, "port", port)
+		h.sender.SendPinned(chatID, h.t(chatID, "tunnel.open", t.URL, port, port))
+
+	case "stop":
+		port := 0
+		if a.Len() > 1 {
+			var err error
+			if port, err = a.Int(1, 0); err != nil {
+				h.sender.SendPlain(chatID, h.t(chatID, "tunnel.invalid_port", a.Arg(1)))
+				return
+			}
+		}
+		var ports []int
+		for _, t := range h.chatTunnels(chatID) {
+			// The webhook's tunnel carries the bot's own updates.
+			if t.ChatID != 0 && (port == 0 || t.Port == port) {
+				ports = append(ports, t.Port)
+			}
+		}
+		stopped := 0
+		for _, port := range ports {
+			if h.tunnels.Stop(port) {
+				slog.Info("tunnel stopped", "chat_id", chatID, "port", port)
+				stopped++
+			}
+		}
+		if stopped == 0 {
+			h.sender.SendPlain(chatID, h.t(chatID, "tunnel.none_matching"))
+			return
+		}
+		h.sender.SendPlain(chatID, h.t(chatID, "tunnel.stopped", stopped))
+
+	case "list":
+		tunnels := h.chatTunnels(chatID)
+		if len(tunnels) == 0 {
+			h.sender.SendPlain(chatID, h.t(chatID, "tunnel.none"))
+			return
+		}
+		var b strings.Builder
+		b.WriteString(h.t(chatID, "tunnel.list") + "\n")
+		for _, t := range tunnels {
+			b.WriteString(h.t(chatID, "tunnel.entry", t.URL, t.Port, time.Since(t.Started).Round(time.Second)) + "\n")
+		}
+		h.sender.SendPlain(chatID, b.String())
+
+	default:
+		h.sendUsage(chatID, "tunnel")
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeNgrokEnv makes the test binary act as ngrok: it serves the agent
+// API on --web-addr until killed, or exits at once when set to "exit".
+const fakeNgrokEnv = "TRASH_FAKE_NGROK"
+
+func TestFakeNgrok(t *testing.T) {
+	mode := os.Getenv(fakeNgrokEnv)
+	if mode == "" {
+		t.Skip("only runs as the fake ngrok")
+	}
+	args := os.Args[slices.Index(os.Args, "--")+1:]
+	if mode == "exit" {
+		fmt.Fprintln(os.Stderr, "ERROR: authentication failed")
+		os.Exit(1)
+	}
+	addr := args[slices.Index(args, "--web-addr")+1]
+	http.HandleFunc("/api/tunnels", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"tunnels":[{"public_url":"http://fake.ngrok.app"},{"public_url":"https://fake.ngrok.app/%s"}]}`, args[1])
+	})
+	http.ListenAndServe(addr, nil)
+	os.Exit(1)
+}
+
+// useFakeNgrok puts a fake ngrok first on PATH.
+func useFakeNgrok(t *testing.T, mode string) {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("starts a process")
+	}
+	dir := t.TempDir()
+	script := fmt.Sprintf("#!/bin/sh\n%s=%s exec %q -test.run='^TestFakeNgrok$' -- \"$@\"\n", fakeNgrokEnv, mode, os.Args[0])
+	if err := os.WriteFile(filepath.Join(dir, "ngrok"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func TestTunnelLifecycle(t *testing.T) {
+	useFakeNgrok(t, "serve")
+	m := NewTunnelManager()
+	ctx := context.Background()
+
+	tun, err := m.Start(ctx, 1, 8080)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tun.URL != "https://fake.ngrok.app/8080" || tun.ChatID != 1 {
+		t.Errorf("tunnel = %+v, want the https URL", tun)
+	}
+	if _, err := m.Start(ctx, 2, 8080); err == nil || !strings.Contains(err.Error(), "already tunneled") {
+		t.Errorf("second tunnel on a port: %v", err)
+	}
+	if _, err := m.Start(ctx, 1, 9090); err != nil {
+		t.Fatal(err)
+	}
+	if list := m.List(); len(list) != 2 || list[0].Port != 8080 || list[1].Port != 9090 {
+		t.Errorf("List = %v", list)
+	}
+
+	if !m.Stop(8080) || m.Stop(8080) {
+		t.Error("Stop should succeed once")
+	}
+	select {
+	case <-tun.done:
+	case <-time.After(5 * time.Second):
+		t.Error("ngrok still running after Stop")
+	}
+	m.StopAll()
+	if list := m.List(); len(list) != 0 {
+		t.Errorf("after StopAll: %v", list)
+	}
+}
+
+func TestTunnelExitsEarly(t *testing.T) {
+	useFakeNgrok(t, "exit")
+	m := NewTunnelManager()
+	if _, err := m.Start(context.Background(), 1, 8080); err == nil || !strings.Contains(err.Error(), "exited") {
+		t.Errorf("Start = %v, want the early exit reported", err)
+	}
+	if list := m.List(); len(list) != 0 {
+		t.Errorf("a dead tunnel is listed: %v", list)
+	}
+}
+
+func TestTunnelStopChatsKeepsWebhook(t *testing.T) {
+	useFakeNgrok(t, "serve")
+	m := NewTunnelManager()
+	defer m.StopAll()
+	ctx := context.Background()
+	if _, err := m.Start(ctx, 0, 8443); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Start(ctx, 1, 8080); err != nil {
+		t.Fatal(err)
+	}
+	if n := m.StopChats(); n != 1 {
+		t.Errorf("StopChats = %d, want 1", n)
+	}
+	if list := m.List(); len(list) != 1 || list[0].ChatID != 0 {
+		t.Errorf("after StopChats: %v", list)
+	}
+}
+
+func TestTunnelStartReservesPort(t *testing.T) {
+	useFakeNgrok(t, "serve")
+	m := NewTunnelManager()
+	defer m.StopAll()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 4)
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(chatID int64) {
+			defer wg.Done()
+			_, err := m.Start(context.Background(), chatID, 8080)
+			errs <- err
+		}(int64(i + 1))
+	}
+	wg.Wait()
+	close(errs)
+	started := 0
+	for err := range errs {
+		if err == nil {
+			started++
+		}
+	}
+	if started != 1 || len(m.List()) != 1 {
+		t.Errorf("%d starts succeeded with %d tunnels, want 1", started, len(m.List()))
+	}
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// omodelListLimit caps how many models one /omodel listing shows as buttons.
+const omodelListLimit = 30
+
+// HandleOpenRouterModel browses OpenRouter's catalog: vendors, then a
+// vendor's models cheapest first, or switches to a model ID directly.
+func (h *Handlers) HandleOpenRouterModel(ctx context.Context, chatID int64, args string) {
+	a, ok := h.parseCommandArgs(chatID, "omodel", args, "max-price")
+	if !ok {
+		return
+	}
+	maxPrice := -1.0
+	if v := a.Flag("max-price", ""); v != "" {
+		p, err := strconv.ParseFloat(v, 64)
+		if err != nil || p < 0 {
+			h.sendArgError(chatID, "omodel", fmt.Errorf("invalid --max-price %q", v))
+			return
+		}
+		maxPrice = p
+	}
+
+	models, err := h.openrouter.Models(ctx)
+	if err != nil {
+		slog.Warn("fetch openrouter models failed", "chat_id", chatID, "err", err)
+		if id := a.Arg(0); strings.Contains(id, "/") {
+			// Catalog unavailable: trust the ID the user typed.
+			h.setOpenRouterModel(chatID, id)
+			h.sender.SendPlain(chatID, h.t(chatID, "omodel.switched_unverified", id))
+			return
+		}
+		h.sender.SendPlain(chatID, h.t(chatID, "omodel.fetch_failed", err))
+		return
+	}
+
+	arg := strings.ToLower(a.Arg(0))
+	switch {
+	case strings.Contains(arg, "/"):
+		for _, m := range models {
+			if strings.ToLower(m.ID) == arg {
+				h.setOpenRouterModel(chatID, m.ID)
+				h.sender.SendPlain(chatID, h.t(chatID, "omodel.switched", m.ID, formatModelPrice(h.uiLanguage(chatID), m)))
+				return
+			}
+		}
+		h.sender.SendPlain(chatID, h.t(chatID, "omodel.unknown", a.Arg(0)))
+	case arg != "" || maxPrice >= 0:
+		h.sendOpenRouterModels(chatID, filterOpenRouterModels(models, arg, maxPrice), arg, maxPrice)
+	default:
+		h.sendOpenRouterVendors(chatID, models)
+	}
+}
+
+// sendOpenRouterVendors shows one button per vendor with its model count
+// and cheapest input price.
+func (h *Handlers) sendOpenRouterVendors(chatID int64, models []OpenRouterModel) {
+	type vendorInfo struct {
+		name     string
+		count    int
+		cheapest float64
+	}
+	byName := map[string]*vendorInfo{}
+	for _, m := range models {
+		v := byName[m.Vendor()]
+		if v == nil {
+			v = &vendorInfo{name: m.Vendor(), cheapest: -1}
+			byName[m.Vendor()] = v
+		}
+		v.count++
+		if m.PromptPrice >= 0 && (v.cheapest < 0 || m.PromptPrice < v.cheapest) {
+			v.cheapest = m.PromptPrice
+		}
+	}
+	vendors := make([]*vendorInfo, 0, len(byName))
+	for _, v := range byName {
+		vendors = append(vendors, v)
+	}
+	sort.Slice(vendors, func(i, j int) bool { return vendors[i].name < vendors[j].name })
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	var row []tgbotapi.InlineKeyboardButton
+	for _, v := range vendors {
+		data := "omodel:v:" + v.name
+		if len(data) > 64 {
+			continue
+		}
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("%s (%d)", v.name, v.count), data))
+		if len(row) == 3 {
+			rows = append(rows, row)
+			row = nil
+		}
+	}
+	if len(row) > 0 {
+		rows = append(rows, row)
+	}
+	if len(rows) == 0 {
+		h.sender.SendPlain(chatID, h.t(chatID, "omodel.no_models"))
+		return
+	}
+	h.sender.SendWithKeyboard(chatID, h.t(chatID, "omodel.choose_vendor", h.chatModel(chatID, "openrouter")), tgbotapi.NewInlineKeyboardMarkup(rows...))
+}
+
+// sendOpenRouterModels shows up to omodelListLimit models as buttons.
+func (h *Handlers) sendOpenRouterModels(chatID int64, models []OpenRouterModel, vendor string, maxPrice float64) {
+	if len(models) == 0 {
+		h.sender.SendPlain(chatID, h.t(chatID, "omodel.no_match"))
+		return
+	}
+	current := h.chatModel(chatID, "openrouter")
+	lang := h.uiLanguage(chatID)
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, m := range models {
+		data := "omodel:m:" + m.ID
+		if len(data) > 64 {
+			continue
+		}
+		label := fmt.Sprintf("%s — %s", m.Name, formatModelPrice(lang, m))
+		if m.ID == current {
+			label = "✅ " + label
+		}
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(label, data)))
+		if len(rows) == omodelListLimit {
+			break
+		}
+	}
+	title := h.t(chatID, "omodel.title")
+	if vendor != "" {
+		title += h.t(chatID, "omodel.title_vendor", vendor)
+	}
+	if maxPrice >= 0 {
+		title += h.t(chatID, "omodel.title_price", trimPrice(maxPrice))
+	}
+	if len(models) > len(rows) {
+		title += h.t(chatID, "omodel.title_cheapest", len(rows), len(models))
+	}
+	h.sender.SendWithKeyboard(chatID, title+":", tgbotapi.NewInlineKeyboardMarkup(rows...))
+}
+
+// filterOpenRouterModels keeps models from vendor (all when empty) whose
+// input price is at most maxPrice (no cap when negative).
+func filterOpenRouterModels(models []OpenRouterModel, vendor string, maxPrice float64) []OpenRouterModel {
+	var out []OpenRouterModel
+	for _, m := range models {
+		if vendor != "" && strings.ToLower(m.Vendor()) != vendor {
+			continue
+		}
+		if maxPrice >= 0 && (m.PromptPrice < 0 || m.PromptPrice > maxPrice) {
+			continue
+		}
+		out = append(out, m)
+	}
+	return out
+}
+
+// handleOpenRouterModelCallback handles the vendor and model buttons of /omodel.
+func (h *Handlers) handleOpenRouterModelCallback(ctx context.Context, chatID int64, callbackID, data string, messageID int) {
+	kind, value, _ := strings.Cut(strings.TrimPrefix(data, "omodel:"), ":")
+	switch kind {
+	case "v":
+		h.sender.AnswerCallback(callbackID, "")
+		models, err := h.openrouter.Models(ctx)
+		if err != nil {
+			h.sender.SendPlain(chatID, h.t(chatID, "omodel.fetch_failed", err))
+			return
+		}
+		h.sender.EditRemoveKeyboard(chatID, messageID, h.t(chatID, "omodel.vendor", value))
+		h.sendOpenRouterModels(chatID, filterOpenRouterModels(models, strings.ToLower(value), -1), value, -1)
+	case "m":
+		h.setOpenRouterModel(chatID, value)
+		h.sender.AnswerCallback(callbackID, h.t(chatID, "model.switched"))
+		h.sender.EditRemoveKeyboard(chatID, messageID, h.t(chatID, "omodel.done", value))
+	default:
+		h.sender.AnswerCallback(callbackID, h.t(chatID, "selection.unknown"))
+	}
+}
+
+// setOpenRouterModel switches the chat's OpenRouter model and resets its
+// history so the next message starts fresh on it.
+func (h *Handlers) setOpenRouterModel(chatID int64, model string) {
+	if err := h.setChatModel(chatID, "openrouter", model); err != nil {
+		slog.Warn("save openrouter model failed", "chat_id", chatID, "err", err)
+	}
+	slog.Info("model switched", "chat_id", chatID, "provider", "openrouter", "model", model)
+}
@@ -0,0 +1,393 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// openRouterAPIKeyFile is where we persist the OpenRouter API key across restarts.
+const openRouterAPIKeyFile = ".openrouter_api_key"
+
+const (
+	openRouterBaseURL      = "https://openrouter.ai/api/v1"
+	defaultOpenRouterModel = "openrouter/auto"
+	openRouterModelsTTL    = time.Hour
+)
+
+// loadOpenRouterAPIKey reads the stored API key from disk (if any).
+func loadOpenRouterAPIKey() string {
+	home, _ := os.UserHomeDir()
+	data, err := os.ReadFile(filepath.Join(home, openRouterAPIKeyFile))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// saveOpenRouterAPIKey writes the API key to disk.
+func saveOpenRouterAPIKey(key string) error {
+	home, _ := os.UserHomeDir()
+	return os.WriteFile(filepath.Join(home, openRouterAPIKeyFile), []byte(strings.TrimSpace(key)), 0600)
+}
+
+// --- OpenRouter (OpenAI-compatible) API types ---
+
+type openRouterMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// openAIMessages converts the bot's history (Gemini roles "user"/"model")
+// and the new message to the OpenAI chat format shared by OpenRouter and
+// Azure OpenAI. The command protocol is explained on the first message, as
+// for Gemini.
+func openAIMessages(systemPrompt string, history []GeminiMessage, message string) []openRouterMessage {
+	msgs := []openRouterMessage{{Role: "system", Content: systemPrompt}}
+	for _, m := range history {
+		role := "user"
+		if m.Role == "model" {
+			role = "assistant"
+		}
+		msgs = append(msgs, openRouterMessage{Role: role, Content: m.Content})
+	}
+	if len(history) == 0 {
+		message = geminiCommandInstruction + message
+	}
+	return append(msgs, openRouterMessage{Role: "user", Content: message})
+}
+
+type openRouterRequest struct {
+	Model     string              `json:"model"`
+	Messages  []openRouterMessage `json:"messages"`
+	Stream    bool                `json:"stream,omitempty"`
+	MaxTokens int                 `json:"max_tokens,omitempty"`
+	Usage     struct {
+		Include bool `json:"include"`
+	} `json:"usage"`
+}
+
+type openRouterResponse struct {
+	Model   string `json:"model"`
+	Choices []struct {
+		Message      openRouterMessage `json:"message"`
+		FinishReason string            `json:"finish_reason"`
+	} `json:"choices"`
+	Usage openAIUsage `json:"usage"`
+	Error *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// OpenRouterModel is one entry of OpenRouter's model catalog. Prices are USD
+// per million tokens; negative means the price varies (routers).
+type OpenRouterModel struct {
+	ID            string
+	Name          string
+	ContextLength int
+	PromptPrice   float64
+	OutputPrice   float64
+	Vision        bool // accepts image input
+	Tools         bool // supports tool calling
+	Reasoning     bool // can think before answering
+}
+
+// Vendor returns the model's provider prefix, e.g. "anthropic" for
+// "anthropic/claude-sonnet-4".
+func (m OpenRouterModel) Vendor() string {
+	vendor, _, _ := strings.Cut(m.ID, "/")
+	return vendor
+}
+
+// OpenRouterClient calls OpenRouter's chat completions API, giving access to
+// many vendors' models with a single API key. Like Gemini it is stateless, so
+// the bot keeps the conversation history and commands run locally.
+type OpenRouterClient struct {
+	mu           sync.RWMutex
+	model        string
+	apiKey       string
+	systemPrompt string
+	baseURL      string
+	httpClient   *http.Client
+
+	models        []OpenRouterModel // cached catalog
+	modelsFetched time.Time
+}
+
+func NewOpenRouterClient(cfg *Config) *OpenRouterClient {
+	apiKey := cfg.OpenRouterAPIKey
+	if apiKey == "" {
+		apiKey = loadOpenRouterAPIKey()
+	}
+	model := cfg.OpenRouterModel
+	if model == "" {
+		model = defaultOpenRouterModel
+	}
+	return &OpenRouterClient{
+		model:        model,
+		apiKey:       apiKey,
+		systemPrompt: chatSystemPrompt(cfg),
+		baseURL:      openRouterBaseURL,
+		httpClient:   &http.Client{Timeout: 120 * time.Second},
+	}
+}
+
+// SetAPIKey stores a new API key in memory and persists it to disk.
+func (o *OpenRouterClient) SetAPIKey(key string) error {
+	o.mu.Lock()
+	o.apiKey = key
+	o.mu.Unlock()
+	if err := saveOpenRouterAPIKey(key); err != nil {
+		return fmt.Errorf("failed to save API key: %w", err)
+	}
+	slog.Info("openrouter API key updated and saved")
+	return nil
+}
+
+// SetModel changes the active OpenRouter model at runtime.
+func (o *OpenRouterClient) SetModel(model string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.model = model
+	slog.Info("openrouter model changed", "model", model)
+}
+
+// GetModel returns the currently active model.
+func (o *OpenRouterClient) GetModel() string {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	return o.model
+}
+
+// HasAPIKey reports whether an API key is configured.
+func (o *OpenRouterClient) HasAPIKey() bool {
+	return o.getAPIKey() != ""
+}
+
+func (o *OpenRouterClient) getAPIKey() string {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	return o.apiKey
+}
+
+// SetupToken returns a callback that checks and stores a pasted API key;
+// the "login.key_openrouter" message tells the user where to get one.
+func (o *OpenRouterClient) SetupToken(ctx context.Context) (func(key string) error, error) {
+	feedKey := func(key string) error {
+		key = strings.TrimSpace(key)
+		if key == "" {
+			return fmt.Errorf("empty API key")
+		}
+		if !strings.HasPrefix(key, "sk-or-") {
+			return fmt.Errorf("that doesn't look like an OpenRouter API key (should start with sk-or-)")
+		}
+		return o.SetAPIKey(key)
+	}
+	return feedKey, nil
+}
+
+// Send sends a message with the full conversation context and returns the
+// reply with the call's usage. history uses Gemini's roles ("user"/"model").
+func (o *OpenRouterClient) Send(ctx context.Context, history []GeminiMessage, message string) (string, APIUsage, error) {
+	return o.send(ctx, history, message, nil)
+}
+
+// Stream is Send with the reply streamed: onDelta is called with each piece
+// of text as it arrives. If ctx is cancelled mid-stream, the text received
+// so far is returned with ctx's error.
+func (o *OpenRouterClient) Stream(ctx context.Context, history []GeminiMessage, message string, onDelta func(string)) (string, APIUsage, error) {
+	return o.send(ctx, history, message, onDelta)
+}
+
+func (o *OpenRouterClient) send(ctx context.Context, history []GeminiMessage, message string, onDelta func(string)) (string, APIUsage, error) {
+	model := modelFrom(ctx, o.GetModel())
+	usage := APIUsage{Model: model}
+	apiKey := o.getAPIKey()
+	if apiKey == "" {
+		return "", usage, fmt.Errorf("api key not set")
+	}
+
+	reqBody := openRouterRequest{Model: model, Messages: openAIMessages(systemPromptFrom(ctx, o.systemPrompt), history, message), Stream: onDelta != nil, MaxTokens: maxTokensFrom(ctx)}
+	reqBody.Usage.Include = true
+
+	body, err := json.Marshal(reqBody)
+	if err != nil {
+		return "", usage, fmt.Errorf("marshal request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.baseURL+"/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return "", usage, fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+apiKey)
+	req.Header.Set("X-Title", "AI Code Bot")
+
+	slog.Debug("openrouter API call", "model", model, "history_turns", len(history), "message_bytes", len(message))
+	start := time.Now()
+	resp, err := o.httpClient.Do(req)
+	if err != nil {
+		return "", usage, fmt.Errorf("HTTP request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	// Errors are plain JSON even when streaming was asked for.
+	if onDelta != nil && resp.StatusCode == http.StatusOK {
+		st, err := readChatStream(ctx, resp.Body, onDelta)
+		slog.Info("openrouter API stream", "duration", time.Since(start), "bytes", len(st.Text), "err", err)
+		if st.Model == "" {
+			st.Model = model
+		}
+		usage = st.Usage.apiUsage(st.Model)
+		if err != nil {
+			return st.Text, usage, fmt.Errorf("openrouter: %w", err)
+		}
+		result := strings.TrimSpace(st.Text)
+		if result == "" {
+			return "", usage, fmt.Errorf("openrouter returned empty response (finish_reason=%s)", st.FinishReason)
+		}
+		return result, usage, nil
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", usage, fmt.Errorf("read response: %w", err)
+	}
+	slog.Info("openrouter API response", "duration", time.Since(start), "status", resp.StatusCode, "body_bytes", len(respBody))
+
+	var apiResp openRouterResponse
+	if err := json.Unmarshal(respBody, &apiResp); err != nil {
+		return "", usage, fmt.Errorf("unmarshal response (status %d): %w\nraw: %.500s", resp.StatusCode, err, respBody)
+	}
+	if apiResp.Model != "" {
+		usage.Model = apiResp.Model
+	}
+	usage = apiResp.Usage.apiUsage(usage.Model)
+
+	if apiResp.Error != nil {
+		slog.Error("openrouter API error", "code", apiResp.Error.Code, "message", apiResp.Error.Message)
+		if apiResp.Error.Code == http.StatusUnauthorized {
+			return "", usage, fmt.Errorf("openrouter API error (401): invalid api key: %s", apiResp.Error.Message)
+		}
+		return "", usage, fmt.Errorf("openrouter API error (%d): %s", apiResp.Error.Code, apiResp.Error.Message)
+	}
+	if len(apiResp.Choices) == 0 {
+		return "", usage, fmt.Errorf("openrouter returned no choices (raw: %.300s)", respBody)
+	}
+	result := strings.TrimSpace(apiResp.Choices[0].Message.Content)
+	if result == "" {
+		return "", usage, fmt.Errorf("openrouter returned empty response (finish_reason=%s)", apiResp.Choices[0].FinishReason)
+	}
+	return result, usage, nil
+}
+
+// Models returns OpenRouter's model catalog, cached for an hour.
+func (o *OpenRouterClient) Models(ctx context.Context) ([]OpenRouterModel, error) {
+	o.mu.RLock()
+	if o.models != nil && time.Since(o.modelsFetched) < openRouterModelsTTL {
+		models := o.models
+		o.mu.RUnlock()
+		return models, nil
+	}
+	o.mu.RUnlock()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, o.baseURL+"/models", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := o.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetch models: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch models: HTTP %d", resp.StatusCode)
+	}
+	var catalog struct {
+		Data []struct {
+			ID            string `json:"id"`
+			Name          string `json:"name"`
+			ContextLength int    `json:"context_length"`
+			Pricing       struct {
+				Prompt     string `json:"prompt"`
+				Completion string `json:"completion"`
+			} `json:"pricing"`
+			Architecture struct {
+				InputModalities []string `json:"input_modalities"`
+			} `json:"architecture"`
+			SupportedParameters []string `json:"supported_parameters"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&catalog); err != nil {
+		return nil, fmt.Errorf("decode models: %w", err)
+	}
+	models := make([]OpenRouterModel, 0, len(catalog.Data))
+	for _, d := range catalog.Data {
+		models = append(models, OpenRouterModel{
+			ID:            d.ID,
+			Name:          d.Name,
+			ContextLength: d.ContextLength,
+			PromptPrice:   perMillion(d.Pricing.Prompt),
+			OutputPrice:   perMillion(d.Pricing.Completion),
+			Vision:        slices.Contains(d.Architecture.InputModalities, "image"),
+			Tools:         slices.Contains(d.SupportedParameters, "tools"),
+			Reasoning:     slices.Contains(d.SupportedParameters, "reasoning"),
+		})
+	}
+	sortModelsByPrice(models)
+
+	o.mu.Lock()
+	o.models, o.modelsFetched = models, time.Now()
+	o.mu.Unlock()
+	return models, nil
+}
+
+// perMillion converts OpenRouter's per-token USD price string to USD per
+// million tokens. Unparseable prices are treated as varying.
+func perMillion(perToken string) float64 {
+	v, err := strconv.ParseFloat(perToken, 64)
+	if err != nil || v < 0 {
+		return -1
+	}
+	return v * 1e6
+}
+
+// sortModelsByPrice orders models cheapest first (by input price, then
+// output price); models with varying prices go last.
+func sortModelsByPrice(models []OpenRouterModel) {
+	sort.SliceStable(models, func(i, j int) bool {
+		a, b := models[i], models[j]
+		if (a.PromptPrice < 0) != (b.PromptPrice < 0) {
+			return b.PromptPrice < 0
+		}
+		if a.PromptPrice != b.PromptPrice {
+			return a.PromptPrice < b.PromptPrice
+		}
+		return a.OutputPrice < b.OutputPrice
+	})
+}
+
+// formatModelPrice renders a model's input/output prices per million tokens.
+func formatModelPrice(lang string, m OpenRouterModel) string {
+	switch {
+	case m.PromptPrice < 0:
+		return translate(lang, "price.varies")
+	case m.PromptPrice == 0 && m.OutputPrice == 0:
+		return translate(lang, "price.free")
+	}
+	return translate(lang, "price.per_million", trimPrice(m.PromptPrice), trimPrice(m.OutputPrice))
+}
+
+func trimPrice(v float64) string {
+	return strings.TrimSuffix(strings.TrimRight(strconv.FormatFloat(v, 'f', 3, 64), "0"), ".")
+}
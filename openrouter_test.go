@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestOpenRouterSend(t *testing.T) {
+	var got openRouterRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/chat/completions" || r.Header.Get("Authorization") != "Bearer sk-or-test" {
+			t.Errorf("unexpected request %s %s", r.URL.Path, r.Header.Get("Authorization"))
+		}
+		body, _ := io.ReadAll(r.Body)
+		json.Unmarshal(body, &got)
+		io.WriteString(w, `{"model":"openai/gpt-4o-mini","choices":[{"message":{"role":"assistant","content":" hi "},"finish_reason":"stop"}],
+			"usage":{"prompt_tokens":120,"completion_tokens":8,"total_tokens":128,"cost":0.00042}}`)
+	}))
+	defer srv.Close()
+
+	o := NewOpenRouterClient(&Config{OpenRouterAPIKey: "sk-or-test"})
+	o.baseURL = srv.URL
+	history := []GeminiMessage{{Role: "user", Content: "q"}, {Role: "model", Content: "a"}}
+	reply, usage, err := o.Send(context.Background(), history, "next")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply != "hi" || usage.TotalTokens != 128 || usage.CostUSD != 0.00042 || usage.Model != "openai/gpt-4o-mini" {
+		t.Errorf("reply %q usage %+v", reply, usage)
+	}
+	if got.Model != defaultOpenRouterModel || !got.Usage.Include || len(got.Messages) != 4 {
+		t.Fatalf("request = %+v", got)
+	}
+	if got.Messages[0].Role != "system" || got.Messages[2].Role != "assistant" || got.Messages[3].Content != "next" {
+		t.Errorf("messages = %+v", got.Messages)
+	}
+}
+
+func TestOpenRouterSendErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		io.WriteString(w, `{"error":{"code":401,"message":"No auth credentials found"}}`)
+	}))
+	defer srv.Close()
+
+	o := NewOpenRouterClient(&Config{OpenRouterAPIKey: "sk-or-bad"})
+	o.baseURL = srv.URL
+	_, _, err := o.Send(context.Background(), nil, "hello")
+	if !IsGeminiNotLoggedIn(err) {
+		t.Errorf("401 should read as not logged in, got %v", err)
+	}
+
+	o.apiKey = ""
+	if _, _, err := o.Send(context.Background(), nil, "hello"); err == nil || !strings.Contains(err.Error(), "api key") {
+		t.Errorf("missing key error = %v", err)
+	}
+}
+
+func TestOpenRouterModels(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"data":[
+			{"id":"anthropic/claude-sonnet-4","name":"Claude Sonnet 4","pricing":{"prompt":"0.000003","completion":"0.000015"},
+				"architecture":{"input_modalities":["text","image"]},"supported_parameters":["tools","reasoning","max_tokens"]},
+			{"id":"openrouter/auto","name":"Auto Router","pricing":{"prompt":"-1","completion":"-1"}},
+			{"id":"meta-llama/llama-3.3-70b:free","name":"Llama 3.3 70B (free)","pricing":{"prompt":"0","completion":"0"}},
+			{"id":"openai/gpt-4o-mini","name":"GPT-4o mini","pricing":{"prompt":"0.00000015","completion":"0.0000006"}}]}`)
+	}))
+	defer srv.Close()
+
+	o := NewOpenRouterClient(&Config{})
+	o.baseURL = srv.URL
+	models, err := o.Models(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, m := range models {
+		ids = append(ids, m.ID)
+	}
+	want := "meta-llama/llama-3.3-70b:free openai/gpt-4o-mini anthropic/claude-sonnet-4 openrouter/auto"
+	if strings.Join(ids, " ") != want {
+		t.Errorf("models sorted as %v", ids)
+	}
+	if p := formatModelPrice("en", models[1]); p != "$0.15/$0.6 per 1M" {
+		t.Errorf("price = %q", p)
+	}
+	if p := formatModelPrice("en", models[0]); p != "free" {
+		t.Errorf("free price = %q", p)
+	}
+	if m := models[2]; !m.Vision || !m.Tools || !m.Reasoning {
+		t.Errorf("capabilities of %s = %+v", m.ID, m)
+	}
+	if m := models[1]; m.Vision || m.Tools || m.Reasoning {
+		t.Errorf("capabilities of %s = %+v", m.ID, m)
+	}
+
+	if got := filterOpenRouterModels(models, "", 1); len(got) != 2 {
+		t.Errorf("max price 1 kept %d models", len(got))
+	}
+	if got := filterOpenRouterModels(models, "anthropic", -1); len(got) != 1 || got[0].Vendor() != "anthropic" {
+		t.Errorf("vendor filter = %v", got)
+	}
+}
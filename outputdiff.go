@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Commands are often re-run to watch something change (kubectl get pods,
+// df -h). With output diffing on, a repeated command's output is replaced,
+// in chat and in the results sent to the AI, by what changed since its
+// previous run.
+
+const (
+	// maxRememberedOutputs caps the commands whose output a chat keeps.
+	maxRememberedOutputs = 30
+	// maxDiffCells bounds the line diff's work (lines before × lines after);
+	// bigger outputs are shown in full.
+	maxDiffCells = 1 << 20
+)
+
+// OutputStore remembers the last output of the commands each chat ran, and
+// which chats want repeated outputs diffed.
+type OutputStore struct {
+	mu      sync.Mutex
+	outputs map[int64]map[string]string
+	order   map[int64][]string // commands, least recently run first
+	enabled map[int64]bool
+	def     bool // OUTPUT_DIFF
+}
+
+func NewOutputStore(enabled bool) *OutputStore {
+	return &OutputStore{
+		outputs: make(map[int64]map[string]string),
+		order:   make(map[int64][]string),
+		enabled: make(map[int64]bool),
+		def:     enabled,
+	}
+}
+
+// Swap records command's new output and returns the previous one.
+func (s *OutputStore) Swap(chatID int64, command, output string) (prev string, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	outputs := s.outputs[chatID]
+	if outputs == nil {
+		outputs = make(map[string]string)
+		s.outputs[chatID] = outputs
+	}
+	prev, ok = outputs[command]
+	order := s.order[chatID]
+	if ok {
+		for i, c := range order {
+			if c == command {
+				order = append(order[:i], order[i+1:]...)
+				break
+			}
+		}
+	} else if len(order) >= maxRememberedOutputs {
+		delete(outputs, order[0])
+		order = order[1:]
+	}
+	s.order[chatID] = append(order, command)
+	outputs[command] = output
+	return prev, ok
+}
+
+// Enabled reports whether the chat's repeated outputs are diffed.
+func (s *OutputStore) Enabled(chatID int64) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if on, ok := s.enabled[chatID]; ok {
+		return on
+	}
+	return s.def
+}
+
+func (s *OutputStore) SetEnabled(chatID int64, on bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.enabled[chatID] = on
+}
+
+// lineDiff lists the lines removed ("- ") and added ("+ ") between a and b,
+// collapsing unchanged runs into a count. ok is false when the outputs are
+// too large to diff.
+func lineDiff(a, b string) (diff string, ok bool) {
+	x := strings.Split(strings.TrimRight(a, "\n"), "\n")
+	y := strings.Split(strings.TrimRight(b, "\n"), "\n")
+	n, m := len(x), len(y)
+	if n*m > maxDiffCells {
+		return "", false
+	}
+	// lcs[i][j] is the length of the longest common subsequence of x[i:]
+	// and y[j:].
+	lcs := make([][]int32, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int32, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var out strings.Builder
+	same := 0
+	flush := func() {
+		if same > 0 {
+			fmt.Fprintf(&out, "  … %d unchanged\n", same)
+			same = 0
+		}
+	}
+	i, j := 0, 0
+	for i < n || j < m {
+		switch {
+		case i < n && j < m && x[i] == y[j]:
+			same++
+			i++
+			j++
+		case i < n && (j == m || lcs[i+1][j] >= lcs[i][j+1]):
+			flush()
+			out.WriteString("- " + x[i] + "\n")
+			i++
+		default:
+			flush()
+			out.WriteString("+ " + y[j] + "\n")
+			j++
+		}
+	}
+	flush()
+	return strings.TrimRight(out.String(), "\n"), true
+}
+
+// diffAgainstLastRun records command's output and, when the chat has output
+// diffing on and the command ran before, returns what changed instead of
+// the full output. The full output is kept when the diff would not be
+// shorter.
+func (h *Handlers) diffAgainstLastRun(chatID int64, command, output string) string {
+	prev, ok := h.outputs.Swap(chatID, h.vars.Expand(chatID, command), output)
+	if !ok || !h.outputs.Enabled(chatID) {
+		return output
+	}
+	if prev == output {
+		return fmt.Sprintf("(output unchanged since the last run: %d lines)", strings.Count(strings.TrimRight(output, "\n"), "\n")+1)
+	}
+	diff, ok := lineDiff(prev, output)
+	if !ok || len(diff) >= len(output) {
+		return output
+	}
+	return "Changes since the last run (- before, + now):\n```diff\n" + diff + "\n```"
+}
+
+// HandleOutputDiff shows or toggles output diffing for the chat.
+// Usage: /outdiff [on|off]
+func (h *Handlers) HandleOutputDiff(chatID int64, args string) {
+	switch strings.ToLower(strings.TrimSpace(args)) {
+	case "":
+		h.sender.SendPlain(chatID, h.t(chatID, "outdiff.state", h.onOff(chatID, h.outputs.Enabled(chatID))))
+	case "on":
+		h.outputs.SetEnabled(chatID, true)
+		h.sender.SendPlain(chatID, h.t(chatID, "outdiff.on"))
+	case "off":
+		h.outputs.SetEnabled(chatID, false)
+		h.sender.SendPlain(chatID, h.t(chatID, "outdiff.off"))
+	default:
+		h.sendUsage(chatID, "outdiff")
+	}
+}
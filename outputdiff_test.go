@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestLineDiff(t *testing.T) {
+	before := "NAME   STATUS\nweb-1  Running\nweb-2  Pending\ndb-0   Running\n"
+	after := "NAME   STATUS\nweb-1  Running\nweb-2  Running\ndb-0   Running\nweb-3  Pending\n"
+	got, ok := lineDiff(before, after)
+	if !ok {
+		t.Fatal("not diffed")
+	}
+	want := "  … 2 unchanged\n- web-2  Pending\n+ web-2  Running\n  … 1 unchanged\n+ web-3  Pending"
+	if got != want {
+		t.Errorf("lineDiff =\n%s\nwant\n%s", got, want)
+	}
+
+	big := strings.Repeat("x\n", 2000)
+	if _, ok := lineDiff(big, big+"y"); ok {
+		t.Error("diffed outputs over the size limit")
+	}
+}
+
+func TestOutputStore(t *testing.T) {
+	s := NewOutputStore(false)
+	if _, ok := s.Swap(1, "df -h", "a"); ok {
+		t.Error("first run had a previous output")
+	}
+	if prev, ok := s.Swap(1, "df -h", "b"); !ok || prev != "a" {
+		t.Errorf("Swap = %q, %v", prev, ok)
+	}
+	if _, ok := s.Swap(2, "df -h", "c"); ok {
+		t.Error("outputs shared between chats")
+	}
+
+	for i := 0; i < maxRememberedOutputs; i++ {
+		s.Swap(1, fmt.Sprintf("cmd %d", i), "out")
+	}
+	if _, ok := s.Swap(1, "df -h", "d"); ok {
+		t.Error("oldest command not evicted")
+	}
+	if _, ok := s.Swap(1, fmt.Sprintf("cmd %d", maxRememberedOutputs-1), "out"); !ok {
+		t.Error("recent command evicted")
+	}
+
+	if s.Enabled(1) {
+		t.Error("enabled by default")
+	}
+	s.SetEnabled(1, true)
+	if !s.Enabled(1) || s.Enabled(2) {
+		t.Error("SetEnabled not per chat")
+	}
+}
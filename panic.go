@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// /panic is the admin's emergency stop: every in-flight AI call and
+// command is cancelled, backgrounded commands and tunnels are killed,
+// pending approvals are dropped, and every chat is read-only until an
+// admin sends /resume. The stop is persisted so a restart does not lift it.
+
+// EmergencyStop holds the root context of all update handling, which
+// /panic cancels, and whether the bot is stopped.
+type EmergencyStop struct {
+	mu     sync.Mutex
+	path   string
+	ctx    context.Context
+	cancel context.CancelFunc
+	state  emergencyState
+}
+
+type emergencyState struct {
+	At time.Time `json:"at"` // zero when not stopped
+	By int64     `json:"by"`
+}
+
+func NewEmergencyStop(dataDir string) *EmergencyStop {
+	e := &EmergencyStop{path: filepath.Join(dataDir, "panic.json")}
+	e.ctx, e.cancel = context.WithCancel(context.Background())
+	if err := loadJSONFile(e.path, &e.state); err != nil {
+		slog.Warn("failed to load emergency stop state", "path", e.path, "err", err)
+	}
+	if !e.state.At.IsZero() {
+		slog.Warn("emergency stop in effect, every chat is read-only until /resume", "since", e.state.At, "by", e.state.By)
+	}
+	return e
+}
+
+// Context returns the context updates are handled under. It is cancelled
+// by the next /panic.
+func (e *EmergencyStop) Context() context.Context {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.ctx
+}
+
+// Active reports whether the bot is stopped. A nil stop never is.
+func (e *EmergencyStop) Active() bool {
+	if e == nil {
+		return false
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return !e.state.At.IsZero()
+}
+
+// Trip cancels everything running under Context and stops the bot.
+// Updates handled afterwards get a fresh context.
+func (e *EmergencyStop) Trip(by int64, now time.Time) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.cancel()
+	e.ctx, e.cancel = context.WithCancel(context.Background())
+	e.state = emergencyState{At: now, By: by}
+	return saveJSONFile(e.path, e.state)
+}
+
+// Reset lifts the stop, reporting whether there was one.
+func (e *EmergencyStop) Reset() (bool, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.state.At.IsZero() {
+		return false, nil
+	}
+	e.state = emergencyState{}
+	return true, saveJSONFile(e.path, e.state)
+}
+
+// HandlePanic is the emergency stop (admin only).
+func (h *Handlers) HandlePanic(chatID int64) {
+	if err := h.emergency.Trip(chatID, time.Now()); err != nil {
+		slog.Error("failed to save emergency stop", "err", err)
+	}
+	killed := h.gemini.KillBackground()
+	// The webhook's tunnel stays up, or the /resume never arrives.
+	h.tunnels.StopChats()
+	pending := h.approvals.All()
+	for id := range pending {
+		h.approvals.Delete(id)
+		if id != chatID {
+			h.sender.SendPlain(id, h.t(id, "panic.cancelled"))
+		}
+	}
+	slog.Warn("emergency stop", "chat_id", chatID, "background_killed", killed, "approvals_cleared", len(pending))
+	h.events.Emit(Event{Type: EventEmergencyStop, ChatID: chatID})
+	h.sender.SendPlain(chatID, h.t(chatID, "panic.done", killed, len(pending)))
+}
+
+// HandleResume lifts the emergency stop (admin only).
+func (h *Handlers) HandleResume(chatID int64) {
+	stopped, err := h.emergency.Reset()
+	if err != nil {
+		h.sender.SendPlain(chatID, h.t(chatID, "save_failed", err))
+		return
+	}
+	if !stopped {
+		h.sender.SendPlain(chatID, h.t(chatID, "resume.not_stopped"))
+		return
+	}
+	slog.Warn("emergency stop lifted", "chat_id", chatID)
+	key := "resume.done"
+	if h.readOnly.global {
+		key = "resume.read_only"
+	}
+	h.sender.SendPlain(chatID, h.t(chatID, key))
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEmergencyStop(t *testing.T) {
+	dir := t.TempDir()
+	e := NewEmergencyStop(dir)
+	ro := NewReadOnlyStore(dir, false)
+	ro.SetEmergency(e)
+	if e.Active() || ro.Enabled(1) {
+		t.Fatal("stopped before /panic")
+	}
+
+	before := e.Context()
+	if err := e.Trip(42, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if before.Err() == nil {
+		t.Error("/panic did not cancel the running context")
+	}
+	if e.Context().Err() != nil {
+		t.Error("updates after /panic get a cancelled context")
+	}
+	if !ro.Enabled(1) {
+		t.Error("chats not read-only during the stop")
+	}
+
+	if e = NewEmergencyStop(dir); !e.Active() {
+		t.Fatal("stop lifted by a restart")
+	}
+	if stopped, err := e.Reset(); err != nil || !stopped {
+		t.Fatalf("Reset() = %v, %v", stopped, err)
+	}
+	if e.Active() {
+		t.Error("still stopped after /resume")
+	}
+}
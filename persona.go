@@ -0,0 +1,214 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Persona is a named preset: a system prompt and, optionally, the provider
+// and model it works best with.
+type Persona struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Prompt      string `json:"prompt"`
+	Provider    string `json:"provider"`
+	Model       string `json:"model"`
+}
+
+// builtinPersonas are always available; PERSONAS_FILE can add more or
+// override them by name.
+var builtinPersonas = []Persona{
+	{
+		Name:        "devops",
+		Description: "CI/CD, containers, Kubernetes and cloud infrastructure",
+		Prompt: "You are a DevOps engineer working on a remote server. Focus on CI/CD pipelines, containers, Kubernetes and cloud infrastructure. " +
+			"Prefer declarative, reproducible changes (manifests, Terraform, pipeline files) over one-off fixes, check the current state before changing it, " +
+			"and mention how to roll back.",
+	},
+	{
+		Name:        "code-reviewer",
+		Description: "Reviews code for bugs, security and readability",
+		Prompt: "You are a senior code reviewer. Read the code before commenting, point out bugs, security issues, missing error handling and unclear naming, " +
+			"ordered by severity, and suggest concrete fixes. Do not change files unless asked to.",
+	},
+	{
+		Name:        "sysadmin",
+		Description: "Linux administration: services, disks, networking, logs",
+		Prompt: "You are a Linux system administrator. Diagnose with read-only commands first (systemctl, journalctl, df, ss, ps), " +
+			"explain what you find, and keep changes minimal and reversible.",
+	},
+}
+
+// LoadPersonas returns the built-in personas plus any from path (a JSON
+// list of personas), sorted by name. Custom personas override built-ins of
+// the same name.
+func LoadPersonas(path string) ([]Persona, error) {
+	byName := make(map[string]Persona)
+	for _, p := range builtinPersonas {
+		byName[p.Name] = p
+	}
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("read personas file: %w", err)
+		}
+		var custom []Persona
+		if err := json.Unmarshal(data, &custom); err != nil {
+			return nil, fmt.Errorf("parse personas file: %w", err)
+		}
+		for _, p := range custom {
+			if err := p.validate(); err != nil {
+				return nil, err
+			}
+			byName[p.Name] = p
+		}
+	}
+	personas := make([]Persona, 0, len(byName))
+	for _, p := range byName {
+		personas = append(personas, p)
+	}
+	sort.Slice(personas, func(i, j int) bool { return personas[i].Name < personas[j].Name })
+	return personas, nil
+}
+
+func (p Persona) validate() error {
+	switch {
+	case p.Name == "" || strings.ContainsAny(p.Name, " \t\n"):
+		return fmt.Errorf("persona %q: name must be a single word", p.Name)
+	case p.Prompt == "":
+		return fmt.Errorf("persona %s: prompt is required", p.Name)
+	case len(p.Prompt) > maxSystemPrompt:
+		return fmt.Errorf("persona %s: prompt too long (max %d bytes)", p.Name, maxSystemPrompt)
+	}
+	switch p.Provider {
+	case "", "claude", "gemini", "openrouter", "azure", "bedrock":
+	default:
+		return fmt.Errorf("persona %s: unknown provider %q", p.Name, p.Provider)
+	}
+	// Azure and Bedrock answer with their configured deployment or model.
+	if p.Model != "" && (p.Provider == "" || p.Provider == "azure" || p.Provider == "bedrock") {
+		return fmt.Errorf("persona %s: model needs provider claude, gemini or openrouter", p.Name)
+	}
+	return nil
+}
+
+func (h *Handlers) lookupPersona(name string) (Persona, bool) {
+	for _, p := range h.personaList() {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return Persona{}, false
+}
+
+// activePersona returns the persona whose prompt is the chat's system
+// prompt. Deriving it keeps /persona and /system consistent without
+// storing the choice twice.
+func (h *Handlers) activePersona(chatID int64) (Persona, bool) {
+	custom := h.systemPrompts.Get(chatID)
+	if custom == "" {
+		return Persona{}, false
+	}
+	for _, p := range h.personaList() {
+		if p.Prompt == custom {
+			return p, true
+		}
+	}
+	return Persona{}, false
+}
+
+// HandlePersona lists the personas, switches the chat to one, or goes back
+// to the default system prompt.
+// Usage: /persona [name|off]
+func (h *Handlers) HandlePersona(chatID int64, args string) {
+	name := strings.ToLower(strings.TrimSpace(args))
+	if name == "" {
+		active, _ := h.activePersona(chatID)
+		var b strings.Builder
+		b.WriteString(h.t(chatID, "persona.title"))
+		for _, p := range h.personaList() {
+			marker := "  "
+			if p.Name == active.Name {
+				marker = "✅"
+			}
+			fmt.Fprintf(&b, "%s %s", marker, p.Name)
+			if p.Description != "" {
+				b.WriteString(" — " + p.Description)
+			}
+			if p.Provider != "" {
+				b.WriteString(" [" + providerLabel(p.Provider))
+				if p.Model != "" {
+					b.WriteString(" " + p.Model)
+				}
+				b.WriteString("]")
+			}
+			b.WriteString("\n")
+		}
+		b.WriteString(h.t(chatID, "persona.footer"))
+		h.sender.SendPlain(chatID, b.String())
+		return
+	}
+
+	unlock := h.locks.Lock(chatID)
+	defer unlock()
+
+	if name == "off" {
+		if _, err := h.systemPrompts.Reset(chatID); err != nil {
+			h.sender.SendPlain(chatID, h.t(chatID, "system.reset_failed", err))
+			return
+		}
+		h.resetSession(chatID)
+		slog.Info("persona cleared", "chat_id", chatID)
+		h.sender.SendPlain(chatID, h.t(chatID, "persona.off"))
+		return
+	}
+
+	p, ok := h.lookupPersona(name)
+	if !ok {
+		h.sender.SendPlain(chatID, h.t(chatID, "persona.unknown", name))
+		return
+	}
+	if (p.Provider == "azure" || p.Provider == "bedrock") && !h.hasAPIKey(p.Provider) {
+		h.sender.SendPlain(chatID, h.cloudProviderHint(chatID, p.Provider))
+		return
+	}
+	if err := h.systemPrompts.Set(chatID, p.Prompt); err != nil {
+		h.sender.SendPlain(chatID, h.t(chatID, "system.set_failed", err))
+		return
+	}
+	if p.Provider != "" {
+		h.providers.Set(chatID, p.Provider)
+	}
+	if p.Provider == "claude" || p.Model != "" {
+		if err := h.setChatModel(chatID, p.Provider, p.Model); err != nil {
+			slog.Warn("set persona model failed", "chat_id", chatID, "provider", p.Provider, "err", err)
+		}
+	}
+	// Claude only reads the system prompt when a session starts.
+	h.resetSession(chatID)
+
+	provider := h.providers.Get(chatID)
+	model := h.chatModel(chatID, provider)
+	if provider == "claude" {
+		model = h.claudeModelName(chatID, h.claude.Model(chatID))
+	}
+	slog.Info("persona set", "chat_id", chatID, "persona", p.Name, "provider", provider, "model", model)
+	ai := providerLabel(provider)
+	if model != "" {
+		ai += ", " + model
+	}
+	h.sender.SendPlain(chatID, h.t(chatID, "persona.set", p.Name, ai))
+}
+
+// resetSession drops the chat's conversation state so the next message
+// starts a new session. The caller holds the chat lock.
+func (h *Handlers) resetSession(chatID int64) {
+	h.sessions.Delete(chatID)
+	h.geminiSessions.Delete(chatID)
+	h.history.Reset(chatID)
+	h.approvals.Delete(chatID)
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadPersonas(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "personas.json")
+	os.WriteFile(path, []byte(`[
+		{"name": "devops", "prompt": "Custom devops.", "provider": "gemini", "model": "gemini-2.5-pro"},
+		{"name": "dba", "prompt": "You are a DBA."}
+	]`), 0o600)
+	personas, err := LoadPersonas(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := &Handlers{personas: personas}
+	if p, ok := h.lookupPersona("devops"); !ok || p.Prompt != "Custom devops." || p.Model != "gemini-2.5-pro" {
+		t.Errorf("devops = %+v, want the file's override", p)
+	}
+	if _, ok := h.lookupPersona("sysadmin"); !ok {
+		t.Error("built-in sysadmin missing")
+	}
+	if personas[0].Name != "code-reviewer" || personas[1].Name != "dba" {
+		t.Errorf("personas not sorted: %s, %s", personas[0].Name, personas[1].Name)
+	}
+
+	for _, bad := range []string{
+		`[{"name": "x", "prompt": ""}]`,
+		`[{"name": "x y", "prompt": "p"}]`,
+		`[{"name": "x", "prompt": "p", "provider": "watson"}]`,
+		`[{"name": "x", "prompt": "p", "model": "gpt-4o"}]`,
+		`[{"name": "x", "prompt": "p", "provider": "azure", "model": "gpt-4o"}]`,
+	} {
+		os.WriteFile(path, []byte(bad), 0o600)
+		if _, err := LoadPersonas(path); err == nil {
+			t.Errorf("LoadPersonas(%s) succeeded", bad)
+		}
+	}
+}
+
+func TestActivePersona(t *testing.T) {
+	personas, _ := LoadPersonas("")
+	h := &Handlers{personas: personas, systemPrompts: NewSystemPromptStore(t.TempDir())}
+	if _, ok := h.activePersona(1); ok {
+		t.Error("persona active without a custom prompt")
+	}
+	p, _ := h.lookupPersona("sysadmin")
+	h.systemPrompts.Set(1, p.Prompt)
+	if got, ok := h.activePersona(1); !ok || got.Name != "sysadmin" {
+		t.Errorf("activePersona = %q, %v", got.Name, ok)
+	}
+	h.systemPrompts.Set(1, "Something else.")
+	if _, ok := h.activePersona(1); ok {
+		t.Error("persona active after /system set")
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Plain mode is for screen readers and clients that render MarkdownV2
+// poorly: messages are sent without formatting or emojis, and code blocks
+// are framed by spoken-friendly CODE START / CODE END lines.
+
+// toPlainText converts Markdown to unformatted text for chats in plain mode.
+func toPlainText(text string) string {
+	var b strings.Builder
+	for _, part := range splitByCodeBlocks(text) {
+		if part.isCode {
+			b.WriteString("CODE START")
+			if part.lang != "" {
+				b.WriteString(" (" + part.lang + ")")
+			}
+			b.WriteString("\n" + strings.TrimRight(part.content, "\n") + "\nCODE END")
+			continue
+		}
+		b.WriteString(plainInline(part.content))
+	}
+	return stripEmoji(b.String())
+}
+
+// plainInline drops the Markdown markers from text outside code blocks.
+func plainInline(text string) string {
+	text = inlineCodeRe.ReplaceAllString(text, "$1")
+	text = linkRe.ReplaceAllString(text, "$1 ($2)")
+	text = headingRe.ReplaceAllString(text, "$2")
+	text = boldItalicRe.ReplaceAllString(text, "$1")
+	text = boldRe.ReplaceAllString(text, "$1")
+	text = italicStarRe.ReplaceAllString(text, "$1")
+	text = replaceDelimited(underlineRe, text, "__", mUnderline)
+	text = replaceDelimited(italicUnderRe, text, "_", mItalic)
+	text = strikeRe.ReplaceAllString(text, "$1")
+	return strings.NewReplacer(string(mUnderline), "", string(mItalic), "").Replace(text)
+}
+
+// isEmoji reports whether r is an emoji or a joiner/selector that is only
+// used inside emoji sequences.
+func isEmoji(r rune) bool {
+	switch {
+	case r >= 0x1F000 && r <= 0x1FAFF, // pictographs, emoticons, flags...
+		r >= 0x2600 && r <= 0x27BF, // misc symbols and dingbats (✅ ❌ ⚠)
+		r >= 0x2B00 && r <= 0x2BFF, // ⬆ ⭐ ⭕
+		r >= 0x23E9 && r <= 0x23FA, // ⏩ ⏳ ⏹
+		r == 0x231A || r == 0x231B, // ⌚ ⌛
+		r == 0x200D || r == 0xFE0F || r == 0xFE0E || r == 0x20E3:
+		return true
+	}
+	return false
+}
+
+// stripEmoji removes emojis, and the space after one, from text.
+func stripEmoji(text string) string {
+	var b strings.Builder
+	skipSpace := false
+	for _, r := range text {
+		if isEmoji(r) {
+			skipSpace = true
+			continue
+		}
+		if skipSpace && r == ' ' {
+			skipSpace = false
+			continue
+		}
+		skipSpace = false
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// plainKeyboard returns keyboard with emojis removed from the button labels.
+func plainKeyboard(keyboard tgbotapi.InlineKeyboardMarkup) tgbotapi.InlineKeyboardMarkup {
+	rows := make([][]tgbotapi.InlineKeyboardButton, len(keyboard.InlineKeyboard))
+	for i, row := range keyboard.InlineKeyboard {
+		rows[i] = make([]tgbotapi.InlineKeyboardButton, len(row))
+		for j, button := range row {
+			if label := stripEmoji(button.Text); label != "" {
+				button.Text = label
+			}
+			rows[i][j] = button
+		}
+	}
+	return tgbotapi.InlineKeyboardMarkup{InlineKeyboard: rows}
+}
+
+// HandlePlain shows or toggles plain-text mode for the chat.
+// Usage: /plain [on|off]
+func (h *Handlers) HandlePlain(chatID int64, args string) {
+	switch strings.ToLower(strings.TrimSpace(args)) {
+	case "":
+		h.sender.SendPlain(chatID, h.t(chatID, "plain.state", h.onOff(chatID, h.sender.PlainMode(chatID))))
+	case "on":
+		h.sender.SetPlainMode(chatID, true)
+		h.sender.SendPlain(chatID, h.t(chatID, "plain.on"))
+	case "off":
+		h.sender.SetPlainMode(chatID, false)
+		h.sender.SendPlain(chatID, h.t(chatID, "plain.off"))
+	default:
+		h.sendUsage(chatID, "plain")
+	}
+}
@@ -0,0 +1,26 @@
+package main
+
+import "testing"
+
+func TestToPlainText(t *testing.T) {
+	in := "## ✅ **Done**\nRun `make test` then see [the docs](https://example.com).\n```go\nfmt.Println(1)\n```\n_note_: keep max_tool_rounds ⚠️ low"
+	want := "Done\nRun make test then see the docs (https://example.com).\nCODE START (go)\nfmt.Println(1)\nCODE END\nnote: keep max_tool_rounds low"
+	if got := toPlainText(in); got != want {
+		t.Errorf("toPlainText =\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestPlainKeyboard(t *testing.T) {
+	s := NewSender(nil, nil)
+	kb := streamKeyboard("⏹ Stop")
+	if got := s.plainKeyboardFor(1, kb).InlineKeyboard[0][0].Text; got != "⏹ Stop" {
+		t.Errorf("keyboard changed outside plain mode: %q", got)
+	}
+	s.SetPlainMode(1, true)
+	if got := s.plainKeyboardFor(1, kb).InlineKeyboard[0][0].Text; got != "Stop" {
+		t.Errorf("plain label = %q", got)
+	}
+	if kb.InlineKeyboard[0][0].Text != "⏹ Stop" {
+		t.Error("plainKeyboard modified the original keyboard")
+	}
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Plugins are operator-provided executables that add new tags the AI can
+// use alongside <command>, e.g. <jira>PROJ-123</jira>. Each executable in
+// PLUGIN_DIR speaks JSON over stdio:
+//
+//	<exe> describe  → {"name": "jira", "description": "Look up a Jira issue by key"}
+//	<exe> run       ← {"tool": "jira", "input": "PROJ-123", "chat_id": 1, "work_dir": "/work"}
+//	                → {"output": "...", "error": ""}
+//
+// Plugin calls go through the same approval pipeline as shell commands.
+
+// pluginNameRe restricts plugin names to safe tag names.
+var pluginNameRe = regexp.MustCompile(`^[a-z][a-z0-9_-]{0,31}$`)
+
+// reservedTags cannot be claimed by plugins.
+var reservedTags = map[string]bool{"command": true}
+
+// Plugin is one registered subprocess tool.
+type Plugin struct {
+	Name        string
+	Description string
+	Path        string
+	tagRe       *regexp.Regexp
+}
+
+type pluginRequest struct {
+	Tool    string `json:"tool"`
+	Input   string `json:"input"`
+	ChatID  int64  `json:"chat_id"`
+	WorkDir string `json:"work_dir"`
+}
+
+type pluginResponse struct {
+	Output string `json:"output"`
+	Error  string `json:"error"`
+}
+
+// PluginRegistry holds the loaded plugins, keyed by tag name. It is built
+// once at startup and read-only afterwards.
+type PluginRegistry struct {
+	plugins map[string]*Plugin
+	timeout time.Duration
+}
+
+// LoadPlugins registers every executable in dir. An empty dir yields an
+// empty registry. Plugins that fail to describe themselves are skipped.
+func LoadPlugins(dir string, timeout time.Duration) *PluginRegistry {
+	r := &PluginRegistry{plugins: make(map[string]*Plugin), timeout: timeout}
+	if dir == "" {
+		return r
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		slog.Warn("read plugin dir failed", "dir", dir, "err", err)
+		return r
+	}
+	for _, e := range entries {
+		info, err := e.Info()
+		if err != nil || !info.Mode().IsRegular() || info.Mode()&0o111 == 0 {
+			continue
+		}
+		p, err := describePlugin(filepath.Join(dir, e.Name()))
+		if err != nil {
+			slog.Warn("skipping plugin", "file", e.Name(), "err", err)
+			continue
+		}
+		if _, dup := r.plugins[p.Name]; dup {
+			slog.Warn("skipping plugin: tag already registered", "file", e.Name(), "tag", p.Name)
+			continue
+		}
+		r.plugins[p.Name] = p
+		slog.Info("plugin registered", "tag", p.Name, "path", p.Path)
+	}
+	return r
+}
+
+func describePlugin(path string) (*Plugin, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, path, "describe").Output()
+	if err != nil {
+		return nil, fmt.Errorf("describe: %w", err)
+	}
+	var desc struct {
+		Name        string `json:"name"`
+		Description string `json:"description"`
+	}
+	if err := json.Unmarshal(out, &desc); err != nil {
+		return nil, fmt.Errorf("describe: invalid JSON: %w", err)
+	}
+	if !pluginNameRe.MatchString(desc.Name) || reservedTags[desc.Name] {
+		return nil, fmt.Errorf("invalid tag name %q", desc.Name)
+	}
+	return &Plugin{
+		Name:        desc.Name,
+		Description: desc.Description,
+		Path:        path,
+		tagRe:       regexp.MustCompile(`(?m)^[ \t]*<` + desc.Name + `>([\s\S]*?)</` + desc.Name + `>`),
+	}, nil
+}
+
+// names returns registered plugin names, sorted.
+func (r *PluginRegistry) names() []string {
+	names := make([]string, 0, len(r.plugins))
+	for name := range r.plugins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Prompt describes the registered plugin tags for the system prompt.
+func (r *PluginRegistry) Prompt() string {
+	if len(r.plugins) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("\n\nAdditional tools are available. Invoke one by putting its tag on its own line, like a command, e.g. <name>input</name>. " +
+		"Tool calls need the same user approval as commands. Available tools:\n")
+	for _, name := range r.names() {
+		fmt.Fprintf(&b, "- <%s>: %s\n", name, r.plugins[name].Description)
+	}
+	return b.String()
+}
+
+// Parse extracts plugin calls from text. Calls are returned in their tag
+// form (e.g. "<jira>PROJ-1</jira>") so they can travel through the approval
+// pipeline as ordinary command strings; tags in the text become inline code.
+func (r *PluginRegistry) Parse(text string) (cleanText string, calls []string) {
+	cleanText = text
+	for _, name := range r.names() {
+		p := r.plugins[name]
+		for _, m := range p.tagRe.FindAllStringSubmatch(cleanText, -1) {
+			if input := strings.TrimSpace(m[1]); input != "" {
+				calls = append(calls, "<"+name+">"+input+"</"+name+">")
+			}
+		}
+		cleanText = p.tagRe.ReplaceAllStringFunc(cleanText, func(match string) string {
+			sub := p.tagRe.FindStringSubmatch(match)
+			return "`<" + name + "> " + strings.TrimSpace(sub[1]) + "`"
+		})
+	}
+	return strings.TrimSpace(cleanText), calls
+}
+
+// Match reports whether call is a plugin invocation produced by Parse.
+func (r *PluginRegistry) Match(call string) (*Plugin, string, bool) {
+	if !strings.HasPrefix(call, "<") {
+		return nil, "", false
+	}
+	end := strings.IndexByte(call, '>')
+	if end < 0 {
+		return nil, "", false
+	}
+	name := call[1:end]
+	p, ok := r.plugins[name]
+	if !ok || !strings.HasSuffix(call, "</"+name+">") {
+		return nil, "", false
+	}
+	return p, call[end+1 : len(call)-len(name)-3], true
+}
+
+// Run invokes a plugin with the given input.
+func (r *PluginRegistry) Run(ctx context.Context, p *Plugin, chatID int64, workDir, input string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	req, err := json.Marshal(pluginRequest{Tool: p.Name, Input: input, ChatID: chatID, WorkDir: workDir})
+	if err != nil {
+		return "", err
+	}
+	cmd := exec.CommandContext(ctx, p.Path, "run")
+	ownGroup(cmd)
+	cmd.Dir = workDir
+	cmd.Stdin = bytes.NewReader(req)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return strings.TrimSpace(stdout.String() + "\n" + stderr.String()), fmt.Errorf("plugin %s: %w", p.Name, err)
+	}
+
+	var resp pluginResponse
+	if err := json.Unmarshal(stdout.Bytes(), &resp); err != nil {
+		// Tolerate plugins that just print text.
+		return strings.TrimSpace(stdout.String()), nil
+	}
+	if resp.Error != "" {
+		return resp.Output, fmt.Errorf("plugin %s: %s", p.Name, resp.Error)
+	}
+	return resp.Output, nil
+}
+
+// parseCalls extracts shell commands and plugin calls from an AI response.
+func (h *Handlers) parseCalls(text string) (string, []string) {
+	cleanText, commands := ParseCommands(text)
+	cleanText, calls := h.plugins.Parse(cleanText)
+	return cleanText, append(commands, calls...)
+}
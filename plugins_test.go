@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPluginParseAndMatch(t *testing.T) {
+	p, err := newTestPlugin(t, "jira")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &PluginRegistry{plugins: map[string]*Plugin{"jira": p}, timeout: time.Second}
+
+	clean, calls := r.Parse("Let me check.\n<jira>PROJ-1</jira>\nand <jira>inline</jira>")
+	if len(calls) != 1 || calls[0] != "<jira>PROJ-1</jira>" {
+		t.Fatalf("calls = %q, want one <jira>PROJ-1</jira>", calls)
+	}
+	if want := "Let me check.\n`<jira> PROJ-1`\nand <jira>inline</jira>"; clean != want {
+		t.Errorf("clean = %q, want %q", clean, want)
+	}
+
+	got, input, ok := r.Match(calls[0])
+	if !ok || got != p || input != "PROJ-1" {
+		t.Errorf("Match = %v, %q, %v", got, input, ok)
+	}
+	for _, call := range []string{"ls -la", "<grafana>x</grafana>", "<jira>x</grafana>"} {
+		if _, _, ok := r.Match(call); ok {
+			t.Errorf("Match(%q) = true, want false", call)
+		}
+	}
+}
+
+func TestPluginRun(t *testing.T) {
+	p, err := newTestPlugin(t, "echo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &PluginRegistry{plugins: map[string]*Plugin{"echo": p}, timeout: 5 * time.Second}
+	out, err := r.Run(context.Background(), p, 1, t.TempDir(), "hello")
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if out != "got hello" {
+		t.Errorf("output = %q, want %q", out, "got hello")
+	}
+}
+
+// newTestPlugin writes a shell-script plugin and loads it via describe.
+func newTestPlugin(t *testing.T, name string) (*Plugin, error) {
+	t.Helper()
+	script := `#!/bin/sh
+if [ "$1" = describe ]; then
+  echo '{"name":"` + name + `","description":"test plugin"}'
+  exit 0
+fi
+input=$(sed -n 's/.*"input":"\([^"]*\)".*/\1/p')
+echo "{\"output\":\"got $input\"}"
+`
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(script), 0o755); err != nil {
+		return nil, err
+	}
+	return describePlugin(path)
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Post-processors rewrite a chat's AI replies before they are sent, after
+// the guardrails, e.g. for chats whose messages feed other automations:
+// regex replacements, a signature line, or dropping the reasoning some
+// models print before their answer. They are kept in the chat's settings
+// and applied in the order they were added.
+
+// Post-processor kinds.
+const (
+	PostReplace        = "replace"         // Pattern -> Replacement, regexp syntax
+	PostSignature      = "signature"       // append Text on its own line
+	PostStripReasoning = "strip-reasoning" // drop <think>-style blocks
+)
+
+// maxPostProcessors caps the steps one chat may define.
+const maxPostProcessors = 20
+
+// PostProcessor is one step applied to a chat's AI replies.
+type PostProcessor struct {
+	Kind        string `json:"kind"`
+	Pattern     string `json:"pattern,omitempty"`
+	Replacement string `json:"replacement,omitempty"`
+	Text        string `json:"text,omitempty"`
+}
+
+// reasoningBlock matches the reasoning sections models wrap in tags, up to
+// the end of the text when the closing tag is missing.
+var reasoningBlock = regexp.MustCompile(`(?is)<(think|thinking|reasoning)>.*?(</(think|thinking|reasoning)>|$)`)
+
+// String describes the step for /postprocess.
+func (p PostProcessor) String() string {
+	switch p.Kind {
+	case PostReplace:
+		return fmt.Sprintf("replace %s => %s", p.Pattern, p.Replacement)
+	case PostSignature:
+		return "signature " + p.Text
+	}
+	return p.Kind
+}
+
+// Apply runs the step on text.
+func (p PostProcessor) Apply(text string) string {
+	switch p.Kind {
+	case PostReplace:
+		re, err := regexp.Compile(p.Pattern)
+		if err != nil {
+			return text // validated when added
+		}
+		return re.ReplaceAllString(text, p.Replacement)
+	case PostSignature:
+		return strings.TrimRight(text, "\n") + "\n\n" + p.Text
+	case PostStripReasoning:
+		return strings.TrimSpace(reasoningBlock.ReplaceAllString(text, ""))
+	}
+	return text
+}
+
+// postProcess applies the chat's post-processors to an AI reply.
+func (h *Handlers) postProcess(chatID int64, text string) string {
+	for _, p := range h.settings.Get(chatID).PostProcess {
+		text = p.Apply(text)
+	}
+	return text
+}
+
+// parsePostProcessor reads the step defined by a /postprocess subcommand
+// and its raw arguments.
+func parsePostProcessor(kind, args string) (PostProcessor, error) {
+	switch kind {
+	case PostReplace:
+		pattern, repl, ok := strings.Cut(args, " =>")
+		pattern = strings.TrimSpace(pattern)
+		if !ok || pattern == "" {
+			return PostProcessor{}, fmt.Errorf("usage: /postprocess replace <regex> => <replacement>")
+		}
+		if _, err := regexp.Compile(pattern); err != nil {
+			return PostProcessor{}, fmt.Errorf("invalid regex: %v", err)
+		}
+		return PostProcessor{Kind: kind, Pattern: pattern, Replacement: strings.TrimPrefix(repl, " ")}, nil
+	case PostSignature:
+		if args == "" {
+			return PostProcessor{}, fmt.Errorf("usage: /postprocess signature <text>")
+		}
+		return PostProcessor{Kind: kind, Text: args}, nil
+	case PostStripReasoning:
+		return PostProcessor{Kind: kind}, nil
+	}
+	return PostProcessor{}, fmt.Errorf("unknown post-processor %q", kind)
+}
+
+// HandlePostProcess lists, adds or removes the chat's reply post-processors.
+// Usage: /postprocess [list] | replace <regex> => <replacement> |
+// signature <text> | strip-reasoning | remove <n> | clear
+func (h *Handlers) HandlePostProcess(chatID int64, args string) {
+	sub, rest, _ := strings.Cut(strings.TrimSpace(args), " ")
+	rest = strings.TrimSpace(rest)
+	switch strings.ToLower(sub) {
+	case "", "list":
+		steps := h.settings.Get(chatID).PostProcess
+		if len(steps) == 0 {
+			h.sender.SendPlain(chatID, h.t(chatID, "postprocess.none"))
+			return
+		}
+		var b strings.Builder
+		b.WriteString(h.t(chatID, "postprocess.list"))
+		for i, p := range steps {
+			fmt.Fprintf(&b, "  %d. %s\n", i+1, p)
+		}
+		h.sender.SendPlain(chatID, strings.TrimRight(b.String(), "\n"))
+
+	case PostReplace, PostSignature, PostStripReasoning:
+		p, err := parsePostProcessor(strings.ToLower(sub), rest)
+		if err != nil {
+			h.sender.SendPlain(chatID, err.Error())
+			return
+		}
+		err = h.settings.Update(chatID, func(s *ChatSettings) error {
+			if len(s.PostProcess) >= maxPostProcessors {
+				return fmt.Errorf("at most %d post-processors", maxPostProcessors)
+			}
+			s.PostProcess = append(slices.Clip(s.PostProcess), p)
+			return nil
+		})
+		if err != nil {
+			h.sender.SendPlain(chatID, h.t(chatID, "postprocess.add_failed", err))
+			return
+		}
+		slog.Info("post-processor added", "chat_id", chatID, "kind", p.Kind)
+		h.sender.SendPlain(chatID, h.t(chatID, "postprocess.added", p))
+
+	case "remove":
+		n, err := strconv.Atoi(rest)
+		if err != nil {
+			h.sender.SendPlain(chatID, h.t(chatID, "postprocess.remove_usage"))
+			return
+		}
+		var removed PostProcessor
+		err = h.settings.Update(chatID, func(s *ChatSettings) error {
+			if n < 1 || n > len(s.PostProcess) {
+				return fmt.Errorf("no post-processor %d", n)
+			}
+			removed = s.PostProcess[n-1]
+			s.PostProcess = slices.Concat(s.PostProcess[:n-1], s.PostProcess[n:])
+			if len(s.PostProcess) == 0 {
+				s.PostProcess = nil
+			}
+			return nil
+		})
+		if err != nil {
+			h.sender.SendPlain(chatID, h.t(chatID, "postprocess.remove_failed", err))
+			return
+		}
+		slog.Info("post-processor removed", "chat_id", chatID, "kind", removed.Kind)
+		h.sender.SendPlain(chatID, h.t(chatID, "postprocess.removed", removed))
+
+	case "clear":
+		err := h.settings.Update(chatID, func(s *ChatSettings) error {
+			s.PostProcess = nil
+			return nil
+		})
+		if err != nil {
+			h.sender.SendPlain(chatID, h.t(chatID, "error", err))
+			return
+		}
+		slog.Info("post-processors cleared", "chat_id", chatID)
+		h.sender.SendPlain(chatID, h.t(chatID, "postprocess.cleared"))
+
+	default:
+		h.sendUsage(chatID, "postprocess")
+	}
+}
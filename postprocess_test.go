@@ -0,0 +1,75 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestPostProcessorApply(t *testing.T) {
+	tests := []struct {
+		p    PostProcessor
+		in   string
+		want string
+	}{
+		{PostProcessor{Kind: PostReplace, Pattern: `(?i)\bprod\b`, Replacement: "production"}, "Deploy to PROD now", "Deploy to production now"},
+		{PostProcessor{Kind: PostReplace, Pattern: `ticket (\d+)`, Replacement: "#$1"}, "see ticket 42", "see #42"},
+		{PostProcessor{Kind: PostSignature, Text: "-- bot"}, "Done.\n", "Done.\n\n-- bot"},
+		{PostProcessor{Kind: PostStripReasoning}, "<think>\nfirst, list files\n</think>\nHere they are.", "Here they are."},
+		{PostProcessor{Kind: PostStripReasoning}, "Answer.\n<Reasoning>cut off mid-", "Answer."},
+		{PostProcessor{Kind: PostStripReasoning}, "No reasoning here.", "No reasoning here."},
+	}
+	for _, tt := range tests {
+		if got := tt.p.Apply(tt.in); got != tt.want {
+			t.Errorf("%s on %q = %q, want %q", tt.p, tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParsePostProcessor(t *testing.T) {
+	p, err := parsePostProcessor(PostReplace, `\s+$ => `)
+	if err != nil || p.Pattern != `\s+$` || p.Replacement != "" {
+		t.Errorf("replace with empty replacement = %+v, %v", p, err)
+	}
+	p, err = parsePostProcessor(PostReplace, `a => b => c`)
+	if err != nil || p.Pattern != "a" || p.Replacement != "b => c" {
+		t.Errorf("replace = %+v, %v", p, err)
+	}
+	for kind, args := range map[string]string{
+		PostReplace:   "no arrow",
+		"uppercase":   "",
+		PostSignature: "",
+	} {
+		if _, err := parsePostProcessor(kind, args); err == nil {
+			t.Errorf("parsePostProcessor(%q, %q) succeeded", kind, args)
+		}
+	}
+	if _, err := parsePostProcessor(PostReplace, "([a-z] => x"); err == nil {
+		t.Error("invalid regex accepted")
+	}
+}
+
+func TestPostProcessChain(t *testing.T) {
+	dir := t.TempDir()
+	h := &Handlers{settings: NewSettingsStore(dir)}
+	h.settings.Update(1, func(s *ChatSettings) error {
+		s.PostProcess = []PostProcessor{
+			{Kind: PostStripReasoning},
+			{Kind: PostReplace, Pattern: "secret-host", Replacement: "[host]"},
+			{Kind: PostSignature, Text: "-- ops bot"},
+		}
+		return nil
+	})
+
+	h.settings = NewSettingsStore(dir)
+	got := h.postProcess(1, "<thinking>check secret-host</thinking>secret-host is up.")
+	if want := "[host] is up.\n\n-- ops bot"; got != want {
+		t.Errorf("postProcess = %q, want %q", got, want)
+	}
+	if got := h.postProcess(2, "untouched"); got != "untouched" {
+		t.Errorf("other chat = %q", got)
+	}
+
+	h.settings.Update(1, func(s *ChatSettings) error { s.PostProcess = nil; return nil })
+	if len(h.settings.All()) != 0 {
+		t.Error("chat without post-processors left in the store")
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"log/slog"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// Commands run through sh, and whatever they start (pipelines, "&" jobs,
+// the claude CLI's own tools) outlives a plain kill of the shell. So every
+// child the bot starts gets a process group of its own, and the whole
+// group is killed on timeout or cancel. Processes orphaned anyway are
+// re-parented to the bot, which reaps them once they exit.
+
+// groupWaitDelay bounds how long Wait waits for a killed command's
+// output pipes to close.
+const groupWaitDelay = 5 * time.Second
+
+// ownGroup makes cmd start in a new process group and, for a command made
+// with exec.CommandContext, kills the whole group when ctx is done.
+func ownGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+	if cmd.Cancel != nil {
+		killGroupOnCancel(cmd)
+	}
+}
+
+// killGroupOnCancel makes cancelling cmd's context kill its process group
+// rather than only cmd. cmd must lead its group: started with ownGroup, or
+// as a session leader (a PTY).
+func killGroupOnCancel(cmd *exec.Cmd) {
+	cmd.Cancel = func() error { return killGroup(cmd.Process) }
+	cmd.WaitDelay = groupWaitDelay
+}
+
+// killGroup kills the process group led by p.
+func killGroup(p *os.Process) error {
+	if p == nil {
+		return nil
+	}
+	if err := syscall.Kill(-p.Pid, syscall.SIGKILL); err != nil {
+		// Not a group leader after all: kill what we can.
+		return p.Kill()
+	}
+	return nil
+}
+
+// prSetChildSubreaper is prctl's PR_SET_CHILD_SUBREAPER.
+const prSetChildSubreaper = 36
+
+// reapInterval is how often orphans are looked for without a SIGCHLD.
+const reapInterval = time.Minute
+
+// StartReaper makes the bot the subreaper of its descendants and reaps the
+// orphans re-parented to it as they exit, so they do not fill the PID
+// table with zombies (the bot is often PID 1 in its container). It only
+// reaps processes that are not the leader of their group: the bot waits
+// for its direct children itself, and those always lead a group of their
+// own or belong to the bot's.
+func StartReaper() {
+	if _, _, errno := syscall.RawSyscall(syscall.SYS_PRCTL, prSetChildSubreaper, 1, 0); errno != 0 {
+		slog.Warn("could not become child subreaper", "err", errno)
+	}
+	sigchld := make(chan os.Signal, 1)
+	signal.Notify(sigchld, syscall.SIGCHLD)
+	go func() {
+		ticker := time.NewTicker(reapInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-sigchld:
+			case <-ticker.C:
+			}
+			if n := reapOrphans(); n > 0 {
+				slog.Debug("reaped orphaned processes", "count", n)
+			}
+		}
+	}()
+}
+
+// reapOrphans waits for the bot's exited children that lead no process
+// group and the bot did not start itself, and returns how many it reaped.
+func reapOrphans() int {
+	self := os.Getpid()
+	botGroup := syscall.Getpgrp()
+	dirs, err := filepath.Glob("/proc/[0-9]*")
+	if err != nil {
+		return 0
+	}
+	reaped := 0
+	for _, dir := range dirs {
+		pid, err := strconv.Atoi(filepath.Base(dir))
+		if err != nil {
+			continue
+		}
+		stat, err := os.ReadFile(filepath.Join(dir, "stat"))
+		if err != nil {
+			continue
+		}
+		state, ppid, pgrp, ok := parseProcStat(stat)
+		if !ok || state != 'Z' || ppid != self || pgrp == pid || pgrp == botGroup {
+			continue
+		}
+		var ws syscall.WaitStatus
+		if got, err := syscall.Wait4(pid, &ws, syscall.WNOHANG, nil); err == nil && got == pid {
+			reaped++
+		}
+	}
+	return reaped
+}
+
+// parseProcStat reads the state, parent PID and process group from a
+// /proc/<pid>/stat line: "pid (comm) state ppid pgrp ...". comm may
+// contain spaces and parentheses, so fields are counted from the last ')'.
+func parseProcStat(stat []byte) (state byte, ppid, pgrp int, ok bool) {
+	i := bytes.LastIndexByte(stat, ')')
+	if i < 0 {
+		return 0, 0, 0, false
+	}
+	fields := bytes.Fields(stat[i+1:])
+	if len(fields) < 3 || len(fields[0]) != 1 {
+		return 0, 0, 0, false
+	}
+	ppid, err1 := strconv.Atoi(string(fields[1]))
+	pgrp, err2 := strconv.Atoi(string(fields[2]))
+	if err1 != nil || err2 != nil {
+		return 0, 0, 0, false
+	}
+	return fields[0][0], ppid, pgrp, true
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os/exec"
+	"testing"
+	"time"
+)
+
+func TestParseProcStat(t *testing.T) {
+	state, ppid, pgrp, ok := parseProcStat([]byte("4242 (sh -c (x)) Z 1 4200 4200 0 -1 4194560 0 0"))
+	if !ok || state != 'Z' || ppid != 1 || pgrp != 4200 {
+		t.Errorf("got %c %d %d %v, want Z 1 4200 true", state, ppid, pgrp, ok)
+	}
+	for _, bad := range []string{"", "4242 sh Z 1 4200", "4242 (sh) Z 1", "4242 (sh) Z x 4200"} {
+		if _, _, _, ok := parseProcStat([]byte(bad)); ok {
+			t.Errorf("parseProcStat(%q) ok, want failure", bad)
+		}
+	}
+}
+
+func TestOwnGroupKillsChildrenOnCancel(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	// The backgrounded sleep holds the output pipe open; killing only sh
+	// would leave Run waiting for it.
+	cmd := exec.CommandContext(ctx, "sh", "-c", "sleep 30 & sleep 30")
+	ownGroup(cmd)
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	start := time.Now()
+	if err := cmd.Run(); err == nil {
+		t.Fatal("Run succeeded, want it killed")
+	}
+	if elapsed := time.Since(start); elapsed > groupWaitDelay {
+		t.Errorf("Run took %v, want the whole group killed at the deadline", elapsed)
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/pprof"
+)
+
+// ProfilingServer exposes the net/http/pprof endpoints under /debug/pprof/
+// on METRICS_ADDR, for profiling the bot while it handles real traffic.
+// It is kept off the health address so probes can be public while
+// profiles stay on an internal interface.
+type ProfilingServer struct {
+	addr string
+}
+
+func NewProfilingServer(addr string) *ProfilingServer {
+	return &ProfilingServer{addr: addr}
+}
+
+// ListenAndServe blocks serving profiles on the configured address.
+func (s *ProfilingServer) ListenAndServe() error {
+	slog.Info("profiling server listening", "addr", s.addr)
+	return http.ListenAndServe(s.addr, s.mux())
+}
+
+func (s *ProfilingServer) mux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	return mux
+}
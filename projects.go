@@ -0,0 +1,202 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// defaultProject is the implicit project that maps to WORK_DIR.
+const defaultProject = "default"
+
+// chatProjects holds one chat's named workspaces and the active one.
+type chatProjects struct {
+	Active   string            `json:"active"`
+	Projects map[string]string `json:"projects"` // name → absolute path
+}
+
+// ProjectStore manages named working directories per chat, persisted to
+// DATA_DIR. Every chat implicitly has a "default" project at its workspace
+// directory (see Workspaces).
+type ProjectStore struct {
+	mu    sync.RWMutex
+	path  string
+	ws    *Workspaces
+	chats map[int64]*chatProjects
+}
+
+func NewProjectStore(dataDir string, ws *Workspaces) *ProjectStore {
+	s := &ProjectStore{
+		path:  filepath.Join(dataDir, "projects.json"),
+		ws:    ws,
+		chats: make(map[int64]*chatProjects),
+	}
+	if err := loadJSONFile(s.path, &s.chats); err != nil {
+		slog.Warn("failed to load projects", "path", s.path, "err", err)
+	}
+	return s
+}
+
+// Active returns the name and directory of the chat's active project.
+func (s *ProjectStore) Active(chatID int64) (name, dir string) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	cp := s.chats[chatID]
+	if cp == nil || cp.Active == "" || cp.Active == defaultProject {
+		return defaultProject, s.ws.Dir(chatID)
+	}
+	if dir, ok := cp.Projects[cp.Active]; ok {
+		return cp.Active, dir
+	}
+	return defaultProject, s.ws.Dir(chatID)
+}
+
+// List returns the chat's projects (including default) as name → dir.
+func (s *ProjectStore) List(chatID int64) map[string]string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := map[string]string{defaultProject: s.ws.Dir(chatID)}
+	if cp := s.chats[chatID]; cp != nil {
+		for name, dir := range cp.Projects {
+			out[name] = dir
+		}
+	}
+	return out
+}
+
+// Add registers a project. Relative paths are resolved against WORK_DIR;
+// other chats' workspaces, and directories holding them all such as
+// WORK_DIR itself, are refused.
+func (s *ProjectStore) Add(chatID int64, name, dir string) (string, error) {
+	if name == defaultProject {
+		return "", fmt.Errorf("%q is reserved", defaultProject)
+	}
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(s.ws.root, dir)
+	}
+	dir = filepath.Clean(dir)
+	if s.ws.Foreign(chatID, dir) || s.ws.Encloses(dir) {
+		return "", fmt.Errorf("%s is %w", dir, ErrOutsideWorkspace)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cp := s.chats[chatID]
+	if cp == nil {
+		cp = &chatProjects{}
+		s.chats[chatID] = cp
+	}
+	if cp.Projects == nil {
+		cp.Projects = make(map[string]string)
+	}
+	cp.Projects[name] = dir
+	return dir, s.saveLocked()
+}
+
+// Switch makes name the chat's active project.
+func (s *ProjectStore) Switch(chatID int64, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cp := s.chats[chatID]
+	if name != defaultProject {
+		if cp == nil || cp.Projects[name] == "" {
+			return fmt.Errorf("unknown project %q", name)
+		}
+	}
+	if cp == nil {
+		cp = &chatProjects{}
+		s.chats[chatID] = cp
+	}
+	cp.Active = name
+	return s.saveLocked()
+}
+
+func (s *ProjectStore) saveLocked() error {
+	return saveJSONFile(s.path, s.chats)
+}
+
+// projectDir returns the working directory of the chat's active project.
+func (h *Handlers) projectDir(chatID int64) string {
+	_, dir := h.projects.Active(chatID)
+	return dir
+}
+
+// HandleProject manages per-chat project workspaces.
+// Usage: /project [list] | /project switch <name> | /project add <name> <path>
+func (h *Handlers) HandleProject(chatID int64, args string) {
+	a, ok := h.parseCommandArgs(chatID, "project", args)
+	if !ok {
+		return
+	}
+	sub := strings.ToLower(a.Arg(0))
+	if sub == "" {
+		sub = "list"
+	}
+
+	switch sub {
+	case "list":
+		active, _ := h.projects.Active(chatID)
+		projects := h.projects.List(chatID)
+		names := make([]string, 0, len(projects))
+		for name := range projects {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		var b strings.Builder
+		b.WriteString(h.t(chatID, "project.list") + "\n")
+		for _, name := range names {
+			marker := "  "
+			if name == active {
+				marker = "✅"
+			}
+			fmt.Fprintf(&b, "%s %s — %s\n", marker, name, projects[name])
+		}
+		b.WriteString("\n" + h.t(chatID, "project.list_footer"))
+		h.sender.SendPlain(chatID, b.String())
+
+	case "add":
+		if a.Len() < 3 {
+			h.sender.SendPlain(chatID, h.t(chatID, "project.add_usage"))
+			return
+		}
+		name := a.Arg(1)
+		dir, err := h.projects.Add(chatID, name, a.Rest(2))
+		if err != nil {
+			h.sender.SendPlain(chatID, h.t(chatID, "project.add_failed", err))
+			return
+		}
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			slog.Warn("create project dir failed", "chat_id", chatID, "dir", dir, "err", err)
+		}
+		slog.Info("project added", "chat_id", chatID, "project", name, "dir", dir)
+		h.sender.SendPlain(chatID, h.t(chatID, "project.added", name, dir, name))
+
+	case "switch":
+		if a.Len() < 2 {
+			h.sender.SendPlain(chatID, h.t(chatID, "project.switch_usage"))
+			return
+		}
+		unlock := h.locks.Lock(chatID)
+		defer unlock()
+		if err := h.projects.Switch(chatID, a.Arg(1)); err != nil {
+			h.sender.SendPlain(chatID, h.t(chatID, "project.switch_failed", err))
+			return
+		}
+		// Claude sessions are tied to their working directory, so start fresh.
+		h.sessions.Delete(chatID)
+		h.geminiSessions.Delete(chatID)
+		h.history.Reset(chatID)
+		h.approvals.Delete(chatID)
+		h.gemini.ResetCwd(chatID)
+		name, dir := h.projects.Active(chatID)
+		slog.Info("switched project", "chat_id", chatID, "project", name, "dir", dir)
+		h.sender.SendPlain(chatID, h.t(chatID, "project.switched", name, dir))
+
+	default:
+		h.sendUsage(chatID, "project")
+	}
+}
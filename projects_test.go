@@ -0,0 +1,91 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestProjectStoreAdd(t *testing.T) {
+	root := t.TempDir()
+	s := NewProjectStore(t.TempDir(), NewWorkspaces(root, true, 0))
+	for _, tc := range []struct {
+		name, dir string
+		want      string // "" when refused
+	}{
+		{"api", "api", filepath.Join(root, "api")},
+		{"mine", filepath.Join(root, "1", "app"), filepath.Join(root, "1", "app")},
+		{"etc", "/srv/app/", "/srv/app"},
+		{"default", "other", ""},
+		{"theirs", filepath.Join(root, "2"), ""},
+		{"theirs-rel", "2/app", ""},
+		{"escape", "1/../2", ""},
+		{"root", root, ""},
+		{"dot", ".", ""},
+		{"parent", filepath.Dir(root), ""},
+		{"slash", "/", ""},
+	} {
+		dir, err := s.Add(1, tc.name, tc.dir)
+		if tc.want == "" {
+			if err == nil {
+				t.Errorf("Add(%q, %q) = %q, want refused", tc.name, tc.dir, dir)
+			}
+			continue
+		}
+		if err != nil || dir != tc.want {
+			t.Errorf("Add(%q, %q) = %q, %v, want %q", tc.name, tc.dir, dir, err, tc.want)
+		}
+	}
+	if _, err := s.Add(1, "theirs", filepath.Join(root, "2")); !errors.Is(err, ErrOutsideWorkspace) {
+		t.Errorf("foreign dir error = %v, want ErrOutsideWorkspace", err)
+	}
+
+	// With a shared WORK_DIR there is nothing to keep apart.
+	shared := NewProjectStore(t.TempDir(), NewWorkspaces(root, false, 0))
+	if _, err := shared.Add(1, "root", root); err != nil {
+		t.Errorf("shared WORK_DIR refused: %v", err)
+	}
+}
+
+func TestProjectStoreSwitch(t *testing.T) {
+	root, data := t.TempDir(), t.TempDir()
+	ws := NewWorkspaces(root, true, 0)
+	s := NewProjectStore(data, ws)
+	if name, dir := s.Active(1); name != defaultProject || dir != ws.Dir(1) {
+		t.Errorf("initial Active = %q, %q", name, dir)
+	}
+	if err := s.Switch(1, "api"); err == nil {
+		t.Error("switched to an unknown project")
+	}
+	api, _ := s.Add(1, "api", "api")
+	for _, tc := range []struct {
+		chat      int64
+		project   string
+		wantName  string
+		wantDir   string
+		wantError bool
+	}{
+		{1, "api", "api", api, false},
+		{2, "api", defaultProject, ws.Dir(2), true}, // projects are per chat
+		{1, "nope", "api", api, true},
+		{1, defaultProject, defaultProject, ws.Dir(1), false},
+		{1, "api", "api", api, false},
+	} {
+		err := s.Switch(tc.chat, tc.project)
+		if (err != nil) != tc.wantError {
+			t.Errorf("Switch(%d, %q) = %v", tc.chat, tc.project, err)
+		}
+		if name, dir := s.Active(tc.chat); name != tc.wantName || dir != tc.wantDir {
+			t.Errorf("after Switch(%d, %q): Active = %q, %q, want %q, %q", tc.chat, tc.project, name, dir, tc.wantName, tc.wantDir)
+		}
+	}
+
+	// The active project and list survive a restart.
+	s = NewProjectStore(data, ws)
+	if name, dir := s.Active(1); name != "api" || dir != api {
+		t.Errorf("after restart: Active = %q, %q", name, dir)
+	}
+	if list := s.List(1); len(list) != 2 || list["api"] != api || list[defaultProject] != ws.Dir(1) {
+		t.Errorf("List = %v", list)
+	}
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ErrReadOnly is returned for a command the chat may not run because it
+// is in read-only mode.
+var ErrReadOnly = errors.New("read-only mode: commands are not executed")
+
+// ReadOnlyStore holds which chats are read-only: the AI converses and
+// suggests commands, but nothing is executed, neither approved commands
+// nor Claude's own tools. READ_ONLY=true makes every chat read-only;
+// /readonly does it for one chat, persisted to DATA_DIR.
+type ReadOnlyStore struct {
+	global    bool                     // READ_ONLY
+	emergency *EmergencyStop           // /panic makes every chat read-only, may be nil
+	parent    func(chatID int64) int64 // the group a topic belongs to, may be nil
+
+	mu    sync.RWMutex
+	path  string
+	chats map[int64]bool
+	held  map[int64]int // chats read-only for a turn, see Hold
+}
+
+func NewReadOnlyStore(dataDir string, global bool) *ReadOnlyStore {
+	s := &ReadOnlyStore{
+		global: global,
+		path:   filepath.Join(dataDir, "read_only.json"),
+		chats:  make(map[int64]bool),
+		held:   make(map[int64]int),
+	}
+	if err := loadJSONFile(s.path, &s.chats); err != nil {
+		slog.Warn("failed to load read-only settings", "path", s.path, "err", err)
+	}
+	return s
+}
+
+// SetEmergency makes every chat read-only while the emergency stop is on.
+func (s *ReadOnlyStore) SetEmergency(e *EmergencyStop) {
+	s.emergency = e
+}
+
+// SetParentChat registers a function mapping a forum topic or member
+// conversation to its group, whose read-only mode then covers them.
+func (s *ReadOnlyStore) SetParentChat(parent func(chatID int64) int64) {
+	s.parent = parent
+}
+
+// Enabled reports whether the chat, or the group it belongs to, is
+// read-only. A nil store never is.
+func (s *ReadOnlyStore) Enabled(chatID int64) bool {
+	if s == nil {
+		return false
+	}
+	if s.global || s.emergency.Active() {
+		return true
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.chats[chatID] || s.held[chatID] > 0 {
+		return true
+	}
+	if s.parent == nil {
+		return false
+	}
+	group := s.parent(chatID)
+	return s.chats[group] || s.held[group] > 0
+}
+
+// Hold makes the chat read-only until the returned release is called,
+// without persisting anything. It is for turns the chat's user did not
+// write, such as CI failure analysis; the caller holds the chat lock.
+func (s *ReadOnlyStore) Hold(chatID int64) (release func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.held[chatID]++
+	return func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.held[chatID]--; s.held[chatID] <= 0 {
+			delete(s.held, chatID)
+		}
+	}
+}
+
+func (s *ReadOnlyStore) Set(chatID int64, on bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if on {
+		s.chats[chatID] = true
+	} else {
+		delete(s.chats, chatID)
+	}
+	return saveJSONFile(s.path, s.chats)
+}
+
+// showSuggestions lists the commands the AI proposed in a read-only chat,
+// without running them or offering approval.
+func (h *Handlers) showSuggestions(chatID int64, provider string, commands []string) {
+	slog.Info("read-only: not running commands", "chat_id", chatID, "provider", provider, "commands", len(commands))
+	var b strings.Builder
+	b.WriteString(h.t(chatID, "readonly.suggest"))
+	for _, cmd := range commands {
+		fmt.Fprintf(&b, "\n`%s`", h.vars.Expand(chatID, cmd))
+	}
+	h.sender.Send(chatID, b.String())
+	h.events.Emit(Event{Type: EventTaskCompleted, ChatID: chatID, Provider: provider})
+}
+
+// HandleReadOnly shows or sets whether the chat is read-only. Turning it
+// off needs the admin chat when one is configured. Only an admin chat can
+// set it for another chat, so without admins a chat only changes its own.
+// READ_ONLY=true cannot be lifted per chat.
+// Usage: /readonly [on|off] [chatID]
+func (h *Handlers) HandleReadOnly(chatID int64, args string) {
+	a, ok := h.parseCommandArgs(chatID, "readonly", args)
+	if !ok {
+		return
+	}
+	if a.Len() == 0 {
+		state := "readonly.state_off"
+		switch {
+		case h.emergency.Active():
+			state = "readonly.state_emergency"
+		case h.readOnly.global:
+			state = "readonly.state_global"
+		case h.readOnly.Enabled(chatID):
+			state = "readonly.state_on"
+		}
+		h.sender.SendPlain(chatID, h.t(chatID, "readonly.state", h.t(chatID, state)))
+		return
+	}
+
+	var on bool
+	switch strings.ToLower(a.Arg(0)) {
+	case "on":
+		on = true
+	case "off":
+	default:
+		h.sendUsage(chatID, "readonly")
+		return
+	}
+	target := chatID
+	if a.Len() > 1 {
+		id, err := strconv.ParseInt(a.Arg(1), 10, 64)
+		if err != nil {
+			h.sendArgError(chatID, "readonly", fmt.Errorf("invalid chat ID %q", a.Arg(1)))
+			return
+		}
+		target = id
+	}
+	if !h.isAdmin(chatID) && (target != chatID || (!on && len(h.admins) > 0)) {
+		h.sender.SendPlain(chatID, h.t(chatID, "readonly.admin_only"))
+		return
+	}
+	if !on && h.readOnly.global {
+		h.sender.SendPlain(chatID, h.t(chatID, "readonly.global"))
+		return
+	}
+	if !on && h.emergency.Active() {
+		h.sender.SendPlain(chatID, h.t(chatID, "readonly.emergency"))
+		return
+	}
+
+	unlock := h.locks.Lock(target)
+	defer unlock()
+	if err := h.readOnly.Set(target, on); err != nil {
+		h.sender.SendPlain(chatID, h.t(chatID, "readonly.save_failed", err))
+		return
+	}
+	// Claude's tool access is fixed when a session starts, and pending
+	// approvals would otherwise wait for commands that can no longer run.
+	h.resetSession(target)
+	slog.Warn("read-only changed", "chat_id", target, "by_chat_id", chatID, "enabled", on)
+
+	key := "readonly.off"
+	if on {
+		key = "readonly.on"
+	}
+	if target != chatID {
+		h.sender.SendPlain(target, h.t(target, key))
+		h.sender.SendPlain(chatID, h.t(chatID, "readonly.other", target, h.onOff(chatID, on)))
+		return
+	}
+	h.sender.SendPlain(chatID, h.t(chatID, key))
+}
@@ -0,0 +1,54 @@
+package main
+
+import "testing"
+
+func TestReadOnlyStore(t *testing.T) {
+	dir := t.TempDir()
+	s := NewReadOnlyStore(dir, false)
+	if s.Enabled(1) {
+		t.Error("read-only on by default")
+	}
+	if err := s.Set(1, true); err != nil {
+		t.Fatal(err)
+	}
+
+	s = NewReadOnlyStore(dir, false)
+	if !s.Enabled(1) {
+		t.Error("chat 1 lost its setting on reload")
+	}
+	s.Set(1, false)
+	if s.Enabled(1) {
+		t.Error("chat 1 still read-only after turning it off")
+	}
+
+	if s = NewReadOnlyStore(dir, true); !s.Enabled(2) {
+		t.Error("READ_ONLY=true does not apply to every chat")
+	}
+	var none *ReadOnlyStore
+	if none.Enabled(1) {
+		t.Error("nil store reports read-only")
+	}
+}
+
+func TestReadOnlyFollowsGroup(t *testing.T) {
+	const group, topic = -100, -200
+	s := NewReadOnlyStore(t.TempDir(), false)
+	s.SetParentChat(func(id int64) int64 {
+		if id == topic {
+			return group
+		}
+		return id
+	})
+	release := s.Hold(group)
+	if !s.Enabled(topic) {
+		t.Error("topic not read-only while its group is held")
+	}
+	release()
+	s.Set(group, true)
+	if !s.Enabled(topic) {
+		t.Error("topic not read-only with its group read-only")
+	}
+	if s.Enabled(-300) {
+		t.Error("another chat is read-only")
+	}
+}
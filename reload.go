@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ReloadConfig re-reads the configuration (environment, --config file,
+// GUARDRAILS_FILE and PERSONAS_FILE) and applies what can change while
+// running: the allowed chats, SYSTEM_PROMPT, the guardrail profiles, the
+// personas, COMMAND_TIMEOUT and MAX_TOOL_ROUNDS. The handler settings are
+// swapped together under liveMu. On error nothing changes. It returns the
+// names of the settings that changed.
+func (h *Handlers) ReloadConfig() ([]string, error) {
+	cfg, err := LoadConfig(h.configFile)
+	if err != nil {
+		return nil, err
+	}
+
+	h.liveMu.Lock()
+	var changed []string
+	if !maps.Equal(h.allowed, cfg.AllowedChatIDs) {
+		changed = append(changed, "ALLOWED_CHAT_IDS")
+	}
+	if h.basePrompt != cfg.SystemPrompt {
+		changed = append(changed, "SYSTEM_PROMPT")
+	}
+	if !slices.Equal(h.personas, cfg.Personas) {
+		changed = append(changed, "PERSONAS_FILE")
+	}
+	if h.timeout != cfg.CommandTimeout {
+		changed = append(changed, "COMMAND_TIMEOUT")
+	}
+	if h.maxRounds != cfg.MaxToolRounds {
+		changed = append(changed, "MAX_TOOL_ROUNDS")
+	}
+	h.allowed = cfg.AllowedChatIDs
+	h.basePrompt = cfg.SystemPrompt
+	h.personas = cfg.Personas
+	h.timeout = cfg.CommandTimeout
+	h.maxRounds = cfg.MaxToolRounds
+	h.liveMu.Unlock()
+
+	// Compiled rules cannot be compared; the profiles are always replaced.
+	h.guardrails.Reload(cfg.GuardrailProfiles, cfg.GuardrailProfile)
+
+	slog.Info("config reloaded", "file", h.configFile, "changed", changed)
+	return changed, nil
+}
+
+// listed reports whether chatID is in ALLOWED_CHAT_IDS or was added with
+// /allow, and was not revoked.
+func (h *Handlers) listed(chatID int64) bool {
+	return h.allowlist.Allowed(chatID, h.configured(chatID))
+}
+
+// configured reports whether ALLOWED_CHAT_IDS lists chatID.
+func (h *Handlers) configured(chatID int64) bool {
+	h.liveMu.RLock()
+	defer h.liveMu.RUnlock()
+	return h.allowed[chatID]
+}
+
+// defaultTimeout returns COMMAND_TIMEOUT.
+func (h *Handlers) defaultTimeout() time.Duration {
+	h.liveMu.RLock()
+	defer h.liveMu.RUnlock()
+	return h.timeout
+}
+
+// defaultMaxRounds returns MAX_TOOL_ROUNDS.
+func (h *Handlers) defaultMaxRounds() int {
+	h.liveMu.RLock()
+	defer h.liveMu.RUnlock()
+	return h.maxRounds
+}
+
+func (h *Handlers) personaList() []Persona {
+	h.liveMu.RLock()
+	defer h.liveMu.RUnlock()
+	return h.personas
+}
+
+// reloadSummary describes a reload's outcome for a chat.
+func reloadSummary(changed []string, err error) string {
+	switch {
+	case err != nil:
+		return fmt.Sprintf("Config reload failed, keeping the current settings:\n%v", err)
+	case len(changed) == 0:
+		return "Config reloaded: no changes (guardrail profiles re-read)."
+	}
+	return fmt.Sprintf("Config reloaded. Changed: %s (guardrail profiles re-read).\nOther settings need a restart.", strings.Join(changed, ", "))
+}
+
+// HandleReload re-reads the configuration (admin only).
+func (h *Handlers) HandleReload(chatID int64) {
+	if !h.isAdmin(chatID) {
+		h.sender.SendPlain(chatID, h.t(chatID, "admin_only"))
+		return
+	}
+	changed, err := h.ReloadConfig()
+	if err != nil {
+		slog.Error("config reload failed", "chat_id", chatID, "err", err)
+	}
+	h.sender.SendPlain(chatID, reloadSummary(changed, err))
+}
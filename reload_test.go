@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"os"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestReloadConfig(t *testing.T) {
+	// Register the keys the file sets so the environment is restored.
+	for _, name := range []string{"TELEGRAM_BOT_TOKEN", "ALLOWED_CHAT_IDS", "SYSTEM_PROMPT", "COMMAND_TIMEOUT", "MAX_TOOL_ROUNDS", "DATA_DIR"} {
+		t.Setenv(name, "")
+	}
+	path := writeConfigFile(t, "telegram_bot_token: x\nallowed_chat_ids: [1]\ndata_dir: "+t.TempDir()+"\n")
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := &Handlers{
+		systemPrompts: NewSystemPromptStore(t.TempDir()),
+		plugins:       LoadPlugins("", 0),
+		settings:      NewSettingsStore(t.TempDir()),
+		guardrails:    NewGuardrailStore(cfg.GuardrailProfiles, cfg.GuardrailProfile),
+		configFile:    cfg.ConfigFile,
+		clientPrompt:  cfg.SystemPrompt,
+		allowed:       cfg.AllowedChatIDs,
+		basePrompt:    cfg.SystemPrompt,
+		personas:      cfg.Personas,
+		timeout:       cfg.CommandTimeout,
+		maxRounds:     cfg.MaxToolRounds,
+	}
+	if !h.IsAllowed(1) || h.IsAllowed(2) {
+		t.Fatal("initial allowed chats wrong")
+	}
+
+	os.WriteFile(path, []byte("telegram_bot_token: x\nallowed_chat_ids: [2]\nsystem_prompt: Be terse.\ncommand_timeout: 1m\n"), 0o600)
+	changed, err := h.ReloadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"ALLOWED_CHAT_IDS", "SYSTEM_PROMPT", "COMMAND_TIMEOUT"}; !slices.Equal(changed, want) {
+		t.Errorf("changed = %v, want %v", changed, want)
+	}
+	if h.IsAllowed(1) || !h.IsAllowed(2) {
+		t.Error("allowed chats not reloaded")
+	}
+	if h.timeoutFor(2) != time.Minute {
+		t.Errorf("timeout = %s", h.timeoutFor(2))
+	}
+	// The clients were built without SYSTEM_PROMPT, so the new one is sent
+	// through the context.
+	ctx := h.withSystemPrompt(context.Background(), 2, "gemini")
+	if got := systemPromptFrom(ctx, "default"); got != "Be terse."+safeguardPrompt+askPrompt {
+		t.Errorf("system prompt = %q", got)
+	}
+
+	// A broken file keeps the running settings.
+	os.WriteFile(path, []byte("telegram_bot_token: x\nallowed_chat_ids: [3]\ncommand_timeout: soon\n"), 0o600)
+	if _, err := h.ReloadConfig(); err == nil {
+		t.Fatal("invalid config accepted")
+	}
+	if !h.IsAllowed(2) || h.timeoutFor(2) != time.Minute {
+		t.Error("failed reload changed settings")
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// maxReplyContext caps the quoted text of a replied-to message, in
+// characters.
+const maxReplyContext = 2000
+
+// withReplyContext prefixes text with the message it replies to, so the AI
+// knows what "this" refers to. botID tells the bot's own messages apart.
+// Replies to messages without text or a file, such as the implicit reply
+// to a forum topic's first message, add nothing.
+func withReplyContext(text string, reply *tgbotapi.Message, botID int64) string {
+	if reply == nil {
+		return text
+	}
+	quoted := reply.Text
+	if quoted == "" {
+		quoted = reply.Caption
+	}
+	switch {
+	case reply.Document != nil && quoted == "":
+		quoted = fmt.Sprintf("(file %s)", reply.Document.FileName)
+	case reply.Document != nil:
+		quoted = fmt.Sprintf("(file %s) %s", reply.Document.FileName, quoted)
+	case len(reply.Photo) > 0 && quoted == "":
+		quoted = "(a photo)"
+	}
+	if strings.TrimSpace(quoted) == "" {
+		return text
+	}
+	if utf8.RuneCountInString(quoted) > maxReplyContext {
+		quoted = string([]rune(quoted)[:maxReplyContext]) + "…"
+	}
+
+	author := "the user"
+	switch {
+	case reply.From != nil && reply.From.ID == botID:
+		author = "you"
+	case reply.From != nil && displayName(reply.From) != "":
+		author = displayName(reply.From)
+	}
+	return fmt.Sprintf("[The user is replying to this earlier message from %s:]\n%s\n[End of quoted message.]\n\n%s",
+		author, quoteLines(quoted), text)
+}
+
+// quoteLines prefixes each line with "> ".
+func quoteLines(s string) string {
+	return "> " + strings.ReplaceAll(s, "\n", "\n> ")
+}
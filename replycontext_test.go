@@ -0,0 +1,43 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestWithReplyContext(t *testing.T) {
+	bot := &tgbotapi.User{ID: 99, UserName: "trashbot"}
+	alice := &tgbotapi.User{ID: 1, UserName: "alice"}
+
+	if got := withReplyContext("hi", nil, 99); got != "hi" {
+		t.Errorf("no reply: %q", got)
+	}
+
+	got := withReplyContext("why is this slow?", &tgbotapi.Message{From: bot, Text: "SELECT *\nFROM t"}, 99)
+	for _, want := range []string{"earlier message from you:", "> SELECT *\n> FROM t", "why is this slow?"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("reply to the bot: %q lacks %q", got, want)
+		}
+	}
+	if !strings.HasSuffix(got, "\n\nwhy is this slow?") {
+		t.Errorf("question not last: %q", got)
+	}
+
+	got = withReplyContext("fix it", &tgbotapi.Message{From: alice, Document: &tgbotapi.Document{FileName: "answer.md"}}, 99)
+	if !strings.Contains(got, "from @alice:") || !strings.Contains(got, "(file answer.md)") {
+		t.Errorf("reply to a file: %q", got)
+	}
+
+	// The implicit reply to a forum topic's first message has no text.
+	if got := withReplyContext("hello", &tgbotapi.Message{From: alice}, 99); got != "hello" {
+		t.Errorf("empty reply added context: %q", got)
+	}
+
+	long := strings.Repeat("x", maxReplyContext+50)
+	got = withReplyContext("q", &tgbotapi.Message{From: bot, Text: long}, 99)
+	if strings.Count(got, "x") != maxReplyContext || !strings.Contains(got, "…") {
+		t.Error("long quote not truncated")
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// A chat can limit how long its replies get, with two /config settings:
+//
+//   - max_tokens caps the tokens the provider generates (Gemini's
+//     maxOutputTokens, max_tokens for OpenRouter and Azure, Bedrock's
+//     inferenceConfig and CLAUDE_CODE_MAX_OUTPUT_TOKENS for Claude). A
+//     reply that hits it is cut off mid-sentence.
+//   - reply_chars asks the model to stay under that many characters, for
+//     reading on a phone. Longer replies are truncated in the chat and the
+//     full answer is attached as a file.
+
+// maxTokensKey is the context key of a chat's output token limit.
+type maxTokensKey struct{}
+
+// withMaxTokens returns ctx carrying the chat's max_tokens setting, which
+// the provider clients read with maxTokensFrom.
+func (h *Handlers) withMaxTokens(ctx context.Context, chatID int64) context.Context {
+	if n := h.settings.Get(chatID).MaxTokens; n > 0 {
+		return context.WithValue(ctx, maxTokensKey{}, n)
+	}
+	return ctx
+}
+
+// maxTokensFrom returns the output token limit carried by ctx, or 0 for
+// the provider's default.
+func maxTokensFrom(ctx context.Context) int {
+	n, _ := ctx.Value(maxTokensKey{}).(int)
+	return n
+}
+
+// withLengthPrompt prefixes message with an instruction to keep the reply
+// under the chat's reply_chars.
+func (h *Handlers) withLengthPrompt(chatID int64, message string) string {
+	n := h.settings.Get(chatID).ReplyChars
+	if n == 0 {
+		return message
+	}
+	return fmt.Sprintf("[Keep your answer under %d characters; it is read on a phone.]\n", n) + message
+}
+
+// shortenReply cuts text to at most limit characters, at a paragraph or
+// line break when there is one in the second half, and closes a code fence
+// the cut leaves open. ok is false when text already fits.
+func shortenReply(text string, limit int) (short string, ok bool) {
+	if limit <= 0 || utf8.RuneCountInString(text) <= limit {
+		return text, false
+	}
+	cut := len(text)
+	for i := range text {
+		if limit == 0 {
+			cut = i
+			break
+		}
+		limit--
+	}
+	short = text[:cut]
+	if i := strings.LastIndex(short, "\n\n"); i > cut/2 {
+		short = short[:i]
+	} else if i := strings.LastIndex(short, "\n"); i > cut/2 {
+		short = short[:i]
+	}
+	short = strings.TrimRight(short, " \n")
+	if strings.Count(short, "```")%2 == 1 {
+		short += "\n```"
+	}
+	return short, true
+}
+
+// truncateText caps s at n characters, appending "..." when cut. It never
+// splits a multi-byte character.
+func truncateText(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for i := range s {
+		if n == 0 {
+			return s[:i] + "..."
+		}
+		n--
+	}
+	return s
+}
+
+// sendShortened sends the first reply_chars of a long reply and the full
+// reply as a document. It reports false when the reply fits and nothing
+// was sent.
+func (h *Handlers) sendShortened(chatID int64, text string) bool {
+	short, ok := shortenReply(text, h.settings.Get(chatID).ReplyChars)
+	if !ok {
+		return false
+	}
+	h.sender.Send(chatID, short+h.t(chatID, "reply.shortened"))
+	h.sender.SendDocument(chatID, "answer.md", []byte(text), h.t(chatID, "reply.full_caption", utf8.RuneCountInString(text)))
+	return true
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestShortenReply(t *testing.T) {
+	if _, ok := shortenReply("short", 100); ok {
+		t.Error("short reply shortened")
+	}
+	text := strings.Repeat("a", 60) + "\n\n" + strings.Repeat("b", 60)
+	short, ok := shortenReply(text, 100)
+	if !ok || short != strings.Repeat("a", 60) {
+		t.Errorf("paragraph cut = %q", short)
+	}
+	short, _ = shortenReply("héllo wörld ünïcode", 8)
+	if short != "héllo wö" {
+		t.Errorf("rune cut = %q", short)
+	}
+	short, _ = shortenReply("Run this:\n```sh\n"+strings.Repeat("echo hi\n", 20), 60)
+	if strings.Count(short, "```") != 2 || !strings.HasSuffix(short, "\n```") {
+		t.Errorf("open fence not closed: %q", short)
+	}
+}
+
+func TestTruncateText(t *testing.T) {
+	if got := truncateText("short", 10); got != "short" {
+		t.Errorf("short text = %q", got)
+	}
+	if got := truncateText("abcdef", 3); got != "abc..." {
+		t.Errorf("ascii cut = %q", got)
+	}
+	// Five characters in ten bytes fit a limit of 6; a byte cut would have
+	// split the euro sign.
+	if got := truncateText("ééé€a", 6); got != "ééé€a" {
+		t.Errorf("multi-byte text within limit = %q", got)
+	}
+	got := truncateText("héllo wörld", 5)
+	if got != "héllo..." || !utf8.ValidString(got) {
+		t.Errorf("rune cut = %q", got)
+	}
+}
+
+func TestMaxTokensSetting(t *testing.T) {
+	h := &Handlers{settings: NewSettingsStore(t.TempDir())}
+	if n := maxTokensFrom(h.withMaxTokens(context.Background(), 1)); n != 0 {
+		t.Errorf("default max tokens = %d", n)
+	}
+	s, _ := lookupChatSetting("max_tokens")
+	if err := h.settings.Update(1, func(cs *ChatSettings) error { return s.Set(cs, "100") }); err == nil {
+		t.Error("max_tokens below range accepted")
+	}
+	h.settings.Update(1, func(cs *ChatSettings) error { return s.Set(cs, "1024") })
+	ctx := h.withMaxTokens(context.Background(), 1)
+
+	var got openRouterRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		json.Unmarshal(body, &got)
+		io.WriteString(w, `{"choices":[{"message":{"role":"assistant","content":"ok"}}]}`)
+	}))
+	defer srv.Close()
+	o := NewOpenRouterClient(&Config{OpenRouterAPIKey: "sk-or-test"})
+	o.baseURL = srv.URL
+	if _, _, err := o.Send(ctx, nil, "hello"); err != nil {
+		t.Fatal(err)
+	}
+	if got.MaxTokens != 1024 {
+		t.Errorf("max_tokens = %d, want 1024", got.MaxTokens)
+	}
+
+	if msg := h.withLengthPrompt(1, "hi"); msg != "hi" {
+		t.Errorf("length prompt without reply_chars: %q", msg)
+	}
+	h.settings.Update(1, func(cs *ChatSettings) error { cs.ReplyChars = 500; return nil })
+	if msg := h.withLengthPrompt(1, "hi"); !strings.Contains(msg, "under 500 characters") || !strings.HasSuffix(msg, "\nhi") {
+		t.Errorf("length prompt = %q", msg)
+	}
+}
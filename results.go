@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+// Strategies for shrinking command output before it is fed back to the AI.
+// What the user sees in chat is not affected.
+const (
+	ResultFull      = "full"      // send output as is (already capped at 10 KB)
+	ResultHeadTail  = "headtail"  // keep the first and last part
+	ResultErrors    = "errors"    // keep error-looking lines plus the tail
+	ResultSummarize = "summarize" // have the AI summarize long output
+)
+
+// resultStrategies lists the valid RESULT_STRATEGY values.
+var resultStrategies = []string{ResultFull, ResultHeadTail, ResultErrors, ResultSummarize}
+
+// errorLineRe matches lines worth keeping under the errors strategy.
+var errorLineRe = regexp.MustCompile(`(?i)\b(error|err|fail(ed|ure)?|fatal|panic|exception|traceback|denied|not found|no such|cannot|undefined|warning)\b`)
+
+// errorTailLines is how many trailing lines the errors strategy always keeps,
+// since exit summaries usually come last.
+const errorTailLines = 5
+
+// headTail keeps roughly the first and last max/2 bytes of s on line boundaries.
+func headTail(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	half := max / 2
+	head := s[:half]
+	if i := strings.LastIndexByte(head, '\n'); i > 0 {
+		head = head[:i]
+	}
+	tail := s[len(s)-half:]
+	if i := strings.IndexByte(tail, '\n'); i >= 0 && i < len(tail)-1 {
+		tail = tail[i+1:]
+	}
+	omitted := len(s) - len(head) - len(tail)
+	return fmt.Sprintf("%s\n... (%d bytes omitted) ...\n%s", head, omitted, tail)
+}
+
+// errorLines keeps lines that look like errors or warnings plus the last few
+// lines, falling back to headTail when nothing matches.
+func errorLines(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	lines := strings.Split(strings.TrimRight(s, "\n"), "\n")
+	var kept []string
+	matched := 0
+	for i, line := range lines {
+		isTail := i >= len(lines)-errorTailLines
+		if errorLineRe.MatchString(line) {
+			matched++
+		} else if !isTail {
+			continue
+		}
+		kept = append(kept, fmt.Sprintf("%d: %s", i+1, line))
+	}
+	if matched == 0 {
+		return headTail(s, max)
+	}
+	out := fmt.Sprintf("(%d of %d lines: errors/warnings and the last %d)\n%s", len(kept), len(lines), errorTailLines, strings.Join(kept, "\n"))
+	return headTail(out, max)
+}
+
+// compactOutput applies the configured strategy to one command's output.
+func (h *Handlers) compactOutput(ctx context.Context, chatID int64, command, output string) string {
+	limit := h.resultLimitFor(chatID)
+	if len(output) <= limit {
+		return output
+	}
+	switch h.resultStrategy {
+	case ResultHeadTail:
+		return headTail(output, limit)
+	case ResultErrors:
+		return errorLines(output, limit)
+	case ResultSummarize:
+		summary, err := h.summarizeOutput(ctx, chatID, command, output)
+		if err != nil {
+			slog.Warn("summarizing command output failed, using head+tail", "chat_id", chatID, "err", err)
+			return headTail(output, limit)
+		}
+		return fmt.Sprintf("(summary of %d bytes of output)\n%s", len(output), summary)
+	}
+	return output
+}
+
+// compactResults returns results with their outputs shrunk for the AI.
+func (h *Handlers) compactResults(ctx context.Context, chatID int64, results []CommandResult) []CommandResult {
+	if h.resultStrategy == ResultFull {
+		return results
+	}
+	out := make([]CommandResult, len(results))
+	for i, r := range results {
+		r.Output = h.compactOutput(ctx, chatID, r.Command, r.Output)
+		out[i] = r
+	}
+	return out
+}
+
+// summarizeOutput asks a provider, outside the chat's session, to condense
+// command output. Gemini is preferred when configured because it is a single
+// stateless API call.
+func (h *Handlers) summarizeOutput(ctx context.Context, chatID int64, command, output string) (string, error) {
+	prompt := fmt.Sprintf("Summarize the output of the command `%s` for a coding assistant that will act on it. "+
+		"Keep every error, failing test, file path and number that matters; drop repetitive noise. "+
+		"Reply with the summary only, no commands.\n\n%s", command, output)
+	if h.gemini.HasAPIKey() {
+		summary, usage, err := h.gemini.Send(ctx, nil, prompt)
+		h.recordGeminiUsage(ctx, chatID, usage, 0)
+		return summary, err
+	}
+	resp, err := h.claude.Send(ctx, chatID, h.projectDir(chatID), "", prompt)
+	if err != nil {
+		return "", err
+	}
+	h.recordUsage(ctx, chatID, resp)
+	return resp.Result, nil
+}
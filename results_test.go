@@ -0,0 +1,43 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHeadTail(t *testing.T) {
+	var lines []string
+	for i := 0; i < 200; i++ {
+		lines = append(lines, strings.Repeat("x", 20))
+	}
+	lines[0], lines[199] = "first line", "last line"
+	out := headTail(strings.Join(lines, "\n"), 400)
+	if !strings.HasPrefix(out, "first line\n") || !strings.HasSuffix(out, "\nlast line") {
+		t.Errorf("head or tail missing:\n%s", out)
+	}
+	if !strings.Contains(out, "bytes omitted") || len(out) > 500 {
+		t.Errorf("output not shortened (%d bytes):\n%s", len(out), out)
+	}
+	if short := "ok\n"; headTail(short, 400) != short {
+		t.Error("short output should be unchanged")
+	}
+}
+
+func TestErrorLines(t *testing.T) {
+	var lines []string
+	for i := 0; i < 300; i++ {
+		lines = append(lines, "compiling module "+strings.Repeat("a", 10))
+	}
+	lines[100] = "main.go:12: undefined: foo"
+	lines[150] = "--- FAIL: TestBar"
+	lines[299] = "exit status 1"
+	out := errorLines(strings.Join(lines, "\n"), 1000)
+	for _, want := range []string{"101: main.go:12: undefined: foo", "151: --- FAIL: TestBar", "300: exit status 1"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "50: compiling") {
+		t.Errorf("noise line kept:\n%s", out)
+	}
+}
@@ -1,8 +1,9 @@
 package main
 
 import (
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"regexp"
 	"strings"
 )
@@ -15,28 +16,72 @@ const (
 	CommandBlocked
 )
 
+// ErrCommandBlocked is returned (wrapped) by ExecuteCommand when a safeguard
+// rule rejects a command.
+var ErrCommandBlocked = errors.New("command blocked")
+
 // SafeguardRule defines a single rule that can block a command.
 type SafeguardRule struct {
-	Name    string
-	Check   func(cmd string) bool
-	Reason  string
+	Name   string
+	Check  func(cmd string) bool
+	Reason string
 }
 
 // Safeguard checks commands against a set of security rules.
 type Safeguard struct {
-	rules []SafeguardRule
+	rules      []SafeguardRule
+	warnings   []SafeguardRule      // risky but allowed: run only once the user types a confirmation
+	exceptions *SafeguardExceptions // admin-approved exact commands, may be nil
+}
+
+// SetExceptions makes the safeguard let the listed commands through the
+// rules they were allowed for.
+func (s *Safeguard) SetExceptions(e *SafeguardExceptions) {
+	s.exceptions = e
 }
 
 // NewSafeguard creates a Safeguard with all built-in rules.
 func NewSafeguard() *Safeguard {
 	s := &Safeguard{}
 	s.registerRules()
+	s.registerWarnings()
 	return s
 }
 
 // Check evaluates a command against all rules. Returns the verdict and
 // a human-readable reason if blocked.
 func (s *Safeguard) Check(command string) (CommandVerdict, string) {
+	if rule := s.match(command); rule != nil {
+		slog.Warn("safeguard blocked command", "command", command, "rule", rule.Name)
+		return CommandBlocked, fmt.Sprintf("Blocked by safeguard rule '%s': %s", rule.Name, rule.Reason)
+	}
+	return CommandAllowed, ""
+}
+
+// Warning returns why command is risky enough to need a typed confirmation
+// before it runs, or "" if tapping Approve is enough.
+func (s *Safeguard) Warning(command string) string {
+	normalized := strings.TrimSpace(command)
+	lower := strings.ToLower(normalized)
+	for _, rule := range s.warnings {
+		if rule.Check(normalized) || rule.Check(lower) {
+			return rule.Reason
+		}
+	}
+	return ""
+}
+
+// Rule returns the name of the rule that blocks command, or "" if none does.
+func (s *Safeguard) Rule(command string) string {
+	if rule := s.match(command); rule != nil {
+		return rule.Name
+	}
+	return ""
+}
+
+// match returns the first rule that blocks command, or nil. A rule the
+// command has an exception for is skipped; later rules still apply.
+func (s *Safeguard) match(command string) *SafeguardRule {
 	// Normalize: collapse whitespace, trim.
 	normalized := strings.TrimSpace(command)
 	// Also create a version without quotes for pattern matching.
@@ -44,13 +89,16 @@ func (s *Safeguard) Check(command string) (CommandVerdict, string) {
 	lower := strings.ToLower(normalized)
 	lowerUnquoted := strings.ToLower(unquoted)
 
-	for _, rule := range s.rules {
+	for i := range s.rules {
+		rule := &s.rules[i]
 		if rule.Check(normalized) || rule.Check(unquoted) || rule.Check(lower) || rule.Check(lowerUnquoted) {
-			log.Printf("[safeguard] BLOCKED command: %s (rule: %s)", command, rule.Name)
-			return CommandBlocked, fmt.Sprintf("Blocked by safeguard rule '%s': %s", rule.Name, rule.Reason)
+			if s.exceptions.Allowed(rule.Name, normalized) {
+				continue
+			}
+			return rule
 		}
 	}
-	return CommandAllowed, ""
+	return nil
 }
 
 // registerRules sets up all built-in safeguard rules.
@@ -172,6 +220,29 @@ func (s *Safeguard) registerRules() {
 		"Piping remote content directly to shell")
 }
 
+// registerWarnings sets up the warn tier: commands that are allowed but
+// destructive or hard to undo, so a stray tap on Approve must not run them.
+func (s *Safeguard) registerWarnings() {
+	for _, w := range []struct{ name, pattern, reason string }{
+		{"rm-recursive", `\brm\s+(\S+\s+)*(-[a-z]*[rf]|--recursive|--force)`, "Recursive or forced file removal"},
+		{"git-force", `git\s+(push\s+(.*\s)?(--force|-f)\b|reset\s+--hard|clean\s+-[a-z]*f|branch\s+-D)`, "Discards git history or uncommitted work"},
+		{"sql-drop", `\b(drop\s+(table|database|schema)|truncate\s+table)\b`, "Drops or empties database objects"},
+		{"kubectl-delete", `kubectl\s+(.*\s)?(delete|drain)\s`, "Deletes cluster resources"},
+		{"terraform-destroy", `terraform\s+(destroy|apply\s+.*-destroy)`, "Destroys infrastructure"},
+		{"docker-prune", `docker\s+(system\s+prune|volume\s+(rm|prune)|rm\s+-f)`, "Removes containers or volumes"},
+		{"recursive-perms", `\b(chmod|chown)\s+(\S+\s+)*(-[a-z]*r|--recursive)\b`, "Recursive permission or ownership change"},
+		{"power", `(^|[;&|]\s*|sudo\s+|systemctl\s+)(shutdown|reboot|halt|poweroff)\b`, "Stops or restarts the machine"},
+		{"kill-all", `\b(killall|pkill)\s|kill\s+-9\s+-1\b`, "Kills processes by name or all of them"},
+	} {
+		re := regexp.MustCompile(w.pattern)
+		s.warnings = append(s.warnings, SafeguardRule{
+			Name:   w.name,
+			Check:  func(cmd string) bool { return re.MatchString(cmd) },
+			Reason: w.reason,
+		})
+	}
+}
+
 // addRegex registers a rule that matches a regular expression.
 func (s *Safeguard) addRegex(name, pattern, reason string) {
 	re := regexp.MustCompile(pattern)
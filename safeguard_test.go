@@ -102,3 +102,41 @@ func TestSafeguardAllows(t *testing.T) {
 		})
 	}
 }
+
+func TestSafeguardWarning(t *testing.T) {
+	sg := NewSafeguard()
+	for _, cmd := range []string{
+		"rm -rf ./build",
+		"cd app && rm -r dist",
+		"git push --force origin main",
+		"git push -f",
+		"git reset --hard HEAD~3",
+		"git branch -D feature",
+		"psql -c 'DROP TABLE users'",
+		"kubectl -n prod delete pod web-1",
+		"terraform destroy -auto-approve",
+		"docker system prune -af",
+		"chmod -R 755 ./dist",
+		"sudo reboot",
+		"pkill -f server",
+	} {
+		if sg.Warning(cmd) == "" {
+			t.Errorf("%q not flagged as risky", cmd)
+		}
+	}
+	for _, cmd := range []string{
+		"rm /tmp/test.txt",
+		"git push origin feature-foo",
+		"git branch -d merged",
+		"kubectl get pods",
+		"terraform plan",
+		"docker ps",
+		"chmod 644 file",
+		"grep reboot /var/log/syslog",
+		"ls -la",
+	} {
+		if reason := sg.Warning(cmd); reason != "" {
+			t.Errorf("%q flagged as risky: %s", cmd, reason)
+		}
+	}
+}
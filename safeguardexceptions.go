@@ -0,0 +1,261 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// A blocked command that was legitimate can be sent to the admin chat for
+// review. The admin can allow that exact command for the rule that blocked
+// it: other commands, and other rules matching the same command, are still
+// enforced.
+
+// SafeguardException lets one exact command through one rule.
+type SafeguardException struct {
+	Rule    string    `json:"rule"`
+	Command string    `json:"command"`
+	ChatID  int64     `json:"chat_id"` // chat that requested it
+	AddedBy string    `json:"added_by"`
+	Added   time.Time `json:"added"`
+}
+
+// SafeguardExceptions is the admin-approved allowlist, persisted to
+// DATA_DIR and shared by the providers' safeguards.
+type SafeguardExceptions struct {
+	mu      sync.RWMutex
+	path    string
+	entries []SafeguardException
+}
+
+func NewSafeguardExceptions(dataDir string) *SafeguardExceptions {
+	e := &SafeguardExceptions{path: filepath.Join(dataDir, "safeguard_exceptions.json")}
+	if err := loadJSONFile(e.path, &e.entries); err != nil {
+		slog.Warn("failed to load safeguard exceptions", "path", e.path, "err", err)
+	}
+	return e
+}
+
+// Allowed reports whether command is excepted from rule. It is false on a
+// nil list.
+func (e *SafeguardExceptions) Allowed(rule, command string) bool {
+	if e == nil {
+		return false
+	}
+	command = strings.TrimSpace(command)
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	for _, x := range e.entries {
+		if x.Rule == rule && x.Command == command {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *SafeguardExceptions) Add(x SafeguardException) error {
+	x.Command = strings.TrimSpace(x.Command)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, y := range e.entries {
+		if y.Rule == x.Rule && y.Command == x.Command {
+			return nil
+		}
+	}
+	e.entries = append(e.entries, x)
+	return saveJSONFile(e.path, e.entries)
+}
+
+// Remove deletes the i-th exception (1-based, as listed).
+func (e *SafeguardExceptions) Remove(i int) (SafeguardException, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if i < 1 || i > len(e.entries) {
+		return SafeguardException{}, fmt.Errorf("no exception #%d", i)
+	}
+	x := e.entries[i-1]
+	e.entries = append(e.entries[:i-1], e.entries[i:]...)
+	return x, saveJSONFile(e.path, e.entries)
+}
+
+func (e *SafeguardExceptions) List() []SafeguardException {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return append([]SafeguardException(nil), e.entries...)
+}
+
+// exceptionRequest is a blocked command offered for review. Requests live
+// in memory: after a restart the user asks again.
+type exceptionRequest struct {
+	ChatID  int64
+	Command string
+	Rule    string
+	Reason  string
+}
+
+// ExceptionRequests holds the open requests by ID.
+type ExceptionRequests struct {
+	mu       sync.Mutex
+	next     int
+	requests map[int]*exceptionRequest
+}
+
+func NewExceptionRequests() *ExceptionRequests {
+	return &ExceptionRequests{requests: make(map[int]*exceptionRequest)}
+}
+
+func (r *ExceptionRequests) Add(req *exceptionRequest) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.next++
+	r.requests[r.next] = req
+	return r.next
+}
+
+func (r *ExceptionRequests) Get(id int) *exceptionRequest {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.requests[id]
+}
+
+// Take removes a decided request.
+func (r *ExceptionRequests) Take(id int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.requests, id)
+}
+
+// offerException shows a "Request exception" button under a blocked
+// command, when there is an admin chat to review it.
+func (h *Handlers) offerException(chatID int64, cmd string) {
+	if h.adminChatID == 0 {
+		return
+	}
+	cmd = h.vars.Expand(chatID, cmd)
+	rule := h.claude.safeguard.Rule(cmd)
+	if rule == "" {
+		return
+	}
+	_, reason := h.claude.safeguard.Check(cmd)
+	id := h.exceptionReqs.Add(&exceptionRequest{ChatID: chatID, Command: cmd, Rule: rule, Reason: reason})
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(h.t(chatID, "sgx.request_button"), fmt.Sprintf("sgx:req:%d", id)),
+		),
+	)
+	h.sender.SendPlainWithKeyboard(chatID, h.t(chatID, "sgx.blocked", rule), keyboard)
+}
+
+// handleExceptionCallback handles the request button and the admin's
+// decision buttons: "sgx:req|allow|reject:<id>".
+func (h *Handlers) handleExceptionCallback(chatID int64, callbackID, data string, messageID int, from string) {
+	action, idText, _ := strings.Cut(strings.TrimPrefix(data, "sgx:"), ":")
+	id, err := strconv.Atoi(idText)
+	if err != nil {
+		h.sender.AnswerCallback(callbackID, h.t(chatID, "sgx.invalid"))
+		return
+	}
+
+	if action == "req" {
+		req := h.exceptionReqs.Get(id)
+		if req == nil || req.ChatID != chatID {
+			h.sender.AnswerCallback(callbackID, h.t(chatID, "sgx.expired"))
+			return
+		}
+		slog.Info("safeguard exception requested", "chat_id", chatID, "rule", req.Rule, "command", req.Command)
+		var by string
+		if from != "" {
+			by = fmt.Sprintf(" (%s)", from)
+		}
+		keyboard := tgbotapi.NewInlineKeyboardMarkup(
+			tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData(h.t(h.adminChatID, "sgx.allow_button"), fmt.Sprintf("sgx:allow:%d", id)),
+				tgbotapi.NewInlineKeyboardButtonData(h.t(h.adminChatID, "sgx.reject_button"), fmt.Sprintf("sgx:reject:%d", id)),
+			),
+		)
+		h.sender.SendPlainWithKeyboard(h.adminChatID, h.t(h.adminChatID, "sgx.request_card", chatID, by, req.Rule, req.Reason, req.Command), keyboard)
+		h.sender.AnswerCallback(callbackID, h.t(chatID, "sgx.sent"))
+		h.sender.EditRemoveKeyboard(chatID, messageID, h.t(chatID, "sgx.requested", req.Rule))
+		return
+	}
+
+	if !h.isAdmin(chatID) {
+		h.sender.AnswerCallback(callbackID, h.t(chatID, "sgx.admin_only"))
+		return
+	}
+	req := h.exceptionReqs.Get(id)
+	if req == nil {
+		h.sender.AnswerCallback(callbackID, h.t(chatID, "sgx.decided"))
+		return
+	}
+	switch action {
+	case "allow":
+		x := SafeguardException{Rule: req.Rule, Command: req.Command, ChatID: req.ChatID, AddedBy: from, Added: time.Now()}
+		if err := h.exceptions.Add(x); err != nil {
+			// The request stays open so the admin can retry.
+			h.sender.AnswerCallback(callbackID, h.t(chatID, "sgx.save_failed"))
+			slog.Error("failed to save safeguard exception", "err", err)
+			return
+		}
+		h.exceptionReqs.Take(id)
+		slog.Warn("safeguard exception added", "chat_id", req.ChatID, "rule", req.Rule, "command", req.Command, "by", from)
+		h.sender.AnswerCallback(callbackID, h.t(chatID, "sgx.allowed"))
+		h.sender.EditRemoveKeyboard(chatID, messageID, h.t(chatID, "sgx.allowed_card", req.Rule, req.Command))
+		h.sender.SendPlain(req.ChatID, h.t(req.ChatID, "sgx.allowed_notice", req.Rule, req.Command))
+	case "reject":
+		h.exceptionReqs.Take(id)
+		slog.Info("safeguard exception rejected", "chat_id", req.ChatID, "rule", req.Rule, "command", req.Command, "by", from)
+		h.sender.AnswerCallback(callbackID, h.t(chatID, "sgx.rejected"))
+		h.sender.EditRemoveKeyboard(chatID, messageID, h.t(chatID, "sgx.rejected_card", req.Rule, req.Command))
+		h.sender.SendPlain(req.ChatID, h.t(req.ChatID, "sgx.rejected_notice", req.Rule, req.Command))
+	default:
+		h.sender.AnswerCallback(callbackID, h.t(chatID, "sgx.invalid"))
+	}
+}
+
+// handleSafeguardExceptions lists or revokes exceptions (admin only).
+// Usage: /safeguard exceptions | /safeguard revoke <n>
+func (h *Handlers) handleSafeguardExceptions(chatID int64, sub, args string) {
+	if !h.isAdmin(chatID) {
+		h.sender.SendPlain(chatID, h.t(chatID, "admin_only"))
+		return
+	}
+	if sub == "revoke" {
+		n, err := strconv.Atoi(strings.TrimSpace(args))
+		if err != nil {
+			h.sender.SendPlain(chatID, h.t(chatID, "sgx.revoke_usage"))
+			return
+		}
+		x, err := h.exceptions.Remove(n)
+		if err != nil {
+			h.sender.SendPlain(chatID, h.t(chatID, "sgx.revoke_failed", err))
+			return
+		}
+		slog.Warn("safeguard exception revoked", "rule", x.Rule, "command", x.Command)
+		h.sender.SendPlain(chatID, h.t(chatID, "sgx.revoked", x.Rule, x.Command))
+		return
+	}
+
+	list := h.exceptions.List()
+	if len(list) == 0 {
+		h.sender.SendPlain(chatID, h.t(chatID, "sgx.none"))
+		return
+	}
+	var b strings.Builder
+	b.WriteString(h.t(chatID, "sgx.list"))
+	for i, x := range list {
+		b.WriteString(h.t(chatID, "sgx.entry", i+1, x.Rule, x.ChatID, x.Added.UTC().Format("2006-01-02")))
+		if x.AddedBy != "" {
+			b.WriteString(h.t(chatID, "sgx.entry_by", x.AddedBy))
+		}
+		fmt.Fprintf(&b, "\n   %s\n", x.Command)
+	}
+	b.WriteString(h.t(chatID, "sgx.list_footer"))
+	h.sender.SendPlain(chatID, b.String())
+}
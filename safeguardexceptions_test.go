@@ -0,0 +1,45 @@
+package main
+
+import "testing"
+
+func TestSafeguardExceptions(t *testing.T) {
+	dir := t.TempDir()
+	s := NewSafeguard()
+	const cmd = "rm -rf /"
+	rule := s.Rule(cmd)
+	if rule == "" {
+		t.Fatalf("%q not blocked", cmd)
+	}
+
+	e := NewSafeguardExceptions(dir)
+	s.SetExceptions(e)
+	if err := e.Add(SafeguardException{Rule: rule, Command: " " + cmd + " ", ChatID: 1}); err != nil {
+		t.Fatal(err)
+	}
+	e.Add(SafeguardException{Rule: rule, Command: cmd, ChatID: 2})
+	if n := len(e.List()); n != 1 {
+		t.Errorf("duplicate exception stored: %d entries", n)
+	}
+	if v, reason := s.Check(cmd); v == CommandBlocked && s.Rule(cmd) == rule {
+		t.Errorf("excepted command still blocked by its rule: %s", reason)
+	}
+	if v, _ := s.Check("rm -rf / --no-preserve-root"); v != CommandBlocked {
+		t.Error("exception is not limited to the exact command")
+	}
+
+	// Exceptions are persisted and can be revoked.
+	e = NewSafeguardExceptions(dir)
+	if !e.Allowed(rule, cmd) {
+		t.Error("exception lost on reload")
+	}
+	if _, err := e.Remove(2); err == nil {
+		t.Error("Remove(2) succeeded with one exception")
+	}
+	if x, err := e.Remove(1); err != nil || x.Rule != rule {
+		t.Errorf("Remove(1) = %+v, %v", x, err)
+	}
+	s.SetExceptions(e)
+	if v, _ := s.Check(cmd); v != CommandBlocked {
+		t.Error("revoked exception still applies")
+	}
+}
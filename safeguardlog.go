@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// maxSafeguardVerdicts is how many verdicts the log keeps.
+	maxSafeguardVerdicts = 2000
+	// defaultSafeguardReport is how many verdicts /safeguard report exports.
+	defaultSafeguardReport = 500
+)
+
+// SafeguardVerdict records one safeguard check of a command about to run.
+// Only a hash of the command is kept, so the log can be shared for review
+// without leaking what was run.
+type SafeguardVerdict struct {
+	Time        time.Time `json:"time"`
+	ChatID      int64     `json:"chat_id"`
+	Provider    string    `json:"provider"`
+	Blocked     bool      `json:"blocked"`
+	Rule        string    `json:"rule,omitempty"`
+	CommandHash string    `json:"command_hash"`
+}
+
+// SafeguardLog keeps the most recent safeguard verdicts, persisted to
+// DATA_DIR, for periodic security reviews of what the AI attempted.
+type SafeguardLog struct {
+	mu       sync.Mutex
+	path     string
+	verdicts []SafeguardVerdict
+	lastSave time.Time
+}
+
+func NewSafeguardLog(dataDir string) *SafeguardLog {
+	l := &SafeguardLog{path: filepath.Join(dataDir, "safeguard_verdicts.json")}
+	if err := loadJSONFile(l.path, &l.verdicts); err != nil {
+		slog.Warn("failed to load safeguard verdicts", "path", l.path, "err", err)
+	}
+	return l
+}
+
+// commandHash identifies a command without revealing it: the first 16 hex
+// digits of its SHA-256.
+func commandHash(command string) string {
+	sum := sha256.Sum256([]byte(command))
+	return hex.EncodeToString(sum[:8])
+}
+
+// Record adds a verdict. Blocked verdicts are saved at once; allowed ones
+// at most every metricsSaveInterval.
+func (l *SafeguardLog) Record(v SafeguardVerdict) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.verdicts = append(l.verdicts, v)
+	if len(l.verdicts) > maxSafeguardVerdicts {
+		l.verdicts = l.verdicts[len(l.verdicts)-maxSafeguardVerdicts:]
+	}
+	if v.Blocked || time.Since(l.lastSave) >= metricsSaveInterval {
+		l.saveLocked()
+	}
+}
+
+// Save flushes the log to disk.
+func (l *SafeguardLog) Save() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.saveLocked()
+}
+
+func (l *SafeguardLog) saveLocked() {
+	if err := saveJSONFile(l.path, l.verdicts); err != nil {
+		slog.Warn("failed to save safeguard verdicts", "path", l.path, "err", err)
+		return
+	}
+	l.lastSave = time.Now()
+}
+
+// Last returns up to n of the most recent verdicts, oldest first.
+func (l *SafeguardLog) Last(n int) []SafeguardVerdict {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if n > len(l.verdicts) {
+		n = len(l.verdicts)
+	}
+	return append([]SafeguardVerdict(nil), l.verdicts[len(l.verdicts)-n:]...)
+}
+
+// safeguardCSV renders verdicts with a header row.
+func safeguardCSV(verdicts []SafeguardVerdict) []byte {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"time", "chat_id", "provider", "verdict", "rule", "command_hash"})
+	for _, v := range verdicts {
+		verdict := "allowed"
+		if v.Blocked {
+			verdict = "blocked"
+		}
+		w.Write([]string{v.Time.UTC().Format(time.RFC3339), strconv.FormatInt(v.ChatID, 10), v.Provider, verdict, v.Rule, v.CommandHash})
+	}
+	w.Flush()
+	return buf.Bytes()
+}
+
+// recordSafeguardVerdict logs whether the safeguard let cmd run.
+func (h *Handlers) recordSafeguardVerdict(chatID int64, provider, cmd string, blocked bool) {
+	v := SafeguardVerdict{Time: time.Now(), ChatID: chatID, Provider: provider, Blocked: blocked, CommandHash: commandHash(cmd)}
+	if blocked {
+		v.Rule = h.claude.safeguard.Rule(cmd)
+	}
+	h.verdicts.Record(v)
+}
+
+// sendSafeguardReport sends the last verdicts as a CSV attachment (admin only).
+// Usage: /safeguard report [N]
+func (h *Handlers) sendSafeguardReport(chatID int64, args string) {
+	if !h.isAdmin(chatID) {
+		h.sender.SendPlain(chatID, h.t(chatID, "admin_only"))
+		return
+	}
+	n := defaultSafeguardReport
+	if s := strings.TrimSpace(args); s != "" {
+		v, err := strconv.Atoi(s)
+		if err != nil || v <= 0 {
+			h.sender.SendPlain(chatID, h.t(chatID, "safeguard.report_invalid", s))
+			return
+		}
+		n = min(v, maxSafeguardVerdicts)
+	}
+	verdicts := h.verdicts.Last(n)
+	if len(verdicts) == 0 {
+		h.sender.SendPlain(chatID, h.t(chatID, "safeguard.report_none"))
+		return
+	}
+	blocked := 0
+	for _, v := range verdicts {
+		if v.Blocked {
+			blocked++
+		}
+	}
+	name := fmt.Sprintf("safeguard-%s.csv", time.Now().UTC().Format("20060102-150405"))
+	caption := h.t(chatID, "safeguard.report_caption",
+		len(verdicts), verdicts[0].Time.UTC().Format("2006-01-02 15:04 MST"), blocked)
+	slog.Info("safeguard report sent", "chat_id", chatID, "verdicts", len(verdicts), "blocked", blocked)
+	h.sender.SendDocument(chatID, name, safeguardCSV(verdicts), caption)
+}
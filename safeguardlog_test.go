@@ -0,0 +1,45 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSafeguardLog(t *testing.T) {
+	dir := t.TempDir()
+	l := NewSafeguardLog(dir)
+	at := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	l.Record(SafeguardVerdict{Time: at, ChatID: 1, Provider: "claude", CommandHash: commandHash("ls")})
+	l.Record(SafeguardVerdict{Time: at, ChatID: 2, Provider: "gemini", Blocked: true, Rule: "rm-root", CommandHash: commandHash("rm -rf /")})
+
+	l = NewSafeguardLog(dir)
+	got := l.Last(10)
+	if len(got) != 2 || got[1].Rule != "rm-root" {
+		t.Fatalf("reloaded verdicts = %+v", got)
+	}
+	if last := l.Last(1); len(last) != 1 || last[0].ChatID != 2 {
+		t.Errorf("Last(1) = %+v", last)
+	}
+
+	csv := string(safeguardCSV(got))
+	want := "time,chat_id,provider,verdict,rule,command_hash\n" +
+		"2025-03-01T12:00:00Z,1,claude,allowed,," + commandHash("ls") + "\n" +
+		"2025-03-01T12:00:00Z,2,gemini,blocked,rm-root," + commandHash("rm -rf /") + "\n"
+	if csv != want {
+		t.Errorf("csv =\n%s\nwant\n%s", csv, want)
+	}
+	if strings.Contains(csv, "rm -rf") {
+		t.Error("csv leaks the command")
+	}
+}
+
+func TestSafeguardRule(t *testing.T) {
+	s := NewSafeguard()
+	if rule := s.Rule("rm -rf /"); rule == "" {
+		t.Error("rm -rf / not matched by any rule")
+	}
+	if rule := s.Rule("ls -la"); rule != "" {
+		t.Errorf("ls -la matched rule %q", rule)
+	}
+}
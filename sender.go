@@ -1,8 +1,10 @@
 package main
 
 import (
-	"log"
+	"log/slog"
 	"strings"
+	"sync"
+	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
@@ -11,12 +13,27 @@ const maxMessageLength = 4096
 
 // Sender handles sending messages to Telegram with formatting and splitting.
 type Sender struct {
-	api    *tgbotapi.BotAPI
+	api     *tgbotapi.BotAPI
 	secrets []string // strings to redact from outgoing messages
+	mirror  func(chatID int64) []int64
+
+	typingMu  sync.RWMutex
+	noTyping  bool           // typing indicators disabled globally
+	typingOff map[int64]bool // chats that turned typing indicators off
+
+	plainMu sync.RWMutex
+	plain   map[int64]bool // chats in plain-text mode (see toPlainText)
+
+	batch *coalescer // merges small SendPlain messages; nil when disabled
+
+	retries int                      // extra attempts for transient send failures
+	pacer   *sendPacer               // spaces out messages per chat; nil when disabled
+	queue   *sendQueue               // sends paced messages off the caller's goroutine; nil when pacing is off
+	parent  func(chatID int64) int64 // the group a topic belongs to (see SetParentChat)
 }
 
 func NewSender(api *tgbotapi.BotAPI, secrets []string) *Sender {
-	return &Sender{api: api, secrets: secrets}
+	return &Sender{api: api, secrets: secrets, typingOff: make(map[int64]bool), plain: make(map[int64]bool)}
 }
 
 // redact replaces any secret values in text with "[REDACTED]".
@@ -29,82 +46,293 @@ func (s *Sender) redact(text string) string {
 	return text
 }
 
+// SetCoalesce merges small plain messages sent to a chat within window into
+// one message. A zero window disables merging.
+func (s *Sender) SetCoalesce(window time.Duration) {
+	if window > 0 {
+		s.batch = newCoalescer(window, s.sendPlainNow)
+	}
+}
+
+// flush sends any merged messages still buffered for chatID, so that a
+// message sent by other means does not overtake them.
+func (s *Sender) flush(chatID int64) {
+	if s.batch != nil {
+		s.batch.Flush(chatID)
+	}
+}
+
+// FlushAll sends every buffered and queued message; call before shutting
+// down.
+func (s *Sender) FlushAll() {
+	if s.batch != nil {
+		s.batch.FlushAll()
+	}
+	if s.queue != nil {
+		s.queue.drain()
+	}
+}
+
+// SetMirror registers a function returning extra chats that should receive
+// a copy of everything sent with Send to chatID (shared sessions).
+func (s *Sender) SetMirror(mirror func(chatID int64) []int64) {
+	s.mirror = mirror
+}
+
 // Send sends text to a chat, converting to MarkdownV2 with plain-text fallback.
-// Long messages are split at newline/space boundaries.
+// Long messages are split at newline/space boundaries. The text is also
+// mirrored to any chats the session is shared with.
 func (s *Sender) Send(chatID int64, text string) {
+	s.sendFormatted(chatID, text)
+	if s.mirror != nil {
+		for _, id := range s.mirror(chatID) {
+			s.sendFormatted(id, text)
+		}
+	}
+}
+
+// sendFormatted does the actual MarkdownV2 send for Send.
+func (s *Sender) sendFormatted(chatID int64, text string) {
+	s.flush(chatID)
+	if s.PlainMode(chatID) {
+		s.sendPlainNow(chatID, toPlainText(text))
+		return
+	}
 	text = s.redact(text)
+
 	chunks := splitMessage(text, maxMessageLength)
 
 	for i, chunk := range chunks {
-		formatted := ToTelegramMarkdownV2(chunk)
-		msg := tgbotapi.NewMessage(chatID, formatted)
-		msg.ParseMode = tgbotapi.ModeMarkdownV2
-
-		_, err := s.api.Send(msg)
-		if err != nil {
-			log.Printf("MarkdownV2 send failed (chunk %d): %v; falling back to plain text", i, err)
-			msg := tgbotapi.NewMessage(chatID, chunk)
-			if _, err := s.api.Send(msg); err != nil {
-				log.Printf("plain text send also failed (chunk %d): %v", i, err)
+		s.post(chatID, func() {
+			msg := tgbotapi.NewMessage(chatID, ToTelegramMarkdownV2(chunk))
+			msg.ParseMode = tgbotapi.ModeMarkdownV2
+			if _, err := s.send(chatID, msg); err != nil {
+				slog.Debug("MarkdownV2 send failed, falling back to plain text", "chat_id", chatID, "chunk", i, "err", err)
+				msg := tgbotapi.NewMessage(chatID, chunk)
+				if _, err := s.send(chatID, msg); err != nil {
+					slog.Error("plain text send also failed", "chat_id", chatID, "chunk", i, "err", err)
+				}
 			}
-		}
+		})
 	}
 }
 
-// SendTyping sends a "typing..." indicator to the chat.
+// SendTyping sends a "typing..." indicator to the chat, unless typing
+// indicators are disabled globally or for the chat.
 func (s *Sender) SendTyping(chatID int64) {
+	if !s.TypingEnabled(chatID) {
+		return
+	}
 	action := tgbotapi.NewChatAction(chatID, tgbotapi.ChatTyping)
 	s.api.Send(action)
 }
 
-// SendPlain sends a plain text message without any formatting.
+// DisableTyping turns typing indicators off for every chat.
+func (s *Sender) DisableTyping() {
+	s.typingMu.Lock()
+	defer s.typingMu.Unlock()
+	s.noTyping = true
+}
+
+// SetTyping turns typing indicators on or off for one chat.
+func (s *Sender) SetTyping(chatID int64, enabled bool) {
+	s.typingMu.Lock()
+	defer s.typingMu.Unlock()
+	if enabled {
+		delete(s.typingOff, chatID)
+	} else {
+		s.typingOff[chatID] = true
+	}
+}
+
+// TypingEnabled reports whether typing indicators are sent to the chat.
+func (s *Sender) TypingEnabled(chatID int64) bool {
+	s.typingMu.RLock()
+	defer s.typingMu.RUnlock()
+	return !s.noTyping && !s.typingOff[chatID]
+}
+
+// SetPlainMode turns plain-text mode on or off for one chat.
+func (s *Sender) SetPlainMode(chatID int64, on bool) {
+	s.plainMu.Lock()
+	defer s.plainMu.Unlock()
+	if on {
+		s.plain[chatID] = true
+	} else {
+		delete(s.plain, chatID)
+	}
+}
+
+// PlainMode reports whether the chat is in plain-text mode.
+func (s *Sender) PlainMode(chatID int64) bool {
+	s.plainMu.RLock()
+	defer s.plainMu.RUnlock()
+	return s.plain[chatID]
+}
+
+// plainKeyboardFor strips emojis from button labels for chats in plain-text mode.
+func (s *Sender) plainKeyboardFor(chatID int64, keyboard tgbotapi.InlineKeyboardMarkup) tgbotapi.InlineKeyboardMarkup {
+	if s.PlainMode(chatID) {
+		return plainKeyboard(keyboard)
+	}
+	return keyboard
+}
+
+// plainFor strips emojis from text for chats in plain-text mode.
+func (s *Sender) plainFor(chatID int64, text string) string {
+	if s.PlainMode(chatID) {
+		return stripEmoji(text)
+	}
+	return text
+}
+
+// SendPlain sends a plain text message without any formatting. Small
+// messages may be merged with others sent shortly after (see SetCoalesce).
 func (s *Sender) SendPlain(chatID int64, text string) {
-	text = s.redact(text)
+	if s.batch != nil {
+		if s.batch.Add(chatID, text) {
+			return
+		}
+		s.batch.Flush(chatID)
+	}
+	s.sendPlainNow(chatID, text)
+}
+
+// sendPlainNow sends a plain text message immediately, split if needed.
+func (s *Sender) sendPlainNow(chatID int64, text string) {
+	text = s.redact(s.plainFor(chatID, text))
 	for _, chunk := range splitMessage(text, maxMessageLength) {
 		msg := tgbotapi.NewMessage(chatID, chunk)
-		if _, err := s.api.Send(msg); err != nil {
-			log.Printf("send failed: %v", err)
-		}
+		s.post(chatID, func() {
+			if _, err := s.send(chatID, msg); err != nil {
+				slog.Error("send failed", "chat_id", chatID, "err", err)
+			}
+		})
 	}
 }
 
-// AnswerCallback acknowledges a callback query with optional text.
+// SendPinned sends a plain text message and pins it silently.
+func (s *Sender) SendPinned(chatID int64, text string) {
+	s.flush(chatID)
+	msg := tgbotapi.NewMessage(chatID, s.redact(s.plainFor(chatID, text)))
+	s.post(chatID, func() {
+		sent, err := s.send(chatID, msg)
+		if err != nil {
+			slog.Error("send failed", "chat_id", chatID, "err", err)
+			return
+		}
+		pin := tgbotapi.PinChatMessageConfig{ChatID: chatID, MessageID: sent.MessageID, DisableNotification: true}
+		if err := s.do(chatID, func() error { _, err := s.api.Request(pin); return err }); err != nil {
+			slog.Warn("pin message failed", "chat_id", chatID, "err", err)
+		}
+	})
+}
+
+// AnswerCallback acknowledges a callback query with optional text. An empty
+// callbackID (a decision made from the reply keyboard) is ignored.
 func (s *Sender) AnswerCallback(callbackID, text string) {
+	if callbackID == "" {
+		return
+	}
 	cb := tgbotapi.NewCallback(callbackID, text)
 	if _, err := s.api.Request(cb); err != nil {
-		log.Printf("answer callback failed: %v", err)
+		slog.Warn("answer callback failed", "err", err)
 	}
 }
 
 // SendWithKeyboard sends a message with inline keyboard buttons. Returns the message ID.
 func (s *Sender) SendWithKeyboard(chatID int64, text string, keyboard tgbotapi.InlineKeyboardMarkup) int {
+	s.flush(chatID)
+	if s.PlainMode(chatID) {
+		return s.SendPlainWithKeyboard(chatID, toPlainText(text), keyboard)
+	}
 	text = s.redact(text)
 	msg := tgbotapi.NewMessage(chatID, text)
 	msg.ReplyMarkup = keyboard
 	msg.ParseMode = tgbotapi.ModeMarkdownV2
 
-	sent, err := s.api.Send(msg)
-	if err != nil {
-		// Fallback without MarkdownV2
-		msg.ParseMode = ""
-		sent, err = s.api.Send(msg)
-		if err != nil {
-			log.Printf("send with keyboard failed: %v", err)
-			return 0
+	var sent tgbotapi.Message
+	var err error
+	s.wait(chatID, func() {
+		if sent, err = s.send(chatID, msg); err != nil {
+			// Fallback without MarkdownV2
+			msg.ParseMode = ""
+			sent, err = s.send(chatID, msg)
 		}
+	})
+	if err != nil {
+		slog.Error("send with keyboard failed", "chat_id", chatID, "err", err)
+		return 0
 	}
 	return sent.MessageID
 }
 
+// SendReplyMarkup sends a plain text message that sets or removes the
+// chat's reply keyboard.
+func (s *Sender) SendReplyMarkup(chatID int64, text string, markup any) {
+	s.flush(chatID)
+	msg := tgbotapi.NewMessage(chatID, s.redact(s.plainFor(chatID, text)))
+	msg.ReplyMarkup = markup
+	s.post(chatID, func() {
+		if _, err := s.send(chatID, msg); err != nil {
+			slog.Error("send reply keyboard failed", "chat_id", chatID, "err", err)
+		}
+	})
+}
+
 // EditRemoveKeyboard edits a message to show new text and removes the inline keyboard.
 func (s *Sender) EditRemoveKeyboard(chatID int64, messageID int, newText string) {
-	newText = s.redact(newText)
+	newText = s.redact(s.plainFor(chatID, newText))
 	edit := tgbotapi.NewEditMessageText(chatID, messageID, newText)
 	emptyMarkup := tgbotapi.InlineKeyboardMarkup{InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{}}
 	edit.ReplyMarkup = &emptyMarkup
-	if _, err := s.api.Send(edit); err != nil {
-		log.Printf("edit remove keyboard failed: %v", err)
+	s.post(chatID, func() {
+		if _, err := s.send(chatID, edit); err != nil {
+			slog.Warn("edit remove keyboard failed", "chat_id", chatID, "err", err)
+		}
+	})
+}
+
+// SendPlainWithKeyboard sends a plain text message with inline keyboard
+// buttons, bypassing coalescing. Returns the message ID, or 0 on failure.
+func (s *Sender) SendPlainWithKeyboard(chatID int64, text string, keyboard tgbotapi.InlineKeyboardMarkup) int {
+	s.flush(chatID)
+	msg := tgbotapi.NewMessage(chatID, s.redact(s.plainFor(chatID, text)))
+	msg.ReplyMarkup = s.plainKeyboardFor(chatID, keyboard)
+	var sent tgbotapi.Message
+	var err error
+	s.wait(chatID, func() { sent, err = s.send(chatID, msg) })
+	if err != nil {
+		slog.Error("send with keyboard failed", "chat_id", chatID, "err", err)
+		return 0
+	}
+	return sent.MessageID
+}
+
+// EditPlain replaces a message's text, keeping keyboard as its inline
+// keyboard (nil removes it).
+func (s *Sender) EditPlain(chatID int64, messageID int, text string, keyboard *tgbotapi.InlineKeyboardMarkup) {
+	edit := tgbotapi.NewEditMessageText(chatID, messageID, s.redact(s.plainFor(chatID, text)))
+	markup := tgbotapi.InlineKeyboardMarkup{InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{}}
+	if keyboard != nil {
+		markup = s.plainKeyboardFor(chatID, *keyboard)
 	}
+	edit.ReplyMarkup = &markup
+	s.post(chatID, func() {
+		if _, err := s.send(chatID, edit); err != nil {
+			slog.Warn("edit message failed", "chat_id", chatID, "err", err)
+		}
+	})
+}
+
+// DeleteMessage deletes a message the bot sent.
+func (s *Sender) DeleteMessage(chatID int64, messageID int) {
+	del := tgbotapi.NewDeleteMessage(chatID, messageID)
+	s.post(chatID, func() {
+		if err := s.do(chatID, func() error { _, err := s.api.Request(del); return err }); err != nil {
+			slog.Warn("delete message failed", "chat_id", chatID, "err", err)
+		}
+	})
 }
 
 // splitMessage splits text into chunks respecting maxLen.
@@ -135,3 +363,27 @@ func splitMessage(text string, maxLen int) []string {
 	}
 	return chunks
 }
+
+// SendVoice sends OGG/Opus audio as a voice note.
+func (s *Sender) SendVoice(chatID int64, audio []byte) {
+	s.flush(chatID)
+	voice := tgbotapi.NewVoice(chatID, tgbotapi.FileBytes{Name: "reply.ogg", Bytes: audio})
+	s.post(chatID, func() {
+		if _, err := s.send(chatID, voice); err != nil {
+			slog.Error("send voice failed", "chat_id", chatID, "err", err)
+		}
+	})
+}
+
+// SendDocument uploads data as a file attachment with an optional caption.
+// Secrets are redacted from the contents like any other outgoing text.
+func (s *Sender) SendDocument(chatID int64, name string, data []byte, caption string) {
+	s.flush(chatID)
+	doc := tgbotapi.NewDocument(chatID, tgbotapi.FileBytes{Name: name, Bytes: []byte(s.redact(string(data)))})
+	doc.Caption = s.redact(s.plainFor(chatID, caption))
+	s.post(chatID, func() {
+		if _, err := s.send(chatID, doc); err != nil {
+			slog.Error("send document failed", "chat_id", chatID, "file", name, "err", err)
+		}
+	})
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSplitMessage(t *testing.T) {
+	text := strings.Repeat("line of output\n", 1000)
+	chunks := splitMessage(text, 4096)
+	if got := strings.Join(chunks, ""); got != text {
+		t.Fatalf("chunks do not join back to the input")
+	}
+	for i, c := range chunks {
+		if len(c) > 4096 {
+			t.Errorf("chunk %d is %d bytes, over the limit", i, len(c))
+		}
+		if i < len(chunks)-1 && !strings.HasSuffix(c, "\n") {
+			t.Errorf("chunk %d does not end at a newline", i)
+		}
+	}
+}
+
+func BenchmarkSplitMessage(b *testing.B) {
+	text := largeOutput(100 << 10)
+	b.SetBytes(int64(len(text)))
+	for i := 0; i < b.N; i++ {
+		splitMessage(text, 4096)
+	}
+}
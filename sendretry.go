@@ -0,0 +1,244 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"net/url"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Telegram asks bots to stay under about one message per second per chat,
+// 20 per minute in groups and 30 per second overall.
+const (
+	groupPaceFactor = 3
+	globalPace      = time.Second / 30
+	maxSendBackoff  = 30 * time.Second
+)
+
+// sendPacer spaces out messages so bursts (split replies, auto-execute
+// output) queue up instead of hitting 429s. Each send reserves the next free
+// slot for its chat and globally, then sleeps until that slot. Chats are
+// the ones Telegram sees: a forum topic is paced with its group.
+type sendPacer struct {
+	perChat time.Duration
+
+	mu     sync.Mutex
+	next   map[int64]time.Time
+	global time.Time
+}
+
+func newSendPacer(perChat time.Duration) *sendPacer {
+	return &sendPacer{perChat: perChat, next: make(map[int64]time.Time)}
+}
+
+// reserve claims the next send slot for chatID and returns how long to wait
+// for it.
+func (p *sendPacer) reserve(chatID int64, now time.Time) time.Duration {
+	interval := p.perChat
+	if chatID < 0 {
+		interval *= groupPaceFactor
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	global := now
+	if p.global.After(global) {
+		global = p.global
+	}
+	p.global = global.Add(globalPace)
+	slot := global
+	if t := p.next[chatID]; t.After(slot) {
+		slot = t
+	}
+	p.next[chatID] = slot.Add(interval)
+	// Forget idle chats so the map does not grow without bound.
+	for id, t := range p.next {
+		if t.Before(now) {
+			delete(p.next, id)
+		}
+	}
+	return slot.Sub(now)
+}
+
+// hold pushes the chat's next slot to until, after Telegram answered 429.
+func (p *sendPacer) hold(chatID int64, until time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if until.After(p.next[chatID]) {
+		p.next[chatID] = until
+	}
+}
+
+// retryDelay reports whether a failed send should be retried and after how
+// long: 429s after Telegram's retry_after, 5xx and network errors with
+// exponential backoff. Other API errors (bad markup, blocked bot, ...) are
+// final.
+func retryDelay(err error, attempt int) (time.Duration, bool) {
+	var apiErr *tgbotapi.Error
+	if errors.As(err, &apiErr) {
+		if apiErr.RetryAfter > 0 {
+			return time.Duration(apiErr.RetryAfter) * time.Second, true
+		}
+		if apiErr.Code >= 500 {
+			return sendBackoff(attempt), true
+		}
+		return 0, false
+	}
+	var netErr *url.Error
+	if errors.As(err, &netErr) {
+		return sendBackoff(attempt), true
+	}
+	return 0, false
+}
+
+func sendBackoff(attempt int) time.Duration {
+	d := 500 * time.Millisecond << attempt
+	if d <= 0 || d > maxSendBackoff {
+		return maxSendBackoff
+	}
+	return d
+}
+
+// sendQueue runs each chat's sends in order on a goroutine of its own, so
+// pacing and retries wait there instead of in the handler that sent them,
+// which usually holds the chat lock. A chat's goroutine exits once its
+// queue is empty.
+type sendQueue struct {
+	mu   sync.Mutex
+	idle *sync.Cond
+	jobs map[int64][]func() // chat → pending sends; present while its goroutine runs
+}
+
+func newSendQueue() *sendQueue {
+	q := &sendQueue{jobs: make(map[int64][]func())}
+	q.idle = sync.NewCond(&q.mu)
+	return q
+}
+
+// push appends job to chatID's queue, starting its goroutine if needed.
+func (q *sendQueue) push(chatID int64, job func()) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	jobs, running := q.jobs[chatID]
+	q.jobs[chatID] = append(jobs, job)
+	if !running {
+		go q.run(chatID)
+	}
+}
+
+func (q *sendQueue) run(chatID int64) {
+	for {
+		q.mu.Lock()
+		jobs := q.jobs[chatID]
+		if len(jobs) == 0 {
+			delete(q.jobs, chatID)
+			q.idle.Broadcast()
+			q.mu.Unlock()
+			return
+		}
+		q.jobs[chatID] = jobs[1:]
+		q.mu.Unlock()
+		jobs[0]()
+	}
+}
+
+// drain waits until every queued send is done.
+func (q *sendQueue) drain() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for len(q.jobs) > 0 {
+		q.idle.Wait()
+	}
+}
+
+// chatOf returns the chat Telegram delivers chatID's messages to, whose
+// rate limit they count against.
+func (s *Sender) chatOf(chatID int64) int64 {
+	if s.parent == nil {
+		return chatID
+	}
+	return s.parent(chatID)
+}
+
+// post runs job, one or more sends to chatID, after the chat's earlier
+// sends, without waiting for it. Without pacing it runs at once.
+func (s *Sender) post(chatID int64, job func()) {
+	if s.queue == nil {
+		job()
+		return
+	}
+	s.queue.push(s.chatOf(chatID), job)
+}
+
+// wait is post for sends whose result the caller needs: it returns once
+// job has run.
+func (s *Sender) wait(chatID int64, job func()) {
+	if s.queue == nil {
+		job()
+		return
+	}
+	done := make(chan struct{})
+	s.queue.push(s.chatOf(chatID), func() {
+		defer close(done)
+		job()
+	})
+	<-done
+}
+
+// do runs one Telegram call for chatID, paced and retried per the sender's
+// settings. Only the final error is returned. With pacing on it must run
+// on the chat's queue (see post).
+func (s *Sender) do(chatID int64, call func() error) error {
+	for attempt := 0; ; attempt++ {
+		if s.pacer != nil {
+			if wait := s.pacer.reserve(s.chatOf(chatID), time.Now()); wait > 0 {
+				time.Sleep(wait)
+			}
+		}
+		err := call()
+		if err == nil {
+			return nil
+		}
+		delay, retry := retryDelay(err, attempt)
+		if !retry || attempt >= s.retries {
+			return err
+		}
+		slog.Warn("telegram send failed, retrying", "chat_id", chatID, "attempt", attempt+1, "delay", delay, "err", err)
+		if s.pacer != nil {
+			s.pacer.hold(s.chatOf(chatID), time.Now().Add(delay))
+		} else {
+			time.Sleep(delay)
+		}
+	}
+}
+
+// send is api.Send through do, for calls that return a Message.
+func (s *Sender) send(chatID int64, c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	var msg tgbotapi.Message
+	err := s.do(chatID, func() error {
+		var err error
+		msg, err = s.api.Send(c)
+		return err
+	})
+	return msg, err
+}
+
+// SetRetry configures send retries and per-chat pacing. retries is the number
+// of extra attempts for transient failures; a zero pace disables pacing,
+// and with it the send queues.
+func (s *Sender) SetRetry(retries int, pace time.Duration) {
+	s.retries = retries
+	if pace > 0 {
+		s.pacer = newSendPacer(pace)
+		s.queue = newSendQueue()
+	}
+}
+
+// SetParentChat registers a function mapping a forum topic or member
+// conversation to its group, so they are paced and queued as the one chat
+// Telegram rate-limits.
+func (s *Sender) SetParentChat(parent func(chatID int64) int64) {
+	s.parent = parent
+}
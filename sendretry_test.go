@@ -0,0 +1,104 @@
+package main
+
+import (
+	"errors"
+	"net/url"
+	"slices"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestRetryDelay(t *testing.T) {
+	tests := []struct {
+		name  string
+		err   error
+		want  time.Duration
+		retry bool
+	}{
+		{"429", &tgbotapi.Error{Code: 429, ResponseParameters: tgbotapi.ResponseParameters{RetryAfter: 7}}, 7 * time.Second, true},
+		{"5xx", &tgbotapi.Error{Code: 502}, time.Second, true},
+		{"network", &url.Error{Op: "Post", URL: "https://api.telegram.org", Err: errors.New("connection reset")}, time.Second, true},
+		{"bad request", &tgbotapi.Error{Code: 400, Message: "can't parse entities"}, 0, false},
+		{"decode", errors.New("json: cannot unmarshal bool"), 0, false},
+	}
+	for _, tt := range tests {
+		got, retry := retryDelay(tt.err, 1)
+		if got != tt.want || retry != tt.retry {
+			t.Errorf("%s: retryDelay = %v, %v; want %v, %v", tt.name, got, retry, tt.want, tt.retry)
+		}
+	}
+	if d := sendBackoff(40); d != maxSendBackoff {
+		t.Errorf("sendBackoff(40) = %v, want cap %v", d, maxSendBackoff)
+	}
+}
+
+func TestSendPacer(t *testing.T) {
+	p := newSendPacer(time.Second)
+	now := time.Unix(1000, 0)
+	if w := p.reserve(1, now); w != 0 {
+		t.Errorf("first send waits %v", w)
+	}
+	if w := p.reserve(1, now); w != time.Second {
+		t.Errorf("second send to the chat waits %v, want 1s", w)
+	}
+	if w := p.reserve(2, now); w != 2*globalPace {
+		t.Errorf("other chat waits %v, want only the global gap", w)
+	}
+	if w := p.reserve(-5, now); w != 3*globalPace {
+		t.Errorf("group first send waits %v", w)
+	}
+	if w := p.reserve(-5, now); w != 3*globalPace+3*time.Second {
+		t.Errorf("group second send waits %v, want 3s after the first", w)
+	}
+
+	p.hold(1, now.Add(10*time.Second))
+	if w := p.reserve(1, now); w != 10*time.Second {
+		t.Errorf("send after 429 waits %v, want 10s", w)
+	}
+}
+
+func TestSendQueue(t *testing.T) {
+	tg := &fakeTelegram{}
+	api, err := tgbotapi.NewBotAPIWithClient("token", tgbotapi.APIEndpoint, tg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewSender(api, nil)
+	s.SetRetry(0, 20*time.Millisecond)
+	// Topics 1 and 2 are in group -100, so they share its 60ms pace.
+	s.SetParentChat(func(id int64) int64 {
+		if id == 1 || id == 2 {
+			return -100
+		}
+		return id
+	})
+
+	start := time.Now()
+	s.SendPlain(1, "first")
+	s.SendPlain(2, "second")
+	s.SendPlain(1, "third")
+	if d := time.Since(start); d > 30*time.Millisecond {
+		t.Errorf("SendPlain waited %v for pacing", d)
+	}
+	if id := s.SendPlainWithKeyboard(2, "card", tgbotapi.NewInlineKeyboardMarkup()); id != 100 {
+		t.Errorf("SendPlainWithKeyboard = %d, want the sent message's ID", id)
+	}
+	s.FlushAll()
+	if d := time.Since(start); d < 3*3*20*time.Millisecond {
+		t.Errorf("4 sends to one group took %v, want them paced as one chat", d)
+	}
+
+	tg.mu.Lock()
+	defer tg.mu.Unlock()
+	var texts []string
+	for i, form := range tg.sent {
+		if tg.calls[i] == "sendMessage" {
+			texts = append(texts, form.Get("text"))
+		}
+	}
+	if want := []string{"first", "second", "third", "card"}; !slices.Equal(texts, want) {
+		t.Errorf("sent %q, want %q in order", texts, want)
+	}
+}
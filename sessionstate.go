@@ -0,0 +1,92 @@
+package main
+
+import (
+	"log/slog"
+	"path/filepath"
+)
+
+// SessionState carries the in-memory chat state that would otherwise be
+// lost on restart across it: the histories the bot keeps for Gemini and
+// the other stateless providers, and each chat's active provider. It is
+// written once on shutdown and read on startup, so a crash still loses it.
+type SessionState struct {
+	historyPath   string
+	providersPath string
+	maxBytes      int // per chat history; 0 = nothing is saved
+}
+
+func NewSessionState(dataDir string, maxBytes int) *SessionState {
+	return &SessionState{
+		historyPath:   filepath.Join(dataDir, "gemini_sessions.json"),
+		providersPath: filepath.Join(dataDir, "providers.json"),
+		maxBytes:      maxBytes,
+	}
+}
+
+// Load restores what the last Save wrote into the stores.
+func (s *SessionState) Load(sessions *GeminiSessionStore, providers *ProviderStore) {
+	if s.maxBytes <= 0 {
+		return
+	}
+	var histories map[int64][]GeminiMessage
+	if err := loadJSONFile(s.historyPath, &histories); err != nil {
+		slog.Warn("failed to load gemini sessions", "path", s.historyPath, "err", err)
+	}
+	for chatID, msgs := range histories {
+		sessions.Replace(chatID, msgs)
+	}
+	var active map[int64]string
+	if err := loadJSONFile(s.providersPath, &active); err != nil {
+		slog.Warn("failed to load providers", "path", s.providersPath, "err", err)
+	}
+	for chatID, provider := range active {
+		providers.Set(chatID, provider)
+	}
+	if len(histories) > 0 || len(active) > 0 {
+		slog.Info("restored session state", "histories", len(histories), "providers", len(active))
+	}
+}
+
+// Save writes the stores, each chat's history capped at maxBytes.
+func (s *SessionState) Save(sessions *GeminiSessionStore, providers *ProviderStore) {
+	if s.maxBytes <= 0 {
+		return
+	}
+	histories := sessions.Snapshot()
+	for chatID, msgs := range histories {
+		if msgs = capHistory(msgs, s.maxBytes); len(msgs) > 0 {
+			histories[chatID] = msgs
+		} else {
+			delete(histories, chatID)
+		}
+	}
+	if err := saveJSONFile(s.historyPath, histories); err != nil {
+		slog.Warn("failed to save gemini sessions", "path", s.historyPath, "err", err)
+	}
+	active := providers.Snapshot()
+	if err := saveJSONFile(s.providersPath, active); err != nil {
+		slog.Warn("failed to save providers", "path", s.providersPath, "err", err)
+	}
+	slog.Info("saved session state", "histories", len(histories), "providers", len(active))
+}
+
+// capHistory drops the oldest user/model pairs until the contents of
+// history fit in maxBytes.
+func capHistory(history []GeminiMessage, maxBytes int) []GeminiMessage {
+	size := 0
+	for _, m := range history {
+		size += len(m.Content)
+	}
+	i := 0
+	for i < len(history) && size > maxBytes {
+		size -= len(history[i].Content)
+		if i+1 < len(history) {
+			size -= len(history[i+1].Content)
+		}
+		i += 2
+	}
+	if i >= len(history) {
+		return nil
+	}
+	return history[i:]
+}
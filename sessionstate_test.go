@@ -0,0 +1,53 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSessionStateRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	sessions, providers := NewGeminiSessionStore(), NewProviderStore("claude")
+	sessions.Append(1, GeminiMessage{Role: "user", Content: "hi"}, GeminiMessage{Role: "model", Content: "hello"})
+	sessions.Append(2, GeminiMessage{Role: "user", Content: strings.Repeat("x", 100)}, GeminiMessage{Role: "model", Content: "ok"},
+		GeminiMessage{Role: "user", Content: "second"}, GeminiMessage{Role: "model", Content: "done"})
+	providers.Set(1, "gemini")
+	NewSessionState(dir, 50).Save(sessions, providers)
+
+	sessions, providers = NewGeminiSessionStore(), NewProviderStore("claude")
+	NewSessionState(dir, 50).Load(sessions, providers)
+	if got := sessions.Get(1); len(got) != 2 || got[1].Content != "hello" {
+		t.Errorf("chat 1 history = %+v", got)
+	}
+	if got := sessions.Get(2); len(got) != 2 || got[0].Content != "second" {
+		t.Errorf("chat 2 history not capped to its latest pair: %+v", got)
+	}
+	if providers.Get(1) != "gemini" || providers.Get(2) != "claude" {
+		t.Errorf("providers = %q, %q", providers.Get(1), providers.Get(2))
+	}
+}
+
+func TestSessionStateDisabled(t *testing.T) {
+	dir := t.TempDir()
+	sessions, providers := NewGeminiSessionStore(), NewProviderStore("claude")
+	sessions.Append(1, GeminiMessage{Role: "user", Content: "hi"})
+	NewSessionState(dir, 0).Save(sessions, providers)
+	sessions = NewGeminiSessionStore()
+	NewSessionState(dir, 100).Load(sessions, providers)
+	if got := sessions.Get(1); len(got) != 0 {
+		t.Errorf("history saved with saving disabled: %+v", got)
+	}
+}
+
+func TestCapHistory(t *testing.T) {
+	h := []GeminiMessage{{Content: "aaaa"}, {Content: "bb"}, {Content: "cc"}, {Content: "d"}}
+	if got := capHistory(h, 9); len(got) != 4 {
+		t.Errorf("under the cap: kept %d", len(got))
+	}
+	if got := capHistory(h, 5); len(got) != 2 || got[0].Content != "cc" {
+		t.Errorf("over the cap: got %+v", got)
+	}
+	if got := capHistory(h, 1); got != nil {
+		t.Errorf("nothing fits: got %+v", got)
+	}
+}
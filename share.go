@@ -0,0 +1,201 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ShareMode controls what a guest chat may do with a shared session.
+type ShareMode string
+
+const (
+	ShareReadOnly ShareMode = "readonly" // sees AI responses and approval cards
+	ShareCollab   ShareMode = "collab"   // can also send prompts and approve/deny
+)
+
+// ShareStore tracks which guest chats have access to which owner's session.
+// A guest can be attached to at most one owner at a time. Shares are
+// persisted to DATA_DIR so a restart neither revokes nor forgets them.
+type ShareStore struct {
+	mu     sync.RWMutex
+	path   string
+	guests map[int64]map[int64]ShareMode // owner → guest → mode
+	owners map[int64]int64               // guest → owner
+}
+
+func NewShareStore(dataDir string) *ShareStore {
+	s := &ShareStore{
+		path:   filepath.Join(dataDir, "shares.json"),
+		guests: make(map[int64]map[int64]ShareMode),
+		owners: make(map[int64]int64),
+	}
+	if err := loadJSONFile(s.path, &s.guests); err != nil {
+		slog.Warn("failed to load shared sessions", "path", s.path, "err", err)
+	}
+	for owner, guests := range s.guests {
+		for guest := range guests {
+			s.owners[guest] = owner
+		}
+	}
+	return s
+}
+
+// save writes the shares to disk. The caller must hold mu.
+func (s *ShareStore) save() {
+	if err := saveJSONFile(s.path, s.guests); err != nil {
+		slog.Error("failed to save shared sessions", "path", s.path, "err", err)
+	}
+}
+
+// Share grants guest access to owner's session, replacing any previous share of guest.
+func (s *ShareStore) Share(owner, guest int64, mode ShareMode) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if prev, ok := s.owners[guest]; ok {
+		delete(s.guests[prev], guest)
+	}
+	if s.guests[owner] == nil {
+		s.guests[owner] = make(map[int64]ShareMode)
+	}
+	s.guests[owner][guest] = mode
+	s.owners[guest] = owner
+	s.save()
+}
+
+// Unshare revokes guest's access to owner's session. Returns false if not shared.
+func (s *ShareStore) Unshare(owner, guest int64) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.guests[owner][guest]; !ok {
+		return false
+	}
+	delete(s.guests[owner], guest)
+	if len(s.guests[owner]) == 0 {
+		delete(s.guests, owner)
+	}
+	delete(s.owners, guest)
+	s.save()
+	return true
+}
+
+// Guests returns the guest chats of owner with their modes, sorted by chat ID.
+func (s *ShareStore) Guests(owner int64) []int64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	ids := make([]int64, 0, len(s.guests[owner]))
+	for id := range s.guests[owner] {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+// Mode returns the share mode of guest within owner's session.
+func (s *ShareStore) Mode(owner, guest int64) (ShareMode, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	m, ok := s.guests[owner][guest]
+	return m, ok
+}
+
+// CollabOwner returns the owner whose session guest collaborates on, if any.
+func (s *ShareStore) CollabOwner(guest int64) (int64, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	owner, ok := s.owners[guest]
+	if !ok || s.guests[owner][guest] != ShareCollab {
+		return 0, false
+	}
+	return owner, true
+}
+
+// HandleShare grants another allowed chat access to this chat's session (admin only).
+// Usage: /share <chatID> [readonly|collab]. Without arguments it lists current shares.
+func (h *Handlers) HandleShare(chatID int64, args string) {
+	if !h.isAdmin(chatID) {
+		h.sender.SendPlain(chatID, h.t(chatID, "admin_only"))
+		return
+	}
+
+	a, ok := h.parseCommandArgs(chatID, "share", args)
+	if !ok {
+		return
+	}
+	if a.Len() == 0 {
+		guests := h.shares.Guests(chatID)
+		if len(guests) == 0 {
+			h.sender.SendPlain(chatID, h.t(chatID, "share.none"))
+			return
+		}
+		var b strings.Builder
+		b.WriteString(h.t(chatID, "share.list") + "\n")
+		for _, g := range guests {
+			mode, _ := h.shares.Mode(chatID, g)
+			fmt.Fprintf(&b, "  %d (%s)\n", g, mode)
+		}
+		h.sender.SendPlain(chatID, b.String())
+		return
+	}
+
+	guest, err := a.ChatID(0)
+	if err == nil && guest == chatID {
+		err = fmt.Errorf("cannot share a session with itself")
+	}
+	if err != nil {
+		h.sendArgError(chatID, "share", err)
+		return
+	}
+	if !h.IsAllowed(guest) {
+		h.sender.SendPlain(chatID, h.t(chatID, "not_allowed_chat", guest))
+		return
+	}
+	mode := ShareReadOnly
+	if a.Len() > 1 {
+		switch ShareMode(strings.ToLower(a.Arg(1))) {
+		case ShareReadOnly, "ro":
+			mode = ShareReadOnly
+		case ShareCollab:
+			mode = ShareCollab
+		default:
+			h.sender.SendPlain(chatID, h.t(chatID, "share.unknown_mode", a.Arg(1)))
+			return
+		}
+	}
+
+	h.shares.Share(chatID, guest, mode)
+	slog.Info("session shared", "chat_id", chatID, "guest_chat_id", guest, "mode", mode)
+	h.sender.SendPlain(chatID, h.t(chatID, "share.done", guest, mode, guest))
+	if mode == ShareCollab {
+		h.sender.SendPlain(guest, h.t(guest, "share.collab_notice", chatID))
+	} else {
+		h.sender.SendPlain(guest, h.t(guest, "share.readonly_notice", chatID))
+	}
+}
+
+// HandleUnshare revokes a guest chat's access to this chat's session (admin only).
+func (h *Handlers) HandleUnshare(chatID int64, args string) {
+	if !h.isAdmin(chatID) {
+		h.sender.SendPlain(chatID, h.t(chatID, "admin_only"))
+		return
+	}
+	a, ok := h.parseCommandArgs(chatID, "unshare", args)
+	if !ok {
+		return
+	}
+	guest, err := a.ChatID(0)
+	if err != nil {
+		h.sendArgError(chatID, "unshare", err)
+		return
+	}
+	if !h.shares.Unshare(chatID, guest) {
+		h.sender.SendPlain(chatID, h.t(chatID, "unshare.not_shared", guest))
+		return
+	}
+	slog.Info("session unshared", "chat_id", chatID, "guest_chat_id", guest)
+	h.sender.SendPlain(chatID, h.t(chatID, "unshare.done", guest))
+	h.sender.SendPlain(guest, h.t(guest, "unshare.notice", chatID))
+}
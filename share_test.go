@@ -0,0 +1,41 @@
+package main
+
+import "testing"
+
+func TestShareStore(t *testing.T) {
+	dir := t.TempDir()
+	s := NewShareStore(dir)
+	s.Share(1, 2, ShareReadOnly)
+	s.Share(1, 3, ShareCollab)
+	if g := s.Guests(1); len(g) != 2 || g[0] != 2 || g[1] != 3 {
+		t.Errorf("Guests(1) = %v", g)
+	}
+	if _, ok := s.CollabOwner(2); ok {
+		t.Error("a read-only guest is a collaborator")
+	}
+
+	// Sharing a guest with a new owner moves it.
+	s.Share(4, 2, ShareCollab)
+	if g := s.Guests(1); len(g) != 1 || g[0] != 3 {
+		t.Errorf("Guests(1) after the move = %v", g)
+	}
+
+	// A restart keeps every share and its mode.
+	s = NewShareStore(dir)
+	if owner, ok := s.CollabOwner(2); !ok || owner != 4 {
+		t.Errorf("after restart: CollabOwner(2) = %d, %v", owner, ok)
+	}
+	if m, ok := s.Mode(1, 3); !ok || m != ShareCollab {
+		t.Errorf("after restart: Mode(1, 3) = %q, %v", m, ok)
+	}
+
+	if !s.Unshare(1, 3) || s.Unshare(1, 3) {
+		t.Error("Unshare should succeed once")
+	}
+	if s = NewShareStore(dir); len(s.Guests(1)) != 0 || len(s.Guests(4)) != 1 {
+		t.Errorf("after unshare and restart: %v, %v", s.Guests(1), s.Guests(4))
+	}
+	if _, ok := s.CollabOwner(3); ok {
+		t.Error("an unshared guest is still a collaborator")
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+)
+
+// turnTracker counts in-flight updates per chat so shutdown can wait for
+// them and tell the affected chats what happened.
+type turnTracker struct {
+	mu       sync.Mutex
+	draining bool
+	active   map[int64]int
+}
+
+func newTurnTracker() *turnTracker {
+	return &turnTracker{active: make(map[int64]int)}
+}
+
+// begin registers an update for chatID. It returns false once draining has
+// started, in which case the update must not be handled.
+func (t *turnTracker) begin(chatID int64) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.draining {
+		return false
+	}
+	t.active[chatID]++
+	return true
+}
+
+func (t *turnTracker) end(chatID int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.active[chatID]--; t.active[chatID] <= 0 {
+		delete(t.active, chatID)
+	}
+}
+
+// drain refuses further updates and returns the chats with turns in flight.
+func (t *turnTracker) drain() []int64 {
+	t.mu.Lock()
+	t.draining = true
+	t.mu.Unlock()
+	return t.chats()
+}
+
+func (t *turnTracker) chats() []int64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	ids := make([]int64, 0, len(t.active))
+	for id := range t.active {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+// wait blocks until no turns are in flight or timeout passes, and returns
+// the chats whose turns were still running.
+func (t *turnTracker) wait(timeout time.Duration) []int64 {
+	deadline := time.Now().Add(timeout)
+	for {
+		ids := t.chats()
+		if len(ids) == 0 || !time.Now().Before(deadline) {
+			return ids
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// Shutdown stops accepting updates, waits up to timeout for in-flight AI
+// turns and commands to finish, tells chats whose turn was cut off to resend,
+// then persists state.
+func (b *Bot) Shutdown(timeout time.Duration) {
+	busy := b.turns.drain()
+	if b.webhookQueue != nil {
+		b.stopWebhook()
+	} else {
+		b.api.StopReceivingUpdates()
+	}
+
+	if len(busy) > 0 {
+		slog.Info("draining in-flight turns", "chats", len(busy), "timeout", timeout)
+		for _, id := range busy {
+			if id != 0 {
+				b.handlers.sender.SendPlain(id, b.handlers.t(id, "shutdown.draining"))
+			}
+		}
+	}
+	start := time.Now()
+	left := b.turns.wait(timeout)
+	for _, id := range left {
+		if id != 0 {
+			b.handlers.sender.SendPlain(id, b.handlers.t(id, "shutdown.lost"))
+		}
+	}
+	if len(left) > 0 {
+		slog.Warn("shutdown timeout, abandoning in-flight turns", "chats", len(left))
+	} else if len(busy) > 0 {
+		slog.Info("in-flight turns finished", "duration", time.Since(start))
+	}
+	b.Stop()
+}
+
+// refuse answers an update that arrived after shutdown began.
+func (b *Bot) refuse(chatID int64) {
+	if chatID != 0 && b.handlers.IsAllowed(chatID) {
+		b.handlers.sender.SendPlain(chatID, b.handlers.t(chatID, "restarting"))
+	}
+}
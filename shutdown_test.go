@@ -0,0 +1,34 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTurnTrackerDrain(t *testing.T) {
+	tr := newTurnTracker()
+	if !tr.begin(1) || !tr.begin(2) || !tr.begin(2) {
+		t.Fatal("begin refused before draining")
+	}
+	tr.end(2)
+
+	if busy := tr.drain(); !reflect.DeepEqual(busy, []int64{1, 2}) {
+		t.Fatalf("drain() = %v, want [1 2]", busy)
+	}
+	if tr.begin(3) {
+		t.Error("begin accepted an update while draining")
+	}
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		tr.end(1)
+	}()
+	if left := tr.wait(50 * time.Millisecond); !reflect.DeepEqual(left, []int64{2}) {
+		t.Errorf("wait() = %v, want [2] still running", left)
+	}
+	tr.end(2)
+	if left := tr.wait(time.Second); len(left) != 0 {
+		t.Errorf("wait() = %v after all turns ended", left)
+	}
+}
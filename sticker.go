@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Stickers and GIFs reach the AI as a still image: a static sticker's own
+// WebP, or the preview Telegram sends with animated stickers and GIFs.
+// Without one the AI still hears what was sent, e.g. the sticker's emoji.
+
+// stickerImage returns the WebP image to show the AI for a sticker, or nil
+// for an animated sticker without a preview.
+func stickerImage(s *tgbotapi.Sticker) *tgbotapi.PhotoSize {
+	if !s.IsAnimated {
+		return &tgbotapi.PhotoSize{FileID: s.FileID, FileSize: s.FileSize}
+	}
+	return s.Thumbnail
+}
+
+// HandleSticker processes a sticker message.
+func (h *Handlers) HandleSticker(ctx context.Context, chatID int64, sticker *tgbotapi.Sticker) {
+	what := "a sticker"
+	switch {
+	case sticker.Emoji != "" && sticker.SetName != "":
+		what = fmt.Sprintf("a sticker (%s from the set %q)", sticker.Emoji, sticker.SetName)
+	case sticker.Emoji != "":
+		what = fmt.Sprintf("a sticker (%s)", sticker.Emoji)
+	}
+	h.handleStill(ctx, chatID, "sticker", what, stickerImage(sticker), "webp", "")
+}
+
+// HandleAnimation processes a GIF or other silent animation.
+func (h *Handlers) HandleAnimation(ctx context.Context, chatID int64, animation *tgbotapi.Animation, caption string) {
+	what := fmt.Sprintf("a GIF (%s)", formatDuration(animation.Duration))
+	h.handleStill(ctx, chatID, "GIF", what, animation.Thumbnail, "jpg", caption)
+}
+
+// handleStill sends the AI an image standing for a sticker or GIF. kind
+// names the message in logs and errors, what describes it to the AI.
+// image may be nil.
+func (h *Handlers) handleStill(ctx context.Context, chatID int64, kind, what string, image *tgbotapi.PhotoSize, ext, caption string) {
+	unlock, ok := h.lockFresh(ctx, chatID, kind, caption)
+	if !ok {
+		return
+	}
+	defer unlock()
+
+	slog.Info("received "+kind, "chat_id", chatID, "image", image != nil)
+
+	if h.rejectIfFrozen(chatID) || h.rejectIfOverQuota(ctx, chatID) {
+		return
+	}
+
+	if h.approvals.Has(chatID) {
+		h.sender.SendPlain(chatID, h.t(chatID, "approval.pending_first"))
+		return
+	}
+
+	h.sender.SendTyping(chatID)
+
+	message := fmt.Sprintf("The user sent %s with no image to look at.", what)
+	if image != nil {
+		path, ok := h.downloadMedia(chatID, kind, image.FileID, ext, image.FileSize)
+		if !ok {
+			return
+		}
+		defer h.media.Cleanup(path)
+		message = fmt.Sprintf("The user sent %s; a still image of it is saved at %s. Please read and analyze it.", what, path)
+	}
+	if caption != "" {
+		message += fmt.Sprintf("\nUser's message: %s", caption)
+	}
+
+	h.callAI(ctx, chatID, message)
+}
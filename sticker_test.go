@@ -0,0 +1,20 @@
+package main
+
+import (
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestStickerImage(t *testing.T) {
+	thumb := &tgbotapi.PhotoSize{FileID: "thumb"}
+	if got := stickerImage(&tgbotapi.Sticker{FileID: "static", FileSize: 900, Thumbnail: thumb}); got == nil || got.FileID != "static" || got.FileSize != 900 {
+		t.Errorf("static sticker image = %+v", got)
+	}
+	if got := stickerImage(&tgbotapi.Sticker{FileID: "anim", IsAnimated: true, Thumbnail: thumb}); got != thumb {
+		t.Errorf("animated sticker image = %+v", got)
+	}
+	if got := stickerImage(&tgbotapi.Sticker{FileID: "anim", IsAnimated: true}); got != nil {
+		t.Errorf("animated sticker without preview = %+v", got)
+	}
+}
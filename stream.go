@@ -0,0 +1,274 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// errStreamStopped is returned by streamChat when the user pressed Stop.
+var errStreamStopped = errors.New("stopped by the user")
+
+// stoppedNote is appended to a stopped reply in the stored history, so the
+// model knows its previous answer was cut off.
+const stoppedNote = "\n\n[Response stopped by the user before it was finished.]"
+
+// liveStream is a provider reply being streamed into a chat.
+type liveStream struct {
+	cancel  context.CancelFunc
+	stopped atomic.Bool
+}
+
+// StreamStore tracks the chats with a reply being streamed, so the Stop
+// button can cancel the request without waiting for the chat lock.
+type StreamStore struct {
+	mu      sync.Mutex
+	streams map[int64]*liveStream
+}
+
+func NewStreamStore() *StreamStore {
+	return &StreamStore{streams: make(map[int64]*liveStream)}
+}
+
+// Start registers a stream for chatID, cancelled with cancel.
+func (s *StreamStore) Start(chatID int64, cancel context.CancelFunc) *liveStream {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ls := &liveStream{cancel: cancel}
+	s.streams[chatID] = ls
+	return ls
+}
+
+// End unregisters ls once its request is over.
+func (s *StreamStore) End(chatID int64, ls *liveStream) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.streams[chatID] == ls {
+		delete(s.streams, chatID)
+	}
+}
+
+// Stop cancels the chat's stream, reporting whether there was one.
+func (s *StreamStore) Stop(chatID int64) bool {
+	s.mu.Lock()
+	ls := s.streams[chatID]
+	s.mu.Unlock()
+	if ls == nil {
+		return false
+	}
+	ls.stopped.Store(true)
+	ls.cancel()
+	return true
+}
+
+// streamKeyboard is the Stop button shown under a reply being streamed.
+func streamKeyboard(label string) tgbotapi.InlineKeyboardMarkup {
+	return tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(label, "stop")),
+	)
+}
+
+// streamView shows a reply as it is generated in a single message, edited
+// at most every STREAM_INTERVAL (Telegram rate-limits edits). The preview is
+// plain text, since half a reply is rarely valid Markdown; the finished reply
+// replaces it.
+type streamView struct {
+	h         *Handlers
+	chatID    int64
+	messageID int
+	keyboard  tgbotapi.InlineKeyboardMarkup
+
+	mu    sync.Mutex
+	text  string
+	shown string
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+func (h *Handlers) newStreamView(chatID int64) *streamView {
+	v := &streamView{h: h, chatID: chatID, keyboard: streamKeyboard(h.t(chatID, "stream.stop")), done: make(chan struct{})}
+	v.wg.Add(1)
+	go v.run()
+	return v
+}
+
+// add appends a piece of the reply; it is shown on the next tick.
+func (v *streamView) add(delta string) {
+	v.mu.Lock()
+	v.text += delta
+	v.mu.Unlock()
+}
+
+func (v *streamView) run() {
+	defer v.wg.Done()
+	ticker := time.NewTicker(v.h.streamEvery)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			v.refresh()
+		case <-v.done:
+			return
+		}
+	}
+}
+
+// refresh shows the text received so far, with the Stop button.
+func (v *streamView) refresh() {
+	v.mu.Lock()
+	text := v.text
+	v.mu.Unlock()
+	if text == v.shown {
+		return
+	}
+	v.shown = text
+	preview := previewText(v.h.guardrails.Get(v.chatID).Apply(text)) + " …"
+	if v.messageID == 0 {
+		v.messageID = v.h.sender.SendPlainWithKeyboard(v.chatID, preview, v.keyboard)
+		return
+	}
+	v.h.sender.EditPlain(v.chatID, v.messageID, preview, &v.keyboard)
+}
+
+// close stops refreshing the preview.
+func (v *streamView) close() {
+	close(v.done)
+	v.wg.Wait()
+}
+
+// finish removes the preview; the caller sends the complete reply.
+func (v *streamView) finish() {
+	v.close()
+	if v.messageID != 0 {
+		v.h.sender.DeleteMessage(v.chatID, v.messageID)
+	}
+}
+
+// received returns the text added so far.
+func (v *streamView) received() string {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.text
+}
+
+// stop leaves the partial reply in place, marked as stopped.
+func (v *streamView) stop() {
+	v.close()
+	partial := v.received()
+	text := "⏹ Stopped."
+	if partial != "" {
+		text = previewText(v.h.guardrails.Get(v.chatID).Apply(partial)) + "\n\n" + text
+	}
+	if v.messageID == 0 {
+		v.h.sender.SendPlain(v.chatID, text)
+		return
+	}
+	v.h.sender.EditPlain(v.chatID, v.messageID, text, nil)
+}
+
+// previewText keeps the end of a reply too long for one message.
+func previewText(text string) string {
+	const max = maxMessageLength - 100
+	if len(text) <= max {
+		return text
+	}
+	cut := len(text) - max
+	for cut < len(text) && !utf8.RuneStart(text[cut]) {
+		cut++
+	}
+	return "… " + text[cut:]
+}
+
+// canStream reports whether provider's replies can be streamed.
+func (h *Handlers) canStream(provider string) bool {
+	switch provider {
+	case "claude", "gemini", "openrouter", "azure":
+		return h.streamEvery > 0
+	}
+	return false
+}
+
+// apiStreamer returns the Stream function of a streaming HTTP provider
+// (OpenRouter or Azure OpenAI).
+func (h *Handlers) apiStreamer(provider string) func(context.Context, []GeminiMessage, string, func(string)) (string, APIUsage, error) {
+	if provider == "azure" {
+		return h.azure.Stream
+	}
+	return h.openrouter.Stream
+}
+
+// withStream runs send with the reply shown as it is generated, under a
+// Stop button that cancels send's context. It reports whether the user
+// pressed Stop, in which case the partial reply is left in the chat.
+func (h *Handlers) withStream(ctx context.Context, chatID int64, provider string, send func(ctx context.Context, onDelta func(string))) bool {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	ls := h.streams.Start(chatID, cancel)
+	defer h.streams.End(chatID, ls)
+
+	view := h.newStreamView(chatID)
+	send(ctx, view.add)
+	if ls.stopped.Load() {
+		slog.Info("stream stopped by user", "chat_id", chatID, "provider", provider, "bytes", len(view.received()))
+		view.stop()
+		return true
+	}
+	view.finish()
+	return false
+}
+
+// streamChat is sendChat with the reply streamed (see withStream). When
+// stopped it returns the text received so far with errStreamStopped.
+func (h *Handlers) streamChat(ctx context.Context, chatID int64, provider string, history []GeminiMessage, message string) (string, error) {
+	var result string
+	var err error
+	stopped := h.withStream(ctx, chatID, provider, func(ctx context.Context, onDelta func(string)) {
+		if provider == "gemini" {
+			result, err = h.sendGemini(ctx, chatID, history, message, onDelta)
+			return
+		}
+		result, err = h.sendAPI(ctx, chatID, provider, history, message, onDelta)
+	})
+	if stopped {
+		return result, errStreamStopped
+	}
+	return result, err
+}
+
+// recordStopped keeps a stopped reply in the chat's history and transcript,
+// marked as cut off.
+func (h *Handlers) recordStopped(chatID int64, provider, message, partial string) {
+	h.geminiSessions.Append(chatID,
+		GeminiMessage{Role: "user", Content: message},
+		GeminiMessage{Role: "model", Content: partial + stoppedNote},
+	)
+	h.record(TranscriptEntry{ChatID: chatID, Kind: TranscriptAssistant, Provider: provider, Text: partial + stoppedNote})
+}
+
+// recordClaudeStopped keeps the session of a stopped Claude reply, so the
+// next message resumes it, and records the partial reply in the transcript.
+func (h *Handlers) recordClaudeStopped(chatID int64, resp *ClaudeResponse) {
+	if resp == nil {
+		return
+	}
+	if resp.SessionID != "" {
+		h.sessions.Set(chatID, resp.SessionID)
+	}
+	h.record(TranscriptEntry{ChatID: chatID, Kind: TranscriptAssistant, Provider: "claude", Text: resp.Result + stoppedNote})
+}
+
+// handleStopCallback handles the Stop button under a streamed reply.
+func (h *Handlers) handleStopCallback(chatID int64, callbackID string) {
+	if !h.streams.Stop(chatID) {
+		h.sender.AnswerCallback(callbackID, h.t(chatID, "stream.nothing"))
+		return
+	}
+	h.sender.AnswerCallback(callbackID, h.t(chatID, "stream.stopping"))
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestReadChatStream(t *testing.T) {
+	body := `: OPENROUTER PROCESSING
+
+data: {"model":"openai/gpt-4o-mini","choices":[{"delta":{"role":"assistant","content":"Hel"}}]}
+
+data: {"choices":[{"delta":{"content":"lo"},"finish_reason":"stop"}]}
+
+data: {"choices":[],"usage":{"prompt_tokens":10,"completion_tokens":2,"total_tokens":12,"cost":0.0001}}
+
+data: [DONE]
+`
+	var deltas []string
+	st, err := readChatStream(context.Background(), strings.NewReader(body), func(d string) { deltas = append(deltas, d) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Text != "Hello" || st.Model != "openai/gpt-4o-mini" || st.FinishReason != "stop" || st.Usage.TotalTokens != 12 {
+		t.Errorf("stream = %+v", st)
+	}
+	if strings.Join(deltas, "|") != "Hel|lo" {
+		t.Errorf("deltas = %q", deltas)
+	}
+
+	_, err = readChatStream(context.Background(), strings.NewReader(`data: {"error":{"message":"overloaded"}}`), nil)
+	if err == nil || !strings.Contains(err.Error(), "overloaded") {
+		t.Errorf("error chunk = %v", err)
+	}
+}
+
+func TestReadGeminiStream(t *testing.T) {
+	body := strings.Join([]string{
+		`data: {"candidates":[{"content":{"role":"model","parts":[{"text":"Hello"}]}}],"usageMetadata":{"promptTokenCount":5,"totalTokenCount":6}}`,
+		``,
+		`data: {"candidates":[{"content":{"role":"model","parts":[{"text":", world"}]},"finishReason":"STOP"}],"usageMetadata":{"promptTokenCount":5,"candidatesTokenCount":3,"totalTokenCount":8}}`,
+		``,
+	}, "\n")
+	var deltas []string
+	st, err := readGeminiStream(context.Background(), strings.NewReader(body), func(d string) { deltas = append(deltas, d) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Text != "Hello, world" || len(deltas) != 2 {
+		t.Errorf("text = %q, deltas = %q", st.Text, deltas)
+	}
+	if st.FinishReason != "STOP" || st.Usage.TotalTokens != 8 || st.Usage.CandidatesTokens != 3 {
+		t.Errorf("stream = %+v", st)
+	}
+
+	errBody := `data: {"candidates":[{"content":{"parts":[{"text":"Hi"}]}}]}` + "\n\n" +
+		`data: {"error":{"code":500,"status":"INTERNAL","message":"boom"}}` + "\n"
+	st, err = readGeminiStream(context.Background(), strings.NewReader(errBody), func(string) {})
+	if err == nil || !strings.Contains(err.Error(), "boom") || st.Text != "Hi" {
+		t.Errorf("error stream: text %q, err %v", st.Text, err)
+	}
+}
+
+func TestOpenRouterStreamCancel(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if !strings.Contains(string(body), `"stream":true`) {
+			t.Errorf("request did not ask for a stream: %s", body)
+		}
+		io.WriteString(w, "data: {\"choices\":[{\"delta\":{\"content\":\"partial\"}}]}\n\n")
+		w.(http.Flusher).Flush()
+		<-release
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	o := NewOpenRouterClient(&Config{OpenRouterAPIKey: "sk-or-test"})
+	o.baseURL = srv.URL
+	ctx, cancel := context.WithCancel(context.Background())
+	text, _, err := o.Stream(ctx, nil, "hi", func(string) { cancel() })
+	if !errors.Is(err, context.Canceled) || text != "partial" {
+		t.Errorf("Stream = %q, %v; want the partial text and context.Canceled", text, err)
+	}
+}
+
+func TestStreamStore(t *testing.T) {
+	s := NewStreamStore()
+	if s.Stop(1) {
+		t.Error("Stop with no stream should report false")
+	}
+	cancelled := false
+	ls := s.Start(1, func() { cancelled = true })
+	if !s.Stop(1) || !cancelled || !ls.stopped.Load() {
+		t.Error("Stop should cancel the stream and mark it stopped")
+	}
+	s.End(1, ls)
+	if s.Stop(1) {
+		t.Error("ended stream should be gone")
+	}
+}
+
+func TestPreviewText(t *testing.T) {
+	long := strings.Repeat("é", maxMessageLength)
+	got := previewText(long)
+	if len(got) > maxMessageLength || !strings.HasPrefix(got, "… ") || !strings.HasSuffix(got, "é") {
+		t.Errorf("preview of %d bytes = %d bytes", len(long), len(got))
+	}
+	if previewText("short") != "short" {
+		t.Error("short text should be unchanged")
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+)
+
+// summarizePrompt asks the model for a hand-off summary of the session.
+const summarizePrompt = "Summarize our conversation so far so it can seed a fresh session: " +
+	"the goal, key decisions, files and commands involved, the current state and open next steps. " +
+	"Be concise. Reply with the summary only and do not propose any commands."
+
+// summarySeed introduces the summary at the start of the compressed session.
+const summarySeed = "This session continues an earlier conversation. Summary so far:\n\n"
+
+// estimateTokens roughly converts text length to tokens (about 4 bytes per
+// token for English and code), for budgets and reports where the provider
+// does not give an exact count.
+func estimateTokens(s string) int {
+	return (len(s) + 3) / 4
+}
+
+// estimateHistoryTokens estimates the size of a Gemini history.
+func estimateHistoryTokens(history []GeminiMessage) int {
+	n := 0
+	for _, m := range history {
+		n += estimateTokens(m.Content)
+	}
+	return n
+}
+
+// contextTokens is the prompt size Claude reported for a call, cached or not.
+func contextTokens(u ClaudeUsage) int64 {
+	return u.InputTokens + u.CacheReadInputTokens + u.CacheCreationInputTokens
+}
+
+// HandleSummarize has the active AI summarize the session, then replaces the
+// Gemini history (or starts a fresh Claude session) with that summary.
+func (h *Handlers) HandleSummarize(ctx context.Context, chatID int64) {
+	unlock := h.locks.Lock(chatID)
+	defer unlock()
+
+	if h.rejectIfFrozen(chatID) || h.rejectIfOverQuota(ctx, chatID) {
+		return
+	}
+	if h.approvals.Has(chatID) {
+		h.sender.SendPlain(chatID, h.t(chatID, "approval.pending_first"))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, h.defaultTimeout())
+	defer cancel()
+	h.sender.SendTyping(chatID)
+
+	var summary string
+	var before, after int64
+	if provider := h.providers.Get(chatID); isChatProvider(provider) {
+		history := h.geminiSessions.Get(chatID)
+		if len(history) == 0 {
+			h.sender.SendPlain(chatID, h.t(chatID, "summarize.empty"))
+			return
+		}
+		var err error
+		summary, err = h.sendChat(ctx, chatID, provider, history, summarizePrompt)
+		if err != nil {
+			h.sender.SendPlain(chatID, h.t(chatID, "summarize.failed", err))
+			return
+		}
+		compressed := []GeminiMessage{
+			{Role: "user", Content: summarySeed + summary},
+			{Role: "model", Content: "Understood. I'll continue from this summary."},
+		}
+		h.geminiSessions.Replace(chatID, compressed)
+		before, after = int64(estimateHistoryTokens(history)), int64(estimateHistoryTokens(compressed))
+	} else {
+		sessionID := h.sessions.Get(chatID)
+		if sessionID == "" {
+			h.sender.SendPlain(chatID, h.t(chatID, "summarize.empty"))
+			return
+		}
+		resp, err := h.sendClaude(ctx, chatID, sessionID, summarizePrompt, nil, nil)
+		if err != nil {
+			h.sender.SendPlain(chatID, h.t(chatID, "summarize.failed", err))
+			return
+		}
+		h.recordUsage(ctx, chatID, resp)
+		summary = resp.Result
+		before = contextTokens(resp.Usage)
+
+		seeded, err := h.sendClaude(ctx, chatID, "", summarySeed+summary+"\n\nReply only with \"OK\".", nil, nil)
+		if err != nil {
+			h.sender.SendPlain(chatID, h.t(chatID, "summarize.seed_failed", err))
+			return
+		}
+		h.recordUsage(ctx, chatID, seeded)
+		h.sessions.Set(chatID, seeded.SessionID)
+		after = contextTokens(seeded.Usage)
+	}
+
+	slog.Info("session summarized", "chat_id", chatID, "tokens_before", before, "tokens_after", after)
+	saved := ""
+	if before > 0 && after < before {
+		saved = h.t(chatID, "summarize.smaller", 100*(before-after)/before)
+	}
+	h.sendReply(ctx, chatID, summary)
+	h.sender.SendPlain(chatID, h.t(chatID, "summarize.done", before, after, saved))
+}
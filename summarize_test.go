@@ -0,0 +1,19 @@
+package main
+
+import "testing"
+
+func TestEstimateTokens(t *testing.T) {
+	if n := estimateTokens(""); n != 0 {
+		t.Errorf("empty = %d", n)
+	}
+	if n := estimateTokens("abcde"); n != 2 {
+		t.Errorf("5 bytes = %d, want 2", n)
+	}
+	history := []GeminiMessage{{Role: "user", Content: "abcd"}, {Role: "model", Content: "abcdefgh"}}
+	if n := estimateHistoryTokens(history); n != 3 {
+		t.Errorf("history = %d, want 3", n)
+	}
+	if n := contextTokens(ClaudeUsage{InputTokens: 10, OutputTokens: 99, CacheReadInputTokens: 200, CacheCreationInputTokens: 30}); n != 240 {
+		t.Errorf("contextTokens = %d, want 240", n)
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// maxSystemPrompt caps a chat's custom system prompt, in bytes.
+const maxSystemPrompt = 8000
+
+// SystemPromptStore holds the system prompts chats set with /system,
+// persisted to DATA_DIR. They replace SYSTEM_PROMPT (or the built-in
+// prompt); the safeguard rules and plugin tags are still appended.
+type SystemPromptStore struct {
+	mu      sync.RWMutex
+	path    string
+	prompts map[int64]string
+}
+
+func NewSystemPromptStore(dataDir string) *SystemPromptStore {
+	s := &SystemPromptStore{
+		path:    filepath.Join(dataDir, "system_prompts.json"),
+		prompts: make(map[int64]string),
+	}
+	if err := loadJSONFile(s.path, &s.prompts); err != nil {
+		slog.Warn("failed to load system prompts", "path", s.path, "err", err)
+	}
+	return s
+}
+
+// Get returns the chat's custom prompt, or "" if it uses the default.
+func (s *SystemPromptStore) Get(chatID int64) string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.prompts[chatID]
+}
+
+func (s *SystemPromptStore) Set(chatID int64, prompt string) error {
+	if len(prompt) > maxSystemPrompt {
+		return fmt.Errorf("prompt too long (%d bytes, max %d)", len(prompt), maxSystemPrompt)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.prompts[chatID] = prompt
+	return saveJSONFile(s.path, s.prompts)
+}
+
+// Reset removes the chat's custom prompt, reporting whether it had one.
+func (s *SystemPromptStore) Reset(chatID int64) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.prompts[chatID]; !ok {
+		return false, nil
+	}
+	delete(s.prompts, chatID)
+	return true, saveJSONFile(s.path, s.prompts)
+}
+
+// systemPromptKey is the context key of a chat's system prompt override.
+type systemPromptKey struct{}
+
+// withSystemPrompt returns ctx carrying the chat's complete system prompt
+// for provider when it differs from the one the clients were built with:
+// the chat has a custom prompt, or a config reload changed SYSTEM_PROMPT.
+// The provider clients read it with systemPromptFrom, so their Send
+// signatures stay chat-agnostic.
+func (h *Handlers) withSystemPrompt(ctx context.Context, chatID int64, provider string) context.Context {
+	prompt := h.systemPrompts.Get(chatID)
+	if prompt == "" {
+		h.liveMu.RLock()
+		reloaded := h.basePrompt != h.clientPrompt
+		h.liveMu.RUnlock()
+		if !reloaded {
+			return ctx
+		}
+		prompt = h.defaultSystemPromptFor(provider)
+	}
+	return context.WithValue(ctx, systemPromptKey{}, prompt+safeguardPrompt+h.plugins.Prompt()+askPrompt)
+}
+
+// systemPromptFrom returns the system prompt carried by ctx, or def.
+func systemPromptFrom(ctx context.Context, def string) string {
+	if p, ok := ctx.Value(systemPromptKey{}).(string); ok {
+		return p
+	}
+	return def
+}
+
+// defaultSystemPromptFor returns the prompt a chat without a custom one
+// uses with provider, without the appended safeguard rules.
+func (h *Handlers) defaultSystemPromptFor(provider string) string {
+	h.liveMu.RLock()
+	base := h.basePrompt
+	h.liveMu.RUnlock()
+	switch {
+	case base != "":
+		return base
+	case provider == "claude":
+		return defaultSystemPrompt
+	}
+	return defaultGeminiSystemPrompt
+}
+
+// HandleSystem shows, sets or resets the chat's system prompt.
+// Usage: /system [show] | /system set <prompt> | /system reset
+func (h *Handlers) HandleSystem(chatID int64, args string) {
+	// The prompt is taken verbatim, line breaks included.
+	args = strings.TrimSpace(args)
+	sub, rest := args, ""
+	if i := strings.IndexAny(args, " \n"); i >= 0 {
+		sub, rest = args[:i], strings.TrimSpace(args[i+1:])
+	}
+
+	switch strings.ToLower(sub) {
+	case "", "show":
+		if custom := h.systemPrompts.Get(chatID); custom != "" {
+			h.sender.SendPlain(chatID, h.t(chatID, "system.custom", custom))
+			return
+		}
+		provider := h.providers.Get(chatID)
+		h.sender.SendPlain(chatID, h.t(chatID, "system.default",
+			providerLabel(provider), strings.TrimSpace(h.defaultSystemPromptFor(provider))))
+
+	case "set":
+		if rest == "" {
+			h.sender.SendPlain(chatID, h.t(chatID, "system.set_usage"))
+			return
+		}
+		if err := h.systemPrompts.Set(chatID, rest); err != nil {
+			h.sender.SendPlain(chatID, h.t(chatID, "system.set_failed", err))
+			return
+		}
+		slog.Info("system prompt set", "chat_id", chatID, "bytes", len(rest))
+		h.sender.SendPlain(chatID, h.t(chatID, "system.updated"))
+
+	case "reset":
+		found, err := h.systemPrompts.Reset(chatID)
+		switch {
+		case err != nil:
+			h.sender.SendPlain(chatID, h.t(chatID, "system.reset_failed", err))
+		case !found:
+			h.sender.SendPlain(chatID, h.t(chatID, "system.already_default"))
+		default:
+			slog.Info("system prompt reset", "chat_id", chatID)
+			h.sender.SendPlain(chatID, h.t(chatID, "system.reset"))
+		}
+
+	default:
+		h.sendUsage(chatID, "system")
+	}
+}
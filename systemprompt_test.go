@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestSystemPromptStore(t *testing.T) {
+	dir := t.TempDir()
+	s := NewSystemPromptStore(dir)
+	if err := s.Set(1, "You are terse."); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Set(1, strings.Repeat("x", maxSystemPrompt+1)); err == nil {
+		t.Error("oversized prompt accepted")
+	}
+
+	s = NewSystemPromptStore(dir)
+	if got := s.Get(1); got != "You are terse." {
+		t.Errorf("reloaded prompt = %q", got)
+	}
+	if found, err := s.Reset(1); !found || err != nil {
+		t.Errorf("Reset = %v, %v", found, err)
+	}
+	if found, _ := s.Reset(1); found {
+		t.Error("Reset found a removed prompt")
+	}
+}
+
+func TestWithSystemPrompt(t *testing.T) {
+	h := &Handlers{systemPrompts: NewSystemPromptStore(t.TempDir()), plugins: LoadPlugins("", 0)}
+	ctx := h.withSystemPrompt(context.Background(), 1, "claude")
+	if got := systemPromptFrom(ctx, "default"); got != "default" {
+		t.Errorf("chat without a custom prompt got %q", got)
+	}
+
+	h.systemPrompts.Set(1, "You are terse.")
+	ctx = h.withSystemPrompt(context.Background(), 1, "claude")
+	got := systemPromptFrom(ctx, "default")
+	if !strings.HasPrefix(got, "You are terse.") || !strings.HasSuffix(got, safeguardPrompt+askPrompt) {
+		t.Errorf("custom prompt = %q, want it followed by the safeguard rules and <ask>", got)
+	}
+	if got := systemPromptFrom(h.withSystemPrompt(context.Background(), 2, "claude"), "default"); got != "default" {
+		t.Errorf("other chat got %q", got)
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+)
+
+// ErrTestsFailed is returned for a git commit/push held back because the
+// workspace's test command failed.
+var ErrTestsFailed = errors.New("tests failed")
+
+// gitPublishRe matches git commit and git push, including git -C <dir> and
+// other global options before the subcommand.
+var gitPublishRe = regexp.MustCompile(`(?:^|[;&|(]\s*|\s)git(?:\s+-[^\s]+(?:\s+[^-\s][^\s]*)?)*\s+(?:commit|push)(?:$|[\s;&|)])`)
+
+// isGitPublish reports whether a command commits or pushes with git.
+func isGitPublish(command string) bool {
+	return gitPublishRe.MatchString(command)
+}
+
+// runTestGate runs the configured test command in the chat's workspace before
+// a git commit or push. It returns the test output and ErrTestsFailed when the
+// suite fails; other commands, or an unset TEST_GATE_COMMAND, pass through.
+func (h *Handlers) runTestGate(ctx context.Context, chatID int64, command string) (string, error) {
+	if h.testGate == "" || !isGitPublish(command) {
+		return "", nil
+	}
+	h.sender.SendPlain(chatID, h.t(chatID, "testgate.running", truncateText(command, 80), h.testGate))
+	output, err := h.claude.ExecuteCommand(ctx, h.projectDir(chatID), h.testGate)
+	if err != nil {
+		slog.Warn("test gate failed, command held back", "chat_id", chatID, "command", command, "err", err)
+		return fmt.Sprintf("Test gate: %s failed, %q was not run.\n\n%s", h.testGate, command, output), fmt.Errorf("%w: %v", ErrTestsFailed, err)
+	}
+	slog.Info("test gate passed", "chat_id", chatID, "command", command)
+	return "", nil
+}
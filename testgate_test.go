@@ -0,0 +1,22 @@
+package main
+
+import "testing"
+
+func TestIsGitPublish(t *testing.T) {
+	cases := map[string]bool{
+		`git commit -m "fix"`:                 true,
+		`git push origin main`:                true,
+		`git add -A && git commit -m x`:       true,
+		`git -C repo push`:                    true,
+		`cd app; git push --force-with-lease`: true,
+		`git status`:                          false,
+		`git log --grep commit`:               false,
+		`legit push`:                          false,
+		`git commit-tree HEAD^{tree} -m snap`: false,
+	}
+	for cmd, want := range cases {
+		if got := isGitPublish(cmd); got != want {
+			t.Errorf("isGitPublish(%q) = %v, want %v", cmd, got, want)
+		}
+	}
+}
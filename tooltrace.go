@@ -0,0 +1,159 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// With ALLOWED_TOOLS or SKIP_PERMISSIONS Claude reads, edits and runs things
+// itself, and the chat would only see the final answer. The tool uses from
+// the stream-json output are listed in a message that grows as Claude works.
+
+// toolInput holds the tool input fields used in descriptions.
+type toolInput struct {
+	FilePath     string `json:"file_path"`
+	NotebookPath string `json:"notebook_path"`
+	Path         string `json:"path"`
+	Command      string `json:"command"`
+	Pattern      string `json:"pattern"`
+	URL          string `json:"url"`
+	Query        string `json:"query"`
+	Description  string `json:"description"`
+}
+
+// describeToolUse summarizes a tool use in a few words, e.g. "📖 Read
+// main.go" or "▶️ Ran go test ./...". Paths under dir are shown relative.
+func describeToolUse(name string, input json.RawMessage, dir string) string {
+	var in toolInput
+	_ = json.Unmarshal(input, &in)
+	file := relPath(in.FilePath, dir)
+	switch name {
+	case "Read":
+		return "📖 Read " + file
+	case "Write":
+		return "📝 Wrote " + file
+	case "Edit", "MultiEdit":
+		return "✏️ Edited " + file
+	case "NotebookEdit":
+		return "✏️ Edited " + relPath(in.NotebookPath, dir)
+	case "Bash":
+		return "▶️ Ran " + shortCommand(in.Command)
+	case "Grep":
+		s := fmt.Sprintf("🔍 Searched %q", in.Pattern)
+		if in.Path != "" {
+			s += " in " + relPath(in.Path, dir)
+		}
+		return s
+	case "Glob":
+		return "📂 Listed " + in.Pattern
+	case "LS":
+		return "📂 Listed " + relPath(in.Path, dir)
+	case "WebFetch":
+		return "🌐 Fetched " + in.URL
+	case "WebSearch":
+		return fmt.Sprintf("🌐 Searched the web for %q", in.Query)
+	case "Task":
+		return "🤖 Started a subtask: " + in.Description
+	case "TodoWrite":
+		return "📋 Updated the todo list"
+	}
+	return "🔧 Used " + name
+}
+
+// relPath shows path relative to dir when it is inside it.
+func relPath(path, dir string) string {
+	if path == "" {
+		return "(unknown)"
+	}
+	if rel, err := filepath.Rel(dir, path); err == nil && !strings.HasPrefix(rel, "..") {
+		return rel
+	}
+	return path
+}
+
+// shortCommand keeps the first line of a command, cut to fit one line.
+func shortCommand(cmd string) string {
+	cmd = strings.TrimSpace(cmd)
+	first, _, multiline := strings.Cut(cmd, "\n")
+	if multiline {
+		first += " …"
+	}
+	return truncateText(first, 80)
+}
+
+const (
+	// toolLogEvery is the minimum time between edits of the tool log.
+	toolLogEvery = time.Second
+	// toolLogLines is how many steps the tool log shows.
+	toolLogLines = 25
+)
+
+// toolLog is the message listing the tools Claude used in a turn.
+type toolLog struct {
+	h         *Handlers
+	chatID    int64
+	messageID int
+
+	mu     sync.Mutex
+	steps  []string
+	edited time.Time
+	dirty  bool
+}
+
+func (h *Handlers) newToolLog(chatID int64) *toolLog {
+	return &toolLog{h: h, chatID: chatID}
+}
+
+// add records a step and shows it, unless the message was edited less than
+// toolLogEvery ago; such steps are shown with the next one or by close.
+func (l *toolLog) add(step string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.steps = append(l.steps, step)
+	l.dirty = true
+	if time.Since(l.edited) >= toolLogEvery {
+		l.showLocked()
+	}
+}
+
+// close shows any steps not shown yet. It is a no-op on a nil log.
+func (l *toolLog) close() {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.dirty {
+		l.showLocked()
+	}
+}
+
+func (l *toolLog) showLocked() {
+	text := formatToolLog(l.h.uiLanguage(l.chatID), l.steps)
+	if l.messageID == 0 {
+		noButtons := tgbotapi.InlineKeyboardMarkup{InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{}}
+		l.messageID = l.h.sender.SendPlainWithKeyboard(l.chatID, text, noButtons)
+	} else {
+		l.h.sender.EditPlain(l.chatID, l.messageID, text, nil)
+	}
+	l.edited = time.Now()
+	l.dirty = false
+}
+
+// formatToolLog lists the last toolLogLines steps.
+func formatToolLog(lang string, steps []string) string {
+	var b strings.Builder
+	b.WriteString(translate(lang, "tooltrace.title"))
+	if n := len(steps) - toolLogLines; n > 0 {
+		b.WriteString(translate(lang, "tooltrace.earlier", n))
+		steps = steps[n:]
+	}
+	b.WriteString(strings.Join(steps, "\n"))
+	return b.String()
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestDescribeToolUse(t *testing.T) {
+	tests := []struct {
+		name, input, want string
+	}{
+		{"Read", `{"file_path":"/work/main.go"}`, "📖 Read main.go"},
+		{"Edit", `{"file_path":"/work/cmd/handlers.go","old_string":"a","new_string":"b"}`, "✏️ Edited cmd/handlers.go"},
+		{"Write", `{"file_path":"/etc/hosts"}`, "📝 Wrote /etc/hosts"},
+		{"Bash", `{"command":"go test ./...\necho done","description":"Run tests"}`, "▶️ Ran go test ./... …"},
+		{"Grep", `{"pattern":"TODO","path":"/work/src"}`, `🔍 Searched "TODO" in src`},
+		{"Glob", `{"pattern":"**/*.go"}`, "📂 Listed **/*.go"},
+		{"mcp__db__query", `{}`, "🔧 Used mcp__db__query"},
+		{"Read", `not json`, "📖 Read (unknown)"},
+	}
+	for _, tt := range tests {
+		if got := describeToolUse(tt.name, []byte(tt.input), "/work"); got != tt.want {
+			t.Errorf("describeToolUse(%s, %s) = %q, want %q", tt.name, tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestFormatToolLog(t *testing.T) {
+	var steps []string
+	for i := 1; i <= toolLogLines+3; i++ {
+		steps = append(steps, fmt.Sprintf("step %d", i))
+	}
+	got := formatToolLog("en", steps)
+	if !strings.Contains(got, "… 3 earlier\nstep 4\n") || !strings.HasSuffix(got, fmt.Sprintf("step %d", toolLogLines+3)) {
+		t.Errorf("formatToolLog =\n%s", got)
+	}
+	if strings.Contains(got, "step 3\n") {
+		t.Error("kept a step beyond the limit")
+	}
+}
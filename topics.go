@@ -0,0 +1,405 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// In a forum supergroup each topic is a chat of its own: it has its own
+// session, provider, approvals and usage, and replies go to the topic.
+// With GROUP_SESSIONS=per_user the same holds for each member of a group
+// (in each topic): their messages form their own conversation, and the
+// bot's replies quote the message they answer.
+//
+// The Telegram library predates forum topics, so the bot tracks them
+// itself. Each topic, or member, gets a chat ID from a reserved range,
+// kept in DATA_DIR, which the rest of the bot uses like any other chat ID.
+// The HTTP client the library sends through reads message_thread_id from
+// incoming updates, and turns such a chat ID in outgoing requests back
+// into the group's chat_id and the topic's message_thread_id. The General
+// topic has no thread and stays the supergroup itself.
+
+// topicIDBase is the first topic chat ID, far below any real chat ID.
+const topicIDBase = -(1 << 62)
+
+// maxSentCards is how many of the bot's messages to topics and members are
+// remembered, so that a button press reaches the chat the message was sent
+// for, before the older half is forgotten.
+const maxSentCards = 5000
+
+// topicKey identifies a forum topic, a group member's conversation, or
+// both.
+type topicKey struct {
+	Chat   int64 `json:"chat"`
+	Thread int   `json:"thread,omitempty"`
+	User   int64 `json:"user,omitempty"`
+}
+
+// topicMessage identifies a message in a group.
+type topicMessage struct {
+	chat      int64
+	messageID int
+}
+
+// TopicStore maps forum topics and group members to chat IDs, persisted to
+// DATA_DIR.
+type TopicStore struct {
+	perUser bool // GROUP_SESSIONS=per_user
+
+	mu       sync.Mutex
+	path     string
+	ids      map[int64]topicKey
+	byKey    map[topicKey]int64
+	received map[topicMessage]topicKey // received messages not yet handled
+	sent     map[topicMessage]int64    // chat ID the bot's messages were sent for
+	sentOld  map[topicMessage]int64
+	lastMsg  map[int64]int // member conversation → message to quote
+}
+
+func NewTopicStore(dataDir string, perUser bool) *TopicStore {
+	s := &TopicStore{
+		perUser:  perUser,
+		path:     filepath.Join(dataDir, "topics.json"),
+		ids:      make(map[int64]topicKey),
+		byKey:    make(map[topicKey]int64),
+		received: make(map[topicMessage]topicKey),
+		sent:     make(map[topicMessage]int64),
+		lastMsg:  make(map[int64]int),
+	}
+	if err := loadJSONFile(s.path, &s.ids); err != nil {
+		slog.Warn("failed to load forum topics", "path", s.path, "err", err)
+	}
+	for id, k := range s.ids {
+		s.byKey[k] = id
+	}
+	return s
+}
+
+// ID returns the chat ID of a topic or member conversation, allocating one
+// the first time it is seen. A key with neither is the group itself.
+func (s *TopicStore) ID(k topicKey) int64 {
+	if k.Thread == 0 && k.User == 0 {
+		return k.Chat
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if id, ok := s.byKey[k]; ok {
+		return id
+	}
+	id := int64(topicIDBase) - int64(len(s.ids))
+	s.ids[id] = k
+	s.byKey[k] = id
+	if err := saveJSONFile(s.path, s.ids); err != nil {
+		slog.Warn("failed to save forum topics", "path", s.path, "err", err)
+	}
+	slog.Info("new group conversation", "chat_id", id, "group", k.Chat, "thread", k.Thread, "user", k.User)
+	return id
+}
+
+// Resolve returns the group, thread and member of a chat ID. Other chat
+// IDs are returned as they are.
+func (s *TopicStore) Resolve(id int64) topicKey {
+	if s == nil || id > topicIDBase {
+		return topicKey{Chat: id}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if k, ok := s.ids[id]; ok {
+		return k
+	}
+	return topicKey{Chat: id}
+}
+
+// Chat returns the group a topic or member conversation belongs to, or id
+// itself. A nil store returns id.
+func (s *TopicStore) Chat(id int64) int64 {
+	return s.Resolve(id).Chat
+}
+
+// Take returns the chat ID an incoming message or button press belongs
+// to: the chat the pressed message was sent for, the message's topic or
+// member conversation, or chatID.
+func (s *TopicStore) Take(chatID int64, messageID int) int64 {
+	if s == nil {
+		return chatID
+	}
+	m := topicMessage{chat: chatID, messageID: messageID}
+	s.mu.Lock()
+	if id, ok := s.sent[m]; ok {
+		s.mu.Unlock()
+		return id
+	}
+	if id, ok := s.sentOld[m]; ok {
+		s.mu.Unlock()
+		return id
+	}
+	k, ok := s.received[m]
+	delete(s.received, m)
+	s.mu.Unlock()
+	if !ok {
+		return chatID
+	}
+	id := s.ID(k)
+	if k.User != 0 {
+		s.mu.Lock()
+		s.lastMsg[id] = messageID
+		s.mu.Unlock()
+	}
+	return id
+}
+
+// noteSent remembers that the bot's message messageID in chat was sent for
+// the chat ID id.
+func (s *TopicStore) noteSent(chat int64, messageID int, id int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.sent) >= maxSentCards/2 {
+		s.sentOld, s.sent = s.sent, make(map[topicMessage]int64)
+	}
+	s.sent[topicMessage{chat: chat, messageID: messageID}] = id
+}
+
+// quoted returns the message a member conversation's replies quote, or 0.
+func (s *TopicStore) quoted(id int64) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lastMsg[id]
+}
+
+// rawTopicMessage holds the message fields the library drops.
+type rawTopicMessage struct {
+	MessageID int `json:"message_id"`
+	Chat      struct {
+		ID   int64  `json:"id"`
+		Type string `json:"type"`
+	} `json:"chat"`
+	From *struct {
+		ID    int64 `json:"id"`
+		IsBot bool  `json:"is_bot"`
+	} `json:"from"`
+	Thread  int  `json:"message_thread_id"`
+	IsTopic bool `json:"is_topic_message"`
+}
+
+type rawTopicUpdate struct {
+	Message       *rawTopicMessage `json:"message"`
+	CallbackQuery *struct {
+		Message *rawTopicMessage `json:"message"`
+	} `json:"callback_query"`
+}
+
+// noteUpdates remembers the topic and, with per-user sessions, the sender
+// of each group message in updates. message_thread_id alone also marks
+// replies in ordinary groups, so only is_topic_message counts. A button
+// press counts the pressed message's topic; its sender is the bot.
+func (s *TopicStore) noteUpdates(updates []rawTopicUpdate) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	note := func(m *rawTopicMessage, sender bool) {
+		if m == nil {
+			return
+		}
+		k := topicKey{Chat: m.Chat.ID}
+		if m.IsTopic {
+			k.Thread = m.Thread
+		}
+		group := m.Chat.Type == "group" || m.Chat.Type == "supergroup"
+		if sender && s.perUser && group && m.From != nil && !m.From.IsBot {
+			k.User = m.From.ID
+		}
+		if k.Thread != 0 || k.User != 0 {
+			s.received[topicMessage{chat: m.Chat.ID, messageID: m.MessageID}] = k
+		}
+	}
+	for _, u := range updates {
+		note(u.Message, true)
+		if u.CallbackQuery != nil {
+			note(u.CallbackQuery.Message, false)
+		}
+	}
+}
+
+// NoteWebhookUpdate remembers the topic of an update posted to the webhook.
+func (s *TopicStore) NoteWebhookUpdate(body []byte) {
+	var u rawTopicUpdate
+	if err := json.Unmarshal(body, &u); err != nil {
+		return // the library reports it
+	}
+	s.noteUpdates([]rawTopicUpdate{u})
+}
+
+// retarget returns the group, thread and quoted message of a topic's or
+// member's chat ID; ok is false for other chat IDs.
+func (s *TopicStore) retarget(chatID string) (id int64, k topicKey, quote int, ok bool) {
+	id, err := strconv.ParseInt(chatID, 10, 64)
+	if err != nil {
+		return 0, topicKey{}, 0, false
+	}
+	k = s.Resolve(id)
+	if k.Thread == 0 && k.User == 0 {
+		return 0, k, 0, false
+	}
+	if k.User != 0 {
+		quote = s.quoted(id)
+	}
+	return id, k, quote, true
+}
+
+// setTarget addresses a request for a topic or member conversation: the
+// group's chat_id and, when method posts a message, the thread and the
+// member's message to quote.
+func setTarget(set func(key, value string), method string, k topicKey, quote int) {
+	set("chat_id", strconv.FormatInt(k.Chat, 10))
+	if !threadMethods(method) {
+		return
+	}
+	if k.Thread != 0 {
+		set("message_thread_id", strconv.Itoa(k.Thread))
+	}
+	if quote != 0 {
+		set("reply_to_message_id", strconv.Itoa(quote))
+		set("allow_sending_without_reply", "true")
+	}
+}
+
+// threadMethods reports whether a Bot API method posts a message and so
+// takes message_thread_id. Edits and deletes address the message itself.
+func threadMethods(method string) bool {
+	return strings.HasPrefix(method, "send") || method == "copyMessage" || method == "forwardMessage"
+}
+
+// topicClient is the HTTP client the Telegram library uses, translating
+// topics in both directions.
+type topicClient struct {
+	topics *TopicStore
+	client tgbotapi.HTTPClient
+}
+
+func (c *topicClient) Do(req *http.Request) (*http.Response, error) {
+	method := path.Base(req.URL.Path)
+	var target int64
+	if req.Body != nil {
+		var err error
+		if target, err = c.rewrite(req, method); err != nil {
+			return nil, err
+		}
+	}
+	resp, err := c.client.Do(req)
+	if err != nil || (method != "getUpdates" && (target == 0 || !threadMethods(method))) {
+		return resp, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	if method == "getUpdates" {
+		var r struct {
+			Result []rawTopicUpdate `json:"result"`
+		}
+		if json.Unmarshal(body, &r) == nil {
+			c.topics.noteUpdates(r.Result)
+		}
+		return resp, nil
+	}
+	// Buttons on the message must reach the chat it was sent for.
+	var r struct {
+		Result rawTopicMessage `json:"result"`
+	}
+	if json.Unmarshal(body, &r) == nil && r.Result.MessageID != 0 {
+		c.topics.noteSent(r.Result.Chat.ID, r.Result.MessageID, target)
+	}
+	return resp, nil
+}
+
+// rewrite addresses a request for a topic's or member's chat ID to its
+// group, returning that chat ID, or 0 when the request was left alone.
+func (c *topicClient) rewrite(req *http.Request, method string) (target int64, err error) {
+	c.topics.mu.Lock()
+	none := len(c.topics.ids) == 0
+	c.topics.mu.Unlock()
+	if none {
+		return 0, nil
+	}
+	mediaType, params, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	var body bytes.Buffer
+	switch mediaType {
+	case "application/x-www-form-urlencoded":
+		raw, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return 0, err
+		}
+		values, err := url.ParseQuery(string(raw))
+		if err != nil {
+			return 0, err
+		}
+		if id, k, quote, ok := c.topics.retarget(values.Get("chat_id")); ok {
+			target = id
+			setTarget(values.Set, method, k, quote)
+			raw = []byte(values.Encode())
+		}
+		body.Write(raw)
+
+	case "multipart/form-data":
+		r := multipart.NewReader(req.Body, params["boundary"])
+		w := multipart.NewWriter(&body)
+		if err := w.SetBoundary(params["boundary"]); err != nil {
+			return 0, err
+		}
+		for {
+			p, err := r.NextPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return 0, err
+			}
+			if p.FormName() == "chat_id" && p.FileName() == "" {
+				v, err := io.ReadAll(p)
+				if err != nil {
+					return 0, err
+				}
+				id, k, quote, ok := c.topics.retarget(string(v))
+				if !ok {
+					w.WriteField("chat_id", string(v))
+					continue
+				}
+				target = id
+				setTarget(func(key, value string) { w.WriteField(key, value) }, method, k, quote)
+				continue
+			}
+			part, err := w.CreatePart(p.Header)
+			if err != nil {
+				return 0, err
+			}
+			if _, err := io.Copy(part, p); err != nil {
+				return 0, err
+			}
+		}
+		req.Body.Close()
+		if err := w.Close(); err != nil {
+			return 0, err
+		}
+
+	default:
+		return 0, nil
+	}
+	req.Body = io.NopCloser(&body)
+	req.ContentLength = int64(body.Len())
+	return target, nil
+}
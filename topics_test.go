@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestTopicStore(t *testing.T) {
+	dir := t.TempDir()
+	s := NewTopicStore(dir, false)
+	if id := s.ID(topicKey{Chat: -1001, Thread: 0}); id != -1001 {
+		t.Errorf("General topic ID = %d, want the supergroup", id)
+	}
+	a := s.ID(topicKey{Chat: -1001, Thread: 5})
+	b := s.ID(topicKey{Chat: -1001, Thread: 9})
+	if a == b || a == -1001 || s.ID(topicKey{Chat: -1001, Thread: 5}) != a {
+		t.Fatalf("topic IDs not distinct and stable: %d, %d", a, b)
+	}
+	if k := s.Resolve(b); k != (topicKey{Chat: -1001, Thread: 9}) {
+		t.Errorf("Resolve(%d) = %+v", b, k)
+	}
+	if k := s.Resolve(42); k != (topicKey{Chat: 42}) {
+		t.Errorf("Resolve(42) = %+v", k)
+	}
+
+	s = NewTopicStore(dir, false)
+	if s.ID(topicKey{Chat: -1001, Thread: 5}) != a {
+		t.Error("topic ID changed on reload")
+	}
+	var none *TopicStore
+	if none.Chat(a) != a {
+		t.Error("nil store changed the chat ID")
+	}
+}
+
+func TestTopicStoreTake(t *testing.T) {
+	s := NewTopicStore(t.TempDir(), false)
+	s.NoteWebhookUpdate([]byte(`{"message":{"message_id":10,"chat":{"id":-1001},"message_thread_id":5,"is_topic_message":true}}`))
+	s.NoteWebhookUpdate([]byte(`{"message":{"message_id":11,"chat":{"id":-2002},"message_thread_id":3}}`))
+
+	if id := s.Take(-1001, 10); id != s.ID(topicKey{Chat: -1001, Thread: 5}) {
+		t.Errorf("topic message went to %d", id)
+	}
+	if id := s.Take(-1001, 10); id != -1001 {
+		t.Error("message topic not forgotten after Take")
+	}
+	if id := s.Take(-2002, 11); id != -2002 {
+		t.Error("reply thread in an ordinary group treated as a topic")
+	}
+}
+
+// recordingClient captures the request the Telegram library would send.
+type recordingClient struct {
+	body     []byte
+	response string
+}
+
+func (c *recordingClient) Do(req *http.Request) (*http.Response, error) {
+	c.body, _ = io.ReadAll(req.Body)
+	return &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader(c.response))}, nil
+}
+
+func TestTopicClientForm(t *testing.T) {
+	s := NewTopicStore(t.TempDir(), false)
+	topic := s.ID(topicKey{Chat: -1001, Thread: 5})
+	rec := &recordingClient{response: `{"ok":true}`}
+	c := &topicClient{topics: s, client: rec}
+
+	send := func(method string, chatID int64) url.Values {
+		form := url.Values{"chat_id": {strconv.FormatInt(chatID, 10)}, "text": {"hi"}}
+		req, _ := http.NewRequest("POST", "https://api.telegram.org/botX/"+method, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if _, err := c.Do(req); err != nil {
+			t.Fatal(err)
+		}
+		got, _ := url.ParseQuery(string(rec.body))
+		return got
+	}
+
+	got := send("sendMessage", topic)
+	if got.Get("chat_id") != "-1001" || got.Get("message_thread_id") != "5" {
+		t.Errorf("sendMessage to a topic sent %v", got)
+	}
+	got = send("editMessageText", topic)
+	if got.Get("chat_id") != "-1001" || got.Has("message_thread_id") {
+		t.Errorf("editMessageText to a topic sent %v", got)
+	}
+	got = send("sendMessage", 42)
+	if got.Get("chat_id") != "42" || got.Has("message_thread_id") {
+		t.Errorf("sendMessage to a chat sent %v", got)
+	}
+}
+
+func TestTopicClientMultipart(t *testing.T) {
+	s := NewTopicStore(t.TempDir(), false)
+	topic := s.ID(topicKey{Chat: -1001, Thread: 5})
+	rec := &recordingClient{response: `{"ok":true}`}
+	c := &topicClient{topics: s, client: rec}
+
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	w.WriteField("chat_id", strconv.FormatInt(topic, 10))
+	f, _ := w.CreateFormFile("document", "answer.md")
+	f.Write([]byte("# answer"))
+	w.Close()
+	req, _ := http.NewRequest("POST", "https://api.telegram.org/botX/sendDocument", &body)
+	req.Header.Set("Content-Type", w.FormDataContentType())
+	if _, err := c.Do(req); err != nil {
+		t.Fatal(err)
+	}
+
+	r := multipart.NewReader(bytes.NewReader(rec.body), w.Boundary())
+	form, err := r.ReadForm(1 << 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if form.Value["chat_id"][0] != "-1001" || form.Value["message_thread_id"][0] != "5" {
+		t.Errorf("sendDocument to a topic sent %v", form.Value)
+	}
+	if len(form.File["document"]) != 1 || form.File["document"][0].Filename != "answer.md" {
+		t.Errorf("document lost: %v", form.File)
+	}
+}
+
+func TestTopicClientNotesUpdates(t *testing.T) {
+	s := NewTopicStore(t.TempDir(), false)
+	rec := &recordingClient{response: `{"ok":true,"result":[{"update_id":1,"message":{"message_id":7,"chat":{"id":-1001},"message_thread_id":3,"is_topic_message":true}}]}`}
+	c := &topicClient{topics: s, client: rec}
+	req, _ := http.NewRequest("POST", "https://api.telegram.org/botX/getUpdates", strings.NewReader("offset=0"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := c.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := io.ReadAll(resp.Body); !strings.Contains(string(b), `"update_id":1`) {
+		t.Error("getUpdates response not passed on")
+	}
+	if id := s.Take(-1001, 7); id != s.ID(topicKey{Chat: -1001, Thread: 3}) {
+		t.Errorf("polled topic message went to %d", id)
+	}
+}
+
+func TestTopicStorePerUser(t *testing.T) {
+	s := NewTopicStore(t.TempDir(), true)
+	s.NoteWebhookUpdate([]byte(`{"message":{"message_id":10,"chat":{"id":-1001,"type":"supergroup"},"from":{"id":7}}}`))
+	s.NoteWebhookUpdate([]byte(`{"message":{"message_id":11,"chat":{"id":-1001,"type":"supergroup"},"from":{"id":8}}}`))
+	s.NoteWebhookUpdate([]byte(`{"message":{"message_id":12,"chat":{"id":-1001,"type":"supergroup"},"from":{"id":7},"message_thread_id":5,"is_topic_message":true}}`))
+	s.NoteWebhookUpdate([]byte(`{"message":{"message_id":13,"chat":{"id":7,"type":"private"},"from":{"id":7}}}`))
+
+	a, b, topic := s.Take(-1001, 10), s.Take(-1001, 11), s.Take(-1001, 12)
+	if a == b || a == -1001 || b == -1001 {
+		t.Fatalf("members share a chat ID: %d, %d", a, b)
+	}
+	if topic == a || s.Resolve(topic) != (topicKey{Chat: -1001, Thread: 5, User: 7}) {
+		t.Errorf("member in a topic got %d (%+v)", topic, s.Resolve(topic))
+	}
+	if s.Chat(a) != -1001 {
+		t.Errorf("member conversation belongs to %d", s.Chat(a))
+	}
+	if id := s.Take(7, 13); id != 7 {
+		t.Errorf("private chat went to %d", id)
+	}
+}
+
+func TestTopicClientPerUser(t *testing.T) {
+	s := NewTopicStore(t.TempDir(), true)
+	s.NoteWebhookUpdate([]byte(`{"message":{"message_id":10,"chat":{"id":-1001,"type":"group"},"from":{"id":7}}}`))
+	member := s.Take(-1001, 10)
+	rec := &recordingClient{response: `{"ok":true,"result":{"message_id":20,"chat":{"id":-1001,"type":"group"}}}`}
+	c := &topicClient{topics: s, client: rec}
+
+	form := url.Values{"chat_id": {strconv.FormatInt(member, 10)}, "text": {"hi"}}
+	req, _ := http.NewRequest("POST", "https://api.telegram.org/botX/sendMessage", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if _, err := c.Do(req); err != nil {
+		t.Fatal(err)
+	}
+	got, _ := url.ParseQuery(string(rec.body))
+	if got.Get("chat_id") != "-1001" || got.Get("reply_to_message_id") != "10" || got.Has("message_thread_id") {
+		t.Errorf("sendMessage to a member sent %v", got)
+	}
+
+	// Anyone pressing a button on the reply acts in its owner's conversation.
+	s.NoteWebhookUpdate([]byte(`{"callback_query":{"message":{"message_id":20,"chat":{"id":-1001,"type":"group"},"from":{"id":1,"is_bot":true}}}}`))
+	if id := s.Take(-1001, 20); id != member {
+		t.Errorf("button press went to %d, want %d", id, member)
+	}
+}
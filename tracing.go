@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer is a no-op until SetupTracing installs a provider, so spans can be
+// created unconditionally.
+var tracer = otel.Tracer("trash-bot")
+
+// SetupTracing installs a global tracer provider according to
+// OTEL_TRACES_EXPORTER: "otlp" (OTLP/HTTP, configured via the standard
+// OTEL_EXPORTER_OTLP_* variables), "console" (stdout), or "none"/unset.
+// The returned func flushes pending spans.
+func SetupTracing(cfg *Config) (func(context.Context) error, error) {
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch cfg.TracesExporter {
+	case "", "none":
+		return func(context.Context) error { return nil }, nil
+	case "otlp":
+		exporter, err = otlptracehttp.New(context.Background())
+	case "console":
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	default:
+		return nil, fmt.Errorf("unknown OTEL_TRACES_EXPORTER %q (want otlp, console or none)", cfg.TracesExporter)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("create %s trace exporter: %w", cfg.TracesExporter, err)
+	}
+
+	// The default resource honors OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES.
+	tp := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter))
+	otel.SetTracerProvider(tp)
+	slog.Info("exporting trace spans", "exporter", cfg.TracesExporter)
+	return tp.Shutdown, nil
+}
+
+// endSpan records err on the span (if any) and ends it.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// sendReply applies the chat's guardrail checks and post-processors and
+// sends AI text to the chat inside a telegram.reply span. Code blocks longer than CODE_FILE_BYTES
+// are sent as documents, and so is the whole reply when it is longer than
+// the chat's reply_chars. With /voice on a voice note follows.
+func (h *Handlers) sendReply(ctx context.Context, chatID int64, text string) {
+	_, span := tracer.Start(ctx, "telegram.reply", trace.WithAttributes(
+		attribute.Int64("chat.id", chatID),
+		attribute.Int("reply.bytes", len(text)),
+	))
+	defer span.End()
+	text = h.postProcess(chatID, h.guardrails.Get(chatID).Apply(text))
+	text, questions := ParseAsks(text)
+	if len(questions) > 0 {
+		span.SetAttributes(attribute.Int("reply.questions", len(questions)))
+		defer h.sendAsks(chatID, questions)
+	}
+	if h.sendShortened(chatID, text) {
+		span.SetAttributes(attribute.Bool("reply.shortened", true))
+		h.sendVoiceReply(ctx, chatID, text)
+		return
+	}
+	for _, part := range splitCodeFiles(text, h.codeFileBytes) {
+		if part.file != "" {
+			h.sender.SendDocument(chatID, part.file, []byte(part.text), "")
+		} else if strings.TrimSpace(part.text) != "" {
+			h.sender.Send(chatID, part.text)
+		}
+	}
+	h.sendVoiceReply(ctx, chatID, text)
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestSetupTracing(t *testing.T) {
+	for _, exporter := range []string{"", "none"} {
+		shutdown, err := SetupTracing(&Config{TracesExporter: exporter})
+		if err != nil {
+			t.Fatalf("%q: %v", exporter, err)
+		}
+		if err := shutdown(context.Background()); err != nil {
+			t.Errorf("%q: shutdown: %v", exporter, err)
+		}
+	}
+	if _, err := SetupTracing(&Config{TracesExporter: "jaeger"}); err == nil {
+		t.Error("unknown exporter accepted")
+	}
+}
+
+var (
+	spansOnce sync.Once
+	spans     *tracetest.InMemoryExporter
+)
+
+// recordSpans installs a global tracer provider that keeps ended spans in
+// memory. Like SetupTracing it can only take effect once per process, so
+// every test shares the exporter; it is emptied when the test ends.
+func recordSpans(t *testing.T) *tracetest.InMemoryExporter {
+	spansOnce.Do(func() {
+		spans = tracetest.NewInMemoryExporter()
+		otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(spans)))
+	})
+	spans.Reset()
+	t.Cleanup(spans.Reset)
+	return spans
+}
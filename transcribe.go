@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Voice and audio messages are transcribed by the backend WHISPER_BACKEND
+// selects: the local whisper CLI, OpenAI's audio API (or any server with
+// the same /audio/transcriptions endpoint), or a faster-whisper server
+// speaking the whisper-asr-webservice /asr API.
+
+// transcriptionBackends are the WHISPER_BACKEND values.
+var transcriptionBackends = []string{"cli", "openai", "faster-whisper"}
+
+// transcribeTimeout bounds one transcription.
+const transcribeTimeout = 5 * time.Minute
+
+// Transcriber turns an audio file into text.
+type Transcriber interface {
+	Transcribe(ctx context.Context, path string) (string, error)
+}
+
+// NewTranscriber returns the backend cfg selects.
+func NewTranscriber(cfg *Config) Transcriber {
+	client := &http.Client{Timeout: transcribeTimeout}
+	switch cfg.WhisperBackend {
+	case "openai":
+		t := &openAITranscriber{
+			url:      strings.TrimRight(cfg.WhisperAPIURL, "/"),
+			apiKey:   cfg.WhisperAPIKey,
+			model:    cfg.WhisperModel,
+			language: cfg.WhisperLanguage,
+			client:   client,
+		}
+		if t.url == "" {
+			t.url = "https://api.openai.com/v1"
+		}
+		if t.model == "" {
+			t.model = "whisper-1"
+		}
+		return t
+	case "faster-whisper":
+		return &asrTranscriber{
+			url:      strings.TrimRight(cfg.WhisperAPIURL, "/"),
+			language: cfg.WhisperLanguage,
+			client:   client,
+		}
+	default:
+		t := &whisperCLI{cmd: cfg.WhisperCmd, model: cfg.WhisperModel, language: cfg.WhisperLanguage}
+		if t.model == "" {
+			t.model = "base"
+		}
+		return t
+	}
+}
+
+// whisperCLI runs the openai-whisper command line tool.
+type whisperCLI struct {
+	cmd      string
+	model    string
+	language string
+}
+
+func (w *whisperCLI) Transcribe(ctx context.Context, path string) (string, error) {
+	dir := filepath.Dir(path)
+
+	args := []string{path, "--model", w.model, "--output_format", "txt", "--output_dir", dir}
+	if w.language != "" {
+		args = append(args, "--language", w.language)
+	}
+	cmd := exec.CommandContext(ctx, w.cmd, args...)
+	ownGroup(cmd)
+	slog.Info("running transcription", "cmd", cmd.String())
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("whisper failed: %w\noutput: %s", err, string(output))
+	}
+
+	// Whisper writes <basename>.txt in the output dir.
+	base := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	txtPath := filepath.Join(dir, base+".txt")
+
+	transcript, err := os.ReadFile(txtPath)
+	if err != nil {
+		return "", fmt.Errorf("read transcript: %w", err)
+	}
+
+	// Clean up the txt file.
+	os.Remove(txtPath)
+	return string(transcript), nil
+}
+
+// openAITranscriber calls POST {url}/audio/transcriptions.
+type openAITranscriber struct {
+	url      string
+	apiKey   string
+	model    string
+	language string
+	client   *http.Client
+}
+
+func (o *openAITranscriber) Transcribe(ctx context.Context, path string) (string, error) {
+	fields := map[string]string{"model": o.model, "response_format": "text"}
+	if o.language != "" {
+		fields["language"] = o.language
+	}
+	body, contentType, err := multipartAudio("file", path, fields)
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.url+"/audio/transcriptions", body)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", contentType)
+	if o.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+o.apiKey)
+	}
+	return doTranscription(o.client, req)
+}
+
+// asrTranscriber calls POST {url}/asr, the API of whisper-asr-webservice
+// with its faster-whisper engine.
+type asrTranscriber struct {
+	url      string
+	language string
+	client   *http.Client
+}
+
+func (a *asrTranscriber) Transcribe(ctx context.Context, path string) (string, error) {
+	body, contentType, err := multipartAudio("audio_file", path, nil)
+	if err != nil {
+		return "", err
+	}
+	q := url.Values{"task": {"transcribe"}, "output": {"txt"}}
+	if a.language != "" {
+		q.Set("language", a.language)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.url+"/asr?"+q.Encode(), body)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", contentType)
+	return doTranscription(a.client, req)
+}
+
+// multipartAudio builds a multipart form with the file at path under
+// fileField and the given fields.
+func multipartAudio(fileField, path string, fields map[string]string) (io.Reader, string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, "", err
+	}
+	defer f.Close()
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	for k, v := range fields {
+		w.WriteField(k, v)
+	}
+	part, err := w.CreateFormFile(fileField, filepath.Base(path))
+	if err != nil {
+		return nil, "", err
+	}
+	if _, err := io.Copy(part, f); err != nil {
+		return nil, "", fmt.Errorf("read audio: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return nil, "", err
+	}
+	return &buf, w.FormDataContentType(), nil
+}
+
+// doTranscription sends req and returns the plain-text transcript.
+func doTranscription(client *http.Client, req *http.Request) (string, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("transcription request: %w", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("read transcription: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("transcription API returned %d: %s", resp.StatusCode, truncateText(string(data), 300))
+	}
+	return string(data), nil
+}
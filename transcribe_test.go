@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeAudio(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "voice.ogg")
+	if err := os.WriteFile(path, []byte("OggS-audio"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestOpenAITranscriber(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/audio/transcriptions" || r.Header.Get("Authorization") != "Bearer k" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		f, _, err := r.FormFile("file")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		audio, _ := io.ReadAll(f)
+		if string(audio) != "OggS-audio" || r.FormValue("model") != "whisper-1" || r.FormValue("language") != "it" || r.FormValue("response_format") != "text" {
+			http.Error(w, "unexpected form", http.StatusBadRequest)
+			return
+		}
+		io.WriteString(w, "ciao mondo\n")
+	}))
+	defer srv.Close()
+
+	tr := NewTranscriber(&Config{WhisperBackend: "openai", WhisperAPIURL: srv.URL + "/v1", WhisperAPIKey: "k", WhisperLanguage: "it"})
+	got, err := tr.Transcribe(context.Background(), writeAudio(t))
+	if err != nil || got != "ciao mondo\n" {
+		t.Errorf("Transcribe = %q, %v", got, err)
+	}
+
+	tr = NewTranscriber(&Config{WhisperBackend: "openai", WhisperAPIURL: srv.URL + "/v1", WhisperAPIKey: "wrong"})
+	if _, err := tr.Transcribe(context.Background(), writeAudio(t)); err == nil {
+		t.Error("error status not reported")
+	}
+}
+
+func TestASRTranscriber(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if r.URL.Path != "/asr" || q.Get("task") != "transcribe" || q.Get("output") != "txt" || q.Get("language") != "de" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		if _, _, err := r.FormFile("audio_file"); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		io.WriteString(w, "hallo welt")
+	}))
+	defer srv.Close()
+
+	tr := NewTranscriber(&Config{WhisperBackend: "faster-whisper", WhisperAPIURL: srv.URL + "/", WhisperLanguage: "de"})
+	got, err := tr.Transcribe(context.Background(), writeAudio(t))
+	if err != nil || got != "hallo welt" {
+		t.Errorf("Transcribe = %q, %v", got, err)
+	}
+}
+
+func TestWhisperCLI(t *testing.T) {
+	// A stand-in for whisper that writes its arguments as the transcript.
+	script := filepath.Join(t.TempDir(), "whisper")
+	os.WriteFile(script, []byte("#!/bin/sh\nout=\"$7/$(basename \"$1\" .ogg).txt\"\nshift\necho \"$@\" > \"$out\"\n"), 0o755)
+	tr := NewTranscriber(&Config{WhisperCmd: script, WhisperLanguage: "es"})
+	path := writeAudio(t)
+	got, err := tr.Transcribe(context.Background(), path)
+	want := "--model base --output_format txt --output_dir " + filepath.Dir(path) + " --language es\n"
+	if err != nil || got != want {
+		t.Errorf("Transcribe = %q, %v; want %q", got, err, want)
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(path), "voice.txt")); !os.IsNotExist(err) {
+		t.Error("transcript file left behind")
+	}
+}
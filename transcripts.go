@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Transcript entry kinds.
+const (
+	TranscriptUser      = "user"
+	TranscriptAssistant = "assistant"
+	TranscriptCommand   = "command"
+)
+
+// TranscriptEntry is one line of a chat transcript file.
+type TranscriptEntry struct {
+	Time     time.Time `json:"time"`
+	ChatID   int64     `json:"chat_id"`
+	Kind     string    `json:"kind"`
+	Actor    string    `json:"actor,omitempty"` // set when someone other than the chat's user sent it
+	Provider string    `json:"provider,omitempty"`
+	Text     string    `json:"text,omitempty"`
+	Command  string    `json:"command,omitempty"`
+	Output   string    `json:"output,omitempty"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// TranscriptLog appends conversation entries to per-chat, per-day JSONL files
+// under dir/<chatID>/YYYY-MM-DD.jsonl. Files older than the retention period
+// are deleted at startup and then once a day.
+type TranscriptLog struct {
+	mu        sync.Mutex
+	dir       string
+	retention time.Duration
+}
+
+// NewTranscriptLog returns a transcript log, or one whose Record is a no-op
+// when dir is empty. A zero retention keeps transcripts forever.
+func NewTranscriptLog(dir string, retention time.Duration) *TranscriptLog {
+	t := &TranscriptLog{dir: dir, retention: retention}
+	if dir != "" && retention > 0 {
+		go func() {
+			for {
+				t.Prune(time.Now())
+				time.Sleep(24 * time.Hour)
+			}
+		}()
+	}
+	return t
+}
+
+// Record appends an entry to the chat's transcript for the entry's day.
+func (t *TranscriptLog) Record(e TranscriptEntry) {
+	if t.dir == "" {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+	line, err := json.Marshal(e)
+	if err != nil {
+		slog.Error("marshal transcript entry failed", "chat_id", e.ChatID, "err", err)
+		return
+	}
+	chatDir := filepath.Join(t.dir, strconv.FormatInt(e.ChatID, 10))
+	path := filepath.Join(chatDir, e.Time.Format("2006-01-02")+".jsonl")
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err := os.MkdirAll(chatDir, 0o700); err != nil {
+		slog.Error("create transcript dir failed", "chat_id", e.ChatID, "err", err)
+		return
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		slog.Error("open transcript failed", "chat_id", e.ChatID, "err", err)
+		return
+	}
+	defer f.Close()
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		slog.Error("write transcript failed", "chat_id", e.ChatID, "err", err)
+	}
+}
+
+// Prune deletes transcript files whose day ended more than the retention
+// period before now, and removes chat directories left empty.
+func (t *TranscriptLog) Prune(now time.Time) {
+	if t.dir == "" || t.retention <= 0 {
+		return
+	}
+	cutoff := now.Add(-t.retention)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	chats, err := os.ReadDir(t.dir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			slog.Warn("read transcript dir failed", "err", err)
+		}
+		return
+	}
+	removed := 0
+	for _, chat := range chats {
+		if !chat.IsDir() {
+			continue
+		}
+		chatDir := filepath.Join(t.dir, chat.Name())
+		files, err := os.ReadDir(chatDir)
+		if err != nil {
+			continue
+		}
+		kept := 0
+		for _, f := range files {
+			day, err := time.Parse("2006-01-02", strings.TrimSuffix(f.Name(), ".jsonl"))
+			if err != nil || !strings.HasSuffix(f.Name(), ".jsonl") || !day.AddDate(0, 0, 1).Before(cutoff) {
+				kept++
+				continue
+			}
+			if err := os.Remove(filepath.Join(chatDir, f.Name())); err != nil {
+				slog.Warn("remove transcript failed", "file", f.Name(), "err", err)
+				kept++
+				continue
+			}
+			removed++
+		}
+		if kept == 0 {
+			os.Remove(chatDir)
+		}
+	}
+	if removed > 0 {
+		slog.Info("pruned old transcripts", "files", removed)
+	}
+}
+
+// errorText returns err's message, or "" for a nil error.
+func errorText(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTranscriptLogRecord(t *testing.T) {
+	dir := t.TempDir()
+	tl := NewTranscriptLog(dir, 0)
+	day := time.Date(2025, 3, 4, 10, 0, 0, 0, time.UTC)
+
+	tl.Record(TranscriptEntry{Time: day, ChatID: 42, Kind: TranscriptUser, Text: "list files"})
+	tl.Record(TranscriptEntry{Time: day.Add(time.Minute), ChatID: 42, Kind: TranscriptCommand, Command: "ls", Output: "a\nb"})
+
+	f, err := os.Open(filepath.Join(dir, "42", "2025-03-04.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var entries []TranscriptEntry
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var e TranscriptEntry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			t.Fatalf("bad line %q: %v", sc.Text(), err)
+		}
+		entries = append(entries, e)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("got %d entries, want 2", len(entries))
+	}
+	if entries[0].Kind != TranscriptUser || entries[1].Command != "ls" || entries[1].Output != "a\nb" {
+		t.Errorf("unexpected entries: %+v", entries)
+	}
+}
+
+func TestTranscriptLogDisabled(t *testing.T) {
+	tl := NewTranscriptLog("", 0)
+	tl.Record(TranscriptEntry{ChatID: 1, Kind: TranscriptUser, Text: "hi"}) // must not panic
+}
+
+func TestTranscriptLogPrune(t *testing.T) {
+	dir := t.TempDir()
+	tl := &TranscriptLog{dir: dir, retention: 7 * 24 * time.Hour}
+	now := time.Date(2025, 3, 20, 12, 0, 0, 0, time.UTC)
+
+	tl.Record(TranscriptEntry{Time: now.AddDate(0, 0, -30), ChatID: 1, Kind: TranscriptUser})
+	tl.Record(TranscriptEntry{Time: now.AddDate(0, 0, -2), ChatID: 2, Kind: TranscriptUser})
+	tl.Record(TranscriptEntry{Time: now.AddDate(0, 0, -30), ChatID: 2, Kind: TranscriptUser})
+	tl.Prune(now)
+
+	if _, err := os.Stat(filepath.Join(dir, "1")); !os.IsNotExist(err) {
+		t.Errorf("expected empty chat dir to be removed, err=%v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "2", "2025-02-18.jsonl")); !os.IsNotExist(err) {
+		t.Errorf("expected old transcript to be removed, err=%v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "2", "2025-03-18.jsonl")); err != nil {
+		t.Errorf("recent transcript should be kept: %v", err)
+	}
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// With /voice on, AI replies are also read out as a Telegram voice note,
+// the counterpart of whisper transcribing the user's voice messages. The
+// speech comes from TTS_CMD or from an OpenAI-compatible /audio/speech
+// endpoint (TTS_API_URL); either has to produce OGG/Opus, the format
+// Telegram plays as a voice note.
+
+// ttsTimeout bounds one synthesis.
+const ttsTimeout = 2 * time.Minute
+
+// TTS synthesizes speech for voice replies.
+type TTS struct {
+	cmd        string // run with sh -c, text on stdin, writes $TTS_OUTPUT
+	apiURL     string
+	apiKey     string
+	model      string
+	voice      string
+	maxChars   int
+	httpClient *http.Client
+}
+
+// NewTTS returns nil when neither TTS_CMD nor TTS_API_URL is set.
+func NewTTS(cfg *Config) *TTS {
+	if cfg.TTSCmd == "" && cfg.TTSAPIURL == "" {
+		return nil
+	}
+	t := &TTS{
+		cmd:        cfg.TTSCmd,
+		apiURL:     strings.TrimRight(cfg.TTSAPIURL, "/"),
+		apiKey:     cfg.TTSAPIKey,
+		model:      cfg.TTSModel,
+		voice:      cfg.TTSVoice,
+		maxChars:   cfg.TTSMaxChars,
+		httpClient: &http.Client{Timeout: ttsTimeout},
+	}
+	if t.model == "" {
+		t.model = "tts-1"
+	}
+	if t.voice == "" {
+		t.voice = "alloy"
+	}
+	return t
+}
+
+// Synthesize returns text as OGG/Opus audio.
+func (t *TTS) Synthesize(ctx context.Context, text string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, ttsTimeout)
+	defer cancel()
+	if t.cmd != "" {
+		return t.runCmd(ctx, text)
+	}
+	return t.callAPI(ctx, text)
+}
+
+func (t *TTS) runCmd(ctx context.Context, text string) ([]byte, error) {
+	dir, err := os.MkdirTemp("", "tts-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	out := filepath.Join(dir, "reply.ogg")
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", t.cmd)
+	ownGroup(cmd)
+	cmd.Env = append(os.Environ(), "TTS_OUTPUT="+out)
+	cmd.Stdin = strings.NewReader(text)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("TTS_CMD failed: %w\noutput: %s", err, truncateText(string(output), 500))
+	}
+	audio, err := os.ReadFile(out)
+	if err != nil {
+		return nil, fmt.Errorf("TTS_CMD wrote no audio: %w", err)
+	}
+	return audio, nil
+}
+
+func (t *TTS) callAPI(ctx context.Context, text string) ([]byte, error) {
+	body, _ := json.Marshal(map[string]string{
+		"model":           t.model,
+		"voice":           t.voice,
+		"input":           text,
+		"response_format": "opus",
+	})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.apiURL+"/audio/speech", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if t.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+t.apiKey)
+	}
+	resp, err := t.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("tts request: %w", err)
+	}
+	defer resp.Body.Close()
+	audio, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read tts response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("tts API returned %d: %s", resp.StatusCode, truncateText(string(audio), 300))
+	}
+	return audio, nil
+}
+
+var (
+	speechCodeBlock  = regexp.MustCompile("(?s)```.*?(```|$)")
+	speechInlineCode = regexp.MustCompile("`([^`]*)`")
+	speechLink       = regexp.MustCompile(`\[([^\]]*)\]\([^)]*\)`)
+	speechMarkup     = regexp.MustCompile(`[*_~#>|]+`)
+	speechBlankLines = regexp.MustCompile(`\n{3,}`)
+)
+
+// speechText turns a reply into something worth reading aloud: code blocks
+// are left out, markdown markup is dropped, and the text is cut at a
+// sentence end within maxChars characters (0 = no limit).
+func speechText(text string, maxChars int) string {
+	text = speechCodeBlock.ReplaceAllString(text, "")
+	text = speechInlineCode.ReplaceAllString(text, "$1")
+	text = speechLink.ReplaceAllString(text, "$1")
+	text = speechMarkup.ReplaceAllString(text, "")
+	text = strings.TrimSpace(speechBlankLines.ReplaceAllString(text, "\n\n"))
+	if maxChars <= 0 || utf8.RuneCountInString(text) <= maxChars {
+		return text
+	}
+	cut := string([]rune(text)[:maxChars])
+	if i := strings.LastIndexAny(cut, ".!?\n"); i > len(cut)/2 {
+		cut = cut[:i+1]
+	}
+	return strings.TrimSpace(cut) + " …"
+}
+
+// sendVoiceReply reads a reply out as a voice note when the chat has /voice
+// on. Failures are logged: the text reply has already been sent.
+func (h *Handlers) sendVoiceReply(ctx context.Context, chatID int64, text string) {
+	if h.tts == nil || !h.settings.Get(chatID).Voice {
+		return
+	}
+	speech := speechText(text, h.tts.maxChars)
+	if speech == "" {
+		return
+	}
+	audio, err := h.tts.Synthesize(ctx, speech)
+	if err != nil {
+		slog.Warn("speech synthesis failed", "chat_id", chatID, "err", err)
+		return
+	}
+	h.sender.SendVoice(chatID, audio)
+}
+
+// HandleVoiceMode shows or sets whether the chat's AI replies are also sent
+// as voice notes.
+// Usage: /voice [on|off]
+func (h *Handlers) HandleVoiceMode(chatID int64, args string) {
+	a, ok := h.parseCommandArgs(chatID, "voice", args)
+	if !ok {
+		return
+	}
+	switch strings.ToLower(a.Arg(0)) {
+	case "":
+		h.sender.SendPlain(chatID, h.t(chatID, "voice.state", h.onOff(chatID, h.settings.Get(chatID).Voice)))
+		return
+	case "on", "off":
+	default:
+		h.sendUsage(chatID, "voice")
+		return
+	}
+	on := strings.EqualFold(a.Arg(0), "on")
+	if on && h.tts == nil {
+		h.sender.SendPlain(chatID, h.t(chatID, "voice.unavailable"))
+		return
+	}
+	err := h.settings.Update(chatID, func(s *ChatSettings) error {
+		s.Voice = on
+		return nil
+	})
+	if err != nil {
+		h.sender.SendPlain(chatID, h.t(chatID, "error", err))
+		return
+	}
+	if on {
+		h.sender.SendPlain(chatID, h.t(chatID, "voice.on"))
+	} else {
+		h.sender.SendPlain(chatID, h.t(chatID, "voice.off"))
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSpeechText(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		max  int
+		want string
+	}{
+		{"Run `make test` and see [the docs](https://x.y).", 0, "Run make test and see the docs."},
+		{"**Done.**\n\n```sh\nrm -rf build\n```\n\n\n\nAll clean.", 0, "Done.\n\nAll clean."},
+		{"```\nonly code\n```", 0, ""},
+		{"First sentence here. Second sentence is long.", 30, "First sentence here. …"},
+		{"no sentence end at all in this text", 10, "no sentenc …"},
+	} {
+		if got := speechText(tc.in, tc.max); got != tc.want {
+			t.Errorf("speechText(%q, %d) = %q, want %q", tc.in, tc.max, got, tc.want)
+		}
+	}
+}
+
+func TestTTSCmd(t *testing.T) {
+	tts := NewTTS(&Config{TTSCmd: `tr a-z A-Z > "$TTS_OUTPUT"`})
+	audio, err := tts.Synthesize(context.Background(), "hello")
+	if err != nil || string(audio) != "HELLO" {
+		t.Errorf("Synthesize = %q, %v", audio, err)
+	}
+
+	tts = NewTTS(&Config{TTSCmd: "true"})
+	if _, err := tts.Synthesize(context.Background(), "hello"); err == nil {
+		t.Error("command that wrote nothing succeeded")
+	}
+	if NewTTS(&Config{}) != nil {
+		t.Error("TTS without TTS_CMD or TTS_API_URL")
+	}
+}
+
+func TestTTSAPI(t *testing.T) {
+	var got map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/audio/speech" || r.Header.Get("Authorization") != "Bearer k" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+		w.Write([]byte("OggS"))
+	}))
+	defer srv.Close()
+
+	tts := NewTTS(&Config{TTSAPIURL: srv.URL + "/v1/", TTSAPIKey: "k", TTSVoice: "nova"})
+	audio, err := tts.Synthesize(context.Background(), "hi")
+	if err != nil || string(audio) != "OggS" {
+		t.Fatalf("Synthesize = %q, %v", audio, err)
+	}
+	if got["input"] != "hi" || got["voice"] != "nova" || got["model"] != "tts-1" || got["response_format"] != "opus" {
+		t.Errorf("request = %v", got)
+	}
+
+	tts.apiKey = "wrong"
+	if _, err := tts.Synthesize(context.Background(), "hi"); err == nil {
+		t.Error("error status not reported")
+	}
+}
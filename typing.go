@@ -0,0 +1,49 @@
+package main
+
+import (
+	"strings"
+	"time"
+)
+
+// keepTyping repeats the typing indicator every TYPING_INTERVAL until done is
+// closed. Telegram shows the indicator for about 5 seconds per call.
+func (h *Handlers) keepTyping(chatID int64, done <-chan struct{}) {
+	if h.typingEvery <= 0 {
+		return
+	}
+	ticker := time.NewTicker(h.typingEvery)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			h.sender.SendTyping(chatID)
+		case <-done:
+			return
+		}
+	}
+}
+
+// HandleTyping shows or toggles typing indicators for the chat.
+// Usage: /typing [on|off]
+func (h *Handlers) HandleTyping(chatID int64, args string) {
+	if h.typingEvery <= 0 {
+		h.sender.SendPlain(chatID, h.t(chatID, "typing.global_off"))
+		return
+	}
+	switch strings.ToLower(strings.TrimSpace(args)) {
+	case "":
+		key := "typing.state_on"
+		if !h.sender.TypingEnabled(chatID) {
+			key = "typing.state_off"
+		}
+		h.sender.SendPlain(chatID, h.t(chatID, key, h.typingEvery))
+	case "on":
+		h.sender.SetTyping(chatID, true)
+		h.sender.SendPlain(chatID, h.t(chatID, "typing.enabled"))
+	case "off":
+		h.sender.SetTyping(chatID, false)
+		h.sender.SendPlain(chatID, h.t(chatID, "typing.disabled"))
+	default:
+		h.sendUsage(chatID, "typing")
+	}
+}
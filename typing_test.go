@@ -0,0 +1,59 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestKeepTyping(t *testing.T) {
+	tg := &fakeTelegram{}
+	api, err := tgbotapi.NewBotAPIWithClient("token", tgbotapi.APIEndpoint, tg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := &Handlers{sender: NewSender(api, nil), typingEvery: 20 * time.Millisecond}
+	actions := func() int {
+		tg.mu.Lock()
+		defer tg.mu.Unlock()
+		n := 0
+		for _, m := range tg.calls {
+			if m == "sendChatAction" {
+				n++
+			}
+		}
+		return n
+	}
+	// typeFor runs keepTyping for d and returns the indicators sent.
+	typeFor := func(d time.Duration) int {
+		before := actions()
+		done := make(chan struct{})
+		stopped := make(chan struct{})
+		go func() {
+			h.keepTyping(1, done)
+			close(stopped)
+		}()
+		time.Sleep(d)
+		close(done)
+		<-stopped
+		return actions() - before
+	}
+
+	if n := typeFor(110 * time.Millisecond); n < 3 || n > 6 {
+		t.Errorf("sent %d indicators in 110ms at a 20ms cadence", n)
+	}
+	if n := typeFor(0); n != 0 {
+		t.Errorf("sent %d indicators after done", n)
+	}
+
+	h.sender.SetTyping(1, false)
+	if n := typeFor(60 * time.Millisecond); n != 0 {
+		t.Errorf("/typing off: sent %d indicators", n)
+	}
+	h.sender.SetTyping(1, true)
+	h.sender.DisableTyping()
+	if n := typeFor(60 * time.Millisecond); n != 0 {
+		t.Errorf("TYPING_INTERVAL=off: sent %d indicators", n)
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"log/slog"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// UsageLedger accumulates usage per chat between scheduled reports. Unlike
+// UsageTracker it is not reset by /new, and it is persisted so a restart
+// does not lose the current period.
+type UsageLedger struct {
+	mu    sync.Mutex
+	path  string
+	Since time.Time            `json:"since"`
+	Chats map[int64]*ChatUsage `json:"chats"`
+	// Spending across all chats on the current local day, for
+	// DAILY_BUDGET_USD. Reports do not reset it.
+	Day    string  `json:"day,omitempty"`
+	DayUSD float64 `json:"day_usd,omitempty"`
+}
+
+func NewUsageLedger(dataDir string) *UsageLedger {
+	l := &UsageLedger{
+		path:  filepath.Join(dataDir, "usage_ledger.json"),
+		Since: time.Now(),
+		Chats: make(map[int64]*ChatUsage),
+	}
+	if err := loadJSONFile(l.path, l); err != nil {
+		slog.Warn("failed to load usage ledger", "path", l.path, "err", err)
+	}
+	if l.Chats == nil {
+		l.Chats = make(map[int64]*ChatUsage)
+	}
+	return l
+}
+
+// Record adds one provider call for a chat.
+func (l *UsageLedger) Record(chatID int64, provider string, cost float64, in, out int64, d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	s := l.Chats[chatID]
+	if s == nil {
+		s = &ChatUsage{}
+		l.Chats[chatID] = s
+	}
+	s.add(provider, cost, in, out, d)
+	if day := time.Now().Format(time.DateOnly); day != l.Day {
+		l.Day, l.DayUSD = day, 0
+	}
+	l.DayUSD += cost
+}
+
+// SpentOn returns the spending across all chats on now's local day.
+func (l *UsageLedger) SpentOn(now time.Time) float64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.Day != now.Format(time.DateOnly) {
+		return 0
+	}
+	return l.DayUSD
+}
+
+// Flush returns the period's usage and starts a new period.
+func (l *UsageLedger) Flush() (since time.Time, chats map[int64]*ChatUsage) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	since, chats = l.Since, l.Chats
+	l.Since, l.Chats = time.Now(), make(map[int64]*ChatUsage)
+	l.saveLocked()
+	return since, chats
+}
+
+// Save writes the current period to disk.
+func (l *UsageLedger) Save() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.saveLocked()
+}
+
+func (l *UsageLedger) saveLocked() {
+	if err := saveJSONFile(l.path, l); err != nil {
+		slog.Warn("failed to save usage ledger", "path", l.path, "err", err)
+	}
+}
+
+// formatUsageReport renders a period's usage, busiest chats (by cost, then
+// calls) first.
+func formatUsageReport(lang string, since, until time.Time, chats map[int64]*ChatUsage) string {
+	ids := make([]int64, 0, len(chats))
+	var total ChatUsage
+	for id, c := range chats {
+		ids = append(ids, id)
+		total.NumCalls += c.NumCalls
+		total.InputTokens += c.InputTokens
+		total.OutputTokens += c.OutputTokens
+		total.TotalCostUSD += c.TotalCostUSD
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		a, b := chats[ids[i]], chats[ids[j]]
+		if a.TotalCostUSD != b.TotalCostUSD {
+			return a.TotalCostUSD > b.TotalCostUSD
+		}
+		if a.NumCalls != b.NumCalls {
+			return a.NumCalls > b.NumCalls
+		}
+		return ids[i] < ids[j]
+	})
+
+	var b strings.Builder
+	b.WriteString(translate(lang, "usage.title", since.Format("2006-01-02 15:04"), until.Format("2006-01-02 15:04")))
+	if len(ids) == 0 {
+		b.WriteString(translate(lang, "usage.report_none"))
+		return b.String()
+	}
+	b.WriteString(translate(lang, "usage.total",
+		total.NumCalls, total.InputTokens, total.OutputTokens, total.TotalCostUSD))
+	for _, id := range ids {
+		c := chats[id]
+		b.WriteString(translate(lang, "usage.chat", id, c.NumCalls, c.InputTokens, c.OutputTokens, c.TotalCostUSD))
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// runUsageReports posts the usage report to the report chat whenever the
+// schedule matches, checking once per minute. It never returns.
+func (h *Handlers) runUsageReports() {
+	for {
+		now := time.Now()
+		next := now.Truncate(time.Minute).Add(time.Minute)
+		time.Sleep(next.Sub(now))
+		if !h.reportSchedule.Matches(next) {
+			continue
+		}
+		since, chats := h.ledger.Flush()
+		slog.Info("posting usage report", "chat_id", h.reportChatID, "chats", len(chats))
+		h.sender.SendPlain(h.reportChatID, formatUsageReport(h.uiLanguage(h.reportChatID), since, next, chats))
+	}
+}
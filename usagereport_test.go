@@ -0,0 +1,22 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormatUsageReport(t *testing.T) {
+	since := time.Date(2025, 3, 3, 9, 0, 0, 0, time.UTC)
+	chats := map[int64]*ChatUsage{
+		1: {NumCalls: 2, InputTokens: 100, OutputTokens: 10, TotalCostUSD: 0.01},
+		2: {NumCalls: 5, InputTokens: 900, OutputTokens: 90, TotalCostUSD: 0.50},
+	}
+	got := formatUsageReport("en", since, since.AddDate(0, 0, 7), chats)
+	if !strings.Contains(got, "Total: 7 calls, 1000 in / 100 out tokens, $0.5100") {
+		t.Errorf("missing totals:\n%s", got)
+	}
+	if strings.Index(got, "  2: ") > strings.Index(got, "  1: ") {
+		t.Errorf("costlier chat should come first:\n%s", got)
+	}
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Usage is attributed to the Telegram user whose message (or button press)
+// started the turn, so shared group chats show who spent what, and
+// USER_DAILY_TOKENS / USER_DAILY_USD cap each user's AI use per local day
+// across all chats.
+
+// chatUser is the Telegram user a turn belongs to.
+type chatUser struct {
+	ID   int64
+	Name string
+}
+
+// userKey carries the Telegram user whose message or button press started
+// the turn.
+type userKey struct{}
+
+// withUser records the user a turn belongs to, for attributing its usage
+// and checking their quota. Turns without one, such as scheduled jobs,
+// count for the chat only.
+func withUser(ctx context.Context, user chatUser) context.Context {
+	if user.ID == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, userKey{}, user)
+}
+
+// userFrom returns the user ctx's turn belongs to, if known.
+func userFrom(ctx context.Context) (chatUser, bool) {
+	u, ok := ctx.Value(userKey{}).(chatUser)
+	return u, ok
+}
+
+// UserDay is one user's usage on the current day.
+type UserDay struct {
+	Name    string  `json:"name,omitempty"`
+	Tokens  int64   `json:"tokens"`
+	CostUSD float64 `json:"cost_usd"`
+}
+
+// UserQuotas tracks each user's usage for the current local day, persisted
+// to DATA_DIR so a restart does not reset anyone's quota.
+type UserQuotas struct {
+	maxTokens int64   // 0 = unlimited
+	maxUSD    float64 // 0 = unlimited
+
+	mu    sync.Mutex
+	path  string
+	Day   string             `json:"day"`
+	Users map[int64]*UserDay `json:"users"`
+}
+
+func NewUserQuotas(dataDir string, maxTokens int64, maxUSD float64) *UserQuotas {
+	q := &UserQuotas{
+		maxTokens: maxTokens,
+		maxUSD:    maxUSD,
+		path:      filepath.Join(dataDir, "user_usage.json"),
+		Users:     make(map[int64]*UserDay),
+	}
+	if err := loadJSONFile(q.path, q); err != nil {
+		slog.Warn("failed to load user usage", "path", q.path, "err", err)
+	}
+	if q.Users == nil {
+		q.Users = make(map[int64]*UserDay)
+	}
+	return q
+}
+
+// Record adds a call's tokens and cost to user's day.
+func (q *UserQuotas) Record(user chatUser, tokens int64, cost float64, now time.Time) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.rollLocked(now)
+	d := q.Users[user.ID]
+	if d == nil {
+		d = &UserDay{}
+		q.Users[user.ID] = d
+	}
+	d.Name = user.Name
+	d.Tokens += tokens
+	d.CostUSD += cost
+	if err := saveJSONFile(q.path, q); err != nil {
+		slog.Warn("failed to save user usage", "path", q.path, "err", err)
+	}
+}
+
+// Exceeded returns why userID may not make more AI calls today, or "" if
+// they may.
+func (q *UserQuotas) Exceeded(userID int64, now time.Time) string {
+	if q.maxTokens <= 0 && q.maxUSD <= 0 {
+		return ""
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.rollLocked(now)
+	d := q.Users[userID]
+	if d == nil {
+		return ""
+	}
+	if q.maxTokens > 0 && d.Tokens >= q.maxTokens {
+		return fmt.Sprintf("%d of %d tokens used", d.Tokens, q.maxTokens)
+	}
+	if q.maxUSD > 0 && d.CostUSD >= q.maxUSD {
+		return fmt.Sprintf("$%.2f of $%.2f spent", d.CostUSD, q.maxUSD)
+	}
+	return ""
+}
+
+// rollLocked starts a new day's counts once the local date changes.
+func (q *UserQuotas) rollLocked(now time.Time) {
+	if day := now.Format(time.DateOnly); day != q.Day {
+		q.Day = day
+		clear(q.Users)
+	}
+}
+
+// attributeUsage adds a provider call to the usage of the user whose turn
+// it was: their share of the chat's /usage, and their daily quota.
+func (h *Handlers) attributeUsage(ctx context.Context, chatID int64, cost float64, in, out int64) {
+	user, ok := userFrom(ctx)
+	if !ok {
+		return
+	}
+	h.usage.RecordUser(chatID, user, cost, in, out)
+	h.quotas.Record(user, in+out, cost, time.Now())
+}
+
+// rejectIfOverQuota tells the user whose turn it is that their daily
+// quota is used up and returns true if so.
+func (h *Handlers) rejectIfOverQuota(ctx context.Context, chatID int64) bool {
+	user, ok := userFrom(ctx)
+	if !ok {
+		return false
+	}
+	reason := h.quotas.Exceeded(user.ID, time.Now())
+	if reason == "" {
+		return false
+	}
+	slog.Info("rejected: user daily quota reached", "chat_id", chatID, "user_id", user.ID, "reason", reason)
+	h.sender.SendPlain(chatID, h.t(chatID, "quota_exceeded", reason))
+	return true
+}
+
+// formatUserUsage lists each user's share of a chat's usage, biggest
+// spender (by cost, then tokens) first.
+func formatUserUsage(users map[int64]*UserUsage) string {
+	ids := make([]int64, 0, len(users))
+	for id := range users {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		a, b := users[ids[i]], users[ids[j]]
+		if a.CostUSD != b.CostUSD {
+			return a.CostUSD > b.CostUSD
+		}
+		if ta, tb := a.InputTokens+a.OutputTokens, b.InputTokens+b.OutputTokens; ta != tb {
+			return ta > tb
+		}
+		return ids[i] < ids[j]
+	})
+	var b strings.Builder
+	for _, id := range ids {
+		u := users[id]
+		name := u.Name
+		if name == "" {
+			name = fmt.Sprint(id)
+		}
+		fmt.Fprintf(&b, "\n  %s: %d calls, %d in / %d out tokens, $%.4f", name, u.NumCalls, u.InputTokens, u.OutputTokens, u.CostUSD)
+	}
+	return b.String()
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUserQuotas(t *testing.T) {
+	dir := t.TempDir()
+	q := NewUserQuotas(dir, 1000, 0.5)
+	alice := chatUser{ID: 7, Name: "@alice"}
+	day1 := time.Date(2026, 3, 1, 10, 0, 0, 0, time.Local)
+
+	q.Record(alice, 600, 0.1, day1)
+	if r := q.Exceeded(alice.ID, day1); r != "" {
+		t.Fatalf("under quota: got %q", r)
+	}
+	q.Record(alice, 400, 0.1, day1)
+	if r := q.Exceeded(alice.ID, day1); !strings.Contains(r, "1000 of 1000 tokens") {
+		t.Fatalf("token quota: got %q", r)
+	}
+	if r := q.Exceeded(8, day1); r != "" {
+		t.Fatalf("other user: got %q", r)
+	}
+
+	// The count survives a restart and resets the next day.
+	q = NewUserQuotas(dir, 1000, 0.5)
+	if q.Exceeded(alice.ID, day1) == "" {
+		t.Fatal("usage lost across restart")
+	}
+	if r := q.Exceeded(alice.ID, day1.Add(24*time.Hour)); r != "" {
+		t.Fatalf("next day: got %q", r)
+	}
+
+	q.Record(alice, 10, 0.6, day1.Add(24*time.Hour))
+	if r := q.Exceeded(alice.ID, day1.Add(24*time.Hour)); !strings.Contains(r, "$0.60 of $0.50") {
+		t.Fatalf("cost quota: got %q", r)
+	}
+}
+
+func TestAttributeUsageFollowsTurn(t *testing.T) {
+	h := &Handlers{quotas: NewUserQuotas(t.TempDir(), 100, 0), usage: NewUsageTracker(t.TempDir())}
+	alice := withUser(context.Background(), chatUser{ID: 7, Name: "@alice"})
+	bob := withUser(context.Background(), chatUser{ID: 8, Name: "@bob"})
+
+	// Interleaved turns in one chat each count for the user who sent them.
+	h.attributeUsage(bob, 1, 0.01, 100, 50)
+	h.attributeUsage(alice, 1, 0.01, 5, 5)
+	now := time.Now()
+	if h.quotas.Exceeded(8, now) == "" {
+		t.Error("bob's turn not counted for bob")
+	}
+	if r := h.quotas.Exceeded(7, now); r != "" {
+		t.Errorf("alice charged for bob's turn: %q", r)
+	}
+
+	// A turn nobody sent, such as a scheduled job, counts for no one.
+	h.attributeUsage(context.Background(), 1, 1, 1000, 1000)
+	if _, ok := userFrom(withUser(context.Background(), chatUser{})); ok {
+		t.Error("a turn without a sender has a user")
+	}
+	if r := h.quotas.Exceeded(7, now); r != "" {
+		t.Errorf("alice charged for a turn nobody sent: %q", r)
+	}
+}
+
+func TestFormatUserUsage(t *testing.T) {
+	got := formatUserUsage(map[int64]*UserUsage{
+		1: {Name: "@bob", ProviderUsage: ProviderUsage{CostUSD: 0.01, NumCalls: 1}},
+		2: {Name: "@alice", ProviderUsage: ProviderUsage{CostUSD: 0.20, NumCalls: 3}},
+	})
+	if strings.Index(got, "@alice") > strings.Index(got, "@bob") {
+		t.Errorf("biggest spender should come first:\n%s", got)
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Chat variables keep frequently used values (hosts, paths, IDs) out of the
+// AI's memory: {{NAME}} in a prompt or a command is replaced with the
+// chat's value before it is sent or run.
+
+var (
+	varNameRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	varRefRe  = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_]*)\s*\}\}`)
+)
+
+// maxVars caps the variables of one chat.
+const maxVars = 50
+
+// VarStore holds per-chat variables, persisted to DATA_DIR.
+type VarStore struct {
+	mu    sync.RWMutex
+	path  string
+	chats map[int64]map[string]string
+}
+
+func NewVarStore(dataDir string) *VarStore {
+	s := &VarStore{
+		path:  filepath.Join(dataDir, "vars.json"),
+		chats: make(map[int64]map[string]string),
+	}
+	if err := loadJSONFile(s.path, &s.chats); err != nil {
+		slog.Warn("failed to load variables", "path", s.path, "err", err)
+	}
+	return s
+}
+
+// List returns a copy of the chat's variables.
+func (s *VarStore) List(chatID int64) map[string]string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make(map[string]string, len(s.chats[chatID]))
+	for k, v := range s.chats[chatID] {
+		out[k] = v
+	}
+	return out
+}
+
+// Set defines or replaces a variable.
+func (s *VarStore) Set(chatID int64, name, value string) error {
+	if !varNameRe.MatchString(name) {
+		return fmt.Errorf("invalid name %q (letters, digits and _, not starting with a digit)", name)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	vars := s.chats[chatID]
+	if vars == nil {
+		vars = make(map[string]string)
+		s.chats[chatID] = vars
+	}
+	if _, ok := vars[name]; !ok && len(vars) >= maxVars {
+		return fmt.Errorf("too many variables (max %d)", maxVars)
+	}
+	vars[name] = value
+	return saveJSONFile(s.path, s.chats)
+}
+
+// Unset removes a variable, reporting whether it existed.
+func (s *VarStore) Unset(chatID int64, name string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.chats[chatID][name]; !ok {
+		return false, nil
+	}
+	delete(s.chats[chatID], name)
+	if len(s.chats[chatID]) == 0 {
+		delete(s.chats, chatID)
+	}
+	return true, saveJSONFile(s.path, s.chats)
+}
+
+// Expand replaces {{NAME}} references to the chat's variables in text.
+// Unknown names are left as written.
+func (s *VarStore) Expand(chatID int64, text string) string {
+	if !strings.Contains(text, "{{") {
+		return text
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	vars := s.chats[chatID]
+	if len(vars) == 0 {
+		return text
+	}
+	return varRefRe.ReplaceAllStringFunc(text, func(ref string) string {
+		if v, ok := vars[varRefRe.FindStringSubmatch(ref)[1]]; ok {
+			return v
+		}
+		return ref
+	})
+}
+
+// HandleVar lists, sets or removes the chat's variables.
+// Usage: /var [list] | /var set <NAME> <value> | /var unset <NAME>
+func (h *Handlers) HandleVar(chatID int64, args string) {
+	a, ok := h.parseCommandArgs(chatID, "var", args)
+	if !ok {
+		return
+	}
+	sub := strings.ToLower(a.Arg(0))
+	if sub == "" {
+		sub = "list"
+	}
+
+	switch sub {
+	case "list":
+		vars := h.vars.List(chatID)
+		if len(vars) == 0 {
+			h.sender.SendPlain(chatID, h.t(chatID, "var.none"))
+			return
+		}
+		names := make([]string, 0, len(vars))
+		for name := range vars {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		var b strings.Builder
+		b.WriteString(h.t(chatID, "var.list"))
+		for _, name := range names {
+			fmt.Fprintf(&b, "  {{%s}} = %s\n", name, vars[name])
+		}
+		h.sender.SendPlain(chatID, strings.TrimRight(b.String(), "\n"))
+
+	case "set":
+		if a.Len() < 3 {
+			h.sender.SendPlain(chatID, h.t(chatID, "var.set_usage"))
+			return
+		}
+		name := a.Arg(1)
+		if err := h.vars.Set(chatID, name, a.Rest(2)); err != nil {
+			h.sender.SendPlain(chatID, h.t(chatID, "var.set_failed", err))
+			return
+		}
+		slog.Info("variable set", "chat_id", chatID, "name", name)
+		h.sender.SendPlain(chatID, h.t(chatID, "var.set", name))
+
+	case "unset":
+		if a.Len() < 2 {
+			h.sender.SendPlain(chatID, h.t(chatID, "var.unset_usage"))
+			return
+		}
+		name := a.Arg(1)
+		found, err := h.vars.Unset(chatID, name)
+		switch {
+		case err != nil:
+			h.sender.SendPlain(chatID, h.t(chatID, "var.unset_failed", err))
+		case !found:
+			h.sender.SendPlain(chatID, h.t(chatID, "var.unknown", name))
+		default:
+			slog.Info("variable removed", "chat_id", chatID, "name", name)
+			h.sender.SendPlain(chatID, h.t(chatID, "var.removed", name))
+		}
+
+	default:
+		h.sendUsage(chatID, "var")
+	}
+}
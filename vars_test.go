@@ -0,0 +1,40 @@
+package main
+
+import "testing"
+
+func TestVarStore(t *testing.T) {
+	dir := t.TempDir()
+	s := NewVarStore(dir)
+	if err := s.Set(1, "SERVER", "10.0.0.5"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Set(1, "APP_DIR", "/srv/app"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Set(1, "9lives", "x"); err == nil {
+		t.Error("invalid name accepted")
+	}
+
+	got := s.Expand(1, "ssh {{SERVER}} 'ls {{ APP_DIR }}' {{OTHER}} {{server}}")
+	if want := "ssh 10.0.0.5 'ls /srv/app' {{OTHER}} {{server}}"; got != want {
+		t.Errorf("Expand = %q, want %q", got, want)
+	}
+	if got := s.Expand(2, "ssh {{SERVER}}"); got != "ssh {{SERVER}}" {
+		t.Errorf("other chat expanded: %q", got)
+	}
+
+	// Variables survive a restart.
+	s = NewVarStore(dir)
+	if v := s.List(1)["SERVER"]; v != "10.0.0.5" {
+		t.Errorf("reloaded SERVER = %q", v)
+	}
+	if found, err := s.Unset(1, "SERVER"); !found || err != nil {
+		t.Errorf("Unset = %v, %v", found, err)
+	}
+	if found, _ := s.Unset(1, "SERVER"); found {
+		t.Error("Unset found a removed variable")
+	}
+	if got := s.Expand(1, "{{SERVER}}"); got != "{{SERVER}}" {
+		t.Errorf("removed variable expanded: %q", got)
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Videos and video notes reach the AI as text plus images: ffmpeg pulls
+// the audio track out for the transcription backend and grabs up to
+// VIDEO_KEYFRAMES frames spread over the video, which the AI reads like a
+// photo. Without ffmpeg the AI only gets the video's path.
+
+// maxVideoKeyframes caps VIDEO_KEYFRAMES.
+const maxVideoKeyframes = 10
+
+// ffmpegTimeout bounds one ffmpeg run.
+const ffmpegTimeout = 2 * time.Minute
+
+// errNoAudio is returned by ExtractAudio for a video without sound.
+var errNoAudio = errors.New("video has no audio track")
+
+// runFFmpeg runs ffmpeg quietly with args.
+func (m *MediaHandler) runFFmpeg(ctx context.Context, args ...string) error {
+	ctx, cancel := context.WithTimeout(ctx, ffmpegTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, m.ffmpeg, append([]string{"-nostdin", "-loglevel", "error", "-y"}, args...)...)
+	ownGroup(cmd)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("ffmpeg failed: %w\noutput: %s", err, truncateText(string(output), 500))
+	}
+	return nil
+}
+
+// ExtractAudio writes the video's audio track next to it as 16 kHz mono
+// WAV, which every transcription backend accepts, and returns its path.
+func (m *MediaHandler) ExtractAudio(ctx context.Context, video string) (string, error) {
+	out := strings.TrimSuffix(video, filepath.Ext(video)) + ".wav"
+	if err := m.runFFmpeg(ctx, "-i", video, "-vn", "-map", "0:a:0", "-ac", "1", "-ar", "16000", out); err != nil {
+		os.Remove(out)
+		if strings.Contains(err.Error(), "matches no streams") {
+			return "", errNoAudio
+		}
+		return "", err
+	}
+	return out, nil
+}
+
+// ExtractFrames saves up to VIDEO_KEYFRAMES JPEG frames of a video of the
+// given duration in seconds, one from the middle of each equal stretch,
+// and returns their paths.
+func (m *MediaHandler) ExtractFrames(ctx context.Context, video string, duration int) ([]string, error) {
+	n := min(m.keyframes, max(duration, 1))
+	base := strings.TrimSuffix(video, filepath.Ext(video))
+	var frames []string
+	for i := range n {
+		at := float64(duration) * (float64(i) + 0.5) / float64(n)
+		out := fmt.Sprintf("%s_frame%d.jpg", base, i+1)
+		if err := m.runFFmpeg(ctx, "-ss", strconv.FormatFloat(at, 'f', 2, 64), "-i", video, "-frames:v", "1", "-q:v", "3", out); err != nil {
+			m.Cleanup(frames...)
+			return nil, err
+		}
+		// Seeking past the last frame succeeds without writing anything.
+		if _, err := os.Stat(out); err == nil {
+			frames = append(frames, out)
+		}
+	}
+	return frames, nil
+}
+
+// formatDuration renders seconds as m:ss.
+func formatDuration(seconds int) string {
+	return fmt.Sprintf("%d:%02d", seconds/60, seconds%60)
+}
+
+// HandleVideo processes a video message.
+func (h *Handlers) HandleVideo(ctx context.Context, chatID int64, video *tgbotapi.Video, caption string) {
+	ext := "mp4"
+	if _, sub, ok := strings.Cut(video.MimeType, "/"); ok && sub != "" {
+		ext = sub
+	}
+	h.handleVideo(ctx, chatID, "video", video.FileID, ext, video.FileSize, video.Duration, caption)
+}
+
+// HandleVideoNote processes a round video message.
+func (h *Handlers) HandleVideoNote(ctx context.Context, chatID int64, note *tgbotapi.VideoNote) {
+	h.handleVideo(ctx, chatID, "video note", note.FileID, "mp4", note.FileSize, note.Duration, "")
+}
+
+func (h *Handlers) handleVideo(ctx context.Context, chatID int64, what, fileID, ext string, size, duration int, caption string) {
+	unlock, ok := h.lockFresh(ctx, chatID, what, caption)
+	if !ok {
+		return
+	}
+	defer unlock()
+
+	slog.Info("received video", "chat_id", chatID, "what", what, "duration", duration, "size", size)
+
+	if h.rejectIfFrozen(chatID) || h.rejectIfOverQuota(ctx, chatID) {
+		return
+	}
+
+	if h.approvals.Has(chatID) {
+		h.sender.SendPlain(chatID, h.t(chatID, "approval.pending_first"))
+		return
+	}
+
+	h.sender.SendTyping(chatID)
+
+	path, ok := h.downloadMedia(chatID, what, fileID, ext, size)
+	if !ok {
+		return
+	}
+	defer h.media.Cleanup(path)
+
+	message := fmt.Sprintf("The user sent a %s (%s) saved at %s.", what, formatDuration(duration), path)
+
+	audio, err := h.media.ExtractAudio(ctx, path)
+	switch {
+	case errors.Is(err, errNoAudio):
+		message += "\nIt has no sound."
+	case err != nil:
+		slog.Warn("extract video audio failed", "chat_id", chatID, "err", err)
+		message += "\nIts audio could not be extracted."
+	default:
+		transcript, err := h.media.TranscribeAudio(ctx, audio)
+		h.media.Cleanup(audio)
+		if err != nil {
+			slog.Error("transcription failed", "chat_id", chatID, "err", err)
+			message += "\nIts audio could not be transcribed."
+		} else if transcript == "" {
+			message += "\nNo speech was recognized in its audio."
+		} else {
+			message += fmt.Sprintf("\nTranscript of its audio: %s", transcript)
+		}
+	}
+
+	if h.media.keyframes > 0 {
+		frames, err := h.media.ExtractFrames(ctx, path, duration)
+		if err != nil {
+			slog.Warn("extract video frames failed", "chat_id", chatID, "err", err)
+		}
+		defer h.media.Cleanup(frames...)
+		if len(frames) > 0 {
+			message += fmt.Sprintf("\nFrames from across the video are saved at %s. Please read and analyze them.", strings.Join(frames, ", "))
+		}
+	}
+
+	if caption != "" {
+		message += fmt.Sprintf("\nUser's message: %s", caption)
+	}
+
+	h.callAI(ctx, chatID, message)
+}
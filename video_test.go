@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeFFmpeg writes an ffmpeg stand-in that logs its arguments and writes
+// its output file (the last argument). With silent it fails like ffmpeg on
+// a video without an audio track. Seeking to 10s writes nothing, as past
+// the end of a video.
+func fakeFFmpeg(t *testing.T, silent bool) (path, log string) {
+	t.Helper()
+	dir := t.TempDir()
+	log = filepath.Join(dir, "calls.log")
+	script := `#!/bin/sh
+echo "$*" >> "` + log + `"
+for out; do :; done
+case "$*" in
+*"-map 0:a:0"*) if [ "` + map[bool]string{true: "1", false: ""}[silent] + `" ]; then echo "Stream map '0:a:0' matches no streams." >&2; exit 1; fi ;;
+*"-ss 10."*) exit 0 ;;
+esac
+echo data > "$out"
+`
+	path = filepath.Join(dir, "ffmpeg")
+	if err := os.WriteFile(path, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return path, log
+}
+
+func TestExtractAudio(t *testing.T) {
+	video := filepath.Join(t.TempDir(), "clip.mp4")
+	ffmpeg, log := fakeFFmpeg(t, false)
+	m := &MediaHandler{ffmpeg: ffmpeg}
+
+	audio, err := m.ExtractAudio(context.Background(), video)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := strings.TrimSuffix(video, ".mp4") + ".wav"; audio != want {
+		t.Errorf("audio = %s, want %s", audio, want)
+	}
+	if data, _ := os.ReadFile(log); !strings.Contains(string(data), "-i "+video+" -vn -map 0:a:0 -ac 1 -ar 16000") {
+		t.Errorf("ffmpeg ran with %s", data)
+	}
+
+	m.ffmpeg, _ = fakeFFmpeg(t, true)
+	if _, err := m.ExtractAudio(context.Background(), video); !errors.Is(err, errNoAudio) {
+		t.Errorf("silent video: err = %v", err)
+	}
+
+	m.ffmpeg = filepath.Join(t.TempDir(), "missing")
+	if _, err := m.ExtractAudio(context.Background(), video); err == nil || errors.Is(err, errNoAudio) {
+		t.Errorf("missing ffmpeg: err = %v", err)
+	}
+}
+
+func TestExtractFrames(t *testing.T) {
+	video := filepath.Join(t.TempDir(), "clip.mp4")
+	ffmpeg, log := fakeFFmpeg(t, false)
+	m := &MediaHandler{ffmpeg: ffmpeg, keyframes: 3}
+
+	frames, err := m.ExtractFrames(context.Background(), video, 12)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The frame at 10s is past the end in the fake.
+	if len(frames) != 2 || !strings.HasSuffix(frames[0], "clip_frame1.jpg") || !strings.HasSuffix(frames[1], "clip_frame2.jpg") {
+		t.Errorf("frames = %v", frames)
+	}
+	data, _ := os.ReadFile(log)
+	for _, at := range []string{"-ss 2.00 ", "-ss 6.00 ", "-ss 10.00 "} {
+		if !strings.Contains(string(data), at) {
+			t.Errorf("no frame taken with %q:\n%s", at, data)
+		}
+	}
+
+	// A video shorter than the frame count gives one frame per second.
+	os.Remove(log)
+	if frames, err := m.ExtractFrames(context.Background(), video, 1); err != nil || len(frames) != 1 {
+		t.Errorf("1s video: frames = %v, err = %v", frames, err)
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// webhookQueueSize buffers updates between the HTTP handler and the update loop.
+const webhookQueueSize = 100
+
+// webhookMaxBody caps the size of one update posted to the webhook.
+const webhookMaxBody = 1 << 20
+
+// telegramWebhookPath derives an unguessable URL path from the bot token, so
+// only Telegram (which is told the full URL) can post updates to it.
+func telegramWebhookPath(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return "/telegram/" + hex.EncodeToString(sum[:16])
+}
+
+// newWebhookSecret returns a random secret_token for setWebhook. Telegram
+// sends it back in the X-Telegram-Bot-Api-Secret-Token header of every
+// update, so posts to a leaked webhook URL are still refused.
+func newWebhookSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// startWebhook serves Telegram updates on WEBHOOK_LISTEN and registers
+// WEBHOOK_URL with Telegram. WEBHOOK_URL=ngrok opens an ngrok tunnel to the
+// listen port and uses its public URL.
+func (b *Bot) startWebhook() (tgbotapi.UpdatesChannel, error) {
+	path := telegramWebhookPath(b.cfg.TelegramToken)
+	secret, err := newWebhookSecret()
+	if err != nil {
+		return nil, fmt.Errorf("webhook secret: %w", err)
+	}
+	updates := make(chan tgbotapi.Update, webhookQueueSize)
+	stop := make(chan struct{})
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+		token := r.Header.Get("X-Telegram-Bot-Api-Secret-Token")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(secret)) != 1 {
+			slog.Warn("Telegram webhook: invalid secret token", "remote", r.RemoteAddr)
+			http.Error(w, "invalid secret token", http.StatusUnauthorized)
+			return
+		}
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, webhookMaxBody))
+		if err != nil {
+			code := http.StatusBadRequest
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				code = http.StatusRequestEntityTooLarge
+			}
+			http.Error(w, err.Error(), code)
+			return
+		}
+		b.handlers.topics.NoteWebhookUpdate(body)
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		update, err := b.api.HandleUpdate(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		select {
+		case <-stop:
+			http.Error(w, "shutting down", http.StatusServiceUnavailable)
+		default:
+			select {
+			case updates <- *update:
+			case <-stop:
+				http.Error(w, "shutting down", http.StatusServiceUnavailable)
+			}
+		}
+	})
+
+	ln, err := net.Listen("tcp", b.cfg.WebhookListen)
+	if err != nil {
+		return nil, fmt.Errorf("listen on %s: %w", b.cfg.WebhookListen, err)
+	}
+	b.webhookServer = &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		var err error
+		if b.cfg.WebhookTLSCert != "" {
+			err = b.webhookServer.ServeTLS(ln, b.cfg.WebhookTLSCert, b.cfg.WebhookTLSKey)
+		} else {
+			err = b.webhookServer.Serve(ln)
+		}
+		if err != nil && err != http.ErrServerClosed {
+			slog.Warn("Telegram webhook server stopped", "err", err)
+		}
+	}()
+
+	baseURL := b.cfg.WebhookURL
+	if baseURL == "ngrok" {
+		port := ln.Addr().(*net.TCPAddr).Port
+		t, err := b.handlers.tunnels.Start(context.Background(), 0, port)
+		if err != nil {
+			b.webhookServer.Close()
+			return nil, fmt.Errorf("ngrok tunnel for port %d: %w", port, err)
+		}
+		baseURL = t.URL
+	}
+
+	hookURL, err := url.Parse(strings.TrimRight(baseURL, "/") + path)
+	if err != nil {
+		b.webhookServer.Close()
+		return nil, fmt.Errorf("webhook URL: %w", err)
+	}
+	// tgbotapi's WebhookConfig has no secret_token, so setWebhook is called
+	// directly.
+	params := tgbotapi.Params{"url": hookURL.String(), "secret_token": secret}
+	if _, err := b.api.MakeRequest("setWebhook", params); err != nil {
+		b.webhookServer.Close()
+		return nil, fmt.Errorf("setWebhook: %w", err)
+	}
+	slog.Info("receiving updates via webhook", "url", baseURL+"/telegram/…", "listen", ln.Addr().String())
+	b.webhookStop, b.webhookQueue = stop, updates
+	return updates, nil
+}
+
+// stopWebhook shuts down the webhook HTTP server, if running, and closes
+// the update queue so the update loop ends as it does after long polling.
+// Telegram retries the updates refused meanwhile after the restart.
+func (b *Bot) stopWebhook() {
+	if b.webhookStop == nil {
+		return
+	}
+	close(b.webhookStop)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	b.webhookServer.Shutdown(ctx)
+	close(b.webhookQueue)
+	b.webhookStop, b.webhookQueue = nil, nil
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// With CHAT_WORKDIRS (the default) each chat works in its own directory,
+// WORK_DIR/<chatID>, instead of sharing WORK_DIR. Commands the bot runs
+// are checked against a path policy that keeps them out of the other
+// chats' directories, and CHAT_DISK_QUOTA caps what a chat may store.
+// Like the safeguard, the policy matches the command text: it is a guard
+// against mistakes and casual snooping, not a sandbox. Claude's own tools
+// (ALLOWED_TOOLS, auto-execute) only start in the chat's directory.
+
+var (
+	// ErrOutsideWorkspace is returned (wrapped) when a command refers to
+	// another chat's directory.
+	ErrOutsideWorkspace = errors.New("outside this chat's workspace")
+	// ErrOverQuota is returned (wrapped) when the chat's directory is over
+	// CHAT_DISK_QUOTA.
+	ErrOverQuota = errors.New("workspace over its disk quota")
+)
+
+// workspaceUsageTTL is how long a measured directory size is trusted.
+const workspaceUsageTTL = 30 * time.Second
+
+// quotaExempt are the programs still allowed over quota, so space can be
+// freed and inspected.
+var quotaExempt = map[string]bool{"rm": true, "rmdir": true, "du": true, "ls": true, "df": true, "truncate": true}
+
+// Workspaces maps chats to their directories under WORK_DIR.
+type Workspaces struct {
+	root    string // WORK_DIR, absolute
+	isolate bool   // CHAT_WORKDIRS
+	quota   int64  // CHAT_DISK_QUOTA in bytes; 0 = none
+
+	mu    sync.Mutex
+	usage map[int64]measuredUsage
+}
+
+type measuredUsage struct {
+	bytes int64
+	at    time.Time
+}
+
+func NewWorkspaces(workDir string, isolate bool, quota int64) *Workspaces {
+	if abs, err := filepath.Abs(workDir); err == nil {
+		workDir = abs
+	}
+	if !isolate {
+		quota = 0
+	}
+	return &Workspaces{root: workDir, isolate: isolate, quota: quota, usage: make(map[int64]measuredUsage)}
+}
+
+// Dir returns the chat's directory, creating it on first use: WORK_DIR
+// itself when chats share it.
+func (w *Workspaces) Dir(chatID int64) string {
+	if !w.isolate {
+		return w.root
+	}
+	dir := filepath.Join(w.root, strconv.FormatInt(chatID, 10))
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		slog.Warn("create chat workspace failed", "chat_id", chatID, "dir", dir, "err", err)
+	}
+	return dir
+}
+
+// Foreign reports whether path is WORK_DIR itself or inside another chat's
+// directory. Paths elsewhere, such as the repositories cloned from
+// GIT_REPOS into WORK_DIR, are shared.
+func (w *Workspaces) Foreign(chatID int64, path string) bool {
+	if !w.isolate {
+		return false
+	}
+	rel, err := filepath.Rel(w.root, filepath.Clean(path))
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return false
+	}
+	if rel == "." {
+		return true
+	}
+	first, _, _ := strings.Cut(rel, string(filepath.Separator))
+	if strings.ContainsAny(first, "*?[") {
+		return true
+	}
+	id, err := strconv.ParseInt(first, 10, 64)
+	return err == nil && id != chatID
+}
+
+// Encloses reports whether path is WORK_DIR or one of its parents, and so
+// holds every chat's directory.
+func (w *Workspaces) Encloses(path string) bool {
+	if !w.isolate {
+		return false
+	}
+	rel, err := filepath.Rel(filepath.Clean(path), w.root)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// CheckPaths refuses a command that runs in, or names, WORK_DIR or another
+// chat's directory: as an absolute path, through $VARIABLES, or relative
+// to dir (the directory it runs in) or to where a cd in the command moved.
+func (w *Workspaces) CheckPaths(chatID int64, dir, command string) error {
+	if !w.isolate {
+		return nil
+	}
+	if w.Foreign(chatID, dir) {
+		return fmt.Errorf("%w: %s", ErrOutsideWorkspace, dir)
+	}
+	cd := false
+	for _, word := range strings.FieldsFunc(command, isShellSeparator) {
+		word = os.ExpandEnv(strings.Trim(word, `'"`))
+		if _, v, ok := strings.Cut(word, "="); ok && strings.ContainsRune(v, filepath.Separator) {
+			word = v // --output=/path, VAR=/path
+		}
+		path := word
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, word)
+		}
+		if w.Foreign(chatID, path) {
+			return fmt.Errorf("%w: %s", ErrOutsideWorkspace, word)
+		}
+		if cd && !strings.HasPrefix(word, "-") {
+			dir, cd = path, false
+		}
+		if word == "cd" || word == "pushd" {
+			cd = true
+		}
+	}
+	return nil
+}
+
+func isShellSeparator(r rune) bool {
+	switch r {
+	case ' ', '\t', '\n', ';', '|', '&', '<', '>', '(', ')', '`':
+		return true
+	}
+	return false
+}
+
+// Usage returns how many bytes the chat's directory holds, measured at
+// most every workspaceUsageTTL.
+func (w *Workspaces) Usage(chatID int64) int64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if u, ok := w.usage[chatID]; ok && time.Since(u.at) < workspaceUsageTTL {
+		return u.bytes
+	}
+	n := dirSize(w.Dir(chatID))
+	w.usage[chatID] = measuredUsage{bytes: n, at: time.Now()}
+	return n
+}
+
+// Changed drops the chat's measured size after something wrote to it.
+func (w *Workspaces) Changed(chatID int64) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	delete(w.usage, chatID)
+}
+
+// Free returns the bytes the chat may still store, or -1 without a quota.
+func (w *Workspaces) Free(chatID int64) int64 {
+	if w.quota <= 0 {
+		return -1
+	}
+	return max(w.quota-w.Usage(chatID), 0)
+}
+
+// CheckQuota refuses a command while the chat's directory is over its
+// quota, unless it only inspects or deletes files (see quotaExempt).
+func (w *Workspaces) CheckQuota(chatID int64, command string) error {
+	if w.quota <= 0 || quotaExempt[filepath.Base(confirmPhrase(command))] {
+		return nil
+	}
+	if used := w.Usage(chatID); used >= w.quota {
+		return fmt.Errorf("%w (%s of %s); delete files to run other commands", ErrOverQuota, formatBytes(used), formatBytes(w.quota))
+	}
+	return nil
+}
+
+// dirSize adds up the sizes of the regular files under dir.
+func dirSize(dir string) int64 {
+	var n int64
+	filepath.WalkDir(dir, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.Type().IsRegular() {
+			if fi, err := d.Info(); err == nil {
+				n += fi.Size()
+			}
+		}
+		return nil
+	})
+	return n
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWorkspaceDir(t *testing.T) {
+	root := t.TempDir()
+	if got := NewWorkspaces(root, false, 0).Dir(7); got != root {
+		t.Errorf("shared Dir = %q, want %q", got, root)
+	}
+	w := NewWorkspaces(root, true, 0)
+	dir := w.Dir(-1007)
+	if dir != filepath.Join(root, "-1007") {
+		t.Errorf("Dir = %q", dir)
+	}
+	if fi, err := os.Stat(dir); err != nil || fi.Mode().Perm() != 0o700 {
+		t.Errorf("workspace not created private: %v %v", fi, err)
+	}
+}
+
+func TestWorkspaceCheckPaths(t *testing.T) {
+	root := t.TempDir()
+	w := NewWorkspaces(root, true, 0)
+	dir := w.Dir(1)
+	t.Setenv("WORK_DIR", root)
+	for _, tc := range []struct {
+		cmd     string
+		refused bool
+	}{
+		{"ls -la", false},
+		{"cat notes.txt", false},
+		{"cat " + filepath.Join(root, "1", "notes.txt"), false},
+		{"cd " + filepath.Join(root, "myrepo") + " && git pull", false},
+		{"cat /etc/hostname", false},
+		{"cat " + filepath.Join(root, "2", "notes.txt"), true},
+		{"cat '" + filepath.Join(root, "-100200") + "/x'", true},
+		{"ls " + root, true},
+		{"ls " + root + "/*/secrets", true},
+		{"cat ../2/notes.txt", true},
+		{"cd .. && ls", true},
+		{"cat ../1/notes.txt", false},
+		{"grep -r key $WORK_DIR/2", true},
+		{"tar -C /tmp --file=" + filepath.Join(root, "2", "a.tar") + " -x", true},
+		{"echo hi>" + filepath.Join(root, "3", "x"), true},
+	} {
+		err := w.CheckPaths(1, dir, tc.cmd)
+		if got := errors.Is(err, ErrOutsideWorkspace); got != tc.refused {
+			t.Errorf("CheckPaths(%q) = %v, want refused %v", tc.cmd, err, tc.refused)
+		}
+	}
+	if err := NewWorkspaces(root, false, 0).CheckPaths(1, root, "cat "+filepath.Join(root, "2", "x")); err != nil {
+		t.Errorf("shared WORK_DIR refused a command: %v", err)
+	}
+
+	// Relative paths resolve against where the command runs: a tracked
+	// cwd, or a cd earlier in the command.
+	parent, base := filepath.Dir(root), filepath.Base(root)
+	for _, tc := range []struct {
+		dir, cmd string
+		refused  bool
+	}{
+		{root, "ls", true},
+		{filepath.Join(root, "2"), "cat notes.txt", true},
+		{parent, "cat " + filepath.Join(base, "2", "notes.txt"), true},
+		{parent, "cat " + filepath.Join(base, "1", "notes.txt"), false},
+		{dir, "cd " + parent + " && cat " + filepath.Join(base, "2", "x"), true},
+		{dir, "cd /tmp && cat x", false},
+	} {
+		err := w.CheckPaths(1, tc.dir, tc.cmd)
+		if got := errors.Is(err, ErrOutsideWorkspace); got != tc.refused {
+			t.Errorf("CheckPaths(%q in %s) = %v, want refused %v", tc.cmd, tc.dir, err, tc.refused)
+		}
+	}
+}
+
+func TestWorkspaceQuota(t *testing.T) {
+	w := NewWorkspaces(t.TempDir(), true, 100)
+	if err := w.CheckQuota(1, "make build"); err != nil {
+		t.Fatalf("empty workspace over quota: %v", err)
+	}
+	if free := w.Free(1); free != 100 {
+		t.Errorf("Free = %d, want 100", free)
+	}
+	os.WriteFile(filepath.Join(w.Dir(1), "big"), []byte(strings.Repeat("x", 150)), 0o600)
+	if err := w.CheckQuota(1, "make build"); err != nil {
+		t.Errorf("measured size not cached: %v", err)
+	}
+	w.Changed(1)
+	if err := w.CheckQuota(1, "make build"); !errors.Is(err, ErrOverQuota) {
+		t.Errorf("CheckQuota over quota = %v", err)
+	}
+	if err := w.CheckQuota(1, "sudo rm big"); err != nil {
+		t.Errorf("cleanup refused over quota: %v", err)
+	}
+	if free := w.Free(1); free != 0 {
+		t.Errorf("Free over quota = %d", free)
+	}
+	if err := w.CheckQuota(2, "make build"); err != nil {
+		t.Errorf("quota shared between chats: %v", err)
+	}
+	if NewWorkspaces(t.TempDir(), false, 100).Free(1) != -1 {
+		t.Error("quota applied to a shared WORK_DIR")
+	}
+}
+
+func TestProjectStoreRefusesOtherChats(t *testing.T) {
+	root := t.TempDir()
+	s := NewProjectStore(t.TempDir(), NewWorkspaces(root, true, 0))
+	if _, dir := s.Active(1); dir != filepath.Join(root, "1") {
+		t.Errorf("default project = %q", dir)
+	}
+	if _, err := s.Add(1, "peek", "2/src"); !errors.Is(err, ErrOutsideWorkspace) {
+		t.Errorf("Add into another chat = %v", err)
+	}
+	if dir, err := s.Add(1, "web", "1/web"); err != nil || dir != filepath.Join(root, "1", "web") {
+		t.Errorf("Add = %q, %v", dir, err)
+	}
+	if _, err := s.Add(1, "repo", "myrepo"); err != nil {
+		t.Errorf("shared repo refused: %v", err)
+	}
+}